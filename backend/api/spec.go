@@ -0,0 +1,9 @@
+// Package api holds the published OpenAPI description of the HTTP API.
+package api
+
+import _ "embed"
+
+// OpenAPISpec is the OpenAPI 3 document served by the API, in YAML.
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
@@ -0,0 +1,79 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Alert describes an operational event that should reach a human.
+type Alert struct {
+	Title     string            `json:"title"`
+	Message   string            `json:"message"`
+	RequestID string            `json:"requestId,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	Time      time.Time         `json:"time"`
+}
+
+// Notifier delivers alerts to an external channel.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NopNotifier discards every alert.
+type NopNotifier struct{}
+
+// Notify implements Notifier.
+func (NopNotifier) Notify(context.Context, Alert) error { return nil }
+
+// WebhookNotifier posts alerts as JSON to an incoming webhook. The payload
+// carries a "text" field so Slack-compatible endpoints render it directly.
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier builds a notifier for url; a nil client uses a default
+// one with a short timeout.
+func NewWebhookNotifier(url string, client *http.Client) *WebhookNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &WebhookNotifier{url: url, client: client}
+}
+
+type webhookPayload struct {
+	Text string `json:"text"`
+	Alert
+}
+
+// Notify implements Notifier.
+func (w *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	text := fmt.Sprintf("*%s*\n%s", alert.Title, alert.Message)
+	if alert.RequestID != "" {
+		text += "\nrequest: " + alert.RequestID
+	}
+
+	body, err := json.Marshal(webhookPayload{Text: text, Alert: alert})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook respondio %d", resp.StatusCode)
+	}
+	return nil
+}
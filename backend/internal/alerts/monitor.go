@@ -0,0 +1,159 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// monitorBuckets is how many slices the rolling window is split into.
+const monitorBuckets = 30
+
+// MonitorConfig sets the thresholds of a Monitor. A zero ErrorRate or
+// LoginFailures disables that check.
+type MonitorConfig struct {
+	// Window is the rolling window the rates are measured over.
+	Window time.Duration
+	// ErrorRate is the percentage of 5xx responses that fires an alert,
+	// once the window has at least MinRequests requests.
+	ErrorRate   float64
+	MinRequests int
+	// LoginFailures is the number of failed logins in the window that
+	// fires an alert.
+	LoginFailures int
+	// Cooldown is the minimum time between two alerts of the same kind;
+	// it defaults to Window.
+	Cooldown time.Duration
+}
+
+type monitorBucket struct {
+	start         time.Time
+	requests      int
+	errors        int
+	loginFailures int
+}
+
+// Monitor tracks the error rate of the API and the failed logins over a
+// rolling window, in memory, and notifies when they exceed the thresholds.
+// Each instance of the API monitors its own traffic.
+type Monitor struct {
+	cfg      MonitorConfig
+	notifier Notifier
+	now      func() time.Time
+
+	mu      sync.Mutex
+	buckets [monitorBuckets]monitorBucket
+	alerted map[string]time.Time
+}
+
+// NewMonitor builds a monitor that sends its alerts to notifier.
+func NewMonitor(cfg MonitorConfig, notifier Notifier, now func() time.Time) *Monitor {
+	if notifier == nil {
+		notifier = NopNotifier{}
+	}
+	if now == nil {
+		now = time.Now
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Minute
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = cfg.Window
+	}
+	return &Monitor{cfg: cfg, notifier: notifier, now: now, alerted: map[string]time.Time{}}
+}
+
+// Request records a response with status.
+func (m *Monitor) Request(status int) {
+	now := m.now()
+	m.mu.Lock()
+	b := m.bucket(now)
+	b.requests++
+	if status >= 500 {
+		b.errors++
+	}
+	var alert *Alert
+	if m.cfg.ErrorRate > 0 {
+		requests, errors, _ := m.totals(now)
+		rate := float64(errors) * 100 / float64(requests)
+		if requests >= m.cfg.MinRequests && rate >= m.cfg.ErrorRate && m.fire("error_rate", now) {
+			alert = &Alert{
+				Title:   "Tasa de errores elevada",
+				Message: fmt.Sprintf("%d de %d solicitudes (%.1f%%) fallaron en los ultimos %s", errors, requests, rate, m.cfg.Window),
+				Fields:  map[string]string{"errors": strconv.Itoa(errors), "requests": strconv.Itoa(requests), "window": m.cfg.Window.String()},
+				Time:    now,
+			}
+		}
+	}
+	m.mu.Unlock()
+	m.notify(alert)
+}
+
+// LoginFailed records a rejected login.
+func (m *Monitor) LoginFailed() {
+	now := m.now()
+	m.mu.Lock()
+	m.bucket(now).loginFailures++
+	var alert *Alert
+	if m.cfg.LoginFailures > 0 {
+		_, _, failures := m.totals(now)
+		if failures >= m.cfg.LoginFailures && m.fire("login_failures", now) {
+			alert = &Alert{
+				Title:   "Pico de inicios de sesion fallidos",
+				Message: fmt.Sprintf("%d inicios de sesion fallidos en los ultimos %s", failures, m.cfg.Window),
+				Fields:  map[string]string{"failures": strconv.Itoa(failures), "window": m.cfg.Window.String()},
+				Time:    now,
+			}
+		}
+	}
+	m.mu.Unlock()
+	m.notify(alert)
+}
+
+// bucket returns the slice of the window now falls in, emptying it when it
+// last held an older slice.
+func (m *Monitor) bucket(now time.Time) *monitorBucket {
+	width := m.cfg.Window / monitorBuckets
+	start := now.Truncate(width)
+	b := &m.buckets[(start.UnixNano()/int64(width))%monitorBuckets]
+	if !b.start.Equal(start) {
+		*b = monitorBucket{start: start}
+	}
+	return b
+}
+
+func (m *Monitor) totals(now time.Time) (requests, errors, loginFailures int) {
+	for _, b := range m.buckets {
+		if now.Sub(b.start) < m.cfg.Window {
+			requests += b.requests
+			errors += b.errors
+			loginFailures += b.loginFailures
+		}
+	}
+	return requests, errors, loginFailures
+}
+
+// fire reports whether an alert of kind is due, recording it if so.
+func (m *Monitor) fire(kind string, now time.Time) bool {
+	if last, ok := m.alerted[kind]; ok && now.Sub(last) < m.cfg.Cooldown {
+		return false
+	}
+	m.alerted[kind] = now
+	return true
+}
+
+func (m *Monitor) notify(alert *Alert) {
+	if alert == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.notifier.Notify(ctx, *alert); err != nil {
+			log.Printf("no se pudo enviar la alerta %q: %v", alert.Title, err)
+		}
+	}()
+}
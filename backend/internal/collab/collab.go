@@ -0,0 +1,247 @@
+// Package collab merges concurrent edits of a text with operational
+// transformation. An edit is an Op that walks the whole text: it retains,
+// inserts and deletes runs of characters. The server keeps the ops it
+// applied, so an op written against an older version is transformed over
+// the ones applied since and every client converges on the same text.
+//
+// Positions and lengths count Unicode code points.
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrInvalidOp is returned for ops that are malformed or do not fit the
+// text they are applied or transformed against.
+var ErrInvalidOp = errors.New("invalid op")
+
+// Component is one step of an op; exactly one field is set.
+type Component struct {
+	Retain int    `bson:"retain,omitempty"`
+	Insert string `bson:"insert,omitempty"`
+	Delete int    `bson:"delete,omitempty"`
+}
+
+// Op is an edit of a whole text. As JSON it is an array where a positive
+// number retains that many characters, a negative one deletes them and a
+// string inserts it, e.g. [5, " nuevo", -3] (the format of ot.js).
+type Op []Component
+
+// Replace returns the op that replaces from with to.
+func Replace(from, to string) Op {
+	var op Op
+	op = op.delete(utf8.RuneCountInString(from))
+	return op.insert(to)
+}
+
+// BaseLength is the length of the texts op applies to.
+func (op Op) BaseLength() int {
+	n := 0
+	for _, c := range op {
+		n += c.Retain + c.Delete
+	}
+	return n
+}
+
+// TargetLength is the length of the texts op produces.
+func (op Op) TargetLength() int {
+	n := 0
+	for _, c := range op {
+		n += c.Retain + utf8.RuneCountInString(c.Insert)
+	}
+	return n
+}
+
+// Validate checks that every component sets exactly one positive step.
+func (op Op) Validate() error {
+	for _, c := range op {
+		set := 0
+		if c.Retain != 0 {
+			set++
+		}
+		if c.Insert != "" {
+			set++
+		}
+		if c.Delete != 0 {
+			set++
+		}
+		if set != 1 || c.Retain < 0 || c.Delete < 0 {
+			return ErrInvalidOp
+		}
+	}
+	return nil
+}
+
+// Apply returns text edited by op.
+func (op Op) Apply(text string) (string, error) {
+	if err := op.Validate(); err != nil {
+		return "", err
+	}
+	runes := []rune(text)
+	if op.BaseLength() != len(runes) {
+		return "", fmt.Errorf("%w: it edits %d characters, the text has %d", ErrInvalidOp, op.BaseLength(), len(runes))
+	}
+	out := make([]rune, 0, op.TargetLength())
+	i := 0
+	for _, c := range op {
+		switch {
+		case c.Retain > 0:
+			out = append(out, runes[i:i+c.Retain]...)
+			i += c.Retain
+		case c.Delete > 0:
+			i += c.Delete
+		default:
+			out = append(out, []rune(c.Insert)...)
+		}
+	}
+	return string(out), nil
+}
+
+// Transform returns a' and b', the ops a and b rewritten to apply after
+// each other, given both were written against the same text: applying a
+// then b' gives the same text as b then a'. When both insert at the same
+// place, the text of a goes first.
+func Transform(a, b Op) (Op, Op, error) {
+	if a.Validate() != nil || b.Validate() != nil || a.BaseLength() != b.BaseLength() {
+		return nil, nil, ErrInvalidOp
+	}
+	var aPrime, bPrime Op
+	i, j := 0, 0
+	var x, y Component
+	next := func(op Op, k *int) Component {
+		if *k >= len(op) {
+			return Component{}
+		}
+		*k++
+		return op[*k-1]
+	}
+	x, y = next(a, &i), next(b, &j)
+	for x != (Component{}) || y != (Component{}) {
+		switch {
+		case x.Insert != "":
+			aPrime = aPrime.insert(x.Insert)
+			bPrime = bPrime.retain(utf8.RuneCountInString(x.Insert))
+			x = next(a, &i)
+			continue
+		case y.Insert != "":
+			aPrime = aPrime.retain(utf8.RuneCountInString(y.Insert))
+			bPrime = bPrime.insert(y.Insert)
+			y = next(b, &j)
+			continue
+		case x == (Component{}) || y == (Component{}):
+			// Equal base lengths make both ops run out together.
+			return nil, nil, ErrInvalidOp
+		}
+
+		n := min(x.Retain+x.Delete, y.Retain+y.Delete)
+		switch {
+		case x.Retain > 0 && y.Retain > 0:
+			aPrime, bPrime = aPrime.retain(n), bPrime.retain(n)
+		case x.Delete > 0 && y.Retain > 0:
+			aPrime = aPrime.delete(n)
+		case x.Retain > 0 && y.Delete > 0:
+			bPrime = bPrime.delete(n)
+		}
+		// Both deleting the same characters leaves nothing to do.
+		x, y = consume(x, n), consume(y, n)
+		if x == (Component{}) {
+			x = next(a, &i)
+		}
+		if y == (Component{}) {
+			y = next(b, &j)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// consume takes n characters off a retain or delete component.
+func consume(c Component, n int) Component {
+	if c.Retain > 0 {
+		c.Retain -= n
+	} else {
+		c.Delete -= n
+	}
+	return c
+}
+
+func (op Op) retain(n int) Op {
+	if n <= 0 {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].Retain > 0 {
+		op[last].Retain += n
+		return op
+	}
+	return append(op, Component{Retain: n})
+}
+
+func (op Op) insert(s string) Op {
+	if s == "" {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].Insert != "" {
+		op[last].Insert += s
+		return op
+	}
+	return append(op, Component{Insert: s})
+}
+
+func (op Op) delete(n int) Op {
+	if n <= 0 {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].Delete > 0 {
+		op[last].Delete += n
+		return op
+	}
+	return append(op, Component{Delete: n})
+}
+
+// MarshalJSON encodes op in the ot.js format.
+func (op Op) MarshalJSON() ([]byte, error) {
+	out := make([]any, 0, len(op))
+	for _, c := range op {
+		switch {
+		case c.Retain > 0:
+			out = append(out, c.Retain)
+		case c.Delete > 0:
+			out = append(out, -c.Delete)
+		default:
+			out = append(out, c.Insert)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes op from the ot.js format.
+func (op *Op) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var out Op
+	for _, item := range raw {
+		var n int
+		if err := json.Unmarshal(item, &n); err == nil {
+			if n == 0 {
+				return ErrInvalidOp
+			}
+			if n > 0 {
+				out = out.retain(n)
+			} else {
+				out = out.delete(-n)
+			}
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(item, &s); err != nil || s == "" {
+			return ErrInvalidOp
+		}
+		out = out.insert(s)
+	}
+	*op = out
+	return nil
+}
@@ -0,0 +1,634 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config groups every runtime setting read from the environment.
+type Config struct {
+	Port     string
+	MongoURI string
+	MongoDB  string
+	// MongoCollectionPrefix is prepended to every collection name, so
+	// environments like QA and PROD can share a cluster and a database.
+	MongoCollectionPrefix string
+	// MongoValidationAction is what MongoDB does with the writes that break
+	// the schemas of the users, todos and bookings: "error" rejects them,
+	// "warn" only logs them and "off" leaves the validators as they are.
+	MongoValidationAction string
+	TLS                   TLSConfig
+	// ConfigFile holds KEY=VALUE lines that override the environment; it is
+	// checked for changes every ConfigWatchInterval (zero only reloads it
+	// on SIGHUP).
+	ConfigFile          string
+	ConfigWatchInterval time.Duration
+	// AllowedOrigins are the frontend origins allowed by CORS.
+	AllowedOrigins []string
+	Secrets        SecretsConfig
+	// TrustedProxies holds the CIDRs of reverse proxies (e.g. Nginx) allowed
+	// to set X-Forwarded-For.
+	TrustedProxies []string
+	IPAccess       IPAccessConfig
+	// RequestTimeout is the default per-request budget; RouteTimeouts
+	// overrides it for specific "METHOD /path" routes.
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+	// SlowQueryThreshold logs database commands slower than this value.
+	SlowQueryThreshold time.Duration
+	// ExplainQueries logs the plan MongoDB picks for each todo listing.
+	ExplainQueries bool
+	Resilience     ResilienceConfig
+	MongoPool      MongoPoolConfig
+	// AdminToken is the shared secret for /admin endpoints (disabled if empty).
+	AdminToken string
+	// MaintenanceMode starts the API rejecting writes with 503; clients are
+	// told to retry after MaintenanceRetryAfter.
+	MaintenanceMode       bool
+	MaintenanceRetryAfter time.Duration
+	// DeprecationSunsets sets, by name, the date each deprecated endpoint or
+	// field stops working; the Sunset header is left out until it is set.
+	DeprecationSunsets map[string]time.Time
+	RateLimit          RateLimitConfig
+	BodyLog            BodyLogConfig
+	// ServerTiming adds the database and serialization time of each request
+	// to its response, in the Server-Timing header.
+	ServerTiming bool
+	// FaultInjection enables the X-Fault-* headers and /admin/faults, which
+	// slow down, fail or drop requests on purpose. Test environments only.
+	FaultInjection bool
+	// MockIntegrations replaces every outbound integration with a mock that
+	// records what would have been sent, listed in /admin/outbox-preview;
+	// the last MockOutboxSize messages are kept. Test environments only.
+	MockIntegrations bool
+	MockOutboxSize   int
+	// AlertWebhookURL receives alerts (e.g. recovered panics) as JSON.
+	AlertWebhookURL string
+	// AlertMonitor sets the thresholds of the error rate and failed login
+	// alerts.
+	AlertMonitor AlertMonitorConfig
+	// ContractValidation validates responses against the OpenAPI spec in
+	// test/QA environments: "log", "fail" or empty to disable.
+	ContractValidation string
+	// HousekeepingEmails receive the cleaning todos created on check-out.
+	HousekeepingEmails []string
+	// PaymentWebhookSecret signs payment provider notifications; the webhook
+	// is disabled when empty.
+	PaymentWebhookSecret string
+	// RatingCacheTTL is how long room ratings are cached (zero disables it).
+	RatingCacheTTL time.Duration
+	// StatsCacheTTL is how long the productivity breakdown of each account
+	// is cached (zero disables it).
+	StatsCacheTTL time.Duration
+	// SessionTTL is how long login tokens stay valid.
+	SessionTTL time.Duration
+	// ImpersonationTTL is how long the tokens issued to support staff
+	// through impersonation stay valid.
+	ImpersonationTTL time.Duration
+	// RealtimeBridge carries the realtime messages between the replicas:
+	// "memory" (a single replica, the default) or "mongo" (a change stream,
+	// which needs a replica set).
+	RealtimeBridge string
+	// ActivityMaxBytes and ActivityMaxEvents bound the capped collection
+	// that keeps the activity feed; the oldest entries roll out first.
+	ActivityMaxBytes  int
+	ActivityMaxEvents int
+	// ShutdownTimeout is how long the server waits on SIGINT or SIGTERM
+	// for the requests in flight and the queued emails before exiting.
+	ShutdownTimeout time.Duration
+	// WaitlistHold is how long a freed room stays held for the waitlisted
+	// guest; WaitlistInterval is how often the waitlist worker runs.
+	WaitlistHold     time.Duration
+	WaitlistInterval time.Duration
+	Mail             MailConfig
+	Events           EventsConfig
+	Jobs             JobsConfig
+	Quotas           QuotaConfig
+	WebAuthn         WebAuthnConfig
+	Captcha          CaptchaConfig
+	// SSORedirectURL is the page of the frontend the identity providers of
+	// the properties send the browser back to after signing in.
+	SSORedirectURL string
+	Attachments    AttachmentsConfig
+	LinkPreviews   LinkPreviewsConfig
+}
+
+// SecretsConfig selects the secrets manager that holds the credentials:
+// "vault", "aws" or empty to read them only from the environment. Path is
+// the Vault KV path or the AWS secret ID.
+type SecretsConfig struct {
+	Provider           string
+	Path               string
+	VaultAddr          string
+	VaultToken         string
+	AWSRegion          string
+	AWSEndpoint        string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+}
+
+// CaptchaConfig enables the CAPTCHA on registration and on logins after
+// LoginFailures failed attempts within FailureWindow. An empty Provider
+// disables it; TestToken passes without asking the provider (E2E tests).
+type CaptchaConfig struct {
+	Provider      string
+	Secret        string
+	TestToken     string
+	LoginFailures int
+	FailureWindow time.Duration
+}
+
+// WebAuthnConfig identifies the site to passkey authenticators. RPID is the
+// domain passkeys are bound to and Origins the frontend origins allowed to
+// use them.
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// IPAccessConfig lists the CIDRs allowed and denied for every request, for
+// the /admin endpoints and for the testing endpoints that wipe data.
+type IPAccessConfig struct {
+	Allow        []string
+	Deny         []string
+	AdminAllow   []string
+	AdminDeny    []string
+	TestingAllow []string
+	TestingDeny  []string
+}
+
+// QuotaConfig holds the plan limits of the accounts; zero means unlimited.
+// Administrators override them per account.
+type QuotaConfig struct {
+	MaxTodos int
+}
+
+// JobsConfig holds the cron schedules of the background jobs; "off"
+// disables a job.
+type JobsConfig struct {
+	Reminders      string
+	TodoDigest     string
+	TrashPurge     string
+	RecurringTodos string
+	AttachmentScan string
+	TodoArchive    string
+	// TrashRetention is how long deleted todos stay in the trash.
+	TrashRetention time.Duration
+	// ArchiveAfter is how long after completed a todo moves to the archive.
+	ArchiveAfter time.Duration
+	// LeaseTTL is how long the leader replica holds the scheduler lease
+	// without renewing it; Instance names this replica (host and PID by
+	// default).
+	LeaseTTL time.Duration
+	Instance string
+}
+
+// EventsConfig selects where the outbox relay delivers the domain events.
+type EventsConfig struct {
+	// Broker is "memory" (in-process, the default), "nats" or "kafka".
+	Broker string
+	// URL is the NATS server or the Kafka REST proxy.
+	URL string
+	// TopicPrefix is prepended to the event type to name the subject or topic.
+	TopicPrefix string
+	// Webhooks also receive every event, signed with WebhookSecret when set.
+	Webhooks      []string
+	WebhookSecret string
+	// RelayInterval is how often the outbox relay publishes pending events;
+	// failed ones are retried after RetryBackoff, doubling up to MaxBackoff,
+	// and moved to the dead letters after MaxAttempts (zero never does).
+	RelayInterval time.Duration
+	RetryBackoff  time.Duration
+	MaxBackoff    time.Duration
+	MaxAttempts   int
+}
+
+// AlertMonitorConfig sets when the error rate and the failed logins of the
+// last Window fire an alert; zero ErrorRate or LoginFailures disable that
+// alert.
+type AlertMonitorConfig struct {
+	Window time.Duration
+	// ErrorRate is a percentage of 5xx responses, checked once the window
+	// has MinRequests requests.
+	ErrorRate     int
+	MinRequests   int
+	LoginFailures int
+	Cooldown      time.Duration
+}
+
+// MailConfig controls the booking emails. Without SMTPAddr emails are only
+// logged.
+type MailConfig struct {
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	// TemplatesDir overrides the built-in email templates.
+	TemplatesDir string
+	// BaseURL prefixes the review and opt-out links in the emails.
+	BaseURL string
+	// OptOutSecret signs the opt-out links; they are omitted when empty.
+	OptOutSecret string
+	// ReminderDays sends the pre-arrival reminder that many days before
+	// check-in (zero disables it).
+	ReminderDays int
+	// DeliveryWorkers is how many recipients are emailed or notified at
+	// once, with up to DeliveryQueue more waiting; DeliveryInterval is the
+	// least time between two deliveries to the same recipient.
+	DeliveryWorkers  int
+	DeliveryQueue    int
+	DeliveryInterval time.Duration
+}
+
+// AttachmentsConfig controls the files attached to todos. The signed
+// download URLs are disabled without URLSecret. Scanner ("clamav" or
+// "http") scans the uploads at ScannerAddr, the clamd address or the URL
+// of the scanning API; empty serves them unscanned.
+type AttachmentsConfig struct {
+	MaxBytes       int
+	URLSecret      string
+	URLTTL         time.Duration
+	Scanner        string
+	ScannerAddr    string
+	ScannerToken   string
+	ScannerTimeout time.Duration
+}
+
+// LinkPreviewsConfig controls the previews of the URLs in todo titles.
+// Each page is fetched once per TTL, waiting up to Timeout.
+type LinkPreviewsConfig struct {
+	Enabled bool
+	TTL     time.Duration
+	Timeout time.Duration
+}
+
+// RateLimitConfig sets how many requests per Window each kind of caller may
+// make: anonymous clients by IP, signed-in users by account and the admin
+// token. Zero leaves a tier unlimited.
+type RateLimitConfig struct {
+	Window    time.Duration
+	Anonymous int
+	User      int
+	Admin     int
+}
+
+// BodyLogConfig controls debug logging of request/response bodies.
+type BodyLogConfig struct {
+	Enabled    bool
+	MaxBytes   int
+	SkipRoutes []string
+}
+
+// MongoPoolConfig holds connection pool and read routing settings; zero values
+// keep the driver defaults.
+type MongoPoolConfig struct {
+	MaxPoolSize            int
+	MinPoolSize            int
+	SocketTimeout          time.Duration
+	ServerSelectionTimeout time.Duration
+	ReadPreference         string
+	// AnalyticsReads routes the todo and user listings, the reports and the
+	// dashboard to AnalyticsReadPreference, accepting data up to
+	// AnalyticsMaxStaleness old.
+	AnalyticsReads          bool
+	AnalyticsReadPreference string
+	AnalyticsMaxStaleness   time.Duration
+}
+
+// ResilienceConfig tunes retries and the circuit breaker around MongoDB calls.
+type ResilienceConfig struct {
+	RetryAttempts    int
+	RetryBackoff     time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// TLSConfig controls how the server terminates TLS.
+type TLSConfig struct {
+	// CertFile and KeyFile point to a PEM certificate/key pair.
+	CertFile string
+	KeyFile  string
+	// AutocertDomains enables Let's Encrypt certificates for the given hosts.
+	AutocertDomains  []string
+	AutocertCacheDir string
+	AutocertEmail    string
+	// RedirectPort, when set, serves a plain HTTP listener that redirects to HTTPS.
+	RedirectPort string
+}
+
+// Enabled reports whether the server should serve HTTPS.
+func (t TLSConfig) Enabled() bool {
+	return t.UsesAutocert() || (t.CertFile != "" && t.KeyFile != "")
+}
+
+// UsesAutocert reports whether certificates are obtained through ACME.
+func (t TLSConfig) UsesAutocert() bool {
+	return len(t.AutocertDomains) > 0
+}
+
+// Load reads the configuration from environment variables, overridden by
+// the file named by CONFIG_FILE, applying defaults. An unreadable file is
+// logged and ignored.
+func Load() Config {
+	cfg, err := LoadFile(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Printf("no se pudo leer CONFIG_FILE, se usa solo el entorno: %v", err)
+		cfg, _ = LoadFile("")
+	}
+	return cfg
+}
+
+// LoadFile is like Load with the overrides of path instead of CONFIG_FILE;
+// an empty path reads only the environment.
+func LoadFile(path string) (Config, error) {
+	values, err := readFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	setFileValues(values)
+	return load(path), nil
+}
+
+func load(path string) Config {
+	return Config{
+		Port:                  String("PORT", "8080"),
+		MongoURI:              String("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDB:               String("MONGO_DB", ""),
+		MongoCollectionPrefix: String("MONGO_COLLECTION_PREFIX", ""),
+		MongoValidationAction: strings.ToLower(String("MONGO_VALIDATION_ACTION", "error")),
+		ConfigFile:            path,
+		ConfigWatchInterval:   Duration("CONFIG_WATCH_INTERVAL", 10*time.Second),
+		AllowedOrigins:        allowedOrigins(),
+		Secrets: SecretsConfig{
+			Provider:           strings.ToLower(String("SECRETS_PROVIDER", "")),
+			Path:               String("SECRETS_PATH", ""),
+			VaultAddr:          String("VAULT_ADDR", ""),
+			VaultToken:         String("VAULT_TOKEN", ""),
+			AWSRegion:          String("AWS_REGION", ""),
+			AWSEndpoint:        String("AWS_ENDPOINT_URL", ""),
+			AWSAccessKeyID:     String("AWS_ACCESS_KEY_ID", ""),
+			AWSSecretAccessKey: String("AWS_SECRET_ACCESS_KEY", ""),
+			AWSSessionToken:    String("AWS_SESSION_TOKEN", ""),
+		},
+		TLS: TLSConfig{
+			CertFile:         String("TLS_CERT_FILE", ""),
+			KeyFile:          String("TLS_KEY_FILE", ""),
+			AutocertDomains:  List("TLS_AUTOCERT_DOMAINS"),
+			AutocertCacheDir: String("TLS_AUTOCERT_CACHE_DIR", "certs"),
+			AutocertEmail:    String("TLS_AUTOCERT_EMAIL", ""),
+			RedirectPort:     String("HTTP_REDIRECT_PORT", ""),
+		},
+		TrustedProxies: List("TRUSTED_PROXIES"),
+		IPAccess: IPAccessConfig{
+			Allow:        List("IP_ALLOW"),
+			Deny:         List("IP_DENY"),
+			AdminAllow:   List("ADMIN_IP_ALLOW"),
+			AdminDeny:    List("ADMIN_IP_DENY"),
+			TestingAllow: List("TESTING_IP_ALLOW"),
+			TestingDeny:  List("TESTING_IP_DENY"),
+		},
+		RequestTimeout:     Duration("REQUEST_TIMEOUT", 10*time.Second),
+		RouteTimeouts:      DurationMap("ROUTE_TIMEOUTS"),
+		SlowQueryThreshold: Duration("SLOW_QUERY_THRESHOLD", 500*time.Millisecond),
+		ExplainQueries:     Bool("EXPLAIN_QUERIES", false),
+		Resilience: ResilienceConfig{
+			RetryAttempts:    Int("MONGO_RETRY_ATTEMPTS", 3),
+			RetryBackoff:     Duration("MONGO_RETRY_BACKOFF", 100*time.Millisecond),
+			BreakerThreshold: Int("MONGO_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  Duration("MONGO_BREAKER_COOLDOWN", 30*time.Second),
+		},
+		MongoPool: MongoPoolConfig{
+			MaxPoolSize:             Int("MONGO_MAX_POOL_SIZE", 0),
+			MinPoolSize:             Int("MONGO_MIN_POOL_SIZE", 0),
+			SocketTimeout:           Duration("MONGO_SOCKET_TIMEOUT", 0),
+			ServerSelectionTimeout:  Duration("MONGO_SERVER_SELECTION_TIMEOUT", 0),
+			ReadPreference:          String("MONGO_READ_PREFERENCE", ""),
+			AnalyticsReads:          Bool("MONGO_ANALYTICS_READS", false),
+			AnalyticsReadPreference: String("MONGO_ANALYTICS_READ_PREFERENCE", "secondaryPreferred"),
+			AnalyticsMaxStaleness:   Duration("MONGO_ANALYTICS_MAX_STALENESS", 90*time.Second),
+		},
+		AdminToken:            String("ADMIN_TOKEN", ""),
+		MaintenanceMode:       Bool("MAINTENANCE_MODE", false),
+		MaintenanceRetryAfter: Duration("MAINTENANCE_RETRY_AFTER", time.Minute),
+		DeprecationSunsets:    DateMap("DEPRECATION_SUNSETS"),
+		RateLimit: RateLimitConfig{
+			Window:    Duration("RATE_LIMIT_WINDOW", time.Minute),
+			Anonymous: Int("RATE_LIMIT_ANONYMOUS", 0),
+			User:      Int("RATE_LIMIT_USER", 0),
+			Admin:     Int("RATE_LIMIT_ADMIN", 0),
+		},
+		BodyLog: BodyLogConfig{
+			Enabled:    Bool("LOG_BODIES", false),
+			MaxBytes:   Int("LOG_BODY_MAX_BYTES", 2048),
+			SkipRoutes: List("LOG_BODY_SKIP_ROUTES"),
+		},
+		ServerTiming:         Bool("SERVER_TIMING", false),
+		FaultInjection:       Bool("FAULT_INJECTION", false),
+		MockIntegrations:     Bool("MOCK_INTEGRATIONS", false),
+		MockOutboxSize:       Int("MOCK_OUTBOX_SIZE", 200),
+		AlertWebhookURL:      String("ALERT_WEBHOOK_URL", ""),
+		ContractValidation:   String("CONTRACT_VALIDATION", ""),
+		HousekeepingEmails:   List("HOUSEKEEPING_EMAILS"),
+		PaymentWebhookSecret: String("PAYMENT_WEBHOOK_SECRET", ""),
+		RatingCacheTTL:       Duration("RATING_CACHE_TTL", 5*time.Minute),
+		StatsCacheTTL:        Duration("STATS_CACHE_TTL", time.Minute),
+		SessionTTL:           Duration("SESSION_TTL", 12*time.Hour),
+		ImpersonationTTL:     Duration("IMPERSONATION_TTL", 15*time.Minute),
+		WaitlistHold:         Duration("WAITLIST_HOLD", 2*time.Hour),
+		WaitlistInterval:     Duration("WAITLIST_INTERVAL", time.Minute),
+		ShutdownTimeout:      Duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		RealtimeBridge:       strings.ToLower(String("REALTIME_BRIDGE", "memory")),
+		ActivityMaxBytes:     Int("ACTIVITY_MAX_BYTES", 16<<20),
+		ActivityMaxEvents:    Int("ACTIVITY_MAX_EVENTS", 10000),
+		AlertMonitor: AlertMonitorConfig{
+			Window:        Duration("ALERT_WINDOW", 5*time.Minute),
+			ErrorRate:     Int("ALERT_ERROR_RATE", 10),
+			MinRequests:   Int("ALERT_MIN_REQUESTS", 50),
+			LoginFailures: Int("ALERT_LOGIN_FAILURES", 30),
+			Cooldown:      Duration("ALERT_COOLDOWN", 15*time.Minute),
+		},
+		Mail: MailConfig{
+			SMTPAddr:         String("SMTP_ADDR", ""),
+			SMTPUsername:     String("SMTP_USERNAME", ""),
+			SMTPPassword:     String("SMTP_PASSWORD", ""),
+			From:             String("MAIL_FROM", "reservas@hotel.local"),
+			TemplatesDir:     String("MAIL_TEMPLATES_DIR", ""),
+			BaseURL:          String("MAIL_BASE_URL", "http://localhost:8080"),
+			OptOutSecret:     String("MAIL_OPT_OUT_SECRET", ""),
+			ReminderDays:     Int("MAIL_REMINDER_DAYS", 3),
+			DeliveryWorkers:  Int("MAIL_DELIVERY_WORKERS", 8),
+			DeliveryQueue:    Int("MAIL_DELIVERY_QUEUE", 1000),
+			DeliveryInterval: Duration("MAIL_DELIVERY_INTERVAL", time.Second),
+		},
+		Events: EventsConfig{
+			Broker:        strings.ToLower(String("EVENTS_BROKER", "memory")),
+			URL:           String("EVENTS_URL", ""),
+			TopicPrefix:   String("EVENTS_TOPIC_PREFIX", "hotel."),
+			Webhooks:      List("EVENTS_WEBHOOKS"),
+			WebhookSecret: String("EVENTS_WEBHOOK_SECRET", ""),
+			RelayInterval: Duration("EVENTS_RELAY_INTERVAL", time.Second),
+			RetryBackoff:  Duration("EVENTS_RETRY_BACKOFF", time.Second),
+			MaxBackoff:    Duration("EVENTS_MAX_BACKOFF", 5*time.Minute),
+			MaxAttempts:   Int("EVENTS_MAX_ATTEMPTS", 10),
+		},
+		Jobs: JobsConfig{
+			Reminders:      String("JOBS_REMINDERS", "0 * * * *"),
+			TodoDigest:     String("JOBS_TODO_DIGEST", "0 8 * * *"),
+			TrashPurge:     String("JOBS_TRASH_PURGE", "30 3 * * *"),
+			RecurringTodos: String("JOBS_RECURRING_TODOS", "*/5 * * * *"),
+			AttachmentScan: String("JOBS_ATTACHMENT_SCAN", "*/10 * * * *"),
+			TodoArchive:    String("JOBS_TODO_ARCHIVE", "0 4 * * *"),
+			TrashRetention: Duration("TODO_TRASH_RETENTION", 30*24*time.Hour),
+			ArchiveAfter:   Duration("TODO_ARCHIVE_AFTER", 180*24*time.Hour),
+			LeaseTTL:       Duration("JOBS_LEASE_TTL", 30*time.Second),
+			Instance:       String("JOBS_INSTANCE", defaultInstance()),
+		},
+		Quotas: QuotaConfig{
+			MaxTodos: Int("QUOTA_MAX_TODOS", 1000),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:    String("WEBAUTHN_RP_ID", "localhost"),
+			RPName:  String("WEBAUTHN_RP_NAME", "Hotel"),
+			Origins: webAuthnOrigins(),
+		},
+		Captcha: CaptchaConfig{
+			Provider:      strings.ToLower(String("CAPTCHA_PROVIDER", "")),
+			Secret:        String("CAPTCHA_SECRET", ""),
+			TestToken:     String("CAPTCHA_TEST_SECRET", ""),
+			LoginFailures: Int("CAPTCHA_LOGIN_FAILURES", 3),
+			FailureWindow: Duration("CAPTCHA_FAILURE_WINDOW", 15*time.Minute),
+		},
+		SSORedirectURL: String("SSO_REDIRECT_URL", "http://localhost:3000/sso/callback"),
+		Attachments: AttachmentsConfig{
+			MaxBytes:       Int("ATTACHMENT_MAX_BYTES", 10<<20),
+			URLSecret:      String("ATTACHMENT_URL_SECRET", ""),
+			URLTTL:         Duration("ATTACHMENT_URL_TTL", 15*time.Minute),
+			Scanner:        String("ATTACHMENT_SCANNER", ""),
+			ScannerAddr:    String("ATTACHMENT_SCANNER_ADDR", "localhost:3310"),
+			ScannerToken:   String("ATTACHMENT_SCANNER_TOKEN", ""),
+			ScannerTimeout: Duration("ATTACHMENT_SCANNER_TIMEOUT", 30*time.Second),
+		},
+		LinkPreviews: LinkPreviewsConfig{
+			Enabled: Bool("LINK_PREVIEWS", true),
+			TTL:     Duration("LINK_PREVIEW_TTL", 24*time.Hour),
+			Timeout: Duration("LINK_PREVIEW_TIMEOUT", 3*time.Second),
+		},
+	}
+}
+
+// webAuthnOrigins defaults to the development frontends.
+func webAuthnOrigins() []string {
+	if origins := List("WEBAUTHN_ORIGINS"); len(origins) > 0 {
+		return origins
+	}
+	return []string{"http://localhost:3000", "http://localhost:3001"}
+}
+
+// allowedOrigins defaults to the development frontends.
+func allowedOrigins() []string {
+	if origins := List("ALLOWED_ORIGINS"); len(origins) > 0 {
+		return origins
+	}
+	return []string{"http://localhost:3000", "http://localhost:3001"}
+}
+
+// defaultInstance names this process after its host and PID.
+func defaultInstance() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
+// String returns the trimmed value of key or fallback when unset.
+func String(key, fallback string) string {
+	if value := strings.TrimSpace(lookup(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// List splits a comma separated variable, dropping empty entries.
+func List(key string) []string {
+	raw := lookup(key)
+	if raw == "" {
+		return nil
+	}
+
+	var values []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// Bool parses key as a boolean, returning fallback when unset or invalid.
+func Bool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(lookup(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Int parses key as an integer, returning fallback when unset or invalid.
+func Int(key string, fallback int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(lookup(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Duration parses key with time.ParseDuration, returning fallback when unset or invalid.
+func Duration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(lookup(key)))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// DurationMap parses comma separated "name=duration" pairs, skipping
+// malformed entries.
+func DurationMap(key string) map[string]time.Duration {
+	values := make(map[string]time.Duration)
+	for _, entry := range List(key) {
+		name, raw, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSpace(name)] = d
+	}
+	return values
+}
+
+// DateMap parses comma separated "name=YYYY-MM-DD" pairs, skipping
+// malformed entries. Dates are midnight UTC.
+func DateMap(key string) map[string]time.Time {
+	values := make(map[string]time.Time)
+	for _, entry := range List(key) {
+		name, raw, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		date, err := time.Parse(time.DateOnly, strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSpace(name)] = date
+	}
+	return values
+}
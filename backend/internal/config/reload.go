@@ -0,0 +1,164 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Change is a reloadable setting whose value changed, named by its
+// variable.
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Key, c.Old, c.New)
+}
+
+// reloadable returns the settings applied without a restart, by variable.
+func (c Config) reloadable() map[string]string {
+	return map[string]string{
+		"ALLOWED_ORIGINS":      strings.Join(c.AllowedOrigins, ","),
+		"QUOTA_MAX_TODOS":      strconv.Itoa(c.Quotas.MaxTodos),
+		"LOG_BODIES":           strconv.FormatBool(c.BodyLog.Enabled),
+		"LOG_BODY_MAX_BYTES":   strconv.Itoa(c.BodyLog.MaxBytes),
+		"LOG_BODY_SKIP_ROUTES": strings.Join(c.BodyLog.SkipRoutes, ","),
+		"RATE_LIMIT_WINDOW":    c.RateLimit.Window.String(),
+		"RATE_LIMIT_ANONYMOUS": strconv.Itoa(c.RateLimit.Anonymous),
+		"RATE_LIMIT_USER":      strconv.Itoa(c.RateLimit.User),
+		"RATE_LIMIT_ADMIN":     strconv.Itoa(c.RateLimit.Admin),
+	}
+}
+
+// Changes lists the reloadable settings that differ in next, sorted by
+// variable.
+func (c Config) Changes(next Config) []Change {
+	current, updated := c.reloadable(), next.reloadable()
+	var changes []Change
+	for key, value := range updated {
+		if current[key] != value {
+			changes = append(changes, Change{Key: key, Old: current[key], New: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// RequiresRestart reports whether next changes settings that are only
+// read at startup.
+func (c Config) RequiresRestart(next Config) bool {
+	next.AllowedOrigins = c.AllowedOrigins
+	next.Quotas.MaxTodos = c.Quotas.MaxTodos
+	next.BodyLog = c.BodyLog
+	return !reflect.DeepEqual(c, next)
+}
+
+// Reload sources.
+const (
+	ReloadSignal = "sighup"
+	ReloadFile   = "file"
+)
+
+// Watcher reloads the configuration on SIGHUP and whenever its config file
+// changes, and hands the reloadable changes to apply.
+type Watcher struct {
+	apply func(next Config, changes []Change, source string)
+
+	mu      sync.Mutex
+	current Config
+	modTime time.Time
+}
+
+// NewWatcher builds a watcher of current.ConfigFile. apply is called with
+// the new configuration when a reload changes a reloadable setting.
+func NewWatcher(current Config, apply func(next Config, changes []Change, source string)) *Watcher {
+	w := &Watcher{current: current, apply: apply}
+	w.modTime, _ = w.fileModTime()
+	return w
+}
+
+func (w *Watcher) fileModTime() (time.Time, error) {
+	if w.current.ConfigFile == "" {
+		return time.Time{}, nil
+	}
+	info, err := os.Stat(w.current.ConfigFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Current returns the configuration in use.
+func (w *Watcher) Current() Config {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Reload reads the environment and the config file again and applies the
+// reloadable changes. On error the configuration in use is kept.
+func (w *Watcher) Reload(source string) ([]Change, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	next, err := LoadFile(w.current.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	if w.current.RequiresRestart(next) {
+		log.Printf("configuracion (%s): hay cambios que solo se aplican al reiniciar", source)
+	}
+	changes := w.current.Changes(next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	for _, change := range changes {
+		log.Printf("configuracion recargada (%s): %s", source, change)
+	}
+	w.apply(next, changes, source)
+	w.current = next
+	return changes, nil
+}
+
+// Run reloads on SIGHUP and polls the config file every
+// ConfigWatchInterval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var ticks <-chan time.Time
+	if cfg := w.Current(); cfg.ConfigFile != "" && cfg.ConfigWatchInterval > 0 {
+		ticker := time.NewTicker(cfg.ConfigWatchInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		source := ReloadSignal
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+		case <-ticks:
+			modTime, err := w.fileModTime()
+			if err != nil || modTime.Equal(w.modTime) {
+				continue
+			}
+			w.modTime, source = modTime, ReloadFile
+		}
+		if _, err := w.Reload(source); err != nil {
+			log.Printf("no se pudo recargar la configuracion (%s): %v", source, err)
+		}
+	}
+}
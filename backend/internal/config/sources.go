@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fileValues holds the overrides read from the config file.
+var fileValues struct {
+	sync.RWMutex
+	values map[string]string
+}
+
+func setFileValues(values map[string]string) {
+	fileValues.Lock()
+	defer fileValues.Unlock()
+	fileValues.values = values
+}
+
+// secretValues holds the values fetched from the secrets provider.
+var secretValues struct {
+	sync.RWMutex
+	values map[string]string
+}
+
+// SetSecrets makes the values of a secrets provider available to the next
+// Load, as a fallback for the variables not set otherwise.
+func SetSecrets(values map[string]string) {
+	secretValues.Lock()
+	defer secretValues.Unlock()
+	secretValues.values = values
+}
+
+// lookup returns the value of key from, in order: the config file, the
+// environment, the file named by key+"_FILE" (Docker and Kubernetes
+// secrets) and the secrets provider.
+func lookup(key string) string {
+	fileValues.RLock()
+	value, ok := fileValues.values[key]
+	fileValues.RUnlock()
+	if ok {
+		return value
+	}
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("no se pudo leer %s_FILE: %v", key, err)
+			return ""
+		}
+		return strings.TrimRight(string(content), "\r\n")
+	}
+	secretValues.RLock()
+	defer secretValues.RUnlock()
+	return secretValues.values[key]
+}
+
+// readFile parses the KEY=VALUE lines of path, skipping blank lines and
+// "#" comments. Values may be quoted.
+func readFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%s:%d: se esperaba CLAVE=VALOR", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		values[strings.TrimSpace(key)] = value
+	}
+	return values, scanner.Err()
+}
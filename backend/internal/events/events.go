@@ -0,0 +1,134 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Domain event types. Brokers receive each type on its own subject or
+// topic, prefixed with the configured prefix (e.g. "hotel.booking.created").
+const (
+	UserRegistered        = "user.registered"
+	UserImpersonated      = "user.impersonated"
+	UserAnonymized        = "user.anonymized"
+	UserMerged            = "user.merged"
+	UserEmailChanged      = "user.email_changed"
+	TodoCompleted         = "todo.completed"
+	TodoReactionAdded     = "todo.reaction_added"
+	TodoReactionRemoved   = "todo.reaction_removed"
+	TodoApprovalRequested = "todo.approval_requested"
+	TodoApproved          = "todo.approved"
+	TodoRejected          = "todo.rejected"
+	BookingCreated        = "booking.created"
+	ConfigReloaded        = "config.reloaded"
+)
+
+// Event is a domain event as published to the broker. Key identifies the
+// aggregate (user email, todo or booking ID) so brokers that partition keep
+// the events of one aggregate in order.
+type Event struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Key  string          `json:"key"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// New builds an event of type eventType with data encoded as JSON.
+func New(eventType, key string, data any, at time.Time) (Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Event{}, err
+	}
+	return Event{ID: hex.EncodeToString(id), Type: eventType, Key: key, Time: at.UTC(), Data: encoded}, nil
+}
+
+// Publisher sends events to a broker.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Handler consumes events published on a Bus.
+type Handler func(ctx context.Context, event Event)
+
+// Bus is the in-process broker: it hands every event to the subscribed
+// handlers synchronously. It is the default when no external broker is
+// configured.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus builds an empty in-process bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers handler for every event.
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish implements Publisher.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+	return nil
+}
+
+// Fanout publishes every event to all its publishers. An event counts as
+// published only when all of them accepted it, so a failure re-sends it to
+// every destination; consumers deduplicate by Event.ID.
+type Fanout []Publisher
+
+// Publish implements Publisher.
+func (f Fanout) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, publisher := range f {
+		if err := publisher.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Brokers accepted by Open.
+const (
+	BrokerMemory = "memory"
+	BrokerNATS   = "nats"
+	BrokerKafka  = "kafka"
+)
+
+// Open returns the publisher of broker: "memory" (or empty) for the
+// in-process bus, "nats" for a NATS server at url and "kafka" for a Kafka
+// REST proxy at url. prefix is prepended to the event type to name the
+// subject or topic.
+func Open(broker, url, prefix string) (Publisher, error) {
+	switch broker {
+	case "", BrokerMemory:
+		return NewBus(), nil
+	case BrokerNATS:
+		return NewNATSPublisher(url, prefix)
+	case BrokerKafka:
+		return NewKafkaPublisher(url, prefix, nil), nil
+	default:
+		return nil, fmt.Errorf("broker de eventos desconocido: %q", broker)
+	}
+}
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaPublisher publishes events to Kafka through a Confluent-compatible
+// REST proxy (POST /topics/{topic}), keyed by Event.Key.
+type KafkaPublisher struct {
+	baseURL string
+	prefix  string
+	client  *http.Client
+}
+
+// NewKafkaPublisher builds a publisher for the REST proxy at baseURL; a nil
+// client uses a default one with a short timeout.
+func NewKafkaPublisher(baseURL, prefix string, client *http.Client) *KafkaPublisher {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &KafkaPublisher{baseURL: strings.TrimRight(baseURL, "/"), prefix: prefix, client: client}
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+type kafkaRecords struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+// Publish implements Publisher.
+func (k *KafkaPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(kafkaRecords{Records: []kafkaRecord{{Key: event.Key, Value: event}}})
+	if err != nil {
+		return err
+	}
+	topic := url.PathEscape(k.prefix + event.Type)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.baseURL+"/topics/"+topic, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("proxy REST de Kafka respondio %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsTimeout bounds connecting and each write when ctx has no deadline.
+const natsTimeout = 5 * time.Second
+
+// NATSPublisher publishes events to a NATS server using the plain-text
+// client protocol (CONNECT/PUB/PING/PONG), so no client library is needed.
+// The connection is opened on the first publish and reopened after errors.
+// TLS is not supported.
+type NATSPublisher struct {
+	addr    string
+	connect []byte
+	prefix  string
+
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// NewNATSPublisher builds a publisher for rawURL ("nats://[user:pass@]host:port"
+// or "nats://token@host:port").
+func NewNATSPublisher(rawURL, prefix string) (*NATSPublisher, error) {
+	addr, options, err := parseNATSURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	return &NATSPublisher{addr: addr, connect: connect, prefix: prefix}, nil
+}
+
+func parseNATSURL(rawURL string) (string, natsConnect, error) {
+	options := natsConnect{Name: "tp6-backend", Lang: "go"}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return "", options, fmt.Errorf("url de NATS invalida: %q", rawURL)
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			options.User, options.Pass = u.User.Username(), pass
+		} else {
+			options.Token = u.User.Username()
+		}
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	return addr, options, nil
+}
+
+// Publish implements Publisher. A write on a stale connection is retried
+// once on a new one.
+func (p *NATSPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	subject := p.prefix + event.Type
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		err := p.publish(ctx, subject, payload)
+		if err == nil || attempt > 0 || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, subject string, payload []byte) error {
+	if p.conn == nil {
+		if err := p.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	p.conn.SetWriteDeadline(deadline(ctx))
+	fmt.Fprintf(p.w, "PUB %s %d\r\n", subject, len(payload))
+	p.w.Write(payload)
+	p.w.WriteString("\r\n")
+	if err := p.w.Flush(); err != nil {
+		p.drop(p.conn)
+		return err
+	}
+	return nil
+}
+
+// dial opens a connection and completes the handshake: the server greets
+// with INFO, and the PONG to our PING confirms CONNECT was accepted.
+func (p *NATSPublisher) dial(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: natsTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline(ctx))
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	if err := expectNATS(r, "INFO"); err != nil {
+		conn.Close()
+		return err
+	}
+	fmt.Fprintf(w, "CONNECT %s\r\nPING\r\n", p.connect)
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return err
+	}
+	if err := expectNATS(r, "PONG"); err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	p.conn, p.w = conn, w
+	go p.read(conn, r)
+	return nil
+}
+
+// read answers the server keep-alive PINGs until the connection fails.
+func (p *NATSPublisher) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			p.mu.Lock()
+			p.drop(conn)
+			p.mu.Unlock()
+			return
+		}
+		if strings.HasPrefix(line, "PING") {
+			p.mu.Lock()
+			if p.conn == conn {
+				p.conn.SetWriteDeadline(time.Now().Add(natsTimeout))
+				p.w.WriteString("PONG\r\n")
+				p.w.Flush()
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// drop closes conn if it is still the current connection; p.mu must be held.
+func (p *NATSPublisher) drop(conn net.Conn) {
+	conn.Close()
+	if p.conn == conn {
+		p.conn, p.w = nil, nil
+	}
+}
+
+// Close closes the connection, if any.
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.drop(p.conn)
+	}
+	return nil
+}
+
+func expectNATS(r *bufio.Reader, op string) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(line, "-ERR") {
+		return errors.New("NATS: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+	}
+	if !strings.HasPrefix(line, op) {
+		return fmt.Errorf("NATS: se esperaba %s y llego %q", op, strings.TrimSpace(line))
+	}
+	return nil
+}
+
+func deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(natsTimeout)
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook delivery headers. EventIDHeader carries the deduplication ID:
+// receivers must ignore an event whose ID they already processed, since
+// deliveries are at-least-once.
+const (
+	EventIDHeader   = "X-Event-ID"
+	EventTypeHeader = "X-Event-Type"
+	SignatureHeader = "X-Event-Signature"
+)
+
+// WebhookPublisher posts each event as JSON to a URL. With a secret the
+// body is signed as "sha256=<hex HMAC-SHA256>" in SignatureHeader.
+type WebhookPublisher struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookPublisher builds a publisher for url; a nil client uses a
+// default one with a short timeout.
+func NewWebhookPublisher(url, secret string, client *http.Client) *WebhookPublisher {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &WebhookPublisher{url: url, secret: secret, client: client}
+}
+
+// Publish implements Publisher.
+func (w *WebhookPublisher) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, event.ID)
+	req.Header.Set(EventTypeHeader, event.Type)
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook de eventos %s respondio %d", w.url, resp.StatusCode)
+	}
+	return nil
+}
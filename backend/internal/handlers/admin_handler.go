@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mock"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+)
+
+// AdminHandler exposes operational endpoints for administrators.
+type AdminHandler struct {
+	maintenance  *middleware.MaintenanceMode
+	faults       *middleware.FaultInjector
+	mocks        *mock.Recorder
+	deprecations *middleware.Deprecations
+}
+
+// NewAdminHandler constructs an AdminHandler instance. faults and mocks may
+// be nil when fault injection or the mock integrations are disabled.
+func NewAdminHandler(maintenance *middleware.MaintenanceMode, faults *middleware.FaultInjector, mocks *mock.Recorder, deprecations *middleware.Deprecations) *AdminHandler {
+	return &AdminHandler{maintenance: maintenance, faults: faults, mocks: mocks, deprecations: deprecations}
+}
+
+// GetMaintenance reports whether maintenance mode is active.
+func (h *AdminHandler) GetMaintenance(c *gin.Context) {
+	respond.Render(c, http.StatusOK, gin.H{"maintenance": h.maintenance.Enabled()})
+}
+
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SetMaintenance turns maintenance mode on or off.
+func (h *AdminHandler) SetMaintenance(c *gin.Context) {
+	var payload maintenanceRequest
+	if err := bindJSON(c, &payload); err != nil || payload.Enabled == nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	h.maintenance.Set(*payload.Enabled)
+	respond.Render(c, http.StatusOK, gin.H{"maintenance": *payload.Enabled})
+}
+
+type faultsRequest struct {
+	Rate    *float64 `json:"rate"`
+	DelayMs int      `json:"delayMs"`
+	Status  int      `json:"status"`
+	Drop    bool     `json:"drop"`
+}
+
+func faultsResponse(faults middleware.Faults) gin.H {
+	return gin.H{
+		"rate":    faults.Rate,
+		"delayMs": faults.Delay.Milliseconds(),
+		"status":  faults.Status,
+		"drop":    faults.Drop,
+	}
+}
+
+// GetFaults reports the faults injected into every request.
+func (h *AdminHandler) GetFaults(c *gin.Context) {
+	respond.Render(c, http.StatusOK, faultsResponse(h.faults.Faults()))
+}
+
+// SetFaults replaces the faults injected into every request; a rate of 0
+// turns them off.
+func (h *AdminHandler) SetFaults(c *gin.Context) {
+	var payload faultsRequest
+	if err := bindJSON(c, &payload); err != nil || payload.Rate == nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	faults := middleware.Faults{
+		Rate:   *payload.Rate,
+		Delay:  time.Duration(payload.DelayMs) * time.Millisecond,
+		Status: payload.Status,
+		Drop:   payload.Drop,
+	}
+	if !faults.Valid() {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	h.faults.Set(faults)
+	respond.Render(c, http.StatusOK, faultsResponse(faults))
+}
+
+// OutboxPreview lists what the mock integrations would have sent, oldest
+// first, optionally of one ?channel=.
+func (h *AdminHandler) OutboxPreview(c *gin.Context) {
+	channel := c.Query("channel")
+	if channel != "" && !slices.Contains(mock.Channels, channel) {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidOutboxChannel)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"messages": h.mocks.Messages(channel)})
+}
+
+// ClearOutboxPreview forgets the recorded messages, e.g. between E2E
+// tests.
+func (h *AdminHandler) ClearOutboxPreview(c *gin.Context) {
+	h.mocks.Clear()
+	i18n.Message(c, http.StatusOK, i18n.OutboxPreviewCleared)
+}
+
+type deprecationResponse struct {
+	Name       string           `json:"name" xml:"name"`
+	Since      time.Time        `json:"since" xml:"since"`
+	Sunset     *time.Time       `json:"sunset,omitempty" xml:"sunset,omitempty"`
+	Link       string           `json:"link,omitempty" xml:"link,omitempty"`
+	Count      int64            `json:"count" xml:"count"`
+	LastUsedAt *time.Time       `json:"lastUsedAt,omitempty" xml:"lastUsedAt,omitempty"`
+	Clients    map[string]int64 `json:"clients" xml:"-"`
+}
+
+// ListDeprecations reports how much each deprecated endpoint or field is
+// still used, and by which clients, to decide when it can be removed.
+func (h *AdminHandler) ListDeprecations(c *gin.Context) {
+	usage := h.deprecations.Usage()
+	out := make([]deprecationResponse, len(usage))
+	for i, u := range usage {
+		out[i] = deprecationResponse{
+			Name:       u.Name,
+			Since:      u.Since,
+			Sunset:     optionalTime(u.Sunset),
+			Link:       u.Link,
+			Count:      u.Count,
+			LastUsedAt: optionalTime(u.LastUsedAt),
+			Clients:    u.Clients,
+		}
+	}
+	respond.Render(c, http.StatusOK, gin.H{"deprecations": out})
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// ApprovalHandler exposes HTTP handlers for the approval workflow of
+// delegated todos.
+type ApprovalHandler struct {
+	approvals *services.ApprovalService
+}
+
+// NewApprovalHandler builds a new ApprovalHandler instance.
+func NewApprovalHandler(approvals *services.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{approvals: approvals}
+}
+
+// RequestApproval marks a todo as done pending approval, as its assignee.
+func (h *ApprovalHandler) RequestApproval(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	todo, err := h.approvals.Request(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email)
+	renderApproval(c, todo, err)
+}
+
+type approvalRequest struct {
+	Comment string `json:"comment" normalize:"text"`
+}
+
+// ApproveTodo approves and completes a submitted todo, as its owner.
+func (h *ApprovalHandler) ApproveTodo(c *gin.Context) {
+	h.review(c, true)
+}
+
+// RejectTodo sends a submitted todo back to its assignee, as its owner.
+func (h *ApprovalHandler) RejectTodo(c *gin.Context) {
+	h.review(c, false)
+}
+
+func (h *ApprovalHandler) review(c *gin.Context, approve bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload approvalRequest
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &payload); err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+			return
+		}
+	}
+	decide := h.approvals.Reject
+	if approve {
+		decide = h.approvals.Approve
+	}
+	todo, err := decide(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, payload.Comment)
+	renderApproval(c, todo, err)
+}
+
+func renderApproval(c *gin.Context, todo services.TodoResponse, err error) {
+	switch {
+	case err == nil:
+		renderTodo(c, http.StatusOK, todo)
+	case errors.Is(err, services.ErrInvalidComment):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidComment)
+	case errors.Is(err, services.ErrApprovalForbidden):
+		i18n.Error(c, http.StatusForbidden, i18n.ApprovalForbidden)
+	case errors.Is(err, services.ErrApprovalStateConflict):
+		i18n.Error(c, http.StatusConflict, i18n.ApprovalStateConflict)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.ApprovalFailed)
+	}
+}
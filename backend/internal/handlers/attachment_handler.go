@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// AttachmentHandler exposes HTTP handlers for todo attachments.
+type AttachmentHandler struct {
+	attachments *services.AttachmentService
+}
+
+// NewAttachmentHandler builds a new AttachmentHandler instance.
+func NewAttachmentHandler(attachments *services.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{attachments: attachments}
+}
+
+// attachmentError answers the errors shared by the attachment endpoints.
+func attachmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.AttachmentNotFound)
+	case errors.Is(err, services.ErrAttachmentPending):
+		i18n.Error(c, http.StatusConflict, i18n.AttachmentScanPending)
+	case errors.Is(err, services.ErrAttachmentBlocked):
+		i18n.Error(c, http.StatusForbidden, i18n.AttachmentBlocked)
+	default:
+		serverError(c, err, i18n.AttachmentFailed)
+	}
+}
+
+// serveAttachment writes the content of attachment as a download that
+// neither the browser nor proxies keep.
+func serveAttachment(c *gin.Context, attachment services.Attachment) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, attachment.ContentType, attachment.Data)
+}
+
+// ListAttachments returns the attachments of a todo, oldest first.
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	if _, ok := middleware.CurrentPrincipal(c); !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	attachments, err := h.attachments.List(c.Request.Context(), middleware.GetObjectID(c, "id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"attachments": attachments})
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.AttachmentFailed)
+	}
+}
+
+// UploadAttachment attaches the request body to a todo as the file named
+// by the name query parameter, with the Content-Type of the request.
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	attachment, err := h.attachments.Upload(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email,
+		c.Query("name"), c.GetHeader("Content-Type"), c.Request.Body)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"attachment": attachment})
+	case errors.Is(err, services.ErrInvalidAttachment):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidAttachment)
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		i18n.Error(c, http.StatusRequestEntityTooLarge, i18n.AttachmentTooLarge)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.AttachmentFailed)
+	}
+}
+
+// DownloadAttachment streams an attachment of a todo to a signed-in user.
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	if _, ok := middleware.CurrentPrincipal(c); !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	attachment, err := h.attachments.Open(c.Request.Context(), middleware.GetObjectID(c, "id"), middleware.GetObjectID(c, "attachmentId"))
+	if err != nil {
+		attachmentError(c, err)
+		return
+	}
+	serveAttachment(c, attachment)
+}
+
+// SignAttachment returns a short-lived URL that downloads an attachment
+// without credentials, to embed in emails and shared views.
+func (h *AttachmentHandler) SignAttachment(c *gin.Context) {
+	if _, ok := middleware.CurrentPrincipal(c); !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	signed, err := h.attachments.Sign(c.Request.Context(), middleware.GetObjectID(c, "id"), middleware.GetObjectID(c, "attachmentId"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"download": signed})
+	case errors.Is(err, services.ErrSignedURLsDisabled):
+		i18n.Error(c, http.StatusForbidden, i18n.SignedURLsDisabled)
+	default:
+		attachmentError(c, err)
+	}
+}
+
+// DownloadSigned serves the attachment of a signed URL; it needs no
+// session, only a valid and unexpired signature.
+func (h *AttachmentHandler) DownloadSigned(c *gin.Context) {
+	attachment, err := h.attachments.OpenSigned(c.Request.Context(), c.Param("id"), c.Query("expires"), c.Query("signature"))
+	switch {
+	case err == nil:
+		serveAttachment(c, attachment)
+	case errors.Is(err, services.ErrInvalidAttachmentLink):
+		i18n.Error(c, http.StatusForbidden, i18n.InvalidAttachmentLink)
+	default:
+		attachmentError(c, err)
+	}
+}
+
+// DeleteAttachment removes an attachment of a todo.
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	if _, ok := middleware.CurrentPrincipal(c); !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	if err := h.attachments.Delete(c.Request.Context(), middleware.GetObjectID(c, "id"), middleware.GetObjectID(c, "attachmentId")); err != nil {
+		attachmentError(c, err)
+		return
+	}
+	i18n.Message(c, http.StatusOK, i18n.AttachmentDeleted)
+}
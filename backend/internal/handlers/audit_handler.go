@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// AuditHandler exposes the audit trail of the domain events for compliance
+// reviews.
+type AuditHandler struct {
+	audit *services.AuditService
+}
+
+// NewAuditHandler builds a new AuditHandler instance.
+func NewAuditHandler(audit *services.AuditService) *AuditHandler {
+	return &AuditHandler{audit: audit}
+}
+
+// auditActor records the caller in the request context, so the events
+// stored while serving it name their actor: the signed-in user or, with
+// the admin token, services.AuditAdmin.
+func auditActor(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor := ""
+		if principal, ok := middleware.CurrentPrincipal(c); ok {
+			actor = principal.Email
+		}
+		if provided := c.GetHeader(middleware.AdminTokenHeader); provided != "" && adminToken != "" &&
+			subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) == 1 {
+			actor = services.AuditAdmin
+		}
+		if actor != "" {
+			c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), actor))
+		}
+		c.Next()
+	}
+}
+
+// ListAudit returns the events matching ?actor=&type=&resource=&from=&to=,
+// newest first, one page per ?cursor= (see nextCursor). With ?format=csv
+// it exports every matching event instead.
+func (h *AuditHandler) ListAudit(c *gin.Context) {
+	query, ok := auditQuery(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidAuditQuery)
+		return
+	}
+
+	if wantsCSV(c) {
+		records := [][]string{{"id", "time", "type", "actor", "resource", "data"}}
+		err := h.audit.Export(c.Request.Context(), query, func(entry services.AuditEntry) error {
+			records = append(records, []string{
+				entry.ID, entry.Time.UTC().Format(time.RFC3339), entry.Type, entry.Actor, entry.Resource, string(entry.Data),
+			})
+			return nil
+		})
+		if err != nil {
+			auditError(c, err)
+			return
+		}
+		renderCSV(c, "audit.csv", records)
+		return
+	}
+
+	page, err := h.audit.Query(c.Request.Context(), query)
+	if err != nil {
+		auditError(c, err)
+		return
+	}
+	// Cursor pages only link forward.
+	links := linkSet{"self": {Href: c.Request.URL.RequestURI()}}
+	if page.NextCursor != "" {
+		query := c.Request.URL.Query()
+		query.Set("cursor", page.NextCursor)
+		next := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		links["next"] = link{Href: next.String()}
+		c.Header("Link", fmt.Sprintf("<%s>; rel=%q", next.String(), "next"))
+	}
+	respond.Render(c, http.StatusOK, gin.H{"entries": page.Entries, "nextCursor": page.NextCursor, "links": links})
+}
+
+// auditQuery reads the filters of ListAudit; from and to take an RFC 3339
+// time or a YYYY-MM-DD date.
+func auditQuery(c *gin.Context) (services.AuditQuery, bool) {
+	query := services.AuditQuery{
+		Actor:    c.Query("actor"),
+		Type:     c.Query("type"),
+		Resource: c.Query("resource"),
+		Cursor:   c.Query("cursor"),
+	}
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		raw := c.Query(bound.param)
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if at, err = time.Parse(services.DateLayout, raw); err != nil {
+				return services.AuditQuery{}, false
+			}
+		}
+		*bound.value = at.UTC()
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return services.AuditQuery{}, false
+		}
+		query.Limit = limit
+	}
+	return query, true
+}
+
+func auditError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidAuditQuery) {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidAuditQuery)
+		return
+	}
+	serverError(c, err, i18n.ListAuditFailed)
+}
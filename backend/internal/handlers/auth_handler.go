@@ -1,34 +1,67 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
 	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
 )
 
 // AuthHandler exposes HTTP handlers related to authentication.
 type AuthHandler struct {
-	users *services.UserService
+	users    *services.UserService
+	sessions *services.SessionService
+	captcha  *services.CaptchaGuard
 }
 
 // NewAuthHandler constructs an AuthHandler instance.
-func NewAuthHandler(users *services.UserService) *AuthHandler {
-	return &AuthHandler{users: users}
+func NewAuthHandler(users *services.UserService, sessions *services.SessionService, captcha *services.CaptchaGuard) *AuthHandler {
+	return &AuthHandler{users: users, sessions: sessions, captcha: captcha}
+}
+
+// captchaError answers a failed CAPTCHA check and reports whether err was
+// one.
+func captchaError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrCaptchaRequired):
+		i18n.Error(c, http.StatusBadRequest, i18n.CaptchaRequired)
+	case errors.Is(err, services.ErrCaptchaRejected):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidCaptcha)
+	case errors.Is(err, services.ErrUnavailable):
+		serverError(c, err, i18n.CaptchaUnavailable)
+	default:
+		c.Header("Retry-After", retryAfterSeconds)
+		i18n.Error(c, http.StatusServiceUnavailable, i18n.CaptchaUnavailable)
+	}
+	return true
 }
 
 type registerRequest struct {
-	Email    string `json:"email"`
+	Email    string `json:"email" normalize:"email"`
 	Password string `json:"password"`
+	// Captcha is the token solved in the browser, when the CAPTCHA is on.
+	Captcha string `json:"captcha"`
 }
 
 // Register handles user registration.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var payload registerRequest
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "datos invalidos"})
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	if captchaError(c, h.captcha.CheckRegistration(c.Request.Context(), payload.Captcha, c.ClientIP())) {
 		return
 	}
 
@@ -38,37 +71,174 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	})
 	switch {
 	case err == nil:
-		c.JSON(http.StatusCreated, gin.H{"message": "usuario registrado con exito"})
+		metrics.UsersRegistered.Inc()
+		i18n.Message(c, http.StatusCreated, i18n.UserRegistered)
 	case errors.Is(err, services.ErrInvalidUserInput):
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email y clave son requeridos"})
+		i18n.Error(c, http.StatusBadRequest, i18n.EmailPasswordRequired)
 	case errors.Is(err, services.ErrUserAlreadyExists):
-		c.JSON(http.StatusConflict, gin.H{"error": "usuario ya existe"})
+		i18n.Error(c, http.StatusConflict, i18n.UserAlreadyExists)
 	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al registrar usuario"})
+		serverError(c, err, i18n.RegisterFailed)
 	}
 }
 
 type loginRequest struct {
-	Email    string `json:"email"`
+	Email    string `json:"email" normalize:"email"`
 	Password string `json:"password"`
+	// Captcha is required after repeated failed logins.
+	Captcha string `json:"captcha"`
 }
 
 // Login handles user authentication.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var payload loginRequest
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "datos invalidos"})
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if captchaError(c, h.captcha.CheckLogin(ctx, payload.Email, payload.Captcha, c.ClientIP())) {
 		return
 	}
 
-	err := h.users.Login(c.Request.Context(), payload.Email, payload.Password)
+	user, err := h.users.Login(ctx, payload.Email, payload.Password)
 	switch {
 	case err == nil:
-		c.JSON(http.StatusOK, gin.H{"message": "login exitoso"})
+		if err := h.captcha.LoginSucceeded(ctx, user.Email); err != nil {
+			log.Printf("no se pudieron reiniciar los intentos fallidos de %s: %v", user.Email, err)
+		}
 	case errors.Is(err, services.ErrInvalidCredentials):
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "credenciales invalidas"})
+		metrics.LoginFailures.Inc()
+		if err := h.captcha.LoginFailed(ctx, payload.Email); err != nil {
+			log.Printf("no se pudo registrar el intento fallido de %s: %v", payload.Email, err)
+		}
+		i18n.Error(c, http.StatusUnauthorized, i18n.InvalidCredentials)
+		return
+	case errors.Is(err, services.ErrAccountSuspended):
+		i18n.Error(c, http.StatusForbidden, i18n.AccountSuspended)
+		return
 	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al autenticar"})
+		serverError(c, err, i18n.LoginFailed)
+		return
+	}
+
+	startSession(c, h.sessions, user)
+}
+
+// startSession opens a session for the authenticated user and answers with
+// its token.
+func startSession(c *gin.Context, sessions *services.SessionService, user services.User) {
+	token, expiresAt, err := sessions.Start(c.Request.Context(), user.Email, services.Client{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		serverError(c, err, i18n.LoginFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{
+		"message":   i18n.T(c, i18n.LoginSucceeded),
+		"code":      i18n.LoginSucceeded,
+		"token":     token,
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+		"role":      user.Role,
+	})
+}
+
+// Resolve adapts the session service to middleware.Authenticate.
+func (h *AuthHandler) Resolve(ctx context.Context, token string) (middleware.Principal, error) {
+	user, session, err := h.sessions.Resolve(ctx, token)
+	if errors.Is(err, services.ErrInvalidSession) {
+		return middleware.Principal{}, middleware.ErrInvalidToken
+	}
+	if err != nil {
+		return middleware.Principal{}, err
+	}
+	principal := middleware.Principal{Email: user.Email, Role: user.Role, SessionID: session.ID.Hex()}
+	if user.PropertyID != nil {
+		principal.PropertyID = user.PropertyID.Hex()
+	}
+	return principal, nil
+}
+
+// ListSessions returns the open sessions of the signed-in account, so the
+// user sees where it is logged in.
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	sessions, err := h.sessions.List(c.Request.Context(), principal.Email, principal.SessionID)
+	if err != nil {
+		serverError(c, err, i18n.ListSessionsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession closes one session of the signed-in account, typically on
+// another device.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	err := h.sessions.Revoke(c.Request.Context(), principal.Email, c.Param("id"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.SessionRevoked)
+	case errors.Is(err, services.ErrInvalidSessionID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.SessionNotFound)
+	default:
+		serverError(c, err, i18n.RevokeSessionFailed)
+	}
+}
+
+// ListLogins returns the most recent logins of the signed-in account.
+func (h *AuthHandler) ListLogins(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	logins, err := h.sessions.Logins(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.ListSessionsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"logins": logins})
+}
+
+type roleRequest struct {
+	Role *string `json:"role" normalize:"keyword"`
+}
+
+// SetRole assigns the staff role of a user; an empty role revokes it.
+func (h *AuthHandler) SetRole(c *gin.Context) {
+	var payload roleRequest
+	if err := bindJSON(c, &payload); err != nil || payload.Role == nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	user, err := h.users.SetRole(c.Request.Context(), paramEmail(c, "email"), *payload.Role)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"user": user})
+	case errors.Is(err, services.ErrInvalidRole):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRole)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.UserNotFound)
+	default:
+		serverError(c, err, i18n.UpdateRoleFailed)
 	}
 }
 
@@ -76,18 +246,102 @@ func (h *AuthHandler) Login(c *gin.Context) {
 func (h *AuthHandler) ListUsers(c *gin.Context) {
 	users, err := h.users.List(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener usuarios"})
+		serverError(c, err, i18n.ListUsersFailed)
+		return
+	}
+	if respond.IsJSONAPI(c) {
+		respond.Render(c, http.StatusOK, userListDocument(users))
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"users": users})
+}
+
+// SearchUsers returns a page of users whose email contains ?q=.
+func (h *AuthHandler) SearchUsers(c *gin.Context) {
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+		return
+	}
+
+	result, err := h.users.Search(c.Request.Context(), services.UserQuery{
+		Search: c.Query("q"),
+		Offset: page.Offset,
+		Limit:  page.Limit,
+	})
+	switch {
+	case err == nil:
+		respond.RenderPage(c, http.StatusOK, gin.H{
+			"users": result.Users,
+			"links": pageLinks(c, page, result.Total),
+		}, pageMeta(page, result.Total))
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	default:
+		serverError(c, err, i18n.ListUsersFailed)
+	}
+}
+
+// SuspendUser keeps a user from signing in and closes its sessions.
+func (h *AuthHandler) SuspendUser(c *gin.Context) {
+	user, err := h.users.Suspend(c.Request.Context(), paramEmail(c, "email"))
+	h.renderSuspension(c, user, err)
+}
+
+// ReactivateUser lifts the suspension of a user.
+func (h *AuthHandler) ReactivateUser(c *gin.Context) {
+	user, err := h.users.Reactivate(c.Request.Context(), paramEmail(c, "email"))
+	h.renderSuspension(c, user, err)
+}
+
+func (h *AuthHandler) renderSuspension(c *gin.Context, user services.PublicUser, err error) {
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"user": user})
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.UserNotFound)
+	default:
+		serverError(c, err, i18n.SuspendUserFailed)
+	}
+}
+
+type impersonateRequest struct {
+	Operator string `json:"operator" normalize:"email"`
+	Reason   string `json:"reason" normalize:"text"`
+}
+
+// Impersonate issues a short-lived token to act as a user while support
+// staff debug an issue they reported. Every token is audited.
+func (h *AuthHandler) Impersonate(c *gin.Context) {
+	var payload impersonateRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"users": users})
+
+	impersonation, err := h.sessions.Impersonate(c.Request.Context(), paramEmail(c, "email"), payload.Operator, payload.Reason)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"impersonation": impersonation})
+	case errors.Is(err, services.ErrInvalidImpersonation):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidImpersonation)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.UserNotFound)
+	case errors.Is(err, services.ErrImpersonationForbidden):
+		i18n.Error(c, http.StatusForbidden, i18n.ImpersonationForbidden)
+	case errors.Is(err, services.ErrAccountSuspended):
+		i18n.Error(c, http.StatusConflict, i18n.AccountSuspended)
+	default:
+		serverError(c, err, i18n.ImpersonateFailed)
+	}
 }
 
 // ClearUsers removes every user. Intended for testing scenarios.
 func (h *AuthHandler) ClearUsers(c *gin.Context) {
 	if err := h.users.Clear(c.Request.Context()); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al limpiar usuarios"})
+		serverError(c, err, i18n.ClearUsersFailed)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "usuarios eliminados"})
+	i18n.Message(c, http.StatusOK, i18n.UsersCleared)
 }
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// MIMEGzip is the media type of the backup archives.
+const MIMEGzip = "application/gzip"
+
+// MaxRestoreBytes caps the size of an archive sent to POST /admin/restore.
+const MaxRestoreBytes = 512 << 20
+
+// BackupHandler exposes the backup and restore of the collections, used to
+// clone an environment.
+type BackupHandler struct {
+	backups *services.BackupService
+}
+
+// NewBackupHandler builds a new BackupHandler instance.
+func NewBackupHandler(backups *services.BackupService) *BackupHandler {
+	return &BackupHandler{backups: backups}
+}
+
+// Backup streams a gzip-compressed tar archive of the collections. Once
+// the first bytes are out the status can no longer change, so a failure
+// halfway drops the connection and the client sees a truncated archive,
+// which a restore rejects.
+func (h *BackupHandler) Backup(c *gin.Context) {
+	c.Header("Content-Type", MIMEGzip)
+	c.Header("Content-Disposition", `attachment; filename="backup.tar.gz"`)
+	manifest, err := h.backups.Backup(c.Request.Context(), c.Writer)
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			serverError(c, err, i18n.BackupFailed)
+			return
+		}
+		log.Printf("respaldo interrumpido: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	log.Printf("respaldo generado: %v", manifest.Collections)
+}
+
+// Dump streams the documents of one backed up collection straight from
+// the database, as NDJSON or, with ?format=json or Accept:
+// application/json, as a JSON array. Like Backup, a failure once the
+// first chunk is out drops the connection.
+func (h *BackupHandler) Dump(c *gin.Context) {
+	collection := c.Param("collection")
+	extension := ".ndjson"
+	array := wantsJSONArray(c)
+	if array {
+		extension = ".json"
+	}
+	stream := newJSONStream(c, array, collection+extension)
+	err := h.backups.Dump(c.Request.Context(), collection, func(doc json.RawMessage) error {
+		return stream.Write(doc)
+	})
+	if err == nil {
+		err = stream.Close()
+	}
+	if err == nil {
+		return
+	}
+	if !stream.Discard() {
+		log.Printf("volcado de %s interrumpido: %v", collection, err)
+		panic(http.ErrAbortHandler)
+	}
+	if errors.Is(err, services.ErrUnknownCollection) {
+		i18n.Error(c, http.StatusNotFound, i18n.UnknownCollection)
+		return
+	}
+	serverError(c, err, i18n.DumpFailed)
+}
+
+// Restore validates an archive written by Backup and replaces the
+// collections it holds.
+func (h *BackupHandler) Restore(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, MaxRestoreBytes)
+	manifest, err := h.backups.Restore(c.Request.Context(), body)
+	switch {
+	case err == nil:
+		log.Printf("respaldo del %s restaurado: %v", manifest.CreatedAt.Format("2006-01-02T15:04:05Z"), manifest.Collections)
+		respond.Render(c, http.StatusOK, gin.H{"restore": manifest})
+	case errors.Is(err, services.ErrInvalidBackup):
+		log.Printf("respaldo rechazado: %v", err)
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidBackup)
+	default:
+		serverError(c, err, i18n.RestoreFailed)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// The normalize struct tag names how bindJSON cleans a string field of a
+// request payload (also behind pointers, in slices and in nested structs):
+//
+//   - email: trimmed and lowercased, as services.NormalizeEmail;
+//   - text: trimmed free-form text such as titles, names and comments;
+//   - keyword: trimmed and lowercased enum-like values (roles, types,
+//     labels, statuses...);
+//   - date: a trimmed YYYY-MM-DD date; the services parse it and answer
+//     with their own error codes.
+//
+// Passwords, tokens and IDs are left untagged and reach the services as
+// sent.
+var normalizers = map[string]func(string) string{
+	"email":   services.NormalizeEmail,
+	"text":    services.NormalizeText,
+	"keyword": func(value string) string { return strings.ToLower(strings.TrimSpace(value)) },
+	"date":    services.NormalizeText,
+}
+
+// bindJSON decodes the JSON body of c into payload, as ShouldBindJSON, and
+// normalizes its tagged fields, so every handler hands the services the
+// same clean input.
+func bindJSON(c *gin.Context, payload any) error {
+	if err := c.ShouldBindJSON(payload); err != nil {
+		return err
+	}
+	normalizeInput(reflect.ValueOf(payload), "")
+	return nil
+}
+
+// queryEmail returns the ?key= email of c, normalized.
+func queryEmail(c *gin.Context, key string) string {
+	return services.NormalizeEmail(c.Query(key))
+}
+
+// paramEmail returns the :key email of the path of c, normalized.
+func paramEmail(c *gin.Context, key string) string {
+	return services.NormalizeEmail(c.Param(key))
+}
+
+func normalizeInput(v reflect.Value, kind string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			normalizeInput(v.Elem(), kind)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			normalizeInput(v.Index(i), kind)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				normalizeInput(v.Field(i), t.Field(i).Tag.Get("normalize"))
+			}
+		}
+	case reflect.String:
+		if normalize, ok := normalizers[kind]; ok && v.CanSet() {
+			v.SetString(normalize(v.String()))
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// BookingHandler exposes HTTP handlers for room reservations.
+type BookingHandler struct {
+	bookings *services.BookingService
+}
+
+// NewBookingHandler builds a new BookingHandler instance.
+func NewBookingHandler(bookings *services.BookingService) *BookingHandler {
+	return &BookingHandler{bookings: bookings}
+}
+
+// Availability lists the rooms free between ?from= and ?to= (YYYY-MM-DD).
+func (h *BookingHandler) Availability(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	rooms, err := h.bookings.Available(c.Request.Context(), from, to)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"from": from, "to": to, "rooms": rooms})
+	case errors.Is(err, services.ErrInvalidStayDates):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidStayDates)
+	default:
+		serverError(c, err, i18n.AvailabilityFailed)
+	}
+}
+
+// Quote prices a stay given ?roomId=, ?checkIn= and ?checkOut=.
+func (h *BookingHandler) Quote(c *gin.Context) {
+	quote, err := h.bookings.Quote(c.Request.Context(), c.Query("roomId"), c.Query("checkIn"), c.Query("checkOut"))
+	if err != nil {
+		h.bookingError(c, err, i18n.QuoteFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"quote": quote})
+}
+
+// ListBookings retrieves bookings optionally filtered by ?roomId= and ?email=.
+func (h *BookingHandler) ListBookings(c *gin.Context) {
+	bookings, err := h.bookings.List(c.Request.Context(), c.Query("roomId"), queryEmail(c, "email"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"bookings": bookings})
+	case errors.Is(err, services.ErrInvalidRoomID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	default:
+		serverError(c, err, i18n.ListBookingsFailed)
+	}
+}
+
+// GetBooking returns a single booking.
+func (h *BookingHandler) GetBooking(c *gin.Context) {
+	booking, err := h.bookings.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.bookingError(c, err, i18n.GetBookingFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"booking": booking})
+}
+
+type createBookingRequest struct {
+	RoomID   string `json:"roomId"`
+	GuestID  string `json:"guestId"`
+	Email    string `json:"email" normalize:"email"`
+	Guests   int    `json:"guests"`
+	CheckIn  string `json:"checkIn" normalize:"date"`
+	CheckOut string `json:"checkOut" normalize:"date"`
+}
+
+// CreateBooking reserves a room.
+func (h *BookingHandler) CreateBooking(c *gin.Context) {
+	var payload createBookingRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	booking, err := h.bookings.Create(c.Request.Context(), services.BookingInput{
+		RoomID:   payload.RoomID,
+		GuestID:  payload.GuestID,
+		Email:    payload.Email,
+		Guests:   payload.Guests,
+		CheckIn:  payload.CheckIn,
+		CheckOut: payload.CheckOut,
+	})
+	if err != nil {
+		h.bookingError(c, err, i18n.CreateBookingFailed)
+		return
+	}
+	c.Header("Location", "/bookings/"+booking.ID)
+	respond.Render(c, http.StatusCreated, gin.H{"booking": booking})
+}
+
+type updateBookingRequest struct {
+	RoomID   *string `json:"roomId"`
+	Guests   *int    `json:"guests"`
+	CheckIn  *string `json:"checkIn" normalize:"date"`
+	CheckOut *string `json:"checkOut" normalize:"date"`
+}
+
+// UpdateBooking modifies the room, guests or dates of an active booking.
+func (h *BookingHandler) UpdateBooking(c *gin.Context) {
+	var payload updateBookingRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	booking, err := h.bookings.Modify(c.Request.Context(), c.Param("id"), services.BookingChange{
+		RoomID:   payload.RoomID,
+		Guests:   payload.Guests,
+		CheckIn:  payload.CheckIn,
+		CheckOut: payload.CheckOut,
+	})
+	if err != nil {
+		h.bookingError(c, err, i18n.UpdateBookingFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"booking": booking})
+}
+
+// CancelBooking cancels an active booking.
+func (h *BookingHandler) CancelBooking(c *gin.Context) {
+	booking, err := h.bookings.Cancel(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.bookingError(c, err, i18n.CancelBookingFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"booking": booking})
+}
+
+// CheckIn registers the guest arrival.
+func (h *BookingHandler) CheckIn(c *gin.Context) {
+	booking, err := h.bookings.CheckIn(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.bookingError(c, err, i18n.CheckInFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"booking": booking})
+}
+
+// CheckOut registers the guest departure.
+func (h *BookingHandler) CheckOut(c *gin.Context) {
+	booking, err := h.bookings.CheckOut(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.bookingError(c, err, i18n.CheckOutFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"booking": booking})
+}
+
+// bookingError maps booking service errors shared by several endpoints.
+func (h *BookingHandler) bookingError(c *gin.Context, err error, fallback i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidBookingInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidBookingInput)
+	case errors.Is(err, services.ErrInvalidStayDates):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidStayDates)
+	case errors.Is(err, services.ErrInvalidBookingID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrBookingRoomNotFound):
+		i18n.Error(c, http.StatusUnprocessableEntity, i18n.RoomNotFound)
+	case errors.Is(err, services.ErrBookingGuestNotFound):
+		i18n.Error(c, http.StatusUnprocessableEntity, i18n.GuestNotFound)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.BookingNotFound)
+	case errors.Is(err, services.ErrBookingOverlap):
+		i18n.Error(c, http.StatusConflict, i18n.RoomNotAvailable)
+	case errors.Is(err, services.ErrBookingStateConflict):
+		i18n.Error(c, http.StatusConflict, i18n.BookingStateConflict)
+	case errors.Is(err, services.ErrCheckInOutsideStay):
+		i18n.Error(c, http.StatusConflict, i18n.CheckInOutsideStay)
+	default:
+		serverError(c, err, fallback)
+	}
+}
@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// CalDAV paths and namespaces.
+const (
+	CalDAVPrefix = "/caldav"
+	// calDAVCalendar is the only calendar of each user, with their todos.
+	calDAVCalendar = "todos"
+	nsDAV          = "DAV:"
+	nsCalDAV       = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer    = "http://calendarserver.org/ns/"
+	calendarType   = "text/calendar; charset=utf-8"
+	// maxCalDAVBody caps the iCalendar objects and XML requests read.
+	maxCalDAVBody = 256 << 10
+)
+
+// CalDAVHandler exposes the todos of each user as a CalDAV calendar of
+// VTODO resources (RFC 4791), so clients such as Apple Reminders and
+// Thunderbird sync them both ways. Clients sign in with HTTP Basic and the
+// account password; like the login, they get refused once the email
+// reaches the failed attempts that require a CAPTCHA.
+type CalDAVHandler struct {
+	caldav  *services.CalDAVService
+	users   *services.UserService
+	captcha *services.CaptchaGuard
+}
+
+// NewCalDAVHandler builds a new CalDAVHandler instance.
+func NewCalDAVHandler(caldav *services.CalDAVService, users *services.UserService, captcha *services.CaptchaGuard) *CalDAVHandler {
+	return &CalDAVHandler{caldav: caldav, users: users, captcha: captcha}
+}
+
+// CalDAVMethods are the methods routed to Serve.
+var CalDAVMethods = []string{http.MethodOptions, "PROPFIND", "REPORT", http.MethodGet, http.MethodPut, http.MethodDelete}
+
+// WellKnown points clients that only know the server to the CalDAV root
+// (RFC 6764).
+func (h *CalDAVHandler) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, CalDAVPrefix+"/")
+}
+
+// calDAVPath is a parsed CalDAV path.
+type calDAVPath struct {
+	kind  string
+	email string
+	name  string
+}
+
+const (
+	pathRoot      = "root"
+	pathPrincipal = "principal"
+	pathHome      = "home"
+	pathCalendar  = "calendar"
+	pathTodo      = "todo"
+)
+
+func parseCalDAVPath(raw string) (calDAVPath, bool) {
+	parts := strings.Split(strings.Trim(raw, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "":
+		return calDAVPath{kind: pathRoot}, true
+	case len(parts) == 2 && parts[0] == "principals":
+		return calDAVPath{kind: pathPrincipal, email: parts[1]}, true
+	case len(parts) == 2 && parts[0] == "calendars":
+		return calDAVPath{kind: pathHome, email: parts[1]}, true
+	case len(parts) == 3 && parts[0] == "calendars" && parts[2] == calDAVCalendar:
+		return calDAVPath{kind: pathCalendar, email: parts[1]}, true
+	case len(parts) == 4 && parts[0] == "calendars" && parts[2] == calDAVCalendar && strings.HasSuffix(parts[3], ".ics"):
+		name := strings.TrimSuffix(parts[3], ".ics")
+		return calDAVPath{kind: pathTodo, email: parts[1], name: name}, name != ""
+	}
+	return calDAVPath{}, false
+}
+
+func principalHref(email string) string {
+	return CalDAVPrefix + "/principals/" + url.PathEscape(email) + "/"
+}
+
+func homeHref(email string) string {
+	return CalDAVPrefix + "/calendars/" + url.PathEscape(email) + "/"
+}
+
+func calendarHref(email string) string {
+	return homeHref(email) + calDAVCalendar + "/"
+}
+
+func todoHref(email, name string) string {
+	return calendarHref(email) + url.PathEscape(name) + ".ics"
+}
+
+// authenticate returns the email of the Basic credentials, answering 401
+// when they are missing or wrong.
+func (h *CalDAVHandler) authenticate(c *gin.Context) (string, bool) {
+	ctx := c.Request.Context()
+	email, password, ok := c.Request.BasicAuth()
+	if ok {
+		if err := h.captcha.CheckLogin(ctx, email, "", c.ClientIP()); err != nil {
+			ok = false
+		}
+	}
+	if ok {
+		user, err := h.users.Login(ctx, email, password)
+		switch {
+		case err == nil:
+			if err := h.captcha.LoginSucceeded(ctx, user.Email); err != nil {
+				log.Printf("no se pudieron limpiar los intentos fallidos de %s: %v", user.Email, err)
+			}
+			return user.Email, true
+		case errors.Is(err, services.ErrInvalidCredentials):
+			if err := h.captcha.LoginFailed(ctx, email); err != nil {
+				log.Printf("no se pudo registrar el intento fallido de %s: %v", email, err)
+			}
+		case errors.Is(err, services.ErrAccountSuspended):
+		default:
+			serverError(c, err, i18n.CalDAVFailed)
+			return "", false
+		}
+	}
+	c.Header("WWW-Authenticate", `Basic realm="CalDAV", charset="UTF-8"`)
+	i18n.Error(c, http.StatusUnauthorized, i18n.InvalidCredentials)
+	return "", false
+}
+
+// Serve answers every CalDAV request under CalDAVPrefix.
+func (h *CalDAVHandler) Serve(c *gin.Context) {
+	if c.Request.Method == http.MethodOptions {
+		c.Header("DAV", "1, 3, calendar-access")
+		c.Header("Allow", strings.Join(CalDAVMethods, ", "))
+		c.Status(http.StatusOK)
+		return
+	}
+	email, ok := h.authenticate(c)
+	if !ok {
+		return
+	}
+	path, ok := parseCalDAVPath(c.Param("path"))
+	if !ok {
+		i18n.Error(c, http.StatusNotFound, i18n.RouteNotFound)
+		return
+	}
+	if path.kind != pathRoot && services.NormalizeEmail(path.email) != email {
+		i18n.Error(c, http.StatusForbidden, i18n.CalDAVForbidden)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalDAVBody)
+
+	switch {
+	case c.Request.Method == "PROPFIND":
+		h.propfind(c, email, path)
+	case c.Request.Method == "REPORT" && path.kind == pathCalendar:
+		h.report(c, email)
+	case c.Request.Method == http.MethodGet && path.kind == pathTodo:
+		h.get(c, email, path.name)
+	case c.Request.Method == http.MethodPut && path.kind == pathTodo:
+		h.put(c, email, path.name)
+	case c.Request.Method == http.MethodDelete && path.kind == pathTodo:
+		h.delete(c, email, path.name)
+	default:
+		i18n.Error(c, http.StatusMethodNotAllowed, i18n.MethodNotAllowed)
+	}
+}
+
+// xmlName is a namespaced XML element name.
+type xmlName struct {
+	space, local string
+}
+
+// requestedProps reads the properties a PROPFIND or REPORT asks for; nil
+// means all of them (an empty body or allprop).
+func requestedProps(body []byte) ([]xmlName, string, []string, error) {
+	var props []xmlName
+	var hrefs []string
+	root := ""
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var stack []string
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return props, root, hrefs, nil
+		}
+		if err != nil {
+			return nil, "", nil, err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if root == "" {
+				root = element.Name.Local
+			}
+			if len(stack) > 0 && stack[len(stack)-1] == "prop" && element.Name.Local != "prop" {
+				props = append(props, xmlName{element.Name.Space, element.Name.Local})
+			}
+			stack = append(stack, element.Name.Local)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1] == "href" {
+				hrefs = append(hrefs, strings.TrimSpace(string(element)))
+			}
+		}
+	}
+}
+
+// davResponse is one <response> of a multistatus: the properties found,
+// as XML fragments, and those missing.
+type davResponse struct {
+	href    string
+	found   []string
+	missing []xmlName
+	status  int
+}
+
+func escapeXML(text string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// propertyValues are the properties of a resource, as XML fragments.
+type propertyValues map[xmlName]string
+
+// pick keeps the requested properties, or all when props is nil.
+func (values propertyValues) pick(href string, props []xmlName) davResponse {
+	response := davResponse{href: href}
+	if props == nil {
+		for _, name := range allProps {
+			if value, ok := values[name]; ok {
+				response.found = append(response.found, value)
+			}
+		}
+		return response
+	}
+	for _, name := range props {
+		if value, ok := values[name]; ok {
+			response.found = append(response.found, value)
+		} else {
+			response.missing = append(response.missing, name)
+		}
+	}
+	return response
+}
+
+var (
+	propResourceType      = xmlName{nsDAV, "resourcetype"}
+	propDisplayName       = xmlName{nsDAV, "displayname"}
+	propPrincipal         = xmlName{nsDAV, "current-user-principal"}
+	propPrincipalURL      = xmlName{nsDAV, "principal-URL"}
+	propETag              = xmlName{nsDAV, "getetag"}
+	propContentType       = xmlName{nsDAV, "getcontenttype"}
+	propCalendarHome      = xmlName{nsCalDAV, "calendar-home-set"}
+	propSupportedComps    = xmlName{nsCalDAV, "supported-calendar-component-set"}
+	propCalendarData      = xmlName{nsCalDAV, "calendar-data"}
+	propCTag              = xmlName{nsCalServer, "getctag"}
+	propSupportedReports  = xmlName{nsDAV, "supported-report-set"}
+	propUserAddressCalSet = xmlName{nsCalDAV, "calendar-user-address-set"}
+)
+
+// allProps lists, in order, what allprop returns; calendar-data only
+// comes with REPORT or when asked for.
+var allProps = []xmlName{
+	propResourceType, propDisplayName, propPrincipal, propPrincipalURL, propETag, propContentType,
+	propCalendarHome, propSupportedComps, propCTag, propSupportedReports, propUserAddressCalSet,
+}
+
+func (h *CalDAVHandler) principalProps(email string) propertyValues {
+	principal := "<d:href>" + escapeXML(principalHref(email)) + "</d:href>"
+	return propertyValues{
+		propPrincipal:    "<d:current-user-principal>" + principal + "</d:current-user-principal>",
+		propCalendarHome: "<c:calendar-home-set><d:href>" + escapeXML(homeHref(email)) + "</d:href></c:calendar-home-set>",
+	}
+}
+
+func (h *CalDAVHandler) collectionProps(email string, kind string, resources []services.CalDAVResource) propertyValues {
+	values := h.principalProps(email)
+	switch kind {
+	case pathRoot, pathHome:
+		values[propResourceType] = "<d:resourcetype><d:collection/></d:resourcetype>"
+	case pathPrincipal:
+		values[propResourceType] = "<d:resourcetype><d:principal/></d:resourcetype>"
+		values[propPrincipalURL] = "<d:principal-URL><d:href>" + escapeXML(principalHref(email)) + "</d:href></d:principal-URL>"
+		values[propDisplayName] = "<d:displayname>" + escapeXML(email) + "</d:displayname>"
+		values[propUserAddressCalSet] = "<c:calendar-user-address-set><d:href>mailto:" + escapeXML(email) + "</d:href></c:calendar-user-address-set>"
+	case pathCalendar:
+		values[propResourceType] = "<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>"
+		values[propDisplayName] = "<d:displayname>" + calDAVCalendar + "</d:displayname>"
+		values[propSupportedComps] = `<c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>`
+		values[propCTag] = "<cs:getctag>" + escapeXML(services.CTag(resources)) + "</cs:getctag>"
+		values[propSupportedReports] = "<d:supported-report-set>" +
+			"<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+			"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>" +
+			"</d:supported-report-set>"
+	}
+	return values
+}
+
+func todoProps(resource services.CalDAVResource) propertyValues {
+	return propertyValues{
+		propResourceType: "<d:resourcetype/>",
+		propETag:         "<d:getetag>" + escapeXML(resource.ETag) + "</d:getetag>",
+		propContentType:  "<d:getcontenttype>text/calendar; charset=utf-8; component=vtodo</d:getcontenttype>",
+		propCalendarData: "<c:calendar-data>" + escapeXML(string(resource.Data)) + "</c:calendar-data>",
+	}
+}
+
+// renderMultistatus writes a 207 Multi-Status response.
+func renderMultistatus(c *gin.Context, responses []davResponse) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCalServer + `">`)
+	for _, response := range responses {
+		buf.WriteString("<d:response><d:href>" + escapeXML(response.href) + "</d:href>")
+		if response.status != 0 {
+			buf.WriteString("<d:status>HTTP/1.1 " + statusLine(response.status) + "</d:status>")
+		}
+		if len(response.found) > 0 {
+			buf.WriteString("<d:propstat><d:prop>" + strings.Join(response.found, "") + "</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if len(response.missing) > 0 {
+			buf.WriteString("<d:propstat><d:prop>")
+			for _, name := range response.missing {
+				buf.WriteString(`<x:` + name.local + ` xmlns:x="` + escapeXML(name.space) + `"/>`)
+			}
+			buf.WriteString("</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		buf.WriteString("</d:response>")
+	}
+	buf.WriteString("</d:multistatus>")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", buf.Bytes())
+}
+
+func statusLine(status int) string {
+	return strconv.Itoa(status) + " " + http.StatusText(status)
+}
+
+// readDAVBody reads the XML body of PROPFIND and REPORT.
+func readDAVBody(c *gin.Context) ([]xmlName, string, []string, bool) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil {
+		var props []xmlName
+		var root string
+		var hrefs []string
+		if props, root, hrefs, err = requestedProps(body); err == nil {
+			return props, root, hrefs, true
+		}
+	}
+	i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+	return nil, "", nil, false
+}
+
+func (h *CalDAVHandler) propfind(c *gin.Context, email string, path calDAVPath) {
+	props, _, _, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	depth := c.GetHeader("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+	ctx := c.Request.Context()
+
+	var responses []davResponse
+	switch path.kind {
+	case pathRoot:
+		responses = append(responses, h.collectionProps(email, pathRoot, nil).pick(CalDAVPrefix+"/", props))
+	case pathPrincipal:
+		responses = append(responses, h.collectionProps(email, pathPrincipal, nil).pick(principalHref(email), props))
+	case pathHome:
+		responses = append(responses, h.collectionProps(email, pathHome, nil).pick(homeHref(email), props))
+		if depth != "0" {
+			resources, err := h.caldav.Resources(ctx, email)
+			if err != nil {
+				serverError(c, err, i18n.CalDAVFailed)
+				return
+			}
+			responses = append(responses, h.collectionProps(email, pathCalendar, resources).pick(calendarHref(email), props))
+		}
+	case pathCalendar:
+		resources, err := h.caldav.Resources(ctx, email)
+		if err != nil {
+			serverError(c, err, i18n.CalDAVFailed)
+			return
+		}
+		responses = append(responses, h.collectionProps(email, pathCalendar, resources).pick(calendarHref(email), props))
+		if depth != "0" {
+			for _, resource := range resources {
+				responses = append(responses, todoProps(resource).pick(todoHref(email, resource.Name), props))
+			}
+		}
+	case pathTodo:
+		resource, err := h.caldav.Resource(ctx, email, path.name)
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+			return
+		case err != nil:
+			serverError(c, err, i18n.CalDAVFailed)
+			return
+		}
+		responses = append(responses, todoProps(resource).pick(todoHref(email, resource.Name), props))
+	}
+	renderMultistatus(c, responses)
+}
+
+// report answers calendar-query with every todo and calendar-multiget with
+// the todos it names.
+func (h *CalDAVHandler) report(c *gin.Context, email string) {
+	props, root, hrefs, ok := readDAVBody(c)
+	if !ok {
+		return
+	}
+	if root != "calendar-query" && root != "calendar-multiget" {
+		i18n.Error(c, http.StatusForbidden, i18n.UnsupportedReport)
+		return
+	}
+	resources, err := h.caldav.Resources(c.Request.Context(), email)
+	if err != nil {
+		serverError(c, err, i18n.CalDAVFailed)
+		return
+	}
+
+	var responses []davResponse
+	if root == "calendar-query" {
+		for _, resource := range resources {
+			responses = append(responses, todoProps(resource).pick(todoHref(email, resource.Name), props))
+		}
+		renderMultistatus(c, responses)
+		return
+	}
+	byHref := make(map[string]services.CalDAVResource, len(resources))
+	for _, resource := range resources {
+		byHref[todoHref(email, resource.Name)] = resource
+	}
+	for _, href := range hrefs {
+		if parsed, err := url.Parse(href); err == nil {
+			href = parsed.EscapedPath()
+		}
+		if resource, found := byHref[href]; found {
+			responses = append(responses, todoProps(resource).pick(href, props))
+		} else {
+			responses = append(responses, davResponse{href: href, status: http.StatusNotFound})
+		}
+	}
+	renderMultistatus(c, responses)
+}
+
+func (h *CalDAVHandler) get(c *gin.Context, email, name string) {
+	resource, err := h.caldav.Resource(c.Request.Context(), email, name)
+	switch {
+	case err == nil:
+		c.Header("ETag", resource.ETag)
+		c.Data(http.StatusOK, calendarType, resource.Data)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.CalDAVFailed)
+	}
+}
+
+func calDAVConditions(c *gin.Context) services.CalDAVConditions {
+	return services.CalDAVConditions{IfMatch: c.GetHeader("If-Match"), IfNoneMatch: c.GetHeader("If-None-Match")}
+}
+
+func (h *CalDAVHandler) put(c *gin.Context, email, name string) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		i18n.Error(c, http.StatusRequestEntityTooLarge, i18n.InvalidCalendarData)
+		return
+	}
+	resource, created, err := h.caldav.Put(c.Request.Context(), email, name, body, calDAVConditions(c))
+	switch {
+	case err == nil:
+		c.Header("ETag", resource.ETag)
+		if created {
+			metrics.TodosCreated.Inc()
+			c.Header("Location", todoHref(email, resource.Name))
+			c.Status(http.StatusCreated)
+		} else {
+			c.Status(http.StatusNoContent)
+		}
+	case errors.Is(err, services.ErrInvalidCalendarData), errors.Is(err, services.ErrInvalidRecurrence):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidCalendarData)
+	case errors.Is(err, services.ErrPreconditionFailed):
+		i18n.Error(c, http.StatusPreconditionFailed, i18n.PreconditionFailed)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		i18n.Error(c, http.StatusForbidden, i18n.QuotaExceeded)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.CalDAVFailed)
+	}
+}
+
+func (h *CalDAVHandler) delete(c *gin.Context, email, name string) {
+	err := h.caldav.Delete(c.Request.Context(), email, name, calDAVConditions(c))
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, services.ErrPreconditionFailed):
+		i18n.Error(c, http.StatusPreconditionFailed, i18n.PreconditionFailed)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.CalDAVFailed)
+	}
+}
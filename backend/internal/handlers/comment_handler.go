@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// CommentHandler exposes HTTP handlers for todo comments.
+type CommentHandler struct {
+	comments *services.CommentService
+}
+
+// NewCommentHandler builds a new CommentHandler instance.
+func NewCommentHandler(comments *services.CommentService) *CommentHandler {
+	return &CommentHandler{comments: comments}
+}
+
+// ListComments returns the comments of a todo, oldest first.
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	if _, ok := middleware.CurrentPrincipal(c); !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	comments, err := h.comments.List(c.Request.Context(), middleware.GetObjectID(c, "id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"comments": comments})
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.ListCommentsFailed)
+	}
+}
+
+type commentRequest struct {
+	Body string `json:"body" normalize:"text"`
+}
+
+// CreateComment comments on a todo as the signed-in user, notifying the
+// collaborators it mentions.
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload commentRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	comment, err := h.comments.Create(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, payload.Body)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"comment": comment})
+	case errors.Is(err, services.ErrInvalidComment):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidComment)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.CreateCommentFailed)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// DashboardHandler exposes the operational summaries of the ops dashboard.
+type DashboardHandler struct {
+	dashboard *services.DashboardService
+}
+
+// NewDashboardHandler builds a new DashboardHandler instance.
+func NewDashboardHandler(dashboard *services.DashboardService) *DashboardHandler {
+	return &DashboardHandler{dashboard: dashboard}
+}
+
+// Users counts the registered users and the staff per role.
+func (h *DashboardHandler) Users(c *gin.Context) {
+	summary, err := h.dashboard.Users(c.Request.Context())
+	if err != nil {
+		serverError(c, err, i18n.DashboardFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, summary)
+}
+
+// Signups returns the registrations per day of ?from=&to=.
+func (h *DashboardHandler) Signups(c *gin.Context) {
+	days, err := h.dashboard.Signups(c.Request.Context(), dashboardRange(c))
+	if err != nil {
+		dashboardError(c, err)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"days": days})
+}
+
+// Todos returns the todos created and completed per day of ?from=&to=, with
+// their totals and the percentiles of the completion times.
+func (h *DashboardHandler) Todos(c *gin.Context) {
+	report, err := h.dashboard.Todos(c.Request.Context(), dashboardRange(c))
+	if err != nil {
+		dashboardError(c, err)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"totals": report.Totals, "days": report.Days})
+}
+
+// Webhooks returns how the events stored per day of ?from=&to= reached the
+// broker and the webhooks, with the failure rate of the attempts.
+func (h *DashboardHandler) Webhooks(c *gin.Context) {
+	report, err := h.dashboard.Deliveries(c.Request.Context(), dashboardRange(c))
+	if err != nil {
+		dashboardError(c, err)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"totals": report.Totals, "days": report.Days})
+}
+
+// Storage returns the size of the database per collection.
+func (h *DashboardHandler) Storage(c *gin.Context) {
+	summary, err := h.dashboard.Storage(c.Request.Context())
+	if err != nil {
+		serverError(c, err, i18n.DashboardFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, summary)
+}
+
+func dashboardRange(c *gin.Context) services.DashboardRange {
+	return services.DashboardRange{From: c.Query("from"), To: c.Query("to")}
+}
+
+func dashboardError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidDashboardRange) {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidDashboardRange)
+		return
+	}
+	serverError(c, err, i18n.DashboardFailed)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// DeadLetterHandler exposes the failed deliveries to administrators.
+type DeadLetterHandler struct {
+	deadLetters *services.DeadLetterService
+}
+
+// NewDeadLetterHandler builds a new DeadLetterHandler instance.
+func NewDeadLetterHandler(deadLetters *services.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{deadLetters: deadLetters}
+}
+
+// ListDeadLetters returns a page of dead letters, filtered by ?kind= and
+// ?status=.
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+		return
+	}
+
+	result, err := h.deadLetters.List(c.Request.Context(), services.DeadLetterQuery{
+		Kind:   c.Query("kind"),
+		Status: c.Query("status"),
+		Offset: page.Offset,
+		Limit:  page.Limit,
+	})
+	switch {
+	case err == nil:
+		respond.RenderPage(c, http.StatusOK, gin.H{
+			"deadLetters": result.DeadLetters,
+			"links":       pageLinks(c, page, result.Total),
+		}, pageMeta(page, result.Total))
+	case errors.Is(err, services.ErrInvalidDeadLetterFilter):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidDeadLetterFilter)
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	default:
+		serverError(c, err, i18n.ListDeadLettersFailed)
+	}
+}
+
+// GetDeadLetter returns one dead letter with its payload and last error.
+func (h *DeadLetterHandler) GetDeadLetter(c *gin.Context) {
+	letter, err := h.deadLetters.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.deadLetterError(c, err, i18n.GetDeadLetterFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"deadLetter": letter})
+}
+
+// RetryDeadLetter redelivers one dead letter and returns it updated; a
+// failed redelivery leaves it pending with the new error.
+func (h *DeadLetterHandler) RetryDeadLetter(c *gin.Context) {
+	letter, err := h.deadLetters.Retry(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.deadLetterError(c, err, i18n.RetryDeadLetterFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"deadLetter": letter})
+}
+
+type retryDeadLettersRequest struct {
+	Kind string `json:"kind" normalize:"text"`
+}
+
+// RetryDeadLetters redelivers the pending dead letters, optionally of one
+// kind, and reports how many were delivered.
+func (h *DeadLetterHandler) RetryDeadLetters(c *gin.Context) {
+	var payload retryDeadLettersRequest
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &payload); err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+			return
+		}
+	}
+
+	result, err := h.deadLetters.RetryAll(c.Request.Context(), payload.Kind)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, result)
+	case errors.Is(err, services.ErrInvalidDeadLetterFilter):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidDeadLetterFilter)
+	default:
+		serverError(c, err, i18n.RetryDeadLetterFailed)
+	}
+}
+
+// deadLetterError maps dead letter service errors shared by several
+// endpoints.
+func (h *DeadLetterHandler) deadLetterError(c *gin.Context, err error, fallback i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDeadLetterID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.DeadLetterNotFound)
+	case errors.Is(err, services.ErrDeadLetterRetried):
+		i18n.Error(c, http.StatusConflict, i18n.DeadLetterRetried)
+	default:
+		serverError(c, err, fallback)
+	}
+}
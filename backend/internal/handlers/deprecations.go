@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+)
+
+// LegacyTodoEmail is the unauthenticated mode of the todo endpoints, where
+// the owner comes from ?email= or the body instead of the session.
+const LegacyTodoEmail = "todos.email"
+
+// Deprecations lists what the API is retiring; sunsets sets, by name, the
+// date each one stops working.
+func Deprecations(sunsets map[string]time.Time) []middleware.Deprecation {
+	return []middleware.Deprecation{
+		{
+			Name:   LegacyTodoEmail,
+			Since:  time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC),
+			Sunset: sunsets[LegacyTodoEmail],
+			Link:   "/openapi.yaml",
+		},
+	}
+}
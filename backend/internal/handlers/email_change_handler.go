@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// EmailChangeHandler changes the email of the signed-in account.
+type EmailChangeHandler struct {
+	changes *services.EmailChangeService
+}
+
+// NewEmailChangeHandler builds a new EmailChangeHandler instance.
+func NewEmailChangeHandler(changes *services.EmailChangeService) *EmailChangeHandler {
+	return &EmailChangeHandler{changes: changes}
+}
+
+type emailChangeRequest struct {
+	Email    string `json:"email" normalize:"email"`
+	Password string `json:"password"`
+}
+
+// RequestEmailChange emails a confirmation link to the new email and a
+// cancellation link to the current one; the email only changes once the
+// link sent to the new one is opened.
+func (h *EmailChangeHandler) RequestEmailChange(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload emailChangeRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	change, err := h.changes.Request(c.Request.Context(), principal.Email, payload.Email, payload.Password)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusAccepted, gin.H{"emailChange": change})
+	case errors.Is(err, services.ErrInvalidEmailChange):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidEmailChange)
+	case errors.Is(err, services.ErrEmailChangeNotVerified):
+		i18n.Error(c, http.StatusForbidden, i18n.EmailChangeNotVerified)
+	case errors.Is(err, services.ErrUserAlreadyExists):
+		i18n.Error(c, http.StatusConflict, i18n.UserAlreadyExists)
+	default:
+		serverError(c, err, i18n.EmailChangeFailed)
+	}
+}
+
+// ConfirmEmailChange switches the account to its new email. It is the link
+// emailed to the new address, so it needs no session.
+func (h *EmailChangeHandler) ConfirmEmailChange(c *gin.Context) {
+	change, err := h.changes.Confirm(c.Request.Context(), c.Query("token"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"emailChange": change})
+	case errors.Is(err, services.ErrInvalidEmailChangeToken):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidEmailChangeToken)
+	case errors.Is(err, services.ErrUserAlreadyExists):
+		i18n.Error(c, http.StatusConflict, i18n.UserAlreadyExists)
+	default:
+		serverError(c, err, i18n.EmailChangeFailed)
+	}
+}
+
+// CancelEmailChange drops a pending email change. It is the link emailed
+// to the current address, so it needs no session.
+func (h *EmailChangeHandler) CancelEmailChange(c *gin.Context) {
+	err := h.changes.Cancel(c.Request.Context(), c.Query("token"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.EmailChangeCancelled)
+	case errors.Is(err, services.ErrInvalidEmailChangeToken):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidEmailChangeToken)
+	default:
+		serverError(c, err, i18n.EmailChangeFailed)
+	}
+}
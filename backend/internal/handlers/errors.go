@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// retryAfterSeconds is suggested to clients while the database is unavailable.
+const retryAfterSeconds = "30"
+
+// serverError answers an unexpected error: 503 while the database circuit
+// breaker is open, 500 with code otherwise.
+func serverError(c *gin.Context, err error, code i18n.Code) {
+	if errors.Is(err, services.ErrUnavailable) {
+		c.Header("Retry-After", retryAfterSeconds)
+		i18n.Error(c, http.StatusServiceUnavailable, i18n.ServiceUnavailable)
+		return
+	}
+	i18n.Error(c, http.StatusInternalServerError, code)
+}
+
+// routeNotFound answers requests for unknown routes.
+func routeNotFound(c *gin.Context) {
+	i18n.Error(c, http.StatusNotFound, i18n.RouteNotFound)
+}
+
+// methodNotAllowed answers requests using a method the route does not
+// support; gin has already filled the Allow header.
+func methodNotAllowed(c *gin.Context) {
+	i18n.Error(c, http.StatusMethodNotAllowed, i18n.MethodNotAllowed)
+}
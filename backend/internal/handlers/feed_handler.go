@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// AtomContentType is the media type of the todo feed.
+const AtomContentType = "application/atom+xml; charset=utf-8"
+
+// FeedHandler exposes the Atom feed of the todos, which feed readers read
+// with a token in the URL since they cannot sign in.
+type FeedHandler struct {
+	feeds   *services.TodoFeedService
+	baseURL string
+}
+
+// NewFeedHandler builds a new FeedHandler instance; baseURL prefixes the
+// links of the feed.
+func NewFeedHandler(feeds *services.TodoFeedService, baseURL string) *FeedHandler {
+	return &FeedHandler{feeds: feeds, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// IssueFeedToken creates the feed token of the signed-in user, replacing
+// the previous one; the token is only shown in this response.
+func (h *FeedHandler) IssueFeedToken(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	token, err := h.feeds.IssueToken(c.Request.Context(), principal.Email)
+	switch {
+	case err == nil:
+		query := url.Values{"token": {token.Token}}
+		respond.Render(c, http.StatusCreated, gin.H{"feed": token, "url": h.baseURL + "/todos/feed.xml?" + query.Encode()})
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+	default:
+		serverError(c, err, i18n.FeedFailed)
+	}
+}
+
+// RevokeFeedToken removes the feed token of the signed-in user.
+func (h *FeedHandler) RevokeFeedToken(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	err := h.feeds.RevokeToken(c.Request.Context(), principal.Email)
+	if err != nil && !errors.Is(err, services.ErrNotFound) {
+		serverError(c, err, i18n.FeedFailed)
+		return
+	}
+	i18n.Message(c, http.StatusOK, i18n.FeedTokenRevoked)
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    string         `xml:"content"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// Feed returns the todos recently created and completed by the owner of
+// the token as an Atom feed. The list, color and icon parameters narrow it
+// to one shared list or label.
+func (h *FeedHandler) Feed(c *gin.Context) {
+	user, err := h.feeds.Authenticate(c.Request.Context(), c.Query("token"))
+	switch {
+	case errors.Is(err, services.ErrInvalidFeedToken):
+		i18n.Error(c, http.StatusUnauthorized, i18n.InvalidFeedToken)
+		return
+	case err != nil:
+		serverError(c, err, i18n.FeedFailed)
+		return
+	}
+
+	query := services.FeedQuery{Color: c.Query("color"), Icon: c.Query("icon")}
+	if list := c.Query("list"); list != "" {
+		if query.ListID, err = primitive.ObjectIDFromHex(list); err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+			return
+		}
+	}
+	entries, err := h.feeds.Entries(c.Request.Context(), user.Email, query)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrInvalidTodoLabel):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidTodoLabel)
+		return
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.ListNotFound)
+		return
+	case errors.Is(err, services.ErrListForbidden):
+		i18n.Error(c, http.StatusForbidden, i18n.ListForbidden)
+		return
+	default:
+		serverError(c, err, i18n.FeedFailed)
+		return
+	}
+
+	// The token stays out of the feed id and link, which readers may show.
+	self := url.Values{}
+	for _, param := range []string{"list", "color", "icon"} {
+		if value := c.Query(param); value != "" {
+			self.Set(param, value)
+		}
+	}
+	selfURL := h.baseURL + "/todos/feed.xml"
+	if len(self) > 0 {
+		selfURL += "?" + self.Encode()
+	}
+	feed := atomFeed{
+		ID:      selfURL,
+		Title:   i18n.T(c, i18n.FeedTitle) + " " + user.Email,
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "self", Href: selfURL},
+		Author:  atomPerson{Name: user.Email},
+	}
+	if len(entries) > 0 {
+		feed.Updated = entries[0].At.UTC().Format(time.RFC3339)
+	}
+	for _, entry := range entries {
+		title := i18n.T(c, i18n.FeedTodoCreated)
+		if entry.Kind == services.FeedCompleted {
+			title = i18n.T(c, i18n.FeedTodoCompleted)
+		}
+		categories := []atomCategory{{Term: entry.Kind}}
+		for _, label := range []string{entry.Todo.Color, entry.Todo.Icon} {
+			if label != "" {
+				categories = append(categories, atomCategory{Term: label})
+			}
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:         h.baseURL + "/todos/" + entry.Todo.ID + "#" + entry.Kind,
+			Title:      title + ": " + entry.Todo.Title,
+			Updated:    entry.At.UTC().Format(time.RFC3339),
+			Author:     atomPerson{Name: entry.Todo.Email},
+			Categories: categories,
+			Content:    entry.Todo.Title,
+		})
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		serverError(c, err, i18n.FeedFailed)
+		return
+	}
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, AtomContentType, append([]byte(xml.Header), body...))
+}
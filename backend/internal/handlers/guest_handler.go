@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// GuestHandler exposes HTTP handlers for guest profiles.
+type GuestHandler struct {
+	guests *services.GuestService
+}
+
+// NewGuestHandler builds a new GuestHandler instance.
+func NewGuestHandler(guests *services.GuestService) *GuestHandler {
+	return &GuestHandler{guests: guests}
+}
+
+// ListGuests searches guests by name or document through ?q=.
+func (h *GuestHandler) ListGuests(c *gin.Context) {
+	guests, err := h.guests.Search(c.Request.Context(), c.Query("q"))
+	if err != nil {
+		serverError(c, err, i18n.ListGuestsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"guests": guests})
+}
+
+// GetGuest returns a single guest.
+func (h *GuestHandler) GetGuest(c *gin.Context) {
+	guest, err := h.guests.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.guestError(c, err, i18n.GetGuestFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"guest": guest})
+}
+
+type guestRequest struct {
+	Name        *string   `json:"name" normalize:"text"`
+	Document    *string   `json:"document" normalize:"text"`
+	Email       *string   `json:"email" normalize:"email"`
+	Phone       *string   `json:"phone" normalize:"text"`
+	Preferences *[]string `json:"preferences" normalize:"text"`
+}
+
+// CreateGuest stores a new guest profile.
+func (h *GuestHandler) CreateGuest(c *gin.Context) {
+	var payload guestRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	var guest services.Guest
+	if payload.Name != nil {
+		guest.Name = *payload.Name
+	}
+	if payload.Document != nil {
+		guest.Document = *payload.Document
+	}
+	if payload.Email != nil {
+		guest.Email = *payload.Email
+	}
+	if payload.Phone != nil {
+		guest.Phone = *payload.Phone
+	}
+	if payload.Preferences != nil {
+		guest.Preferences = *payload.Preferences
+	}
+
+	created, err := h.guests.Create(c.Request.Context(), guest)
+	if err != nil {
+		h.guestError(c, err, i18n.CreateGuestFailed)
+		return
+	}
+	c.Header("Location", "/guests/"+created.ID)
+	respond.Render(c, http.StatusCreated, gin.H{"guest": created})
+}
+
+// UpdateGuest modifies an existing guest profile.
+func (h *GuestHandler) UpdateGuest(c *gin.Context) {
+	var payload guestRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	guest, err := h.guests.Update(c.Request.Context(), c.Param("id"), services.GuestUpdate{
+		Name:        payload.Name,
+		Document:    payload.Document,
+		Email:       payload.Email,
+		Phone:       payload.Phone,
+		Preferences: payload.Preferences,
+	})
+	if err != nil {
+		h.guestError(c, err, i18n.UpdateGuestFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"guest": guest})
+}
+
+// DeleteGuest removes a guest profile.
+func (h *GuestHandler) DeleteGuest(c *gin.Context) {
+	if err := h.guests.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		h.guestError(c, err, i18n.DeleteGuestFailed)
+		return
+	}
+	i18n.Message(c, http.StatusOK, i18n.GuestDeleted)
+}
+
+// ListGuestBookings returns the booking history of a guest.
+func (h *GuestHandler) ListGuestBookings(c *gin.Context) {
+	bookings, err := h.guests.Bookings(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.guestError(c, err, i18n.ListBookingsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"bookings": bookings})
+}
+
+// guestError maps guest service errors shared by several endpoints.
+func (h *GuestHandler) guestError(c *gin.Context, err error, fallback i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidGuestInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidGuestInput)
+	case errors.Is(err, services.ErrInvalidGuestID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.GuestNotFound)
+	case errors.Is(err, services.ErrGuestDocumentTaken):
+		i18n.Error(c, http.StatusConflict, i18n.GuestDocumentTaken)
+	default:
+		serverError(c, err, fallback)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// ImportHandler exposes HTTP handlers for the bookings imported from
+// channel managers.
+type ImportHandler struct {
+	imports *services.ImportService
+}
+
+// NewImportHandler builds a new ImportHandler instance.
+func NewImportHandler(imports *services.ImportService) *ImportHandler {
+	return &ImportHandler{imports: imports}
+}
+
+type externalBookingRequest struct {
+	ExternalRef string `json:"externalRef" normalize:"text"`
+	RoomID      string `json:"roomId"`
+	RoomNumber  string `json:"roomNumber" normalize:"text"`
+	Email       string `json:"email" normalize:"email"`
+	Guests      int    `json:"guests"`
+	CheckIn     string `json:"checkIn" normalize:"date"`
+	CheckOut    string `json:"checkOut" normalize:"date"`
+}
+
+type importBookingsRequest struct {
+	Channel  string                   `json:"channel" normalize:"keyword"`
+	Bookings []externalBookingRequest `json:"bookings"`
+}
+
+// ImportBookings starts importing the bookings of a channel, sent as JSON
+// ({channel, bookings}) or as text/csv with ?channel=. The run is processed
+// in the background and polled through its Location.
+func (h *ImportHandler) ImportBookings(c *gin.Context) {
+	channel := c.Query("channel")
+	var rows []services.ExternalBooking
+	if c.ContentType() == MIMECSV {
+		parsed, err := services.ParseImportCSV(c.Request.Body)
+		if err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidImport)
+			return
+		}
+		rows = parsed
+	} else {
+		var payload importBookingsRequest
+		if err := bindJSON(c, &payload); err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+			return
+		}
+		if payload.Channel != "" {
+			channel = payload.Channel
+		}
+		rows = make([]services.ExternalBooking, len(payload.Bookings))
+		for i, booking := range payload.Bookings {
+			rows[i] = services.ExternalBooking(booking)
+		}
+	}
+
+	run, err := h.imports.Start(c.Request.Context(), channel, rows)
+	switch {
+	case err == nil:
+		c.Header("Location", "/integrations/bookings/imports/"+run.ID)
+		respond.Render(c, http.StatusAccepted, gin.H{"run": run})
+	case errors.Is(err, services.ErrInvalidImport):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidImport)
+	default:
+		serverError(c, err, i18n.ImportBookingsFailed)
+	}
+}
+
+// GetImport returns the progress and row results of an import run.
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	run, err := h.imports.Get(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"run": run})
+	case errors.Is(err, services.ErrInvalidImportID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.ImportNotFound)
+	default:
+		serverError(c, err, i18n.GetImportFailed)
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+)
+
+// JobHandler exposes the background job scheduler to administrators.
+type JobHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+// NewJobHandler builds a new JobHandler instance.
+func NewJobHandler(scheduler *scheduler.Scheduler) *JobHandler {
+	return &JobHandler{scheduler: scheduler}
+}
+
+// ListJobs reports the last run, duration and error of every scheduled job,
+// and whether this replica is the one running them.
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	jobs, err := h.scheduler.Statuses(c.Request.Context())
+	if err != nil {
+		serverError(c, err, i18n.ListJobsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{
+		"instance": h.scheduler.Instance(),
+		"leader":   h.scheduler.Leader(),
+		"jobs":     jobs,
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// jsonapiResource is a JSON:API resource object.
+type jsonapiResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    interface{}                    `json:"attributes"`
+	Relationships map[string]jsonapiRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+// jsonapiIdentifier points to another resource.
+type jsonapiIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type jsonapiRelationship struct {
+	Data jsonapiIdentifier `json:"data"`
+}
+
+type todoAttributes struct {
+	Title       string                   `json:"title"`
+	Completed   bool                     `json:"completed"`
+	CreatedAt   time.Time                `json:"createdAt"`
+	CompletedAt *time.Time               `json:"completedAt,omitempty"`
+	Recurrence  string                   `json:"recurrence,omitempty"`
+	Color       string                   `json:"color,omitempty"`
+	Icon        string                   `json:"icon,omitempty"`
+	Reactions   []services.ReactionCount `json:"reactions,omitempty"`
+	Approval    string                   `json:"approval,omitempty"`
+}
+
+type userAttributes struct {
+	Email string `json:"email"`
+}
+
+// Users are identified by their email in JSON:API documents.
+func userIdentifier(email string) jsonapiIdentifier {
+	return jsonapiIdentifier{Type: "users", ID: email}
+}
+
+func todoJSONAPIResource(todo services.TodoResponse) jsonapiResource {
+	return jsonapiResource{
+		Type: "todos",
+		ID:   todo.ID,
+		Attributes: todoAttributes{
+			Title:       todo.Title,
+			Completed:   todo.Completed,
+			CreatedAt:   todo.CreatedAt,
+			CompletedAt: todo.CompletedAt,
+			Recurrence:  todo.Recurrence,
+			Color:       todo.Color,
+			Icon:        todo.Icon,
+			Reactions:   todo.Reactions,
+			Approval:    todo.Approval,
+		},
+		Relationships: todoRelationships(todo),
+		Links:         map[string]string{"self": "/todos/" + todo.ID},
+	}
+}
+
+func todoRelationships(todo services.TodoResponse) map[string]jsonapiRelationship {
+	relationships := map[string]jsonapiRelationship{
+		"owner": {Data: userIdentifier(todo.Email)},
+	}
+	if todo.Assignee != "" {
+		relationships["assignee"] = jsonapiRelationship{Data: userIdentifier(todo.Assignee)}
+	}
+	if todo.ListID != "" {
+		relationships["list"] = jsonapiRelationship{Data: jsonapiIdentifier{Type: "lists", ID: todo.ListID}}
+	}
+	if todo.RoomID != "" {
+		relationships["room"] = jsonapiRelationship{Data: jsonapiIdentifier{Type: "rooms", ID: todo.RoomID}}
+	}
+	return relationships
+}
+
+func todoDocument(todo services.TodoResponse) respond.Document {
+	return respond.Document{Data: todoJSONAPIResource(todo)}
+}
+
+func todoListDocument(todos []services.TodoResponse, total int64, pageLinks linkSet) respond.Document {
+	data := make([]jsonapiResource, 0, len(todos))
+	for _, todo := range todos {
+		data = append(data, todoJSONAPIResource(todo))
+	}
+
+	links := make(map[string]string, len(pageLinks))
+	for rel, l := range pageLinks {
+		links[rel] = l.Href
+	}
+	return respond.Document{Data: data, Meta: map[string]int64{"total": total}, Links: links}
+}
+
+func userListDocument(users []services.PublicUser) respond.Document {
+	data := make([]jsonapiResource, 0, len(users))
+	for _, user := range users {
+		id := userIdentifier(user.Email)
+		data = append(data, jsonapiResource{
+			Type:       id.Type,
+			ID:         id.ID,
+			Attributes: userAttributes{Email: user.Email},
+		})
+	}
+	return respond.Document{Data: data}
+}
+
+// renderTodo writes a single todo, as a JSON:API document when requested.
+func renderTodo(c *gin.Context, status int, todo services.TodoResponse) {
+	if respond.IsJSONAPI(c) {
+		respond.Render(c, status, todoDocument(todo))
+		return
+	}
+	respond.Render(c, status, gin.H{"todo": newTodoResource(todo)})
+}
+
+// renderTodoPage writes a todo listing with its pagination links.
+func renderTodoPage(c *gin.Context, page pagination, result services.TodoPage) {
+	links := pageLinks(c, page, result.Total)
+	if respond.IsJSONAPI(c) {
+		respond.Render(c, http.StatusOK, todoListDocument(result.Todos, result.Total, links))
+		return
+	}
+	respond.RenderPage(c, http.StatusOK, gin.H{
+		"todos": newTodoResources(result.Todos),
+		"links": links,
+	}, pageMeta(page, result.Total))
+}
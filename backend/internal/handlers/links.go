@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// maxPageSize caps the limit query parameter of paginated listings.
+const maxPageSize = 100
+
+// link is a hypermedia control pointing to a related resource or action.
+type link struct {
+	Href   string `json:"href" xml:"href,attr"`
+	Method string `json:"method,omitempty" xml:"method,attr,omitempty"`
+}
+
+// linkSet maps relation names to their link.
+type linkSet map[string]link
+
+// MarshalXML renders the set as <links><link rel="..." href="..."/></links>
+// in a stable order, since encoding/xml cannot encode maps.
+func (l linkSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	rels := make([]string, 0, len(l))
+	for rel := range l {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, rel := range rels {
+		elem := xml.StartElement{Name: xml.Name{Local: "link"}, Attr: []xml.Attr{{Name: xml.Name{Local: "rel"}, Value: rel}}}
+		if err := e.EncodeElement(l[rel], elem); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// pagination holds the offset/limit query parameters of a listing.
+type pagination struct {
+	Offset int
+	Limit  int
+}
+
+// parsePagination reads ?offset= and ?limit=. A missing limit disables
+// pagination so existing clients keep receiving the full listing.
+func parsePagination(c *gin.Context) (pagination, bool) {
+	var p pagination
+	if raw := c.Query("offset"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return pagination{}, false
+		}
+		p.Offset = value
+	}
+	if raw := c.Query("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > maxPageSize {
+			return pagination{}, false
+		}
+		p.Limit = value
+	}
+	return p, true
+}
+
+// pageMeta describes the page in the meta of the response.
+func pageMeta(p pagination, total int64) respond.Pagination {
+	return respond.Pagination{Offset: p.Offset, Limit: p.Limit, Total: total}
+}
+
+// pageLinks builds self/next/prev links for a listing and mirrors them in an
+// RFC 8288 (formerly RFC 5988) Link header.
+func pageLinks(c *gin.Context, p pagination, total int64) linkSet {
+	result := linkSet{"self": {Href: pageURL(c, p.Offset, p.Limit)}}
+
+	if p.Limit > 0 {
+		if next := p.Offset + p.Limit; int64(next) < total {
+			result["next"] = link{Href: pageURL(c, next, p.Limit)}
+		}
+		if p.Offset > 0 {
+			result["prev"] = link{Href: pageURL(c, max(p.Offset-p.Limit, 0), p.Limit)}
+		}
+	}
+
+	var header []string
+	for _, rel := range []string{"self", "next", "prev"} {
+		if l, ok := result[rel]; ok {
+			header = append(header, fmt.Sprintf("<%s>; rel=%q", l.Href, rel))
+		}
+	}
+	c.Header("Link", strings.Join(header, ", "))
+	return result
+}
+
+func pageURL(c *gin.Context, offset, limit int) string {
+	query := c.Request.URL.Query()
+	query.Del("offset")
+	query.Del("limit")
+	if limit > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	u := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// todoResource is a todo enriched with the actions available on it.
+type todoResource struct {
+	services.TodoResponse
+	Links linkSet `json:"links" xml:"links"`
+}
+
+func newTodoResource(todo services.TodoResponse) todoResource {
+	self := "/todos/" + todo.ID
+	result := linkSet{
+		"self":   {Href: self},
+		"update": {Href: self, Method: "PUT"},
+		"delete": {Href: self, Method: "DELETE"},
+	}
+	if !todo.Completed {
+		result["complete"] = link{Href: self, Method: "PUT"}
+	}
+	return todoResource{TodoResponse: todo, Links: result}
+}
+
+func newTodoResources(todos []services.TodoResponse) []todoResource {
+	resources := make([]todoResource, 0, len(todos))
+	for _, todo := range todos {
+		resources = append(resources, newTodoResource(todo))
+	}
+	return resources
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// ListHandler exposes HTTP handlers for the shared todo lists, and the
+// middlewares that enforce the list policy on them and on their todos.
+type ListHandler struct {
+	lists *services.ListService
+	todos *services.TodoService
+}
+
+// NewListHandler builds a new ListHandler instance.
+func NewListHandler(lists *services.ListService, todos *services.TodoService) *ListHandler {
+	return &ListHandler{lists: lists, todos: todos}
+}
+
+// authorizeList answers the error when the signed-in user may not perform
+// action on the list id, and reports whether they may.
+func authorizeList(c *gin.Context, lists *services.ListService, id primitive.ObjectID, action policy.Action) bool {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return false
+	}
+	_, err := lists.Authorize(c.Request.Context(), id, principal.Email, action)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.ListNotFound)
+	case errors.Is(err, services.ErrListForbidden):
+		i18n.Error(c, http.StatusForbidden, i18n.ListForbidden)
+	default:
+		serverError(c, err, i18n.ListListsFailed)
+	}
+	return false
+}
+
+// Require lets through the requests to /lists/:id whose user may perform
+// action on the list. It goes after ObjectIDParam("id").
+func (h *ListHandler) Require(action policy.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authorizeList(c, h.lists, middleware.GetObjectID(c, "id"), action) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireTodo lets through the requests to /todos/:id when the todo is
+// not in a list or its list allows the user to perform action; it goes
+// after ObjectIDParam("id"). Users outside the list get a 404, like for a
+// todo that does not exist.
+func (h *ListHandler) RequireTodo(action policy.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, signedIn := middleware.CurrentPrincipal(c)
+		err := h.lists.AuthorizeTodo(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, action)
+		switch {
+		case err == nil:
+			c.Next()
+			return
+		case errors.Is(err, services.ErrListForbidden) && !signedIn:
+			i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		case errors.Is(err, services.ErrListForbidden):
+			i18n.Error(c, http.StatusForbidden, i18n.ListForbidden)
+		case errors.Is(err, services.ErrNotFound):
+			i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+		default:
+			serverError(c, err, i18n.ListListsFailed)
+		}
+		c.Abort()
+	}
+}
+
+// ListLists returns the lists shared with the signed-in user.
+func (h *ListHandler) ListLists(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	lists, err := h.lists.ListFor(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.ListListsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"lists": lists})
+}
+
+type createListRequest struct {
+	Name string `json:"name" normalize:"text"`
+}
+
+// CreateList creates a list with the signed-in user as its admin.
+func (h *ListHandler) CreateList(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload createListRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	list, err := h.lists.Create(c.Request.Context(), principal.Email, payload.Name)
+	switch {
+	case err == nil:
+		c.Header("Location", "/lists/"+list.ID)
+		respond.Render(c, http.StatusCreated, gin.H{"list": list})
+	case errors.Is(err, services.ErrInvalidList):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidList)
+	default:
+		serverError(c, err, i18n.CreateListFailed)
+	}
+}
+
+// GetList returns a list and its members; it goes after Require(Read).
+func (h *ListHandler) GetList(c *gin.Context) {
+	principal, _ := middleware.CurrentPrincipal(c)
+	list, err := h.lists.Get(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email)
+	h.renderList(c, http.StatusOK, list, err)
+}
+
+// ListListTodos returns a page of the todos of a list; it goes after
+// Require(Read).
+func (h *ListHandler) ListListTodos(c *gin.Context) {
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+		return
+	}
+	result, err := h.todos.List(c.Request.Context(), services.TodoQuery{
+		ListID:  middleware.GetObjectID(c, "id"),
+		Trashed: c.Query("trashed") == "true",
+		Offset:  page.Offset,
+		Limit:   page.Limit,
+	})
+	switch {
+	case err == nil:
+		renderTodoPage(c, page, result)
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	default:
+		serverError(c, err, i18n.ListTodosFailed)
+	}
+}
+
+type memberRequest struct {
+	Email string `json:"email" normalize:"email"`
+	Role  string `json:"role" normalize:"keyword"`
+}
+
+// AddMember shares a list with another user.
+func (h *ListHandler) AddMember(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload memberRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	list, err := h.lists.AddMember(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, payload.Email, policy.Role(payload.Role))
+	h.renderList(c, http.StatusCreated, list, err)
+}
+
+// SetMemberRole changes the role of the member :email.
+func (h *ListHandler) SetMemberRole(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload memberRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	list, err := h.lists.SetRole(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, paramEmail(c, "email"), policy.Role(payload.Role))
+	h.renderList(c, http.StatusOK, list, err)
+}
+
+// RemoveMember takes the member :email out of a list; members may remove
+// themselves.
+func (h *ListHandler) RemoveMember(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	list, err := h.lists.RemoveMember(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, paramEmail(c, "email"))
+	h.renderList(c, http.StatusOK, list, err)
+}
+
+func (h *ListHandler) renderList(c *gin.Context, status int, list services.TodoListResponse, err error) {
+	switch {
+	case err == nil:
+		respond.Render(c, status, gin.H{"list": list})
+	case errors.Is(err, services.ErrInvalidList):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidList)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.ListNotFound)
+	case errors.Is(err, services.ErrListForbidden):
+		i18n.Error(c, http.StatusForbidden, i18n.ListForbidden)
+	case errors.Is(err, services.ErrListMemberNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.ListMemberNotFound)
+	case errors.Is(err, services.ErrListMemberExists):
+		i18n.Error(c, http.StatusConflict, i18n.ListMemberExists)
+	case errors.Is(err, services.ErrLastListAdmin):
+		i18n.Error(c, http.StatusConflict, i18n.LastListAdmin)
+	default:
+		serverError(c, err, i18n.UpdateListFailed)
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// MailHandler exposes the opt-out link of the booking emails.
+type MailHandler struct {
+	mailer *services.BookingMailer
+}
+
+// NewMailHandler builds a new MailHandler instance.
+func NewMailHandler(mailer *services.BookingMailer) *MailHandler {
+	return &MailHandler{mailer: mailer}
+}
+
+// OptOut stops the booking emails to ?email=; ?token= is the signature
+// included in the link of every email, so it works as a plain GET.
+func (h *MailHandler) OptOut(c *gin.Context) {
+	err := h.mailer.OptOut(c.Request.Context(), queryEmail(c, "email"), c.Query("token"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.MailOptedOut)
+	case errors.Is(err, services.ErrInvalidOptOutToken):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidOptOutToken)
+	default:
+		serverError(c, err, i18n.OptOutFailed)
+	}
+}
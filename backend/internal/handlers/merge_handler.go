@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// AccountMergeHandler merges a duplicate account into the signed-in one.
+type AccountMergeHandler struct {
+	merges *services.AccountMergeService
+}
+
+// NewAccountMergeHandler builds a new AccountMergeHandler instance.
+func NewAccountMergeHandler(merges *services.AccountMergeService) *AccountMergeHandler {
+	return &AccountMergeHandler{merges: merges}
+}
+
+type mergeRequest struct {
+	Email    string `json:"email" normalize:"email"`
+	Password string `json:"password"`
+	Token    string `json:"token"`
+	DryRun   bool   `json:"dryRun"`
+}
+
+// MergeAccount moves the todos, lists and history of the account of email
+// into the caller's and deletes it. The caller proves owning it with its
+// password or a session token; with dryRun it gets the summary of what
+// would move without changing anything.
+func (h *AccountMergeHandler) MergeAccount(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload mergeRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	source := services.MergeSource{Email: payload.Email, Password: payload.Password, Token: payload.Token}
+	summary, err := h.merges.Merge(c.Request.Context(), principal.Email, source, payload.DryRun)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"merge": summary})
+	case errors.Is(err, services.ErrInvalidMerge):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidMerge)
+	case errors.Is(err, services.ErrMergeEmailMismatch):
+		i18n.Error(c, http.StatusUnprocessableEntity, i18n.MergeEmailMismatch)
+	case errors.Is(err, services.ErrMergeNotVerified):
+		i18n.Error(c, http.StatusForbidden, i18n.MergeNotVerified)
+	default:
+		serverError(c, err, i18n.MergeAccountFailed)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// NotificationHandler exposes the in-app inbox of the signed-in user.
+type NotificationHandler struct {
+	notifications *services.NotificationService
+}
+
+// NewNotificationHandler builds a new NotificationHandler instance.
+func NewNotificationHandler(notifications *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notifications: notifications}
+}
+
+// ListNotifications returns a page of the inbox, newest first, with how
+// many notifications are unread; ?unread=true leaves out the ones already
+// read.
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+		return
+	}
+
+	result, err := h.notifications.List(c.Request.Context(), services.NotificationQuery{
+		Email:  principal.Email,
+		Unread: c.Query("unread") == "true",
+		Offset: page.Offset,
+		Limit:  page.Limit,
+	})
+	switch {
+	case err == nil:
+		respond.RenderPage(c, http.StatusOK, gin.H{
+			"notifications": result.Notifications,
+			"unread":        result.Unread,
+			"links":         pageLinks(c, page, result.Total),
+		}, pageMeta(page, result.Total))
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	default:
+		serverError(c, err, i18n.ListNotificationsFailed)
+	}
+}
+
+// ReadNotification marks a notification of the signed-in user as read.
+func (h *NotificationHandler) ReadNotification(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	notification, err := h.notifications.MarkRead(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"notification": notification})
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.NotificationNotFound)
+	default:
+		serverError(c, err, i18n.ReadNotificationFailed)
+	}
+}
+
+// ReadAllNotifications marks the whole inbox of the signed-in user as read.
+func (h *NotificationHandler) ReadAllNotifications(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	read, err := h.notifications.MarkAllRead(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.ReadNotificationFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"read": read})
+}
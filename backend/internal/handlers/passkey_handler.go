@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/webauthn"
+)
+
+// PasskeyHandler exposes the WebAuthn ceremonies that register passkeys and
+// sign in with them.
+type PasskeyHandler struct {
+	passkeys *services.PasskeyService
+	sessions *services.SessionService
+}
+
+// NewPasskeyHandler constructs a PasskeyHandler instance.
+func NewPasskeyHandler(passkeys *services.PasskeyService, sessions *services.SessionService) *PasskeyHandler {
+	return &PasskeyHandler{passkeys: passkeys, sessions: sessions}
+}
+
+// credentialRequest is a PublicKeyCredential as serialised by its toJSON
+// method: binary fields are base64url strings.
+type credentialRequest struct {
+	ID       string `json:"id"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AttestationObject string `json:"attestationObject"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+	} `json:"response"`
+}
+
+// decode returns the base64url fields of the credential, failing on the
+// first invalid one.
+func decode(values ...string) ([][]byte, bool) {
+	decoded := make([][]byte, len(values))
+	for i, value := range values {
+		raw, err := webauthn.Decode(value)
+		if err != nil || len(raw) == 0 {
+			return nil, false
+		}
+		decoded[i] = raw
+	}
+	return decoded, true
+}
+
+// ceremonyError answers the errors shared by both ceremonies.
+func ceremonyError(c *gin.Context, err error, code i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCeremony):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPasskeyCeremony)
+	case errors.Is(err, services.ErrInvalidPasskey):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPasskey)
+	default:
+		serverError(c, err, code)
+	}
+}
+
+// RegistrationOptions starts adding a passkey to the signed-in account.
+func (h *PasskeyHandler) RegistrationOptions(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	id, options, err := h.passkeys.BeginRegistration(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.PasskeyOptionsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"ceremonyId": id, "publicKey": options})
+}
+
+type registerPasskeyRequest struct {
+	CeremonyID string            `json:"ceremonyId"`
+	Name       string            `json:"name" normalize:"text"`
+	Credential credentialRequest `json:"credential"`
+}
+
+// RegisterPasskey stores the passkey created by the browser.
+func (h *PasskeyHandler) RegisterPasskey(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload registerPasskeyRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	fields, ok := decode(payload.Credential.Response.ClientDataJSON, payload.Credential.Response.AttestationObject)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	passkey, err := h.passkeys.FinishRegistration(c.Request.Context(), principal.Email, payload.CeremonyID, payload.Name,
+		services.PasskeyAttestation{ClientDataJSON: fields[0], AttestationObject: fields[1]})
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"passkey": passkey})
+	case errors.Is(err, services.ErrPasskeyExists):
+		i18n.Error(c, http.StatusConflict, i18n.PasskeyExists)
+	default:
+		ceremonyError(c, err, i18n.SavePasskeyFailed)
+	}
+}
+
+// ListPasskeys returns the passkeys of the signed-in account.
+func (h *PasskeyHandler) ListPasskeys(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	passkeys, err := h.passkeys.List(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.ListPasskeysFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// DeletePasskey removes a passkey of the signed-in account, e.g. after
+// losing the device holding it.
+func (h *PasskeyHandler) DeletePasskey(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	err := h.passkeys.Delete(c.Request.Context(), principal.Email, c.Param("id"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.PasskeyDeleted)
+	case errors.Is(err, services.ErrInvalidPasskeyID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.PasskeyNotFound)
+	default:
+		serverError(c, err, i18n.DeletePasskeyFailed)
+	}
+}
+
+type loginOptionsRequest struct {
+	Email string `json:"email" normalize:"email"`
+}
+
+// LoginOptions starts a passkey login. The body is optional: without an
+// email the browser offers the passkeys it holds for the site.
+func (h *PasskeyHandler) LoginOptions(c *gin.Context) {
+	var payload loginOptionsRequest
+	if err := bindJSON(c, &payload); err != nil && !errors.Is(err, io.EOF) {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	id, options, err := h.passkeys.BeginLogin(c.Request.Context(), payload.Email)
+	if err != nil {
+		serverError(c, err, i18n.PasskeyOptionsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"ceremonyId": id, "publicKey": options})
+}
+
+type passkeyLoginRequest struct {
+	CeremonyID string            `json:"ceremonyId"`
+	Credential credentialRequest `json:"credential"`
+}
+
+// Login signs in with a passkey and answers like the password login.
+func (h *PasskeyHandler) Login(c *gin.Context) {
+	var payload passkeyLoginRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	response := payload.Credential.Response
+	fields, ok := decode(payload.Credential.ID, response.ClientDataJSON, response.AuthenticatorData, response.Signature)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	user, err := h.passkeys.FinishLogin(c.Request.Context(), payload.CeremonyID, services.PasskeyAssertion{
+		CredentialID:      webauthn.Encode(fields[0]),
+		ClientDataJSON:    fields[1],
+		AuthenticatorData: fields[2],
+		Signature:         fields[3],
+	})
+	switch {
+	case err == nil:
+		startSession(c, h.sessions, user)
+	case errors.Is(err, services.ErrInvalidCredentials):
+		i18n.Error(c, http.StatusUnauthorized, i18n.InvalidCredentials)
+	case errors.Is(err, services.ErrAccountSuspended):
+		i18n.Error(c, http.StatusForbidden, i18n.AccountSuspended)
+	default:
+		ceremonyError(c, err, i18n.LoginFailed)
+	}
+}
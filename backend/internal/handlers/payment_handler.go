@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the webhook body
+// computed with the shared provider secret.
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// PaymentHandler exposes HTTP handlers for booking payments and the payment
+// provider webhook.
+type PaymentHandler struct {
+	payments      *services.PaymentService
+	webhookSecret string
+}
+
+// NewPaymentHandler builds a new PaymentHandler. An empty webhookSecret
+// disables the provider webhook.
+func NewPaymentHandler(payments *services.PaymentService, webhookSecret string) *PaymentHandler {
+	return &PaymentHandler{payments: payments, webhookSecret: webhookSecret}
+}
+
+// ListPayments returns the payments recorded for a booking.
+func (h *PaymentHandler) ListPayments(c *gin.Context) {
+	payments, err := h.payments.List(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.paymentError(c, err, i18n.ListPaymentsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"payments": payments})
+}
+
+type paymentRequest struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency" normalize:"text"`
+	Method   string  `json:"method" normalize:"keyword"`
+}
+
+// CreatePayment records a payment for a booking.
+func (h *PaymentHandler) CreatePayment(c *gin.Context) {
+	var payload paymentRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	payment, err := h.payments.Create(c.Request.Context(), c.Param("id"), services.PaymentInput{
+		Amount:   payload.Amount,
+		Currency: payload.Currency,
+		Method:   payload.Method,
+	})
+	if err != nil {
+		h.paymentError(c, err, i18n.CreatePaymentFailed)
+		return
+	}
+	respond.Render(c, http.StatusCreated, gin.H{"payment": payment})
+}
+
+type paymentNotificationRequest struct {
+	EventID     string `json:"eventId"`
+	PaymentID   string `json:"paymentId"`
+	Status      string `json:"status" normalize:"keyword"`
+	ProviderRef string `json:"providerRef" normalize:"text"`
+}
+
+// PaymentWebhook processes a signed status notification from the payment
+// provider. Redelivered or stale notifications are acknowledged with 200 so
+// the provider stops retrying them.
+func (h *PaymentHandler) PaymentWebhook(c *gin.Context) {
+	if h.webhookSecret == "" {
+		i18n.Error(c, http.StatusForbidden, i18n.WebhookDisabled)
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+	if !validSignature(h.webhookSecret, body, c.GetHeader(WebhookSignatureHeader)) {
+		i18n.Error(c, http.StatusUnauthorized, i18n.InvalidWebhookSignature)
+		return
+	}
+
+	var payload paymentNotificationRequest
+	if err := json.Unmarshal(body, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	payment, processed, err := h.payments.Notify(c.Request.Context(), services.PaymentNotification{
+		EventID:     payload.EventID,
+		PaymentID:   payload.PaymentID,
+		Status:      payload.Status,
+		ProviderRef: payload.ProviderRef,
+	})
+	switch {
+	case errors.Is(err, services.ErrInvalidPaymentNotification):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPaymentNotification)
+		return
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.PaymentNotFound)
+		return
+	case err != nil:
+		serverError(c, err, i18n.PaymentNotificationFailed)
+		return
+	}
+
+	code := i18n.PaymentNotificationProcessed
+	if !processed {
+		code = i18n.PaymentNotificationIgnored
+	}
+	respond.Render(c, http.StatusOK, gin.H{"message": i18n.T(c, code), "code": code, "payment": payment})
+}
+
+// paymentError maps payment service errors shared by the booking endpoints.
+func (h *PaymentHandler) paymentError(c *gin.Context, err error, fallback i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPaymentInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPaymentInput)
+	case errors.Is(err, services.ErrInvalidBookingID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.BookingNotFound)
+	default:
+		serverError(c, err, fallback)
+	}
+}
+
+// validSignature reports whether signature is the hex HMAC-SHA256 of body.
+func validSignature(secret string, body []byte, signature string) bool {
+	provided, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(provided, mac.Sum(nil))
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/xlsx"
+)
+
+// MIMEZip and MIMEXLSX are offered by the account export in addition to
+// the usual formats.
+const (
+	MIMEZip  = "application/zip"
+	MIMEXLSX = xlsx.MIMEType
+)
+
+// erasureModeGDPR is the only deletion mode of DELETE /users/me.
+const erasureModeGDPR = "gdpr"
+
+// clearModeAnonymize makes DELETE /users anonymize the users instead of
+// deleting them.
+const clearModeAnonymize = "anonymize"
+
+// PrivacyHandler exposes the data export and the erasure (GDPR) of the
+// signed-in account.
+type PrivacyHandler struct {
+	privacy *services.PrivacyService
+	lists   *services.ListService
+}
+
+// NewPrivacyHandler builds a new PrivacyHandler instance; lists names the
+// sheets of the Excel export.
+func NewPrivacyHandler(privacy *services.PrivacyService, lists *services.ListService) *PrivacyHandler {
+	return &PrivacyHandler{privacy: privacy, lists: lists}
+}
+
+// ExportAccount returns every record kept about the signed-in account, or a
+// ZIP archive with one JSON file per kind of record when asked through
+// ?format=zip or the Accept header. ?format=xlsx exports the todos as an
+// Excel workbook instead.
+func (h *PrivacyHandler) ExportAccount(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	export, err := h.privacy.Export(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.ExportFailed)
+		return
+	}
+	switch c.Query("format") {
+	case "zip":
+		renderExportZip(c, export)
+		return
+	case "xlsx":
+		h.renderExportXLSX(c, principal.Email, export)
+		return
+	}
+	switch c.NegotiateFormat(binding.MIMEJSON, MIMEZip, MIMEXLSX) {
+	case MIMEZip:
+		renderExportZip(c, export)
+		return
+	case MIMEXLSX:
+		h.renderExportXLSX(c, principal.Email, export)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"export": export})
+}
+
+// renderExportZip writes export as a downloadable archive.
+func renderExportZip(c *gin.Context, export services.AccountExport) {
+	c.Header("Vary", "Accept")
+	c.Header("Content-Disposition", `attachment; filename="export.zip"`)
+	c.Header("Content-Type", MIMEZip)
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"account.json", gin.H{"exportedAt": export.ExportedAt, "account": export.Account}},
+		{"todos.json", export.Todos},
+		{"comments.json", export.Comments},
+		{"activity.json", export.Activity},
+		{"logins.json", export.Logins},
+	} {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			break
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			break
+		}
+	}
+	_ = archive.Close()
+}
+
+// exportColumns are the columns of every sheet of the Excel export; the
+// overdue formula below depends on the order of completed and dueAt.
+var exportColumns = []xlsx.Column{
+	{Header: "title", Width: 40},
+	{Header: "completed", Width: 11},
+	{Header: "createdAt", Width: 17},
+	{Header: "completedAt", Width: 17},
+	{Header: "dueAt", Width: 17},
+	{Header: "recurrence", Width: 11},
+	{Header: "color", Width: 9},
+	{Header: "icon", Width: 9},
+	{Header: "assignee", Width: 28},
+}
+
+// overdueFormula highlights the pending todos whose due date has passed,
+// evaluated by Excel whenever the workbook is opened.
+const overdueFormula = `AND(NOT($B2),$E2<>"",$E2<NOW())`
+
+// renderExportXLSX writes the todos of export as a workbook with a sheet
+// for the own todos, one per shared list and one for the trash.
+func (h *PrivacyHandler) renderExportXLSX(c *gin.Context, email string, export services.AccountExport) {
+	lists, err := h.lists.ListFor(c.Request.Context(), email)
+	if err != nil {
+		serverError(c, err, i18n.ExportFailed)
+		return
+	}
+	own := xlsx.Sheet{Name: "todos", Columns: exportColumns, Highlight: overdueFormula}
+	trash := xlsx.Sheet{Name: "trash", Columns: exportColumns}
+	sheets := map[string]*xlsx.Sheet{}
+	var order []string
+	for _, todo := range export.Todos {
+		sheet := &own
+		switch {
+		case todo.DeletedAt != nil:
+			sheet = &trash
+		case todo.ListID != "":
+			if sheets[todo.ListID] == nil {
+				name := "list " + todo.ListID
+				for _, list := range lists {
+					if list.ID == todo.ListID {
+						name = list.Name
+					}
+				}
+				sheets[todo.ListID] = &xlsx.Sheet{Name: name, Columns: exportColumns, Highlight: overdueFormula}
+				order = append(order, todo.ListID)
+			}
+			sheet = sheets[todo.ListID]
+		}
+		sheet.Rows = append(sheet.Rows, []any{
+			todo.Title, todo.Completed, todo.CreatedAt, timeCell(todo.CompletedAt), timeCell(todo.DueAt),
+			todo.Recurrence, todo.Color, todo.Icon, todo.Assignee,
+		})
+	}
+	workbook := []xlsx.Sheet{own}
+	for _, id := range order {
+		workbook = append(workbook, *sheets[id])
+	}
+	if len(trash.Rows) > 0 {
+		workbook = append(workbook, trash)
+	}
+
+	c.Header("Vary", "Accept")
+	c.Header("Content-Disposition", `attachment; filename="todos.xlsx"`)
+	c.Header("Content-Type", MIMEXLSX)
+	c.Status(http.StatusOK)
+	_ = xlsx.Write(c.Writer, workbook)
+}
+
+// timeCell returns t for a cell, or nil to leave it empty.
+func timeCell(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// DeleteAccount erases the signed-in account (?mode=gdpr). Small accounts
+// are erased right away (200); larger ones in the background (202), polled
+// through the Location of the erasure.
+func (h *PrivacyHandler) DeleteAccount(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	if c.Query("mode") != erasureModeGDPR {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidErasureMode)
+		return
+	}
+
+	erasure, err := h.privacy.Erase(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.EraseAccountFailed)
+		return
+	}
+	c.Header("Location", "/users/erasures/"+erasure.ID)
+	status := http.StatusOK
+	if erasure.Status == services.ErasureRunning {
+		status = http.StatusAccepted
+	}
+	respond.Render(c, status, gin.H{"erasure": erasure})
+}
+
+// GetErasure returns the progress of an account erasure. It needs no
+// session, as the account may already be gone; erasures hold no personal
+// data.
+func (h *PrivacyHandler) GetErasure(c *gin.Context) {
+	erasure, err := h.privacy.GetErasure(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"erasure": erasure})
+	case errors.Is(err, services.ErrInvalidErasureID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.ErasureNotFound)
+	default:
+		serverError(c, err, i18n.GetErasureFailed)
+	}
+}
+
+// AnonymizeUsers serves DELETE /users?mode=anonymize, ahead of
+// AuthHandler.ClearUsers, which deletes the users when no mode is given:
+// the accounts are scrubbed of personal data but their todos, bookings and
+// events stay, under an alias, for the statistics and the audit trail.
+func (h *PrivacyHandler) AnonymizeUsers(c *gin.Context) {
+	switch c.Query("mode") {
+	case "":
+		c.Next()
+		return
+	case clearModeAnonymize:
+	default:
+		i18n.AbortError(c, http.StatusBadRequest, i18n.InvalidClearMode)
+		return
+	}
+
+	result, err := h.privacy.AnonymizeUsers(c.Request.Context())
+	c.Abort()
+	if err != nil {
+		serverError(c, err, i18n.AnonymizeUsersFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"anonymized": result})
+}
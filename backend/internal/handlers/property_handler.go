@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// PropertyHandler exposes the hotels of the chain and scopes requests to one
+// of them.
+type PropertyHandler struct {
+	properties *services.PropertyService
+}
+
+// NewPropertyHandler builds a new PropertyHandler instance.
+func NewPropertyHandler(properties *services.PropertyService) *PropertyHandler {
+	return &PropertyHandler{properties: properties}
+}
+
+// Scope attaches the property chosen by middleware.Authenticate to the
+// request context, so the services only see that property. It must run
+// after Authenticate.
+func (h *PropertyHandler) Scope(c *gin.Context) {
+	property := middleware.CurrentProperty(c)
+	if property == "" {
+		c.Next()
+		return
+	}
+
+	id, err := h.properties.Resolve(c.Request.Context(), property)
+	switch {
+	case err == nil:
+		c.Request = c.Request.WithContext(services.WithProperty(c.Request.Context(), id))
+		c.Next()
+	case errors.Is(err, services.ErrInvalidPropertyID):
+		i18n.AbortError(c, http.StatusBadRequest, i18n.InvalidPropertyID)
+	case errors.Is(err, services.ErrPropertyNotFound):
+		i18n.AbortError(c, http.StatusNotFound, i18n.PropertyNotFound)
+	default:
+		serverError(c, err, i18n.InternalError)
+		c.Abort()
+	}
+}
+
+// ListProperties returns every hotel of the chain.
+func (h *PropertyHandler) ListProperties(c *gin.Context) {
+	properties, err := h.properties.List(c.Request.Context())
+	if err != nil {
+		serverError(c, err, i18n.ListPropertiesFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"properties": properties})
+}
+
+type propertyRequest struct {
+	Code string `json:"code" normalize:"text"`
+	Name string `json:"name" normalize:"text"`
+	City string `json:"city" normalize:"text"`
+}
+
+// CreateProperty registers a new hotel.
+func (h *PropertyHandler) CreateProperty(c *gin.Context) {
+	var payload propertyRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	created, err := h.properties.Create(c.Request.Context(), services.Property{
+		Code: payload.Code,
+		Name: payload.Name,
+		City: payload.City,
+	})
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"property": created})
+	case errors.Is(err, services.ErrInvalidPropertyInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPropertyInput)
+	case errors.Is(err, services.ErrPropertyCodeTaken):
+		i18n.Error(c, http.StatusConflict, i18n.PropertyCodeTaken)
+	default:
+		serverError(c, err, i18n.CreatePropertyFailed)
+	}
+}
+
+type staffPropertyRequest struct {
+	PropertyID *string `json:"propertyId"`
+}
+
+// AssignStaff binds a user to one property; an empty propertyId lets the
+// user work for every property.
+func (h *PropertyHandler) AssignStaff(c *gin.Context) {
+	var payload staffPropertyRequest
+	if err := bindJSON(c, &payload); err != nil || payload.PropertyID == nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	user, err := h.properties.AssignStaff(c.Request.Context(), paramEmail(c, "email"), *payload.PropertyID)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"user": user})
+	case errors.Is(err, services.ErrInvalidPropertyID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPropertyID)
+	case errors.Is(err, services.ErrPropertyNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.PropertyNotFound)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.UserNotFound)
+	default:
+		serverError(c, err, i18n.AssignPropertyFailed)
+	}
+}
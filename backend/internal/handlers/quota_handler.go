@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// QuotaHandler exposes the plan limits and usage of the accounts.
+type QuotaHandler struct {
+	quotas *services.QuotaService
+}
+
+// NewQuotaHandler builds a new QuotaHandler instance.
+func NewQuotaHandler(quotas *services.QuotaService) *QuotaHandler {
+	return &QuotaHandler{quotas: quotas}
+}
+
+// Usage reports how much of its limits the signed-in account uses.
+func (h *QuotaHandler) Usage(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	usage, err := h.quotas.Usage(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.UsageFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"usage": usage})
+}
+
+// SetQuota overrides the plan limits of one account; a null limit restores
+// the plan limit and 0 lifts it.
+func (h *QuotaHandler) SetQuota(c *gin.Context) {
+	var payload services.QuotaOverride
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidQuota)
+		return
+	}
+
+	usage, err := h.quotas.SetOverride(c.Request.Context(), paramEmail(c, "email"), payload)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"usage": usage})
+	case errors.Is(err, services.ErrInvalidQuota):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidQuota)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.UserNotFound)
+	default:
+		serverError(c, err, i18n.UpdateQuotaFailed)
+	}
+}
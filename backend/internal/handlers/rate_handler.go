@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// RateHandler exposes HTTP handlers for rate plans.
+type RateHandler struct {
+	rates *services.RateService
+}
+
+// NewRateHandler builds a new RateHandler instance.
+func NewRateHandler(rates *services.RateService) *RateHandler {
+	return &RateHandler{rates: rates}
+}
+
+// ListRatePlans returns every rate plan.
+func (h *RateHandler) ListRatePlans(c *gin.Context) {
+	plans, err := h.rates.List(c.Request.Context())
+	if err != nil {
+		serverError(c, err, i18n.ListRatePlansFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"ratePlans": plans})
+}
+
+// GetRatePlan returns a single rate plan.
+func (h *RateHandler) GetRatePlan(c *gin.Context) {
+	plan, err := h.rates.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.rateError(c, err, i18n.GetRatePlanFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"ratePlan": plan})
+}
+
+type ratePlanRequest struct {
+	Name         string                  `json:"name" normalize:"text"`
+	RoomType     string                  `json:"roomType" normalize:"keyword"`
+	StartDate    string                  `json:"startDate" normalize:"date"`
+	EndDate      string                  `json:"endDate" normalize:"date"`
+	Price        float64                 `json:"price"`
+	WeekendPrice *float64                `json:"weekendPrice"`
+	Discounts    []services.StayDiscount `json:"discounts"`
+	Priority     int                     `json:"priority"`
+}
+
+func (r ratePlanRequest) input() services.RatePlanInput {
+	return services.RatePlanInput{
+		Name:         r.Name,
+		RoomType:     r.RoomType,
+		StartDate:    r.StartDate,
+		EndDate:      r.EndDate,
+		Price:        r.Price,
+		WeekendPrice: r.WeekendPrice,
+		Discounts:    r.Discounts,
+		Priority:     r.Priority,
+	}
+}
+
+// CreateRatePlan stores a new rate plan.
+func (h *RateHandler) CreateRatePlan(c *gin.Context) {
+	var payload ratePlanRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	plan, err := h.rates.Create(c.Request.Context(), payload.input())
+	if err != nil {
+		h.rateError(c, err, i18n.CreateRatePlanFailed)
+		return
+	}
+	c.Header("Location", "/rate-plans/"+plan.ID)
+	respond.Render(c, http.StatusCreated, gin.H{"ratePlan": plan})
+}
+
+// ReplaceRatePlan overwrites a rate plan with the received one.
+func (h *RateHandler) ReplaceRatePlan(c *gin.Context) {
+	var payload ratePlanRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	plan, err := h.rates.Replace(c.Request.Context(), c.Param("id"), payload.input())
+	if err != nil {
+		h.rateError(c, err, i18n.UpdateRatePlanFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"ratePlan": plan})
+}
+
+// DeleteRatePlan removes a rate plan.
+func (h *RateHandler) DeleteRatePlan(c *gin.Context) {
+	if err := h.rates.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		h.rateError(c, err, i18n.DeleteRatePlanFailed)
+		return
+	}
+	i18n.Message(c, http.StatusOK, i18n.RatePlanDeleted)
+}
+
+func (h *RateHandler) rateError(c *gin.Context, err error, fallback i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRatePlanInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRatePlanInput)
+	case errors.Is(err, services.ErrInvalidRatePlanID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.RatePlanNotFound)
+	default:
+		serverError(c, err, fallback)
+	}
+}
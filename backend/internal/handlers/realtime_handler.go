@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/net/websocket"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+const (
+	// realtimePing is how often an idle WebSocket gets a ping message, so
+	// proxies do not close it.
+	realtimePing = 30 * time.Second
+	// realtimeWriteTimeout is how long a message may take to reach the
+	// client before the connection is dropped.
+	realtimeWriteTimeout = 10 * time.Second
+)
+
+// RealtimeHandler is the realtime gateway: the WebSocket the clients get
+// the messages of their channels on, and the activity stream.
+type RealtimeHandler struct {
+	hub      *realtime.Hub
+	tickets  *services.RealtimeTicketService
+	presence *services.PresenceService
+	activity *services.ActivityFeed
+}
+
+// NewRealtimeHandler builds a new RealtimeHandler instance.
+func NewRealtimeHandler(hub *realtime.Hub, tickets *services.RealtimeTicketService, presence *services.PresenceService, activity *services.ActivityFeed) *RealtimeHandler {
+	return &RealtimeHandler{hub: hub, tickets: tickets, presence: presence, activity: activity}
+}
+
+// Ticket issues a one-time ticket the signed-in user opens the WebSocket
+// with as GET /ws?ticket=..., since browsers cannot send the Authorization
+// header on it. Any replica accepts it.
+func (h *RealtimeHandler) Ticket(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	ticket, expiresAt, err := h.tickets.Issue(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.RealtimeTicketFailed)
+		return
+	}
+	respond.Render(c, http.StatusCreated, gin.H{"ticket": ticket, "expiresAt": expiresAt})
+}
+
+// Connect upgrades the request of a signed-in user, or of the holder of a
+// ticket, to a WebSocket carrying the messages of their channel as JSON
+// text frames, plus a "ping" message while it is idle. The client sends
+// presence heartbeats for the shared lists it has open, and gets the
+// presence messages of those lists too.
+func (h *RealtimeHandler) Connect(c *gin.Context) {
+	if !signedInOrTicket(c) {
+		return
+	}
+	// Checked before taking the ticket, so a request that cannot be
+	// upgraded does not use it up.
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		i18n.Error(c, http.StatusBadRequest, i18n.WebSocketRequired)
+		return
+	}
+	email, ok := h.streamUser(c)
+	if !ok {
+		return
+	}
+
+	// Subscribe before the handshake so nothing published once the client
+	// sees the connection open is missed.
+	sub := h.hub.Subscribe(realtime.UserChannel(email))
+	defer sub.Close()
+	conn := &realtimeConn{
+		email:    email,
+		sub:      sub,
+		presence: h.presence,
+		viewing:  map[primitive.ObjectID]struct{}{},
+		replies:  make(chan realtime.Message, 8),
+		done:     make(chan struct{}),
+	}
+	server := websocket.Server{
+		// The bearer token or the ticket authenticates the connection, not
+		// cookies, so any origin may open it.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler:   func(ws *websocket.Conn) { conn.serve(c.Request.Context(), ws) },
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// signedInOrTicket answers 401 to a request with neither a session nor a
+// ?ticket=.
+func signedInOrTicket(c *gin.Context) bool {
+	if _, signedIn := middleware.CurrentPrincipal(c); !signedIn && c.Query("ticket") == "" {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return false
+	}
+	return true
+}
+
+// streamUser returns the email of the signed-in user, or redeems the
+// ?ticket= of a stream opened without a session.
+func (h *RealtimeHandler) streamUser(c *gin.Context) (string, bool) {
+	if principal, signedIn := middleware.CurrentPrincipal(c); signedIn {
+		return principal.Email, true
+	}
+	email, err := h.tickets.Redeem(c.Request.Context(), c.Query("ticket"))
+	if errors.Is(err, services.ErrInvalidRealtimeTicket) {
+		i18n.Error(c, http.StatusUnauthorized, i18n.InvalidRealtimeTicket)
+		return "", false
+	}
+	if err != nil {
+		serverError(c, err, i18n.RealtimeTicketFailed)
+		return "", false
+	}
+	return email, true
+}
+
+// ActivityStream streams the activity of the signed-in user, or of the
+// holder of a ticket, as server-sent events: one event per entry, named
+// after its type and with its ID, so a reconnecting EventSource resumes
+// with Last-Event-ID (or ?after=) from the last one it got. Without
+// either it starts with the next entry; a comment goes out while it is
+// idle, so proxies do not close it.
+func (h *RealtimeHandler) ActivityStream(c *gin.Context) {
+	if !signedInOrTicket(c) {
+		return
+	}
+	email, ok := h.streamUser(c)
+	if !ok {
+		return
+	}
+	after := c.GetHeader("Last-Event-ID")
+	if after == "" {
+		after = c.Query("after")
+	}
+	// An unknown position starts with the next entry, like a new client.
+	afterID, _ := primitive.ObjectIDFromHex(after)
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	entries := make(chan services.ActivityEntry)
+	tailed := make(chan error, 1)
+	go func() {
+		tailed <- h.activity.Follow(ctx, email, afterID, func(entry services.ActivityEntry) error {
+			select {
+			case entries <- entry:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+	ping := time.NewTicker(realtimePing)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case err = <-tailed:
+			if err != nil && ctx.Err() == nil {
+				log.Printf("se corto el flujo de actividad de %s: %v", email, err)
+			}
+			return
+		case <-ping.C:
+			_, err = c.Writer.WriteString(": ping\n\n")
+		case entry := <-entries:
+			var data []byte
+			if data, err = json.Marshal(entry); err == nil {
+				_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", entry.ID.Hex(), entry.Type, data)
+			}
+		}
+		if err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// ListPresence lists the members viewing the list :id. It goes after
+// ObjectIDParam("id") and the list policy.
+func (h *RealtimeHandler) ListPresence(c *gin.Context) {
+	viewers, err := h.presence.Viewers(c.Request.Context(), middleware.GetObjectID(c, "id"))
+	if err != nil {
+		serverError(c, err, i18n.ListPresenceFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"viewers": viewers})
+}
+
+// Messages the clients send on the realtime WebSocket.
+const (
+	// clientPresenceHeartbeat says the client has the list open; it is
+	// repeated while it stays open.
+	clientPresenceHeartbeat = "presence.heartbeat"
+	// clientPresenceLeave says the client closed the list.
+	clientPresenceLeave = "presence.leave"
+	// clientError is the reply to a message the gateway could not handle.
+	clientError = "error"
+)
+
+// clientMessage is a message from the client.
+type clientMessage struct {
+	Type   string `json:"type"`
+	ListID string `json:"listId"`
+}
+
+// realtimeConn is an open realtime WebSocket.
+type realtimeConn struct {
+	email    string
+	sub      *realtime.Subscription
+	presence *services.PresenceService
+	// viewing holds the lists the client has open; only the reader uses it.
+	viewing map[primitive.ObjectID]struct{}
+	// replies carries the answers of the reader to the writer, until done
+	// is closed.
+	replies chan realtime.Message
+	done    chan struct{}
+}
+
+// serve writes the messages of the subscription and the replies to the
+// client on ws until either side closes it. On the way out the client
+// leaves the lists it had open.
+func (rc *realtimeConn) serve(ctx context.Context, ws *websocket.Conn) {
+	closed := make(chan struct{})
+	defer func() {
+		// Stop the reader and wait for it to leave the lists.
+		close(rc.done)
+		_ = ws.Close()
+		<-closed
+	}()
+	go func() {
+		defer close(closed)
+		defer rc.leaveAll(context.WithoutCancel(ctx))
+		for {
+			var msg clientMessage
+			err := websocket.JSON.Receive(ws, &msg)
+			var syntax *json.SyntaxError
+			var mismatch *json.UnmarshalTypeError
+			if errors.As(err, &syntax) || errors.As(err, &mismatch) {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			rc.receive(ctx, msg)
+		}
+	}()
+
+	ping := time.NewTicker(realtimePing)
+	defer ping.Stop()
+	for {
+		var msg realtime.Message
+		select {
+		case <-closed:
+			return
+		case <-ping.C:
+			msg = realtime.Message{Type: realtime.TypePing, Time: time.Now().UTC()}
+		case msg = <-rc.replies:
+		case next, ok := <-rc.sub.Messages():
+			if !ok {
+				return
+			}
+			msg = next
+		}
+		_ = ws.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			return
+		}
+	}
+}
+
+// receive handles a message from the client; unknown types are ignored.
+func (rc *realtimeConn) receive(ctx context.Context, msg clientMessage) {
+	if msg.Type != clientPresenceHeartbeat && msg.Type != clientPresenceLeave {
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(msg.ListID)
+	if err != nil {
+		rc.reply(i18n.InvalidID, msg.ListID)
+		return
+	}
+	channel := realtime.ListChannel(id.Hex())
+	if msg.Type == clientPresenceLeave {
+		rc.leave(ctx, id)
+		return
+	}
+
+	err = rc.presence.Heartbeat(ctx, id, rc.email)
+	switch {
+	case err == nil:
+		if _, ok := rc.viewing[id]; !ok {
+			rc.viewing[id] = struct{}{}
+			rc.sub.Join(channel)
+		}
+	case errors.Is(err, services.ErrNotFound):
+		rc.leave(ctx, id)
+		rc.reply(i18n.ListNotFound, msg.ListID)
+	case errors.Is(err, services.ErrListForbidden):
+		rc.leave(ctx, id)
+		rc.reply(i18n.ListForbidden, msg.ListID)
+	default:
+		log.Printf("no se pudo registrar la presencia de %s en la lista %s: %v", rc.email, msg.ListID, err)
+		rc.reply(i18n.ListPresenceFailed, msg.ListID)
+	}
+}
+
+// leave takes the client out of the list id.
+func (rc *realtimeConn) leave(ctx context.Context, id primitive.ObjectID) {
+	if _, ok := rc.viewing[id]; !ok {
+		return
+	}
+	delete(rc.viewing, id)
+	rc.sub.Leave(realtime.ListChannel(id.Hex()))
+	if err := rc.presence.Leave(ctx, id, rc.email); err != nil {
+		log.Printf("no se pudo registrar que %s dejo la lista %s: %v", rc.email, id.Hex(), err)
+	}
+}
+
+// leaveAll takes the client out of every list it has open.
+func (rc *realtimeConn) leaveAll(ctx context.Context) {
+	for id := range rc.viewing {
+		rc.leave(ctx, id)
+	}
+}
+
+// reply sends the client an error about the list id.
+func (rc *realtimeConn) reply(code i18n.Code, listID string) {
+	msg, err := realtime.NewMessage("", clientError, gin.H{"code": code, "listId": listID}, time.Now())
+	if err != nil {
+		return
+	}
+	select {
+	case rc.replies <- msg:
+	case <-rc.done:
+	}
+}
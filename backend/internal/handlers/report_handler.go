@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// MIMECSV is offered by the report endpoints in addition to the usual formats.
+const MIMECSV = "text/csv"
+
+// ReportHandler exposes the occupancy and revenue reports and the weekly
+// summary of the todos.
+type ReportHandler struct {
+	reports   *services.ReportService
+	summaries *services.TodoSummaryService
+}
+
+// NewReportHandler builds a new ReportHandler instance.
+func NewReportHandler(reports *services.ReportService, summaries *services.TodoSummaryService) *ReportHandler {
+	return &ReportHandler{reports: reports, summaries: summaries}
+}
+
+type occupancyRow struct {
+	Period          string  `json:"period" xml:"period"`
+	Start           string  `json:"start" xml:"start"`
+	End             string  `json:"end" xml:"end"`
+	AvailableNights int     `json:"availableNights" xml:"availableNights"`
+	SoldNights      int     `json:"soldNights" xml:"soldNights"`
+	Occupancy       float64 `json:"occupancy" xml:"occupancy"`
+}
+
+type revenueRow struct {
+	Period     string  `json:"period" xml:"period"`
+	Start      string  `json:"start" xml:"start"`
+	End        string  `json:"end" xml:"end"`
+	SoldNights int     `json:"soldNights" xml:"soldNights"`
+	Revenue    float64 `json:"revenue" xml:"revenue"`
+	ADR        float64 `json:"adr" xml:"adr"`
+	RevPAR     float64 `json:"revpar" xml:"revpar"`
+}
+
+// Occupancy reports sold and available room nights per period.
+func (h *ReportHandler) Occupancy(c *gin.Context) {
+	periods, ok := h.report(c)
+	if !ok {
+		return
+	}
+
+	if wantsCSV(c) {
+		records := [][]string{{"period", "start", "end", "availableNights", "soldNights", "occupancy"}}
+		for _, p := range periods {
+			records = append(records, []string{
+				p.Period, p.Start, p.End,
+				strconv.Itoa(p.AvailableNights), strconv.Itoa(p.SoldNights), formatAmount(p.Occupancy),
+			})
+		}
+		renderCSV(c, "occupancy.csv", records)
+		return
+	}
+
+	rows := make([]occupancyRow, 0, len(periods))
+	for _, p := range periods {
+		rows = append(rows, occupancyRow{
+			Period:          p.Period,
+			Start:           p.Start,
+			End:             p.End,
+			AvailableNights: p.AvailableNights,
+			SoldNights:      p.SoldNights,
+			Occupancy:       p.Occupancy,
+		})
+	}
+	respond.Render(c, http.StatusOK, gin.H{"periods": rows})
+}
+
+// Revenue reports revenue, ADR and RevPAR per period.
+func (h *ReportHandler) Revenue(c *gin.Context) {
+	periods, ok := h.report(c)
+	if !ok {
+		return
+	}
+
+	if wantsCSV(c) {
+		records := [][]string{{"period", "start", "end", "soldNights", "revenue", "adr", "revpar"}}
+		for _, p := range periods {
+			records = append(records, []string{
+				p.Period, p.Start, p.End, strconv.Itoa(p.SoldNights),
+				formatAmount(p.Revenue), formatAmount(p.ADR), formatAmount(p.RevPAR),
+			})
+		}
+		renderCSV(c, "revenue.csv", records)
+		return
+	}
+
+	rows := make([]revenueRow, 0, len(periods))
+	for _, p := range periods {
+		rows = append(rows, revenueRow{
+			Period:     p.Period,
+			Start:      p.Start,
+			End:        p.End,
+			SoldNights: p.SoldNights,
+			Revenue:    p.Revenue,
+			ADR:        p.ADR,
+			RevPAR:     p.RevPAR,
+		})
+	}
+	respond.Render(c, http.StatusOK, gin.H{"periods": rows})
+}
+
+// report runs the report for ?from=&to=&groupBy= writing the error response
+// when it fails.
+func (h *ReportHandler) report(c *gin.Context) ([]services.ReportPeriod, bool) {
+	periods, err := h.reports.Report(c.Request.Context(), services.ReportQuery{
+		From:    c.Query("from"),
+		To:      c.Query("to"),
+		GroupBy: c.Query("groupBy"),
+	})
+	switch {
+	case err == nil:
+		return periods, true
+	case errors.Is(err, services.ErrInvalidReportQuery):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidReportQuery)
+	default:
+		serverError(c, err, i18n.ReportFailed)
+	}
+	return nil, false
+}
+
+// wantsCSV reports whether the client asked for CSV through ?format=csv or
+// the Accept header.
+func wantsCSV(c *gin.Context) bool {
+	return c.Query("format") == "csv" || c.NegotiateFormat(binding.MIMEJSON, MIMECSV) == MIMECSV
+}
+
+// renderCSV writes records as a downloadable CSV file.
+func renderCSV(c *gin.Context, filename string, records [][]string) {
+	c.Header("Vary", "Accept")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Content-Type", MIMECSV+"; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.WriteAll(records)
+}
+
+func formatAmount(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// ReviewHandler exposes HTTP handlers for stay reviews.
+type ReviewHandler struct {
+	reviews *services.ReviewService
+}
+
+// NewReviewHandler builds a new ReviewHandler instance.
+func NewReviewHandler(reviews *services.ReviewService) *ReviewHandler {
+	return &ReviewHandler{reviews: reviews}
+}
+
+type reviewRequest struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment" normalize:"text"`
+}
+
+// CreateReview rates a checked-out booking.
+func (h *ReviewHandler) CreateReview(c *gin.Context) {
+	var payload reviewRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	review, err := h.reviews.Create(c.Request.Context(), c.Param("id"), payload.Rating, payload.Comment)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"review": review})
+	case errors.Is(err, services.ErrInvalidReviewInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidReviewInput)
+	case errors.Is(err, services.ErrInvalidBookingID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.BookingNotFound)
+	case errors.Is(err, services.ErrReviewNotAllowed):
+		i18n.Error(c, http.StatusConflict, i18n.ReviewNotAllowed)
+	case errors.Is(err, services.ErrReviewExists):
+		i18n.Error(c, http.StatusConflict, i18n.ReviewExists)
+	default:
+		serverError(c, err, i18n.CreateReviewFailed)
+	}
+}
+
+// ListRoomReviews returns a page of the reviews of a room, newest first.
+func (h *ReviewHandler) ListRoomReviews(c *gin.Context) {
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+		return
+	}
+
+	result, err := h.reviews.List(c.Request.Context(), c.Param("id"), services.ReviewQuery{
+		Offset: page.Offset,
+		Limit:  page.Limit,
+	})
+	switch {
+	case err == nil:
+		respond.RenderPage(c, http.StatusOK, gin.H{
+			"reviews": result.Reviews,
+			"links":   pageLinks(c, page, result.Total),
+		}, pageMeta(page, result.Total))
+	case errors.Is(err, services.ErrInvalidRoomID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	default:
+		serverError(c, err, i18n.ListReviewsFailed)
+	}
+}
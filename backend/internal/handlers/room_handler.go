@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// RoomHandler exposes HTTP handlers for hotel rooms.
+type RoomHandler struct {
+	rooms *services.RoomService
+}
+
+// NewRoomHandler builds a new RoomHandler instance.
+func NewRoomHandler(rooms *services.RoomService) *RoomHandler {
+	return &RoomHandler{rooms: rooms}
+}
+
+// ListRooms retrieves rooms optionally filtered by ?type= and ?status=.
+func (h *RoomHandler) ListRooms(c *gin.Context) {
+	rooms, err := h.rooms.List(c.Request.Context(), services.RoomQuery{
+		Type:   c.Query("type"),
+		Status: c.Query("status"),
+	})
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"rooms": rooms})
+	case errors.Is(err, services.ErrInvalidRoomInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRoomFilter)
+	default:
+		serverError(c, err, i18n.ListRoomsFailed)
+	}
+}
+
+// GetRoom returns a single room.
+func (h *RoomHandler) GetRoom(c *gin.Context) {
+	room, err := h.rooms.Get(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"room": room})
+	case errors.Is(err, services.ErrInvalidRoomID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.RoomNotFound)
+	default:
+		serverError(c, err, i18n.GetRoomFailed)
+	}
+}
+
+type roomRequest struct {
+	Number    *string   `json:"number" normalize:"text"`
+	Type      *string   `json:"type" normalize:"keyword"`
+	Capacity  *int      `json:"capacity"`
+	Price     *float64  `json:"price"`
+	Amenities *[]string `json:"amenities" normalize:"keyword"`
+	Status    *string   `json:"status" normalize:"keyword"`
+}
+
+// CreateRoom stores a new room.
+func (h *RoomHandler) CreateRoom(c *gin.Context) {
+	var payload roomRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	var room services.Room
+	if payload.Number != nil {
+		room.Number = *payload.Number
+	}
+	if payload.Type != nil {
+		room.Type = *payload.Type
+	}
+	if payload.Capacity != nil {
+		room.Capacity = *payload.Capacity
+	}
+	if payload.Price != nil {
+		room.Price = *payload.Price
+	}
+	if payload.Amenities != nil {
+		room.Amenities = *payload.Amenities
+	}
+	if payload.Status != nil {
+		room.Status = *payload.Status
+	}
+
+	created, err := h.rooms.Create(c.Request.Context(), room)
+	switch {
+	case err == nil:
+		c.Header("Location", "/rooms/"+created.ID)
+		respond.Render(c, http.StatusCreated, gin.H{"room": created})
+	case errors.Is(err, services.ErrInvalidRoomInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRoomInput)
+	case errors.Is(err, services.ErrRoomNumberTaken):
+		i18n.Error(c, http.StatusConflict, i18n.RoomNumberTaken)
+	default:
+		serverError(c, err, i18n.CreateRoomFailed)
+	}
+}
+
+// UpdateRoom modifies an existing room.
+func (h *RoomHandler) UpdateRoom(c *gin.Context) {
+	var payload roomRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	room, err := h.rooms.Update(c.Request.Context(), c.Param("id"), services.RoomUpdate{
+		Number:    payload.Number,
+		Type:      payload.Type,
+		Capacity:  payload.Capacity,
+		Price:     payload.Price,
+		Amenities: payload.Amenities,
+		Status:    payload.Status,
+	})
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"room": room})
+	case errors.Is(err, services.ErrInvalidRoomInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRoomInput)
+	case errors.Is(err, services.ErrInvalidRoomID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.RoomNotFound)
+	case errors.Is(err, services.ErrRoomNumberTaken):
+		i18n.Error(c, http.StatusConflict, i18n.RoomNumberTaken)
+	default:
+		serverError(c, err, i18n.UpdateRoomFailed)
+	}
+}
+
+// DeleteRoom removes a room by ID.
+func (h *RoomHandler) DeleteRoom(c *gin.Context) {
+	err := h.rooms.Delete(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.RoomDeleted)
+	case errors.Is(err, services.ErrInvalidRoomID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.RoomNotFound)
+	default:
+		serverError(c, err, i18n.DeleteRoomFailed)
+	}
+}
@@ -1,48 +1,408 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/api"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mock"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/timing"
 )
 
 // RouterConfig allows customising router construction (handy for tests).
 type RouterConfig struct {
-	AllowedOrigins []string
+	// Origins are the frontend origins allowed by CORS; the development
+	// frontends when nil.
+	Origins *middleware.Origins
+	// TrustedProxies lists the proxy IPs/CIDRs whose forwarding headers are
+	// honoured by c.ClientIP(). When empty, no proxy is trusted and the
+	// connection's remote address is used.
+	TrustedProxies []string
+	// RequestTimeout bounds each request (zero disables it); RouteTimeouts
+	// overrides the budget per "METHOD /path" route.
+	RequestTimeout time.Duration
+	RouteTimeouts  map[string]time.Duration
+	// AdminToken protects the /admin endpoints; when empty they are disabled.
+	// It also grants access to every staff endpoint.
+	AdminToken string
+	// Maintenance is the shared maintenance switch; a disabled one is created
+	// when nil.
+	Maintenance *middleware.MaintenanceMode
+	// BodyLog enables debug logging of request/response bodies when not nil.
+	BodyLog *middleware.BodyLog
+	// Alerts is notified about recovered panics; nil discards them.
+	Alerts alerts.Notifier
+	// Monitor watches the error rate and the failed logins to alert on
+	// spikes when not nil.
+	Monitor *alerts.Monitor
+	// ContractMode enables OpenAPI response validation ("log" or "fail");
+	// empty disables it.
+	ContractMode string
+	// IPRules filter every request; AdminIPRules add to them for /admin
+	// and TestingIPRules for the endpoints that wipe data in tests.
+	IPRules        middleware.IPRules
+	AdminIPRules   middleware.IPRules
+	TestingIPRules middleware.IPRules
+	// ServerTiming reports the database and serialization time of each
+	// request in the Server-Timing header.
+	ServerTiming bool
+	// Faults injects latency, errors and dropped connections for resilience
+	// tests when not nil, and mounts /admin/faults to tune them.
+	Faults *middleware.FaultInjector
+	// Mocks records what the mock integrations would have sent; when not
+	// nil, /admin/outbox-preview lists it.
+	Mocks *mock.Recorder
+	// Deprecations marks and counts the use of deprecated endpoints and
+	// fields; one with Deprecations(nil) is created when nil.
+	Deprecations *middleware.Deprecations
+	// RateLimiter caps the requests of each caller when not nil.
+	RateLimiter *middleware.RateLimiter
+}
+
+// Handlers groups the resource handlers mounted by SetupRouter.
+type Handlers struct {
+	Auth          *AuthHandler
+	Todos         *TodoHandler
+	Rooms         *RoomHandler
+	Bookings      *BookingHandler
+	Guests        *GuestHandler
+	Rates         *RateHandler
+	Payments      *PaymentHandler
+	Reviews       *ReviewHandler
+	Reports       *ReportHandler
+	Properties    *PropertyHandler
+	Waitlist      *WaitlistHandler
+	Mail          *MailHandler
+	Imports       *ImportHandler
+	Jobs          *JobHandler
+	DeadLetters   *DeadLetterHandler
+	Dashboard     *DashboardHandler
+	Quotas        *QuotaHandler
+	Privacy       *PrivacyHandler
+	Passkeys      *PasskeyHandler
+	SSO           *SSOHandler
+	SCIM          *SCIMHandler
+	Backups       *BackupHandler
+	Comments      *CommentHandler
+	Attachments   *AttachmentHandler
+	Notifications *NotificationHandler
+	Realtime      *RealtimeHandler
+	Approvals     *ApprovalHandler
+	Lists         *ListHandler
+	Feeds         *FeedHandler
+	CalDAV        *CalDAVHandler
+	TodoImports   *TodoImportHandler
+	Stats         *StatsHandler
+	Audit         *AuditHandler
+	Merges        *AccountMergeHandler
+	EmailChanges  *EmailChangeHandler
+	Sync          *SyncHandler
 }
 
+// streamingRoutes have no deadline unless RouteTimeouts sets one: the
+// timeout buffers the whole response, backups and dumps can be large and
+// the realtime WebSocket and the activity stream stay open.
+var streamingRoutes = []string{"POST /admin/backup", "POST /admin/restore", "GET /admin/export/:collection", "GET /ws", "GET /activity/stream"}
+
 // SetupRouter wires handlers with the HTTP routes.
-func SetupRouter(auth *AuthHandler, todos *TodoHandler, cfg RouterConfig) *gin.Engine {
-	router := gin.Default()
+func SetupRouter(h Handlers, cfg RouterConfig) *gin.Engine {
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(routeNotFound)
+	router.NoMethod(methodNotAllowed)
+	router.Use(middleware.RequestID(), middleware.AccessLogger())
+	if cfg.Monitor != nil {
+		// Before Recovery, so recovered panics count as errors.
+		router.Use(middleware.Monitor(cfg.Monitor))
+	}
+	router.Use(middleware.Recovery(cfg.Alerts))
+	if cfg.ServerTiming {
+		router.Use(middleware.ServerTiming())
+	}
+	router.Use(i18n.Middleware())
+	router.Use(middleware.IPFilter(cfg.IPRules))
 
-	origins := cfg.AllowedOrigins
-	if len(origins) == 0 {
-		origins = []string{"http://localhost:3000", "http://localhost:3001"}
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("proxies de confianza invalidos, se ignoran: %v", err)
+		_ = router.SetTrustedProxies(nil)
 	}
 
+	origins := cfg.Origins
+	if origins == nil {
+		origins = middleware.NewOrigins([]string{"http://localhost:3000", "http://localhost:3001"})
+	}
+
+	allowHeaders := []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.AdminTokenHeader, middleware.PropertyHeader, middleware.RequestIDHeader}
+	if cfg.Faults != nil {
+		allowHeaders = append(allowHeaders, middleware.FaultHeaders...)
+	}
+	exposeHeaders := []string{middleware.RequestIDHeader, "Link", timing.Header, middleware.DeprecationHeader, middleware.SunsetHeader}
+	if cfg.RateLimiter != nil {
+		exposeHeaders = append(exposeHeaders, middleware.RateLimitHeaders...)
+	}
 	corsCfg := cors.Config{
-		AllowOrigins:     origins,
+		AllowOriginFunc:  origins.Allowed,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     allowHeaders,
+		ExposeHeaders:    exposeHeaders,
 		AllowCredentials: true,
 	}
 	router.Use(cors.New(corsCfg))
+	if cfg.Faults != nil {
+		router.Use(cfg.Faults.Middleware("/admin"))
+	}
+	if cfg.BodyLog != nil {
+		router.Use(cfg.BodyLog.Handler())
+	}
+	if cfg.ContractMode != "" {
+		validator, err := middleware.ContractValidator(api.OpenAPISpec, cfg.ContractMode)
+		if err != nil {
+			panic(fmt.Sprintf("especificacion OpenAPI invalida: %v", err))
+		}
+		router.Use(validator)
+	}
+	routeTimeouts := make(map[string]time.Duration, len(cfg.RouteTimeouts)+len(streamingRoutes))
+	for _, route := range streamingRoutes {
+		routeTimeouts[route] = 0
+	}
+	for route, budget := range cfg.RouteTimeouts {
+		routeTimeouts[route] = budget
+	}
+	router.Use(middleware.Timeout(cfg.RequestTimeout, routeTimeouts))
+
+	maintenance := cfg.Maintenance
+	if maintenance == nil {
+		maintenance = middleware.NewMaintenanceMode(false, time.Minute)
+	}
+	router.Use(maintenance.Guard("/admin"))
+	deprecations := cfg.Deprecations
+	if deprecations == nil {
+		deprecations = middleware.NewDeprecations(nil, Deprecations(nil)...)
+	}
+	router.Use(deprecations.Middleware())
+	router.Use(middleware.Authenticate(h.Auth.Resolve, "/scim/", CalDAVPrefix+"/", "/.well-known/caldav"), h.Properties.Scope, auditActor(cfg.AdminToken))
+	if cfg.RateLimiter != nil {
+		router.Use(cfg.RateLimiter.Handler(cfg.AdminToken, "/healthz", "/metrics"))
+	}
+
+	adminIPs := middleware.IPFilter(cfg.AdminIPRules)
+	testingIPs := middleware.IPFilter(cfg.TestingIPRules)
+	managers := middleware.RequireRole(cfg.AdminToken, services.RoleManager)
+	frontDesk := middleware.RequireRole(cfg.AdminToken, services.RoleManager, services.RoleFrontDesk)
+	housekeeping := middleware.RequireRole(cfg.AdminToken, services.RoleManager, services.RoleFrontDesk, services.RoleHousekeeping)
 
 	router.GET("/healthz", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		respond.Render(c, http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// Prometheus scrapes the domain counters from the admin networks.
+	router.GET("/metrics", adminIPs, gin.WrapH(metrics.Default))
+
+	router.GET("/openapi.yaml", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/yaml", api.OpenAPISpec)
 	})
 
-	router.POST("/register", auth.Register)
-	router.POST("/login", auth.Login)
-	router.GET("/users", auth.ListUsers)
-	router.DELETE("/users", auth.ClearUsers)
+	router.POST("/register", h.Auth.Register)
+	logins := middleware.MonitorLogins(cfg.Monitor)
+	router.POST("/login", logins, h.Auth.Login)
+	router.POST("/login/passkey/options", h.Passkeys.LoginOptions)
+	router.POST("/login/passkey", logins, h.Passkeys.Login)
+	router.POST("/login/sso/options", h.SSO.LoginOptions)
+	router.POST("/login/sso/link", h.SSO.LinkOptions)
+	router.POST("/login/sso", logins, h.SSO.Login)
+	router.GET("/users", h.Auth.ListUsers)
+
+	// SCIM clients authenticate with the token of their property.
+	scim := router.Group("/scim/v2", h.SCIM.Authenticate)
+	scim.GET("/Users", h.SCIM.ListUsers)
+	scim.POST("/Users", h.SCIM.CreateUser)
+	scim.GET("/Users/:id", h.SCIM.GetUser)
+	scim.PATCH("/Users/:id", h.SCIM.PatchUser)
+	scim.DELETE("/Users/:id", h.SCIM.DeleteUser)
+
+	// CalDAV clients authenticate with HTTP Basic; see CalDAVHandler.
+	router.GET("/.well-known/caldav", h.CalDAV.WellKnown)
+	router.Handle("PROPFIND", "/.well-known/caldav", h.CalDAV.WellKnown)
+	for _, method := range CalDAVMethods {
+		router.Handle(method, CalDAVPrefix+"/*path", h.CalDAV.Serve)
+	}
+
+	router.GET("/users/me/usage", h.Quotas.Usage)
+	router.GET("/stats/breakdown", h.Stats.Breakdown)
+	router.GET("/users/me/sessions", h.Auth.ListSessions)
+	router.DELETE("/users/me/sessions/:id", h.Auth.RevokeSession)
+	router.GET("/users/me/logins", h.Auth.ListLogins)
+	router.POST("/users/me/passkeys/options", h.Passkeys.RegistrationOptions)
+	router.GET("/users/me/passkeys", h.Passkeys.ListPasskeys)
+	router.POST("/users/me/passkeys", h.Passkeys.RegisterPasskey)
+	router.DELETE("/users/me/passkeys/:id", h.Passkeys.DeletePasskey)
+	router.POST("/users/me/feed-token", h.Feeds.IssueFeedToken)
+	router.DELETE("/users/me/feed-token", h.Feeds.RevokeFeedToken)
+	router.GET("/users/me/export", h.Privacy.ExportAccount)
+	router.DELETE("/users/me", h.Privacy.DeleteAccount)
+	router.POST("/users/me/merge", h.Merges.MergeAccount)
+	router.PUT("/users/me/email", h.EmailChanges.RequestEmailChange)
+	router.GET("/users/email/confirm", h.EmailChanges.ConfirmEmailChange)
+	router.GET("/users/email/cancel", h.EmailChanges.CancelEmailChange)
+	router.GET("/users/erasures/:id", h.Privacy.GetErasure)
+	router.DELETE("/users", testingIPs, h.Privacy.AnonymizeUsers, h.Auth.ClearUsers)
+
+	router.GET("/notifications", h.Notifications.ListNotifications)
+	router.POST("/notifications/read-all", h.Notifications.ReadAllNotifications)
+	router.POST("/notifications/:id/read", middleware.ObjectIDParam("id"), h.Notifications.ReadNotification)
+	router.POST("/ws/ticket", h.Realtime.Ticket)
+	router.GET("/ws", h.Realtime.Connect)
+	router.GET("/activity/stream", h.Realtime.ActivityStream)
+
+	router.GET("/properties", h.Properties.ListProperties)
+	router.POST("/properties", middleware.RequireAdminToken(cfg.AdminToken), h.Properties.CreateProperty)
+
+	router.GET("/todos", h.Todos.ListTodos)
+	router.POST("/todos", h.Todos.CreateTodo)
+	router.POST("/todos/import", h.TodoImports.ImportTodos)
+	router.POST("/sync/ops", h.Sync.SyncOps)
+	// Feed readers authenticate with the feed token in the URL.
+	router.GET("/todos/feed.xml", h.Feeds.Feed)
+	router.POST("/todos/toggle-all", h.Todos.ToggleAll)
+	router.DELETE("/todos/completed", h.Todos.ClearCompleted)
+	todoID := h.Todos.TodoIDParam("id")
+	// The todos of a shared list follow the list policy; see ListHandler.
+	write, comment := h.Lists.RequireTodo(policy.Write), h.Lists.RequireTodo(policy.Comment)
+	router.GET("/todos/:id", todoID, h.Lists.RequireTodo(policy.Read), h.Todos.GetTodo)
+	router.PUT("/todos/:id", todoID, write, h.Todos.UpdateTodo)
+	router.DELETE("/todos/:id", todoID, write, h.Todos.DeleteTodo)
+	router.POST("/todos/:id/restore", todoID, write, h.Todos.RestoreTodo)
+	router.POST("/todos/:id/reactions", todoID, comment, h.Todos.ReactTodo)
+	router.DELETE("/todos/:id/reactions", todoID, comment, h.Todos.UnreactTodo)
+	router.GET("/todos/:id/title", todoID, h.Lists.RequireTodo(policy.Read), h.Todos.GetTitle)
+	router.POST("/todos/:id/title/edits", todoID, write, h.Todos.EditTitle)
+	router.GET("/todos/:id/comments", todoID, h.Lists.RequireTodo(policy.Read), h.Comments.ListComments)
+	router.POST("/todos/:id/comments", todoID, comment, h.Comments.CreateComment)
+	attachmentID := middleware.ObjectIDParam("attachmentId")
+	router.GET("/todos/:id/attachments", todoID, h.Lists.RequireTodo(policy.Read), h.Attachments.ListAttachments)
+	router.POST("/todos/:id/attachments", todoID, write, h.Attachments.UploadAttachment)
+	router.GET("/todos/:id/attachments/:attachmentId", todoID, attachmentID, h.Lists.RequireTodo(policy.Read), h.Attachments.DownloadAttachment)
+	router.POST("/todos/:id/attachments/:attachmentId/url", todoID, attachmentID, h.Lists.RequireTodo(policy.Read), h.Attachments.SignAttachment)
+	router.DELETE("/todos/:id/attachments/:attachmentId", todoID, attachmentID, write, h.Attachments.DeleteAttachment)
+	router.GET("/attachments/:id", h.Attachments.DownloadSigned)
+	router.POST("/todos/:id/approval", todoID, write, h.Approvals.RequestApproval)
+	router.POST("/todos/:id/approve", todoID, write, h.Approvals.ApproveTodo)
+	router.POST("/todos/:id/reject", todoID, write, h.Approvals.RejectTodo)
+	router.DELETE("/todos", testingIPs, h.Todos.ClearTodos)
+
+	listID := middleware.ObjectIDParam("id")
+	router.GET("/lists", h.Lists.ListLists)
+	router.POST("/lists", h.Lists.CreateList)
+	router.GET("/lists/:id", listID, h.Lists.Require(policy.Read), h.Lists.GetList)
+	router.GET("/lists/:id/todos", listID, h.Lists.Require(policy.Read), h.Lists.ListListTodos)
+	router.GET("/lists/:id/presence", listID, h.Lists.Require(policy.Read), h.Realtime.ListPresence)
+	router.POST("/lists/:id/members", listID, h.Lists.AddMember)
+	router.PUT("/lists/:id/members/:email", listID, h.Lists.SetMemberRole)
+	router.DELETE("/lists/:id/members/:email", listID, h.Lists.RemoveMember)
+
+	router.GET("/rooms", h.Rooms.ListRooms)
+	router.POST("/rooms", managers, h.Rooms.CreateRoom)
+	router.GET("/rooms/availability", h.Bookings.Availability)
+	router.GET("/rooms/:id", h.Rooms.GetRoom)
+	router.PUT("/rooms/:id", managers, h.Rooms.UpdateRoom)
+	router.DELETE("/rooms/:id", managers, h.Rooms.DeleteRoom)
+	router.GET("/rooms/:id/todos", housekeeping, h.Todos.ListRoomTodos)
+	router.GET("/rooms/:id/reviews", h.Reviews.ListRoomReviews)
 
-	router.GET("/todos", todos.ListTodos)
-	router.POST("/todos", todos.CreateTodo)
-	router.PUT("/todos/:id", todos.UpdateTodo)
-	router.DELETE("/todos/:id", todos.DeleteTodo)
-	router.DELETE("/todos", todos.ClearTodos)
+	router.GET("/bookings", frontDesk, h.Bookings.ListBookings)
+	router.POST("/bookings", frontDesk, h.Bookings.CreateBooking)
+	router.GET("/bookings/quote", h.Bookings.Quote)
+	router.GET("/bookings/:id", frontDesk, h.Bookings.GetBooking)
+	router.PUT("/bookings/:id", frontDesk, h.Bookings.UpdateBooking)
+	router.POST("/bookings/:id/cancel", frontDesk, h.Bookings.CancelBooking)
+	router.POST("/bookings/:id/check-in", frontDesk, h.Bookings.CheckIn)
+	router.POST("/bookings/:id/check-out", frontDesk, h.Bookings.CheckOut)
+	router.GET("/bookings/:id/payments", frontDesk, h.Payments.ListPayments)
+	router.POST("/bookings/:id/payments", frontDesk, h.Payments.CreatePayment)
+	router.POST("/bookings/:id/review", h.Reviews.CreateReview)
+	router.POST("/payments/webhook", h.Payments.PaymentWebhook)
+
+	router.GET("/waitlist", frontDesk, h.Waitlist.ListWaitlist)
+	router.POST("/waitlist", frontDesk, h.Waitlist.JoinWaitlist)
+	router.POST("/waitlist/:id/confirm", frontDesk, h.Waitlist.ConfirmWaitlist)
+
+	router.GET("/mail/opt-out", h.Mail.OptOut)
+
+	router.POST("/integrations/bookings/import", managers, h.Imports.ImportBookings)
+	router.GET("/integrations/bookings/imports/:id", managers, h.Imports.GetImport)
+
+	router.GET("/guests", frontDesk, h.Guests.ListGuests)
+	router.POST("/guests", frontDesk, h.Guests.CreateGuest)
+	router.GET("/guests/:id", frontDesk, h.Guests.GetGuest)
+	router.PUT("/guests/:id", frontDesk, h.Guests.UpdateGuest)
+	router.DELETE("/guests/:id", frontDesk, h.Guests.DeleteGuest)
+	router.GET("/guests/:id/bookings", frontDesk, h.Guests.ListGuestBookings)
+
+	router.GET("/rate-plans", h.Rates.ListRatePlans)
+	router.POST("/rate-plans", managers, h.Rates.CreateRatePlan)
+	router.GET("/rate-plans/:id", h.Rates.GetRatePlan)
+	router.PUT("/rate-plans/:id", managers, h.Rates.ReplaceRatePlan)
+	router.DELETE("/rate-plans/:id", managers, h.Rates.DeleteRatePlan)
+
+	reports := router.Group("/reports", managers)
+	reports.GET("/occupancy", h.Reports.Occupancy)
+	reports.GET("/revenue", h.Reports.Revenue)
+	reports.GET("/weekly.pdf", h.Reports.WeeklyPDF)
+
+	// The dashboard powers the ops UI, so managers reach it with their
+	// session as well as with the admin token.
+	dashboard := router.Group("/admin/dashboard", adminIPs, managers)
+	dashboard.GET("/users", h.Dashboard.Users)
+	dashboard.GET("/signups", h.Dashboard.Signups)
+	dashboard.GET("/todos", h.Dashboard.Todos)
+	dashboard.GET("/webhooks", h.Dashboard.Webhooks)
+	dashboard.GET("/storage", h.Dashboard.Storage)
+
+	admin := NewAdminHandler(maintenance, cfg.Faults, cfg.Mocks, deprecations)
+	adminGroup := router.Group("/admin", adminIPs, middleware.RequireAdminToken(cfg.AdminToken))
+	adminGroup.GET("/maintenance", admin.GetMaintenance)
+	adminGroup.PUT("/maintenance", admin.SetMaintenance)
+	if cfg.Faults != nil {
+		adminGroup.GET("/faults", admin.GetFaults)
+		adminGroup.PUT("/faults", admin.SetFaults)
+	}
+	if cfg.Mocks != nil {
+		adminGroup.GET("/outbox-preview", admin.OutboxPreview)
+		adminGroup.DELETE("/outbox-preview", admin.ClearOutboxPreview)
+	}
+	adminGroup.GET("/deprecations", admin.ListDeprecations)
+	adminGroup.GET("/audit", h.Audit.ListAudit)
+	adminGroup.POST("/backup", h.Backups.Backup)
+	adminGroup.POST("/restore", h.Backups.Restore)
+	adminGroup.GET("/export/:collection", h.Backups.Dump)
+	adminGroup.GET("/jobs", h.Jobs.ListJobs)
+	adminGroup.GET("/dead-letters", h.DeadLetters.ListDeadLetters)
+	adminGroup.POST("/dead-letters/retry", h.DeadLetters.RetryDeadLetters)
+	adminGroup.GET("/dead-letters/:id", h.DeadLetters.GetDeadLetter)
+	adminGroup.POST("/dead-letters/:id/retry", h.DeadLetters.RetryDeadLetter)
+	adminGroup.GET("/users", h.Auth.SearchUsers)
+	adminGroup.POST("/users/:email/suspend", h.Auth.SuspendUser)
+	adminGroup.DELETE("/users/:email/suspend", h.Auth.ReactivateUser)
+	adminGroup.POST("/users/:email/impersonate", h.Auth.Impersonate)
+	adminGroup.PUT("/users/:email/role", h.Auth.SetRole)
+	adminGroup.PUT("/users/:email/property", h.Properties.AssignStaff)
+	adminGroup.PUT("/properties/:id/sso", h.SSO.ConfigureSSO)
+	adminGroup.DELETE("/properties/:id/sso", h.SSO.DisableSSO)
+	adminGroup.POST("/properties/:id/scim-token", h.SCIM.IssueToken)
+	adminGroup.DELETE("/properties/:id/scim-token", h.SCIM.RevokeToken)
+	adminGroup.PUT("/users/:email/quota", h.Quotas.SetQuota)
 
 	return router
 }
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// scimMediaType is the media type of SCIM requests and responses.
+const scimMediaType = "application/scim+json"
+
+// scimDefaultCount is the page size of SCIM listings without count.
+const scimDefaultCount = 100
+
+const scimPropertyKey = "scimProperty"
+
+// SCIMHandler exposes the SCIM 2.0 users of each property to its identity
+// provider, and the admin endpoints that issue the SCIM tokens.
+type SCIMHandler struct {
+	scim *services.SCIMService
+}
+
+// NewSCIMHandler constructs a SCIMHandler instance.
+func NewSCIMHandler(scim *services.SCIMService) *SCIMHandler {
+	return &SCIMHandler{scim: scim}
+}
+
+// scimRender writes a SCIM body; SCIM clients expect the resources as they
+// are, without the response envelope.
+func scimRender(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", scimMediaType)
+	c.JSON(status, body)
+}
+
+// scimError writes a SCIM error with the translated message as detail.
+func scimError(c *gin.Context, status int, scimType string, code i18n.Code) {
+	body := gin.H{"schemas": []string{services.SCIMErrorSchema}, "status": strconv.Itoa(status), "detail": i18n.T(c, code)}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	scimRender(c, status, body)
+}
+
+// scimFailure answers the errors shared by the SCIM endpoints.
+func scimFailure(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		scimError(c, http.StatusNotFound, "", i18n.UserNotFound)
+	case errors.Is(err, services.ErrUnavailable):
+		c.Header("Retry-After", retryAfterSeconds)
+		scimError(c, http.StatusServiceUnavailable, "", i18n.ServiceUnavailable)
+	default:
+		scimError(c, http.StatusInternalServerError, "", i18n.SCIMFailed)
+	}
+}
+
+// Authenticate resolves the SCIM token of the "Authorization: Bearer"
+// header into the property the request provisions.
+func (h *SCIMHandler) Authenticate(c *gin.Context) {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	property, err := h.scim.Authenticate(c.Request.Context(), strings.TrimSpace(token))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSCIMToken) {
+			scimError(c, http.StatusUnauthorized, "", i18n.InvalidSCIMToken)
+		} else {
+			scimFailure(c, err)
+		}
+		c.Abort()
+		return
+	}
+	c.Set(scimPropertyKey, property)
+	c.Next()
+}
+
+func scimProperty(c *gin.Context) services.Property {
+	property, _ := c.MustGet(scimPropertyKey).(services.Property)
+	return property
+}
+
+type scimTokenRequest struct {
+	DefaultRole string `json:"defaultRole" normalize:"keyword"`
+}
+
+// IssueToken creates the SCIM token of a property, replacing the previous
+// one; the token is only shown in this response.
+func (h *SCIMHandler) IssueToken(c *gin.Context) {
+	var payload scimTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &payload); err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+			return
+		}
+	}
+
+	token, err := h.scim.IssueToken(c.Request.Context(), c.Param("id"), payload.DefaultRole)
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"scim": token})
+	case errors.Is(err, services.ErrInvalidRole):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRole)
+	case errors.Is(err, services.ErrInvalidPropertyID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPropertyID)
+	case errors.Is(err, services.ErrPropertyNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.PropertyNotFound)
+	default:
+		serverError(c, err, i18n.SCIMFailed)
+	}
+}
+
+// RevokeToken removes the SCIM token of a property.
+func (h *SCIMHandler) RevokeToken(c *gin.Context) {
+	err := h.scim.RevokeToken(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.SCIMTokenRevoked)
+	case errors.Is(err, services.ErrInvalidPropertyID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPropertyID)
+	case errors.Is(err, services.ErrPropertyNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.PropertyNotFound)
+	default:
+		serverError(c, err, i18n.SCIMFailed)
+	}
+}
+
+type scimUserRequest struct {
+	UserName string               `json:"userName" normalize:"email"`
+	Emails   []services.SCIMValue `json:"emails"`
+	Roles    []services.SCIMValue `json:"roles"`
+	Active   *bool                `json:"active"`
+}
+
+// CreateUser provisions a member of the staff of the property.
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var payload scimUserRequest
+	if err := bindJSON(c, &payload); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", i18n.InvalidPayload)
+		return
+	}
+
+	user, err := h.scim.Create(c.Request.Context(), scimProperty(c), services.SCIMUserInput{
+		UserName: payload.UserName,
+		Emails:   payload.Emails,
+		Roles:    payload.Roles,
+		Active:   payload.Active,
+	})
+	switch {
+	case err == nil:
+		resource := user.ToSCIM()
+		c.Header("Location", resource.Meta.Location)
+		scimRender(c, http.StatusCreated, resource)
+	case errors.Is(err, services.ErrInvalidSCIMUser):
+		scimError(c, http.StatusBadRequest, "invalidValue", i18n.InvalidSCIMUser)
+	case errors.Is(err, services.ErrUserAlreadyExists):
+		scimError(c, http.StatusConflict, "uniqueness", i18n.UserAlreadyExists)
+	default:
+		scimFailure(c, err)
+	}
+}
+
+// ListUsers returns a page of the staff of the property, narrowed by the
+// filter query parameter.
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, errStart := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	count, errCount := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimDefaultCount)))
+	if errStart != nil || errCount != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", i18n.InvalidPagination)
+		return
+	}
+
+	users, total, err := h.scim.List(c.Request.Context(), scimProperty(c), c.Query("filter"), startIndex, count)
+	switch {
+	case err == nil:
+		resources := make([]services.SCIMUser, 0, len(users))
+		for _, u := range users {
+			resources = append(resources, u.ToSCIM())
+		}
+		scimRender(c, http.StatusOK, gin.H{
+			"schemas":      []string{services.SCIMListSchema},
+			"totalResults": total,
+			"startIndex":   max(startIndex, 1),
+			"itemsPerPage": len(resources),
+			"Resources":    resources,
+		})
+	case errors.Is(err, services.ErrInvalidSCIMFilter):
+		scimError(c, http.StatusBadRequest, "invalidFilter", i18n.InvalidSCIMFilter)
+	default:
+		scimFailure(c, err)
+	}
+}
+
+// GetUser returns a member of the staff of the property.
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, err := h.scim.Get(c.Request.Context(), scimProperty(c), c.Param("id"))
+	if err != nil {
+		scimFailure(c, err)
+		return
+	}
+	scimRender(c, http.StatusOK, user.ToSCIM())
+}
+
+type scimPatchRequest struct {
+	Operations []services.SCIMPatchOperation `json:"Operations"`
+}
+
+// PatchUser activates, deactivates or changes the role of a member of the
+// staff of the property.
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var payload scimPatchRequest
+	if err := bindJSON(c, &payload); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", i18n.InvalidPayload)
+		return
+	}
+
+	user, err := h.scim.Patch(c.Request.Context(), scimProperty(c), c.Param("id"), payload.Operations)
+	switch {
+	case err == nil:
+		scimRender(c, http.StatusOK, user.ToSCIM())
+	case errors.Is(err, services.ErrInvalidSCIMPatch):
+		scimError(c, http.StatusBadRequest, "invalidPath", i18n.InvalidSCIMPatch)
+	default:
+		scimFailure(c, err)
+	}
+}
+
+// DeleteUser deactivates a member of the staff of the property; the
+// account is kept, suspended.
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	if _, err := h.scim.Deactivate(c.Request.Context(), scimProperty(c), c.Param("id")); err != nil {
+		scimFailure(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
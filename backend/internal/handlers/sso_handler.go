@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// SSOHandler exposes the single sign-on of the property staff through the
+// OpenID Connect provider of each property.
+type SSOHandler struct {
+	sso      *services.SSOService
+	sessions *services.SessionService
+}
+
+// NewSSOHandler constructs an SSOHandler instance.
+func NewSSOHandler(sso *services.SSOService, sessions *services.SessionService) *SSOHandler {
+	return &SSOHandler{sso: sso, sessions: sessions}
+}
+
+// ssoError answers the errors shared by the SSO endpoints.
+func ssoError(c *gin.Context, err error, code i18n.Code) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPropertyID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPropertyID)
+	case errors.Is(err, services.ErrPropertyNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.PropertyNotFound)
+	case errors.Is(err, services.ErrSSONotConfigured):
+		i18n.Error(c, http.StatusNotFound, i18n.SSONotConfigured)
+	case errors.Is(err, services.ErrSSOUnavailable):
+		c.Header("Retry-After", retryAfterSeconds)
+		i18n.Error(c, http.StatusServiceUnavailable, i18n.SSOUnavailable)
+	default:
+		serverError(c, err, code)
+	}
+}
+
+type ssoConfigRequest struct {
+	Issuer       string `json:"issuer" normalize:"text"`
+	ClientID     string `json:"clientId" normalize:"text"`
+	ClientSecret string `json:"clientSecret"`
+	DefaultRole  string `json:"defaultRole" normalize:"keyword"`
+}
+
+// ConfigureSSO sets the identity provider of a property.
+func (h *SSOHandler) ConfigureSSO(c *gin.Context) {
+	var payload ssoConfigRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	sso, err := h.sso.Configure(c.Request.Context(), c.Param("id"), services.PropertySSO{
+		Issuer:       payload.Issuer,
+		ClientID:     payload.ClientID,
+		ClientSecret: payload.ClientSecret,
+		DefaultRole:  payload.DefaultRole,
+	})
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"sso": sso})
+	case errors.Is(err, services.ErrInvalidSSOConfig):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidSSOConfig)
+	default:
+		ssoError(c, err, i18n.SSOFailed)
+	}
+}
+
+// DisableSSO removes the identity provider of a property.
+func (h *SSOHandler) DisableSSO(c *gin.Context) {
+	if err := h.sso.Disable(c.Request.Context(), c.Param("id")); err != nil {
+		ssoError(c, err, i18n.SSOFailed)
+		return
+	}
+	i18n.Message(c, http.StatusOK, i18n.SSODisabled)
+}
+
+type ssoOptionsRequest struct {
+	PropertyID string `json:"propertyId"`
+}
+
+// LoginOptions starts a login at the identity provider of a property and
+// returns the URL to send the browser to.
+func (h *SSOHandler) LoginOptions(c *gin.Context) {
+	var payload ssoOptionsRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	url, err := h.sso.Begin(c.Request.Context(), payload.PropertyID)
+	if err != nil {
+		ssoError(c, err, i18n.LoginFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"authorizationUrl": url})
+}
+
+// LinkOptions starts a login at the identity provider of a property that,
+// once completed through Login, makes the signed-in account, which has no
+// property yet, staff of it.
+func (h *SSOHandler) LinkOptions(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload ssoOptionsRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	url, err := h.sso.BeginLink(c.Request.Context(), payload.PropertyID, principal.Email)
+	if err != nil {
+		ssoError(c, err, i18n.SSOFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"authorizationUrl": url})
+}
+
+type ssoLoginRequest struct {
+	State string `json:"state"`
+	Code  string `json:"code"`
+}
+
+// Login completes the login with the state and code the provider sent the
+// browser back with, and answers like the password login.
+func (h *SSOHandler) Login(c *gin.Context) {
+	var payload ssoLoginRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	user, err := h.sso.Finish(c.Request.Context(), payload.State, payload.Code)
+	switch {
+	case err == nil:
+		startSession(c, h.sessions, user)
+	case errors.Is(err, services.ErrInvalidSSOState):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidSSOState)
+	case errors.Is(err, services.ErrSSORejected):
+		i18n.Error(c, http.StatusUnauthorized, i18n.SSORejected)
+	case errors.Is(err, services.ErrAccountSuspended):
+		i18n.Error(c, http.StatusForbidden, i18n.AccountSuspended)
+	default:
+		ssoError(c, err, i18n.LoginFailed)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// StatsHandler exposes the productivity statistics of the signed-in user.
+type StatsHandler struct {
+	stats *services.TodoStatsService
+}
+
+// NewStatsHandler builds a new StatsHandler instance.
+func NewStatsHandler(stats *services.TodoStatsService) *StatsHandler {
+	return &StatsHandler{stats: stats}
+}
+
+// Breakdown returns the completion counts and median completion times of
+// the todos of the caller per tag, list and weekday.
+func (h *StatsHandler) Breakdown(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	breakdown, err := h.stats.Breakdown(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.StatsFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, breakdown)
+}
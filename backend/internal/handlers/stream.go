@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// MIMENDJSON is the media type of newline-delimited JSON, one document per
+// line.
+const MIMENDJSON = "application/x-ndjson"
+
+const (
+	// streamBufferSize is how much of a stream is held before it is
+	// written to the client; streamFlushEvery flushes it after that many
+	// items anyway, so the client gets chunks while the listing goes on.
+	streamBufferSize = 32 << 10
+	streamFlushEvery = 500
+)
+
+// jsonStream writes a listing item by item, as NDJSON or as a JSON array,
+// in chunks. Writing blocks while the client is not reading, which holds
+// back whatever produces the items (e.g. a Mongo cursor) instead of piling
+// the listing up in memory.
+type jsonStream struct {
+	c       *gin.Context
+	w       *bufio.Writer
+	array   bool
+	items   int
+	flushed bool
+}
+
+// newJSONStream starts a stream answered as an attachment called filename;
+// array writes a JSON array instead of NDJSON. The 200 status goes out
+// with the first chunk, so an error before it can still be answered.
+func newJSONStream(c *gin.Context, array bool, filename string) *jsonStream {
+	contentType := MIMENDJSON
+	if array {
+		contentType = binding.MIMEJSON
+	}
+	c.Header("Vary", "Accept")
+	c.Header("Content-Type", contentType+"; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	return &jsonStream{c: c, w: bufio.NewWriterSize(c.Writer, streamBufferSize), array: array}
+}
+
+// wantsJSONArray tells whether a streamed listing was asked for as a JSON
+// array, through ?format=json or the Accept header, rather than NDJSON.
+func wantsJSONArray(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return format == "json"
+	}
+	return c.NegotiateFormat(MIMENDJSON, binding.MIMEJSON) == binding.MIMEJSON
+}
+
+// Write adds item to the stream. It stops with the error of the request
+// context once the client is gone.
+func (s *jsonStream) Write(item any) error {
+	if err := s.c.Request.Context().Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !s.array:
+		data = append(data, '\n')
+	case s.items == 0:
+		err = s.w.WriteByte('[')
+	default:
+		err = s.w.WriteByte(',')
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	s.items++
+	if s.items%streamFlushEvery == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+// Close writes what is left of the stream.
+func (s *jsonStream) Close() error {
+	if s.array {
+		end := "]"
+		if s.items == 0 {
+			end = "[]"
+		}
+		if _, err := s.w.WriteString(end); err != nil {
+			return err
+		}
+	}
+	return s.flush()
+}
+
+func (s *jsonStream) flush() error {
+	s.flushed = s.flushed || s.w.Buffered() > 0
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	s.c.Writer.Flush()
+	return nil
+}
+
+// Discard drops the stream so the handler can answer an error instead. It
+// reports false once part of the stream reached the client: the status
+// can no longer change, and the handler should abort the connection so
+// the client sees a truncated listing.
+func (s *jsonStream) Discard() bool {
+	if s.flushed || s.c.Writer.Written() {
+		return false
+	}
+	s.w.Reset(s.c.Writer)
+	s.c.Header("Vary", "")
+	s.c.Header("Content-Type", "")
+	s.c.Header("Content-Disposition", "")
+	return true
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// SyncHandler exposes the replay of the ops offline clients queued.
+type SyncHandler struct {
+	sync *services.TodoSyncService
+}
+
+// NewSyncHandler builds a new SyncHandler instance.
+func NewSyncHandler(sync *services.TodoSyncService) *SyncHandler {
+	return &SyncHandler{sync: sync}
+}
+
+type syncOpsRequest struct {
+	Ops []services.SyncOpInput `json:"ops"`
+}
+
+// SyncOps replays, in order, the ops the signed-in user queued offline
+// and reports the outcome of each one.
+func (h *SyncHandler) SyncOps(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload syncOpsRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	report, err := h.sync.Replay(c.Request.Context(), principal.Email, payload.Ops)
+	switch {
+	case err == nil:
+		metrics.TodosCreated.Add(int64(report.Created))
+		respond.Render(c, http.StatusOK, report)
+	case errors.Is(err, services.ErrInvalidSyncBatch):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidSyncBatch)
+	default:
+		serverError(c, err, i18n.SyncOpsFailed)
+	}
+}
@@ -3,115 +3,444 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/collab"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
 	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
 )
 
 // TodoHandler exposes HTTP handlers for todo operations.
 type TodoHandler struct {
-	todos *services.TodoService
+	todos  *services.TodoService
+	quotas *services.QuotaService
+	lists  *services.ListService
 }
 
-// NewTodoHandler builds a new TodoHandler instance.
-func NewTodoHandler(todos *services.TodoService) *TodoHandler {
-	return &TodoHandler{todos: todos}
+// NewTodoHandler builds a new TodoHandler instance; quotas enforces the
+// todo limit of the owners and lists the policy of the shared lists.
+func NewTodoHandler(todos *services.TodoService, quotas *services.QuotaService, lists *services.ListService) *TodoHandler {
+	return &TodoHandler{todos: todos, quotas: quotas, lists: lists}
 }
 
-// ListTodos retrieves todos filtered by email if provided.
+// TodoIDParam works like middleware.ObjectIDParam, but also takes the
+// clientId of one of the user's todos in place of its ObjectID.
+func (h *TodoHandler) TodoIDParam(name string) gin.HandlerFunc {
+	byObjectID := middleware.ObjectIDParam(name)
+	return func(c *gin.Context) {
+		if !services.IsUUID(c.Param(name)) {
+			byObjectID(c)
+			return
+		}
+		principal, _ := middleware.CurrentPrincipal(c)
+		todo, err := h.todos.FindByClientID(c.Request.Context(), principal.Email, c.Param(name))
+		switch {
+		case errors.Is(err, services.ErrNotFound):
+			i18n.AbortError(c, http.StatusNotFound, i18n.TodoNotFound)
+			return
+		case err != nil:
+			serverError(c, err, i18n.ListTodosFailed)
+			c.Abort()
+			return
+		}
+		middleware.SetObjectID(c, name, todo.ID)
+		c.Next()
+	}
+}
+
+// ListTodos retrieves the todos of ?email=, or of the signed-in user
+// without it, paginated when ?limit= is present; ?trashed=true lists the
+// trash instead. Housekeeping staff only see the todos of rooms.
 func (h *TodoHandler) ListTodos(c *gin.Context) {
-	email := c.Query("email")
-	todos, err := h.todos.List(c.Request.Context(), email)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al obtener tareas"})
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"todos": todos})
+	principal, signedIn := middleware.CurrentPrincipal(c)
+	if !signedIn && queryEmail(c, "email") != "" {
+		middleware.Deprecated(c, LegacyTodoEmail)
+	}
+	// Without an email a session lists its own todos; listing every todo
+	// takes the admin token with ?all=true.
+	admin := services.ActorFrom(c.Request.Context()) == services.AuditAdmin
+	email, clientID := queryEmail(c, "email"), strings.ToLower(c.Query("clientId"))
+	all := admin && c.Query("all") == "true"
+	if email == "" && !all {
+		email = principal.Email
+	}
+	result, err := h.todos.List(c.Request.Context(), services.TodoQuery{
+		Email:           email,
+		ClientID:        clientID,
+		RoomsOnly:       principal.Role == services.RoleHousekeeping,
+		Trashed:         c.Query("trashed") == "true",
+		Color:           c.Query("color"),
+		Icon:            c.Query("icon"),
+		Offset:          page.Offset,
+		Limit:           page.Limit,
+		All:             all,
+		IncludeArchived: c.Query("includeArchived") == "true",
+	})
+	switch {
+	case err == nil:
+		renderTodoPage(c, page, result)
+	case errors.Is(err, services.ErrUnboundedQuery):
+		i18n.Error(c, http.StatusBadRequest, i18n.TodoFilterRequired)
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	case errors.Is(err, services.ErrInvalidTodoLabel):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidTodoLabel)
+	default:
+		serverError(c, err, i18n.ListTodosFailed)
+	}
+}
+
+// ListRoomTodos retrieves the todos linked to a room (e.g. cleaning tasks).
+func (h *TodoHandler) ListRoomTodos(c *gin.Context) {
+	page, ok := parsePagination(c)
+	if !ok {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+		return
+	}
+
+	result, err := h.todos.ListForRoom(c.Request.Context(), c.Param("id"), services.TodoQuery{
+		Offset: page.Offset,
+		Limit:  page.Limit,
+	})
+	switch {
+	case err == nil:
+		renderTodoPage(c, page, result)
+	case errors.Is(err, services.ErrInvalidRoomID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrInvalidPagination):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPagination)
+	default:
+		serverError(c, err, i18n.ListTodosFailed)
+	}
 }
 
 type createTodoRequest struct {
-	Email string `json:"email"`
-	Title string `json:"title"`
+	Email      string `json:"email" normalize:"email"`
+	ClientID   string `json:"clientId" normalize:"keyword"`
+	Title      string `json:"title" normalize:"text"`
+	Recurrence string `json:"recurrence" normalize:"keyword"`
+	Color      string `json:"color" normalize:"keyword"`
+	Icon       string `json:"icon" normalize:"keyword"`
+	ListID     string `json:"listId"`
 }
 
-// CreateTodo stores a new todo.
+// CreateTodo stores a new todo. With a listId it goes to that shared list,
+// which needs a session with a role allowed to write in it; the signed-in
+// user owns the todo.
 func (h *TodoHandler) CreateTodo(c *gin.Context) {
 	var payload createTodoRequest
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "datos invalidos"})
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
 		return
 	}
+	if _, signedIn := middleware.CurrentPrincipal(c); !signedIn && payload.Email != "" {
+		middleware.Deprecated(c, LegacyTodoEmail)
+	}
+	var listID *primitive.ObjectID
+	if payload.ListID != "" {
+		id, err := primitive.ObjectIDFromHex(payload.ListID)
+		if err != nil {
+			i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+			return
+		}
+		if !authorizeList(c, h.lists, id, policy.Write) {
+			return
+		}
+		principal, _ := middleware.CurrentPrincipal(c)
+		payload.Email, listID = principal.Email, &id
+	}
 
-	todo, err := h.todos.Create(c.Request.Context(), payload.Email, payload.Title)
+	err := h.quotas.AllowTodo(c.Request.Context(), payload.Email)
+	var todo services.TodoResponse
+	if err == nil {
+		todo, err = h.todos.CreateForClient(c.Request.Context(), payload.Email, payload.ClientID, payload.Title, payload.Recurrence,
+			services.TodoLabel{Color: payload.Color, Icon: payload.Icon}, listID)
+	}
 	switch {
 	case err == nil:
-		c.JSON(http.StatusCreated, gin.H{"todo": todo})
+		metrics.TodosCreated.Inc()
+		c.Header("Location", "/todos/"+todo.ID)
+		renderTodo(c, http.StatusCreated, todo)
 	case errors.Is(err, services.ErrInvalidTodoInput):
-		c.JSON(http.StatusBadRequest, gin.H{"error": "email y titulo son requeridos"})
+		i18n.Error(c, http.StatusBadRequest, i18n.EmailTitleRequired)
+	case errors.Is(err, services.ErrInvalidRecurrence):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidRecurrence)
+	case errors.Is(err, services.ErrInvalidTodoLabel):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidTodoLabel)
+	case errors.Is(err, services.ErrInvalidClientID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidClientID)
+	case errors.Is(err, services.ErrDuplicateClientID):
+		i18n.Error(c, http.StatusConflict, i18n.DuplicateClientID)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		i18n.Error(c, http.StatusForbidden, i18n.QuotaExceeded)
 	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al crear tarea"})
+		serverError(c, err, i18n.CreateTodoFailed)
 	}
 }
 
+// GetTodo returns a todo with its weak ETag, answering 304 Not Modified
+// when If-None-Match names the current one. The tag only validates cached
+// copies: PUT /todos/:id does not take If-Match.
+func (h *TodoHandler) GetTodo(c *gin.Context) {
+	todo, err := h.todos.Get(c.Request.Context(), middleware.GetObjectID(c, "id"))
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+		return
+	case err != nil:
+		serverError(c, err, i18n.ListTodosFailed)
+		return
+	}
+	etag := services.TodoETag(todo)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if match := c.GetHeader("If-None-Match"); match != "" && services.MatchesETag(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	renderTodo(c, http.StatusOK, todo)
+}
+
 type updateTodoRequest struct {
-	Title     *string `json:"title"`
+	Title     *string `json:"title" normalize:"text"`
 	Completed *bool   `json:"completed"`
+	Color     *string `json:"color" normalize:"keyword"`
+	Icon      *string `json:"icon" normalize:"keyword"`
+	Assignee  *string `json:"assignee" normalize:"email"`
 }
 
 // UpdateTodo modifies an existing todo.
 func (h *TodoHandler) UpdateTodo(c *gin.Context) {
-	id := c.Param("id")
-
 	var payload updateTodoRequest
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "datos invalidos"})
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
 		return
 	}
 
-	todo, err := h.todos.Update(c.Request.Context(), id, services.TodoUpdate{
+	todo, err := h.todos.Update(c.Request.Context(), middleware.GetObjectID(c, "id"), services.TodoUpdate{
 		Title:     payload.Title,
 		Completed: payload.Completed,
+		Color:     payload.Color,
+		Icon:      payload.Icon,
+		Assignee:  payload.Assignee,
 	})
 	switch {
 	case err == nil:
-		c.JSON(http.StatusOK, gin.H{"todo": todo})
+		if todo.Completed && payload.Completed != nil {
+			metrics.TodosCompleted.Inc()
+		}
+		renderTodo(c, http.StatusOK, todo)
 	case errors.Is(err, services.ErrInvalidTodoInput):
-		c.JSON(http.StatusBadRequest, gin.H{"error": "nada para actualizar"})
-	case errors.Is(err, services.ErrInvalidTodoID):
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id invalido"})
+		i18n.Error(c, http.StatusBadRequest, i18n.NothingToUpdate)
+	case errors.Is(err, services.ErrInvalidTodoLabel):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidTodoLabel)
 	case errors.Is(err, services.ErrNotFound):
-		c.JSON(http.StatusNotFound, gin.H{"error": "tarea no encontrada"})
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
 	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al actualizar tarea"})
+		serverError(c, err, i18n.UpdateTodoFailed)
 	}
 }
 
-// DeleteTodo removes a todo by ID.
+// DeleteTodo moves a todo to the trash.
 func (h *TodoHandler) DeleteTodo(c *gin.Context) {
-	id := c.Param("id")
+	err := h.todos.Delete(c.Request.Context(), middleware.GetObjectID(c, "id"))
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.TodoDeleted)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.DeleteTodoFailed)
+	}
+}
 
-	err := h.todos.Delete(c.Request.Context(), id)
+// RestoreTodo takes a todo out of the trash.
+func (h *TodoHandler) RestoreTodo(c *gin.Context) {
+	todo, err := h.todos.Restore(c.Request.Context(), middleware.GetObjectID(c, "id"))
 	switch {
 	case err == nil:
-		c.JSON(http.StatusOK, gin.H{"message": "tarea eliminada"})
-	case errors.Is(err, services.ErrInvalidTodoID):
-		c.JSON(http.StatusBadRequest, gin.H{"error": "id invalido"})
+		renderTodo(c, http.StatusOK, todo)
 	case errors.Is(err, services.ErrNotFound):
-		c.JSON(http.StatusNotFound, gin.H{"error": "tarea no encontrada"})
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
 	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al eliminar tarea"})
+		serverError(c, err, i18n.RestoreTodoFailed)
 	}
 }
 
-// ClearTodos removes todos optionally filtered by email.
-func (h *TodoHandler) ClearTodos(c *gin.Context) {
-	email := c.Query("email")
-	if err := h.todos.Clear(c.Request.Context(), email); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error al limpiar tareas"})
+type toggleAllRequest struct {
+	Completed *bool `json:"completed"`
+}
+
+// ToggleAll completes or reopens every todo of the signed-in user. The
+// target state is explicit, so repeating the request or racing another
+// tab converges instead of flipping the todos back.
+func (h *TodoHandler) ToggleAll(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload toggleAllRequest
+	if err := bindJSON(c, &payload); err != nil || payload.Completed == nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	updated, err := h.todos.CompleteAll(c.Request.Context(), principal.Email, *payload.Completed)
+	if err != nil {
+		serverError(c, err, i18n.ToggleTodosFailed)
+		return
+	}
+	if *payload.Completed {
+		metrics.TodosCompleted.Add(updated)
+	}
+	respond.Render(c, http.StatusOK, gin.H{"completed": *payload.Completed, "updated": updated})
+}
+
+// ClearCompleted moves the completed todos of the signed-in user to the
+// trash.
+func (h *TodoHandler) ClearCompleted(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	deleted, err := h.todos.ClearCompleted(c.Request.Context(), principal.Email)
+	if err != nil {
+		serverError(c, err, i18n.ClearCompletedFailed)
+		return
+	}
+	respond.Render(c, http.StatusOK, gin.H{"deleted": deleted})
+}
+
+type reactionRequest struct {
+	Emoji string `json:"emoji" normalize:"text"`
+}
+
+// ReactTodo adds the emoji reaction of the signed-in user to a todo.
+func (h *TodoHandler) ReactTodo(c *gin.Context) {
+	var payload reactionRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
 		return
 	}
+	h.react(c, payload.Emoji, true)
+}
+
+// UnreactTodo removes the ?emoji= reaction of the signed-in user from a
+// todo.
+func (h *TodoHandler) UnreactTodo(c *gin.Context) {
+	h.react(c, c.Query("emoji"), false)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "tareas eliminadas"})
+func (h *TodoHandler) react(c *gin.Context, emoji string, add bool) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	todo, err := h.todos.React(c.Request.Context(), middleware.GetObjectID(c, "id"), principal.Email, emoji, add)
+	switch {
+	case err == nil:
+		renderTodo(c, http.StatusOK, todo)
+	case errors.Is(err, services.ErrInvalidReaction):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidReaction)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.ReactionFailed)
+	}
+}
+
+// GetTitle returns the title of a todo with its version, which the
+// collaborative edits are written against.
+func (h *TodoHandler) GetTitle(c *gin.Context) {
+	state, err := h.todos.Title(c.Request.Context(), middleware.GetObjectID(c, "id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, state)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	default:
+		serverError(c, err, i18n.TitleEditFailed)
+	}
+}
+
+// titleEditRequest is either an op written against BaseVersion or, in the
+// last-writer-wins mode, the whole new Title.
+type titleEditRequest struct {
+	BaseVersion *int      `json:"baseVersion"`
+	Op          collab.Op `json:"op"`
+	Title       *string   `json:"title" normalize:"text"`
+}
+
+// EditTitle applies a collaborative edit of the signed-in user to the
+// title of a todo and returns the merged title.
+func (h *TodoHandler) EditTitle(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+	var payload titleEditRequest
+	if err := bindJSON(c, &payload); err != nil || (payload.Title == nil) == (payload.BaseVersion == nil) {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	id := middleware.GetObjectID(c, "id")
+	var state services.TitleState
+	var err error
+	if payload.Title != nil {
+		state, err = h.todos.ReplaceTitle(c.Request.Context(), id, principal.Email, *payload.Title)
+	} else {
+		state, err = h.todos.EditTitle(c.Request.Context(), id, principal.Email, *payload.BaseVersion, payload.Op)
+	}
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, state)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.TodoNotFound)
+	case errors.Is(err, services.ErrInvalidTitleEdit):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidTitleEdit)
+	case errors.Is(err, services.ErrTitleHistoryGone):
+		i18n.Error(c, http.StatusConflict, i18n.TitleHistoryGone)
+	case errors.Is(err, services.ErrTitleVersionConflict):
+		i18n.Error(c, http.StatusConflict, i18n.TitleEditConflict)
+	default:
+		serverError(c, err, i18n.TitleEditFailed)
+	}
+}
+
+// ClearTodos removes the todos of ?email=, or every todo with ?all=true.
+func (h *TodoHandler) ClearTodos(c *gin.Context) {
+	all := c.Query("all") == "true"
+	if all && services.ActorFrom(c.Request.Context()) != services.AuditAdmin {
+		i18n.Error(c, http.StatusForbidden, i18n.ClearAllForbidden)
+		return
+	}
+	err := h.todos.Clear(c.Request.Context(), queryEmail(c, "email"), all)
+	switch {
+	case err == nil:
+		i18n.Message(c, http.StatusOK, i18n.TodosCleared)
+	case errors.Is(err, services.ErrUnboundedQuery):
+		i18n.Error(c, http.StatusBadRequest, i18n.TodoFilterRequired)
+	default:
+		serverError(c, err, i18n.ClearTodosFailed)
+	}
 }
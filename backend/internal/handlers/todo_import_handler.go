@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// TodoImportHandler exposes the import of todos from other apps.
+type TodoImportHandler struct {
+	imports *services.TodoImportService
+}
+
+// NewTodoImportHandler builds a new TodoImportHandler instance.
+func NewTodoImportHandler(imports *services.TodoImportService) *TodoImportHandler {
+	return &TodoImportHandler{imports: imports}
+}
+
+// ImportTodos imports the export file sent as the body for the signed-in
+// user. ?source= is todoist or google, ?name= the file name and
+// ?dryRun=true only reports what would be imported.
+func (h *TodoImportHandler) ImportTodos(c *gin.Context) {
+	principal, ok := middleware.CurrentPrincipal(c)
+	if !ok {
+		i18n.Error(c, http.StatusUnauthorized, i18n.LoginRequired)
+		return
+	}
+
+	dryRun := c.Query("dryRun") == "true"
+	report, err := h.imports.Import(c.Request.Context(), principal.Email, c.Query("source"), c.Query("name"), c.Request.Body, dryRun)
+	switch {
+	case err == nil && dryRun:
+		respond.Render(c, http.StatusOK, gin.H{"import": report})
+	case err == nil:
+		metrics.TodosCreated.Add(int64(report.Imported))
+		respond.Render(c, http.StatusCreated, gin.H{"import": report})
+	case errors.Is(err, services.ErrUnknownImportSource):
+		i18n.Error(c, http.StatusBadRequest, i18n.UnknownImportSource)
+	case errors.Is(err, services.ErrInvalidTodoImport):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidTodoImport)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		i18n.Error(c, http.StatusForbidden, i18n.QuotaExceeded)
+	default:
+		serverError(c, err, i18n.ImportTodosFailed)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// WaitlistHandler exposes HTTP handlers for the waitlist of fully booked
+// stays.
+type WaitlistHandler struct {
+	waitlist *services.WaitlistService
+}
+
+// NewWaitlistHandler builds a new WaitlistHandler instance.
+func NewWaitlistHandler(waitlist *services.WaitlistService) *WaitlistHandler {
+	return &WaitlistHandler{waitlist: waitlist}
+}
+
+// ListWaitlist returns the entries optionally filtered by ?status=.
+func (h *WaitlistHandler) ListWaitlist(c *gin.Context) {
+	entries, err := h.waitlist.List(c.Request.Context(), c.Query("status"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"entries": entries})
+	case errors.Is(err, services.ErrInvalidWaitlistInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidWaitlistInput)
+	default:
+		serverError(c, err, i18n.ListWaitlistFailed)
+	}
+}
+
+type joinWaitlistRequest struct {
+	Email    string `json:"email" normalize:"email"`
+	Guests   int    `json:"guests"`
+	RoomType string `json:"roomType" normalize:"keyword"`
+	CheckIn  string `json:"checkIn" normalize:"date"`
+	CheckOut string `json:"checkOut" normalize:"date"`
+}
+
+// JoinWaitlist queues a guest for a stay without free rooms.
+func (h *WaitlistHandler) JoinWaitlist(c *gin.Context) {
+	var payload joinWaitlistRequest
+	if err := bindJSON(c, &payload); err != nil {
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidPayload)
+		return
+	}
+
+	entry, err := h.waitlist.Join(c.Request.Context(), services.WaitlistInput{
+		Email:    payload.Email,
+		Guests:   payload.Guests,
+		RoomType: payload.RoomType,
+		CheckIn:  payload.CheckIn,
+		CheckOut: payload.CheckOut,
+	})
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusCreated, gin.H{"entry": entry})
+	case errors.Is(err, services.ErrInvalidWaitlistInput):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidWaitlistInput)
+	case errors.Is(err, services.ErrInvalidStayDates):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidStayDates)
+	case errors.Is(err, services.ErrRoomsAvailable):
+		i18n.Error(c, http.StatusConflict, i18n.RoomsAvailable)
+	default:
+		serverError(c, err, i18n.JoinWaitlistFailed)
+	}
+}
+
+// ConfirmWaitlist books the room held for an offered entry.
+func (h *WaitlistHandler) ConfirmWaitlist(c *gin.Context) {
+	booking, err := h.waitlist.Confirm(c.Request.Context(), c.Param("id"))
+	switch {
+	case err == nil:
+		respond.Render(c, http.StatusOK, gin.H{"booking": booking})
+	case errors.Is(err, services.ErrInvalidWaitlistID):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidID)
+	case errors.Is(err, services.ErrNotFound):
+		i18n.Error(c, http.StatusNotFound, i18n.WaitlistNotFound)
+	case errors.Is(err, services.ErrWaitlistStateConflict), errors.Is(err, services.ErrBookingStateConflict):
+		i18n.Error(c, http.StatusConflict, i18n.WaitlistNotOffered)
+	case errors.Is(err, services.ErrWaitlistHoldExpired):
+		i18n.Error(c, http.StatusConflict, i18n.WaitlistHoldExpired)
+	default:
+		serverError(c, err, i18n.ConfirmWaitlistFailed)
+	}
+}
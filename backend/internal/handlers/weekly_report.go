@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/pdf"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// Colors of the weekly report.
+var (
+	reportBlue  = pdf.Color{R: 0.26, G: 0.45, B: 0.77}
+	reportGreen = pdf.Color{R: 0.30, G: 0.65, B: 0.38}
+	reportAmber = pdf.Color{R: 0.93, G: 0.62, B: 0.16}
+	reportRed   = pdf.Color{R: 0.80, G: 0.22, B: 0.20}
+	reportLight = pdf.Color{R: 0.94, G: 0.95, B: 0.97}
+)
+
+// reportMargin is the left and right margin of the weekly report.
+const reportMargin = 50.0
+
+// WeeklyPDF renders the summary of the todos of ?from=&to= (by default the
+// last seven days) as a PDF; scoped requests only see their property.
+func (h *ReportHandler) WeeklyPDF(c *gin.Context) {
+	summary, err := h.summaries.Summary(c.Request.Context(), services.ReportQuery{From: c.Query("from"), To: c.Query("to")})
+	switch {
+	case errors.Is(err, services.ErrInvalidReportQuery):
+		i18n.Error(c, http.StatusBadRequest, i18n.InvalidSummaryRange)
+		return
+	case err != nil:
+		serverError(c, err, i18n.ReportFailed)
+		return
+	}
+
+	title := i18n.T(c, i18n.WeeklyReportTitle)
+	doc := pdf.New(title)
+	doc.Text(reportMargin, 70, 20, true, pdf.Black, title)
+	doc.Text(reportMargin, 90, 11, false, pdf.Gray, summary.From+" - "+summary.To)
+
+	// Totals.
+	width := (pdf.PageWidth - 2*reportMargin - 3*10) / 4
+	for i, stat := range []struct {
+		code  i18n.Code
+		value int
+		color pdf.Color
+	}{
+		{i18n.ReportCreated, summary.Created, reportBlue},
+		{i18n.ReportCompleted, summary.Completed, reportGreen},
+		{i18n.ReportPending, summary.Pending, reportAmber},
+		{i18n.ReportOverdue, summary.Overdue, reportRed},
+	} {
+		x := reportMargin + float64(i)*(width+10)
+		doc.Rect(x, 110, width, 60, reportLight)
+		doc.Rect(x, 110, 4, 60, stat.color)
+		doc.Text(x+14, 142, 22, true, stat.color, strconv.Itoa(stat.value))
+		doc.Text(x+14, 160, 10, false, pdf.Gray, i18n.T(c, stat.code))
+	}
+
+	drawDailyChart(c, doc, summary.Days, 200)
+	drawOwnerTable(c, doc, summary.Owners, 480)
+
+	c.Header("Content-Disposition", `attachment; filename="weekly-`+summary.From+`.pdf"`)
+	c.Header("Content-Type", pdf.MIMEType)
+	c.Status(http.StatusOK)
+	_, _ = doc.WriteTo(c.Writer)
+}
+
+// drawDailyChart draws the todos created and completed per day as bars,
+// below top.
+func drawDailyChart(c *gin.Context, doc *pdf.Document, days []services.SummaryDay, top float64) {
+	const height = 200.0
+	doc.Text(reportMargin, top, 13, true, pdf.Black, i18n.T(c, i18n.ReportPerDay))
+	doc.Rect(reportMargin+200, top-9, 9, 9, reportBlue)
+	doc.Text(reportMargin+213, top, 9, false, pdf.Gray, i18n.T(c, i18n.ReportCreated))
+	doc.Rect(reportMargin+290, top-9, 9, 9, reportGreen)
+	doc.Text(reportMargin+303, top, 9, false, pdf.Gray, i18n.T(c, i18n.ReportCompleted))
+
+	peak := 1
+	for _, day := range days {
+		peak = max(peak, day.Created, day.Completed)
+	}
+	chartTop := top + 20
+	base := chartTop + height
+	width := pdf.PageWidth - 2*reportMargin - 20
+	doc.Text(reportMargin, chartTop+8, 8, false, pdf.Gray, strconv.Itoa(peak))
+	doc.Text(reportMargin, base, 8, false, pdf.Gray, "0")
+	doc.Line(reportMargin+20, base, reportMargin+20+width, base, 0.8, pdf.Gray)
+	doc.Line(reportMargin+20, chartTop, reportMargin+20+width, chartTop, 0.3, reportLight)
+
+	slot := width / float64(max(len(days), 1))
+	bar := min(slot*0.35, 24)
+	// Label about ten days at most so the dates do not overlap.
+	every := max(1, (len(days)+9)/10)
+	for i, day := range days {
+		x := reportMargin + 20 + float64(i)*slot + (slot-2*bar)/2
+		for j, bars := range []struct {
+			value int
+			color pdf.Color
+		}{{day.Created, reportBlue}, {day.Completed, reportGreen}} {
+			if bars.value > 0 {
+				barHeight := height * float64(bars.value) / float64(peak)
+				doc.Rect(x+float64(j)*bar, base-barHeight, bar, barHeight, bars.color)
+			}
+		}
+		if i%every == 0 {
+			doc.Text(x, base+12, 8, false, pdf.Gray, day.Date[5:])
+		}
+	}
+}
+
+// drawOwnerTable lists the todos per owner below top.
+func drawOwnerTable(c *gin.Context, doc *pdf.Document, owners []services.SummaryOwner, top float64) {
+	columns := []float64{reportMargin, reportMargin + 250, reportMargin + 335, reportMargin + 420}
+	headers := []i18n.Code{i18n.ReportOwner, i18n.ReportCompleted, i18n.ReportPending, i18n.ReportOverdue}
+	doc.Rect(reportMargin, top, pdf.PageWidth-2*reportMargin, 20, reportLight)
+	for i, code := range headers {
+		doc.Text(columns[i]+6, top+14, 10, true, pdf.Black, i18n.T(c, code))
+	}
+	for r, owner := range owners {
+		y := top + 20 + float64(r+1)*18
+		values := []string{owner.Email, strconv.Itoa(owner.Completed), strconv.Itoa(owner.Pending), strconv.Itoa(owner.Overdue)}
+		for i, value := range values {
+			color := pdf.Black
+			if i == 3 && owner.Overdue > 0 {
+				color = reportRed
+			}
+			doc.Text(columns[i]+6, y-5, 10, false, color, value)
+		}
+		doc.Line(reportMargin, y, pdf.PageWidth-reportMargin, y, 0.3, reportLight)
+	}
+}
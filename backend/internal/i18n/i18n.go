@@ -0,0 +1,83 @@
+package i18n
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+)
+
+// DefaultLanguage is used when the client does not ask for a supported one.
+const DefaultLanguage = "es"
+
+const contextKey = "i18n.lang"
+
+// Code is a stable, language-independent identifier returned next to every
+// localized message so clients can branch on it.
+type Code string
+
+var (
+	supported = []language.Tag{language.Spanish, language.English}
+	matcher   = language.NewMatcher(supported)
+)
+
+func init() {
+	respond.RenderFailed = func(c *gin.Context) {
+		Error(c, http.StatusInternalServerError, InternalError)
+	}
+}
+
+// Middleware resolves the request language once and announces it through
+// the Content-Language header.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := detect(c)
+		c.Set(contextKey, lang)
+		c.Header("Content-Language", lang)
+		c.Next()
+	}
+}
+
+// Lang returns the language selected for the request: the ?lang= override
+// first, then Accept-Language, then DefaultLanguage.
+func Lang(c *gin.Context) string {
+	if lang := c.GetString(contextKey); lang != "" {
+		return lang
+	}
+	return detect(c)
+}
+
+func detect(c *gin.Context) string {
+	_, index := language.MatchStrings(matcher, c.Query("lang"), c.GetHeader("Accept-Language"))
+	base, _ := supported[index].Base()
+	return base.String()
+}
+
+// T translates code into the request language, falling back to Spanish and
+// finally to the code itself.
+func T(c *gin.Context, code Code) string {
+	if msg, ok := catalogs[Lang(c)][code]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[DefaultLanguage][code]; ok {
+		return msg
+	}
+	return string(code)
+}
+
+// Error writes the standard error body {"error": <message>, "code": <code>}.
+func Error(c *gin.Context, status int, code Code) {
+	respond.Error(c, status, string(code), T(c, code))
+}
+
+// AbortError is like Error but also stops the handler chain.
+func AbortError(c *gin.Context, status int, code Code) {
+	respond.AbortError(c, status, string(code), T(c, code))
+}
+
+// Message writes a success body {"message": <message>, "code": <code>}.
+func Message(c *gin.Context, status int, code Code) {
+	respond.Render(c, status, gin.H{"message": T(c, code), "code": code})
+}
@@ -0,0 +1,816 @@
+package i18n
+
+// Message codes shared by handlers and middlewares.
+const (
+	InvalidPayload               Code = "INVALID_PAYLOAD"
+	InvalidID                    Code = "INVALID_ID"
+	InvalidPagination            Code = "INVALID_PAGINATION"
+	InternalError                Code = "INTERNAL_ERROR"
+	RouteNotFound                Code = "ROUTE_NOT_FOUND"
+	MethodNotAllowed             Code = "METHOD_NOT_ALLOWED"
+	ContractViolation            Code = "CONTRACT_VIOLATION"
+	ServiceUnavailable           Code = "SERVICE_UNAVAILABLE"
+	RequestTimeout               Code = "REQUEST_TIMEOUT"
+	Maintenance                  Code = "MAINTENANCE"
+	AdminDisabled                Code = "ADMIN_DISABLED"
+	InvalidAdminToken            Code = "INVALID_ADMIN_TOKEN"
+	UserRegistered               Code = "USER_REGISTERED"
+	EmailPasswordRequired        Code = "EMAIL_PASSWORD_REQUIRED"
+	UserAlreadyExists            Code = "USER_ALREADY_EXISTS"
+	RegisterFailed               Code = "REGISTER_FAILED"
+	LoginSucceeded               Code = "LOGIN_SUCCEEDED"
+	InvalidCredentials           Code = "INVALID_CREDENTIALS"
+	LoginFailed                  Code = "LOGIN_FAILED"
+	ListUsersFailed              Code = "LIST_USERS_FAILED"
+	ClearUsersFailed             Code = "CLEAR_USERS_FAILED"
+	UsersCleared                 Code = "USERS_CLEARED"
+	ListTodosFailed              Code = "LIST_TODOS_FAILED"
+	EmailTitleRequired           Code = "EMAIL_TITLE_REQUIRED"
+	CreateTodoFailed             Code = "CREATE_TODO_FAILED"
+	NothingToUpdate              Code = "NOTHING_TO_UPDATE"
+	TodoNotFound                 Code = "TODO_NOT_FOUND"
+	UpdateTodoFailed             Code = "UPDATE_TODO_FAILED"
+	TodoDeleted                  Code = "TODO_DELETED"
+	DeleteTodoFailed             Code = "DELETE_TODO_FAILED"
+	ClearTodosFailed             Code = "CLEAR_TODOS_FAILED"
+	TodosCleared                 Code = "TODOS_CLEARED"
+	InvalidRoomInput             Code = "INVALID_ROOM_INPUT"
+	InvalidRoomFilter            Code = "INVALID_ROOM_FILTER"
+	RoomNumberTaken              Code = "ROOM_NUMBER_TAKEN"
+	RoomNotFound                 Code = "ROOM_NOT_FOUND"
+	ListRoomsFailed              Code = "LIST_ROOMS_FAILED"
+	GetRoomFailed                Code = "GET_ROOM_FAILED"
+	CreateRoomFailed             Code = "CREATE_ROOM_FAILED"
+	UpdateRoomFailed             Code = "UPDATE_ROOM_FAILED"
+	RoomDeleted                  Code = "ROOM_DELETED"
+	DeleteRoomFailed             Code = "DELETE_ROOM_FAILED"
+	InvalidBookingInput          Code = "INVALID_BOOKING_INPUT"
+	InvalidStayDates             Code = "INVALID_STAY_DATES"
+	RoomNotAvailable             Code = "ROOM_NOT_AVAILABLE"
+	BookingNotFound              Code = "BOOKING_NOT_FOUND"
+	BookingStateConflict         Code = "BOOKING_STATE_CONFLICT"
+	AvailabilityFailed           Code = "AVAILABILITY_FAILED"
+	ListBookingsFailed           Code = "LIST_BOOKINGS_FAILED"
+	GetBookingFailed             Code = "GET_BOOKING_FAILED"
+	CreateBookingFailed          Code = "CREATE_BOOKING_FAILED"
+	UpdateBookingFailed          Code = "UPDATE_BOOKING_FAILED"
+	CancelBookingFailed          Code = "CANCEL_BOOKING_FAILED"
+	CheckInOutsideStay           Code = "CHECK_IN_OUTSIDE_STAY"
+	CheckInFailed                Code = "CHECK_IN_FAILED"
+	CheckOutFailed               Code = "CHECK_OUT_FAILED"
+	InvalidGuestInput            Code = "INVALID_GUEST_INPUT"
+	GuestNotFound                Code = "GUEST_NOT_FOUND"
+	GuestDocumentTaken           Code = "GUEST_DOCUMENT_TAKEN"
+	ListGuestsFailed             Code = "LIST_GUESTS_FAILED"
+	GetGuestFailed               Code = "GET_GUEST_FAILED"
+	CreateGuestFailed            Code = "CREATE_GUEST_FAILED"
+	UpdateGuestFailed            Code = "UPDATE_GUEST_FAILED"
+	GuestDeleted                 Code = "GUEST_DELETED"
+	DeleteGuestFailed            Code = "DELETE_GUEST_FAILED"
+	InvalidRatePlanInput         Code = "INVALID_RATE_PLAN_INPUT"
+	RatePlanNotFound             Code = "RATE_PLAN_NOT_FOUND"
+	ListRatePlansFailed          Code = "LIST_RATE_PLANS_FAILED"
+	GetRatePlanFailed            Code = "GET_RATE_PLAN_FAILED"
+	CreateRatePlanFailed         Code = "CREATE_RATE_PLAN_FAILED"
+	UpdateRatePlanFailed         Code = "UPDATE_RATE_PLAN_FAILED"
+	RatePlanDeleted              Code = "RATE_PLAN_DELETED"
+	DeleteRatePlanFailed         Code = "DELETE_RATE_PLAN_FAILED"
+	QuoteFailed                  Code = "QUOTE_FAILED"
+	InvalidPaymentInput          Code = "INVALID_PAYMENT_INPUT"
+	PaymentNotFound              Code = "PAYMENT_NOT_FOUND"
+	ListPaymentsFailed           Code = "LIST_PAYMENTS_FAILED"
+	CreatePaymentFailed          Code = "CREATE_PAYMENT_FAILED"
+	InvalidPaymentNotification   Code = "INVALID_PAYMENT_NOTIFICATION"
+	InvalidWebhookSignature      Code = "INVALID_WEBHOOK_SIGNATURE"
+	WebhookDisabled              Code = "WEBHOOK_DISABLED"
+	PaymentNotificationProcessed Code = "PAYMENT_NOTIFICATION_PROCESSED"
+	PaymentNotificationIgnored   Code = "PAYMENT_NOTIFICATION_IGNORED"
+	PaymentNotificationFailed    Code = "PAYMENT_NOTIFICATION_FAILED"
+	InvalidReviewInput           Code = "INVALID_REVIEW_INPUT"
+	ReviewNotAllowed             Code = "REVIEW_NOT_ALLOWED"
+	ReviewExists                 Code = "REVIEW_ALREADY_EXISTS"
+	CreateReviewFailed           Code = "CREATE_REVIEW_FAILED"
+	ListReviewsFailed            Code = "LIST_REVIEWS_FAILED"
+	InvalidReportQuery           Code = "INVALID_REPORT_QUERY"
+	ReportFailed                 Code = "REPORT_FAILED"
+	AuthRequired                 Code = "AUTH_REQUIRED"
+	InvalidSession               Code = "INVALID_SESSION"
+	RoleForbidden                Code = "ROLE_FORBIDDEN"
+	InvalidRole                  Code = "INVALID_ROLE"
+	UserNotFound                 Code = "USER_NOT_FOUND"
+	UpdateRoleFailed             Code = "UPDATE_ROLE_FAILED"
+	InvalidPropertyInput         Code = "INVALID_PROPERTY_INPUT"
+	InvalidPropertyID            Code = "INVALID_PROPERTY_ID"
+	PropertyNotFound             Code = "PROPERTY_NOT_FOUND"
+	PropertyCodeTaken            Code = "PROPERTY_CODE_TAKEN"
+	PropertyForbidden            Code = "PROPERTY_FORBIDDEN"
+	ListPropertiesFailed         Code = "LIST_PROPERTIES_FAILED"
+	CreatePropertyFailed         Code = "CREATE_PROPERTY_FAILED"
+	AssignPropertyFailed         Code = "ASSIGN_PROPERTY_FAILED"
+	InvalidWaitlistInput         Code = "INVALID_WAITLIST_INPUT"
+	RoomsAvailable               Code = "ROOMS_AVAILABLE"
+	WaitlistNotFound             Code = "WAITLIST_NOT_FOUND"
+	WaitlistNotOffered           Code = "WAITLIST_NOT_OFFERED"
+	WaitlistHoldExpired          Code = "WAITLIST_HOLD_EXPIRED"
+	JoinWaitlistFailed           Code = "JOIN_WAITLIST_FAILED"
+	ListWaitlistFailed           Code = "LIST_WAITLIST_FAILED"
+	ConfirmWaitlistFailed        Code = "CONFIRM_WAITLIST_FAILED"
+	MailOptedOut                 Code = "MAIL_OPTED_OUT"
+	InvalidOptOutToken           Code = "INVALID_OPT_OUT_TOKEN"
+	OptOutFailed                 Code = "OPT_OUT_FAILED"
+	InvalidImport                Code = "INVALID_IMPORT"
+	ImportNotFound               Code = "IMPORT_NOT_FOUND"
+	ImportBookingsFailed         Code = "IMPORT_BOOKINGS_FAILED"
+	GetImportFailed              Code = "GET_IMPORT_FAILED"
+	InvalidRecurrence            Code = "INVALID_RECURRENCE"
+	RestoreTodoFailed            Code = "RESTORE_TODO_FAILED"
+	ListJobsFailed               Code = "LIST_JOBS_FAILED"
+	InvalidDeadLetterFilter      Code = "INVALID_DEAD_LETTER_FILTER"
+	DeadLetterNotFound           Code = "DEAD_LETTER_NOT_FOUND"
+	DeadLetterRetried            Code = "DEAD_LETTER_RETRIED"
+	ListDeadLettersFailed        Code = "LIST_DEAD_LETTERS_FAILED"
+	GetDeadLetterFailed          Code = "GET_DEAD_LETTER_FAILED"
+	RetryDeadLetterFailed        Code = "RETRY_DEAD_LETTER_FAILED"
+	InvalidDashboardRange        Code = "INVALID_DASHBOARD_RANGE"
+	DashboardFailed              Code = "DASHBOARD_FAILED"
+	QuotaExceeded                Code = "QUOTA_EXCEEDED"
+	InvalidQuota                 Code = "INVALID_QUOTA"
+	UsageFailed                  Code = "USAGE_FAILED"
+	UpdateQuotaFailed            Code = "UPDATE_QUOTA_FAILED"
+	LoginRequired                Code = "LOGIN_REQUIRED"
+	InvalidErasureMode           Code = "INVALID_ERASURE_MODE"
+	ErasureNotFound              Code = "ERASURE_NOT_FOUND"
+	ExportFailed                 Code = "EXPORT_FAILED"
+	EraseAccountFailed           Code = "ERASE_ACCOUNT_FAILED"
+	GetErasureFailed             Code = "GET_ERASURE_FAILED"
+	AccountSuspended             Code = "ACCOUNT_SUSPENDED"
+	SuspendUserFailed            Code = "SUSPEND_USER_FAILED"
+	InvalidImpersonation         Code = "INVALID_IMPERSONATION"
+	ImpersonationForbidden       Code = "IMPERSONATION_FORBIDDEN"
+	ImpersonateFailed            Code = "IMPERSONATE_FAILED"
+	SessionRevoked               Code = "SESSION_REVOKED"
+	SessionNotFound              Code = "SESSION_NOT_FOUND"
+	ListSessionsFailed           Code = "LIST_SESSIONS_FAILED"
+	RevokeSessionFailed          Code = "REVOKE_SESSION_FAILED"
+	PasskeyDeleted               Code = "PASSKEY_DELETED"
+	PasskeyNotFound              Code = "PASSKEY_NOT_FOUND"
+	PasskeyExists                Code = "PASSKEY_EXISTS"
+	InvalidPasskeyCeremony       Code = "INVALID_PASSKEY_CEREMONY"
+	InvalidPasskey               Code = "INVALID_PASSKEY"
+	PasskeyOptionsFailed         Code = "PASSKEY_OPTIONS_FAILED"
+	SavePasskeyFailed            Code = "SAVE_PASSKEY_FAILED"
+	ListPasskeysFailed           Code = "LIST_PASSKEYS_FAILED"
+	DeletePasskeyFailed          Code = "DELETE_PASSKEY_FAILED"
+	CaptchaRequired              Code = "CAPTCHA_REQUIRED"
+	InvalidCaptcha               Code = "INVALID_CAPTCHA"
+	CaptchaUnavailable           Code = "CAPTCHA_UNAVAILABLE"
+	IPForbidden                  Code = "IP_FORBIDDEN"
+	FaultInjected                Code = "FAULT_INJECTED"
+	InvalidFaultHeader           Code = "INVALID_FAULT_HEADER"
+	InvalidOutboxChannel         Code = "INVALID_OUTBOX_CHANNEL"
+	OutboxPreviewCleared         Code = "OUTBOX_PREVIEW_CLEARED"
+	InvalidBackup                Code = "INVALID_BACKUP"
+	BackupFailed                 Code = "BACKUP_FAILED"
+	RestoreFailed                Code = "RESTORE_FAILED"
+	RateLimited                  Code = "RATE_LIMITED"
+	ToggleTodosFailed            Code = "TOGGLE_TODOS_FAILED"
+	ClearCompletedFailed         Code = "CLEAR_COMPLETED_FAILED"
+	InvalidTodoLabel             Code = "INVALID_TODO_LABEL"
+	InvalidReaction              Code = "INVALID_REACTION"
+	ReactionFailed               Code = "REACTION_FAILED"
+	InvalidComment               Code = "INVALID_COMMENT"
+	ListCommentsFailed           Code = "LIST_COMMENTS_FAILED"
+	CreateCommentFailed          Code = "CREATE_COMMENT_FAILED"
+	NotificationNotFound         Code = "NOTIFICATION_NOT_FOUND"
+	ListNotificationsFailed      Code = "LIST_NOTIFICATIONS_FAILED"
+	ReadNotificationFailed       Code = "READ_NOTIFICATION_FAILED"
+	ApprovalForbidden            Code = "APPROVAL_FORBIDDEN"
+	ApprovalStateConflict        Code = "APPROVAL_STATE_CONFLICT"
+	ApprovalFailed               Code = "APPROVAL_FAILED"
+	InvalidList                  Code = "INVALID_LIST"
+	ListNotFound                 Code = "LIST_NOT_FOUND"
+	ListForbidden                Code = "LIST_FORBIDDEN"
+	ListMemberExists             Code = "LIST_MEMBER_EXISTS"
+	ListMemberNotFound           Code = "LIST_MEMBER_NOT_FOUND"
+	LastListAdmin                Code = "LAST_LIST_ADMIN"
+	ListListsFailed              Code = "LIST_LISTS_FAILED"
+	CreateListFailed             Code = "CREATE_LIST_FAILED"
+	UpdateListFailed             Code = "UPDATE_LIST_FAILED"
+	InvalidSSOConfig             Code = "INVALID_SSO_CONFIG"
+	SSONotConfigured             Code = "SSO_NOT_CONFIGURED"
+	InvalidSSOState              Code = "INVALID_SSO_STATE"
+	SSORejected                  Code = "SSO_REJECTED"
+	SSOUnavailable               Code = "SSO_UNAVAILABLE"
+	SSODisabled                  Code = "SSO_DISABLED"
+	SSOFailed                    Code = "SSO_FAILED"
+	InvalidSCIMToken             Code = "INVALID_SCIM_TOKEN"
+	InvalidSCIMUser              Code = "INVALID_SCIM_USER"
+	InvalidSCIMFilter            Code = "INVALID_SCIM_FILTER"
+	InvalidSCIMPatch             Code = "INVALID_SCIM_PATCH"
+	SCIMTokenRevoked             Code = "SCIM_TOKEN_REVOKED"
+	SCIMFailed                   Code = "SCIM_FAILED"
+	InvalidAttachment            Code = "INVALID_ATTACHMENT"
+	AttachmentTooLarge           Code = "ATTACHMENT_TOO_LARGE"
+	AttachmentNotFound           Code = "ATTACHMENT_NOT_FOUND"
+	AttachmentDeleted            Code = "ATTACHMENT_DELETED"
+	SignedURLsDisabled           Code = "SIGNED_URLS_DISABLED"
+	InvalidAttachmentLink        Code = "INVALID_ATTACHMENT_LINK"
+	AttachmentFailed             Code = "ATTACHMENT_FAILED"
+	AttachmentScanPending        Code = "ATTACHMENT_SCAN_PENDING"
+	AttachmentBlocked            Code = "ATTACHMENT_BLOCKED"
+	InvalidFeedToken             Code = "INVALID_FEED_TOKEN"
+	FeedTokenRevoked             Code = "FEED_TOKEN_REVOKED"
+	FeedFailed                   Code = "FEED_FAILED"
+	FeedTitle                    Code = "FEED_TITLE"
+	FeedTodoCreated              Code = "FEED_TODO_CREATED"
+	FeedTodoCompleted            Code = "FEED_TODO_COMPLETED"
+	CalDAVForbidden              Code = "CALDAV_FORBIDDEN"
+	InvalidCalendarData          Code = "INVALID_CALENDAR_DATA"
+	UnsupportedReport            Code = "UNSUPPORTED_REPORT"
+	PreconditionFailed           Code = "PRECONDITION_FAILED"
+	CalDAVFailed                 Code = "CALDAV_FAILED"
+	InvalidTodoImport            Code = "INVALID_TODO_IMPORT"
+	UnknownImportSource          Code = "UNKNOWN_IMPORT_SOURCE"
+	ImportTodosFailed            Code = "IMPORT_TODOS_FAILED"
+	InvalidSummaryRange          Code = "INVALID_SUMMARY_RANGE"
+	WeeklyReportTitle            Code = "WEEKLY_REPORT_TITLE"
+	ReportCreated                Code = "REPORT_CREATED"
+	ReportCompleted              Code = "REPORT_COMPLETED"
+	ReportPending                Code = "REPORT_PENDING"
+	ReportOverdue                Code = "REPORT_OVERDUE"
+	ReportPerDay                 Code = "REPORT_PER_DAY"
+	ReportOwner                  Code = "REPORT_OWNER"
+	StatsFailed                  Code = "STATS_FAILED"
+	InvalidAuditQuery            Code = "INVALID_AUDIT_QUERY"
+	ListAuditFailed              Code = "LIST_AUDIT_FAILED"
+	InvalidClearMode             Code = "INVALID_CLEAR_MODE"
+	AnonymizeUsersFailed         Code = "ANONYMIZE_USERS_FAILED"
+	InvalidMerge                 Code = "INVALID_MERGE"
+	MergeEmailMismatch           Code = "MERGE_EMAIL_MISMATCH"
+	MergeNotVerified             Code = "MERGE_NOT_VERIFIED"
+	MergeAccountFailed           Code = "MERGE_ACCOUNT_FAILED"
+	InvalidEmailChange           Code = "INVALID_EMAIL_CHANGE"
+	EmailChangeNotVerified       Code = "EMAIL_CHANGE_NOT_VERIFIED"
+	InvalidEmailChangeToken      Code = "INVALID_EMAIL_CHANGE_TOKEN"
+	EmailChangeCancelled         Code = "EMAIL_CHANGE_CANCELLED"
+	EmailChangeFailed            Code = "EMAIL_CHANGE_FAILED"
+	TodoFilterRequired           Code = "TODO_FILTER_REQUIRED"
+	ClearAllForbidden            Code = "CLEAR_ALL_FORBIDDEN"
+	UnknownCollection            Code = "UNKNOWN_COLLECTION"
+	DumpFailed                   Code = "DUMP_FAILED"
+	WebSocketRequired            Code = "WEBSOCKET_REQUIRED"
+	InvalidRealtimeTicket        Code = "INVALID_REALTIME_TICKET"
+	RealtimeTicketFailed         Code = "REALTIME_TICKET_FAILED"
+	ListPresenceFailed           Code = "LIST_PRESENCE_FAILED"
+	InvalidTitleEdit             Code = "INVALID_TITLE_EDIT"
+	TitleEditConflict            Code = "TITLE_EDIT_CONFLICT"
+	TitleHistoryGone             Code = "TITLE_HISTORY_GONE"
+	TitleEditFailed              Code = "TITLE_EDIT_FAILED"
+	InvalidSyncBatch             Code = "INVALID_SYNC_BATCH"
+	SyncOpsFailed                Code = "SYNC_OPS_FAILED"
+	InvalidClientID              Code = "INVALID_CLIENT_ID"
+	DuplicateClientID            Code = "DUPLICATE_CLIENT_ID"
+)
+
+var catalogs = map[string]map[Code]string{
+	"es": {
+		InvalidPayload:               "datos invalidos",
+		InvalidID:                    "id invalido",
+		InvalidPagination:            "parametros de paginacion invalidos",
+		InternalError:                "error interno del servidor",
+		RouteNotFound:                "ruta no encontrada",
+		MethodNotAllowed:             "metodo no permitido",
+		ContractViolation:            "la respuesta no cumple el contrato OpenAPI",
+		ServiceUnavailable:           "servicio no disponible, intente mas tarde",
+		RequestTimeout:               "tiempo de espera agotado",
+		Maintenance:                  "servicio en mantenimiento, intente mas tarde",
+		AdminDisabled:                "endpoints de administracion deshabilitados",
+		InvalidAdminToken:            "token de administracion invalido",
+		UserRegistered:               "usuario registrado con exito",
+		EmailPasswordRequired:        "email y clave son requeridos",
+		UserAlreadyExists:            "usuario ya existe",
+		RegisterFailed:               "error al registrar usuario",
+		LoginSucceeded:               "login exitoso",
+		InvalidCredentials:           "credenciales invalidas",
+		LoginFailed:                  "error al autenticar",
+		ListUsersFailed:              "error al obtener usuarios",
+		ClearUsersFailed:             "error al limpiar usuarios",
+		UsersCleared:                 "usuarios eliminados",
+		ListTodosFailed:              "error al obtener tareas",
+		EmailTitleRequired:           "email y titulo son requeridos",
+		CreateTodoFailed:             "error al crear tarea",
+		NothingToUpdate:              "nada para actualizar",
+		TodoNotFound:                 "tarea no encontrada",
+		UpdateTodoFailed:             "error al actualizar tarea",
+		TodoDeleted:                  "tarea eliminada",
+		DeleteTodoFailed:             "error al eliminar tarea",
+		ClearTodosFailed:             "error al limpiar tareas",
+		TodosCleared:                 "tareas eliminadas",
+		InvalidRoomInput:             "datos de habitacion invalidos",
+		InvalidRoomFilter:            "filtro de habitaciones invalido",
+		RoomNumberTaken:              "ya existe una habitacion con ese numero",
+		RoomNotFound:                 "habitacion no encontrada",
+		ListRoomsFailed:              "error al obtener habitaciones",
+		GetRoomFailed:                "error al obtener habitacion",
+		CreateRoomFailed:             "error al crear habitacion",
+		UpdateRoomFailed:             "error al actualizar habitacion",
+		RoomDeleted:                  "habitacion eliminada",
+		DeleteRoomFailed:             "error al eliminar habitacion",
+		InvalidBookingInput:          "datos de reserva invalidos",
+		InvalidStayDates:             "fechas de estadia invalidas",
+		RoomNotAvailable:             "la habitacion no esta disponible en esas fechas",
+		BookingNotFound:              "reserva no encontrada",
+		BookingStateConflict:         "la reserva no admite esta operacion en su estado actual",
+		AvailabilityFailed:           "error al calcular disponibilidad",
+		ListBookingsFailed:           "error al obtener reservas",
+		GetBookingFailed:             "error al obtener reserva",
+		CreateBookingFailed:          "error al crear reserva",
+		UpdateBookingFailed:          "error al modificar reserva",
+		CancelBookingFailed:          "error al cancelar reserva",
+		CheckInOutsideStay:           "el check-in solo es posible entre la fecha de ingreso y la de salida",
+		CheckInFailed:                "error al registrar el check-in",
+		CheckOutFailed:               "error al registrar el check-out",
+		InvalidGuestInput:            "nombre y documento del huesped son requeridos",
+		GuestNotFound:                "huesped no encontrado",
+		GuestDocumentTaken:           "ya existe un huesped con ese documento",
+		ListGuestsFailed:             "error al obtener huespedes",
+		GetGuestFailed:               "error al obtener huesped",
+		CreateGuestFailed:            "error al crear huesped",
+		UpdateGuestFailed:            "error al actualizar huesped",
+		GuestDeleted:                 "huesped eliminado",
+		DeleteGuestFailed:            "error al eliminar huesped",
+		InvalidRatePlanInput:         "datos de tarifa invalidos",
+		RatePlanNotFound:             "tarifa no encontrada",
+		ListRatePlansFailed:          "error al obtener tarifas",
+		GetRatePlanFailed:            "error al obtener tarifa",
+		CreateRatePlanFailed:         "error al crear tarifa",
+		UpdateRatePlanFailed:         "error al actualizar tarifa",
+		RatePlanDeleted:              "tarifa eliminada",
+		DeleteRatePlanFailed:         "error al eliminar tarifa",
+		QuoteFailed:                  "error al cotizar la estadia",
+		InvalidPaymentInput:          "monto, moneda o medio de pago invalidos",
+		PaymentNotFound:              "pago no encontrado",
+		ListPaymentsFailed:           "no se pudieron obtener los pagos",
+		CreatePaymentFailed:          "no se pudo registrar el pago",
+		InvalidPaymentNotification:   "notificacion de pago invalida",
+		InvalidWebhookSignature:      "firma del webhook invalida",
+		WebhookDisabled:              "el webhook de pagos no esta configurado",
+		PaymentNotificationProcessed: "notificacion de pago procesada",
+		PaymentNotificationIgnored:   "notificacion de pago ya procesada o sin efecto",
+		PaymentNotificationFailed:    "no se pudo procesar la notificacion de pago",
+		InvalidReviewInput:           "la calificacion debe ser de 1 a 5 y el comentario de hasta 1000 caracteres",
+		ReviewNotAllowed:             "solo se pueden calificar estadias finalizadas",
+		ReviewExists:                 "la reserva ya fue calificada",
+		CreateReviewFailed:           "no se pudo guardar la calificacion",
+		ListReviewsFailed:            "no se pudieron obtener las calificaciones",
+		InvalidReportQuery:           "rango de fechas o agrupacion invalidos (from y to en formato YYYY-MM-DD, hasta dos anos; groupBy day, week o month)",
+		ReportFailed:                 "no se pudo generar el reporte",
+		AuthRequired:                 "se requiere iniciar sesion con una cuenta del personal",
+		InvalidSession:               "sesion invalida o vencida",
+		RoleForbidden:                "tu rol no tiene acceso a este recurso",
+		InvalidRole:                  "rol invalido (manager, front_desk, housekeeping o vacio)",
+		UserNotFound:                 "usuario no encontrado",
+		UpdateRoleFailed:             "no se pudo actualizar el rol",
+		InvalidPropertyInput:         "codigo y nombre de la propiedad son obligatorios",
+		InvalidPropertyID:            "id de propiedad invalido",
+		PropertyNotFound:             "propiedad no encontrada",
+		PropertyCodeTaken:            "ya existe una propiedad con ese codigo",
+		PropertyForbidden:            "tu usuario no pertenece a esa propiedad",
+		ListPropertiesFailed:         "no se pudieron obtener las propiedades",
+		CreatePropertyFailed:         "no se pudo crear la propiedad",
+		AssignPropertyFailed:         "no se pudo asignar la propiedad",
+		InvalidWaitlistInput:         "email, huespedes, tipo de habitacion o estado de la lista de espera invalidos",
+		RoomsAvailable:               "hay habitaciones disponibles para esas fechas; crea la reserva directamente",
+		WaitlistNotFound:             "entrada de la lista de espera no encontrada",
+		WaitlistNotOffered:           "la entrada no tiene una habitacion retenida",
+		WaitlistHoldExpired:          "la habitacion retenida ya vencio",
+		JoinWaitlistFailed:           "no se pudo agregar a la lista de espera",
+		ListWaitlistFailed:           "no se pudo obtener la lista de espera",
+		ConfirmWaitlistFailed:        "no se pudo confirmar la reserva de la lista de espera",
+		MailOptedOut:                 "ya no recibiras emails de tus reservas",
+		InvalidOptOutToken:           "enlace para dejar de recibir emails invalido",
+		OptOutFailed:                 "no se pudo registrar la baja de emails",
+		InvalidImport:                "se requiere un canal y entre 1 y 5000 reservas validas",
+		ImportNotFound:               "importacion no encontrada",
+		ImportBookingsFailed:         "no se pudo iniciar la importacion de reservas",
+		GetImportFailed:              "no se pudo obtener la importacion",
+		InvalidRecurrence:            "la recurrencia debe ser daily, weekly o monthly",
+		RestoreTodoFailed:            "error al restaurar tarea",
+		ListJobsFailed:               "error al listar los trabajos programados",
+		InvalidDeadLetterFilter:      "kind debe ser event o email y status pending o retried",
+		DeadLetterNotFound:           "mensaje fallido no encontrado",
+		DeadLetterRetried:            "el mensaje fallido ya fue reenviado",
+		ListDeadLettersFailed:        "error al listar los mensajes fallidos",
+		GetDeadLetterFailed:          "error al obtener el mensaje fallido",
+		RetryDeadLetterFailed:        "error al reenviar los mensajes fallidos",
+		InvalidDashboardRange:        "rango de fechas invalido (from y to en formato YYYY-MM-DD, hasta 366 dias)",
+		DashboardFailed:              "no se pudo generar el resumen del panel",
+		QuotaExceeded:                "se alcanzo el limite del plan de la cuenta",
+		InvalidQuota:                 "los limites deben ser enteros mayores o iguales a cero (0 es sin limite)",
+		UsageFailed:                  "error al obtener el uso de la cuenta",
+		UpdateQuotaFailed:            "error al actualizar los limites de la cuenta",
+		LoginRequired:                "se requiere iniciar sesion",
+		InvalidErasureMode:           "para borrar la cuenta indique mode=gdpr",
+		ErasureNotFound:              "borrado no encontrado",
+		ExportFailed:                 "error al exportar los datos de la cuenta",
+		EraseAccountFailed:           "error al borrar la cuenta",
+		GetErasureFailed:             "error al obtener el borrado",
+		AccountSuspended:             "la cuenta esta suspendida, contacte a soporte",
+		SuspendUserFailed:            "error al actualizar la suspension del usuario",
+		InvalidImpersonation:         "operator y reason son obligatorios",
+		ImpersonationForbidden:       "no se puede suplantar a una cuenta de personal",
+		ImpersonateFailed:            "error al emitir el token de suplantacion",
+		SessionRevoked:               "sesion cerrada",
+		SessionNotFound:              "sesion no encontrada",
+		ListSessionsFailed:           "error al obtener las sesiones",
+		RevokeSessionFailed:          "error al cerrar la sesion",
+		PasskeyDeleted:               "passkey eliminada",
+		PasskeyNotFound:              "passkey no encontrada",
+		PasskeyExists:                "la passkey ya esta registrada",
+		InvalidPasskeyCeremony:       "el desafio de la passkey vencio o ya fue usado",
+		InvalidPasskey:               "la respuesta de la passkey no es valida",
+		PasskeyOptionsFailed:         "error al iniciar la operacion con passkey",
+		SavePasskeyFailed:            "error al registrar la passkey",
+		ListPasskeysFailed:           "error al obtener las passkeys",
+		DeletePasskeyFailed:          "error al eliminar la passkey",
+		CaptchaRequired:              "se requiere resolver el captcha",
+		InvalidCaptcha:               "el captcha no es valido",
+		CaptchaUnavailable:           "no se pudo verificar el captcha, intente nuevamente",
+		IPForbidden:                  "acceso no permitido desde esta direccion IP",
+		FaultInjected:                "falla inyectada para pruebas",
+		InvalidFaultHeader:           "cabeceras de inyeccion de fallas invalidas",
+		InvalidOutboxChannel:         "canal invalido, use email, alert, event, waitlist o captcha",
+		OutboxPreviewCleared:         "mensajes simulados eliminados",
+		InvalidBackup:                "el archivo de respaldo es invalido",
+		BackupFailed:                 "error al generar el respaldo",
+		RestoreFailed:                "error al restaurar el respaldo",
+		RateLimited:                  "demasiadas solicitudes, intente mas tarde",
+		ToggleTodosFailed:            "error al actualizar las tareas",
+		ClearCompletedFailed:         "error al eliminar las tareas completadas",
+		InvalidTodoLabel:             "color o icono de tarea invalido",
+		InvalidReaction:              "reaccion invalida",
+		ReactionFailed:               "error al actualizar la reaccion",
+		InvalidComment:               "el comentario no puede estar vacio ni superar los 2000 caracteres",
+		ListCommentsFailed:           "error al obtener los comentarios",
+		CreateCommentFailed:          "error al crear el comentario",
+		NotificationNotFound:         "notificacion no encontrada",
+		ListNotificationsFailed:      "error al obtener las notificaciones",
+		ReadNotificationFailed:       "error al marcar la notificacion como leida",
+		ApprovalForbidden:            "solo el responsable puede pedir la aprobacion y solo el dueno puede aprobar o rechazar",
+		ApprovalStateConflict:        "la tarea no admite esta operacion en su estado de aprobacion actual",
+		ApprovalFailed:               "error al actualizar la aprobacion de la tarea",
+		InvalidList:                  "la lista necesita un nombre de hasta 100 caracteres y cada miembro un email y un rol valido (viewer, contributor o admin)",
+		ListNotFound:                 "lista no encontrada",
+		ListForbidden:                "tu rol en la lista no permite esta operacion",
+		ListMemberExists:             "el usuario ya es miembro de la lista",
+		ListMemberNotFound:           "el usuario no es miembro de la lista",
+		LastListAdmin:                "la lista tiene que conservar al menos un admin",
+		ListListsFailed:              "error al obtener las listas",
+		CreateListFailed:             "error al crear la lista",
+		UpdateListFailed:             "error al actualizar los miembros de la lista",
+		InvalidSSOConfig:             "el issuer debe ser una URL http(s), el clientId es requerido y el rol por defecto debe ser un rol del personal",
+		SSONotConfigured:             "la propiedad no tiene inicio de sesion unico configurado",
+		InvalidSSOState:              "el inicio de sesion unico vencio o ya fue usado",
+		SSORejected:                  "el proveedor de identidad rechazo el inicio de sesion",
+		SSOUnavailable:               "no se pudo contactar al proveedor de identidad, intente nuevamente",
+		SSODisabled:                  "inicio de sesion unico deshabilitado",
+		SSOFailed:                    "error al configurar el inicio de sesion unico",
+		InvalidSCIMToken:             "token SCIM invalido o revocado",
+		InvalidSCIMUser:              "el usuario necesita un userName con un email valido y roles del personal",
+		InvalidSCIMFilter:            "filtro no soportado: se admiten comparaciones eq de userName, emails.value y active unidas con and",
+		InvalidSCIMPatch:             "solo se pueden agregar o reemplazar active y roles",
+		SCIMTokenRevoked:             "token SCIM revocado",
+		SCIMFailed:                   "error al procesar la solicitud SCIM",
+		InvalidAttachment:            "el adjunto necesita un nombre (name) de hasta 255 caracteres y contenido",
+		AttachmentTooLarge:           "el adjunto supera el tamano maximo",
+		AttachmentNotFound:           "adjunto no encontrado",
+		AttachmentDeleted:            "adjunto eliminado",
+		SignedURLsDisabled:           "los enlaces firmados de descarga no estan configurados",
+		InvalidAttachmentLink:        "el enlace de descarga es invalido o vencio",
+		AttachmentFailed:             "error al procesar el adjunto",
+		AttachmentScanPending:        "el adjunto todavia se esta analizando",
+		AttachmentBlocked:            "el adjunto fue bloqueado por contener malware",
+		InvalidFeedToken:             "token de feed invalido o revocado",
+		FeedTokenRevoked:             "token de feed revocado",
+		FeedFailed:                   "error al generar el feed de tareas",
+		FeedTitle:                    "tareas de",
+		FeedTodoCreated:              "nueva tarea",
+		FeedTodoCompleted:            "tarea completada",
+		CalDAVForbidden:              "solo se puede acceder al calendario propio",
+		InvalidCalendarData:          "se espera un VTODO con UID y SUMMARY",
+		UnsupportedReport:            "reporte no soportado: se admiten calendar-query y calendar-multiget",
+		PreconditionFailed:           "la tarea cambio desde la ultima sincronizacion",
+		CalDAVFailed:                 "error al procesar la solicitud CalDAV",
+		InvalidTodoImport:            "el archivo de exportacion no es valido o tiene mas de 1000 tareas",
+		UnknownImportSource:          "el origen de la importacion debe ser todoist o google",
+		ImportTodosFailed:            "no se pudieron importar las tareas",
+		InvalidSummaryRange:          "rango de fechas invalido (from y to en formato YYYY-MM-DD, hasta 92 dias)",
+		WeeklyReportTitle:            "Resumen semanal de tareas",
+		ReportCreated:                "Creadas",
+		ReportCompleted:              "Completadas",
+		ReportPending:                "Pendientes",
+		ReportOverdue:                "Vencidas",
+		ReportPerDay:                 "Tareas por dia",
+		ReportOwner:                  "Responsable",
+		StatsFailed:                  "no se pudieron calcular las estadisticas",
+		InvalidAuditQuery:            "filtros de auditoria invalidos (from y to en RFC 3339 o YYYY-MM-DD, limit hasta 100, cursor de una pagina anterior)",
+		ListAuditFailed:              "error al consultar la auditoria",
+		InvalidClearMode:             "el unico modo disponible es mode=anonymize",
+		AnonymizeUsersFailed:         "error al anonimizar los usuarios",
+		InvalidMerge:                 "indique el email de otra cuenta para unificar",
+		MergeEmailMismatch:           "solo se pueden unificar cuentas con variantes del mismo email",
+		MergeNotVerified:             "no se pudo verificar que la otra cuenta sea suya",
+		MergeAccountFailed:           "error al unificar las cuentas",
+		InvalidEmailChange:           "indique un email distinto del actual",
+		EmailChangeNotVerified:       "la contrasena no es correcta",
+		InvalidEmailChangeToken:      "el enlace no es valido o ya vencio",
+		EmailChangeCancelled:         "se cancelo el cambio de email",
+		EmailChangeFailed:            "error al cambiar el email",
+		TodoFilterRequired:           "indica un email o all=true para abarcar todas las tareas",
+		ClearAllForbidden:            "solo el token de administrador puede eliminar todas las tareas",
+		UnknownCollection:            "la coleccion no existe o no se puede exportar",
+		DumpFailed:                   "error al exportar la coleccion",
+		WebSocketRequired:            "esta ruta solo acepta conexiones WebSocket",
+		InvalidRealtimeTicket:        "el ticket de tiempo real vencio o ya fue usado",
+		RealtimeTicketFailed:         "no se pudo emitir el ticket de tiempo real",
+		ListPresenceFailed:           "error al obtener quien esta viendo la lista",
+		InvalidTitleEdit:             "la edicion no corresponde al titulo en esa version o lo deja vacio",
+		TitleEditConflict:            "el titulo cambio demasiadas veces a la vez, reintenta la edicion",
+		TitleHistoryGone:             "ya no se puede combinar una edicion de esa version, vuelve a cargar el titulo",
+		TitleEditFailed:              "error al editar el titulo",
+		InvalidSyncBatch:             "lote de operaciones invalido (entre 1 y 100 operaciones, cada una con un opId UUID)",
+		SyncOpsFailed:                "error al sincronizar las operaciones",
+		InvalidClientID:              "el clientId debe ser un UUID",
+		DuplicateClientID:            "ya existe una tarea con ese clientId",
+	},
+	"en": {
+		InvalidPayload:               "invalid payload",
+		InvalidID:                    "invalid id",
+		InvalidPagination:            "invalid pagination parameters",
+		InternalError:                "internal server error",
+		RouteNotFound:                "route not found",
+		MethodNotAllowed:             "method not allowed",
+		ContractViolation:            "response does not match the OpenAPI contract",
+		ServiceUnavailable:           "service unavailable, please try again later",
+		RequestTimeout:               "request timed out",
+		Maintenance:                  "service under maintenance, please try again later",
+		AdminDisabled:                "admin endpoints are disabled",
+		InvalidAdminToken:            "invalid admin token",
+		UserRegistered:               "user registered successfully",
+		EmailPasswordRequired:        "email and password are required",
+		UserAlreadyExists:            "user already exists",
+		RegisterFailed:               "could not register user",
+		LoginSucceeded:               "login successful",
+		InvalidCredentials:           "invalid credentials",
+		LoginFailed:                  "could not authenticate",
+		ListUsersFailed:              "could not list users",
+		ClearUsersFailed:             "could not clear users",
+		UsersCleared:                 "users deleted",
+		ListTodosFailed:              "could not list todos",
+		EmailTitleRequired:           "email and title are required",
+		CreateTodoFailed:             "could not create todo",
+		NothingToUpdate:              "nothing to update",
+		TodoNotFound:                 "todo not found",
+		UpdateTodoFailed:             "could not update todo",
+		TodoDeleted:                  "todo deleted",
+		DeleteTodoFailed:             "could not delete todo",
+		ClearTodosFailed:             "could not clear todos",
+		TodosCleared:                 "todos deleted",
+		InvalidRoomInput:             "invalid room data",
+		InvalidRoomFilter:            "invalid room filter",
+		RoomNumberTaken:              "a room with that number already exists",
+		RoomNotFound:                 "room not found",
+		ListRoomsFailed:              "could not list rooms",
+		GetRoomFailed:                "could not get room",
+		CreateRoomFailed:             "could not create room",
+		UpdateRoomFailed:             "could not update room",
+		RoomDeleted:                  "room deleted",
+		DeleteRoomFailed:             "could not delete room",
+		InvalidBookingInput:          "invalid booking data",
+		InvalidStayDates:             "invalid stay dates",
+		RoomNotAvailable:             "the room is not available for those dates",
+		BookingNotFound:              "booking not found",
+		BookingStateConflict:         "the booking does not allow this operation in its current status",
+		AvailabilityFailed:           "could not compute availability",
+		ListBookingsFailed:           "could not list bookings",
+		GetBookingFailed:             "could not get booking",
+		CreateBookingFailed:          "could not create booking",
+		UpdateBookingFailed:          "could not update booking",
+		CancelBookingFailed:          "could not cancel booking",
+		CheckInOutsideStay:           "check-in is only possible between the arrival and departure dates",
+		CheckInFailed:                "could not check in",
+		CheckOutFailed:               "could not check out",
+		InvalidGuestInput:            "guest name and document are required",
+		GuestNotFound:                "guest not found",
+		GuestDocumentTaken:           "a guest with that document already exists",
+		ListGuestsFailed:             "could not list guests",
+		GetGuestFailed:               "could not get guest",
+		CreateGuestFailed:            "could not create guest",
+		UpdateGuestFailed:            "could not update guest",
+		GuestDeleted:                 "guest deleted",
+		DeleteGuestFailed:            "could not delete guest",
+		InvalidRatePlanInput:         "invalid rate plan data",
+		RatePlanNotFound:             "rate plan not found",
+		ListRatePlansFailed:          "could not list rate plans",
+		GetRatePlanFailed:            "could not get rate plan",
+		CreateRatePlanFailed:         "could not create rate plan",
+		UpdateRatePlanFailed:         "could not update rate plan",
+		RatePlanDeleted:              "rate plan deleted",
+		DeleteRatePlanFailed:         "could not delete rate plan",
+		QuoteFailed:                  "could not quote the stay",
+		InvalidPaymentInput:          "invalid amount, currency or payment method",
+		PaymentNotFound:              "payment not found",
+		ListPaymentsFailed:           "could not list payments",
+		CreatePaymentFailed:          "could not record payment",
+		InvalidPaymentNotification:   "invalid payment notification",
+		InvalidWebhookSignature:      "invalid webhook signature",
+		WebhookDisabled:              "payment webhook is not configured",
+		PaymentNotificationProcessed: "payment notification processed",
+		PaymentNotificationIgnored:   "payment notification already processed or not applicable",
+		PaymentNotificationFailed:    "could not process payment notification",
+		InvalidReviewInput:           "rating must be between 1 and 5 and the comment up to 1000 characters",
+		ReviewNotAllowed:             "only completed stays can be reviewed",
+		ReviewExists:                 "booking already reviewed",
+		CreateReviewFailed:           "could not save review",
+		ListReviewsFailed:            "could not list reviews",
+		InvalidReportQuery:           "invalid date range or grouping (from and to as YYYY-MM-DD, up to two years; groupBy day, week or month)",
+		ReportFailed:                 "could not build report",
+		AuthRequired:                 "sign in with a staff account is required",
+		InvalidSession:               "invalid or expired session",
+		RoleForbidden:                "your role cannot access this resource",
+		InvalidRole:                  "invalid role (manager, front_desk, housekeeping or empty)",
+		UserNotFound:                 "user not found",
+		UpdateRoleFailed:             "could not update role",
+		InvalidPropertyInput:         "property code and name are required",
+		InvalidPropertyID:            "invalid property id",
+		PropertyNotFound:             "property not found",
+		PropertyCodeTaken:            "a property with that code already exists",
+		PropertyForbidden:            "your user does not belong to that property",
+		ListPropertiesFailed:         "could not list properties",
+		CreatePropertyFailed:         "could not create property",
+		AssignPropertyFailed:         "could not assign property",
+		InvalidWaitlistInput:         "invalid waitlist email, guests, room type or status",
+		RoomsAvailable:               "rooms are available for those dates; book directly",
+		WaitlistNotFound:             "waitlist entry not found",
+		WaitlistNotOffered:           "the entry has no room on hold",
+		WaitlistHoldExpired:          "the room hold has expired",
+		JoinWaitlistFailed:           "could not join the waitlist",
+		ListWaitlistFailed:           "could not list the waitlist",
+		ConfirmWaitlistFailed:        "could not confirm the waitlist booking",
+		MailOptedOut:                 "you will no longer receive booking emails",
+		InvalidOptOutToken:           "invalid email opt-out link",
+		OptOutFailed:                 "could not record the email opt-out",
+		InvalidImport:                "a channel and between 1 and 5000 valid bookings are required",
+		ImportNotFound:               "import not found",
+		ImportBookingsFailed:         "could not start the booking import",
+		GetImportFailed:              "could not retrieve the import",
+		InvalidRecurrence:            "recurrence must be daily, weekly or monthly",
+		RestoreTodoFailed:            "could not restore todo",
+		ListJobsFailed:               "could not list scheduled jobs",
+		InvalidDeadLetterFilter:      "kind must be event or email and status pending or retried",
+		DeadLetterNotFound:           "dead letter not found",
+		DeadLetterRetried:            "dead letter already retried",
+		ListDeadLettersFailed:        "could not list dead letters",
+		GetDeadLetterFailed:          "could not get dead letter",
+		RetryDeadLetterFailed:        "could not retry dead letters",
+		InvalidDashboardRange:        "invalid date range (from and to as YYYY-MM-DD, up to 366 days)",
+		DashboardFailed:              "could not build dashboard summary",
+		QuotaExceeded:                "the account reached its plan limit",
+		InvalidQuota:                 "limits must be integers greater than or equal to zero (0 means unlimited)",
+		UsageFailed:                  "could not get account usage",
+		UpdateQuotaFailed:            "could not update account limits",
+		LoginRequired:                "sign in is required",
+		InvalidErasureMode:           "use mode=gdpr to delete the account",
+		ErasureNotFound:              "erasure not found",
+		ExportFailed:                 "could not export the account data",
+		EraseAccountFailed:           "could not delete the account",
+		GetErasureFailed:             "could not retrieve the erasure",
+		AccountSuspended:             "the account is suspended, please contact support",
+		SuspendUserFailed:            "could not update the user suspension",
+		InvalidImpersonation:         "operator and reason are required",
+		ImpersonationForbidden:       "staff accounts cannot be impersonated",
+		ImpersonateFailed:            "could not issue the impersonation token",
+		SessionRevoked:               "session closed",
+		SessionNotFound:              "session not found",
+		ListSessionsFailed:           "could not list sessions",
+		RevokeSessionFailed:          "could not close the session",
+		PasskeyDeleted:               "passkey deleted",
+		PasskeyNotFound:              "passkey not found",
+		PasskeyExists:                "passkey already registered",
+		InvalidPasskeyCeremony:       "the passkey challenge expired or was already used",
+		InvalidPasskey:               "invalid passkey response",
+		PasskeyOptionsFailed:         "could not start the passkey operation",
+		SavePasskeyFailed:            "could not register the passkey",
+		ListPasskeysFailed:           "could not list passkeys",
+		DeletePasskeyFailed:          "could not delete the passkey",
+		CaptchaRequired:              "captcha required",
+		InvalidCaptcha:               "invalid captcha",
+		CaptchaUnavailable:           "could not verify the captcha, try again",
+		IPForbidden:                  "access not allowed from this IP address",
+		FaultInjected:                "fault injected for testing",
+		InvalidFaultHeader:           "invalid fault injection headers",
+		InvalidOutboxChannel:         "invalid channel, use email, alert, event, waitlist or captcha",
+		OutboxPreviewCleared:         "mock messages cleared",
+		InvalidBackup:                "invalid backup archive",
+		BackupFailed:                 "could not create the backup",
+		RestoreFailed:                "could not restore the backup",
+		RateLimited:                  "too many requests, please try again later",
+		ToggleTodosFailed:            "could not update the todos",
+		ClearCompletedFailed:         "could not delete the completed todos",
+		InvalidTodoLabel:             "invalid todo color or icon",
+		InvalidReaction:              "invalid reaction",
+		ReactionFailed:               "could not update the reaction",
+		InvalidComment:               "the comment must not be empty nor longer than 2000 characters",
+		ListCommentsFailed:           "could not list the comments",
+		CreateCommentFailed:          "could not create the comment",
+		NotificationNotFound:         "notification not found",
+		ListNotificationsFailed:      "could not list the notifications",
+		ReadNotificationFailed:       "could not mark the notification as read",
+		ApprovalForbidden:            "only the assignee can request the approval and only the owner can approve or reject",
+		ApprovalStateConflict:        "the todo does not allow this operation in its current approval status",
+		ApprovalFailed:               "could not update the approval of the todo",
+		InvalidList:                  "the list needs a name of up to 100 characters and each member an email and a valid role (viewer, contributor or admin)",
+		ListNotFound:                 "list not found",
+		ListForbidden:                "your role in the list does not allow this operation",
+		ListMemberExists:             "the user already is a member of the list",
+		ListMemberNotFound:           "the user is not a member of the list",
+		LastListAdmin:                "the list must keep at least one admin",
+		ListListsFailed:              "could not list the lists",
+		CreateListFailed:             "could not create the list",
+		UpdateListFailed:             "could not update the members of the list",
+		InvalidSSOConfig:             "the issuer must be an http(s) URL, the clientId is required and the default role must be a staff role",
+		SSONotConfigured:             "the property has no single sign-on configured",
+		InvalidSSOState:              "the single sign-on expired or was already used",
+		SSORejected:                  "the identity provider rejected the login",
+		SSOUnavailable:               "could not reach the identity provider, try again",
+		SSODisabled:                  "single sign-on disabled",
+		SSOFailed:                    "could not configure single sign-on",
+		InvalidSCIMToken:             "invalid or revoked SCIM token",
+		InvalidSCIMUser:              "the user needs a userName with a valid email and staff roles",
+		InvalidSCIMFilter:            "unsupported filter: only eq comparisons of userName, emails.value and active joined with and are supported",
+		InvalidSCIMPatch:             "only active and roles can be added or replaced",
+		SCIMTokenRevoked:             "SCIM token revoked",
+		SCIMFailed:                   "could not process the SCIM request",
+		InvalidAttachment:            "the attachment needs a name of up to 255 characters and content",
+		AttachmentTooLarge:           "the attachment exceeds the maximum size",
+		AttachmentNotFound:           "attachment not found",
+		AttachmentDeleted:            "attachment deleted",
+		SignedURLsDisabled:           "signed download links are not configured",
+		InvalidAttachmentLink:        "the download link is invalid or expired",
+		AttachmentFailed:             "could not process the attachment",
+		AttachmentScanPending:        "the attachment is still being scanned",
+		AttachmentBlocked:            "the attachment was blocked for containing malware",
+		InvalidFeedToken:             "invalid or revoked feed token",
+		FeedTokenRevoked:             "feed token revoked",
+		FeedFailed:                   "failed to build the todo feed",
+		FeedTitle:                    "todos of",
+		FeedTodoCreated:              "new todo",
+		FeedTodoCompleted:            "todo completed",
+		CalDAVForbidden:              "only your own calendar can be accessed",
+		InvalidCalendarData:          "a VTODO with a UID and a SUMMARY is expected",
+		UnsupportedReport:            "unsupported report: calendar-query and calendar-multiget are supported",
+		PreconditionFailed:           "the todo changed since the last sync",
+		CalDAVFailed:                 "failed to process the CalDAV request",
+		InvalidTodoImport:            "the export file is invalid or has more than 1000 todos",
+		UnknownImportSource:          "the import source must be todoist or google",
+		ImportTodosFailed:            "could not import the todos",
+		InvalidSummaryRange:          "invalid date range (from and to as YYYY-MM-DD, up to 92 days)",
+		WeeklyReportTitle:            "Weekly todo summary",
+		ReportCreated:                "Created",
+		ReportCompleted:              "Completed",
+		ReportPending:                "Pending",
+		ReportOverdue:                "Overdue",
+		ReportPerDay:                 "Todos per day",
+		ReportOwner:                  "Owner",
+		StatsFailed:                  "could not compute the statistics",
+		InvalidAuditQuery:            "invalid audit filters (from and to as RFC 3339 or YYYY-MM-DD, limit up to 100, cursor from a previous page)",
+		ListAuditFailed:              "could not query the audit trail",
+		InvalidClearMode:             "the only available mode is mode=anonymize",
+		AnonymizeUsersFailed:         "could not anonymize the users",
+		InvalidMerge:                 "give the email of another account to merge",
+		MergeEmailMismatch:           "only accounts with variants of the same email can be merged",
+		MergeNotVerified:             "could not verify that you own the other account",
+		MergeAccountFailed:           "could not merge the accounts",
+		InvalidEmailChange:           "give an email other than the current one",
+		EmailChangeNotVerified:       "the password is not correct",
+		InvalidEmailChangeToken:      "the link is invalid or expired",
+		EmailChangeCancelled:         "the email change was cancelled",
+		EmailChangeFailed:            "could not change the email",
+		TodoFilterRequired:           "give an email, or all=true to cover every todo",
+		ClearAllForbidden:            "only the admin token can clear every todo",
+		UnknownCollection:            "the collection does not exist or cannot be exported",
+		DumpFailed:                   "could not export the collection",
+		WebSocketRequired:            "this route only accepts WebSocket connections",
+		InvalidRealtimeTicket:        "the realtime ticket expired or was already used",
+		RealtimeTicketFailed:         "could not issue the realtime ticket",
+		ListPresenceFailed:           "could not get who is viewing the list",
+		InvalidTitleEdit:             "the edit does not fit the title at that version or leaves it blank",
+		TitleEditConflict:            "the title changed too many times at once, retry the edit",
+		TitleHistoryGone:             "an edit of that version can no longer be merged, reload the title",
+		TitleEditFailed:              "could not edit the title",
+		InvalidSyncBatch:             "invalid batch of operations (between 1 and 100 operations, each with a UUID opId)",
+		SyncOpsFailed:                "could not sync the operations",
+		InvalidClientID:              "clientId must be a UUID",
+		DuplicateClientID:            "you already have a todo with that clientId",
+	},
+}
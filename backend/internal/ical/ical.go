@@ -0,0 +1,239 @@
+// Package ical reads and writes the VTODO components of iCalendar
+// (RFC 5545) that CalDAV clients exchange. It only knows the properties
+// that todos map to; anything else a client sends is ignored.
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNoTodo is returned for data that is not an iCalendar object with a
+// VTODO.
+var ErrNoTodo = errors.New("ical: no VTODO found")
+
+// Todo is a VTODO. RRule is the recurrence rule as written, e.g.
+// "FREQ=WEEKLY".
+type Todo struct {
+	UID         string
+	Summary     string
+	Created     time.Time
+	Stamp       time.Time
+	Completed   bool
+	CompletedAt time.Time
+	RRule       string
+}
+
+const (
+	utcFormat   = "20060102T150405Z"
+	localFormat = "20060102T150405"
+	dateFormat  = "20060102"
+	// maxLineOctets is where lines are folded.
+	maxLineOctets = 75
+)
+
+// Encode writes todo as an iCalendar object produced by prodID.
+func Encode(prodID string, todo Todo) []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeFolded(&buf, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", escape(prodID))
+	line("BEGIN", "VTODO")
+	line("UID", escape(todo.UID))
+	line("DTSTAMP", todo.Stamp.UTC().Format(utcFormat))
+	if !todo.Created.IsZero() {
+		line("CREATED", todo.Created.UTC().Format(utcFormat))
+	}
+	line("SUMMARY", escape(todo.Summary))
+	if todo.Completed {
+		line("STATUS", "COMPLETED")
+		line("PERCENT-COMPLETE", "100")
+		if !todo.CompletedAt.IsZero() {
+			line("COMPLETED", todo.CompletedAt.UTC().Format(utcFormat))
+		}
+	} else {
+		line("STATUS", "NEEDS-ACTION")
+	}
+	if todo.RRule != "" {
+		line("RRULE", todo.RRule)
+	}
+	line("END", "VTODO")
+	line("END", "VCALENDAR")
+	return buf.Bytes()
+}
+
+// writeFolded writes a content line, folding it at 75 octets without
+// splitting characters.
+func writeFolded(buf *bytes.Buffer, content string) {
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		buf.WriteString(content[:cut])
+		buf.WriteString("\r\n ")
+		content = content[cut:]
+		// The leading space of the continuation takes one octet.
+		limit = maxLineOctets - 1
+	}
+	buf.WriteString(content)
+	buf.WriteString("\r\n")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+func unescape(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i == len(text)-1 {
+			out.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch text[i] {
+		case 'n', 'N':
+			out.WriteByte('\n')
+		default:
+			out.WriteByte(text[i])
+		}
+	}
+	return out.String()
+}
+
+// property is a content line split into its name and value; parameters
+// are dropped.
+type property struct {
+	name  string
+	value string
+}
+
+// parseLine splits "NAME;PARAM=x:value"; colons inside quoted parameter
+// values do not end the name.
+func parseLine(line string) (property, bool) {
+	quoted := false
+	split := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			split = i
+			break
+		}
+	}
+	if split < 0 {
+		return property{}, false
+	}
+	name, _, _ := strings.Cut(line[:split], ";")
+	return property{name: strings.ToUpper(name), value: line[split+1:]}, true
+}
+
+// unfold returns the content lines of data, joining folded lines.
+func unfold(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseTime reads UTC, floating (taken as UTC) and date values.
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range []string{utcFormat, localFormat, dateFormat} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// DecodeTodo reads the first VTODO of an iCalendar object. The components
+// nested in it, such as alarms, are skipped.
+func DecodeTodo(data []byte) (Todo, error) {
+	lines := unfold(data)
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return Todo{}, ErrNoTodo
+	}
+
+	var todo Todo
+	var status string
+	var percent int
+	inTodo, found, nested := false, false, 0
+	for _, line := range lines[1:] {
+		prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case prop.name == "BEGIN" && !inTodo && !found && strings.EqualFold(prop.value, "VTODO"):
+			inTodo = true
+			continue
+		case !inTodo:
+			continue
+		case prop.name == "BEGIN":
+			nested++
+			continue
+		case prop.name == "END" && nested > 0:
+			nested--
+			continue
+		case prop.name == "END":
+			inTodo, found = false, true
+			continue
+		case nested > 0:
+			continue
+		}
+
+		switch prop.name {
+		case "UID":
+			todo.UID = unescape(prop.value)
+		case "SUMMARY":
+			todo.Summary = unescape(prop.value)
+		case "CREATED":
+			todo.Created, _ = parseTime(prop.value)
+		case "DTSTAMP":
+			todo.Stamp, _ = parseTime(prop.value)
+		case "STATUS":
+			status = strings.ToUpper(prop.value)
+		case "COMPLETED":
+			todo.CompletedAt, _ = parseTime(prop.value)
+		case "PERCENT-COMPLETE":
+			percent, _ = strconv.Atoi(strings.TrimSpace(prop.value))
+		case "RRULE":
+			todo.RRule = strings.ToUpper(prop.value)
+		}
+	}
+	if !found {
+		return Todo{}, ErrNoTodo
+	}
+	// STATUS wins; without it a completion date or 100% completes the todo.
+	if status != "" {
+		todo.Completed = status == "COMPLETED"
+	} else {
+		todo.Completed = !todo.CompletedAt.IsZero() || percent == 100
+	}
+	if !todo.Completed {
+		todo.CompletedAt = time.Time{}
+	}
+	return todo, nil
+}
@@ -0,0 +1,258 @@
+// Package linkpreview finds the URLs in a text and reads the OpenGraph
+// metadata of the pages they point to. Pages are fetched through a client
+// that refuses private, loopback and link-local addresses, so users cannot
+// make the server reach internal services (SSRF).
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	// ErrBlockedAddress is returned for URLs that resolve to an address
+	// that is not public.
+	ErrBlockedAddress = errors.New("link preview: blocked address")
+	// ErrNotHTML is returned for URLs that do not point to an HTML page.
+	ErrNotHTML = errors.New("link preview: not an html page")
+)
+
+// maxPageBytes caps how much of a page is read looking for the metadata.
+const maxPageBytes = 512 << 10
+
+// maxTextLength caps the length of the titles and descriptions kept, in
+// characters.
+const maxTextLength = 300
+
+// maxRedirects caps the redirects followed from a URL.
+const maxRedirects = 3
+
+// Preview is the metadata shown for a URL.
+type Preview struct {
+	URL         string `json:"url" xml:"url" bson:"url"`
+	Title       string `json:"title,omitempty" xml:"title,omitempty" bson:"title,omitempty"`
+	Description string `json:"description,omitempty" xml:"description,omitempty" bson:"description,omitempty"`
+	Image       string `json:"image,omitempty" xml:"image,omitempty" bson:"image,omitempty"`
+	SiteName    string `json:"siteName,omitempty" xml:"siteName,omitempty" bson:"siteName,omitempty"`
+}
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"']+`)
+
+// FindURLs returns up to limit distinct http(s) URLs in text, in order,
+// without the punctuation that usually follows them in a sentence.
+func FindURLs(text string, limit int) []string {
+	var urls []string
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		match = strings.TrimRight(match, ".,;:!?)]}")
+		parsed, err := url.Parse(match)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		if !slices.Contains(urls, match) {
+			urls = append(urls, match)
+		}
+		if len(urls) == limit {
+			break
+		}
+	}
+	return urls
+}
+
+// blockedPrefixes are the public-looking ranges that still are not
+// reachable on the internet.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// Public reports whether addr is a public unicast address.
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// SafeClient returns an HTTP client that only connects to public
+// addresses. The check runs on the address actually dialed, after DNS
+// resolution and on every redirect, so rebinding a name does not bypass
+// it. Proxies from the environment are ignored for the same reason.
+func SafeClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !Public(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("link preview: too many redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// Fetcher reads the metadata of web pages.
+type Fetcher struct {
+	client *http.Client
+}
+
+// NewFetcher builds a fetcher around client; nil uses SafeClient with a
+// short timeout.
+func NewFetcher(client *http.Client) *Fetcher {
+	if client == nil {
+		client = SafeClient(5 * time.Second)
+	}
+	return &Fetcher{client: client}
+}
+
+// Fetch returns the preview of the HTML page at rawURL.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return Preview{}, fmt.Errorf("link preview: unsupported scheme %q", req.URL.Scheme)
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "HotelLinkPreview/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return Preview{}, ErrBlockedAddress
+		}
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return Preview{}, fmt.Errorf("link preview: %s answered %d", rawURL, resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNotHTML
+	}
+
+	preview := parse(resp.Request.URL, io.LimitReader(resp.Body, maxPageBytes))
+	preview.URL = rawURL
+	return preview, nil
+}
+
+// parse reads the OpenGraph tags of the head of a page, falling back to
+// its title and description. Relative images are resolved against base.
+func parse(base *url.URL, r io.Reader) Preview {
+	var preview Preview
+	var title, description string
+	tokens := html.NewTokenizer(r)
+	for {
+		switch tokens.Next() {
+		case html.ErrorToken:
+			return finish(base, preview, title, description)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokens.TagName()
+			switch string(name) {
+			case "body":
+				return finish(base, preview, title, description)
+			case "title":
+				if title == "" && tokens.Next() == html.TextToken {
+					title = strings.TrimSpace(string(tokens.Text()))
+				}
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				attrs := map[string]string{}
+				for {
+					key, value, more := tokens.TagAttr()
+					attrs[string(key)] = string(value)
+					if !more {
+						break
+					}
+				}
+				property := attrs["property"]
+				if property == "" {
+					property = attrs["name"]
+				}
+				property = strings.ToLower(property)
+				content := strings.TrimSpace(attrs["content"])
+				switch property {
+				case "og:title":
+					preview.Title = content
+				case "og:description":
+					preview.Description = content
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if preview.Image == "" {
+						preview.Image = content
+					}
+				case "og:site_name":
+					preview.SiteName = content
+				case "description":
+					description = content
+				}
+			}
+		}
+	}
+}
+
+func finish(base *url.URL, preview Preview, title, description string) Preview {
+	if preview.Title == "" {
+		preview.Title = title
+	}
+	if preview.Description == "" {
+		preview.Description = description
+	}
+	preview.Title, preview.Description = clip(preview.Title), clip(preview.Description)
+	if preview.Image != "" {
+		image, err := base.Parse(preview.Image)
+		if err != nil || (image.Scheme != "http" && image.Scheme != "https") {
+			preview.Image = ""
+		} else {
+			preview.Image = image.String()
+		}
+	}
+	return preview
+}
+
+func clip(text string) string {
+	if utf8.RuneCountInString(text) <= maxTextLength {
+		return text
+	}
+	return string([]rune(text)[:maxTextLength-1]) + "…"
+}
@@ -0,0 +1,73 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer delivers emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer only logs the emails; it is used when no SMTP server is
+// configured.
+type LogMailer struct{}
+
+// Send implements Mailer.
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("email para %s: %s", msg.To, msg.Subject)
+	return nil
+}
+
+// SMTPMailer sends emails through an SMTP server, authenticating with PLAIN
+// when a username is set.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer builds a mailer for the "host:port" addr sending as from.
+func NewSMTPMailer(addr, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send implements Mailer. net/smtp has no context support, so ctx is only
+// checked before connecting.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, m.format(msg))
+}
+
+// headerValue keeps line breaks in rendered subjects from injecting headers.
+var headerValue = strings.NewReplacer("\r", "", "\n", " ")
+
+func (m *SMTPMailer) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue.Replace(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,104 @@
+// Package metrics counts what the users do with the product (todos created
+// and completed, registrations, failed logins) and exposes the counters in
+// the Prometheus text format, so dashboards and alerts can be built on
+// them.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType is the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add adds n to the counter; negative values are ignored, counters never
+// go down.
+func (c *Counter) Add(n int64) {
+	if n > 0 {
+		c.value.Add(n)
+	}
+}
+
+// Value returns the current count.
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+// Registry holds the counters exposed together.
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{counters: map[string]*Counter{}}
+}
+
+// Counter returns the counter called name, creating it with help the first
+// time.
+func (r *Registry) Counter(name, help string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if counter, ok := r.counters[name]; ok {
+		return counter
+	}
+	counter := &Counter{name: name, help: help}
+	r.counters[name] = counter
+	return counter
+}
+
+// WriteTo writes every counter in the Prometheus text format, sorted by
+// name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	counters := make([]*Counter, 0, len(r.counters))
+	for _, counter := range r.counters {
+		counters = append(counters, counter)
+	}
+	r.mu.Unlock()
+	sort.Slice(counters, func(i, j int) bool { return counters[i].name < counters[j].name })
+
+	var out bytes.Buffer
+	for _, counter := range counters {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s counter\n%s %d\n",
+			counter.name, counter.help, counter.name, counter.name, counter.Value())
+	}
+	return out.WriteTo(w)
+}
+
+// ServeHTTP answers scrapes with the counters.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = r.WriteTo(w)
+}
+
+// Default is the registry served at /metrics.
+var Default = NewRegistry()
+
+// Domain counters, incremented by the handlers.
+var (
+	TodosCreated    = Default.Counter("todos_created_total", "Todos created, including imported ones.")
+	TodosCompleted  = Default.Counter("todos_completed_total", "Times todos were marked as completed.")
+	UsersRegistered = Default.Counter("users_registered_total", "Accounts registered with email and password.")
+	LoginFailures   = Default.Counter("login_failures_total", "Logins rejected for invalid credentials.")
+)
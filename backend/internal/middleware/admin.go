@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// AdminTokenHeader carries the shared secret required by admin endpoints.
+const AdminTokenHeader = "X-Admin-Token"
+
+// RequireAdminToken rejects requests whose X-Admin-Token header does not
+// match token. An empty token disables the protected endpoints entirely.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			i18n.AbortError(c, http.StatusForbidden, i18n.AdminDisabled)
+			return
+		}
+		provided := c.GetHeader(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			i18n.AbortError(c, http.StatusUnauthorized, i18n.InvalidAdminToken)
+			return
+		}
+		c.Next()
+	}
+}
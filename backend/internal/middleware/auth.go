@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// ErrInvalidToken must be returned (or wrapped) by a PrincipalResolver for
+// unknown or expired tokens; any other error is treated as a server failure.
+var ErrInvalidToken = errors.New("invalid token")
+
+// PropertyHeader names the property (hotel) a request works on.
+const PropertyHeader = "X-Property-ID"
+
+// Principal is the authenticated caller of a request. PropertyID is set for
+// staff bound to one property; SessionID identifies the session of the
+// bearer token.
+type Principal struct {
+	Email      string
+	Role       string
+	PropertyID string
+	SessionID  string
+}
+
+// PrincipalResolver maps a bearer token to its principal.
+type PrincipalResolver func(ctx context.Context, token string) (Principal, error)
+
+const (
+	principalKey = "principal"
+	propertyKey  = "property"
+)
+
+// Authenticate resolves the "Authorization: Bearer <token>" header into the
+// request principal. Requests without the header continue anonymously so
+// public endpoints keep working; a bad token is rejected with 401.
+//
+// It also settles the property of the request from the X-Property-ID
+// header. Staff bound to a property work on it when the header is absent
+// and get 403 when it names another property.
+//
+// Requests under the exempt path prefixes are left alone: their
+// Authorization header carries credentials of their own.
+func Authenticate(resolve PrincipalResolver, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		property := strings.TrimSpace(c.GetHeader(PropertyHeader))
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Set(propertyKey, property)
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			i18n.AbortError(c, http.StatusUnauthorized, i18n.InvalidSession)
+			return
+		}
+		principal, err := resolve(c.Request.Context(), token)
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			i18n.AbortError(c, http.StatusUnauthorized, i18n.InvalidSession)
+			return
+		case err != nil:
+			log.Printf("no se pudo validar la sesion: %v", err)
+			i18n.AbortError(c, http.StatusInternalServerError, i18n.InternalError)
+			return
+		}
+
+		if principal.PropertyID != "" {
+			if property != "" && property != principal.PropertyID {
+				i18n.AbortError(c, http.StatusForbidden, i18n.PropertyForbidden)
+				return
+			}
+			property = principal.PropertyID
+		}
+		c.Set(principalKey, principal)
+		c.Set(propertyKey, property)
+		c.Next()
+	}
+}
+
+// CurrentPrincipal returns the authenticated caller, if any.
+func CurrentPrincipal(c *gin.Context) (Principal, bool) {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	principal, ok := value.(Principal)
+	return principal, ok
+}
+
+// CurrentProperty returns the property the request is scoped to, or "" when
+// it works on every property.
+func CurrentProperty(c *gin.Context) string {
+	return c.GetString(propertyKey)
+}
+
+// RequireRole lets through callers with one of roles. A request carrying
+// a valid X-Admin-Token is also accepted, so the admin keeps access to
+// every staff endpoint; with an empty adminToken only roles are checked.
+func RequireRole(adminToken string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if provided := c.GetHeader(AdminTokenHeader); provided != "" && adminToken != "" {
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) != 1 {
+				i18n.AbortError(c, http.StatusUnauthorized, i18n.InvalidAdminToken)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			i18n.AbortError(c, http.StatusUnauthorized, i18n.AuthRequired)
+			return
+		}
+		for _, role := range roles {
+			if principal.Role == role {
+				c.Next()
+				return
+			}
+		}
+		i18n.AbortError(c, http.StatusForbidden, i18n.RoleForbidden)
+	}
+}
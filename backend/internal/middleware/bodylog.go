@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+const skipBodyLogKey = "middleware.skipBodyLog"
+
+// redactedKeys are matched case-insensitively as substrings of JSON keys.
+var redactedKeys = []string{"password", "token", "secret", "authorization"}
+
+// BodyLogConfig tunes the debug body logger.
+type BodyLogConfig struct {
+	// MaxBytes truncates each logged body; zero means 2048.
+	MaxBytes int
+	// SkipRoutes lists "METHOD /path" routes whose bodies are never logged.
+	SkipRoutes []string
+}
+
+// BodyLogger logs request and response bodies with sensitive fields
+// redacted. It is meant for debugging integration problems; routes can opt
+// out through SkipRoutes or by adding NoBodyLog to their handler chain.
+func BodyLogger(cfg BodyLogConfig) gin.HandlerFunc {
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 2048
+	}
+	skip := make(map[string]bool, len(cfg.SkipRoutes))
+	for _, route := range cfg.SkipRoutes {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		if skip[route] {
+			c.Next()
+			return
+		}
+
+		var requestBody []byte
+		if c.Request.Body != nil {
+			requestBody, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+		}
+
+		recorder := &teeWriter{ResponseWriter: c.Writer, limit: maxBytes * 4}
+		c.Writer = recorder
+
+		c.Next()
+
+		if c.GetBool(skipBodyLogKey) {
+			return
+		}
+		log.Printf("[debug] %s %s -> %d request=%s response=%s",
+			c.Request.Method, c.Request.URL.Path, c.Writer.Status(),
+			RedactBody(requestBody, maxBytes), RedactBody(recorder.body.Bytes(), maxBytes))
+	}
+}
+
+// BodyLog is a BodyLogger whose configuration can be replaced at runtime,
+// e.g. when the configuration is reloaded.
+type BodyLog struct {
+	handler atomic.Pointer[gin.HandlerFunc]
+}
+
+// NewBodyLog builds the logger with cfg; nil leaves it off.
+func NewBodyLog(cfg *BodyLogConfig) *BodyLog {
+	b := &BodyLog{}
+	b.Set(cfg)
+	return b
+}
+
+// Set replaces the configuration; nil turns the logger off.
+func (b *BodyLog) Set(cfg *BodyLogConfig) {
+	if cfg == nil {
+		b.handler.Store(nil)
+		return
+	}
+	handler := BodyLogger(*cfg)
+	b.handler.Store(&handler)
+}
+
+// Handler logs the bodies with the configuration in use.
+func (b *BodyLog) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if handler := b.handler.Load(); handler != nil {
+			(*handler)(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// NoBodyLog marks the current route so BodyLogger does not log its bodies.
+func NoBodyLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(skipBodyLogKey, true)
+		c.Next()
+	}
+}
+
+// RedactBody replaces sensitive JSON fields with "[REDACTED]" and truncates
+// the result to maxBytes. Non-JSON payloads are summarised by size only.
+func RedactBody(body []byte, maxBytes int) string {
+	if len(body) == 0 {
+		return "-"
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("[%d bytes no JSON]", len(body))
+	}
+	encoded, err := json.Marshal(redact(value))
+	if err != nil {
+		return fmt.Sprintf("[%d bytes]", len(body))
+	}
+	if len(encoded) > maxBytes {
+		return string(encoded[:maxBytes]) + fmt.Sprintf("...(%d bytes)", len(encoded))
+	}
+	return string(encoded)
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if isSensitiveKey(key) {
+				v[key] = "[REDACTED]"
+				continue
+			}
+			v[key] = redact(inner)
+		}
+		return v
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redact(inner)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range redactedKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// teeWriter copies up to limit bytes of the response while writing it.
+type teeWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *teeWriter) capture(data []byte) {
+	if room := w.limit - w.body.Len(); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.body.Write(data)
+	}
+}
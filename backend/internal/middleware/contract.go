@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// Contract validation modes.
+const (
+	// ContractLog logs responses that do not match the spec.
+	ContractLog = "log"
+	// ContractFail additionally replaces them with a 500 so tests break.
+	ContractFail = "fail"
+)
+
+// ContractValidator checks every JSON response of a documented route against
+// the OpenAPI spec. It buffers responses, so it is meant for test and QA
+// builds only; undocumented routes and non-JSON representations pass through,
+// and so do server-sent event streams, which never end.
+func ContractValidator(spec []byte, mode string) (gin.HandlerFunc, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		route, params, err := router.FindRoute(c.Request)
+		if err != nil || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, header: make(http.Header)}
+		c.Writer = buffered
+		defer func() { c.Writer = original }()
+
+		c.Next()
+
+		c.Writer = original
+		if err := validateResponse(c.Request, route, params, buffered); err != nil {
+			log.Printf("respuesta fuera de contrato %s %s (%d): %v", c.Request.Method, c.Request.URL.Path, buffered.Status(), err)
+			if mode == ContractFail {
+				i18n.AbortError(c, http.StatusInternalServerError, i18n.ContractViolation)
+				return
+			}
+		}
+		buffered.flush()
+	}, nil
+}
+
+func validateResponse(req *http.Request, route *routers.Route, params map[string]string, w *bufferedWriter) error {
+	mediaType, _, _ := mime.ParseMediaType(w.header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	return openapi3filter.ValidateResponse(req.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: &openapi3filter.RequestValidationInput{
+			Request:    req,
+			PathParams: params,
+			Route:      route,
+		},
+		Status:  w.Status(),
+		Header:  w.header,
+		Body:    io.NopCloser(bytes.NewReader(w.body.Bytes())),
+		Options: &openapi3filter.Options{IncludeResponseStatus: true},
+	})
+}
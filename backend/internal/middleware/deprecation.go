@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"maps"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response headers that announce a deprecation (RFC 9745 and RFC 8594).
+const (
+	DeprecationHeader = "Deprecation"
+	SunsetHeader      = "Sunset"
+)
+
+// maxDeprecationClients caps the clients tracked per deprecation so a
+// flood of addresses cannot grow the registry without bound.
+const maxDeprecationClients = 100
+
+const deprecationsKey = "deprecations"
+
+// Deprecation describes an endpoint, parameter or field being retired.
+type Deprecation struct {
+	// Name identifies it in the code and in the usage report.
+	Name string
+	// Since is when it was deprecated.
+	Since time.Time
+	// Sunset is when it stops working; zero while undecided.
+	Sunset time.Time
+	// Link points to the migration notes.
+	Link string
+}
+
+// DeprecationUsage is how much a deprecation is still used.
+type DeprecationUsage struct {
+	Deprecation
+	Count      int64
+	LastUsedAt time.Time
+	// Clients counts the uses per client IP, up to maxDeprecationClients
+	// addresses.
+	Clients map[string]int64
+}
+
+// Deprecations is the registry of what the API is retiring. Requests that
+// use a deprecation get the Deprecation, Sunset and Link headers and are
+// counted, so it can be removed once nobody calls it anymore.
+type Deprecations struct {
+	mu    sync.Mutex
+	now   func() time.Time
+	order []string
+	usage map[string]*DeprecationUsage
+}
+
+// NewDeprecations builds a registry of deprecations; now stamps the last
+// use and defaults to time.Now.
+func NewDeprecations(now func() time.Time, deprecations ...Deprecation) *Deprecations {
+	if now == nil {
+		now = time.Now
+	}
+	d := &Deprecations{now: now, usage: make(map[string]*DeprecationUsage, len(deprecations))}
+	for _, deprecation := range deprecations {
+		d.order = append(d.order, deprecation.Name)
+		d.usage[deprecation.Name] = &DeprecationUsage{Deprecation: deprecation, Clients: map[string]int64{}}
+	}
+	return d
+}
+
+// Middleware makes the registry available to Deprecated.
+func (d *Deprecations) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(deprecationsKey, d)
+		c.Next()
+	}
+}
+
+// Route marks every request of a route as using the deprecation name.
+func (d *Deprecations) Route(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		d.use(c, name)
+		c.Next()
+	}
+}
+
+// Deprecated marks the current request as using the deprecation name, for
+// handlers that detect a deprecated parameter or field. It has to be
+// called before the response is written; unknown names are ignored.
+func Deprecated(c *gin.Context, name string) {
+	value, _ := c.Get(deprecationsKey)
+	if d, ok := value.(*Deprecations); ok {
+		d.use(c, name)
+	}
+}
+
+func (d *Deprecations) use(c *gin.Context, name string) {
+	d.mu.Lock()
+	usage, ok := d.usage[name]
+	if ok {
+		usage.Count++
+		usage.LastUsedAt = d.now().UTC()
+		client := c.ClientIP()
+		if _, tracked := usage.Clients[client]; tracked || len(usage.Clients) < maxDeprecationClients {
+			usage.Clients[client]++
+		}
+	}
+	d.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	c.Header(DeprecationHeader, "@"+strconv.FormatInt(usage.Since.Unix(), 10))
+	if !usage.Sunset.IsZero() {
+		c.Header(SunsetHeader, usage.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if usage.Link != "" {
+		c.Writer.Header().Add("Link", "<"+usage.Link+`>; rel="deprecation"`)
+	}
+}
+
+// Usage reports every deprecation in registration order.
+func (d *Deprecations) Usage() []DeprecationUsage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DeprecationUsage, 0, len(d.order))
+	for _, name := range d.order {
+		usage := *d.usage[name]
+		usage.Clients = maps.Clone(usage.Clients)
+		out = append(out, usage)
+	}
+	return out
+}
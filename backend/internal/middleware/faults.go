@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// Request headers that inject a fault into that request alone.
+const (
+	FaultDelayHeader  = "X-Fault-Delay"
+	FaultStatusHeader = "X-Fault-Status"
+	FaultDropHeader   = "X-Fault-Drop"
+	FaultRateHeader   = "X-Fault-Rate"
+)
+
+// FaultHeaders lists the fault request headers, for CORS.
+var FaultHeaders = []string{FaultDelayHeader, FaultStatusHeader, FaultDropHeader, FaultRateHeader}
+
+// Faults describes the faults injected into a share of the requests.
+type Faults struct {
+	// Rate is the percentage (0-100) of the requests that get the faults.
+	Rate float64
+	// Delay is added before the request is served.
+	Delay time.Duration
+	// Status, when set, answers the request with that 5xx status instead.
+	Status int
+	// Drop closes the connection without answering.
+	Drop bool
+}
+
+// Valid reports whether the faults can be injected.
+func (f Faults) Valid() bool {
+	return f.Rate >= 0 && f.Rate <= 100 && f.Delay >= 0 &&
+		(f.Status == 0 || (f.Status >= 500 && f.Status <= 599))
+}
+
+func (f Faults) active() bool {
+	return f.Rate > 0 && (f.Delay > 0 || f.Status != 0 || f.Drop)
+}
+
+// FaultInjector slows down, fails or drops a share of the requests so the
+// frontend and the E2E suite can exercise their retry paths. The faults
+// are set for every request through the admin API or for a single one
+// with the X-Fault-* headers. It is meant for test environments only.
+type FaultInjector struct {
+	mu     sync.RWMutex
+	faults Faults
+	random func() float64
+}
+
+// NewFaultInjector builds an injector with no faults. random returns a
+// number in [0, 1) and decides which requests are hit; nil uses
+// math/rand.
+func NewFaultInjector(random func() float64) *FaultInjector {
+	if random == nil {
+		random = rand.Float64
+	}
+	return &FaultInjector{random: random}
+}
+
+// Faults returns the faults injected into every request.
+func (f *FaultInjector) Faults() Faults {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.faults
+}
+
+// Set replaces the faults injected into every request.
+func (f *FaultInjector) Set(faults Faults) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = faults
+}
+
+// Middleware injects the faults. The X-Fault-* headers override the shared
+// faults for their request, with a rate of 100 unless X-Fault-Rate is
+// sent. Paths under any of the exempt prefixes (like the admin API, which
+// turns the faults off again) are always served.
+func (f *FaultInjector) Middleware(exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		faults, ok := requestFaults(c.Request.Header, f.Faults())
+		if !ok {
+			i18n.AbortError(c, http.StatusBadRequest, i18n.InvalidFaultHeader)
+			return
+		}
+		if !faults.active() || f.random()*100 >= faults.Rate {
+			c.Next()
+			return
+		}
+
+		if faults.Delay > 0 {
+			timer := time.NewTimer(faults.Delay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+			}
+		}
+		switch {
+		case faults.Drop:
+			// net/http closes the connection without answering and
+			// without logging a stack trace.
+			panic(http.ErrAbortHandler)
+		case faults.Status != 0:
+			i18n.AbortError(c, faults.Status, i18n.FaultInjected)
+		default:
+			c.Next()
+		}
+	}
+}
+
+// requestFaults applies the X-Fault-* headers over the shared faults.
+func requestFaults(header http.Header, shared Faults) (Faults, bool) {
+	delay, status, drop, rate := header.Get(FaultDelayHeader), header.Get(FaultStatusHeader),
+		header.Get(FaultDropHeader), header.Get(FaultRateHeader)
+	if delay == "" && status == "" && drop == "" {
+		return shared, true
+	}
+
+	faults := Faults{Rate: 100}
+	if delay != "" {
+		ms, err := strconv.Atoi(delay)
+		if err != nil {
+			return Faults{}, false
+		}
+		faults.Delay = time.Duration(ms) * time.Millisecond
+	}
+	if status != "" {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return Faults{}, false
+		}
+		faults.Status = code
+	}
+	if drop != "" {
+		dropped, err := strconv.ParseBool(drop)
+		if err != nil {
+			return Faults{}, false
+		}
+		faults.Drop = dropped
+	}
+	if rate != "" {
+		percent, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return Faults{}, false
+		}
+		faults.Rate = percent
+	}
+	return faults, faults.Valid()
+}
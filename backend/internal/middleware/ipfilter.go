@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// IPRules restricts the client IPs that reach a set of routes. Deny wins
+// over Allow; a non-empty Allow admits only the listed ranges. Empty rules
+// admit everyone.
+type IPRules struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// ParseIPRules parses CIDRs (e.g. "10.8.0.0/16") or single addresses.
+func ParseIPRules(allow, deny []string) (IPRules, error) {
+	var rules IPRules
+	var err error
+	if rules.Allow, err = parsePrefixes(allow); err != nil {
+		return IPRules{}, err
+	}
+	if rules.Deny, err = parsePrefixes(deny); err != nil {
+		return IPRules{}, err
+	}
+	return rules, nil
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("direccion IP invalida %q: %w", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("rango CIDR invalido %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Empty reports whether the rules admit every client.
+func (r IPRules) Empty() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0
+}
+
+// Allows reports whether ip may pass.
+func (r IPRules) Allows(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range r.Deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, prefix := range r.Allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFilter answers 403 to clients outside rules. The client IP comes from
+// c.ClientIP(), so forwarding headers count only from trusted proxies.
+func IPFilter(rules IPRules) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rules.Empty() {
+			c.Next()
+			return
+		}
+		ip, err := netip.ParseAddr(c.ClientIP())
+		if err != nil || !rules.Allows(ip) {
+			i18n.AbortError(c, http.StatusForbidden, i18n.IPForbidden)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// MaintenanceMode is a process-wide switch that rejects mutating requests
+// while reads keep working, e.g. during migrations or Mongo failovers.
+type MaintenanceMode struct {
+	enabled    atomic.Bool
+	retryAfter time.Duration
+}
+
+// NewMaintenanceMode builds the switch with its initial state and the
+// Retry-After hint sent to rejected clients.
+func NewMaintenanceMode(enabled bool, retryAfter time.Duration) *MaintenanceMode {
+	m := &MaintenanceMode{retryAfter: retryAfter}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether maintenance mode is active.
+func (m *MaintenanceMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set turns maintenance mode on or off.
+func (m *MaintenanceMode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Guard answers 503 to mutating requests while maintenance mode is on. Paths
+// under any of the exempt prefixes (like the admin API) are always served.
+func (m *MaintenanceMode) Guard(exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.Enabled() || isReadOnlyMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(m.retryAfter.Seconds())))
+		i18n.AbortError(c, http.StatusServiceUnavailable, i18n.Maintenance)
+	}
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
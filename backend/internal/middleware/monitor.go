@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+)
+
+// Monitor records the status of every response in monitor, for the error
+// rate alerts; a nil monitor does nothing.
+func Monitor(monitor *alerts.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if monitor != nil {
+			monitor.Request(c.Writer.Status())
+		}
+	}
+}
+
+// MonitorLogins records the logins of the route rejected with 401 in
+// monitor, for the failed login alerts; a nil monitor does nothing.
+func MonitorLogins(monitor *alerts.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if monitor != nil && c.Writer.Status() == http.StatusUnauthorized {
+			monitor.LoginFailed()
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+const objectIDKeyPrefix = "objectId:"
+
+// ObjectIDParam parses the path parameter name as a MongoDB ObjectID and
+// stores it for GetObjectID, answering 400 INVALID_ID when it is malformed.
+func ObjectIDParam(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param(name))
+		if err != nil {
+			i18n.AbortError(c, http.StatusBadRequest, i18n.InvalidID)
+			return
+		}
+		SetObjectID(c, name, id)
+		c.Next()
+	}
+}
+
+// SetObjectID stores id as the path parameter name, for the middlewares
+// that resolve the parameter some other way.
+func SetObjectID(c *gin.Context, name string, id primitive.ObjectID) {
+	c.Set(objectIDKeyPrefix+name, id)
+}
+
+// GetObjectID returns the path parameter name parsed by ObjectIDParam, or
+// the zero ObjectID when the route does not use it.
+func GetObjectID(c *gin.Context, name string) primitive.ObjectID {
+	id, _ := c.Get(objectIDKeyPrefix + name)
+	objID, _ := id.(primitive.ObjectID)
+	return objID
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"slices"
+	"sync/atomic"
+)
+
+// Origins is the list of frontend origins allowed by CORS. It can be
+// replaced at runtime, e.g. when the configuration is reloaded.
+type Origins struct {
+	list atomic.Pointer[[]string]
+}
+
+// NewOrigins builds the list with origins.
+func NewOrigins(origins []string) *Origins {
+	o := &Origins{}
+	o.Set(origins)
+	return o
+}
+
+// Set replaces the allowed origins.
+func (o *Origins) Set(origins []string) {
+	origins = slices.Clone(origins)
+	o.list.Store(&origins)
+}
+
+// Allowed reports whether origin may call the API.
+func (o *Origins) Allowed(origin string) bool {
+	return slices.Contains(*o.list.Load(), origin)
+}
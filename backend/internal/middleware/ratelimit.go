@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// Response headers describing the request budget of the caller.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+)
+
+// RateLimitHeaders lists the rate limit response headers, for CORS.
+var RateLimitHeaders = []string{RateLimitLimitHeader, RateLimitRemainingHeader, RateLimitResetHeader, "Retry-After"}
+
+// Rate limit tiers, used in the keys of the store.
+const (
+	TierAnonymous = "anonymous"
+	TierUser      = "user"
+	TierAdmin     = "admin"
+)
+
+// RateLimits are the requests each kind of caller may make per Window; a
+// tier set to 0 is not limited.
+type RateLimits struct {
+	Window    time.Duration
+	Anonymous int
+	User      int
+	Admin     int
+}
+
+func (l RateLimits) limit(tier string) int {
+	switch tier {
+	case TierAdmin:
+		return l.Admin
+	case TierUser:
+		return l.User
+	default:
+		return l.Anonymous
+	}
+}
+
+// RateLimitResult is the outcome of taking a request from a budget.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is when the current window ends.
+	Reset time.Time
+}
+
+// RateLimitStore keeps the request counts of the callers. It is shared by
+// every instance behind a load balancer when it lives outside the process
+// (e.g. in Redis); MemoryRateLimitStore counts per instance.
+type RateLimitStore interface {
+	// Take counts a request of key against limit requests per window and
+	// reports whether it is allowed; rejected requests are not counted.
+	Take(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimiter enforces a request budget per caller: signed-in users are
+// counted by email, requests with the admin token together, and anonymous
+// requests by client IP.
+type RateLimiter struct {
+	limits atomic.Pointer[RateLimits]
+	store  RateLimitStore
+	now    func() time.Time
+}
+
+// NewRateLimiter builds a limiter over store; nil uses a
+// MemoryRateLimitStore and a nil now uses time.Now.
+func NewRateLimiter(limits RateLimits, store RateLimitStore, now func() time.Time) *RateLimiter {
+	if now == nil {
+		now = time.Now
+	}
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	r := &RateLimiter{store: store, now: now}
+	r.SetLimits(limits)
+	return r
+}
+
+// Limits returns the budgets in force.
+func (r *RateLimiter) Limits() RateLimits {
+	return *r.limits.Load()
+}
+
+// SetLimits replaces the budgets, e.g. on a configuration reload. Counts
+// already taken are kept.
+func (r *RateLimiter) SetLimits(limits RateLimits) {
+	r.limits.Store(&limits)
+}
+
+// Handler enforces the budgets after Authenticate, answering 429 with
+// Retry-After once the caller runs out. A valid X-Admin-Token puts the
+// request in the admin tier. Paths under any of the exempt prefixes (like
+// the health check) are not counted. If the store fails the request goes
+// through, so an outage of the store does not take the API down.
+func (r *RateLimiter) Handler(adminToken string, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits := r.Limits()
+		for _, prefix := range exempt {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		tier, caller := rateLimitCaller(c, adminToken)
+		limit := limits.limit(tier)
+		if limits.Window <= 0 || limit <= 0 {
+			c.Next()
+			return
+		}
+
+		now := r.now()
+		result, err := r.store.Take(c.Request.Context(), tier+":"+caller, limit, limits.Window, now)
+		if err != nil {
+			log.Printf("no se pudo consultar el limite de solicitudes: %v", err)
+			c.Next()
+			return
+		}
+		reset := max(int(math.Ceil(result.Reset.Sub(now).Seconds())), 1)
+		c.Header(RateLimitLimitHeader, strconv.Itoa(limit))
+		c.Header(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+		c.Header(RateLimitResetHeader, strconv.Itoa(reset))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(reset))
+			i18n.AbortError(c, http.StatusTooManyRequests, i18n.RateLimited)
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimitCaller(c *gin.Context, adminToken string) (tier, caller string) {
+	if provided := c.GetHeader(AdminTokenHeader); provided != "" && adminToken != "" &&
+		subtle.ConstantTimeCompare([]byte(provided), []byte(adminToken)) == 1 {
+		return TierAdmin, "token"
+	}
+	if principal, ok := CurrentPrincipal(c); ok {
+		return TierUser, principal.Email
+	}
+	return TierAnonymous, c.ClientIP()
+}
+
+// MemoryRateLimitStore is a sliding window counter kept in memory: the
+// count of the current fixed window plus the previous one weighted by how
+// much of it still overlaps the sliding window. It needs two counters per
+// caller instead of a timestamp per request.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+// NewMemoryRateLimitStore builds an empty store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{windows: map[string]*rateWindow{}}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit int, window time.Duration, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(window, now)
+
+	start := now.Truncate(window)
+	w, ok := s.windows[key]
+	switch {
+	case !ok:
+		w = &rateWindow{start: start}
+		s.windows[key] = w
+	case w.start.Add(window).Equal(start):
+		w.start, w.previous, w.current = start, w.current, 0
+	case !w.start.Equal(start):
+		w.start, w.previous, w.current = start, 0, 0
+	}
+
+	overlap := 1 - float64(now.Sub(start))/float64(window)
+	used := float64(w.previous)*overlap + float64(w.current)
+	result := RateLimitResult{Reset: start.Add(window)}
+	if used+1 > float64(limit) {
+		return result, nil
+	}
+	w.current++
+	result.Allowed = true
+	result.Remaining = int(float64(limit) - used - 1)
+	return result, nil
+}
+
+// sweep forgets, at most once per window, the callers idle for two
+// windows, whose counts no longer matter.
+func (s *MemoryRateLimitStore) sweep(window time.Duration, now time.Time) {
+	if now.Sub(s.lastSweep) < window {
+		return
+	}
+	s.lastSweep = now
+	for key, w := range s.windows {
+		if now.Sub(w.start) >= 2*window {
+			delete(s.windows, key)
+		}
+	}
+}
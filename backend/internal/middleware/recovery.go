@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// Recovery turns panics into the standard 500 error body, logs the stack
+// trace with the request ID and notifies the alert hook asynchronously.
+func Recovery(notifier alerts.Notifier) gin.HandlerFunc {
+	if notifier == nil {
+		notifier = alerts.NopNotifier{}
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			if recovered == http.ErrAbortHandler {
+				// A deliberate abort: let net/http drop the connection.
+				panic(recovered)
+			}
+			if isBrokenPipe(recovered) {
+				// The client is gone; there is nobody to answer.
+				c.Abort()
+				return
+			}
+
+			requestID := GetRequestID(c)
+			log.Printf("panic recuperado [request %s] %s %s: %v\n%s",
+				requestID, c.Request.Method, c.Request.URL.Path, recovered, debug.Stack())
+
+			alert := alerts.Alert{
+				Title:     "Panic en la API",
+				Message:   fmt.Sprintf("%s %s: %v", c.Request.Method, c.Request.URL.Path, recovered),
+				RequestID: requestID,
+				Time:      time.Now(),
+			}
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				if err := notifier.Notify(ctx, alert); err != nil {
+					log.Printf("no se pudo enviar la alerta de panic: %v", err)
+				}
+			}()
+
+			if c.Writer.Written() {
+				// The response already left, e.g. the 504 of Timeout.
+				c.Abort()
+				return
+			}
+			i18n.AbortError(c, http.StatusInternalServerError, i18n.InternalError)
+		}()
+		c.Next()
+	}
+}
+
+func isBrokenPipe(recovered interface{}) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		return errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
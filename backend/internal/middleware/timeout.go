@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+)
+
+// Timeout bounds every request with a deadline. The budget is looked up in
+// overrides by "METHOD /route/pattern" (e.g. "GET /todos") and falls back to
+// fallback; a non-positive budget disables the deadline for that route.
+//
+// The rest of the chain runs in its own goroutine writing into a buffer.
+// When the deadline fires first the client gets a 504 right away and the
+// handler's later writes are dropped with http.ErrHandlerTimeout; the
+// deadline also travels through the request context so MongoDB calls
+// abort. The middleware still waits for the handler to return before
+// releasing the context, which gin reuses for other requests.
+func Timeout(fallback time.Duration, overrides map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		budget := fallback
+		if d, ok := overrides[c.Request.Method+" "+c.FullPath()]; ok {
+			budget = d
+		}
+		if budget <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		// The 504 is rendered from a snapshot, as c belongs to the handler
+		// goroutine until it returns.
+		late := c.Copy()
+		buffered := &bufferedWriter{ResponseWriter: original, header: make(http.Header)}
+		c.Writer = buffered
+
+		finished := make(chan any, 1)
+		go func() {
+			defer func() { finished <- recover() }()
+			c.Next()
+		}()
+
+		var recovered any
+		select {
+		case recovered = <-finished:
+			c.Writer = original
+			if recovered == nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				buffered.flush()
+				return
+			}
+		case <-ctx.Done():
+			if buffered.expire() {
+				answerTimeout(late, original)
+			}
+			recovered = <-finished
+			c.Writer = original
+		}
+		if recovered != nil {
+			// Re-raised here so the recovery middleware handles it.
+			panic(recovered)
+		}
+		if !original.Written() {
+			i18n.AbortError(c, http.StatusGatewayTimeout, i18n.RequestTimeout)
+			return
+		}
+		c.Abort()
+	}
+}
+
+// answerTimeout sends the 504 through the real writer. The body goes out
+// with its length and flushed, so the client has it while the handler is
+// still running.
+func answerTimeout(c *gin.Context, original gin.ResponseWriter) {
+	response := &bufferedWriter{ResponseWriter: original, header: original.Header()}
+	c.Writer = response
+	i18n.AbortError(c, http.StatusGatewayTimeout, i18n.RequestTimeout)
+	response.header.Set("Content-Length", strconv.Itoa(response.body.Len()))
+	response.flush()
+	original.Flush()
+}
+
+// bufferedWriter holds the handler output until the middleware decides
+// whether it can be sent.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header http.Header
+
+	mu      sync.Mutex
+	body    bytes.Buffer
+	status  int
+	expired bool
+}
+
+// expire drops the writes still to come and reports whether the handler
+// had not finished yet.
+func (w *bufferedWriter) expire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return false
+	}
+	w.expired = true
+	return true
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeader(code)
+}
+
+func (w *bufferedWriter) writeHeader(code int) {
+	if code > 0 && w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.writeHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status != 0
+}
+
+// Flush is a no-op: nothing reaches the client before the handler finishes.
+func (w *bufferedWriter) Flush() {}
+
+func (w *bufferedWriter) flush() {
+	dst := w.ResponseWriter.Header()
+	for key, values := range w.header {
+		dst[key] = values
+	}
+	if w.status == 0 {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
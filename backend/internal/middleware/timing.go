@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/timing"
+)
+
+// ServerTiming times the database calls and the serialization of every
+// request and reports them, with the total, in the Server-Timing header.
+// The header shows how the API spends its time, so enable it only while
+// profiling.
+func ServerTiming() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recorder := timing.NewRecorder()
+		c.Request = c.Request.WithContext(timing.NewContext(c.Request.Context(), recorder))
+		c.Writer = &timingWriter{ResponseWriter: c.Writer, recorder: recorder}
+		c.Next()
+	}
+}
+
+// timingWriter adds the Server-Timing header right before the response
+// starts.
+type timingWriter struct {
+	gin.ResponseWriter
+	recorder *timing.Recorder
+}
+
+func (w *timingWriter) stamp() {
+	if !w.Written() {
+		w.Header().Set(timing.Header, w.recorder.Header())
+	}
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.stamp()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,146 @@
+// Package mock replaces the outbound integrations (mailer, alerts, event
+// brokers and webhooks, waitlist offers and the CAPTCHA provider) with
+// implementations that only record what would have been sent, so test
+// environments and the E2E suite can inspect it without reaching any
+// external service.
+package mock
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/captcha"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// Channels of the recorded messages.
+const (
+	ChannelEmail    = "email"
+	ChannelAlert    = "alert"
+	ChannelEvent    = "event"
+	ChannelWaitlist = "waitlist"
+	ChannelCaptcha  = "captcha"
+)
+
+// Channels lists every channel, for validation.
+var Channels = []string{ChannelEmail, ChannelAlert, ChannelEvent, ChannelWaitlist, ChannelCaptcha}
+
+// Message is something an integration would have sent: To is the
+// recipient (an email address, a broker or a webhook URL) and Payload the
+// value it would have carried.
+type Message struct {
+	Channel string    `json:"channel"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Payload any       `json:"payload"`
+	Time    time.Time `json:"time"`
+}
+
+// Recorder keeps the last messages of the mock integrations, oldest
+// first. It is safe for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+	capacity int
+	now      func() time.Time
+}
+
+// NewRecorder builds a recorder that keeps the last capacity messages
+// (100 when not positive).
+func NewRecorder(capacity int, now func() time.Time) *Recorder {
+	if capacity <= 0 {
+		capacity = 100
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &Recorder{capacity: capacity, now: now}
+}
+
+// Record stores a message, dropping the oldest one when full.
+func (r *Recorder) Record(channel, to, subject string, payload any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.messages) == r.capacity {
+		r.messages = slices.Delete(r.messages, 0, 1)
+	}
+	r.messages = append(r.messages, Message{Channel: channel, To: to, Subject: subject, Payload: payload, Time: r.now()})
+}
+
+// Messages returns the recorded messages of channel, or of every channel
+// when it is empty.
+func (r *Recorder) Messages(channel string) []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := make([]Message, 0, len(r.messages))
+	for _, message := range r.messages {
+		if channel == "" || message.Channel == channel {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Clear forgets every recorded message.
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = nil
+}
+
+// Mailer records emails instead of sending them.
+type Mailer struct{ Recorder *Recorder }
+
+// Send implements mailer.Mailer.
+func (m Mailer) Send(_ context.Context, msg mailer.Message) error {
+	m.Recorder.Record(ChannelEmail, msg.To, msg.Subject, msg)
+	return nil
+}
+
+// Notifier records alerts instead of posting them.
+type Notifier struct{ Recorder *Recorder }
+
+// Notify implements alerts.Notifier.
+func (n Notifier) Notify(_ context.Context, alert alerts.Alert) error {
+	n.Recorder.Record(ChannelAlert, "alerts", alert.Title, alert)
+	return nil
+}
+
+// Publisher records events instead of publishing them; To names the broker
+// or webhook URL that would have received them.
+type Publisher struct {
+	Recorder *Recorder
+	To       string
+}
+
+// Publish implements events.Publisher.
+func (p Publisher) Publish(_ context.Context, event events.Event) error {
+	p.Recorder.Record(ChannelEvent, p.To, event.Type, event)
+	return nil
+}
+
+// WaitlistNotifier records the room offers instead of delivering them.
+type WaitlistNotifier struct{ Recorder *Recorder }
+
+// NotifyWaitlist implements services.WaitlistNotifier.
+func (n WaitlistNotifier) NotifyWaitlist(_ context.Context, entry services.WaitlistEntry, hold services.Booking) error {
+	n.Recorder.Record(ChannelWaitlist, entry.Email, "habitacion reservada", map[string]any{"entry": entry, "hold": hold})
+	return nil
+}
+
+// Verifier records the CAPTCHA tokens and accepts every non-empty one.
+type Verifier struct{ Recorder *Recorder }
+
+// Verify implements captcha.Verifier.
+func (v Verifier) Verify(_ context.Context, token, remoteIP string) error {
+	v.Recorder.Record(ChannelCaptcha, remoteIP, "siteverify", map[string]string{"token": token})
+	if token == "" {
+		return captcha.ErrRejected
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Package oidc signs users in through an external OpenID Connect identity
+// provider with the authorization code flow: it reads the provider
+// metadata from its discovery document, exchanges the code for an ID token
+// and verifies the token (RS256 or ES256) against the published keys, on
+// top of the standard library.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrRejected is returned when the provider refuses the code or the ID
+// token does not verify. Other errors mean the provider could not be
+// reached or answered garbage.
+var ErrRejected = errors.New("oidc login rejected")
+
+// DiscoveryPath is where providers publish their metadata, relative to the
+// issuer.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// clockSkew is the leeway allowed on the expiry of ID tokens.
+const clockSkew = time.Minute
+
+// Config is a client registered at a provider. Issuer is the issuer URL or
+// its discovery URL.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+}
+
+// issuer returns the issuer URL of c, without the discovery path.
+func (c Config) issuer() string {
+	return strings.TrimSuffix(strings.TrimSuffix(c.Issuer, DiscoveryPath), "/")
+}
+
+// Metadata are the endpoints of a provider read from its discovery
+// document.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a verified ID token used to sign in.
+type Claims struct {
+	Subject string
+	Email   string
+}
+
+// Client talks to the providers.
+type Client struct {
+	http *http.Client
+}
+
+// NewClient builds a client; a nil client uses a default one with a short
+// timeout.
+func NewClient(client *http.Client) *Client {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{http: client}
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s answered %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Discover reads the metadata of the provider of cfg. The issuer it
+// announces must be the configured one.
+func (c *Client) Discover(ctx context.Context, cfg Config) (Metadata, error) {
+	var meta Metadata
+	if err := c.getJSON(ctx, cfg.issuer()+DiscoveryPath, &meta); err != nil {
+		return Metadata{}, err
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != cfg.issuer() || meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return Metadata{}, fmt.Errorf("oidc: invalid discovery document of %s", cfg.issuer())
+	}
+	return meta, nil
+}
+
+// AuthorizationURL is where the browser goes to sign in at the provider;
+// it comes back to redirectURI with the code and state.
+func AuthorizationURL(meta Metadata, cfg Config, redirectURI, state, nonce string) string {
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {cfg.ClientID},
+		"redirect_uri":  {redirectURI},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+		"nonce":         {nonce},
+	}
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Login exchanges code for an ID token and verifies it: signature, issuer,
+// audience, expiry and nonce. The token must carry an email the provider
+// did not flag as unverified.
+func (c *Client) Login(ctx context.Context, meta Metadata, cfg Config, code, redirectURI, nonce string, now time.Time) (Claims, error) {
+	raw, err := c.exchange(ctx, meta, cfg, code, redirectURI)
+	if err != nil {
+		return Claims{}, err
+	}
+	return c.verify(ctx, meta, cfg, raw, nonce, now)
+}
+
+func (c *Client) exchange(ctx context.Context, meta Metadata, cfg Config, code, redirectURI string) (string, error) {
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return "", ErrRejected
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: token endpoint answered %d", resp.StatusCode)
+	}
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.IDToken == "" {
+		return "", ErrRejected
+	}
+	return body.IDToken, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type idToken struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	Expiry        int64    `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified *bool    `json:"email_verified"`
+}
+
+// audience accepts both forms of the aud claim: a string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (c *Client) verify(ctx context.Context, meta Metadata, cfg Config, raw, nonce string, now time.Time) (Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrRejected
+	}
+	var head header
+	var token idToken
+	if decodeSegment(parts[0], &head) != nil || decodeSegment(parts[1], &token) != nil {
+		return Claims{}, ErrRejected
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, ErrRejected
+	}
+
+	keys, err := c.keys(ctx, meta)
+	if err != nil {
+		return Claims{}, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !slices.ContainsFunc(keys, func(k jwk) bool { return k.verifies(head, digest[:], signature) }) {
+		return Claims{}, ErrRejected
+	}
+
+	switch {
+	case token.Issuer != meta.Issuer,
+		!slices.Contains(token.Audience, cfg.ClientID),
+		!now.Before(time.Unix(token.Expiry, 0).Add(clockSkew)),
+		token.Nonce != nonce,
+		token.Email == "",
+		token.EmailVerified != nil && !*token.EmailVerified:
+		return Claims{}, ErrRejected
+	}
+	return Claims{Subject: token.Subject, Email: token.Email}, nil
+}
+
+func decodeSegment(segment string, out any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// jwk is a public key of the provider's key set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (c *Client) keys(ctx context.Context, meta Metadata) ([]jwk, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := c.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	return set.Keys, nil
+}
+
+// verifies reports whether k signed digest as announced in head.
+func (k jwk) verifies(head header, digest, signature []byte) bool {
+	if head.Kid != "" && k.Kid != head.Kid {
+		return false
+	}
+	switch {
+	case head.Alg == "RS256" && k.Kty == "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			return false
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature) == nil
+	case head.Alg == "ES256" && k.Kty == "EC" && k.Crv == "P-256":
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(signature) != 64 {
+			return false
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
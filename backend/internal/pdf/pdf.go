@@ -0,0 +1,147 @@
+// Package pdf writes simple PDF documents on top of the standard library:
+// A4 pages with text in the standard Helvetica fonts, lines and filled
+// rectangles, which is enough for reports with tables and bar charts.
+// Coordinates are in points from the top left corner of the page, unlike
+// PDF itself, whose origin is the bottom left corner.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MIMEType is the content type of the documents.
+const MIMEType = "application/pdf"
+
+// A4 page size, in points.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+// Color is an RGB color with components from 0 to 1.
+type Color struct{ R, G, B float64 }
+
+// Common colors.
+var (
+	Black = Color{0, 0, 0}
+	Gray  = Color{0.45, 0.45, 0.45}
+)
+
+// Document is a PDF being built, one page at a time.
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+}
+
+// New starts a document with title as its metadata title and an empty
+// first page.
+func New(title string) *Document {
+	d := &Document{title: title}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page; what is drawn next goes there.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+func number(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func (c Color) fill() string {
+	return number(c.R) + " " + number(c.G) + " " + number(c.B) + " rg"
+}
+
+func (c Color) stroke() string {
+	return number(c.R) + " " + number(c.G) + " " + number(c.B) + " RG"
+}
+
+// Text writes text with its baseline at (x, y).
+func (d *Document) Text(x, y, size float64, bold bool, color Color, text string) {
+	font := "/F1"
+	if bold {
+		font = "/F2"
+	}
+	fmt.Fprintf(d.page(), "BT %s %s %s Tf %s %s Td (%s) Tj ET\n",
+		color.fill(), font, number(size), number(x), number(PageHeight-y), encode(text))
+}
+
+// Rect fills the rectangle whose top left corner is (x, y).
+func (d *Document) Rect(x, y, width, height float64, color Color) {
+	fmt.Fprintf(d.page(), "%s %s %s %s %s re f\n",
+		color.fill(), number(x), number(PageHeight-y-height), number(width), number(height))
+}
+
+// Line draws a line from (x1, y1) to (x2, y2).
+func (d *Document) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(d.page(), "%s %s w %s %s m %s %s l S\n",
+		color.stroke(), number(width), number(x1), number(PageHeight-y1), number(x2), number(PageHeight-y2))
+}
+
+// encode converts text to the WinAnsi encoding of the standard fonts and
+// escapes it for a string literal; characters outside Latin-1 become "?".
+func encode(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// WriteTo writes the document to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1 to 5 are the catalog, the page tree, the fonts, the info
+	// and the page resources; each page then takes two objects, itself and
+	// its content.
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = strconv.Itoa(6+2*i) + " 0 R"
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >> " +
+		"/F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >> >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (tp6ingsoft3) >>", encode(d.title)))
+	object("<< /Font 3 0 R >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources 5 0 R /Contents %d 0 R >>",
+			number(PageWidth), number(PageHeight), 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}
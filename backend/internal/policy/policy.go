@@ -0,0 +1,71 @@
+// Package policy decides what the members of a shared todo list may do
+// according to their role. Every handler of the lists and of their todos
+// asks Allows instead of comparing roles itself, so the rules live in one
+// table.
+package policy
+
+// Role is the role of a member in a shared list.
+type Role string
+
+// List roles, from the least to the most privileged; each one may do
+// everything the previous ones may.
+const (
+	// Viewer reads the list, its todos and their comments.
+	Viewer Role = "viewer"
+	// Contributor also creates and changes the todos of the list, and
+	// comments and reacts on them.
+	Contributor Role = "contributor"
+	// Admin also manages the members of the list and their roles.
+	Admin Role = "admin"
+)
+
+// Roles lists the valid roles in order of privilege.
+var Roles = []Role{Viewer, Contributor, Admin}
+
+// Action is something a member may try to do on a list.
+type Action string
+
+// Actions on a list and its todos.
+const (
+	Read    Action = "read"
+	Write   Action = "write"
+	Comment Action = "comment"
+	Manage  Action = "manage"
+)
+
+// required is the least privileged role allowed to perform each action.
+var required = map[Action]Role{
+	Read:    Viewer,
+	Write:   Contributor,
+	Comment: Contributor,
+	Manage:  Admin,
+}
+
+func rank(role Role) int {
+	for i, r := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Valid reports whether role is one of Roles.
+func Valid(role Role) bool {
+	return rank(role) > 0
+}
+
+// Allows reports whether a member with role may perform action. Unknown
+// roles and actions are denied.
+func Allows(role Role, action Action) bool {
+	min, ok := required[action]
+	return ok && rank(role) > 0 && rank(role) >= rank(min)
+}
+
+// Higher returns the more privileged of a and b.
+func Higher(a, b Role) Role {
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
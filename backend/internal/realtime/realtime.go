@@ -0,0 +1,238 @@
+// Package realtime pushes messages to the clients connected to the API.
+// Each replica keeps a Hub with the subscriptions of its own connections;
+// a Bridge carries every published message to the hubs of all the
+// replicas, so a client gets it whichever replica it is connected to.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// TypePing is the type of the messages that keep an idle connection open.
+const TypePing = "ping"
+
+// subscriptionBuffer is how many messages a subscription holds before its
+// client counts as too slow and is dropped.
+const subscriptionBuffer = 64
+
+// Message is a message published on a channel.
+type Message struct {
+	Channel string          `json:"channel" bson:"channel"`
+	Type    string          `json:"type" bson:"type"`
+	Data    json.RawMessage `json:"data,omitempty" bson:"data,omitempty"`
+	Time    time.Time       `json:"time" bson:"time"`
+}
+
+// NewMessage builds a message of type messageType on channel with data
+// encoded as JSON.
+func NewMessage(channel, messageType string, data any, at time.Time) (Message, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Channel: channel, Type: messageType, Data: encoded, Time: at.UTC()}, nil
+}
+
+// UserChannel is the channel of the messages for the user of email.
+func UserChannel(email string) string {
+	return "user:" + email
+}
+
+// ListChannel is the channel of the messages about the shared list id,
+// for the members that have it open.
+func ListChannel(id string) string {
+	return "list:" + id
+}
+
+// Bridge carries the messages between the replicas.
+type Bridge interface {
+	// Publish sends msg to every replica listening on the bridge,
+	// including this one.
+	Publish(ctx context.Context, msg Message) error
+	// Listen starts handing fn the messages published through the bridge
+	// until ctx is done. It returns once it is listening.
+	Listen(ctx context.Context, fn func(Message)) error
+}
+
+// LocalBridge is the Bridge of a single replica: it hands every message to
+// the listeners of this process.
+type LocalBridge struct {
+	mu        sync.RWMutex
+	next      int
+	listeners map[int]func(Message)
+}
+
+// NewLocalBridge builds a bridge without listeners.
+func NewLocalBridge() *LocalBridge {
+	return &LocalBridge{listeners: map[int]func(Message){}}
+}
+
+// Publish implements Bridge.
+func (b *LocalBridge) Publish(_ context.Context, msg Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, fn := range b.listeners {
+		fn(msg)
+	}
+	return nil
+}
+
+// Listen implements Bridge.
+func (b *LocalBridge) Listen(ctx context.Context, fn func(Message)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.listeners[id] = fn
+	b.mu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.listeners, id)
+	})
+	return nil
+}
+
+// Subscription receives the messages of some channels. It is closed when
+// its client falls too far behind.
+type Subscription struct {
+	hub *Hub
+	// channels is guarded by hub.mu.
+	channels map[string]struct{}
+	messages chan Message
+	once     sync.Once
+}
+
+// Messages returns the messages of the subscription; it is closed with
+// the subscription.
+func (s *Subscription) Messages() <-chan Message {
+	return s.messages
+}
+
+// Join adds channel to the subscription.
+func (s *Subscription) Join(channel string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.closed() {
+		return
+	}
+	s.hub.add(s, channel)
+}
+
+// Leave removes channel from the subscription.
+func (s *Subscription) Leave(channel string) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s, channel)
+}
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// closed reports whether the subscription was closed; hub.mu must be held.
+func (s *Subscription) closed() bool {
+	return s.channels == nil
+}
+
+// Hub delivers the messages of the bridge to the subscriptions of this
+// replica.
+type Hub struct {
+	bridge Bridge
+
+	mu   sync.RWMutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+// NewHub builds a hub over bridge; nil uses a LocalBridge.
+func NewHub(bridge Bridge) *Hub {
+	if bridge == nil {
+		bridge = NewLocalBridge()
+	}
+	return &Hub{bridge: bridge, subs: map[string]map[*Subscription]struct{}{}}
+}
+
+// Start listens on the bridge until ctx is done.
+func (h *Hub) Start(ctx context.Context) error {
+	return h.bridge.Listen(ctx, h.deliver)
+}
+
+// Publish sends msg to the subscribers of its channel on every replica.
+func (h *Hub) Publish(ctx context.Context, msg Message) error {
+	return h.bridge.Publish(ctx, msg)
+}
+
+// Subscribe starts receiving the messages of channels.
+func (h *Hub) Subscribe(channels ...string) *Subscription {
+	sub := &Subscription{hub: h, channels: map[string]struct{}{}, messages: make(chan Message, subscriptionBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, channel := range channels {
+		h.add(sub, channel)
+	}
+	return sub
+}
+
+// add subscribes sub to channel; h.mu must be held.
+func (h *Hub) add(sub *Subscription, channel string) {
+	if h.subs[channel] == nil {
+		h.subs[channel] = map[*Subscription]struct{}{}
+	}
+	h.subs[channel][sub] = struct{}{}
+	sub.channels[channel] = struct{}{}
+}
+
+// drop unsubscribes sub from channel; h.mu must be held.
+func (h *Hub) drop(sub *Subscription, channel string) {
+	delete(h.subs[channel], sub)
+	if len(h.subs[channel]) == 0 {
+		delete(h.subs, channel)
+	}
+	delete(sub.channels, channel)
+}
+
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// remove drops sub from its channels and closes it; h.mu must be held.
+func (h *Hub) remove(sub *Subscription) {
+	for channel := range sub.channels {
+		h.drop(sub, channel)
+	}
+	sub.channels = nil
+	sub.once.Do(func() { close(sub.messages) })
+}
+
+// deliver hands msg to the local subscribers of its channel, dropping
+// those whose buffer is full so a slow client does not hold the others
+// back; it reconnects and catches up through the API.
+func (h *Hub) deliver(msg Message) {
+	h.mu.RLock()
+	var slow []*Subscription
+	for sub := range h.subs[msg.Channel] {
+		select {
+		case sub.messages <- msg:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, sub := range slow {
+		log.Printf("suscripcion de %s descartada: el cliente no lee los mensajes", msg.Channel)
+		h.remove(sub)
+	}
+}
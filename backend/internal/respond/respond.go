@@ -0,0 +1,249 @@
+package respond
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/timing"
+)
+
+// Media types negotiated beyond gin's defaults.
+const (
+	MIMEMsgPack  = "application/msgpack"
+	MIMEMsgPackX = "application/x-msgpack"
+	MIMEJSONAPI  = "application/vnd.api+json"
+)
+
+// APIVersion is the version of the API contract (info.version in
+// api/openapi.yaml), reported in the meta of every response.
+const APIVersion = "1.0.0"
+
+// RequestIDKey is the context key under which the request identifier is
+// stored.
+const RequestIDKey = "requestId"
+
+var offered = []string{binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2, MIMEMsgPack, MIMEMsgPackX, MIMEJSONAPI}
+
+// Document is a JSON:API top-level document.
+type Document struct {
+	Data   interface{}       `json:"data,omitempty"`
+	Errors []ErrorObject     `json:"errors,omitempty"`
+	Meta   interface{}       `json:"meta,omitempty"`
+	Links  map[string]string `json:"links,omitempty"`
+}
+
+// Envelope wraps every successful response: Data carries the payload and
+// Meta the details of the exchange.
+type Envelope struct {
+	Data interface{} `json:"data"`
+	Meta Meta        `json:"meta"`
+}
+
+// Meta describes the exchange a response belongs to.
+type Meta struct {
+	RequestID  string      `json:"requestId,omitempty" xml:"requestId,omitempty"`
+	APIVersion string      `json:"apiVersion" xml:"apiVersion"`
+	Pagination *Pagination `json:"pagination,omitempty" xml:"pagination,omitempty"`
+}
+
+// Pagination describes the page of a listing. A zero Limit means the
+// listing was not paginated.
+type Pagination struct {
+	Offset int   `json:"offset" xml:"offset"`
+	Limit  int   `json:"limit" xml:"limit"`
+	Total  int64 `json:"total" xml:"total"`
+}
+
+// MarshalXML renders <response><data>...</data><meta>...</meta></response>.
+// gin.H payloads are unfolded into <data> in a stable order, since gin
+// would otherwise wrap them in a <map> element.
+func (e Envelope) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "response"}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	data := xml.StartElement{Name: xml.Name{Local: "data"}}
+	if h, ok := e.Data.(gin.H); ok {
+		if err := encodeXMLFields(enc, data, h); err != nil {
+			return err
+		}
+	} else if err := enc.EncodeElement(e.Data, data); err != nil {
+		return err
+	}
+	if err := enc.EncodeElement(e.Meta, xml.StartElement{Name: xml.Name{Local: "meta"}}); err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func encodeXMLFields(enc *xml.Encoder, start xml.StartElement, fields gin.H) error {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := enc.EncodeElement(fields[key], xml.StartElement{Name: xml.Name{Local: key}}); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlResponse renders the gin.H payloads that go without the envelope,
+// such as error bodies, under the same <response> root instead of <map>.
+type xmlResponse gin.H
+
+func (r xmlResponse) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	return encodeXMLFields(enc, xml.StartElement{Name: xml.Name{Local: "response"}}, gin.H(r))
+}
+
+// ErrorObject is a JSON:API error entry.
+type ErrorObject struct {
+	Status string `json:"status"`
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+}
+
+// Format returns the media type chosen for the response according to the
+// Accept header, defaulting to JSON when nothing better matches.
+func Format(c *gin.Context) string {
+	if format := c.NegotiateFormat(offered...); format != "" {
+		return format
+	}
+	return binding.MIMEJSON
+}
+
+// IsJSONAPI reports whether the client opted into JSON:API documents.
+func IsJSONAPI(c *gin.Context) bool {
+	return Format(c) == MIMEJSONAPI
+}
+
+// Render writes data as JSON, XML, MessagePack or JSON:API depending on the
+// Accept header. Successful responses are wrapped in an Envelope. In JSON:API
+// mode anything that is not already a Document is sent as the document's
+// meta member.
+func Render(c *gin.Context, status int, data interface{}) {
+	write(c, status, data, nil)
+}
+
+// RenderPage is like Render for a page of a listing, described in the
+// pagination member of the meta.
+func RenderPage(c *gin.Context, status int, data interface{}, page Pagination) {
+	write(c, status, data, &page)
+}
+
+func write(c *gin.Context, status int, data interface{}, page *Pagination) {
+	c.Header("Vary", "Accept")
+	format := Format(c)
+	if status >= 200 && status < 300 && format != MIMEJSONAPI {
+		data = Envelope{
+			Data: data,
+			Meta: Meta{RequestID: c.GetString(RequestIDKey), APIVersion: APIVersion, Pagination: page},
+		}
+	}
+	var r render.Render
+	switch format {
+	case binding.MIMEXML, binding.MIMEXML2:
+		if h, ok := data.(gin.H); ok {
+			data = xmlResponse(h)
+		}
+		r = render.XML{Data: data}
+	case MIMEMsgPack, MIMEMsgPackX:
+		r = render.MsgPack{Data: data}
+	case MIMEJSONAPI:
+		doc, ok := data.(Document)
+		if !ok {
+			doc = Document{Meta: data}
+		}
+		c.Header("Content-Type", MIMEJSONAPI)
+		r = render.JSON{Data: doc}
+	default:
+		r = render.JSON{Data: data}
+	}
+
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		c.Render(status, r)
+		return
+	}
+	// Encode up front so a failure can still be answered with a 500, and
+	// so the Server-Timing header, which leaves with the first byte,
+	// includes the serialization.
+	start := time.Now()
+	body := &bodyBuffer{header: c.Writer.Header()}
+	err := r.Render(body)
+	if recorder := timing.FromContext(c.Request.Context()); recorder != nil {
+		recorder.Add(timing.Serialize, time.Since(start))
+	}
+	if err != nil {
+		_ = c.Error(err)
+		c.Abort()
+		if c.GetBool(renderFailedKey) {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Set(renderFailedKey, true)
+		if RenderFailed != nil {
+			RenderFailed(c)
+			return
+		}
+		Error(c, http.StatusInternalServerError, "INTERNAL_ERROR", http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	c.Status(status)
+	_, _ = c.Writer.Write(body.Bytes())
+}
+
+const renderFailedKey = "respond.renderFailed"
+
+// RenderFailed answers a request whose response could not be encoded; the
+// i18n package sets it to its localized INTERNAL_ERROR, as this package
+// cannot depend on it. Without it the error is answered in English.
+var RenderFailed func(c *gin.Context)
+
+// bodyBuffer is the http.ResponseWriter a render encodes into before the
+// response is sent.
+type bodyBuffer struct {
+	bytes.Buffer
+	header http.Header
+}
+
+func (b *bodyBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *bodyBuffer) WriteHeader(int) {}
+
+// Abort is like Render but also stops the handler chain.
+func Abort(c *gin.Context, status int, data interface{}) {
+	c.Abort()
+	Render(c, status, data)
+}
+
+// Error writes the standard error body {"error": message, "code": code}, or
+// an errors document in JSON:API mode.
+func Error(c *gin.Context, status int, code, message string) {
+	if IsJSONAPI(c) {
+		Render(c, status, Document{Errors: []ErrorObject{{Status: strconv.Itoa(status), Code: code, Title: message}}})
+		return
+	}
+	Render(c, status, gin.H{"error": message, "code": code})
+}
+
+// AbortError is like Error but also stops the handler chain.
+func AbortError(c *gin.Context, status int, code, message string) {
+	c.Abort()
+	Error(c, status, code, message)
+}
@@ -0,0 +1,171 @@
+// Package scanner checks uploaded files for malware, either with a ClamAV
+// daemon (clamd) or with an external HTTP scanning API.
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Supported providers.
+const (
+	ProviderClamAV = "clamav"
+	ProviderHTTP   = "http"
+)
+
+// Verdict is the outcome of a scan. Threat names what was found in files
+// that are not Clean.
+type Verdict struct {
+	Clean  bool
+	Threat string
+}
+
+// Scanner checks the content of a file named name.
+type Scanner interface {
+	Scan(ctx context.Context, name string, data []byte) (Verdict, error)
+}
+
+// clamdChunk is the size of the INSTREAM chunks sent to clamd.
+const clamdChunk = 64 << 10
+
+// ClamdScanner streams files to a ClamAV daemon with the INSTREAM command.
+type ClamdScanner struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamdScanner builds a scanner for the clamd listening at addr, a
+// "host:port" or, prefixed with "unix:", the path of its socket.
+func NewClamdScanner(addr string, timeout time.Duration) *ClamdScanner {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return &ClamdScanner{network: "unix", addr: path, timeout: timeout}
+	}
+	return &ClamdScanner{network: "tcp", addr: addr, timeout: timeout}
+}
+
+// Scan implements Scanner.
+func (c *ClamdScanner) Scan(ctx context.Context, _ string, data []byte) (Verdict, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return Verdict{}, err
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	for len(data) > 0 {
+		n := min(len(data), clamdChunk)
+		binary.Write(w, binary.BigEndian, uint32(n))
+		w.Write(data[:n])
+		data = data[n:]
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return Verdict{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Verdict{}, err
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply reads replies like "stream: OK" and
+// "stream: Eicar-Signature FOUND".
+func parseClamdReply(reply string) (Verdict, error) {
+	result := strings.TrimSpace(reply[strings.LastIndex(reply, ":")+1:])
+	switch {
+	case result == "OK":
+		return Verdict{Clean: true}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return Verdict{Threat: strings.TrimSuffix(result, " FOUND")}, nil
+	}
+	return Verdict{}, fmt.Errorf("clamd respondio %q", reply)
+}
+
+// HTTPScanner posts files to an external scanning API, which answers with
+// {"clean": bool, "threat": "..."}.
+type HTTPScanner struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPScanner builds a scanner for the API at url, authenticated with a
+// bearer token when set; a nil client uses a default one.
+func NewHTTPScanner(url, token string, client *http.Client) *HTTPScanner {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &HTTPScanner{url: url, token: token, client: client}
+}
+
+type httpScanResponse struct {
+	Clean  *bool  `json:"clean"`
+	Threat string `json:"threat"`
+}
+
+// Scan implements Scanner. The file name goes in the X-File-Name header.
+func (h *HTTPScanner) Scan(ctx context.Context, name string, data []byte) (Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(data))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", name)
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return Verdict{}, fmt.Errorf("el escaner respondio %d", resp.StatusCode)
+	}
+	var result httpScanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Verdict{}, err
+	}
+	if result.Clean == nil {
+		return Verdict{}, fmt.Errorf("el escaner no indico si el archivo esta limpio")
+	}
+	return Verdict{Clean: *result.Clean, Threat: result.Threat}, nil
+}
+
+// Open returns the scanner of provider at addr (the clamd address or the
+// API URL). An empty provider disables scanning and returns nil.
+func Open(provider, addr, token string, timeout time.Duration) (Scanner, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case ProviderClamAV:
+		return NewClamdScanner(addr, timeout), nil
+	case ProviderHTTP:
+		return NewHTTPScanner(addr, token, &http.Client{Timeout: timeout}), nil
+	}
+	return nil, fmt.Errorf("proveedor de escaneo desconocido: %q", provider)
+}
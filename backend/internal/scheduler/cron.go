@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the activation times of a job.
+type Schedule interface {
+	// Next returns the first activation strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// Parse reads a standard five-field cron expression ("minute hour
+// day-of-month month day-of-week", with "*", lists, ranges and "/" steps),
+// one of the descriptors @yearly, @monthly, @weekly, @daily, @hourly, or
+// "@every <duration>". Cron expressions are evaluated in UTC.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || every < time.Second {
+			return nil, fmt.Errorf("intervalo invalido en %q", spec)
+		}
+		return interval(every), nil
+	}
+	if expanded, ok := descriptors[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("la expresion cron %q debe tener 5 campos", spec)
+	}
+	var c cron
+	for i, target := range []*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow} {
+		set, err := parseField(fields[i], cronBounds[i])
+		if err != nil {
+			return nil, fmt.Errorf("expresion cron %q: %w", spec, err)
+		}
+		*target = set
+	}
+	// Sunday may be written as 0 or 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = fields[2] == "*"
+	c.dowAny = fields[4] == "*"
+	return c, nil
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct{ min, max int }
+
+var cronBounds = [5]bounds{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// parseField returns the bitset of the values matched by a cron field.
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("paso invalido en %q", part)
+			}
+			step = n
+		}
+
+		lo, hi := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("rango invalido en %q", part)
+			}
+			if hi, err = strconv.Atoi(to); err != nil {
+				return 0, fmt.Errorf("rango invalido en %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("valor invalido en %q", part)
+			}
+			lo = n
+			if !hasStep {
+				hi = n
+			}
+		}
+		if lo < b.min || hi > b.max || lo > hi {
+			return 0, fmt.Errorf("%q fuera de %d-%d", part, b.min, b.max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// cron is a parsed five-field expression; each field is a bitset of the
+// matching values.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record a "*" day field: when both day fields are
+	// restricted, a day matching either of them matches, as in crontab.
+	domAny, dowAny bool
+}
+
+func (c cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next implements Schedule by walking forward field by field.
+func (c cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Five years is enough for any satisfiable expression (e.g. Feb 29).
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<t.Hour()) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if c.minute&(1<<t.Minute()) == 0 {
+			// Jump straight to the next matching minute, or the next hour.
+			next := c.minute >> t.Minute()
+			if next == 0 {
+				t = t.Truncate(time.Hour).Add(time.Hour)
+			} else {
+				t = t.Add(time.Duration(bits.TrailingZeros64(next)) * time.Minute)
+			}
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// interval fires every fixed duration, aligned to multiples of it.
+type interval time.Duration
+
+// Next implements Schedule.
+func (i interval) Next(t time.Time) time.Time {
+	return t.Truncate(time.Duration(i)).Add(time.Duration(i))
+}
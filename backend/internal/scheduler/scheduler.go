@@ -0,0 +1,355 @@
+// Package scheduler runs background jobs on cron schedules. When several
+// replicas run, an Elector picks the one that executes the jobs; the others
+// keep their schedules in step and read the job history from a shared Store.
+// A Locker makes sure each activation of a job runs once even when two
+// replicas believe they lead.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Off disables a job in the configuration.
+const Off = "off"
+
+// Status describes the last and next run of a job.
+type Status struct {
+	Name     string `json:"name" bson:"_id"`
+	Schedule string `json:"schedule" bson:"schedule"`
+	// LastRun is when the last run started; LastDurationMs and LastError
+	// describe how it ended.
+	LastRun        *time.Time `json:"lastRun,omitempty" bson:"lastRun,omitempty"`
+	LastDurationMs int64      `json:"lastDurationMs" bson:"lastDurationMs"`
+	LastError      string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	Runs           int        `json:"runs" bson:"runs"`
+	Failures       int        `json:"failures" bson:"failures"`
+	Running        bool       `json:"running" bson:"running"`
+	NextRun        time.Time  `json:"nextRun" bson:"-"`
+}
+
+// Store persists the job statuses so every replica can report them.
+type Store interface {
+	Save(ctx context.Context, status Status) error
+	List(ctx context.Context) ([]Status, error)
+}
+
+// Elector decides which replica runs the jobs.
+type Elector interface {
+	// Lead reports whether this replica is the leader, acquiring or renewing
+	// the leadership when possible. On error the reported leadership still
+	// applies, e.g. a leader whose lease has not expired keeps leading.
+	Lead(ctx context.Context) (bool, error)
+}
+
+// Solo is the Elector of a single replica: it always leads.
+type Solo struct{}
+
+// Lead implements Elector.
+func (Solo) Lead(context.Context) (bool, error) { return true, nil }
+
+// Locker claims the activations of the jobs across the replicas. The
+// Elector alone is not enough: a leader paused past its lease (a long GC, a
+// frozen VM) wakes up still believing it leads while another replica took
+// over, and both would run the same activation or overlap two runs of the
+// same job.
+type Locker interface {
+	// Lock claims the activation of the job name due at due, holding the
+	// lock of the job for ttl. It reports false when that activation was
+	// already claimed or a run of the job still holds the lock.
+	Lock(ctx context.Context, name string, due time.Time, ttl time.Duration) (bool, error)
+	// Extend keeps holding the lock of a run for ttl more; false means the
+	// lock expired and was taken by another replica.
+	Extend(ctx context.Context, name string, due time.Time, ttl time.Duration) (bool, error)
+	// Unlock releases the lock of a run; the activation stays claimed.
+	Unlock(ctx context.Context, name string, due time.Time) error
+}
+
+type job struct {
+	schedule Schedule
+	run      func(ctx context.Context) error
+	next     time.Time
+	status   Status
+}
+
+// Scheduler runs the registered jobs when they are due.
+type Scheduler struct {
+	store    Store
+	elector  Elector
+	instance string
+	now      func() time.Time
+
+	locker  Locker
+	lockTTL time.Duration
+
+	mu     sync.Mutex
+	jobs   []*job
+	leader bool
+	wg     sync.WaitGroup
+}
+
+// New builds a Scheduler; store may be nil to keep the statuses in memory
+// only, and elector nil for a single replica. instance names this replica
+// in the job listing.
+func New(store Store, elector Elector, instance string, now func() time.Time) *Scheduler {
+	if elector == nil {
+		elector = Solo{}
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &Scheduler{store: store, elector: elector, instance: instance, now: now}
+}
+
+// SetLocker claims every run in locker before starting it, holding the
+// lock for ttl and renewing it while the run goes on.
+func (s *Scheduler) SetLocker(locker Locker, ttl time.Duration) {
+	s.locker, s.lockTTL = locker, ttl
+}
+
+// Add registers run under name on the cron spec. An empty or "off" spec
+// leaves the job disabled.
+func (s *Scheduler) Add(name, spec string, run func(ctx context.Context) error) error {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.EqualFold(spec, Off) {
+		return nil
+	}
+	schedule, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("trabajo %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.jobs {
+		if existing.status.Name == name {
+			return fmt.Errorf("trabajo %s registrado dos veces", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{
+		schedule: schedule,
+		run:      run,
+		next:     schedule.Next(s.now()),
+		status:   Status{Name: name, Schedule: spec},
+	})
+	return nil
+}
+
+// Instance returns the name of this replica.
+func (s *Scheduler) Instance() string {
+	return s.instance
+}
+
+// Leader reports whether this replica led on the last tick.
+func (s *Scheduler) Leader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Tick starts the jobs due now in the background, when this replica is the
+// leader. A job still running from its previous activation is skipped.
+// Followers advance their schedules without running anything.
+func (s *Scheduler) Tick(ctx context.Context) {
+	leader, err := s.elector.Lead(ctx)
+	if err != nil {
+		log.Printf("no se pudo renovar el liderazgo del planificador: %v", err)
+	}
+
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if leader != s.leader {
+		log.Printf("planificador %s: lider=%t", s.instance, leader)
+	}
+	s.leader = leader
+	for _, j := range s.jobs {
+		if now.Before(j.next) {
+			continue
+		}
+		due := j.next
+		j.next = j.schedule.Next(now)
+		if !leader {
+			continue
+		}
+		if j.status.Running {
+			log.Printf("trabajo %s omitido: la ejecucion anterior sigue en curso", j.status.Name)
+			continue
+		}
+		previous := j.status
+		j.status.Running = true
+		started := now
+		j.status.LastRun = &started
+		s.wg.Add(1)
+		go s.execute(ctx, j, due, previous, j.status)
+	}
+}
+
+// execute runs the activation of j due at due and records the outcome;
+// started is the status as the run began and previous the one before, put
+// back when another replica claimed the activation.
+func (s *Scheduler) execute(ctx context.Context, j *job, due time.Time, previous, started Status) {
+	defer s.wg.Done()
+	if s.locker != nil {
+		unlock, ok := s.lock(ctx, started.Name, due)
+		if !ok {
+			s.mu.Lock()
+			j.status = previous
+			s.mu.Unlock()
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		renewing := make(chan struct{})
+		go func() {
+			defer close(renewing)
+			s.keepLock(ctx, cancel, started.Name, due)
+		}()
+		// Stop renewing before releasing, or a late renewal would hold the
+		// lock again.
+		defer func() {
+			cancel()
+			<-renewing
+			unlock()
+		}()
+	}
+	s.save(ctx, started)
+
+	err := s.call(ctx, j)
+	finished := s.now()
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastDurationMs = finished.Sub(*j.status.LastRun).Milliseconds()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		log.Printf("el trabajo %s fallo: %v", j.status.Name, err)
+	}
+	status := j.status
+	s.mu.Unlock()
+
+	s.save(ctx, status)
+}
+
+// lock claims the activation of name due at due, returning how to release
+// it.
+func (s *Scheduler) lock(ctx context.Context, name string, due time.Time) (func(), bool) {
+	ok, err := s.locker.Lock(ctx, name, due, s.lockTTL)
+	switch {
+	case err != nil:
+		log.Printf("trabajo %s omitido: no se pudo tomar el bloqueo: %v", name, err)
+		return nil, false
+	case !ok:
+		log.Printf("trabajo %s omitido: otra replica ya tomo la ejecucion de %s", name, due.Format(time.RFC3339))
+		return nil, false
+	}
+	return func() {
+		if err := s.locker.Unlock(context.WithoutCancel(ctx), name, due); err != nil {
+			log.Printf("no se pudo liberar el bloqueo del trabajo %s: %v", name, err)
+		}
+	}, true
+}
+
+// keepLock renews the lock of a run of name until ctx is done, cancelling
+// the run if another replica takes the lock over.
+func (s *Scheduler) keepLock(ctx context.Context, cancel context.CancelFunc, name string, due time.Time) {
+	ticker := time.NewTicker(max(s.lockTTL/3, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, err := s.locker.Extend(ctx, name, due, s.lockTTL)
+			if err != nil {
+				log.Printf("no se pudo renovar el bloqueo del trabajo %s: %v", name, err)
+				continue
+			}
+			if !ok {
+				log.Printf("trabajo %s cancelado: perdio el bloqueo", name)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// call runs j, turning a panic into an error so it does not stop the
+// scheduler.
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.run(ctx)
+}
+
+func (s *Scheduler) save(ctx context.Context, status Status) {
+	if s.store == nil {
+		return
+	}
+	if err := s.store.Save(ctx, status); err != nil {
+		log.Printf("no se pudo guardar el estado del trabajo %s: %v", status.Name, err)
+	}
+}
+
+// Wait blocks until the running jobs finish.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Run ticks every second until ctx is done, then waits for the running
+// jobs.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.Wait()
+			return
+		case <-ticker.C:
+			s.Tick(ctx)
+		}
+	}
+}
+
+// Statuses lists the registered jobs by name. The run history comes from
+// the store when there is one, so followers report the leader's runs.
+func (s *Scheduler) Statuses(ctx context.Context) ([]Status, error) {
+	stored := map[string]Status{}
+	if s.store != nil {
+		saved, err := s.store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range saved {
+			stored[status.Name] = status
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		status := j.status
+		if saved, ok := stored[status.Name]; ok && !status.Running {
+			saved.Schedule = status.Schedule
+			status = saved
+		}
+		status.NextRun = j.next
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(a, b int) bool { return statuses[a].Name < statuses[b].Name })
+	return statuses, nil
+}
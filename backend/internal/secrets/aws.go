@@ -0,0 +1,141 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials sign the requests to AWS.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// SecretsManager reads a JSON secret of AWS Secrets Manager. Requests are
+// signed with Signature Version 4, so no SDK is needed.
+type SecretsManager struct {
+	endpoint string
+	region   string
+	secretID string
+	creds    Credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewSecretsManager builds a provider for secretID in region. endpoint
+// overrides the regional endpoint (e.g. for LocalStack); a nil client uses
+// a default one with a short timeout.
+func NewSecretsManager(region, endpoint, secretID string, creds Credentials, client *http.Client) *SecretsManager {
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &SecretsManager{endpoint: strings.TrimRight(endpoint, "/"), region: region, secretID: secretID, creds: creds, client: client, now: time.Now}
+}
+
+// Values implements Provider. The SecretString must be a JSON object.
+func (s *SecretsManager) Values(ctx context.Context) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": s.secretID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	s.sign(req, payload, s.now())
+
+	body, err := do(s.client, req)
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	if parsed.SecretString == "" {
+		return nil, errors.New("el secreto no tiene SecretString")
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(parsed.SecretString), &fields); err != nil {
+		return nil, fmt.Errorf("el secreto no es un objeto JSON: %w", err)
+	}
+	return stringValues(fields), nil
+}
+
+// sign adds the Signature Version 4 headers of the secretsmanager service.
+func (s *SecretsManager) sign(req *http.Request, payload []byte, at time.Time) {
+	amzDate := at.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, hexSHA256(payload),
+	}, "\n")
+	scope := date + "/" + s.region + "/secretsmanager/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.creds.SecretAccessKey), date)
+	for _, part := range []string{s.region, "secretsmanager", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
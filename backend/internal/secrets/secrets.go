@@ -0,0 +1,147 @@
+// Package secrets reads the credentials of the API (MongoDB URI, tokens,
+// webhook secrets) from a secrets manager, so they do not have to live in
+// plain environment variables. Each provider holds one secret whose fields
+// are named after the variables they replace.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Providers accepted by Open.
+const (
+	ProviderVault = "vault"
+	ProviderAWS   = "aws"
+)
+
+// ErrNotFound is returned when the secret does not exist.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider fetches the secret values, by variable name.
+type Provider interface {
+	Values(ctx context.Context) (map[string]string, error)
+}
+
+// Options configures the providers. Path is the Vault KV path (e.g.
+// "secret/data/hotel-api") or the AWS secret ID.
+type Options struct {
+	Path        string
+	VaultAddr   string
+	VaultToken  string
+	AWSRegion   string
+	AWSEndpoint string
+	AWS         Credentials
+}
+
+// Open returns the provider named provider; an empty name returns nil.
+func Open(provider string, opts Options) (Provider, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case ProviderVault:
+		if opts.VaultAddr == "" || opts.VaultToken == "" || opts.Path == "" {
+			return nil, errors.New("vault requiere VAULT_ADDR, VAULT_TOKEN y SECRETS_PATH")
+		}
+		return NewVault(opts.VaultAddr, opts.VaultToken, opts.Path, nil), nil
+	case ProviderAWS:
+		if opts.AWSRegion == "" || opts.AWS.AccessKeyID == "" || opts.AWS.SecretAccessKey == "" || opts.Path == "" {
+			return nil, errors.New("aws requiere AWS_REGION, credenciales y SECRETS_PATH")
+		}
+		return NewSecretsManager(opts.AWSRegion, opts.AWSEndpoint, opts.Path, opts.AWS, nil), nil
+	default:
+		return nil, fmt.Errorf("proveedor de secretos desconocido: %q", provider)
+	}
+}
+
+// Vault reads a secret of a HashiCorp Vault KV engine (version 1 or 2).
+type Vault struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+}
+
+// NewVault builds a provider for the secret at path of the Vault server at
+// addr; a nil client uses a default one with a short timeout.
+func NewVault(addr, token, path string, client *http.Client) *Vault {
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &Vault{addr: strings.TrimRight(addr, "/"), token: token, path: strings.Trim(path, "/"), client: client}
+}
+
+type vaultResponse struct {
+	Data struct {
+		// Data holds the fields of a KV version 2 secret.
+		Data map[string]any `json:"data"`
+	} `json:"data"`
+}
+
+// Values implements Provider.
+func (v *Vault) Values(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+v.path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	body, err := do(v.client, req)
+	if err != nil {
+		return nil, err
+	}
+	var parsed vaultResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	fields := parsed.Data.Data
+	if fields == nil {
+		// KV version 1 has no metadata wrapper.
+		var v1 struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(body, &v1); err != nil {
+			return nil, err
+		}
+		fields = v1.Data
+	}
+	return stringValues(fields), nil
+}
+
+// do sends req and returns the body of a successful response.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		return nil, fmt.Errorf("el proveedor de secretos respondio %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func stringValues(fields map[string]any) map[string]string {
+	values := make(map[string]string, len(fields))
+	for key, value := range fields {
+		if text, ok := value.(string); ok {
+			values[key] = text
+			continue
+		}
+		values[key] = fmt.Sprint(value)
+	}
+	return values
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+)
+
+// Run serves handler on the configured port until ctx is done, then stops
+// taking connections and waits up to cfg.ShutdownTimeout for the requests
+// in flight. When TLS is enabled the server speaks HTTPS (HTTP/2 is
+// negotiated automatically by net/http) and, if a redirect port is
+// configured, a secondary listener sends plain HTTP clients to the HTTPS
+// endpoint.
+func Run(ctx context.Context, handler http.Handler, cfg config.Config) error {
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	stopped := make(chan error, 1)
+	stop := context.AfterFunc(ctx, func() {
+		log.Printf("deteniendo el servidor")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		stopped <- srv.Shutdown(shutdownCtx)
+	})
+	defer stop()
+
+	err := listen(srv, cfg)
+	if errors.Is(err, http.ErrServerClosed) {
+		return <-stopped
+	}
+	return err
+}
+
+func listen(srv *http.Server, cfg config.Config) error {
+	if !cfg.TLS.Enabled() {
+		return srv.ListenAndServe()
+	}
+
+	var redirect http.Handler = RedirectHandler(cfg.Port)
+	if cfg.TLS.UsesAutocert() {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLS.AutocertCacheDir),
+			Email:      cfg.TLS.AutocertEmail,
+		}
+		srv.TLSConfig = manager.TLSConfig()
+		// ACME HTTP-01 challenges arrive on the plain HTTP listener.
+		redirect = manager.HTTPHandler(redirect)
+	}
+
+	if cfg.TLS.RedirectPort != "" {
+		go func() {
+			redirectSrv := &http.Server{
+				Addr:              ":" + cfg.TLS.RedirectPort,
+				Handler:           redirect,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("servidor de redireccion HTTP detenido: %v", err)
+			}
+		}()
+	}
+
+	if cfg.TLS.UsesAutocert() {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+}
+
+// RedirectHandler sends every request to the same host and path over HTTPS
+// on tlsPort.
+func RedirectHandler(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+)
+
+var (
+	// ErrInvalidMerge indicates a merge without the other account or of an
+	// account into itself.
+	ErrInvalidMerge = errors.New("invalid merge")
+	// ErrMergeEmailMismatch is returned when the two emails are not
+	// variants of the same mailbox (see MailboxKey).
+	ErrMergeEmailMismatch = errors.New("merge email mismatch")
+	// ErrMergeNotVerified is returned when the ownership of the other
+	// account could not be proven.
+	ErrMergeNotVerified = errors.New("merge not verified")
+)
+
+// MergeSource is the account merged into the signed-in one, with the proof
+// that the caller owns it: its password or, for the accounts that sign in
+// through SSO and have none, a session token of it.
+type MergeSource struct {
+	Email    string
+	Password string
+	Token    string
+}
+
+// MergeSummary counts the records moved by a merge, or that would be moved
+// by a dry run.
+type MergeSummary struct {
+	From     string `json:"from" xml:"from"`
+	Into     string `json:"into" xml:"into"`
+	DryRun   bool   `json:"dryRun" xml:"dryRun"`
+	Todos    int64  `json:"todos" xml:"todos"`
+	Lists    int64  `json:"lists" xml:"lists"`
+	Comments int64  `json:"comments" xml:"comments"`
+	Bookings int64  `json:"bookings" xml:"bookings"`
+	Logins   int64  `json:"logins" xml:"logins"`
+	Passkeys int64  `json:"passkeys" xml:"passkeys"`
+}
+
+// AccountMergeRepository moves the records of one account to another.
+type AccountMergeRepository interface {
+	// Count returns how many records of source a merge would move.
+	Count(ctx context.Context, source string) (MergeSummary, error)
+	// Merge moves the todos (trashed included), the reactions, comments,
+	// mentions, attachments and assignments, the notifications, the
+	// memberships of the shared lists (keeping the higher role when both
+	// are members), the bookings, the login history and the passkeys of
+	// source to target, then deletes source and its sessions.
+	Merge(ctx context.Context, source, target string) (MergeSummary, error)
+}
+
+// MongoAccountMergeRepository implements AccountMergeRepository over the
+// collections of db.
+type MongoAccountMergeRepository struct {
+	db *Database
+}
+
+// NewMongoAccountMergeRepository creates a repository over db.
+func NewMongoAccountMergeRepository(db *Database) *MongoAccountMergeRepository {
+	return &MongoAccountMergeRepository{db: db}
+}
+
+// Count implements AccountMergeRepository.
+func (m *MongoAccountMergeRepository) Count(ctx context.Context, source string) (MergeSummary, error) {
+	var summary MergeSummary
+	var archived int64
+	for _, count := range []struct {
+		collection string
+		filter     bson.M
+		into       *int64
+	}{
+		{"todos", bson.M{"email": source}, &summary.Todos},
+		{TodoArchiveCollection, bson.M{"email": source}, &archived},
+		{"todo_lists", bson.M{"members.email": source}, &summary.Lists},
+		{"todo_comments", bson.M{"email": source}, &summary.Comments},
+		{"bookings", bson.M{"email": source}, &summary.Bookings},
+		{"logins", bson.M{"email": source}, &summary.Logins},
+		{"passkeys", bson.M{"email": source}, &summary.Passkeys},
+	} {
+		n, err := m.db.Collection(count.collection).CountDocuments(ctx, count.filter)
+		if err != nil {
+			return MergeSummary{}, err
+		}
+		*count.into = n
+	}
+	summary.Todos += archived
+	return summary, nil
+}
+
+// Merge implements AccountMergeRepository. The todos and the account go
+// last, so a failed merge can be run again.
+func (m *MongoAccountMergeRepository) Merge(ctx context.Context, source, target string) (MergeSummary, error) {
+	var summary MergeSummary
+	rename := func(collection, field string, into *int64) func() error {
+		return func() error {
+			res, err := m.db.Collection(collection).UpdateMany(ctx, bson.M{field: source}, bson.M{"$set": bson.M{field: target}})
+			if err == nil && into != nil {
+				*into = res.ModifiedCount
+			}
+			return err
+		}
+	}
+	err := runSteps(
+		func() error { return m.mergeReactions(ctx, source, target) },
+		func() (err error) {
+			summary.Lists, err = m.mergeLists(ctx, source, target)
+			return err
+		},
+		rename("todos", "assignee", nil),
+		rename(TodoArchiveCollection, "assignee", nil),
+		rename("todo_comments", "email", &summary.Comments),
+		func() error {
+			_, err := m.db.Collection("todo_comments").UpdateMany(ctx,
+				bson.M{"mentions": source}, bson.M{"$set": bson.M{"mentions.$[m]": target}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"m": source}}}))
+			return err
+		},
+		rename("todo_attachments", "email", nil),
+		rename("notifications", "email", nil),
+		rename("notifications", "author", nil),
+		rename("bookings", "email", &summary.Bookings),
+		rename("logins", "email", &summary.Logins),
+		rename("passkeys", "email", &summary.Passkeys),
+		func() error {
+			_, err := m.db.Collection("sessions").DeleteMany(ctx, bson.M{"email": source})
+			return err
+		},
+		func() error {
+			// The todos follow the account of target; while target is not
+			// stored yet (see EmailChangeService.Confirm) they keep theirs.
+			change := bson.M{"email": target}
+			owner, err := todoOwner(ctx, m.db.Collection("users"), target)
+			if err != nil {
+				return err
+			}
+			if owner != nil {
+				change["userId"] = *owner
+			}
+			for _, collection := range []string{TodoArchiveCollection, "todos"} {
+				res, err := m.db.Collection(collection).UpdateMany(ctx, bson.M{"email": source}, bson.M{"$set": change})
+				if err != nil {
+					return err
+				}
+				summary.Todos += res.ModifiedCount
+			}
+			return nil
+		},
+		func() error {
+			_, err := m.db.Collection("users").DeleteOne(ctx, bson.M{"email": source})
+			return err
+		},
+	)
+	return summary, err
+}
+
+// mergeReactions hands the reactions of source to target, dropping those
+// target already made with the same emoji, archived todos included.
+func (m *MongoAccountMergeRepository) mergeReactions(ctx context.Context, source, target string) error {
+	for _, collection := range []string{"todos", TodoArchiveCollection} {
+		todos := m.db.Collection(collection)
+		cursor, err := todos.Find(ctx, bson.M{"reactions.email": source}, options.Find().SetProjection(bson.M{"reactions": 1}))
+		if err != nil {
+			return err
+		}
+		var reacted []Todo
+		if err := cursor.All(ctx, &reacted); err != nil {
+			return err
+		}
+		for _, todo := range reacted {
+			if _, err := todos.UpdateOne(ctx, bson.M{"_id": todo.ID},
+				bson.M{"$set": bson.M{"reactions": MergeReactions(todo.Reactions, source, target)}}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mergeLists hands the memberships of source to target and returns in how
+// many lists source was a member.
+func (m *MongoAccountMergeRepository) mergeLists(ctx context.Context, source, target string) (int64, error) {
+	lists := m.db.Collection("todo_lists")
+	cursor, err := lists.Find(ctx, bson.M{"members.email": source})
+	if err != nil {
+		return 0, err
+	}
+	var shared []TodoList
+	if err := cursor.All(ctx, &shared); err != nil {
+		return 0, err
+	}
+	for _, list := range shared {
+		if _, err := lists.UpdateOne(ctx, bson.M{"_id": list.ID},
+			bson.M{"$set": bson.M{"members": MergeMembers(list.Members, source, target)}}); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(shared)), nil
+}
+
+// MergeReactions returns reactions with those of source made by target,
+// except the emojis target already reacted with.
+func MergeReactions(reactions []TodoReaction, source, target string) []TodoReaction {
+	reacted := map[string]bool{}
+	for _, reaction := range reactions {
+		if reaction.Email == target {
+			reacted[reaction.Emoji] = true
+		}
+	}
+	merged := make([]TodoReaction, 0, len(reactions))
+	for _, reaction := range reactions {
+		if reaction.Email == source {
+			if reacted[reaction.Emoji] {
+				continue
+			}
+			reaction.Email = target
+		}
+		merged = append(merged, reaction)
+	}
+	return merged
+}
+
+// MergeMembers returns members with source replaced by target; when both
+// are members, target keeps the higher of their roles.
+func MergeMembers(members []ListMember, source, target string) []ListMember {
+	var from *ListMember
+	for i := range members {
+		if members[i].Email == source {
+			from = &members[i]
+		}
+	}
+	if from == nil {
+		return members
+	}
+	merged := make([]ListMember, 0, len(members))
+	found := false
+	for _, member := range members {
+		if member.Email == target {
+			member.Role, found = policy.Higher(member.Role, from.Role), true
+		}
+		if member.Email != source {
+			merged = append(merged, member)
+		}
+	}
+	if !found {
+		member := *from
+		member.Email = target
+		merged = append(merged, member)
+	}
+	return merged
+}
+
+// AccountMergeService merges the account a user made with a variant of
+// their email (see MailboxKey), for instance once with a password and once
+// through SSO, into the account they are signed in with.
+type AccountMergeService struct {
+	repo     AccountMergeRepository
+	users    UserRepository
+	sessions *SessionService
+	outbox   Outbox
+	now      func() time.Time
+}
+
+// NewAccountMergeService builds a new AccountMergeService instance;
+// sessions verifies the tokens given as proof of ownership.
+func NewAccountMergeService(repo AccountMergeRepository, users UserRepository, sessions *SessionService, outbox Outbox, now func() time.Time) *AccountMergeService {
+	if now == nil {
+		now = time.Now
+	}
+	return &AccountMergeService{repo: repo, users: users, sessions: sessions, outbox: outbox, now: now}
+}
+
+// Merge moves the records of source into the account of into and deletes
+// source, recording a user.merged event keyed by into. With dryRun it only
+// counts what would be moved, after the same checks.
+func (s *AccountMergeService) Merge(ctx context.Context, into string, source MergeSource, dryRun bool) (MergeSummary, error) {
+	into, from := NormalizeEmail(into), NormalizeEmail(source.Email)
+	if from == "" || from == into {
+		return MergeSummary{}, ErrInvalidMerge
+	}
+	if MailboxKey(from) != MailboxKey(into) {
+		return MergeSummary{}, ErrMergeEmailMismatch
+	}
+	if err := s.verify(ctx, from, source); err != nil {
+		return MergeSummary{}, err
+	}
+
+	if dryRun {
+		summary, err := s.repo.Count(ctx, from)
+		if err != nil {
+			return MergeSummary{}, err
+		}
+		summary.From, summary.Into, summary.DryRun = from, into, true
+		return summary, nil
+	}
+
+	var summary MergeSummary
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		var err error
+		if summary, err = s.repo.Merge(ctx, from, into); err != nil {
+			return nil, err
+		}
+		summary.From, summary.Into = from, into
+		return newEvents(events.UserMerged, into, summary, s.now())
+	})
+	if err != nil {
+		return MergeSummary{}, err
+	}
+	return summary, nil
+}
+
+// verify checks the proof of ownership of the account of email. A missing
+// account fails like a wrong password, so the endpoint does not tell which
+// emails are registered.
+func (s *AccountMergeService) verify(ctx context.Context, email string, source MergeSource) error {
+	user, err := s.users.FindByEmail(ctx, email)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return ErrMergeNotVerified
+	case err != nil:
+		return err
+	case user.SuspendedAt != nil || user.AnonymizedAt != nil:
+		return ErrMergeNotVerified
+	}
+
+	password := NormalizeText(source.Password)
+	if password != "" && user.Password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) == 1 {
+		return nil
+	}
+	if token := NormalizeText(source.Token); token != "" {
+		owner, _, err := s.sessions.Resolve(ctx, token)
+		if err != nil && !errors.Is(err, ErrInvalidSession) {
+			return err
+		}
+		if err == nil && owner.Email == email {
+			return nil
+		}
+	}
+	return ErrMergeNotVerified
+}
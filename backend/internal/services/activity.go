@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// ActivityCollection keeps the activity feed.
+const ActivityCollection = "activity"
+
+const (
+	// activityAwait is how long a tailing cursor waits for new entries
+	// before asking again.
+	activityAwait = 5 * time.Second
+	// activityRetry is the pause before reopening a tailing cursor that
+	// died, e.g. because the collection was still empty.
+	activityRetry = time.Second
+	// namespaceExists is the error code of creating a collection that is
+	// already there.
+	namespaceExists = 48
+)
+
+// ActivityEntry is a domain event in the activity feed of the users it
+// concerns.
+type ActivityEntry struct {
+	ID    primitive.ObjectID `json:"id" bson:"_id"`
+	Users []string           `json:"-" bson:"users"`
+	Type  string             `json:"type" bson:"type"`
+	Key   string             `json:"key" bson:"key"`
+	Data  json.RawMessage    `json:"data,omitempty" bson:"data,omitempty"`
+	Time  time.Time          `json:"time" bson:"time"`
+}
+
+// ActivityRepository keeps the recent entries of the activity feed in a
+// bounded store: the oldest ones roll out as new ones come in.
+type ActivityRepository interface {
+	Append(ctx context.Context, entry ActivityEntry) error
+	// Tail hands fn the entries of email after the entry after, oldest
+	// first, and then the new ones as they are appended, until ctx is done
+	// or fn fails. A zero after starts with the next entry appended.
+	Tail(ctx context.Context, email string, after primitive.ObjectID, fn func(ActivityEntry) error) error
+}
+
+// MongoActivityRepository implements ActivityRepository over a capped
+// collection, which MongoDB keeps in insertion order and within its size,
+// and follows with tailable cursors.
+type MongoActivityRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoActivityRepository creates a repository over collection.
+func NewMongoActivityRepository(collection *mongo.Collection) *MongoActivityRepository {
+	return &MongoActivityRepository{collection: collection}
+}
+
+// EnsureCollection creates the capped collection of the feed, holding up
+// to maxBytes and maxEntries (zero leaves only the size limit). An
+// existing one is resized, which takes MongoDB 6.0.
+func (m *MongoActivityRepository) EnsureCollection(ctx context.Context, maxBytes, maxEntries int64) error {
+	opts := options.CreateCollection().SetCapped(true).SetSizeInBytes(maxBytes)
+	if maxEntries > 0 {
+		opts.SetMaxDocuments(maxEntries)
+	}
+	err := m.collection.Database().CreateCollection(ctx, m.collection.Name(), opts)
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Code != namespaceExists {
+		return err
+	}
+	resize := bson.D{{Key: "collMod", Value: m.collection.Name()}, {Key: "cappedSize", Value: maxBytes}}
+	if maxEntries > 0 {
+		resize = append(resize, bson.E{Key: "cappedMax", Value: maxEntries})
+	}
+	return m.collection.Database().RunCommand(ctx, resize).Err()
+}
+
+// Append implements ActivityRepository.
+func (m *MongoActivityRepository) Append(ctx context.Context, entry ActivityEntry) error {
+	_, err := m.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// Tail implements ActivityRepository with a tailable cursor. The cursor
+// dies when nothing matched yet or its position rolled out of the
+// collection; it is then opened again from the last entry seen.
+func (m *MongoActivityRepository) Tail(ctx context.Context, email string, after primitive.ObjectID, fn func(ActivityEntry) error) error {
+	if after.IsZero() {
+		var last ActivityEntry
+		err := m.collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"$natural": -1}).SetProjection(bson.M{"_id": 1})).Decode(&last)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return err
+		}
+		after = last.ID
+	}
+	for {
+		filter := bson.M{"users": email}
+		if !after.IsZero() {
+			filter["_id"] = bson.M{"$gt": after}
+		}
+		cursor, err := m.collection.Find(ctx, filter, options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(activityAwait))
+		if err != nil {
+			return err
+		}
+		for cursor.Next(ctx) {
+			var entry ActivityEntry
+			if err := cursor.Decode(&entry); err != nil {
+				_ = cursor.Close(context.WithoutCancel(ctx))
+				return err
+			}
+			after = entry.ID
+			if err := fn(entry); err != nil {
+				_ = cursor.Close(context.WithoutCancel(ctx))
+				return err
+			}
+		}
+		err = cursor.Err()
+		_ = cursor.Close(context.WithoutCancel(ctx))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("se corto el cursor de la actividad: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(activityRetry):
+		}
+	}
+}
+
+// ActivityFeed keeps the domain events in the activity feed of the users
+// they concern and streams them. It is meant to be one of the publishers
+// of the outbox relay, like RealtimePublisher.
+type ActivityFeed struct {
+	repo     ActivityRepository
+	audience eventAudience
+	ids      IDGenerator
+}
+
+// NewActivityFeed builds a new ActivityFeed instance.
+func NewActivityFeed(repo ActivityRepository, todos TodoRepository, bookings BookingRepository, ids IDGenerator) *ActivityFeed {
+	return &ActivityFeed{repo: repo, audience: eventAudience{todos: todos, bookings: bookings}, ids: ids}
+}
+
+// Publish implements events.Publisher. Like the realtime channel the feed
+// is best effort: failures are logged and not returned.
+func (f *ActivityFeed) Publish(ctx context.Context, event events.Event) error {
+	users, err := f.audience.users(ctx, event)
+	if errors.Is(err, ErrNotFound) || (err == nil && len(users) == 0) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("no se pudo resolver a quien mostrar el evento %s en la actividad: %v", event.ID, err)
+		return nil
+	}
+	entry := ActivityEntry{ID: f.ids.NewID(), Users: users, Type: event.Type, Key: event.Key, Data: event.Data, Time: event.Time}
+	if err := f.repo.Append(ctx, entry); err != nil {
+		log.Printf("no se pudo guardar el evento %s en la actividad: %v", event.ID, err)
+	}
+	return nil
+}
+
+// Follow hands fn the activity of email after the entry after, and then
+// the new entries as they come, until ctx is done or fn fails.
+func (f *ActivityFeed) Follow(ctx context.Context, email string, after primitive.ObjectID, fn func(ActivityEntry) error) error {
+	return f.repo.Tail(ctx, NormalizeEmail(email), after, fn)
+}
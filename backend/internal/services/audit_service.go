@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalidAuditQuery indicates malformed audit filters, limit or cursor.
+var ErrInvalidAuditQuery = errors.New("invalid audit query")
+
+const (
+	// defaultAuditLimit is the page size of the audit trail when none is
+	// given; maxAuditLimit caps it.
+	defaultAuditLimit = 50
+	maxAuditLimit     = 100
+	// MaxAuditExport caps the entries of one audit export.
+	MaxAuditExport = 10000
+)
+
+// AuditAdmin is the actor recorded for the requests made with the admin
+// token.
+const AuditAdmin = "admin"
+
+type actorKey struct{}
+
+// WithActor records in ctx who is acting, so the events stored with it
+// keep their author in the audit trail.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor recorded by WithActor; it is empty for the
+// changes made by the system itself, such as the scheduled jobs.
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// AuditQuery filters the audit trail. Actor, Type and Resource (the key of
+// the event: user email, todo or booking ID...) must match exactly; From
+// and To bound the time of the events, To excluded. Cursor continues a
+// previous page.
+type AuditQuery struct {
+	Actor    string
+	Type     string
+	Resource string
+	From     time.Time
+	To       time.Time
+	Cursor   string
+	Limit    int
+}
+
+// AuditEntry is one event of the audit trail.
+type AuditEntry struct {
+	ID       string          `json:"id"`
+	Time     time.Time       `json:"time"`
+	Type     string          `json:"type"`
+	Actor    string          `json:"actor,omitempty"`
+	Resource string          `json:"resource"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// AuditPage is one page of the audit trail, newest first; NextCursor is
+// empty on the last page.
+type AuditPage struct {
+	Entries    []AuditEntry
+	NextCursor string
+}
+
+// AuditPosition is where a page of the audit trail ends.
+type AuditPosition struct {
+	Time time.Time
+	ID   string
+}
+
+// AuditFilter is an AuditQuery as handed to the repository: After is the
+// last entry of the previous page, if any.
+type AuditFilter struct {
+	Actor    string
+	Type     string
+	Resource string
+	From     time.Time
+	To       time.Time
+	After    *AuditPosition
+	Limit    int
+}
+
+// AuditRepository reads the stored events, newest first (ties broken by
+// ID, descending).
+type AuditRepository interface {
+	Find(ctx context.Context, filter AuditFilter) ([]OutboxMessage, error)
+}
+
+// MongoAuditRepository implements AuditRepository over the outbox
+// collection, where every domain event is kept.
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAuditRepository creates a repository over the outbox collection.
+func NewMongoAuditRepository(collection *mongo.Collection) *MongoAuditRepository {
+	return &MongoAuditRepository{collection: collection}
+}
+
+// EnsureIndexes creates the indexes behind the audit filters.
+func (m *MongoAuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "event.time", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "event.time", Value: -1}}},
+		{Keys: bson.D{{Key: "event.key", Value: 1}, {Key: "event.time", Value: -1}}},
+	})
+	return err
+}
+
+// Find implements AuditRepository.
+func (m *MongoAuditRepository) Find(ctx context.Context, filter AuditFilter) ([]OutboxMessage, error) {
+	query := bson.M{}
+	if filter.Actor != "" {
+		query["actor"] = filter.Actor
+	}
+	if filter.Type != "" {
+		query["event.type"] = filter.Type
+	}
+	if filter.Resource != "" {
+		query["event.key"] = filter.Resource
+	}
+	between := bson.M{}
+	if !filter.From.IsZero() {
+		between["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		between["$lt"] = filter.To
+	}
+	if len(between) > 0 {
+		query["event.time"] = between
+	}
+	if filter.After != nil {
+		query["$or"] = bson.A{
+			bson.M{"event.time": bson.M{"$lt": filter.After.Time}},
+			bson.M{"event.time": filter.After.Time, "_id": bson.M{"$lt": filter.After.ID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "event.time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(filter.Limit))
+	cursor, err := m.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []OutboxMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// AuditService queries the audit trail for compliance reviews.
+type AuditService struct {
+	repo AuditRepository
+}
+
+// NewAuditService builds a new AuditService instance.
+func NewAuditService(repo AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Query returns a page of the events matching query, newest first. The
+// limit defaults to 50 and goes up to 100.
+func (s *AuditService) Query(ctx context.Context, query AuditQuery) (AuditPage, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultAuditLimit
+	}
+	if query.Limit < 0 || query.Limit > maxAuditLimit {
+		return AuditPage{}, ErrInvalidAuditQuery
+	}
+	return s.page(ctx, query, query.Limit)
+}
+
+// Export calls fn with every event matching query, newest first, up to
+// MaxAuditExport; query.Limit is ignored.
+func (s *AuditService) Export(ctx context.Context, query AuditQuery, fn func(AuditEntry) error) error {
+	exported := 0
+	for exported < MaxAuditExport {
+		page, err := s.page(ctx, query, min(maxAuditLimit, MaxAuditExport-exported))
+		if err != nil {
+			return err
+		}
+		for _, entry := range page.Entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		exported += len(page.Entries)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	return nil
+}
+
+func (s *AuditService) page(ctx context.Context, query AuditQuery, limit int) (AuditPage, error) {
+	if !query.From.IsZero() && !query.To.IsZero() && !query.To.After(query.From) {
+		return AuditPage{}, ErrInvalidAuditQuery
+	}
+	filter := AuditFilter{
+		Actor:    NormalizeText(query.Actor),
+		Type:     NormalizeText(query.Type),
+		Resource: NormalizeText(query.Resource),
+		From:     query.From,
+		To:       query.To,
+		// One more tells whether there is a next page.
+		Limit: limit + 1,
+	}
+	if query.Cursor != "" {
+		after, ok := decodeAuditCursor(query.Cursor)
+		if !ok {
+			return AuditPage{}, ErrInvalidAuditQuery
+		}
+		filter.After = &after
+	}
+
+	messages, err := s.repo.Find(ctx, filter)
+	if err != nil {
+		return AuditPage{}, err
+	}
+	page := AuditPage{Entries: make([]AuditEntry, 0, min(len(messages), limit))}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		last := messages[limit-1]
+		page.NextCursor = encodeAuditCursor(AuditPosition{Time: last.Event.Time, ID: last.ID})
+	}
+	for _, msg := range messages {
+		page.Entries = append(page.Entries, AuditEntry{
+			ID:       msg.ID,
+			Time:     msg.Event.Time,
+			Type:     msg.Event.Type,
+			Actor:    msg.Actor,
+			Resource: msg.Event.Key,
+			Data:     msg.Event.Data,
+		})
+	}
+	return page, nil
+}
+
+// encodeAuditCursor hides the position of the last entry of a page in an
+// opaque token.
+func encodeAuditCursor(position AuditPosition) string {
+	raw := strconv.FormatInt(position.Time.UnixNano(), 10) + "." + position.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeAuditCursor(cursor string) (AuditPosition, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return AuditPosition{}, false
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok || id == "" {
+		return AuditPosition{}, false
+	}
+	at, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return AuditPosition{}, false
+	}
+	return AuditPosition{Time: time.Unix(0, at).UTC(), ID: id}, true
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BackupVersion is the version of the archive layout written by Backup.
+const BackupVersion = 1
+
+// backupManifest is the name of the archive entry that describes it.
+const backupManifest = "manifest.json"
+
+// restoreBatchSize caps the documents inserted at once by a restore.
+const restoreBatchSize = 1000
+
+var (
+	// ErrInvalidBackup indicates an archive that is not a backup of this
+	// API, or one that is truncated or corrupt.
+	ErrInvalidBackup = errors.New("invalid backup")
+	// ErrUnknownCollection is returned when dumping a collection that is
+	// not one of BackupCollections.
+	ErrUnknownCollection = errors.New("unknown collection")
+)
+
+// BackupCollections are the collections copied by a backup. Sessions,
+// WebAuthn ceremonies, login throttling, the outbox and the scheduler state
+// are left out: they only make sense in the environment that wrote them,
+// and restoring the outbox would publish its events again.
+var BackupCollections = []string{
+	"users", "properties", "todos", "rooms", "bookings", "guests",
+	"payments", "payment_events", "reviews", "rate_plans", "waitlist",
+	"passkeys", "logins", "mail_log", "mail_opt_outs", "import_runs", "erasures",
+	"todo_comments", "notifications", "todo_lists", "todo_attachments",
+}
+
+// BackupManifest describes an archive: when it was written and how many
+// documents each collection holds.
+type BackupManifest struct {
+	Version     int              `json:"version" xml:"version"`
+	CreatedAt   time.Time        `json:"createdAt" xml:"createdAt"`
+	Collections map[string]int64 `json:"collections" xml:"-"`
+}
+
+// BackupRepository reads and replaces whole collections as raw documents.
+type BackupRepository interface {
+	// Export calls fn with every document of collection, in _id order.
+	Export(ctx context.Context, collection string, fn func(doc bson.Raw) error) error
+	// Replace deletes every document of collection and inserts docs.
+	Replace(ctx context.Context, collection string, docs []bson.Raw) error
+}
+
+// MongoBackupRepository implements BackupRepository over the collections of
+// db.
+type MongoBackupRepository struct {
+	db *Database
+}
+
+// NewMongoBackupRepository creates a repository over db.
+func NewMongoBackupRepository(db *Database) *MongoBackupRepository {
+	return &MongoBackupRepository{db: db}
+}
+
+// Export implements BackupRepository.
+func (m *MongoBackupRepository) Export(ctx context.Context, collection string, fn func(doc bson.Raw) error) error {
+	cursor, err := m.db.Collection(collection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if err := fn(cursor.Current); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// Replace implements BackupRepository. It is not atomic: a failure halfway
+// leaves the collection partially restored, so the restore has to be run
+// again.
+func (m *MongoBackupRepository) Replace(ctx context.Context, collection string, docs []bson.Raw) error {
+	coll := m.db.Collection(collection)
+	if _, err := coll.DeleteMany(ctx, bson.M{}); err != nil {
+		return err
+	}
+	for start := 0; start < len(docs); start += restoreBatchSize {
+		batch := docs[start:min(start+restoreBatchSize, len(docs))]
+		values := make([]any, len(batch))
+		for i, doc := range batch {
+			values[i] = doc
+		}
+		if _, err := coll.InsertMany(ctx, values, options.InsertMany().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackupService writes the collections to a gzip-compressed tar archive with
+// one JSON lines file per collection, and restores them from it. Documents
+// are encoded as canonical Extended JSON so ObjectIDs and dates survive the
+// round trip.
+type BackupService struct {
+	repo BackupRepository
+	now  func() time.Time
+}
+
+// NewBackupService builds a new BackupService instance.
+func NewBackupService(repo BackupRepository, now func() time.Time) *BackupService {
+	if now == nil {
+		now = time.Now
+	}
+	return &BackupService{repo: repo, now: now}
+}
+
+// Backup streams the archive to w. The manifest goes last, once the
+// documents have been counted.
+func (s *BackupService) Backup(ctx context.Context, w io.Writer) (BackupManifest, error) {
+	manifest := BackupManifest{Version: BackupVersion, CreatedAt: s.now().UTC(), Collections: map[string]int64{}}
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+
+	for _, collection := range BackupCollections {
+		count, err := s.backupCollection(ctx, archive, collection, manifest.CreatedAt)
+		if err != nil {
+			return manifest, fmt.Errorf("%s: %w", collection, err)
+		}
+		manifest.Collections[collection] = count
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	header := &tar.Header{Name: backupManifest, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.CreatedAt}
+	if err := archive.WriteHeader(header); err != nil {
+		return manifest, err
+	}
+	if _, err := archive.Write(data); err != nil {
+		return manifest, err
+	}
+	if err := archive.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, compressed.Close()
+}
+
+// backupCollection adds the entry of collection to archive. tar needs the
+// size of an entry before its contents, so the lines are spooled to a
+// temporary file instead of being held in memory.
+func (s *BackupService) backupCollection(ctx context.Context, archive *tar.Writer, collection string, at time.Time) (int64, error) {
+	spool, err := os.CreateTemp("", "backup-*.jsonl")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	var count int64
+	lines := bufio.NewWriter(spool)
+	err = s.repo.Export(ctx, collection, func(doc bson.Raw) error {
+		line, err := bson.MarshalExtJSON(doc, true, false)
+		if err != nil {
+			return err
+		}
+		count++
+		if _, err := lines.Write(line); err != nil {
+			return err
+		}
+		return lines.WriteByte('\n')
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := lines.Flush(); err != nil {
+		return 0, err
+	}
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := archive.WriteHeader(&tar.Header{Name: collection + ".jsonl", Mode: 0o600, Size: size, ModTime: at}); err != nil {
+		return 0, err
+	}
+	_, err = io.Copy(archive, spool)
+	return count, err
+}
+
+// Dump calls fn with every document of collection, one of
+// BackupCollections, in _id order and as relaxed Extended JSON. Documents
+// are read from the cursor as fn returns, so a slow consumer holds the
+// cursor back instead of the collection piling up in memory.
+func (s *BackupService) Dump(ctx context.Context, collection string, fn func(doc json.RawMessage) error) error {
+	if !slices.Contains(BackupCollections, collection) {
+		return ErrUnknownCollection
+	}
+	return s.repo.Export(ctx, collection, func(doc bson.Raw) error {
+		line, err := bson.MarshalExtJSON(doc, false, false)
+		if err != nil {
+			return err
+		}
+		return fn(line)
+	})
+}
+
+// Restore reads a whole archive written by Backup and, only if every entry
+// is valid and matches the manifest, replaces the collections it holds.
+// Collections missing from the archive are left untouched.
+func (s *BackupService) Restore(ctx context.Context, r io.Reader) (BackupManifest, error) {
+	manifest, collections, err := readBackup(r)
+	if err != nil {
+		return BackupManifest{}, err
+	}
+	for _, collection := range BackupCollections {
+		docs, ok := collections[collection]
+		if !ok {
+			continue
+		}
+		if err := s.repo.Replace(ctx, collection, docs); err != nil {
+			return BackupManifest{}, fmt.Errorf("%s: %w", collection, err)
+		}
+	}
+	return manifest, nil
+}
+
+// readBackup decodes and validates an archive.
+func readBackup(r io.Reader) (BackupManifest, map[string][]bson.Raw, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return BackupManifest{}, nil, ErrInvalidBackup
+	}
+	defer compressed.Close()
+
+	var manifest *BackupManifest
+	collections := map[string][]bson.Raw{}
+	archive := tar.NewReader(compressed)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return BackupManifest{}, nil, ErrInvalidBackup
+		}
+		if header.Name == backupManifest {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(archive).Decode(manifest); err != nil {
+				return BackupManifest{}, nil, ErrInvalidBackup
+			}
+			continue
+		}
+		collection, ok := strings.CutSuffix(header.Name, ".jsonl")
+		if !ok || !slices.Contains(BackupCollections, collection) {
+			return BackupManifest{}, nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBackup, header.Name)
+		}
+		docs, err := readDocuments(archive)
+		if err != nil {
+			return BackupManifest{}, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, collection, err)
+		}
+		collections[collection] = docs
+	}
+
+	if manifest == nil || manifest.Version != BackupVersion {
+		return BackupManifest{}, nil, fmt.Errorf("%w: missing manifest or unsupported version", ErrInvalidBackup)
+	}
+	for collection, count := range manifest.Collections {
+		if int64(len(collections[collection])) != count {
+			return BackupManifest{}, nil, fmt.Errorf("%w: %s has %d documents, the manifest says %d",
+				ErrInvalidBackup, collection, len(collections[collection]), count)
+		}
+	}
+	for collection := range collections {
+		if _, ok := manifest.Collections[collection]; !ok {
+			return BackupManifest{}, nil, fmt.Errorf("%w: %s is not in the manifest", ErrInvalidBackup, collection)
+		}
+	}
+	return *manifest, collections, nil
+}
+
+// readDocuments decodes one Extended JSON document per line; every document
+// needs an _id.
+func readDocuments(r io.Reader) ([]bson.Raw, error) {
+	var docs []bson.Raw
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(scanner.Bytes(), true, &doc); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if _, err := bson.Raw(raw).LookupErr("_id"); err != nil {
+			return nil, fmt.Errorf("line %d: missing _id", line)
+		}
+		docs = append(docs, raw)
+	}
+	return docs, scanner.Err()
+}
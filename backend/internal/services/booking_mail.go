@@ -0,0 +1,424 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+)
+
+// Email kinds; each names a template file (<kind>.tmpl) defining the
+// "subject" and "body" templates.
+const (
+	MailConfirmation = "confirmation"
+	MailReminder     = "reminder"
+	MailReview       = "review"
+	MailDigest       = "digest"
+	MailMention      = "mention"
+	// MailEmailChange asks to confirm a new email of an account and
+	// MailEmailChangeNotice warns the old one; both ignore the opt-outs.
+	MailEmailChange       = "email-change"
+	MailEmailChangeNotice = "email-change-notice"
+)
+
+var mailKinds = []string{MailConfirmation, MailReminder, MailReview, MailDigest, MailMention, MailEmailChange, MailEmailChangeNotice}
+
+// ErrInvalidOptOutToken is returned when an opt-out link was not signed by
+// this server.
+var ErrInvalidOptOutToken = errors.New("invalid opt-out token")
+
+//go:embed mail/*.tmpl
+var defaultMailTemplates embed.FS
+
+// MailTemplates holds the parsed template of each email kind.
+type MailTemplates map[string]*template.Template
+
+// LoadMailTemplates parses the booking email templates from dir, or the
+// built-in ones when dir is empty. Every kind must be present.
+func LoadMailTemplates(dir string) (MailTemplates, error) {
+	var fsys fs.FS = defaultMailTemplates
+	prefix := "mail/"
+	if dir != "" {
+		fsys, prefix = os.DirFS(dir), ""
+	}
+
+	templates := make(MailTemplates, len(mailKinds))
+	for _, kind := range mailKinds {
+		tmpl, err := template.ParseFS(fsys, prefix+kind+".tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("plantilla de email %s: %w", kind, err)
+		}
+		if tmpl.Lookup("subject") == nil || tmpl.Lookup("body") == nil {
+			return nil, fmt.Errorf("plantilla de email %s: faltan subject o body", kind)
+		}
+		templates[kind] = tmpl
+	}
+	return templates, nil
+}
+
+// MailRepository records the booking emails already sent and the guests
+// who opted out of them.
+type MailRepository interface {
+	// MarkSent records key and reports false when it was already recorded.
+	MarkSent(ctx context.Context, key string, at time.Time) (bool, error)
+	// Forget removes key so a failed email is attempted again.
+	Forget(ctx context.Context, key string) error
+	OptedOut(ctx context.Context, email string) (bool, error)
+	OptOut(ctx context.Context, email string, at time.Time) error
+}
+
+// MongoMailRepository implements MailRepository over a sent-email log and
+// an opt-out collection, both keyed by _id.
+type MongoMailRepository struct {
+	sent    *mongo.Collection
+	optOuts *mongo.Collection
+}
+
+// NewMongoMailRepository creates a repository over the mail log and opt-out
+// collections.
+func NewMongoMailRepository(sent, optOuts *mongo.Collection) *MongoMailRepository {
+	return &MongoMailRepository{sent: sent, optOuts: optOuts}
+}
+
+// MarkSent inserts key; the _id index rejects a second insert.
+func (m *MongoMailRepository) MarkSent(ctx context.Context, key string, at time.Time) (bool, error) {
+	_, err := m.sent.InsertOne(ctx, bson.M{"_id": key, "sentAt": at})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Forget deletes key from the log.
+func (m *MongoMailRepository) Forget(ctx context.Context, key string) error {
+	_, err := m.sent.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// OptedOut reports whether email opted out.
+func (m *MongoMailRepository) OptedOut(ctx context.Context, email string) (bool, error) {
+	err := m.optOuts.FindOne(ctx, bson.M{"_id": email}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// OptOut records email, keeping the first opt-out date.
+func (m *MongoMailRepository) OptOut(ctx context.Context, email string, at time.Time) error {
+	_, err := m.optOuts.UpdateOne(ctx,
+		bson.M{"_id": email},
+		bson.M{"$setOnInsert": bson.M{"optedOutAt": at}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// BookingMailerConfig tunes the booking emails.
+type BookingMailerConfig struct {
+	// ReminderDays is how many days before arrival the reminder is sent;
+	// zero disables reminders.
+	ReminderDays int
+	// BaseURL prefixes the links in the emails.
+	BaseURL string
+	// OptOutSecret signs the opt-out links; without it emails carry no
+	// opt-out link and OptOut rejects every request.
+	OptOutSecret string
+}
+
+// BookingMailer emails guests along their booking: a confirmation when it
+// is created, a reminder before arrival and a review request after
+// check-out. Each email is sent at most once per booking, and never to
+// guests who opted out.
+type BookingMailer struct {
+	mail        MailRepository
+	sender      mailer.Mailer
+	deadLetters DeadLetterRepository
+	bookings    BookingRepository
+	rooms       RoomRepository
+	templates   MailTemplates
+	cfg         BookingMailerConfig
+	now         func() time.Time
+	// notifications, when set, gets a reminder notification along each
+	// arrival reminder.
+	notifications *NotificationService
+	// deliveries, when set, runs the deliveries to each recipient.
+	deliveries *DeliveryPool
+}
+
+// NewBookingMailer builds a new BookingMailer instance; the emails sender
+// fails to deliver are stored in deadLetters.
+func NewBookingMailer(mail MailRepository, sender mailer.Mailer, deadLetters DeadLetterRepository, bookings BookingRepository, rooms RoomRepository, templates MailTemplates, cfg BookingMailerConfig, now func() time.Time) *BookingMailer {
+	if now == nil {
+		now = time.Now
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &BookingMailer{mail: mail, sender: sender, deadLetters: deadLetters, bookings: bookings, rooms: rooms, templates: templates, cfg: cfg, now: now}
+}
+
+// HandleBookingEvent sends the confirmation and review request emails in
+// the background, so a slow mail server does not delay the request. It is
+// meant to be registered with BookingService.Subscribe.
+func (m *BookingMailer) HandleBookingEvent(ctx context.Context, event BookingEvent) {
+	var kind string
+	switch event.Type {
+	case EventBookingCreated:
+		kind = MailConfirmation
+	case EventBookingCheckedOut:
+		kind = MailReview
+	default:
+		return
+	}
+
+	m.background(ctx, event.Booking.Email, func(ctx context.Context) {
+		if err := m.deliver(ctx, kind, event.Booking); err != nil {
+			log.Printf("no se pudo enviar el email %s de la reserva %s: %v", kind, event.Booking.ID.Hex(), err)
+		}
+	})
+}
+
+// SetNotifications makes the arrival reminders also reach the in-app inbox
+// of the guests.
+func (m *BookingMailer) SetNotifications(notifications *NotificationService) {
+	m.notifications = notifications
+}
+
+// SetDeliveryPool runs the emails and notifications through deliveries,
+// which bounds how many go out at once and how often each recipient gets
+// one. Without it every background email gets a goroutine of its own and
+// the jobs send one email at a time.
+func (m *BookingMailer) SetDeliveryPool(deliveries *DeliveryPool) {
+	m.deliveries = deliveries
+}
+
+// background runs task, a delivery to to, without waiting for it.
+func (m *BookingMailer) background(ctx context.Context, to string, task func(context.Context)) {
+	if m.deliveries == nil {
+		go task(context.WithoutCancel(ctx))
+		return
+	}
+	if err := m.deliveries.Submit(ctx, to, task); err != nil {
+		log.Printf("no se pudo encolar la entrega a %s: %v", to, err)
+	}
+}
+
+// fanOut runs task(i) for every i of destinations, a delivery to
+// destinations[i], and waits for all of them.
+func (m *BookingMailer) fanOut(ctx context.Context, destinations []string, task func(ctx context.Context, i int)) {
+	if m.deliveries == nil {
+		for i := range destinations {
+			task(ctx, i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	for i, to := range destinations {
+		wg.Add(1)
+		err := m.deliveries.Submit(ctx, to, func(ctx context.Context) {
+			defer wg.Done()
+			task(ctx, i)
+		})
+		if err != nil {
+			wg.Done()
+			log.Printf("no se pudo encolar la entrega a %s: %v", to, err)
+		}
+	}
+	wg.Wait()
+}
+
+// SendReminders emails the guests arriving in ReminderDays days, and
+// notifies them in the inbox when SetNotifications was called.
+func (m *BookingMailer) SendReminders(ctx context.Context) error {
+	if m.cfg.ReminderDays <= 0 {
+		return nil
+	}
+
+	arrival := m.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, m.cfg.ReminderDays)
+	bookings, err := m.bookings.List(ctx, BookingQuery{StayFrom: arrival, StayTo: arrival.AddDate(0, 0, 1)})
+	if err != nil {
+		return err
+	}
+	var due []Booking
+	var guests []string
+	for _, booking := range bookings {
+		if booking.Status == BookingBooked && booking.CheckIn.Equal(arrival) {
+			due, guests = append(due, booking), append(guests, booking.Email)
+		}
+	}
+	m.fanOut(ctx, guests, func(ctx context.Context, i int) {
+		m.remind(ctx, due[i])
+	})
+	return nil
+}
+
+// remind emails the arrival reminder of booking and notifies it in the
+// inbox.
+func (m *BookingMailer) remind(ctx context.Context, booking Booking) {
+	if err := m.deliver(ctx, MailReminder, booking); err != nil {
+		log.Printf("no se pudo enviar el recordatorio de la reserva %s: %v", booking.ID.Hex(), err)
+	}
+	if m.notifications == nil {
+		return
+	}
+	err := m.notifications.Notify(ctx, Notification{
+		Email:     booking.Email,
+		Kind:      NotificationReminder,
+		Key:       NotificationReminder + ":" + booking.ID.Hex(),
+		BookingID: booking.ID,
+	})
+	if err != nil {
+		log.Printf("no se pudo notificar el recordatorio de la reserva %s: %v", booking.ID.Hex(), err)
+	}
+}
+
+// OptOut stops the booking emails to email. token must be the one of the
+// opt-out link sent in the emails.
+func (m *BookingMailer) OptOut(ctx context.Context, email, token string) error {
+	email = NormalizeEmail(email)
+	expected, ok := m.optOutToken(email)
+	if !ok || email == "" || !hmac.Equal([]byte(expected), []byte(strings.ToLower(NormalizeText(token)))) {
+		return ErrInvalidOptOutToken
+	}
+	return m.mail.OptOut(ctx, email, m.now())
+}
+
+// optOutToken returns the hex HMAC-SHA256 of email, or false without a secret.
+func (m *BookingMailer) optOutToken(email string) (string, bool) {
+	if m.cfg.OptOutSecret == "" {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, []byte(m.cfg.OptOutSecret))
+	mac.Write([]byte(email))
+	return hex.EncodeToString(mac.Sum(nil)), true
+}
+
+// deliver sends the kind email of booking at most once.
+func (m *BookingMailer) deliver(ctx context.Context, kind string, booking Booking) error {
+	return m.send(ctx, kind+":"+booking.ID.Hex(), booking.Email, kind, func() any {
+		return m.bookingData(ctx, booking)
+	})
+}
+
+// send emails the kind template, rendered with data, to to unless they
+// opted out or key was already sent. An email the server rejects becomes a
+// dead letter; any other failure is forgotten so the email can be retried.
+func (m *BookingMailer) send(ctx context.Context, key, to, kind string, data func() any) error {
+	optedOut, err := m.mail.OptedOut(ctx, to)
+	if err != nil || optedOut {
+		return err
+	}
+	return m.sendOnce(ctx, key, to, kind, data)
+}
+
+// sendOnce is send without the opt-out check, for the emails about the
+// security of the account.
+func (m *BookingMailer) sendOnce(ctx context.Context, key, to, kind string, data func() any) error {
+	first, err := m.mail.MarkSent(ctx, key, m.now())
+	if err != nil || !first {
+		return err
+	}
+
+	msg, err := m.render(kind, to, data())
+	if err == nil {
+		if err = m.sender.Send(ctx, msg); err != nil && m.bury(ctx, key, msg, err) {
+			return err
+		}
+	}
+	if err != nil {
+		if forgetErr := m.mail.Forget(ctx, key); forgetErr != nil {
+			log.Printf("no se pudo liberar el email %s: %v", key, forgetErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// bury stores an email the mail server rejected as a dead letter, which
+// then owns its retries, and reports whether it did.
+func (m *BookingMailer) bury(ctx context.Context, key string, msg mailer.Message, cause error) bool {
+	letter, err := NewDeadLetter(DeadLetterEmail, msg.To, key, msg, 1, cause, m.now())
+	if err == nil {
+		_, err = m.deadLetters.Create(ctx, letter)
+	}
+	if err != nil {
+		log.Printf("no se pudo guardar el email fallido %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// Redeliver sends the email of a dead letter again. It is meant to be
+// registered with DeadLetterService.Handle.
+func (m *BookingMailer) Redeliver(ctx context.Context, payload json.RawMessage) error {
+	var msg mailer.Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return err
+	}
+	return m.sender.Send(ctx, msg)
+}
+
+// bookingMailData is the data available to the email templates.
+type bookingMailData struct {
+	Booking   BookingResponse
+	Room      string
+	Days      int
+	ReviewURL string
+	OptOutURL string
+}
+
+func (m *BookingMailer) bookingData(ctx context.Context, booking Booking) bookingMailData {
+	data := bookingMailData{
+		Booking:   booking.ToResponse(),
+		Days:      m.cfg.ReminderDays,
+		ReviewURL: m.cfg.BaseURL + "/bookings/" + booking.ID.Hex() + "/review",
+		OptOutURL: m.optOutURL(booking.Email),
+	}
+	if room, err := m.rooms.FindByID(ctx, booking.RoomID); err == nil {
+		data.Room = room.Number
+	}
+	return data
+}
+
+// optOutURL returns the signed opt-out link of email, or "" without a secret.
+func (m *BookingMailer) optOutURL(email string) string {
+	token, ok := m.optOutToken(email)
+	if !ok {
+		return ""
+	}
+	return m.cfg.BaseURL + "/mail/opt-out?" + url.Values{"email": {email}, "token": {token}}.Encode()
+}
+
+func (m *BookingMailer) render(kind, to string, data any) (mailer.Message, error) {
+	tmpl := m.templates[kind]
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
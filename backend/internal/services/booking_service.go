@@ -0,0 +1,799 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// DateLayout is the format used for stay dates in the API.
+const DateLayout = "2006-01-02"
+
+// Booking statuses. A booking moves booked -> checked_in -> checked_out, or
+// booked -> cancelled. Rooms offered to the waitlist start as held and move
+// to booked when the guest confirms, or to cancelled when the hold expires.
+const (
+	BookingHeld       = "held"
+	BookingBooked     = "booked"
+	BookingCheckedIn  = "checked_in"
+	BookingCheckedOut = "checked_out"
+	BookingCancelled  = "cancelled"
+)
+
+// activeBookingStatuses are the statuses that occupy a room.
+var activeBookingStatuses = []string{BookingHeld, BookingBooked, BookingCheckedIn}
+
+// Booking lifecycle events.
+const (
+	EventBookingCreated    = "booking.created"
+	EventBookingCancelled  = "booking.cancelled"
+	EventBookingCheckedIn  = "booking.checked_in"
+	EventBookingCheckedOut = "booking.checked_out"
+)
+
+// BookingEvent describes a booking lifecycle change.
+type BookingEvent struct {
+	Type    string
+	Booking Booking
+	At      time.Time
+}
+
+// BookingEventHandler reacts to booking events (e.g. housekeeping).
+type BookingEventHandler func(ctx context.Context, event BookingEvent)
+
+var (
+	// ErrInvalidBookingInput indicates missing or malformed booking data.
+	ErrInvalidBookingInput = errors.New("invalid booking input")
+	// ErrInvalidBookingID indicates the booking ID could not be parsed.
+	ErrInvalidBookingID = errors.New("invalid booking id")
+	// ErrInvalidStayDates indicates unparsable, reversed or past stay dates.
+	ErrInvalidStayDates = errors.New("invalid stay dates")
+	// ErrBookingRoomNotFound is returned when the booked room does not exist.
+	ErrBookingRoomNotFound = errors.New("booking room not found")
+	// ErrBookingGuestNotFound is returned when the referenced guest does not exist.
+	ErrBookingGuestNotFound = errors.New("booking guest not found")
+	// ErrBookingOverlap is returned when the room is already booked for
+	// some of the requested nights.
+	ErrBookingOverlap = errors.New("booking overlaps an existing one")
+	// ErrBookingStateConflict is returned when the booking is not in the
+	// status required by the operation (e.g. modifying a cancelled one).
+	ErrBookingStateConflict = errors.New("booking state conflict")
+	// ErrCheckInOutsideStay is returned when checking in before the arrival
+	// date or after the departure date.
+	ErrCheckInOutsideStay = errors.New("check-in outside the stay dates")
+	// ErrDuplicateExternalRef is returned when the channel already imported
+	// a booking with the same external reference.
+	ErrDuplicateExternalRef = errors.New("duplicate external booking reference")
+)
+
+// BookingInput carries the raw booking fields received from clients.
+type BookingInput struct {
+	RoomID string
+	// GuestID optionally links the booking to a guest profile; Email then
+	// defaults to the guest email.
+	GuestID  string
+	Email    string
+	Guests   int
+	CheckIn  string
+	CheckOut string
+	// Channel and ExternalRef identify a booking imported from an OTA; the
+	// pair is unique.
+	Channel     string
+	ExternalRef string
+}
+
+// BookingChange models the fields that can be modified on a booking.
+type BookingChange struct {
+	RoomID   *string
+	Guests   *int
+	CheckIn  *string
+	CheckOut *string
+}
+
+// BookingQuery filters booking listings; empty fields match every booking.
+type BookingQuery struct {
+	// PropertyID restricts the listing to one property when not zero.
+	PropertyID primitive.ObjectID
+	RoomID     primitive.ObjectID
+	GuestID    primitive.ObjectID
+	Email      string
+	// Channel and ExternalRef, when ExternalRef is set, find an imported
+	// booking.
+	Channel     string
+	ExternalRef string
+	// StayFrom and StayTo, when both set, keep the bookings whose stay
+	// overlaps [StayFrom, StayTo).
+	StayFrom time.Time
+	StayTo   time.Time
+}
+
+// BookingRepository is the storage contract required by the booking service.
+// Create and Update must reject overlapping active bookings atomically.
+type BookingRepository interface {
+	List(ctx context.Context, query BookingQuery) ([]Booking, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (Booking, error)
+	Create(ctx context.Context, booking Booking) (Booking, error)
+	Update(ctx context.Context, booking Booking) (Booking, error)
+	Transition(ctx context.Context, id primitive.ObjectID, from, to string, at time.Time) (Booking, error)
+	// Available returns the free rooms of a property (every property when
+	// propertyID is zero).
+	Available(ctx context.Context, propertyID primitive.ObjectID, checkIn, checkOut time.Time) ([]Room, error)
+}
+
+// MongoBookingRepository implements BookingRepository backed by MongoDB.
+// Writes run inside transactions, so MongoDB must be a replica set.
+type MongoBookingRepository struct {
+	bookings *mongo.Collection
+	rooms    *mongo.Collection
+}
+
+// NewMongoBookingRepository creates a repository over the bookings and rooms
+// collections.
+func NewMongoBookingRepository(bookings, rooms *mongo.Collection) *MongoBookingRepository {
+	return &MongoBookingRepository{bookings: bookings, rooms: rooms}
+}
+
+// EnsureIndexes creates the index used by the overlap checks, the one used
+// to list the bookings of a property and the unique external reference of
+// imported bookings.
+func (m *MongoBookingRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.bookings.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "roomId", Value: 1}, {Key: "checkIn", Value: 1}, {Key: "checkOut", Value: 1}}},
+		{Keys: bson.D{{Key: "propertyId", Value: 1}, {Key: "checkIn", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "channel", Value: 1}, {Key: "externalRef", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("external_ref_unique").
+				SetPartialFilterExpression(bson.M{"externalRef": bson.M{"$exists": true}}),
+		},
+	})
+	return err
+}
+
+// List returns bookings matching query ordered by check-in date.
+func (m *MongoBookingRepository) List(ctx context.Context, query BookingQuery) ([]Booking, error) {
+	filter := bson.M{}
+	if !query.PropertyID.IsZero() {
+		filter["propertyId"] = query.PropertyID
+	}
+	if !query.RoomID.IsZero() {
+		filter["roomId"] = query.RoomID
+	}
+	if !query.GuestID.IsZero() {
+		filter["guestId"] = query.GuestID
+	}
+	if query.Email != "" {
+		filter["email"] = query.Email
+	}
+	if query.ExternalRef != "" {
+		filter["channel"] = query.Channel
+		filter["externalRef"] = query.ExternalRef
+	}
+	if !query.StayFrom.IsZero() && !query.StayTo.IsZero() {
+		filter["checkIn"] = bson.M{"$lt": query.StayTo}
+		filter["checkOut"] = bson.M{"$gt": query.StayFrom}
+	}
+
+	cursor, err := m.bookings.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "checkIn", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bookings []Booking
+	if err := cursor.All(ctx, &bookings); err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+// FindByID retrieves a booking or returns ErrNotFound.
+func (m *MongoBookingRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Booking, error) {
+	var booking Booking
+	err := m.bookings.FindOne(ctx, bson.M{"_id": id}).Decode(&booking)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Booking{}, ErrNotFound
+	}
+	return booking, err
+}
+
+// Create stores booking unless it overlaps an active booking of the room.
+func (m *MongoBookingRepository) Create(ctx context.Context, booking Booking) (Booking, error) {
+	err := m.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := m.reserve(sc, booking); err != nil {
+			return err
+		}
+		res, err := m.bookings.InsertOne(sc, booking)
+		if err != nil {
+			return err
+		}
+		if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+			booking.ID = oid
+		}
+		return nil
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return Booking{}, ErrDuplicateExternalRef
+	}
+	if err != nil {
+		return Booking{}, err
+	}
+	return booking, nil
+}
+
+// Update replaces the room, guests and dates of an active booking, checking
+// the new range against the other bookings of the room.
+func (m *MongoBookingRepository) Update(ctx context.Context, booking Booking) (Booking, error) {
+	err := m.withTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := m.reserve(sc, booking); err != nil {
+			return err
+		}
+		res, err := m.bookings.UpdateOne(sc,
+			bson.M{"_id": booking.ID, "status": bson.M{"$in": activeBookingStatuses}},
+			bson.M{"$set": bson.M{
+				"roomId":     booking.RoomID,
+				"propertyId": booking.PropertyID,
+				"guests":     booking.Guests,
+				"checkIn":    booking.CheckIn,
+				"checkOut":   booking.CheckOut,
+				"quote":      booking.Quote,
+				"updatedAt":  booking.UpdatedAt,
+			}},
+		)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return ErrBookingStateConflict
+		}
+		return nil
+	})
+	if err != nil {
+		return Booking{}, err
+	}
+	return booking, nil
+}
+
+// reserve locks the room document and fails with ErrBookingOverlap when
+// another active booking shares a night with booking. Bumping a counter on
+// the room makes concurrent transactions for the same room conflict, so two
+// requests cannot both pass the overlap check.
+func (m *MongoBookingRepository) reserve(sc mongo.SessionContext, booking Booking) error {
+	res, err := m.rooms.UpdateOne(sc, bson.M{"_id": booking.RoomID}, bson.M{"$inc": bson.M{"bookingVersion": 1}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrBookingRoomNotFound
+	}
+
+	filter := bson.M{
+		"roomId":   booking.RoomID,
+		"status":   bson.M{"$in": activeBookingStatuses},
+		"checkIn":  bson.M{"$lt": booking.CheckOut},
+		"checkOut": bson.M{"$gt": booking.CheckIn},
+	}
+	if !booking.ID.IsZero() {
+		filter["_id"] = bson.M{"$ne": booking.ID}
+	}
+	err = m.bookings.FindOne(sc, filter).Err()
+	switch {
+	case err == nil:
+		return ErrBookingOverlap
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return nil
+	default:
+		return err
+	}
+}
+
+// bookingTxnOptions makes the overlap check read a majority-committed
+// snapshot and waits for the booking to be majority-acknowledged, so a
+// confirmed booking cannot be rolled back by a failover and then sold again.
+var bookingTxnOptions = options.Transaction().
+	SetReadConcern(readconcern.Snapshot()).
+	SetWriteConcern(writeconcern.Majority())
+
+// withTransaction runs fn in a transaction, joining the one of ctx when the
+// booking is written through the outbox. WithTransaction retries it when
+// the room lock in reserve conflicts with a concurrent booking, so the loser
+// re-checks the overlap against the winner's booking instead of failing.
+func (m *MongoBookingRepository) withTransaction(ctx context.Context, fn func(mongo.SessionContext) error) error {
+	return runInTransaction(ctx, m.bookings.Database().Client(), fn)
+}
+
+// bookingTimestampFields records when a booking entered each status.
+var bookingTimestampFields = map[string]string{
+	BookingCancelled:  "cancelledAt",
+	BookingCheckedIn:  "checkedInAt",
+	BookingCheckedOut: "checkedOutAt",
+}
+
+// Transition moves a booking from status from to status to.
+func (m *MongoBookingRepository) Transition(ctx context.Context, id primitive.ObjectID, from, to string, at time.Time) (Booking, error) {
+	set := bson.M{"status": to, "updatedAt": at}
+	if field, ok := bookingTimestampFields[to]; ok {
+		set[field] = at
+	}
+
+	var booking Booking
+	err := m.bookings.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": from},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&booking)
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return booking, err
+	}
+
+	if _, err := m.FindByID(ctx, id); err != nil {
+		return Booking{}, err
+	}
+	return Booking{}, ErrBookingStateConflict
+}
+
+// Available returns the rooms without active bookings between checkIn and
+// checkOut, computed with a $lookup over the bookings collection.
+func (m *MongoBookingRepository) Available(ctx context.Context, propertyID primitive.ObjectID, checkIn, checkOut time.Time) ([]Room, error) {
+	var pipeline mongo.Pipeline
+	if !propertyID.IsZero() {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"propertyId": propertyID}}})
+	}
+	pipeline = append(pipeline, mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.M{
+			"from": m.bookings.Name(),
+			"let":  bson.M{"roomId": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{
+					"status": bson.M{"$in": activeBookingStatuses},
+					"$expr": bson.M{"$and": bson.A{
+						bson.M{"$eq": bson.A{"$roomId", "$$roomId"}},
+						bson.M{"$lt": bson.A{"$checkIn", checkOut}},
+						bson.M{"$gt": bson.A{"$checkOut", checkIn}},
+					}},
+				}},
+				bson.M{"$limit": 1},
+			},
+			"as": "conflicts",
+		}}},
+		{{Key: "$match", Value: bson.M{"conflicts": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"conflicts": 0}}},
+		{{Key: "$sort", Value: bson.M{"number": 1}}},
+	}...)
+
+	cursor, err := m.rooms.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rooms []Room
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// BookingService encapsulates business logic for room reservations.
+type BookingService struct {
+	bookings BookingRepository
+	rooms    RoomRepository
+	guests   GuestRepository
+	rates    *RateService
+	outbox   Outbox
+	now      func() time.Time
+	ids      IDGenerator
+
+	mu       sync.RWMutex
+	handlers []BookingEventHandler
+}
+
+// NewBookingService builds a new BookingService instance; outbox stores the
+// booking.created events.
+func NewBookingService(bookings BookingRepository, rooms RoomRepository, guests GuestRepository, rates *RateService, outbox Outbox, now func() time.Time, ids IDGenerator) *BookingService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &BookingService{bookings: bookings, rooms: rooms, guests: guests, rates: rates, outbox: outbox, now: now, ids: ids}
+}
+
+// Subscribe registers handler for every booking event. Handlers run
+// synchronously after the change is stored.
+func (s *BookingService) Subscribe(handler BookingEventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+func (s *BookingService) emit(ctx context.Context, event BookingEvent) {
+	s.mu.RLock()
+	handlers := s.handlers
+	s.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
+
+// ParseStay parses a check-in/check-out pair of YYYY-MM-DD dates. The stay
+// must last at least one night and may not start before today.
+func (s *BookingService) ParseStay(checkIn, checkOut string) (time.Time, time.Time, error) {
+	from, err := time.Parse(DateLayout, NormalizeText(checkIn))
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidStayDates
+	}
+	to, err := time.Parse(DateLayout, NormalizeText(checkOut))
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidStayDates
+	}
+
+	now := s.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !to.After(from) || from.Before(today) {
+		return time.Time{}, time.Time{}, ErrInvalidStayDates
+	}
+	return from, to, nil
+}
+
+// Quote prices a stay in a room without booking it.
+func (s *BookingService) Quote(ctx context.Context, roomID, checkIn, checkOut string) (Quote, error) {
+	objID, err := primitive.ObjectIDFromHex(NormalizeText(roomID))
+	if err != nil {
+		return Quote{}, ErrInvalidBookingInput
+	}
+	from, to, err := s.ParseStay(checkIn, checkOut)
+	if err != nil {
+		return Quote{}, err
+	}
+	room, err := s.loadRoom(ctx, objID)
+	if err != nil {
+		return Quote{}, err
+	}
+	return s.rates.QuoteRoom(ctx, room, from, to)
+}
+
+// Available returns the rooms of the scoped property free for the whole stay.
+func (s *BookingService) Available(ctx context.Context, checkIn, checkOut string) ([]RoomResponse, error) {
+	from, to, err := s.ParseStay(checkIn, checkOut)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms, err := s.bookings.Available(ctx, ScopedProperty(ctx), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]RoomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		responses = append(responses, room.ToResponse())
+	}
+	return responses, nil
+}
+
+// List returns the bookings of the scoped property optionally filtered by
+// room and guest email.
+func (s *BookingService) List(ctx context.Context, roomID, email string) ([]BookingResponse, error) {
+	query := BookingQuery{PropertyID: ScopedProperty(ctx), Email: NormalizeEmail(email)}
+	if roomID != "" {
+		objID, err := primitive.ObjectIDFromHex(roomID)
+		if err != nil {
+			return nil, ErrInvalidRoomID
+		}
+		query.RoomID = objID
+	}
+
+	bookings, err := s.bookings.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return bookingResponses(bookings), nil
+}
+
+// Get returns a single booking.
+func (s *BookingService) Get(ctx context.Context, id string) (BookingResponse, error) {
+	booking, err := s.find(ctx, id)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	return booking.ToResponse(), nil
+}
+
+// Create validates input and reserves the room.
+func (s *BookingService) Create(ctx context.Context, input BookingInput) (BookingResponse, error) {
+	booking := Booking{
+		Email:       NormalizeEmail(input.Email),
+		Guests:      input.Guests,
+		Channel:     normalizeKeyword(input.Channel),
+		ExternalRef: NormalizeText(input.ExternalRef),
+	}
+	if guestID := NormalizeText(input.GuestID); guestID != "" {
+		guest, err := s.findGuest(ctx, guestID)
+		if err != nil {
+			return BookingResponse{}, err
+		}
+		booking.GuestID = &guest.ID
+		if booking.Email == "" {
+			booking.Email = guest.Email
+		}
+	}
+	if booking.Email == "" || booking.Guests < 1 {
+		return BookingResponse{}, ErrInvalidBookingInput
+	}
+
+	roomID, err := primitive.ObjectIDFromHex(NormalizeText(input.RoomID))
+	if err != nil {
+		return BookingResponse{}, ErrInvalidBookingInput
+	}
+	booking.RoomID = roomID
+
+	booking.CheckIn, booking.CheckOut, err = s.ParseStay(input.CheckIn, input.CheckOut)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	if err := s.price(ctx, &booking); err != nil {
+		return BookingResponse{}, err
+	}
+
+	now := s.now()
+	booking.ID = s.ids.NewID()
+	booking.Status = BookingBooked
+	booking.CreatedAt = now
+	booking.UpdatedAt = now
+
+	var created Booking
+	err = s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if created, err = s.bookings.Create(ctx, booking); err != nil {
+			return nil, err
+		}
+		return newEvents(events.BookingCreated, created.ID.Hex(), created.ToResponse(), now)
+	})
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	s.emit(ctx, BookingEvent{Type: EventBookingCreated, Booking: created, At: now})
+	return created.ToResponse(), nil
+}
+
+// Modify changes the room, guests or dates of an active booking.
+func (s *BookingService) Modify(ctx context.Context, id string, change BookingChange) (BookingResponse, error) {
+	if change.RoomID == nil && change.Guests == nil && change.CheckIn == nil && change.CheckOut == nil {
+		return BookingResponse{}, ErrInvalidBookingInput
+	}
+
+	booking, err := s.find(ctx, id)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	if booking.Status != BookingBooked {
+		return BookingResponse{}, ErrBookingStateConflict
+	}
+
+	if change.RoomID != nil {
+		roomID, err := primitive.ObjectIDFromHex(NormalizeText(*change.RoomID))
+		if err != nil {
+			return BookingResponse{}, ErrInvalidBookingInput
+		}
+		booking.RoomID = roomID
+	}
+	if change.Guests != nil {
+		if *change.Guests < 1 {
+			return BookingResponse{}, ErrInvalidBookingInput
+		}
+		booking.Guests = *change.Guests
+	}
+	if change.CheckIn != nil || change.CheckOut != nil {
+		checkIn, checkOut := booking.CheckIn.Format(DateLayout), booking.CheckOut.Format(DateLayout)
+		if change.CheckIn != nil {
+			checkIn = *change.CheckIn
+		}
+		if change.CheckOut != nil {
+			checkOut = *change.CheckOut
+		}
+		booking.CheckIn, booking.CheckOut, err = s.ParseStay(checkIn, checkOut)
+		if err != nil {
+			return BookingResponse{}, err
+		}
+	}
+	if err := s.price(ctx, &booking); err != nil {
+		return BookingResponse{}, err
+	}
+	booking.UpdatedAt = s.now()
+
+	updated, err := s.bookings.Update(ctx, booking)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	return updated.ToResponse(), nil
+}
+
+// Cancel releases the room of an active booking; the booking is kept for
+// history.
+func (s *BookingService) Cancel(ctx context.Context, id string) (BookingResponse, error) {
+	current, err := s.find(ctx, id)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+
+	booking, err := s.cancel(ctx, current.ID, BookingBooked)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	return booking.ToResponse(), nil
+}
+
+// cancel moves a booking from status from to cancelled and emits
+// EventBookingCancelled, so the waitlist can offer the freed room.
+func (s *BookingService) cancel(ctx context.Context, id primitive.ObjectID, from string) (Booking, error) {
+	now := s.now()
+	booking, err := s.bookings.Transition(ctx, id, from, BookingCancelled, now)
+	if err != nil {
+		return Booking{}, err
+	}
+	s.emit(ctx, BookingEvent{Type: EventBookingCancelled, Booking: booking, At: now})
+	return booking, nil
+}
+
+// hold books room for a waitlist entry as held until until.
+func (s *BookingService) hold(ctx context.Context, entry WaitlistEntry, roomID primitive.ObjectID, until time.Time) (Booking, error) {
+	booking := Booking{
+		RoomID:   roomID,
+		Email:    entry.Email,
+		Guests:   entry.Guests,
+		CheckIn:  entry.CheckIn,
+		CheckOut: entry.CheckOut,
+	}
+	if err := s.price(ctx, &booking); err != nil {
+		return Booking{}, err
+	}
+
+	now := s.now()
+	booking.ID = s.ids.NewID()
+	booking.Status = BookingHeld
+	booking.HeldUntil = &until
+	booking.CreatedAt = now
+	booking.UpdatedAt = now
+	return s.bookings.Create(ctx, booking)
+}
+
+// confirmHold turns a held booking into a regular one and emits
+// EventBookingCreated, as the guest only now commits to the stay.
+func (s *BookingService) confirmHold(ctx context.Context, id primitive.ObjectID) (Booking, error) {
+	now := s.now()
+	var booking Booking
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		var err error
+		if booking, err = s.bookings.Transition(ctx, id, BookingHeld, BookingBooked, now); err != nil {
+			return nil, err
+		}
+		return newEvents(events.BookingCreated, booking.ID.Hex(), booking.ToResponse(), now)
+	})
+	if err != nil {
+		return Booking{}, err
+	}
+	s.emit(ctx, BookingEvent{Type: EventBookingCreated, Booking: booking, At: now})
+	return booking, nil
+}
+
+// CheckIn marks the guest as arrived and the room as occupied. It is
+// allowed from the arrival date until the day before departure.
+func (s *BookingService) CheckIn(ctx context.Context, id string) (BookingResponse, error) {
+	booking, err := s.find(ctx, id)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+
+	now := s.now()
+	today := now.UTC().Truncate(24 * time.Hour)
+	if booking.Status == BookingBooked && (today.Before(booking.CheckIn) || !today.Before(booking.CheckOut)) {
+		return BookingResponse{}, ErrCheckInOutsideStay
+	}
+	return s.transition(ctx, booking.ID, BookingBooked, BookingCheckedIn, RoomOccupied, EventBookingCheckedIn, now)
+}
+
+// CheckOut closes the stay and sends the room to cleaning.
+func (s *BookingService) CheckOut(ctx context.Context, id string) (BookingResponse, error) {
+	booking, err := s.find(ctx, id)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	return s.transition(ctx, booking.ID, BookingCheckedIn, BookingCheckedOut, RoomCleaning, EventBookingCheckedOut, s.now())
+}
+
+// transition stores the status change, updates the room status and emits
+// eventType. A failed room update is logged but does not undo the booking
+// change: staff can still fix the room through PUT /rooms/:id.
+func (s *BookingService) transition(ctx context.Context, id primitive.ObjectID, from, to, roomStatus, eventType string, at time.Time) (BookingResponse, error) {
+	booking, err := s.bookings.Transition(ctx, id, from, to, at)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+
+	if _, err := s.rooms.Update(ctx, booking.RoomID, RoomUpdate{Status: &roomStatus, UpdatedAt: at}); err != nil {
+		log.Printf("no se pudo actualizar el estado de la habitacion %s a %s: %v", booking.RoomID.Hex(), roomStatus, err)
+	}
+
+	s.emit(ctx, BookingEvent{Type: eventType, Booking: booking, At: at})
+	return booking.ToResponse(), nil
+}
+
+func (s *BookingService) findGuest(ctx context.Context, id string) (Guest, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Guest{}, ErrInvalidBookingInput
+	}
+	guest, err := s.guests.FindByID(ctx, objID)
+	if errors.Is(err, ErrNotFound) {
+		return Guest{}, ErrBookingGuestNotFound
+	}
+	return guest, err
+}
+
+// find loads a booking, reporting bookings of other properties as
+// ErrNotFound.
+func (s *BookingService) find(ctx context.Context, id string) (Booking, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Booking{}, ErrInvalidBookingID
+	}
+	booking, err := s.bookings.FindByID(ctx, objID)
+	if err != nil {
+		return Booking{}, err
+	}
+	if !inScope(ctx, booking.PropertyID) {
+		return Booking{}, ErrNotFound
+	}
+	return booking, nil
+}
+
+// price rejects bookings for unknown rooms or with more guests than the room
+// holds, and stores the property of the room and the quote of the stay on
+// booking.
+func (s *BookingService) price(ctx context.Context, booking *Booking) error {
+	room, err := s.loadRoom(ctx, booking.RoomID)
+	if err != nil {
+		return err
+	}
+	if booking.Guests > room.Capacity {
+		return ErrInvalidBookingInput
+	}
+	booking.PropertyID = room.PropertyID
+
+	quote, err := s.rates.QuoteRoom(ctx, room, booking.CheckIn, booking.CheckOut)
+	if err != nil {
+		return err
+	}
+	booking.Quote = &quote
+	return nil
+}
+
+// loadRoom returns the room, reporting rooms of other properties as
+// ErrBookingRoomNotFound.
+func (s *BookingService) loadRoom(ctx context.Context, id primitive.ObjectID) (Room, error) {
+	room, err := s.rooms.FindByID(ctx, id)
+	if errors.Is(err, ErrNotFound) || (err == nil && !inScope(ctx, room.PropertyID)) {
+		return Room{}, ErrBookingRoomNotFound
+	}
+	return room, err
+}
+
+func bookingResponses(bookings []Booking) []BookingResponse {
+	responses := make([]BookingResponse, 0, len(bookings))
+	for _, booking := range bookings {
+		responses = append(responses, booking.ToResponse())
+	}
+	return responses
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/captcha"
+)
+
+var (
+	// ErrCaptchaRequired is returned when a CAPTCHA token is needed but
+	// missing.
+	ErrCaptchaRequired = errors.New("captcha required")
+	// ErrCaptchaRejected is returned when the provider rejects the token.
+	ErrCaptchaRejected = errors.New("captcha rejected")
+)
+
+// LoginFailures counts the failed logins of an email within a window.
+type LoginFailures struct {
+	Email     string    `bson:"_id"`
+	Count     int       `bson:"count"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// LoginFailureRepository is the storage contract of the failed login
+// counters.
+type LoginFailureRepository interface {
+	// Record counts a failure of email at; the window starts with the first
+	// failure and the count restarts once it expires.
+	Record(ctx context.Context, email string, at time.Time, window time.Duration) error
+	// Count returns the failures of email in a window still open at at.
+	Count(ctx context.Context, email string, at time.Time) (int, error)
+	Reset(ctx context.Context, email string) error
+}
+
+// MongoLoginFailureRepository implements LoginFailureRepository backed by
+// MongoDB.
+type MongoLoginFailureRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoLoginFailureRepository creates a repository over collection.
+func NewMongoLoginFailureRepository(collection *mongo.Collection) *MongoLoginFailureRepository {
+	return &MongoLoginFailureRepository{collection: collection}
+}
+
+// EnsureIndexes creates a TTL index that lets MongoDB purge expired
+// counters.
+func (m *MongoLoginFailureRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Record implements LoginFailureRepository.
+func (m *MongoLoginFailureRepository) Record(ctx context.Context, email string, at time.Time, window time.Duration) error {
+	res, err := m.collection.UpdateOne(ctx,
+		bson.M{"_id": email, "expiresAt": bson.M{"$gt": at}},
+		bson.M{"$inc": bson.M{"count": 1}})
+	if err != nil || res.MatchedCount > 0 {
+		return err
+	}
+	_, err = m.collection.ReplaceOne(ctx, bson.M{"_id": email},
+		LoginFailures{Email: email, Count: 1, ExpiresAt: at.Add(window)},
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// Count implements LoginFailureRepository.
+func (m *MongoLoginFailureRepository) Count(ctx context.Context, email string, at time.Time) (int, error) {
+	var failures LoginFailures
+	err := m.collection.FindOne(ctx, bson.M{"_id": email, "expiresAt": bson.M{"$gt": at}}).Decode(&failures)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return failures.Count, err
+}
+
+// Reset implements LoginFailureRepository.
+func (m *MongoLoginFailureRepository) Reset(ctx context.Context, email string) error {
+	_, err := m.collection.DeleteOne(ctx, bson.M{"_id": email})
+	return err
+}
+
+// CaptchaGuard decides when a CAPTCHA is needed: on every registration and
+// on logins after Threshold failed attempts for the email within Window.
+// Without a verifier the CAPTCHA is disabled.
+type CaptchaGuard struct {
+	verifier  captcha.Verifier
+	failures  LoginFailureRepository
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+}
+
+// NewCaptchaGuard builds a CaptchaGuard; a nil verifier disables it.
+func NewCaptchaGuard(verifier captcha.Verifier, failures LoginFailureRepository, threshold int, window time.Duration, now func() time.Time) *CaptchaGuard {
+	if now == nil {
+		now = time.Now
+	}
+	return &CaptchaGuard{verifier: verifier, failures: failures, threshold: threshold, window: window, now: now}
+}
+
+func (g *CaptchaGuard) verify(ctx context.Context, token, remoteIP string) error {
+	err := g.verifier.Verify(ctx, token, remoteIP)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, captcha.ErrRejected) && token == "":
+		return ErrCaptchaRequired
+	case errors.Is(err, captcha.ErrRejected):
+		return ErrCaptchaRejected
+	default:
+		return err
+	}
+}
+
+// CheckRegistration verifies the token sent with a registration.
+func (g *CaptchaGuard) CheckRegistration(ctx context.Context, token, remoteIP string) error {
+	if g.verifier == nil {
+		return nil
+	}
+	return g.verify(ctx, token, remoteIP)
+}
+
+// CheckLogin verifies the token sent with a login once email reached the
+// failure threshold.
+func (g *CaptchaGuard) CheckLogin(ctx context.Context, email, token, remoteIP string) error {
+	if g.verifier == nil {
+		return nil
+	}
+	failures, err := g.failures.Count(ctx, NormalizeEmail(email), g.now())
+	if err != nil {
+		return err
+	}
+	if failures < g.threshold {
+		return nil
+	}
+	return g.verify(ctx, token, remoteIP)
+}
+
+// LoginFailed counts a failed login of email.
+func (g *CaptchaGuard) LoginFailed(ctx context.Context, email string) error {
+	if g.verifier == nil {
+		return nil
+	}
+	return g.failures.Record(ctx, NormalizeEmail(email), g.now(), g.window)
+}
+
+// LoginSucceeded clears the failures of email.
+func (g *CaptchaGuard) LoginSucceeded(ctx context.Context, email string) error {
+	if g.verifier == nil {
+		return nil
+	}
+	return g.failures.Reset(ctx, NormalizeEmail(email))
+}
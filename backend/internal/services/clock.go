@@ -0,0 +1,33 @@
+package services
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Clock tells the current time. Services take its Now method as their now
+// function, so tests can pin or advance it.
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator issues the IDs of new documents. Services assign them before
+// storing a document instead of leaving it to the MongoDB driver, so tests
+// can predict them.
+type IDGenerator interface {
+	NewID() primitive.ObjectID
+}
+
+// SystemClock is the Clock and IDGenerator used in production.
+type SystemClock struct{}
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewID implements IDGenerator.
+func (SystemClock) NewID() primitive.ObjectID {
+	return primitive.NewObjectID()
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrInvalidDashboardRange indicates a malformed or too long dashboard range.
+var ErrInvalidDashboardRange = errors.New("invalid dashboard range")
+
+const (
+	// defaultDashboardDays is the length of the range used when none is given.
+	defaultDashboardDays = 30
+	// maxDashboardDays caps the length of a dashboard range.
+	maxDashboardDays = 366
+)
+
+// DashboardRange selects the days [From, To) of a dashboard series, as
+// YYYY-MM-DD dates. Empty values default to the last 30 days, today
+// included.
+type DashboardRange struct {
+	From string
+	To   string
+}
+
+// UserSummary counts the registered users and the staff per role.
+type UserSummary struct {
+	Total  int64            `json:"total" xml:"total"`
+	Staff  int64            `json:"staff" xml:"staff"`
+	ByRole map[string]int64 `json:"byRole" xml:"-"`
+}
+
+// DailyCount is the number of occurrences on one day.
+type DailyCount struct {
+	Day   string `json:"day" xml:"day"`
+	Count int64  `json:"count" xml:"count"`
+}
+
+// TodoDay counts the todos created and completed on one day.
+type TodoDay struct {
+	Day       string `json:"day" xml:"day"`
+	Created   int64  `json:"created" xml:"created"`
+	Completed int64  `json:"completed" xml:"completed"`
+}
+
+// CompletionTimes are percentiles of the time from creation to completion
+// of the todos completed in a range, in seconds.
+type CompletionTimes struct {
+	P50 int64 `json:"p50" xml:"p50"`
+	P90 int64 `json:"p90" xml:"p90"`
+	P99 int64 `json:"p99" xml:"p99"`
+}
+
+// completionPercentiles are the percentiles CompletionTimes holds.
+var completionPercentiles = []float64{0.5, 0.9, 0.99}
+
+// TodoTotals sums the todos created and completed in a range.
+type TodoTotals struct {
+	Created           int64           `json:"created" xml:"created"`
+	Completed         int64           `json:"completed" xml:"completed"`
+	CompletionSeconds CompletionTimes `json:"completionSeconds" xml:"completionSeconds"`
+}
+
+// TodoReport sums the todos of a range and splits them per day.
+type TodoReport struct {
+	Totals TodoTotals
+	Days   []TodoDay
+}
+
+// TodoActivity is the todo activity of a range as aggregated by a
+// DashboardRepository: the days with data and the completion times, either
+// as the completionPercentiles in milliseconds, when the server computes
+// them, or as the milliseconds of each completion for the service to take
+// them from.
+type TodoActivity struct {
+	Days        []TodoDay
+	Percentiles []float64
+	Durations   []int64
+}
+
+// DeliveryStats describes how the domain events reached the broker and the
+// webhooks. Every attempt of a delivered event but the last one failed, so
+// FailedAttempts is Attempts minus Delivered; FailureRate is the percentage
+// of failed attempts.
+type DeliveryStats struct {
+	Events         int64   `json:"events" xml:"events"`
+	Delivered      int64   `json:"delivered" xml:"delivered"`
+	Dead           int64   `json:"dead" xml:"dead"`
+	Attempts       int64   `json:"attempts" xml:"attempts"`
+	FailedAttempts int64   `json:"failedAttempts" xml:"failedAttempts"`
+	FailureRate    float64 `json:"failureRate" xml:"failureRate"`
+}
+
+// DeliveryDay holds the delivery figures of the events stored on one day.
+type DeliveryDay struct {
+	Day string `json:"day" xml:"day"`
+	DeliveryStats
+}
+
+// DeliveryReport sums the deliveries of a range and splits them per day.
+type DeliveryReport struct {
+	Totals DeliveryStats
+	Days   []DeliveryDay
+}
+
+// CollectionStorage is the size of one collection, in bytes.
+type CollectionStorage struct {
+	Name         string `json:"name" xml:"name"`
+	Documents    int64  `json:"documents" xml:"documents"`
+	DataBytes    int64  `json:"dataBytes" xml:"dataBytes"`
+	StorageBytes int64  `json:"storageBytes" xml:"storageBytes"`
+	IndexBytes   int64  `json:"indexBytes" xml:"indexBytes"`
+}
+
+// StorageSummary is the size of the database and of each collection, the
+// largest first.
+type StorageSummary struct {
+	Collections  []CollectionStorage `json:"collections" xml:"collections"`
+	DataBytes    int64               `json:"dataBytes" xml:"dataBytes"`
+	StorageBytes int64               `json:"storageBytes" xml:"storageBytes"`
+	IndexBytes   int64               `json:"indexBytes" xml:"indexBytes"`
+}
+
+// DashboardRepository runs the aggregations behind the admin dashboard. The
+// per-day methods only return the days with data in [from, to).
+type DashboardRepository interface {
+	CountUsers(ctx context.Context) (UserSummary, error)
+	SignupsPerDay(ctx context.Context, from, to time.Time) ([]DailyCount, error)
+	TodoActivity(ctx context.Context, from, to time.Time) (TodoActivity, error)
+	// DeliveriesPerDay groups the outbox messages by the day they were
+	// stored; FailedAttempts and FailureRate are left for the service.
+	DeliveriesPerDay(ctx context.Context, from, to time.Time) ([]DeliveryDay, error)
+	Storage(ctx context.Context) (StorageSummary, error)
+}
+
+// MongoDashboardRepository implements DashboardRepository with aggregation
+// pipelines over the collections of db.
+type MongoDashboardRepository struct {
+	db *Database
+	// noPercentile is set once the server rejects $percentile, which needs
+	// MongoDB 7.0, so the later calls go straight to the fallback.
+	noPercentile atomic.Bool
+}
+
+// NewMongoDashboardRepository creates a repository over db.
+func NewMongoDashboardRepository(db *Database) *MongoDashboardRepository {
+	return &MongoDashboardRepository{db: db}
+}
+
+// dayOf groups a date field by its UTC day.
+func dayOf(field string) bson.M {
+	return bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$" + field}}
+}
+
+// countIf sums 1 for the documents whose field equals value.
+func countIf(field string, value any) bson.M {
+	return bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$" + field, value}}, 1, 0}}}
+}
+
+func aggregate[T any](ctx context.Context, collection *mongo.Collection, pipeline bson.A) ([]T, error) {
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []T
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// CountUsers implements DashboardRepository.
+func (m *MongoDashboardRepository) CountUsers(ctx context.Context) (UserSummary, error) {
+	rows, err := aggregate[struct {
+		Role  *string `bson:"_id"`
+		Count int64   `bson:"count"`
+	}](ctx, m.db.Collection("users"), bson.A{
+		bson.M{"$group": bson.M{"_id": "$role", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return UserSummary{}, err
+	}
+
+	summary := UserSummary{ByRole: map[string]int64{}}
+	for _, row := range rows {
+		summary.Total += row.Count
+		if row.Role != nil && *row.Role != "" {
+			summary.ByRole[*row.Role] += row.Count
+			summary.Staff += row.Count
+		}
+	}
+	return summary, nil
+}
+
+// SignupsPerDay implements DashboardRepository. Users registered before
+// the registration date was recorded are not counted.
+func (m *MongoDashboardRepository) SignupsPerDay(ctx context.Context, from, to time.Time) ([]DailyCount, error) {
+	return aggregate[DailyCount](ctx, m.db.Collection("users"), countPerDay("createdAt", from, to))
+}
+
+// TodoActivity implements DashboardRepository, counting the trashed todos
+// too. A single $facet pipeline brings both series and the completion
+// times; on servers without $percentile it pushes the durations instead.
+func (m *MongoDashboardRepository) TodoActivity(ctx context.Context, from, to time.Time) (TodoActivity, error) {
+	if !m.noPercentile.Load() {
+		activity, err := m.todoActivity(ctx, from, to, "percentiles", bson.M{"$percentile": bson.M{
+			"input": "$duration", "p": completionPercentiles, "method": "approximate",
+		}})
+		if !isUnknownOperator(err) {
+			return activity, err
+		}
+		m.noPercentile.Store(true)
+	}
+	return m.todoActivity(ctx, from, to, "durations", bson.M{"$push": "$duration"})
+}
+
+// isUnknownOperator reports whether err is the server rejecting an operator
+// it does not know, or one its featureCompatibilityVersion does not allow
+// yet.
+func isUnknownOperator(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	switch cmdErr.Code {
+	case 15952, 168, 224: // unknown group operator, InvalidPipelineOperator, QueryFeatureNotAllowed
+		return true
+	}
+	return false
+}
+
+// todoActivity runs the $facet pipeline, gathering the completion times
+// into field with accumulator.
+func (m *MongoDashboardRepository) todoActivity(ctx context.Context, from, to time.Time, field string, accumulator bson.M) (TodoActivity, error) {
+	inRange := func(field string) bson.M { return bson.M{field: bson.M{"$gte": from, "$lt": to}} }
+	rows, err := aggregate[struct {
+		Created   []DailyCount `bson:"created"`
+		Completed []DailyCount `bson:"completed"`
+		Times     []struct {
+			Percentiles []float64 `bson:"percentiles"`
+			Durations   []int64   `bson:"durations"`
+		} `bson:"times"`
+	}](ctx, m.db.Collection("todos"), bson.A{
+		bson.M{"$match": bson.M{"$or": bson.A{inRange("createdAt"), inRange("completedAt")}}},
+		bson.M{"$facet": bson.M{
+			"created":   countPerDay("createdAt", from, to),
+			"completed": countPerDay("completedAt", from, to),
+			"times": bson.A{
+				bson.M{"$match": inRange("completedAt")},
+				bson.M{"$set": bson.M{"duration": bson.M{"$subtract": bson.A{"$completedAt", "$createdAt"}}}},
+				bson.M{"$group": bson.M{"_id": nil, field: accumulator}},
+			},
+		}},
+	})
+	if err != nil || len(rows) == 0 {
+		return TodoActivity{}, err
+	}
+	var activity TodoActivity
+	if times := rows[0].Times; len(times) > 0 {
+		activity.Percentiles, activity.Durations = times[0].Percentiles, times[0].Durations
+	}
+	created, completed := rows[0].Created, rows[0].Completed
+
+	days := map[string]*TodoDay{}
+	day := func(name string) *TodoDay {
+		if days[name] == nil {
+			days[name] = &TodoDay{Day: name}
+		}
+		return days[name]
+	}
+	for _, row := range created {
+		day(row.Day).Created = row.Count
+	}
+	for _, row := range completed {
+		day(row.Day).Completed = row.Count
+	}
+	activity.Days = make([]TodoDay, 0, len(days))
+	for _, row := range days {
+		activity.Days = append(activity.Days, *row)
+	}
+	return activity, nil
+}
+
+// countPerDay is the pipeline that counts the documents per day of field
+// in [from, to), as DailyCount rows.
+func countPerDay(field string, from, to time.Time) bson.A {
+	return bson.A{
+		bson.M{"$match": bson.M{field: bson.M{"$gte": from, "$lt": to}}},
+		bson.M{"$group": bson.M{"_id": dayOf(field), "count": bson.M{"$sum": 1}}},
+		bson.M{"$project": bson.M{"_id": 0, "day": "$_id", "count": 1}},
+	}
+}
+
+// DeliveriesPerDay implements DashboardRepository.
+func (m *MongoDashboardRepository) DeliveriesPerDay(ctx context.Context, from, to time.Time) ([]DeliveryDay, error) {
+	rows, err := aggregate[struct {
+		Day       string `bson:"_id"`
+		Events    int64  `bson:"events"`
+		Delivered int64  `bson:"delivered"`
+		Dead      int64  `bson:"dead"`
+		Attempts  int64  `bson:"attempts"`
+	}](ctx, m.db.Collection("outbox"), bson.A{
+		bson.M{"$match": bson.M{"createdAt": bson.M{"$gte": from, "$lt": to}}},
+		bson.M{"$group": bson.M{
+			"_id":       dayOf("createdAt"),
+			"events":    bson.M{"$sum": 1},
+			"delivered": countIf("status", OutboxPublished),
+			"dead":      countIf("status", OutboxDead),
+			"attempts":  bson.M{"$sum": "$attempts"},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]DeliveryDay, 0, len(rows))
+	for _, row := range rows {
+		days = append(days, DeliveryDay{Day: row.Day, DeliveryStats: DeliveryStats{
+			Events: row.Events, Delivered: row.Delivered, Dead: row.Dead, Attempts: row.Attempts,
+		}})
+	}
+	return days, nil
+}
+
+// Storage implements DashboardRepository with the $collStats stage, summing
+// the shards of a sharded collection.
+func (m *MongoDashboardRepository) Storage(ctx context.Context) (StorageSummary, error) {
+	names, err := m.db.CollectionNames(ctx)
+	if err != nil {
+		return StorageSummary{}, err
+	}
+
+	var summary StorageSummary
+	for _, name := range names {
+		shards, err := aggregate[struct {
+			Stats struct {
+				Count          int64 `bson:"count"`
+				Size           int64 `bson:"size"`
+				StorageSize    int64 `bson:"storageSize"`
+				TotalIndexSize int64 `bson:"totalIndexSize"`
+			} `bson:"storageStats"`
+		}](ctx, m.db.Collection(name), bson.A{
+			bson.M{"$collStats": bson.M{"storageStats": bson.M{}}},
+		})
+		if err != nil {
+			return StorageSummary{}, err
+		}
+		collection := CollectionStorage{Name: name}
+		for _, shard := range shards {
+			collection.Documents += shard.Stats.Count
+			collection.DataBytes += shard.Stats.Size
+			collection.StorageBytes += shard.Stats.StorageSize
+			collection.IndexBytes += shard.Stats.TotalIndexSize
+		}
+		summary.Collections = append(summary.Collections, collection)
+	}
+	return summary, nil
+}
+
+// DashboardService summarises the activity of the application for the
+// operations dashboard.
+type DashboardService struct {
+	repo DashboardRepository
+	now  func() time.Time
+}
+
+// NewDashboardService builds a new DashboardService instance.
+func NewDashboardService(repo DashboardRepository, now func() time.Time) *DashboardService {
+	if now == nil {
+		now = time.Now
+	}
+	return &DashboardService{repo: repo, now: now}
+}
+
+// Users counts the registered users.
+func (s *DashboardService) Users(ctx context.Context) (UserSummary, error) {
+	summary, err := s.repo.CountUsers(ctx)
+	if err == nil && summary.ByRole == nil {
+		summary.ByRole = map[string]int64{}
+	}
+	return summary, err
+}
+
+// Signups returns the registrations of every day of the range.
+func (s *DashboardService) Signups(ctx context.Context, query DashboardRange) ([]DailyCount, error) {
+	from, to, err := s.parseRange(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.SignupsPerDay(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return fillDays(from, to, rows, func(row DailyCount) string { return row.Day },
+		func(day string) DailyCount { return DailyCount{Day: day} }), nil
+}
+
+// Todos returns the todos created and completed on every day of the range,
+// their totals and how long the completed ones took.
+func (s *DashboardService) Todos(ctx context.Context, query DashboardRange) (TodoReport, error) {
+	from, to, err := s.parseRange(query)
+	if err != nil {
+		return TodoReport{}, err
+	}
+	activity, err := s.repo.TodoActivity(ctx, from, to)
+	if err != nil {
+		return TodoReport{}, err
+	}
+
+	report := TodoReport{Days: fillDays(from, to, activity.Days, func(row TodoDay) string { return row.Day },
+		func(day string) TodoDay { return TodoDay{Day: day} })}
+	for _, day := range report.Days {
+		report.Totals.Created += day.Created
+		report.Totals.Completed += day.Completed
+	}
+	millis := activity.Percentiles
+	if len(millis) != len(completionPercentiles) {
+		millis = percentiles(activity.Durations, completionPercentiles)
+	}
+	seconds := func(ms float64) int64 { return int64(ms) / int64(time.Second/time.Millisecond) }
+	report.Totals.CompletionSeconds = CompletionTimes{P50: seconds(millis[0]), P90: seconds(millis[1]), P99: seconds(millis[2])}
+	return report, nil
+}
+
+// percentiles returns the nearest-rank percentiles ps of values, zero
+// without values.
+func percentiles(values []int64, ps []float64) []float64 {
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	out := make([]float64, len(ps))
+	for i, p := range ps {
+		if len(sorted) > 0 {
+			rank := max(int(math.Ceil(p*float64(len(sorted)))), 1)
+			out[i] = float64(sorted[rank-1])
+		}
+	}
+	return out
+}
+
+// Deliveries returns the delivery figures of the events stored on every day
+// of the range, and their totals.
+func (s *DashboardService) Deliveries(ctx context.Context, query DashboardRange) (DeliveryReport, error) {
+	from, to, err := s.parseRange(query)
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+	rows, err := s.repo.DeliveriesPerDay(ctx, from, to)
+	if err != nil {
+		return DeliveryReport{}, err
+	}
+
+	report := DeliveryReport{Days: fillDays(from, to, rows, func(row DeliveryDay) string { return row.Day },
+		func(day string) DeliveryDay { return DeliveryDay{Day: day} })}
+	for i := range report.Days {
+		stats := &report.Days[i].DeliveryStats
+		stats.complete()
+		report.Totals.Events += stats.Events
+		report.Totals.Delivered += stats.Delivered
+		report.Totals.Dead += stats.Dead
+		report.Totals.Attempts += stats.Attempts
+	}
+	report.Totals.complete()
+	return report, nil
+}
+
+// complete derives the failed attempts and the failure rate.
+func (d *DeliveryStats) complete() {
+	d.FailedAttempts = d.Attempts - d.Delivered
+	d.FailureRate = 0
+	if d.Attempts > 0 {
+		d.FailureRate = roundCents(float64(d.FailedAttempts) * 100 / float64(d.Attempts))
+	}
+}
+
+// Storage returns the size of the database, the largest collections first.
+func (s *DashboardService) Storage(ctx context.Context) (StorageSummary, error) {
+	summary, err := s.repo.Storage(ctx)
+	if err != nil {
+		return StorageSummary{}, err
+	}
+
+	summary.DataBytes, summary.StorageBytes, summary.IndexBytes = 0, 0, 0
+	for _, collection := range summary.Collections {
+		summary.DataBytes += collection.DataBytes
+		summary.StorageBytes += collection.StorageBytes
+		summary.IndexBytes += collection.IndexBytes
+	}
+	if summary.Collections == nil {
+		summary.Collections = []CollectionStorage{}
+	}
+	sort.SliceStable(summary.Collections, func(a, b int) bool {
+		x, y := summary.Collections[a], summary.Collections[b]
+		if x.StorageBytes != y.StorageBytes {
+			return x.StorageBytes > y.StorageBytes
+		}
+		return x.Name < y.Name
+	})
+	return summary, nil
+}
+
+// parseRange resolves query, defaulting to the last defaultDashboardDays
+// days up to today.
+func (s *DashboardService) parseRange(query DashboardRange) (time.Time, time.Time, error) {
+	now := s.now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	if raw := NormalizeText(query.To); raw != "" {
+		parsed, err := time.Parse(DateLayout, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDashboardRange
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -defaultDashboardDays)
+	if raw := NormalizeText(query.From); raw != "" {
+		parsed, err := time.Parse(DateLayout, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidDashboardRange
+		}
+		from = parsed
+	}
+	if !to.After(from) || to.Sub(from) > maxDashboardDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrInvalidDashboardRange
+	}
+	return from, to, nil
+}
+
+// fillDays returns one row per day of [from, to), taking the rows found and
+// building empty ones for the days without data.
+func fillDays[T any](from, to time.Time, rows []T, key func(T) string, empty func(string) T) []T {
+	byDay := make(map[string]T, len(rows))
+	for _, row := range rows {
+		byDay[key(row)] = row
+	}
+	filled := make([]T, 0, int(to.Sub(from).Hours()/24))
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		name := day.Format(DateLayout)
+		row, ok := byDay[name]
+		if !ok {
+			row = empty(name)
+		}
+		filled = append(filled, row)
+	}
+	return filled
+}
@@ -2,10 +2,19 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 const (
@@ -13,10 +22,62 @@ const (
 	DefaultDatabaseName = "hotelapp"
 )
 
+// ErrInvalidCollectionPrefix indicates a collection prefix MongoDB would
+// reject or that would clash with its system collections.
+var ErrInvalidCollectionPrefix = errors.New("invalid collection prefix")
+
+// Database names the collections of the API inside a MongoDB database. The
+// prefix (e.g. "qa_") is prepended to every collection, so several
+// environments can share a database without seeing each other's data.
+type Database struct {
+	db     *mongo.Database
+	prefix string
+	opts   []*options.CollectionOptions
+}
+
+// NewDatabase wraps db with the collection prefix; an empty prefix keeps
+// the plain collection names.
+func NewDatabase(db *mongo.Database, prefix string) (*Database, error) {
+	if strings.ContainsAny(prefix, "$\x00") || strings.HasPrefix(prefix, "system.") {
+		return nil, ErrInvalidCollectionPrefix
+	}
+	return &Database{db: db, prefix: prefix}, nil
+}
+
+// Collection returns the collection called name in this environment.
+func (d *Database) Collection(name string) *mongo.Collection {
+	return d.db.Collection(d.prefix+name, d.opts...)
+}
+
+// ReadingFrom returns a copy of the database whose collections read with
+// pref instead of the read preference of the client. It shares the client
+// (and its pool) with d; writes are always sent to the primary.
+func (d *Database) ReadingFrom(pref *readpref.ReadPref) *Database {
+	opts := append(append([]*options.CollectionOptions(nil), d.opts...), options.Collection().SetReadPreference(pref))
+	return &Database{db: d.db, prefix: d.prefix, opts: opts}
+}
+
+// CollectionNames lists the collections of this environment, without the
+// prefix.
+func (d *Database) CollectionNames(ctx context.Context) ([]string, error) {
+	filter := bson.M{"type": "collection"}
+	if d.prefix != "" {
+		filter["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(d.prefix)}
+	}
+	names, err := d.db.ListCollectionNames(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		names[i] = strings.TrimPrefix(name, d.prefix)
+	}
+	return names, nil
+}
+
 // ConnectMongo initialises a MongoDB client with a timeout to avoid hanging
-// connections during startup.
-func ConnectMongo(ctx context.Context, uri string) (*mongo.Client, error) {
-	clientOpts := options.Client().ApplyURI(uri)
+// connections during startup. Extra options are merged over the URI settings.
+func ConnectMongo(ctx context.Context, uri string, opts ...*options.ClientOptions) (*mongo.Client, error) {
+	clientOpts := options.MergeClientOptions(append([]*options.ClientOptions{options.Client().ApplyURI(uri)}, opts...)...)
 	client, err := mongo.NewClient(clientOpts)
 	if err != nil {
 		return nil, err
@@ -36,3 +97,224 @@ func ConnectMongo(ctx context.Context, uri string) (*mongo.Client, error) {
 
 	return client, nil
 }
+
+// PoolOptions tunes the connection pool and read routing of the client. Zero
+// values keep the driver defaults.
+type PoolOptions struct {
+	MaxPoolSize            uint64
+	MinPoolSize            uint64
+	SocketTimeout          time.Duration
+	ServerSelectionTimeout time.Duration
+	// ReadPreference is a mode name such as "primary" or "secondaryPreferred".
+	ReadPreference string
+}
+
+// ClientOptions converts the pool settings into driver options.
+func (p PoolOptions) ClientOptions() (*options.ClientOptions, error) {
+	opts := options.Client()
+	if p.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(p.MaxPoolSize)
+	}
+	if p.MinPoolSize > 0 {
+		opts.SetMinPoolSize(p.MinPoolSize)
+	}
+	if p.SocketTimeout > 0 {
+		opts.SetSocketTimeout(p.SocketTimeout)
+	}
+	if p.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(p.ServerSelectionTimeout)
+	}
+	if p.ReadPreference != "" {
+		pref, err := ReadPreference(p.ReadPreference, 0)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetReadPreference(pref)
+	}
+	return opts, nil
+}
+
+// minMaxStaleness is the smallest maxStalenessSeconds MongoDB accepts.
+const minMaxStaleness = 90 * time.Second
+
+// ReadPreference builds the read preference named mode. A positive
+// maxStaleness keeps secondaries that lag the primary by more than that out
+// of the selection; MongoDB requires at least 90s and rejects it with the
+// primary mode.
+func ReadPreference(mode string, maxStaleness time.Duration) (*readpref.ReadPref, error) {
+	parsed, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, err
+	}
+	if maxStaleness <= 0 {
+		return readpref.New(parsed)
+	}
+	if maxStaleness < minMaxStaleness {
+		return nil, fmt.Errorf("max staleness must be at least %s", minMaxStaleness)
+	}
+	return readpref.New(parsed, readpref.WithMaxStaleness(maxStaleness))
+}
+
+// String renders the settings for startup logs, marking unset values.
+func (p PoolOptions) String() string {
+	value := func(set bool, v any) string {
+		if !set {
+			return "default"
+		}
+		return fmt.Sprint(v)
+	}
+	return fmt.Sprintf("maxPoolSize=%s minPoolSize=%s socketTimeout=%s serverSelectionTimeout=%s readPreference=%s",
+		value(p.MaxPoolSize > 0, p.MaxPoolSize),
+		value(p.MinPoolSize > 0, p.MinPoolSize),
+		value(p.SocketTimeout > 0, p.SocketTimeout),
+		value(p.ServerSelectionTimeout > 0, p.ServerSelectionTimeout),
+		value(p.ReadPreference != "", p.ReadPreference),
+	)
+}
+
+// NewSlowQueryMonitor returns a command monitor that logs a warning with the
+// query shape of every database command slower than threshold.
+func NewSlowQueryMonitor(threshold time.Duration) *event.CommandMonitor {
+	var started sync.Map
+
+	finish := func(requestID int64, duration time.Duration, failure string) {
+		value, ok := started.LoadAndDelete(requestID)
+		if !ok || duration < threshold {
+			return
+		}
+		suffix := ""
+		if failure != "" {
+			suffix = " (error: " + failure + ")"
+		}
+		log.Printf("consulta lenta a MongoDB (%s): %s%s", duration, value, suffix)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, evt *event.CommandStartedEvent) {
+			started.Store(evt.RequestID, QueryShape(evt.CommandName, evt.Command))
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, evt.Duration, "")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Duration, evt.Failure)
+		},
+	}
+}
+
+// QueryShape describes a command without its literal values, e.g.
+// `find todos filter={"email":?}`, so it can be logged without leaking data.
+func QueryShape(commandName string, command bson.Raw) string {
+	shape := commandName
+	if collection, ok := command.Lookup(commandName).StringValueOK(); ok {
+		shape += " " + collection
+	}
+
+	for _, key := range []string{"filter", "sort", "pipeline", "updates", "deletes"} {
+		value, err := command.LookupErr(key)
+		if err != nil {
+			continue
+		}
+		shape += " " + key + "=" + valueShape(value)
+	}
+	return shape
+}
+
+// ExplainFind asks MongoDB which plan it would pick for a find on
+// collection and returns it summarized by PlanSummary, along with the query
+// shape. The query is not run.
+func ExplainFind(ctx context.Context, collection *mongo.Collection, filter any, sort bson.D) (shape, plan string, indexed bool, err error) {
+	find := bson.D{{Key: "find", Value: collection.Name()}, {Key: "filter", Value: filter}, {Key: "sort", Value: sort}}
+	raw, err := bson.Marshal(find)
+	if err != nil {
+		return "", "", false, err
+	}
+	shape = QueryShape("find", raw)
+
+	cmd := bson.D{{Key: "explain", Value: find}, {Key: "verbosity", Value: "queryPlanner"}}
+	result, err := collection.Database().RunCommand(ctx, cmd).Raw()
+	if err != nil {
+		return shape, "", false, err
+	}
+	winning, err := result.LookupErr("queryPlanner", "winningPlan")
+	if err != nil {
+		return shape, "", false, err
+	}
+	plan, indexed = PlanSummary(winning.Document())
+	return shape, plan, indexed, nil
+}
+
+// PlanSummary describes the winning plan of an explain, e.g.
+// `FETCH > IXSCAN userId_1_createdAt_1`, and reports whether it reads an
+// index instead of scanning the whole collection.
+func PlanSummary(plan bson.Raw) (string, bool) {
+	// Plans of the slot-based engine nest the stages under queryPlan.
+	if inner, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		plan = inner
+	}
+	stage, _ := plan.Lookup("stage").StringValueOK()
+	indexed := stage == "IXSCAN" || stage == "COUNT_SCAN" || stage == "DISTINCT_SCAN"
+	if name, ok := plan.Lookup("indexName").StringValueOK(); ok {
+		stage += " " + name
+	}
+
+	var inputs []bson.Raw
+	if input, ok := plan.Lookup("inputStage").DocumentOK(); ok {
+		inputs = append(inputs, input)
+	}
+	if list, ok := plan.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := list.Values()
+		for _, v := range values {
+			if input, ok := v.DocumentOK(); ok {
+				inputs = append(inputs, input)
+			}
+		}
+	}
+	parts := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		summary, inputIndexed := PlanSummary(input)
+		parts = append(parts, summary)
+		indexed = indexed || inputIndexed
+	}
+	switch len(parts) {
+	case 0:
+		return stage, indexed
+	case 1:
+		return stage + " > " + parts[0], indexed
+	default:
+		return stage + " > (" + strings.Join(parts, ", ") + ")", indexed
+	}
+}
+
+func valueShape(value bson.RawValue) string {
+	switch value.Type {
+	case bson.TypeEmbeddedDocument:
+		elems, _ := value.Document().Elements()
+		parts := make([]string, 0, len(elems))
+		for _, elem := range elems {
+			parts = append(parts, fmt.Sprintf("%q:%s", elem.Key(), valueShape(elem.Value())))
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case bson.TypeArray:
+		values, _ := value.Array().Values()
+		parts := make([]string, 0, len(values))
+		for _, v := range values {
+			parts = append(parts, valueShape(v))
+		}
+		if len(parts) > 1 && allEqual(parts) {
+			parts = parts[:1]
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	default:
+		return "?"
+	}
+}
+
+func allEqual(values []string) bool {
+	for _, v := range values[1:] {
+		if v != values[0] {
+			return false
+		}
+	}
+	return true
+}
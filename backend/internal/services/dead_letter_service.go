@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Dead letter kinds: the delivery that failed.
+const (
+	// DeadLetterEvent is a domain event the broker or a webhook rejected
+	// on every relay attempt.
+	DeadLetterEvent = "event"
+	// DeadLetterEmail is an email the mail server did not accept.
+	DeadLetterEmail = "email"
+)
+
+// Dead letter statuses.
+const (
+	DeadLetterPending = "pending"
+	DeadLetterRetried = "retried"
+)
+
+// MaxBulkRetry caps how many dead letters one bulk retry redelivers.
+const MaxBulkRetry = 500
+
+var (
+	// ErrInvalidDeadLetterID indicates the dead letter ID could not be parsed.
+	ErrInvalidDeadLetterID = errors.New("invalid dead letter id")
+	// ErrInvalidDeadLetterFilter indicates an unknown kind or status filter.
+	ErrInvalidDeadLetterFilter = errors.New("invalid dead letter filter")
+	// ErrDeadLetterRetried is returned when retrying a delivered dead letter.
+	ErrDeadLetterRetried = errors.New("dead letter already retried")
+)
+
+// DeadLetter is a delivery that failed for good, kept so an administrator
+// can inspect and retry it. Payload is what is redelivered: the event or
+// the email message, as JSON.
+type DeadLetter struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Kind string             `bson:"kind"`
+	// Target is the event type or the email recipient; Reference the event
+	// ID or the sent-email log key.
+	Target    string          `bson:"target"`
+	Reference string          `bson:"reference"`
+	Payload   json.RawMessage `bson:"payload"`
+	Status    string          `bson:"status"`
+	// Attempts counts every failed or successful delivery, the original
+	// ones included.
+	Attempts  int        `bson:"attempts"`
+	LastError string     `bson:"lastError,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt"`
+	UpdatedAt time.Time  `bson:"updatedAt"`
+	RetriedAt *time.Time `bson:"retriedAt,omitempty"`
+}
+
+// DeadLetterResponse is the representation exposed through the API.
+type DeadLetterResponse struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Target    string          `json:"target"`
+	Reference string          `json:"reference"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	RetriedAt *time.Time      `json:"retriedAt,omitempty"`
+}
+
+// ToResponse converts a DeadLetter into its API representation.
+func (d DeadLetter) ToResponse() DeadLetterResponse {
+	return DeadLetterResponse{
+		ID:        d.ID.Hex(),
+		Kind:      d.Kind,
+		Target:    d.Target,
+		Reference: d.Reference,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+		RetriedAt: d.RetriedAt,
+	}
+}
+
+// NewDeadLetter builds a pending dead letter for payload, which is encoded
+// as JSON.
+func NewDeadLetter(kind, target, reference string, payload any, attempts int, cause error, at time.Time) (DeadLetter, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	return DeadLetter{
+		Kind:      kind,
+		Target:    target,
+		Reference: reference,
+		Payload:   encoded,
+		Status:    DeadLetterPending,
+		Attempts:  attempts,
+		LastError: cause.Error(),
+		CreatedAt: at,
+		UpdatedAt: at,
+	}, nil
+}
+
+// DeadLetterQuery selects the dead letters returned by a listing; empty
+// fields match everything.
+type DeadLetterQuery struct {
+	Kind   string
+	Status string
+	// Offset skips that many dead letters; Limit caps the result (zero
+	// means all).
+	Offset int
+	Limit  int
+}
+
+// DeadLetterPage is one slice of a dead letter listing plus the total
+// matching count.
+type DeadLetterPage struct {
+	DeadLetters []DeadLetterResponse
+	Total       int64
+}
+
+// DeadLetterRepository is the storage contract required by the dead letter
+// service.
+type DeadLetterRepository interface {
+	// List returns the matching dead letters, oldest first.
+	List(ctx context.Context, query DeadLetterQuery) ([]DeadLetter, error)
+	Count(ctx context.Context, query DeadLetterQuery) (int64, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (DeadLetter, error)
+	Create(ctx context.Context, letter DeadLetter) (DeadLetter, error)
+	Save(ctx context.Context, letter DeadLetter) error
+}
+
+// MongoDeadLetterRepository implements DeadLetterRepository backed by
+// MongoDB.
+type MongoDeadLetterRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoDeadLetterRepository creates a repository over collection.
+func NewMongoDeadLetterRepository(collection *mongo.Collection) *MongoDeadLetterRepository {
+	return &MongoDeadLetterRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used to list the dead letters.
+func (m *MongoDeadLetterRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "kind", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+func deadLetterFilter(query DeadLetterQuery) bson.M {
+	filter := bson.M{}
+	if query.Kind != "" {
+		filter["kind"] = query.Kind
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	return filter
+}
+
+// List implements DeadLetterRepository.
+func (m *MongoDeadLetterRepository) List(ctx context.Context, query DeadLetterQuery) ([]DeadLetter, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	if query.Offset > 0 {
+		opts.SetSkip(int64(query.Offset))
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := m.collection.Find(ctx, deadLetterFilter(query), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var letters []DeadLetter
+	if err := cursor.All(ctx, &letters); err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// Count implements DeadLetterRepository.
+func (m *MongoDeadLetterRepository) Count(ctx context.Context, query DeadLetterQuery) (int64, error) {
+	return m.collection.CountDocuments(ctx, deadLetterFilter(query))
+}
+
+// FindByID implements DeadLetterRepository.
+func (m *MongoDeadLetterRepository) FindByID(ctx context.Context, id primitive.ObjectID) (DeadLetter, error) {
+	var letter DeadLetter
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&letter)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return DeadLetter{}, ErrNotFound
+	}
+	return letter, err
+}
+
+// Create implements DeadLetterRepository.
+func (m *MongoDeadLetterRepository) Create(ctx context.Context, letter DeadLetter) (DeadLetter, error) {
+	res, err := m.collection.InsertOne(ctx, letter)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		letter.ID = oid
+	}
+	return letter, nil
+}
+
+// Save implements DeadLetterRepository.
+func (m *MongoDeadLetterRepository) Save(ctx context.Context, letter DeadLetter) error {
+	res, err := m.collection.ReplaceOne(ctx, bson.M{"_id": letter.ID}, letter)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Redeliverer sends the payload of a dead letter again.
+type Redeliverer func(ctx context.Context, payload json.RawMessage) error
+
+// BulkRetryResult reports how a bulk retry went.
+type BulkRetryResult struct {
+	Retried int `json:"retried"`
+	Failed  int `json:"failed"`
+}
+
+// DeadLetterService lets administrators inspect and retry the failed
+// deliveries. Each kind is redelivered by the component that sends it,
+// registered with Handle.
+type DeadLetterService struct {
+	repo     DeadLetterRepository
+	handlers map[string]Redeliverer
+	now      func() time.Time
+}
+
+// NewDeadLetterService builds a new DeadLetterService instance.
+func NewDeadLetterService(repo DeadLetterRepository, now func() time.Time) *DeadLetterService {
+	if now == nil {
+		now = time.Now
+	}
+	return &DeadLetterService{repo: repo, handlers: map[string]Redeliverer{}, now: now}
+}
+
+// Handle registers how the dead letters of kind are redelivered.
+func (s *DeadLetterService) Handle(kind string, redeliver Redeliverer) {
+	s.handlers[kind] = redeliver
+}
+
+func validDeadLetterQuery(query DeadLetterQuery) bool {
+	switch query.Kind {
+	case "", DeadLetterEvent, DeadLetterEmail:
+	default:
+		return false
+	}
+	switch query.Status {
+	case "", DeadLetterPending, DeadLetterRetried:
+		return true
+	}
+	return false
+}
+
+// List returns a page of dead letters, oldest first.
+func (s *DeadLetterService) List(ctx context.Context, query DeadLetterQuery) (DeadLetterPage, error) {
+	if !validDeadLetterQuery(query) {
+		return DeadLetterPage{}, ErrInvalidDeadLetterFilter
+	}
+	if query.Offset < 0 || query.Limit < 0 {
+		return DeadLetterPage{}, ErrInvalidPagination
+	}
+
+	letters, err := s.repo.List(ctx, query)
+	if err != nil {
+		return DeadLetterPage{}, err
+	}
+	total := int64(len(letters))
+	if query.Limit > 0 {
+		if total, err = s.repo.Count(ctx, query); err != nil {
+			return DeadLetterPage{}, err
+		}
+	}
+
+	responses := make([]DeadLetterResponse, 0, len(letters))
+	for _, letter := range letters {
+		responses = append(responses, letter.ToResponse())
+	}
+	return DeadLetterPage{DeadLetters: responses, Total: total}, nil
+}
+
+// Get returns one dead letter.
+func (s *DeadLetterService) Get(ctx context.Context, id string) (DeadLetterResponse, error) {
+	letter, err := s.find(ctx, id)
+	if err != nil {
+		return DeadLetterResponse{}, err
+	}
+	return letter.ToResponse(), nil
+}
+
+// Retry redelivers one pending dead letter. A failed redelivery is not an
+// error: the dead letter stays pending with the new error recorded.
+func (s *DeadLetterService) Retry(ctx context.Context, id string) (DeadLetterResponse, error) {
+	letter, err := s.find(ctx, id)
+	if err != nil {
+		return DeadLetterResponse{}, err
+	}
+	if letter.Status == DeadLetterRetried {
+		return DeadLetterResponse{}, ErrDeadLetterRetried
+	}
+	if letter, err = s.retry(ctx, letter); err != nil {
+		return DeadLetterResponse{}, err
+	}
+	return letter.ToResponse(), nil
+}
+
+// RetryAll redelivers up to MaxBulkRetry pending dead letters of kind, or
+// of every kind when kind is empty.
+func (s *DeadLetterService) RetryAll(ctx context.Context, kind string) (BulkRetryResult, error) {
+	query := DeadLetterQuery{Kind: kind, Status: DeadLetterPending, Limit: MaxBulkRetry}
+	if !validDeadLetterQuery(query) {
+		return BulkRetryResult{}, ErrInvalidDeadLetterFilter
+	}
+	letters, err := s.repo.List(ctx, query)
+	if err != nil {
+		return BulkRetryResult{}, err
+	}
+
+	var result BulkRetryResult
+	for _, letter := range letters {
+		retried, err := s.retry(ctx, letter)
+		if err != nil {
+			return result, err
+		}
+		if retried.Status == DeadLetterRetried {
+			result.Retried++
+		} else {
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+func (s *DeadLetterService) find(ctx context.Context, id string) (DeadLetter, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return DeadLetter{}, ErrInvalidDeadLetterID
+	}
+	return s.repo.FindByID(ctx, objID)
+}
+
+// retry redelivers letter and stores the outcome; only storage failures
+// are returned.
+func (s *DeadLetterService) retry(ctx context.Context, letter DeadLetter) (DeadLetter, error) {
+	err := fmt.Errorf("no hay reenvio para el tipo %q", letter.Kind)
+	if redeliver, ok := s.handlers[letter.Kind]; ok {
+		err = redeliver(ctx, letter.Payload)
+	}
+
+	now := s.now()
+	letter.Attempts++
+	letter.UpdatedAt = now
+	if err != nil {
+		letter.LastError = err.Error()
+	} else {
+		letter.Status = DeadLetterRetried
+		letter.RetriedAt = &now
+	}
+	return letter, s.repo.Save(ctx, letter)
+}
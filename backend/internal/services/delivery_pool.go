@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrDeliveryPoolClosed is returned when a delivery is submitted to a pool
+// that is shutting down.
+var ErrDeliveryPoolClosed = errors.New("delivery pool closed")
+
+// DeliveryPoolConfig sizes a DeliveryPool.
+type DeliveryPoolConfig struct {
+	// Workers is how many deliveries run at once (at least one).
+	Workers int
+	// Queue is how many deliveries wait for a worker; Submit blocks while
+	// it is full.
+	Queue int
+	// Interval is the least time between two deliveries to the same
+	// destination; zero does not limit them.
+	Interval time.Duration
+}
+
+// delivery is a task waiting in the queue of a DeliveryPool.
+type delivery struct {
+	ctx         context.Context
+	destination string
+	task        func(context.Context)
+}
+
+// DeliveryPool runs the deliveries of the notifications (emails and inbox
+// entries) on a fixed set of workers, so a digest or a comment with many
+// mentions does not start a goroutine per recipient, and spaces out the
+// deliveries to each destination so no mailbox gets a burst of them.
+type DeliveryPool struct {
+	cfg   DeliveryPoolConfig
+	queue chan delivery
+	// closing stops the submissions blocked on a full queue; abort cancels
+	// the running deliveries when Shutdown runs out of time.
+	closing chan struct{}
+	abort   context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu        sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+
+	slotsMu sync.Mutex
+	slots   map[string]time.Time
+}
+
+// NewDeliveryPool starts the workers of a new DeliveryPool.
+func NewDeliveryPool(cfg DeliveryPoolConfig) *DeliveryPool {
+	cfg.Workers = max(cfg.Workers, 1)
+	cfg.Queue = max(cfg.Queue, 0)
+	abort, cancel := context.WithCancel(context.Background())
+	p := &DeliveryPool{
+		cfg:     cfg,
+		queue:   make(chan delivery, cfg.Queue),
+		closing: make(chan struct{}),
+		abort:   abort,
+		cancel:  cancel,
+		slots:   map[string]time.Time{},
+	}
+	p.workers.Add(cfg.Workers)
+	for range cfg.Workers {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues task to deliver a notification to destination (e.g. an
+// email address), waiting for room in the queue until ctx is done. The
+// task runs with the values of ctx but not its cancellation, so it
+// outlives the request that submitted it.
+func (p *DeliveryPool) Submit(ctx context.Context, destination string, task func(context.Context)) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrDeliveryPoolClosed
+	}
+	select {
+	case p.queue <- delivery{ctx: context.WithoutCancel(ctx), destination: destination, task: task}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.closing:
+		return ErrDeliveryPoolClosed
+	}
+}
+
+// Shutdown stops taking deliveries and waits for the queued ones to
+// finish. Once ctx is done the running deliveries are cancelled, the
+// queued ones are dropped and the error of ctx is returned.
+func (p *DeliveryPool) Shutdown(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		// Release the blocked submissions before taking the lock they hold.
+		close(p.closing)
+		p.mu.Lock()
+		p.closed = true
+		close(p.queue)
+		p.mu.Unlock()
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-drained
+		return ctx.Err()
+	}
+}
+
+func (p *DeliveryPool) work() {
+	defer p.workers.Done()
+	for d := range p.queue {
+		if p.abort.Err() != nil {
+			log.Printf("entrega a %s descartada: el servidor se esta deteniendo", d.destination)
+			continue
+		}
+		p.run(d)
+	}
+}
+
+// run waits for the turn of the destination of d and delivers it.
+func (p *DeliveryPool) run(d delivery) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("panic en la entrega a %s: %v", d.destination, recovered)
+		}
+	}()
+	if wait := time.Until(p.reserve(d.destination)); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-p.abort.Done():
+			log.Printf("entrega a %s descartada: el servidor se esta deteniendo", d.destination)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.abort, cancel)
+	defer stop()
+	d.task(ctx)
+}
+
+// reserve returns when the next delivery to destination may start and
+// books that turn.
+func (p *DeliveryPool) reserve(destination string) time.Time {
+	now := time.Now()
+	if p.cfg.Interval <= 0 {
+		return now
+	}
+	p.slotsMu.Lock()
+	defer p.slotsMu.Unlock()
+	if len(p.slots) >= 1024 {
+		for key, slot := range p.slots {
+			if slot.Before(now) {
+				delete(p.slots, key)
+			}
+		}
+	}
+	slot := now
+	if next, ok := p.slots[destination]; ok && next.After(now) {
+		slot = next
+	}
+	p.slots[destination] = slot.Add(p.cfg.Interval)
+	return slot
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// EmailChangeTTL is how long the links of an email change stay valid.
+const EmailChangeTTL = 24 * time.Hour
+
+var (
+	// ErrInvalidEmailChange indicates a missing new email or the current
+	// one.
+	ErrInvalidEmailChange = errors.New("invalid email change")
+	// ErrEmailChangeNotVerified is returned when the password given to
+	// change the email is not the one of the account.
+	ErrEmailChangeNotVerified = errors.New("email change not verified")
+	// ErrInvalidEmailChangeToken is returned for unknown, used or expired
+	// email change links.
+	ErrInvalidEmailChangeToken = errors.New("invalid email change token")
+)
+
+// PendingEmailChange is a change of email waiting to be confirmed from the
+// new address; the old one can cancel it. Only the hashes of the tokens
+// of both links are stored.
+type PendingEmailChange struct {
+	NewEmail    string    `bson:"newEmail"`
+	TokenHash   string    `bson:"tokenHash"`
+	CancelHash  string    `bson:"cancelHash"`
+	RequestedAt time.Time `bson:"requestedAt"`
+	ExpiresAt   time.Time `bson:"expiresAt"`
+}
+
+// EmailChange is a requested change of email as returned by the API.
+type EmailChange struct {
+	Email     string    `json:"email" xml:"email"`
+	NewEmail  string    `json:"newEmail" xml:"newEmail"`
+	ExpiresAt time.Time `json:"expiresAt" xml:"expiresAt"`
+}
+
+// emailChangeMailData is the data available to the email change templates;
+// URL confirms the change or cancels it.
+type emailChangeMailData struct {
+	Email     string
+	NewEmail  string
+	URL       string
+	ExpiresAt time.Time
+}
+
+// EmailChangeService changes the email of an account once the new address
+// confirms it, warning the old one so its owner can cancel the change.
+type EmailChangeService struct {
+	users  UserRepository
+	merges AccountMergeRepository
+	mail   *BookingMailer
+	outbox Outbox
+	now    func() time.Time
+}
+
+// NewEmailChangeService builds a new EmailChangeService instance; merges
+// moves the records of the account to the new email.
+func NewEmailChangeService(users UserRepository, merges AccountMergeRepository, mail *BookingMailer, outbox Outbox, now func() time.Time) *EmailChangeService {
+	if now == nil {
+		now = time.Now
+	}
+	return &EmailChangeService{users: users, merges: merges, mail: mail, outbox: outbox, now: now}
+}
+
+// Request starts changing the email of the account of email to newEmail,
+// replacing any change in progress. Accounts with a password must give it.
+// The link to confirm goes to newEmail and the one to cancel to email.
+func (s *EmailChangeService) Request(ctx context.Context, email, newEmail, password string) (EmailChange, error) {
+	email, newEmail = NormalizeEmail(email), NormalizeEmail(newEmail)
+	if newEmail == "" || newEmail == email {
+		return EmailChange{}, ErrInvalidEmailChange
+	}
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		return EmailChange{}, err
+	}
+	password = NormalizeText(password)
+	if user.Password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(user.Password)) != 1 {
+		return EmailChange{}, ErrEmailChangeNotVerified
+	}
+	if _, err := s.users.FindByEmail(ctx, newEmail); err == nil {
+		return EmailChange{}, ErrUserAlreadyExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return EmailChange{}, err
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return EmailChange{}, err
+	}
+	cancel, err := newSessionToken()
+	if err != nil {
+		return EmailChange{}, err
+	}
+	now := s.now().UTC()
+	change := PendingEmailChange{
+		NewEmail:    newEmail,
+		TokenHash:   hashToken(token),
+		CancelHash:  hashToken(cancel),
+		RequestedAt: now,
+		ExpiresAt:   now.Add(EmailChangeTTL),
+	}
+	if _, err := s.users.SetEmailChange(ctx, email, &change); err != nil {
+		return EmailChange{}, err
+	}
+
+	err = runSteps(
+		func() error {
+			return s.notify(ctx, MailEmailChange, newEmail, email, change, "/users/email/confirm", token)
+		},
+		func() error {
+			return s.notify(ctx, MailEmailChangeNotice, email, email, change, "/users/email/cancel", cancel)
+		},
+	)
+	if err != nil {
+		return EmailChange{}, err
+	}
+	return EmailChange{Email: email, NewEmail: newEmail, ExpiresAt: change.ExpiresAt}, nil
+}
+
+// notify emails the kind link of change, with token, to to.
+func (s *EmailChangeService) notify(ctx context.Context, kind, to, email string, change PendingEmailChange, path, token string) error {
+	data := emailChangeMailData{
+		Email:     email,
+		NewEmail:  change.NewEmail,
+		URL:       s.mail.cfg.BaseURL + path + "?" + url.Values{"token": {token}}.Encode(),
+		ExpiresAt: change.ExpiresAt,
+	}
+	return s.mail.sendOnce(ctx, kind+":"+change.TokenHash, to, kind, func() any { return data })
+}
+
+// Confirm switches the account with the confirmation token to its new
+// email: the account, its todos and the rest of its records (see
+// AccountMergeRepository.Merge) move to the new email in one transaction
+// that records a user.email_changed event keyed by it. The sessions of the
+// old email are closed.
+func (s *EmailChangeService) Confirm(ctx context.Context, token string) (EmailChange, error) {
+	token = NormalizeText(token)
+	user, err := s.pending(ctx, token)
+	if err != nil {
+		return EmailChange{}, err
+	}
+	change := *user.EmailChange
+	if hashToken(token) != change.TokenHash {
+		return EmailChange{}, ErrInvalidEmailChangeToken
+	}
+
+	result := EmailChange{Email: user.Email, NewEmail: change.NewEmail, ExpiresAt: change.ExpiresAt}
+	err = s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if _, err := s.users.FindByEmail(ctx, change.NewEmail); err == nil {
+			return nil, ErrUserAlreadyExists
+		} else if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		// The account keeps its ID, so its todos stay its own.
+		if _, err := s.merges.Merge(ctx, user.Email, change.NewEmail); err != nil {
+			return nil, err
+		}
+		moved := user
+		moved.Email, moved.EmailChange = change.NewEmail, nil
+		if err := s.users.Insert(ctx, moved); err != nil {
+			return nil, err
+		}
+		return newEvents(events.UserEmailChanged, change.NewEmail, result, s.now())
+	})
+	if err != nil {
+		return EmailChange{}, err
+	}
+	return result, nil
+}
+
+// Cancel drops the pending email change with the cancellation token.
+func (s *EmailChangeService) Cancel(ctx context.Context, token string) error {
+	token = NormalizeText(token)
+	user, err := s.pending(ctx, token)
+	if err != nil {
+		return err
+	}
+	if hashToken(token) != user.EmailChange.CancelHash {
+		return ErrInvalidEmailChangeToken
+	}
+	_, err = s.users.SetEmailChange(ctx, user.Email, nil)
+	return err
+}
+
+// pending returns the user whose unexpired email change has token.
+func (s *EmailChangeService) pending(ctx context.Context, token string) (User, error) {
+	if token == "" {
+		return User{}, ErrInvalidEmailChangeToken
+	}
+	user, err := s.users.FindByEmailChangeToken(ctx, hashToken(token))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return User{}, ErrInvalidEmailChangeToken
+	case err != nil:
+		return User{}, err
+	case user.EmailChange == nil || !s.now().Before(user.EmailChange.ExpiresAt):
+		return User{}, ErrInvalidEmailChangeToken
+	}
+	return user, nil
+}
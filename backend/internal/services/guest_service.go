@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidGuestInput indicates missing or malformed guest data.
+	ErrInvalidGuestInput = errors.New("invalid guest input")
+	// ErrInvalidGuestID indicates the guest ID could not be parsed.
+	ErrInvalidGuestID = errors.New("invalid guest id")
+	// ErrGuestDocumentTaken is returned when another guest has the same document.
+	ErrGuestDocumentTaken = errors.New("guest document already exists")
+)
+
+// GuestUpdate models the fields that can be updated on a Guest.
+type GuestUpdate struct {
+	Name        *string
+	Document    *string
+	Email       *string
+	Phone       *string
+	Preferences *[]string
+	UpdatedAt   time.Time
+}
+
+// GuestRepository is the storage contract required by the guest service.
+type GuestRepository interface {
+	// Search matches search against the name (case-insensitive substring) or
+	// the normalized document; an empty search lists every guest.
+	Search(ctx context.Context, search string) ([]Guest, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (Guest, error)
+	Create(ctx context.Context, guest Guest) (Guest, error)
+	Update(ctx context.Context, id primitive.ObjectID, update GuestUpdate) (Guest, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoGuestRepository implements GuestRepository backed by MongoDB.
+type MongoGuestRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoGuestRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoGuestRepository(collection *mongo.Collection) *MongoGuestRepository {
+	return &MongoGuestRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique document index and the name index used
+// by front-desk searches.
+func (m *MongoGuestRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "document", Value: 1}}, Options: options.Index().SetUnique(true).SetName("document_unique")},
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+	})
+	return err
+}
+
+// Search returns guests matching search ordered by name.
+func (m *MongoGuestRepository) Search(ctx context.Context, search string) ([]Guest, error) {
+	filter := bson.M{}
+	if search != "" {
+		filter["$or"] = bson.A{
+			bson.M{"name": primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}},
+			bson.M{"document": NormalizeDocument(search)},
+		}
+	}
+
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var guests []Guest
+	if err := cursor.All(ctx, &guests); err != nil {
+		return nil, err
+	}
+	return guests, nil
+}
+
+// FindByID retrieves a guest or returns ErrNotFound.
+func (m *MongoGuestRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Guest, error) {
+	var guest Guest
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&guest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Guest{}, ErrNotFound
+	}
+	return guest, err
+}
+
+// Create stores a guest and returns it with the generated ID.
+func (m *MongoGuestRepository) Create(ctx context.Context, guest Guest) (Guest, error) {
+	res, err := m.collection.InsertOne(ctx, guest)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Guest{}, ErrGuestDocumentTaken
+		}
+		return Guest{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		guest.ID = oid
+	}
+	return guest, nil
+}
+
+// Update modifies a guest and returns the updated version.
+func (m *MongoGuestRepository) Update(ctx context.Context, id primitive.ObjectID, update GuestUpdate) (Guest, error) {
+	updateDoc := bson.M{"updatedAt": update.UpdatedAt}
+	if update.Name != nil {
+		updateDoc["name"] = *update.Name
+	}
+	if update.Document != nil {
+		updateDoc["document"] = *update.Document
+	}
+	if update.Email != nil {
+		updateDoc["email"] = *update.Email
+	}
+	if update.Phone != nil {
+		updateDoc["phone"] = *update.Phone
+	}
+	if update.Preferences != nil {
+		updateDoc["preferences"] = *update.Preferences
+	}
+
+	res := m.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": updateDoc},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+
+	var guest Guest
+	if err := res.Decode(&guest); err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return Guest{}, ErrNotFound
+		case mongo.IsDuplicateKeyError(err):
+			return Guest{}, ErrGuestDocumentTaken
+		}
+		return Guest{}, err
+	}
+	return guest, nil
+}
+
+// Delete removes a guest by ID.
+func (m *MongoGuestRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GuestService encapsulates business logic for guest profiles.
+type GuestService struct {
+	repo     GuestRepository
+	bookings BookingRepository
+	now      func() time.Time
+	ids      IDGenerator
+}
+
+// NewGuestService builds a new GuestService instance.
+func NewGuestService(repo GuestRepository, bookings BookingRepository, now func() time.Time, ids IDGenerator) *GuestService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &GuestService{repo: repo, bookings: bookings, now: now, ids: ids}
+}
+
+// Search returns guests whose name contains search or whose document equals it.
+func (s *GuestService) Search(ctx context.Context, search string) ([]GuestResponse, error) {
+	guests, err := s.repo.Search(ctx, NormalizeText(search))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]GuestResponse, 0, len(guests))
+	for _, guest := range guests {
+		responses = append(responses, guest.ToResponse())
+	}
+	return responses, nil
+}
+
+// Get returns a single guest.
+func (s *GuestService) Get(ctx context.Context, id string) (GuestResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return GuestResponse{}, ErrInvalidGuestID
+	}
+
+	guest, err := s.repo.FindByID(ctx, objID)
+	if err != nil {
+		return GuestResponse{}, err
+	}
+	return guest.ToResponse(), nil
+}
+
+// Create validates input and stores a new guest; name and document are
+// required.
+func (s *GuestService) Create(ctx context.Context, guest Guest) (GuestResponse, error) {
+	guest.Name = NormalizeText(guest.Name)
+	guest.Document = NormalizeDocument(guest.Document)
+	guest.Email = NormalizeEmail(guest.Email)
+	guest.Phone = NormalizeText(guest.Phone)
+	guest.Preferences = normalizePreferences(guest.Preferences)
+	if guest.Name == "" || guest.Document == "" {
+		return GuestResponse{}, ErrInvalidGuestInput
+	}
+
+	now := s.now()
+	guest.ID = s.ids.NewID()
+	guest.CreatedAt = now
+	guest.UpdatedAt = now
+
+	created, err := s.repo.Create(ctx, guest)
+	if err != nil {
+		return GuestResponse{}, err
+	}
+	return created.ToResponse(), nil
+}
+
+// Update applies the provided modification to a guest.
+func (s *GuestService) Update(ctx context.Context, id string, update GuestUpdate) (GuestResponse, error) {
+	if update.Name == nil && update.Document == nil && update.Email == nil &&
+		update.Phone == nil && update.Preferences == nil {
+		return GuestResponse{}, ErrInvalidGuestInput
+	}
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return GuestResponse{}, ErrInvalidGuestID
+	}
+
+	if update.Name != nil {
+		name := NormalizeText(*update.Name)
+		if name == "" {
+			return GuestResponse{}, ErrInvalidGuestInput
+		}
+		update.Name = &name
+	}
+	if update.Document != nil {
+		document := NormalizeDocument(*update.Document)
+		if document == "" {
+			return GuestResponse{}, ErrInvalidGuestInput
+		}
+		update.Document = &document
+	}
+	if update.Email != nil {
+		email := NormalizeEmail(*update.Email)
+		update.Email = &email
+	}
+	if update.Phone != nil {
+		phone := NormalizeText(*update.Phone)
+		update.Phone = &phone
+	}
+	if update.Preferences != nil {
+		preferences := normalizePreferences(*update.Preferences)
+		update.Preferences = &preferences
+	}
+	update.UpdatedAt = s.now()
+
+	updated, err := s.repo.Update(ctx, objID, update)
+	if err != nil {
+		return GuestResponse{}, err
+	}
+	return updated.ToResponse(), nil
+}
+
+// Delete removes a guest by ID. Past bookings keep their guest reference.
+func (s *GuestService) Delete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidGuestID
+	}
+	return s.repo.Delete(ctx, objID)
+}
+
+// Bookings returns the booking history of a guest; guest profiles are
+// shared by the whole chain, but scoped requests only see the bookings of
+// their property.
+func (s *GuestService) Bookings(ctx context.Context, id string) ([]BookingResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidGuestID
+	}
+	if _, err := s.repo.FindByID(ctx, objID); err != nil {
+		return nil, err
+	}
+
+	bookings, err := s.bookings.List(ctx, BookingQuery{PropertyID: ScopedProperty(ctx), GuestID: objID})
+	if err != nil {
+		return nil, err
+	}
+	return bookingResponses(bookings), nil
+}
+
+// NormalizeDocument uppercases an identity document number and drops the
+// spaces, dots and dashes people type in different ways.
+func NormalizeDocument(document string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", ".", "", "-", "").Replace(document))
+}
+
+// normalizePreferences trims preferences and drops empty and repeated ones,
+// keeping the original wording.
+func normalizePreferences(preferences []string) []string {
+	seen := make(map[string]bool, len(preferences))
+	result := make([]string, 0, len(preferences))
+	for _, preference := range preferences {
+		preference = NormalizeText(preference)
+		if preference == "" || seen[preference] {
+			continue
+		}
+		seen[preference] = true
+		result = append(result, preference)
+	}
+	return result
+}
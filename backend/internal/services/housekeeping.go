@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Housekeeping turns check-outs into cleaning todos assigned round-robin to
+// the housekeeping staff, who get an assignment notification.
+type Housekeeping struct {
+	todos         *TodoService
+	rooms         RoomRepository
+	notifications *NotificationService
+	staff         []string
+	next          atomic.Uint64
+}
+
+// NewHousekeeping builds a Housekeeping bridge; with no staff emails it only
+// logs the rooms that need cleaning. A nil notifications sends none.
+func NewHousekeeping(todos *TodoService, rooms RoomRepository, notifications *NotificationService, staff []string) *Housekeeping {
+	normalized := make([]string, 0, len(staff))
+	for _, email := range staff {
+		if email = NormalizeEmail(email); email != "" {
+			normalized = append(normalized, email)
+		}
+	}
+	return &Housekeeping{todos: todos, rooms: rooms, notifications: notifications, staff: normalized}
+}
+
+// HandleBookingEvent creates the cleaning todo of a checked-out room. It is
+// meant to be registered with BookingService.Subscribe.
+func (h *Housekeeping) HandleBookingEvent(ctx context.Context, event BookingEvent) {
+	if event.Type != EventBookingCheckedOut {
+		return
+	}
+
+	roomID := event.Booking.RoomID
+	if len(h.staff) == 0 {
+		log.Printf("habitacion %s pendiente de limpieza: no hay personal de housekeeping configurado", roomID.Hex())
+		return
+	}
+
+	title := "Limpiar habitacion"
+	if room, err := h.rooms.FindByID(ctx, roomID); err == nil {
+		title = fmt.Sprintf("Limpiar habitacion %s", room.Number)
+	}
+
+	assignee := h.staff[(h.next.Add(1)-1)%uint64(len(h.staff))]
+	todo, err := h.todos.CreateForRoom(ctx, assignee, title, roomID, event.Booking.PropertyID)
+	if err != nil {
+		log.Printf("no se pudo crear la tarea de limpieza de la habitacion %s: %v", roomID.Hex(), err)
+		return
+	}
+	if h.notifications == nil {
+		return
+	}
+	todoID, _ := primitive.ObjectIDFromHex(todo.ID)
+	err = h.notifications.Notify(ctx, Notification{Email: assignee, Kind: NotificationAssignment, TodoID: todoID})
+	if err != nil {
+		log.Printf("no se pudo notificar la tarea de limpieza a %s: %v", assignee, err)
+	}
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxImportRows caps the bookings accepted by a single import.
+const MaxImportRows = 5000
+
+// importProgressEvery is how many rows are processed between progress saves.
+const importProgressEvery = 100
+
+// Import run statuses.
+const (
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+)
+
+// Import row outcomes. Reason explains invalid, conflict and failed rows.
+const (
+	ImportRowCreated   = "created"
+	ImportRowDuplicate = "duplicate"
+	ImportRowConflict  = "conflict"
+	ImportRowInvalid   = "invalid"
+	ImportRowFailed    = "failed"
+)
+
+var (
+	// ErrInvalidImport indicates a missing channel, no rows, too many rows or
+	// an unreadable CSV.
+	ErrInvalidImport = errors.New("invalid booking import")
+	// ErrInvalidImportID indicates the import run ID could not be parsed.
+	ErrInvalidImportID = errors.New("invalid import id")
+)
+
+// ExternalBooking is a booking received from a channel manager, already
+// normalized to our fields. The room is given by ID or by number.
+type ExternalBooking struct {
+	ExternalRef string
+	RoomID      string
+	RoomNumber  string
+	Email       string
+	Guests      int
+	CheckIn     string
+	CheckOut    string
+}
+
+// ImportResult is the outcome of one imported row (1-based).
+type ImportResult struct {
+	Row         int    `json:"row" bson:"row" xml:"row"`
+	ExternalRef string `json:"externalRef" bson:"externalRef" xml:"externalRef"`
+	Status      string `json:"status" bson:"status" xml:"status"`
+	BookingID   string `json:"bookingId,omitempty" bson:"bookingId,omitempty" xml:"bookingId,omitempty"`
+	Reason      string `json:"reason,omitempty" bson:"reason,omitempty" xml:"reason,omitempty"`
+}
+
+// ImportRun tracks the processing of one import request.
+type ImportRun struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty"`
+	PropertyID *primitive.ObjectID `bson:"propertyId,omitempty"`
+	Channel    string              `bson:"channel"`
+	Status     string              `bson:"status"`
+	Total      int                 `bson:"total"`
+	Processed  int                 `bson:"processed"`
+	Created    int                 `bson:"created"`
+	Duplicates int                 `bson:"duplicates"`
+	Conflicts  int                 `bson:"conflicts"`
+	Invalid    int                 `bson:"invalid"`
+	Failed     int                 `bson:"failed"`
+	Results    []ImportResult      `bson:"results"`
+	CreatedAt  time.Time           `bson:"createdAt"`
+	FinishedAt *time.Time          `bson:"finishedAt,omitempty"`
+}
+
+// ImportRunResponse is the representation exposed through the API.
+type ImportRunResponse struct {
+	ID         string         `json:"id" xml:"id"`
+	PropertyID string         `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	Channel    string         `json:"channel" xml:"channel"`
+	Status     string         `json:"status" xml:"status"`
+	Total      int            `json:"total" xml:"total"`
+	Processed  int            `json:"processed" xml:"processed"`
+	Created    int            `json:"created" xml:"created"`
+	Duplicates int            `json:"duplicates" xml:"duplicates"`
+	Conflicts  int            `json:"conflicts" xml:"conflicts"`
+	Invalid    int            `json:"invalid" xml:"invalid"`
+	Failed     int            `json:"failed" xml:"failed"`
+	Results    []ImportResult `json:"results" xml:"results>result"`
+	CreatedAt  time.Time      `json:"createdAt" xml:"createdAt"`
+	FinishedAt *time.Time     `json:"finishedAt,omitempty" xml:"finishedAt,omitempty"`
+}
+
+// ToResponse converts an ImportRun into an externally safe representation.
+func (r ImportRun) ToResponse() ImportRunResponse {
+	results := r.Results
+	if results == nil {
+		results = []ImportResult{}
+	}
+	response := ImportRunResponse{
+		ID:         r.ID.Hex(),
+		Channel:    r.Channel,
+		Status:     r.Status,
+		Total:      r.Total,
+		Processed:  r.Processed,
+		Created:    r.Created,
+		Duplicates: r.Duplicates,
+		Conflicts:  r.Conflicts,
+		Invalid:    r.Invalid,
+		Failed:     r.Failed,
+		Results:    results,
+		CreatedAt:  r.CreatedAt,
+		FinishedAt: r.FinishedAt,
+	}
+	if r.PropertyID != nil {
+		response.PropertyID = r.PropertyID.Hex()
+	}
+	return response
+}
+
+func (r *ImportRun) record(result ImportResult) {
+	r.Results = append(r.Results, result)
+	r.Processed++
+	switch result.Status {
+	case ImportRowCreated:
+		r.Created++
+	case ImportRowDuplicate:
+		r.Duplicates++
+	case ImportRowConflict:
+		r.Conflicts++
+	case ImportRowInvalid:
+		r.Invalid++
+	default:
+		r.Failed++
+	}
+}
+
+// ImportRunRepository is the storage contract required by the import service.
+type ImportRunRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (ImportRun, error)
+	Create(ctx context.Context, run ImportRun) (ImportRun, error)
+	// Save replaces the stored run with run.
+	Save(ctx context.Context, run ImportRun) error
+}
+
+// MongoImportRunRepository implements ImportRunRepository backed by MongoDB.
+type MongoImportRunRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoImportRunRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoImportRunRepository(collection *mongo.Collection) *MongoImportRunRepository {
+	return &MongoImportRunRepository{collection: collection}
+}
+
+// FindByID retrieves a run or returns ErrNotFound.
+func (m *MongoImportRunRepository) FindByID(ctx context.Context, id primitive.ObjectID) (ImportRun, error) {
+	var run ImportRun
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&run)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ImportRun{}, ErrNotFound
+	}
+	return run, err
+}
+
+// Create stores a run and returns it with the generated ID.
+func (m *MongoImportRunRepository) Create(ctx context.Context, run ImportRun) (ImportRun, error) {
+	res, err := m.collection.InsertOne(ctx, run)
+	if err != nil {
+		return ImportRun{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		run.ID = oid
+	}
+	return run, nil
+}
+
+// Save replaces the run document.
+func (m *MongoImportRunRepository) Save(ctx context.Context, run ImportRun) error {
+	res, err := m.collection.ReplaceOne(ctx, bson.M{"_id": run.ID}, run, options.Replace())
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// importColumns maps the accepted CSV headers (lowercase, without spaces,
+// dashes or underscores) to the ExternalBooking field they fill.
+var importColumns = map[string]func(*ExternalBooking, string){
+	"externalref": func(b *ExternalBooking, v string) { b.ExternalRef = v },
+	"roomid":      func(b *ExternalBooking, v string) { b.RoomID = v },
+	"roomnumber":  func(b *ExternalBooking, v string) { b.RoomNumber = v },
+	"email":       func(b *ExternalBooking, v string) { b.Email = v },
+	"guests": func(b *ExternalBooking, v string) {
+		// A malformed count is left at zero and reported as an invalid row.
+		b.Guests, _ = strconv.Atoi(strings.TrimSpace(v))
+	},
+	"checkin":  func(b *ExternalBooking, v string) { b.CheckIn = v },
+	"checkout": func(b *ExternalBooking, v string) { b.CheckOut = v },
+}
+
+var importHeader = strings.NewReplacer(" ", "", "-", "", "_", "")
+
+// ParseImportCSV reads bookings from a CSV whose first row names the
+// columns (externalRef, roomId or roomNumber, email, guests, checkIn,
+// checkOut); unknown columns are ignored.
+func ParseImportCSV(r io.Reader) ([]ExternalBooking, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrInvalidImport
+	}
+	setters := make([]func(*ExternalBooking, string), len(header))
+	for i, name := range header {
+		setters[i] = importColumns[importHeader.Replace(normalizeKeyword(strings.TrimPrefix(name, "\ufeff")))]
+	}
+
+	var bookings []ExternalBooking
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return bookings, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidImport
+		}
+		var booking ExternalBooking
+		for i, value := range record {
+			if i < len(setters) && setters[i] != nil {
+				setters[i](&booking, value)
+			}
+		}
+		bookings = append(bookings, booking)
+	}
+}
+
+// ImportService loads bookings from channel managers (OTAs). Each import
+// runs in the background; rows already imported from the channel are
+// skipped and rows overlapping existing bookings are reported as conflicts.
+type ImportService struct {
+	runs     ImportRunRepository
+	bookings *BookingService
+	rooms    RoomRepository
+	now      func() time.Time
+	ids      IDGenerator
+}
+
+// NewImportService builds a new ImportService instance.
+func NewImportService(runs ImportRunRepository, bookings *BookingService, rooms RoomRepository, now func() time.Time, ids IDGenerator) *ImportService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &ImportService{runs: runs, bookings: bookings, rooms: rooms, now: now, ids: ids}
+}
+
+// Start records an import run for the scoped property and processes the
+// rows in the background; the returned run is still running.
+func (s *ImportService) Start(ctx context.Context, channel string, rows []ExternalBooking) (ImportRunResponse, error) {
+	channel = normalizeKeyword(channel)
+	if channel == "" || len(rows) == 0 || len(rows) > MaxImportRows {
+		return ImportRunResponse{}, ErrInvalidImport
+	}
+
+	run := ImportRun{
+		ID:        s.ids.NewID(),
+		Channel:   channel,
+		Status:    ImportRunning,
+		Total:     len(rows),
+		Results:   []ImportResult{},
+		CreatedAt: s.now(),
+	}
+	if property := ScopedProperty(ctx); !property.IsZero() {
+		run.PropertyID = &property
+	}
+	created, err := s.runs.Create(ctx, run)
+	if err != nil {
+		return ImportRunResponse{}, err
+	}
+
+	// Keep the property scope but outlive the request.
+	go s.process(context.WithoutCancel(ctx), created, rows)
+	return created.ToResponse(), nil
+}
+
+// Get returns an import run of the scoped property.
+func (s *ImportService) Get(ctx context.Context, id string) (ImportRunResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ImportRunResponse{}, ErrInvalidImportID
+	}
+	run, err := s.runs.FindByID(ctx, objID)
+	if err != nil {
+		return ImportRunResponse{}, err
+	}
+	if !inScope(ctx, run.PropertyID) {
+		return ImportRunResponse{}, ErrNotFound
+	}
+	return run.ToResponse(), nil
+}
+
+func (s *ImportService) process(ctx context.Context, run ImportRun, rows []ExternalBooking) {
+	for i, row := range rows {
+		result := s.importRow(ctx, run.Channel, row)
+		result.Row = i + 1
+		run.record(result)
+
+		if run.Processed%importProgressEvery == 0 && run.Processed < run.Total {
+			if err := s.runs.Save(ctx, run); err != nil {
+				log.Printf("no se pudo guardar el avance de la importacion %s: %v", run.ID.Hex(), err)
+			}
+		}
+	}
+
+	finished := s.now()
+	run.Status = ImportCompleted
+	run.FinishedAt = &finished
+	if err := s.runs.Save(ctx, run); err != nil {
+		log.Printf("no se pudo guardar el resultado de la importacion %s: %v", run.ID.Hex(), err)
+	}
+}
+
+// importRow creates the booking of one row and classifies the outcome.
+func (s *ImportService) importRow(ctx context.Context, channel string, row ExternalBooking) ImportResult {
+	result := ImportResult{ExternalRef: NormalizeText(row.ExternalRef)}
+	if result.ExternalRef == "" {
+		result.Status, result.Reason = ImportRowInvalid, "missing_external_ref"
+		return result
+	}
+
+	existing, err := s.bookings.bookings.List(ctx, BookingQuery{Channel: channel, ExternalRef: result.ExternalRef})
+	if err != nil {
+		return s.failed(result, err)
+	}
+	if len(existing) > 0 {
+		result.Status, result.BookingID = ImportRowDuplicate, existing[0].ID.Hex()
+		return result
+	}
+
+	roomID, err := s.roomID(ctx, row)
+	if err != nil {
+		return s.failed(result, err)
+	}
+	if roomID == "" {
+		result.Status, result.Reason = ImportRowInvalid, "room_not_found"
+		return result
+	}
+
+	booking, err := s.bookings.Create(ctx, BookingInput{
+		RoomID:      roomID,
+		Email:       row.Email,
+		Guests:      row.Guests,
+		CheckIn:     row.CheckIn,
+		CheckOut:    row.CheckOut,
+		Channel:     channel,
+		ExternalRef: result.ExternalRef,
+	})
+	switch {
+	case err == nil:
+		result.Status, result.BookingID = ImportRowCreated, booking.ID
+	case errors.Is(err, ErrDuplicateExternalRef):
+		result.Status = ImportRowDuplicate
+	case errors.Is(err, ErrBookingOverlap):
+		result.Status, result.Reason = ImportRowConflict, "room_not_available"
+	case errors.Is(err, ErrInvalidBookingInput):
+		result.Status, result.Reason = ImportRowInvalid, "invalid_booking"
+	case errors.Is(err, ErrInvalidStayDates):
+		result.Status, result.Reason = ImportRowInvalid, "invalid_dates"
+	case errors.Is(err, ErrBookingRoomNotFound):
+		result.Status, result.Reason = ImportRowInvalid, "room_not_found"
+	default:
+		return s.failed(result, err)
+	}
+	return result
+}
+
+// roomID resolves the room of a row, by ID or by number within the scoped
+// property; it returns "" when no single room matches.
+func (s *ImportService) roomID(ctx context.Context, row ExternalBooking) (string, error) {
+	if id := NormalizeText(row.RoomID); id != "" {
+		return id, nil
+	}
+	number := NormalizeText(row.RoomNumber)
+	if number == "" {
+		return "", nil
+	}
+
+	rooms, err := s.rooms.List(ctx, RoomQuery{PropertyID: ScopedProperty(ctx), Number: number})
+	if err != nil || len(rooms) != 1 {
+		return "", err
+	}
+	return rooms[0].ID.Hex(), nil
+}
+
+func (s *ImportService) failed(result ImportResult, err error) ImportResult {
+	log.Printf("no se pudo importar la reserva %s: %v", result.ExternalRef, err)
+	result.Status, result.Reason = ImportRowFailed, "internal_error"
+	return result
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+)
+
+// Background job names.
+const (
+	JobReminders      = "reminders"
+	JobTodoDigest     = "todo-digest"
+	JobTrashPurge     = "trash-purge"
+	JobRecurringTodos = "recurring-todos"
+	JobAttachmentScan = "attachment-scan"
+	JobTodoArchive    = "todo-archive"
+)
+
+// leaderLeaseID is the _id of the scheduler lease document.
+const leaderLeaseID = "scheduler"
+
+// MongoLeaderLease implements scheduler.Elector with a lease document: the
+// replica holding an unexpired lease is the leader and renews it; when it
+// stops renewing, another replica takes over once the lease expires.
+type MongoLeaderLease struct {
+	collection *mongo.Collection
+	instance   string
+	ttl        time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	renewedAt time.Time
+	leader    bool
+}
+
+// NewMongoLeaderLease creates a lease held for ttl by instance.
+func NewMongoLeaderLease(collection *mongo.Collection, instance string, ttl time.Duration, now func() time.Time) *MongoLeaderLease {
+	if now == nil {
+		now = time.Now
+	}
+	return &MongoLeaderLease{collection: collection, instance: instance, ttl: ttl, now: now}
+}
+
+// Lead implements scheduler.Elector. The leader renews the lease once a
+// third of its ttl has passed, so it keeps it across a few failed renewals.
+func (l *MongoLeaderLease) Lead(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.leader && now.Sub(l.renewedAt) < l.ttl/3 {
+		return true, nil
+	}
+
+	// The filter matches when this replica holds the lease or it expired;
+	// otherwise the upsert collides with the lease held by another replica.
+	_, err := l.collection.UpdateOne(ctx,
+		bson.M{"_id": leaderLeaseID, "$or": bson.A{
+			bson.M{"holder": l.instance},
+			bson.M{"expiresAt": bson.M{"$lte": now}},
+		}},
+		bson.M{"$set": bson.M{"holder": l.instance, "expiresAt": now.Add(l.ttl)}},
+		options.Update().SetUpsert(true),
+	)
+	switch {
+	case err == nil:
+		l.leader, l.renewedAt = true, now
+	case mongo.IsDuplicateKeyError(err):
+		l.leader = false
+		return false, nil
+	default:
+		// Keep leading while the lease we hold has not expired.
+		l.leader = l.leader && now.Before(l.renewedAt.Add(l.ttl))
+		return l.leader, err
+	}
+	return true, nil
+}
+
+// MongoJobLocks implements scheduler.Locker with a lock document per job
+// holding the last activation claimed and until when its run holds the
+// lock. A replica claims an activation only if it is later than the one
+// in the document and the lock expired; otherwise the upsert collides with
+// the document and the claim fails.
+type MongoJobLocks struct {
+	collection *mongo.Collection
+	instance   string
+	now        func() time.Time
+}
+
+// NewMongoJobLocks creates the job locks of instance over collection.
+func NewMongoJobLocks(collection *mongo.Collection, instance string, now func() time.Time) *MongoJobLocks {
+	if now == nil {
+		now = time.Now
+	}
+	return &MongoJobLocks{collection: collection, instance: instance, now: now}
+}
+
+// Lock implements scheduler.Locker.
+func (m *MongoJobLocks) Lock(ctx context.Context, name string, due time.Time, ttl time.Duration) (bool, error) {
+	now := m.now()
+	_, err := m.collection.UpdateOne(ctx,
+		bson.M{"_id": name, "due": bson.M{"$lt": due}, "expiresAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"holder": m.instance, "due": due, "expiresAt": now.Add(ttl)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Extend implements scheduler.Locker.
+func (m *MongoJobLocks) Extend(ctx context.Context, name string, due time.Time, ttl time.Duration) (bool, error) {
+	res, err := m.collection.UpdateOne(ctx,
+		bson.M{"_id": name, "holder": m.instance, "due": due},
+		bson.M{"$set": bson.M{"expiresAt": m.now().Add(ttl)}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// Unlock implements scheduler.Locker, expiring the lock now.
+func (m *MongoJobLocks) Unlock(ctx context.Context, name string, due time.Time) error {
+	_, err := m.collection.UpdateOne(ctx,
+		bson.M{"_id": name, "holder": m.instance, "due": due},
+		bson.M{"$set": bson.M{"expiresAt": m.now()}},
+	)
+	return err
+}
+
+// MongoJobStore implements scheduler.Store over a collection keyed by job
+// name.
+type MongoJobStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoJobStore creates a store over collection.
+func NewMongoJobStore(collection *mongo.Collection) *MongoJobStore {
+	return &MongoJobStore{collection: collection}
+}
+
+// Save implements scheduler.Store.
+func (m *MongoJobStore) Save(ctx context.Context, status scheduler.Status) error {
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": status.Name}, status, options.Replace().SetUpsert(true))
+	return err
+}
+
+// List implements scheduler.Store.
+func (m *MongoJobStore) List(ctx context.Context) ([]scheduler.Status, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var statuses []scheduler.Status
+	if err := cursor.All(ctx, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
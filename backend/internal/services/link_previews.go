@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/linkpreview"
+)
+
+// maxLinkPreviews caps the URLs of a todo that get a preview.
+const maxLinkPreviews = 3
+
+// linkPreviewTimeout bounds the time a request waits for the previews.
+const linkPreviewTimeout = 5 * time.Second
+
+// LinkFetcher reads the preview of a URL; linkpreview.Fetcher implements
+// it.
+type LinkFetcher interface {
+	Fetch(ctx context.Context, url string) (linkpreview.Preview, error)
+}
+
+// CachedLinkPreview is a preview stored in the cache with when it was
+// fetched.
+type CachedLinkPreview struct {
+	URL       string              `bson:"_id"`
+	Preview   linkpreview.Preview `bson:"preview"`
+	FetchedAt time.Time           `bson:"fetchedAt"`
+}
+
+// LinkPreviewCache is the storage contract of the fetched previews.
+type LinkPreviewCache interface {
+	// Find returns the cached preview of url, or ErrNotFound.
+	Find(ctx context.Context, url string) (CachedLinkPreview, error)
+	Save(ctx context.Context, preview CachedLinkPreview) error
+}
+
+// MongoLinkPreviewCache implements LinkPreviewCache backed by MongoDB.
+type MongoLinkPreviewCache struct {
+	collection *mongo.Collection
+}
+
+// NewMongoLinkPreviewCache creates a new cache around a Mongo collection.
+func NewMongoLinkPreviewCache(collection *mongo.Collection) *MongoLinkPreviewCache {
+	return &MongoLinkPreviewCache{collection: collection}
+}
+
+// EnsureIndexes creates the TTL index that drops the previews fetched over
+// ttl ago.
+func (m *MongoLinkPreviewCache) EnsureIndexes(ctx context.Context, ttl time.Duration) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "fetchedAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl / time.Second)),
+	})
+	return err
+}
+
+// Find implements LinkPreviewCache.
+func (m *MongoLinkPreviewCache) Find(ctx context.Context, url string) (CachedLinkPreview, error) {
+	var cached CachedLinkPreview
+	err := m.collection.FindOne(ctx, bson.M{"_id": url}).Decode(&cached)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return CachedLinkPreview{}, ErrNotFound
+	}
+	return cached, err
+}
+
+// Save implements LinkPreviewCache.
+func (m *MongoLinkPreviewCache) Save(ctx context.Context, preview CachedLinkPreview) error {
+	_, err := m.collection.ReplaceOne(ctx, bson.M{"_id": preview.URL}, preview, options.Replace().SetUpsert(true))
+	return err
+}
+
+// LinkPreviewService resolves the previews of the URLs written in todos,
+// fetching each page at most once per ttl.
+type LinkPreviewService struct {
+	fetcher LinkFetcher
+	cache   LinkPreviewCache
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewLinkPreviewService builds a new LinkPreviewService instance.
+func NewLinkPreviewService(fetcher LinkFetcher, cache LinkPreviewCache, ttl time.Duration, now func() time.Time) *LinkPreviewService {
+	if now == nil {
+		now = time.Now
+	}
+	return &LinkPreviewService{fetcher: fetcher, cache: cache, ttl: ttl, now: now}
+}
+
+// Resolve returns the previews of the first URLs in text, in order. Pages
+// that cannot be fetched, or that are not public, get no preview.
+func (s *LinkPreviewService) Resolve(ctx context.Context, text string) []linkpreview.Preview {
+	urls := linkpreview.FindURLs(text, maxLinkPreviews)
+	if len(urls) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, linkPreviewTimeout)
+	defer cancel()
+
+	found := make([]*linkpreview.Preview, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if preview, ok := s.preview(ctx, url); ok {
+				found[i] = &preview
+			}
+		}()
+	}
+	wg.Wait()
+
+	var previews []linkpreview.Preview
+	for _, preview := range found {
+		if preview != nil {
+			previews = append(previews, *preview)
+		}
+	}
+	return previews
+}
+
+func (s *LinkPreviewService) preview(ctx context.Context, url string) (linkpreview.Preview, bool) {
+	cached, err := s.cache.Find(ctx, url)
+	if err == nil && s.now().Sub(cached.FetchedAt) < s.ttl {
+		return cached.Preview, true
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("no se pudo leer la vista previa de %s: %v", url, err)
+	}
+
+	preview, err := s.fetcher.Fetch(ctx, url)
+	if err != nil {
+		log.Printf("no se pudo obtener la vista previa de %s: %v", url, err)
+		return linkpreview.Preview{}, false
+	}
+	if err := s.cache.Save(ctx, CachedLinkPreview{URL: url, Preview: preview, FetchedAt: s.now()}); err != nil {
+		log.Printf("no se pudo guardar la vista previa de %s: %v", url, err)
+	}
+	return preview, true
+}
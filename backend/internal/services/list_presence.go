@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+)
+
+// PresenceTTL is how long a member counts as viewing a list after their
+// last heartbeat; clients send one every 15 seconds or so.
+const PresenceTTL = 45 * time.Second
+
+// Presence message types, sent on the channel of the list.
+const (
+	PresenceJoined = "presence.joined"
+	PresenceLeft   = "presence.left"
+)
+
+// ListPresence is a member that has a shared list open.
+type ListPresence struct {
+	ListID    primitive.ObjectID `bson:"listId"`
+	Email     string             `bson:"email"`
+	Since     time.Time          `bson:"since"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+}
+
+// ListPresenceResponse is the JSON representation of a ListPresence.
+type ListPresenceResponse struct {
+	ListID string    `json:"listId"`
+	Email  string    `json:"email"`
+	Since  time.Time `json:"since"`
+}
+
+// ToResponse converts the presence to its JSON representation.
+func (p ListPresence) ToResponse() ListPresenceResponse {
+	return ListPresenceResponse{ListID: p.ListID.Hex(), Email: p.Email, Since: p.Since.UTC()}
+}
+
+// PresenceRepository keeps who is viewing each list, shared by the
+// replicas.
+type PresenceRepository interface {
+	// Touch keeps email present in the list until expiresAt and reports
+	// whether they just joined it (they were not present at now).
+	Touch(ctx context.Context, listID primitive.ObjectID, email string, now, expiresAt time.Time) (ListPresence, bool, error)
+	// Remove reports whether email was present in the list at now.
+	Remove(ctx context.Context, listID primitive.ObjectID, email string, now time.Time) (bool, error)
+	// Viewers returns who is present in the list at now, oldest first.
+	Viewers(ctx context.Context, listID primitive.ObjectID, now time.Time) ([]ListPresence, error)
+}
+
+// MongoPresenceRepository implements PresenceRepository backed by MongoDB.
+type MongoPresenceRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoPresenceRepository creates a repository over collection.
+func NewMongoPresenceRepository(collection *mongo.Collection) *MongoPresenceRepository {
+	return &MongoPresenceRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique viewer index and a TTL index that lets
+// MongoDB purge the viewers that left without saying so.
+func (m *MongoPresenceRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "listId", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetName("viewer_unique")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Touch implements PresenceRepository. The update keeps since while the
+// viewer has not expired, in one step, so two replicas touching the same
+// viewer agree on when they joined.
+func (m *MongoPresenceRepository) Touch(ctx context.Context, listID primitive.ObjectID, email string, now, expiresAt time.Time) (ListPresence, bool, error) {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"since":     bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$expiresAt", now}}, "$since", now}},
+		"expiresAt": expiresAt,
+	}}}}
+	var before ListPresence
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"listId": listID, "email": email},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return ListPresence{}, false, err
+	}
+	if err == nil && before.ExpiresAt.After(now) {
+		before.ExpiresAt = expiresAt
+		return before, false, nil
+	}
+	return ListPresence{ListID: listID, Email: email, Since: now, ExpiresAt: expiresAt}, true, nil
+}
+
+// Remove implements PresenceRepository.
+func (m *MongoPresenceRepository) Remove(ctx context.Context, listID primitive.ObjectID, email string, now time.Time) (bool, error) {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"listId": listID, "email": email, "expiresAt": bson.M{"$gt": now}})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount > 0, nil
+}
+
+// Viewers implements PresenceRepository.
+func (m *MongoPresenceRepository) Viewers(ctx context.Context, listID primitive.ObjectID, now time.Time) ([]ListPresence, error) {
+	cursor, err := m.collection.Find(ctx,
+		bson.M{"listId": listID, "expiresAt": bson.M{"$gt": now}},
+		options.Find().SetSort(bson.D{{Key: "since", Value: 1}, {Key: "email", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	viewers := []ListPresence{}
+	if err := cursor.All(ctx, &viewers); err != nil {
+		return nil, err
+	}
+	return viewers, nil
+}
+
+// PresenceService tracks which members have a shared list open, from the
+// heartbeats of their realtime connections, and announces who joins and
+// leaves on the channel of the list.
+type PresenceService struct {
+	presence PresenceRepository
+	lists    *ListService
+	hub      *realtime.Hub
+	now      func() time.Time
+}
+
+// NewPresenceService builds a new PresenceService instance.
+func NewPresenceService(presence PresenceRepository, lists *ListService, hub *realtime.Hub, now func() time.Time) *PresenceService {
+	return &PresenceService{presence: presence, lists: lists, hub: hub, now: now}
+}
+
+// Heartbeat keeps email present in the list for PresenceTTL. It returns
+// the errors of ListService.Authorize for users who may not read the list.
+func (s *PresenceService) Heartbeat(ctx context.Context, listID primitive.ObjectID, email string) error {
+	email = NormalizeEmail(email)
+	if _, err := s.lists.Authorize(ctx, listID, email, policy.Read); err != nil {
+		return err
+	}
+	now := s.now()
+	presence, joined, err := s.presence.Touch(ctx, listID, email, now, now.Add(PresenceTTL))
+	if err != nil || !joined {
+		return err
+	}
+	s.announce(ctx, PresenceJoined, presence.ToResponse())
+	return nil
+}
+
+// Leave ends the presence of email in the list, e.g. when they close it
+// or their connection drops.
+func (s *PresenceService) Leave(ctx context.Context, listID primitive.ObjectID, email string) error {
+	email = NormalizeEmail(email)
+	left, err := s.presence.Remove(ctx, listID, email, s.now())
+	if err != nil || !left {
+		return err
+	}
+	s.announce(ctx, PresenceLeft, ListPresenceResponse{ListID: listID.Hex(), Email: email})
+	return nil
+}
+
+// Viewers returns who has the list open, oldest first. The caller checks
+// that the user may read the list.
+func (s *PresenceService) Viewers(ctx context.Context, listID primitive.ObjectID) ([]ListPresenceResponse, error) {
+	viewers, err := s.presence.Viewers(ctx, listID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ListPresenceResponse, 0, len(viewers))
+	for _, viewer := range viewers {
+		out = append(out, viewer.ToResponse())
+	}
+	return out, nil
+}
+
+// announce publishes a presence change; like the other realtime messages
+// it is best effort.
+func (s *PresenceService) announce(ctx context.Context, messageType string, presence ListPresenceResponse) {
+	msg, err := realtime.NewMessage(realtime.ListChannel(presence.ListID), messageType, presence, s.now())
+	if err == nil {
+		err = s.hub.Publish(ctx, msg)
+	}
+	if err != nil {
+		log.Printf("no se pudo avisar la presencia de %s en la lista %s: %v", presence.Email, presence.ListID, err)
+	}
+}
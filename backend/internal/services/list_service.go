@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+)
+
+var (
+	// ErrInvalidList indicates a missing or overly long list name, or a
+	// member with an invalid email or role.
+	ErrInvalidList = errors.New("invalid list")
+	// ErrListForbidden is returned when the role of a member does not
+	// allow the operation.
+	ErrListForbidden = errors.New("list operation forbidden")
+	// ErrLastListAdmin is returned when a change would leave a list
+	// without admins.
+	ErrLastListAdmin = errors.New("last list admin")
+	// ErrListMemberExists is returned when adding someone who already is a
+	// member.
+	ErrListMemberExists = errors.New("list member exists")
+	// ErrListMemberNotFound is returned when changing someone who is not a
+	// member.
+	ErrListMemberNotFound = errors.New("list member not found")
+)
+
+// maxListName caps the length of list names, in characters.
+const maxListName = 100
+
+// ListMember is a user a list is shared with.
+type ListMember struct {
+	Email   string      `bson:"email"`
+	Role    policy.Role `bson:"role"`
+	AddedAt time.Time   `bson:"addedAt"`
+}
+
+// TodoList is a list of todos shared by its members. Whoever creates it is
+// its first admin.
+type TodoList struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Name      string             `bson:"name"`
+	Members   []ListMember       `bson:"members"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// role returns the role of email in the list, if a member.
+func (l TodoList) role(email string) (policy.Role, bool) {
+	for _, member := range l.Members {
+		if member.Email == email {
+			return member.Role, true
+		}
+	}
+	return "", false
+}
+
+// ListMemberResponse is the representation of a member exposed through the
+// API.
+type ListMemberResponse struct {
+	Email   string    `json:"email" xml:"email"`
+	Role    string    `json:"role" xml:"role"`
+	AddedAt time.Time `json:"addedAt" xml:"addedAt"`
+}
+
+// TodoListResponse is the representation exposed through the API. Role is
+// the role of the user asking, empty once they left the list.
+type TodoListResponse struct {
+	ID        string               `json:"id" xml:"id"`
+	Name      string               `json:"name" xml:"name"`
+	Role      string               `json:"role,omitempty" xml:"role,omitempty"`
+	Members   []ListMemberResponse `json:"members" xml:"members>member"`
+	CreatedAt time.Time            `json:"createdAt" xml:"createdAt"`
+}
+
+// ToResponse converts a TodoList into an externally safe representation
+// as seen by email.
+func (l TodoList) ToResponse(email string) TodoListResponse {
+	role, _ := l.role(email)
+	members := make([]ListMemberResponse, 0, len(l.Members))
+	for _, member := range l.Members {
+		members = append(members, ListMemberResponse{Email: member.Email, Role: string(member.Role), AddedAt: member.AddedAt})
+	}
+	return TodoListResponse{ID: l.ID.Hex(), Name: l.Name, Role: string(role), Members: members, CreatedAt: l.CreatedAt}
+}
+
+// ListRepository is the storage contract of the shared lists.
+type ListRepository interface {
+	Create(ctx context.Context, list TodoList) (TodoList, error)
+	// FindByID returns a list or ErrNotFound.
+	FindByID(ctx context.Context, id primitive.ObjectID) (TodoList, error)
+	// ListFor returns the lists email is a member of, oldest first.
+	ListFor(ctx context.Context, email string) ([]TodoList, error)
+	// SetMembers replaces the members of a list.
+	SetMembers(ctx context.Context, id primitive.ObjectID, members []ListMember) (TodoList, error)
+}
+
+// MongoListRepository implements ListRepository backed by MongoDB.
+type MongoListRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoListRepository creates a new repository wrapper around a Mongo
+// collection.
+func NewMongoListRepository(collection *mongo.Collection) *MongoListRepository {
+	return &MongoListRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used to find the lists of a member.
+func (m *MongoListRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "members.email", Value: 1}},
+	})
+	return err
+}
+
+// Create implements ListRepository.
+func (m *MongoListRepository) Create(ctx context.Context, list TodoList) (TodoList, error) {
+	res, err := m.collection.InsertOne(ctx, list)
+	if err != nil {
+		return TodoList{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		list.ID = oid
+	}
+	return list, nil
+}
+
+// FindByID implements ListRepository.
+func (m *MongoListRepository) FindByID(ctx context.Context, id primitive.ObjectID) (TodoList, error) {
+	var list TodoList
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&list)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return TodoList{}, ErrNotFound
+	}
+	return list, err
+}
+
+// ListFor implements ListRepository.
+func (m *MongoListRepository) ListFor(ctx context.Context, email string) ([]TodoList, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"members.email": email},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var lists []TodoList
+	if err := cursor.All(ctx, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// SetMembers implements ListRepository.
+func (m *MongoListRepository) SetMembers(ctx context.Context, id primitive.ObjectID, members []ListMember) (TodoList, error) {
+	var list TodoList
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"members": members}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&list)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return TodoList{}, ErrNotFound
+	}
+	return list, err
+}
+
+// ListService handles the shared todo lists and their members. What each
+// member may do is decided by the policy package; Authorize and
+// AuthorizeTodo are the checks every handler of a list or of its todos
+// goes through.
+type ListService struct {
+	lists         ListRepository
+	todos         TodoRepository
+	outbox        Outbox
+	notifications *NotificationService
+	now           func() time.Time
+	ids           IDGenerator
+}
+
+// NewListService builds a new ListService instance. New members get a
+// share notification through notifications, unless it is nil.
+func NewListService(lists ListRepository, todos TodoRepository, outbox Outbox, notifications *NotificationService, now func() time.Time, ids IDGenerator) *ListService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &ListService{lists: lists, todos: todos, outbox: outbox, notifications: notifications, now: now, ids: ids}
+}
+
+// Create stores a new list with email as its only member and admin.
+func (s *ListService) Create(ctx context.Context, email, name string) (TodoListResponse, error) {
+	email, name = NormalizeEmail(email), NormalizeText(name)
+	if email == "" || name == "" || utf8.RuneCountInString(name) > maxListName {
+		return TodoListResponse{}, ErrInvalidList
+	}
+	now := s.now()
+	list, err := s.lists.Create(ctx, TodoList{
+		ID:        s.ids.NewID(),
+		Name:      name,
+		Members:   []ListMember{{Email: email, Role: policy.Admin, AddedAt: now}},
+		CreatedAt: now,
+	})
+	if err != nil {
+		return TodoListResponse{}, err
+	}
+	return list.ToResponse(email), nil
+}
+
+// ListFor returns the lists shared with email.
+func (s *ListService) ListFor(ctx context.Context, email string) ([]TodoListResponse, error) {
+	email = NormalizeEmail(email)
+	lists, err := s.lists.ListFor(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TodoListResponse, 0, len(lists))
+	for _, list := range lists {
+		out = append(out, list.ToResponse(email))
+	}
+	return out, nil
+}
+
+// Get returns a list to one of its members.
+func (s *ListService) Get(ctx context.Context, id primitive.ObjectID, email string) (TodoListResponse, error) {
+	list, err := s.Authorize(ctx, id, email, policy.Read)
+	if err != nil {
+		return TodoListResponse{}, err
+	}
+	return list.ToResponse(NormalizeEmail(email)), nil
+}
+
+// Authorize returns the list if email may perform action on it. Lists
+// email is not a member of are reported as ErrNotFound, so their existence
+// is not disclosed; members whose role does not allow action get
+// ErrListForbidden.
+func (s *ListService) Authorize(ctx context.Context, id primitive.ObjectID, email string, action policy.Action) (TodoList, error) {
+	list, err := s.lists.FindByID(ctx, id)
+	if err != nil {
+		return TodoList{}, err
+	}
+	role, member := list.role(NormalizeEmail(email))
+	if !member {
+		return TodoList{}, ErrNotFound
+	}
+	if !policy.Allows(role, action) {
+		return TodoList{}, ErrListForbidden
+	}
+	return list, nil
+}
+
+// AuthorizeTodo checks that email may perform action on a todo, live or in
+// the trash, when it belongs to a list. Todos outside lists are left to
+// the rules of each operation. An anonymous caller (empty email) of a list
+// todo gets ErrListForbidden.
+func (s *ListService) AuthorizeTodo(ctx context.Context, todoID primitive.ObjectID, email string, action policy.Action) error {
+	todo, err := s.todos.FindByID(ctx, todoID)
+	if errors.Is(err, ErrNotFound) {
+		// TodoQuery takes the zero ID for no ID at all; no todo has it.
+		if todoID.IsZero() {
+			return nil
+		}
+		trashed, err := s.todos.List(ctx, TodoQuery{ID: todoID, Trashed: true})
+		if err != nil || len(trashed) == 0 {
+			return err
+		}
+		todo = trashed[0]
+	} else if err != nil {
+		return err
+	}
+	if todo.ListID == nil {
+		return nil
+	}
+	if NormalizeEmail(email) == "" {
+		return ErrListForbidden
+	}
+	_, err = s.Authorize(ctx, *todo.ListID, email, action)
+	return err
+}
+
+// AddMember shares a list with member as role; actor needs to be an admin
+// of the list. The new member gets a share notification.
+func (s *ListService) AddMember(ctx context.Context, id primitive.ObjectID, actor, member string, role policy.Role) (TodoListResponse, error) {
+	actor, member = NormalizeEmail(actor), NormalizeEmail(member)
+	if !strings.Contains(member, "@") || !policy.Valid(role) {
+		return TodoListResponse{}, ErrInvalidList
+	}
+	list, err := s.changeMembers(ctx, id, actor, policy.Manage, func(members []ListMember) ([]ListMember, error) {
+		if slices.ContainsFunc(members, func(m ListMember) bool { return m.Email == member }) {
+			return nil, ErrListMemberExists
+		}
+		return append(members, ListMember{Email: member, Role: role, AddedAt: s.now()}), nil
+	})
+	if err != nil {
+		return TodoListResponse{}, err
+	}
+
+	if s.notifications != nil {
+		err := s.notifications.Notify(ctx, Notification{Email: member, Kind: NotificationShare, ListID: list.ID, Author: actor})
+		if err != nil {
+			log.Printf("no se pudo notificar a %s que se compartio la lista %s: %v", member, list.ID.Hex(), err)
+		}
+	}
+	return list.ToResponse(actor), nil
+}
+
+// SetRole changes the role of a member; actor needs to be an admin of the
+// list, and the list keeps at least one admin.
+func (s *ListService) SetRole(ctx context.Context, id primitive.ObjectID, actor, member string, role policy.Role) (TodoListResponse, error) {
+	actor, member = NormalizeEmail(actor), NormalizeEmail(member)
+	if !policy.Valid(role) {
+		return TodoListResponse{}, ErrInvalidList
+	}
+	list, err := s.changeMembers(ctx, id, actor, policy.Manage, func(members []ListMember) ([]ListMember, error) {
+		i := slices.IndexFunc(members, func(m ListMember) bool { return m.Email == member })
+		if i < 0 {
+			return nil, ErrListMemberNotFound
+		}
+		members[i].Role = role
+		return members, nil
+	})
+	if err != nil {
+		return TodoListResponse{}, err
+	}
+	return list.ToResponse(actor), nil
+}
+
+// RemoveMember takes member out of a list. Admins may remove anyone and
+// every member may leave; the list keeps at least one admin.
+func (s *ListService) RemoveMember(ctx context.Context, id primitive.ObjectID, actor, member string) (TodoListResponse, error) {
+	actor, member = NormalizeEmail(actor), NormalizeEmail(member)
+	action := policy.Manage
+	if actor == member {
+		action = policy.Read
+	}
+	list, err := s.changeMembers(ctx, id, actor, action, func(members []ListMember) ([]ListMember, error) {
+		i := slices.IndexFunc(members, func(m ListMember) bool { return m.Email == member })
+		if i < 0 {
+			return nil, ErrListMemberNotFound
+		}
+		return slices.Delete(members, i, i+1), nil
+	})
+	if err != nil {
+		return TodoListResponse{}, err
+	}
+	return list.ToResponse(actor), nil
+}
+
+// changeMembers authorizes actor and stores the members returned by change
+// in one transaction, so two admins changing the list at once cannot leave
+// it without admins.
+func (s *ListService) changeMembers(ctx context.Context, id primitive.ObjectID, actor string, action policy.Action, change func([]ListMember) ([]ListMember, error)) (TodoList, error) {
+	var list TodoList
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		current, err := s.Authorize(ctx, id, actor, action)
+		if err != nil {
+			return nil, err
+		}
+		members, err := change(slices.Clone(current.Members))
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(members, func(m ListMember) bool { return m.Role == policy.Admin }) {
+			return nil, ErrLastListAdmin
+		}
+		list, err = s.lists.SetMembers(ctx, id, members)
+		return nil, err
+	})
+	return list, err
+}
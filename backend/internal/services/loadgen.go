@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Todo distributions of the load generator.
+const (
+	// DistributionUniform gives every user about the same number of todos.
+	DistributionUniform = "uniform"
+	// DistributionZipf gives a few users most of the todos and the rest a
+	// long tail of a handful each, like real accounts.
+	DistributionZipf = "zipf"
+)
+
+// ErrInvalidLoadGen indicates an invalid load generator configuration.
+var ErrInvalidLoadGen = errors.New("invalid load generator config")
+
+// LoadGenConfig describes the data written by a LoadGenerator.
+type LoadGenConfig struct {
+	Users        int
+	Todos        int
+	Distribution string
+	// Completed, Recurring and Trashed are the fractions of the todos that
+	// are completed, repeat or sit in the trash.
+	Completed float64
+	Recurring float64
+	Trashed   float64
+	// Days spreads the sign-ups and the todos over the days before now.
+	Days int
+	// Domain is the email domain of the generated users, so they are easy to
+	// tell apart from real ones.
+	Domain string
+	// Seed makes two runs with the same configuration write the same data.
+	Seed uint64
+	// Workers is how many documents are written at once.
+	Workers int
+}
+
+// LoadGenStats counts the documents written by a LoadGenerator.
+type LoadGenStats struct {
+	Users int
+	Todos int
+}
+
+var loadGenTitles = []string{
+	"Comprar pan", "Pagar la luz", "Llamar al proveedor", "Revisar reservas de la semana",
+	"Preparar informe mensual", "Renovar el seguro", "Limpiar la heladera", "Responder emails",
+	"Actualizar tarifas", "Coordinar el mantenimiento", "Pedir toallas", "Cargar facturas",
+	"Agendar turno con el medico", "Reponer amenities", "Controlar el inventario",
+}
+
+// LoadGenerator fills the user and todo repositories with synthetic,
+// production-like data, to benchmark index and pagination changes.
+type LoadGenerator struct {
+	users UserRepository
+	todos TodoRepository
+	now   func() time.Time
+}
+
+// NewLoadGenerator builds a new LoadGenerator instance.
+func NewLoadGenerator(users UserRepository, todos TodoRepository, now func() time.Time) *LoadGenerator {
+	if now == nil {
+		now = time.Now
+	}
+	return &LoadGenerator{users: users, todos: todos, now: now}
+}
+
+func (c *LoadGenConfig) validate() error {
+	if c.Distribution == "" {
+		c.Distribution = DistributionZipf
+	}
+	if c.Workers <= 0 {
+		c.Workers = 8
+	}
+	switch {
+	case c.Users <= 0 || c.Todos < 0 || c.Days <= 0 || c.Domain == "":
+		return ErrInvalidLoadGen
+	case c.Distribution != DistributionUniform && c.Distribution != DistributionZipf:
+		return ErrInvalidLoadGen
+	}
+	for _, fraction := range []float64{c.Completed, c.Recurring, c.Trashed} {
+		if fraction < 0 || fraction > 1 {
+			return ErrInvalidLoadGen
+		}
+	}
+	return nil
+}
+
+// Run writes cfg.Users users and cfg.Todos todos. The documents are drawn
+// from a single random source seeded with cfg.Seed and written by
+// cfg.Workers goroutines; on error the documents already written stay.
+func (g *LoadGenerator) Run(ctx context.Context, cfg LoadGenConfig) (LoadGenStats, error) {
+	if err := cfg.validate(); err != nil {
+		return LoadGenStats{}, err
+	}
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15))
+	now := g.now()
+	start := now.AddDate(0, 0, -cfg.Days)
+	between := func(from, to time.Time) time.Time {
+		if !to.After(from) {
+			return from
+		}
+		return from.Add(time.Duration(rng.Int64N(int64(to.Sub(from)))))
+	}
+
+	users := make([]User, cfg.Users)
+	for i := range users {
+		created := between(start, now)
+		users[i] = User{
+			ID:        loadGenID(rng, created),
+			Email:     fmt.Sprintf("user%06d@%s", i, cfg.Domain),
+			Password:  "loadgen",
+			CreatedAt: created,
+		}
+	}
+	var stats LoadGenStats
+	err := g.write(ctx, cfg.Workers, len(users), func(i int) error {
+		return g.users.Insert(ctx, users[i])
+	}, func(n int) {
+		stats.Users = n
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	owner := func() int { return rng.IntN(len(users)) }
+	if cfg.Distribution == DistributionZipf {
+		zipf := rand.NewZipf(rng, 1.1, 1, uint64(len(users)-1))
+		owner = func() int { return int(zipf.Uint64()) }
+	}
+	recurrences := []string{RecurDaily, RecurWeekly, RecurMonthly}
+	newTodo := func() Todo {
+		user := users[owner()]
+		created := between(user.CreatedAt, now)
+		todo := Todo{
+			ID:        loadGenID(rng, created),
+			UserID:    &user.ID,
+			Email:     user.Email,
+			Title:     loadGenTitles[rng.IntN(len(loadGenTitles))],
+			CreatedAt: created,
+		}
+		if rng.Float64() < cfg.Completed {
+			completed := between(created, now)
+			todo.Completed, todo.CompletedAt = true, &completed
+		}
+		if rng.Float64() < cfg.Recurring {
+			todo.Recurrence = recurrences[rng.IntN(len(recurrences))]
+			next := nextOccurrence(todo.Recurrence, created)
+			for !next.After(now) {
+				next = nextOccurrence(todo.Recurrence, next)
+			}
+			todo.NextOccurrence = &next
+		}
+		if rng.Float64() < cfg.Trashed {
+			deleted := between(created, now)
+			todo.DeletedAt = &deleted
+		}
+		return todo
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	todos := make(chan Todo, cfg.Workers)
+	go func() {
+		defer close(todos)
+		for range cfg.Todos {
+			select {
+			case todos <- newTodo():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	err = g.write(ctx, cfg.Workers, cfg.Todos, func(int) error {
+		todo, ok := <-todos
+		if !ok {
+			return ctx.Err()
+		}
+		_, err := g.todos.Create(ctx, todo)
+		return err
+	}, func(n int) {
+		stats.Todos = n
+	})
+	return stats, err
+}
+
+// write calls insert for 0..total-1 from workers goroutines, stopping at
+// the first error, and reports the number of successful inserts to done.
+// It logs the progress every tenth of the total.
+func (g *LoadGenerator) write(ctx context.Context, workers, total int, insert func(i int) error, done func(n int)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		next     int
+		written  int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	step := max(total/10, 1)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				next++
+				mu.Unlock()
+				if i >= total || ctx.Err() != nil {
+					return
+				}
+				if err := insert(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					cancel()
+					return
+				}
+				mu.Lock()
+				written++
+				if written%step == 0 {
+					log.Printf("loadgen: %d de %d documentos escritos", written, total)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	done(written)
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// loadGenID returns an ObjectID stamped with at, as if the document had
+// been created then, so sorting by _id matches sorting by creation date.
+func loadGenID(rng *rand.Rand, at time.Time) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[:4], uint32(at.Unix()))
+	binary.BigEndian.PutUint64(id[4:], rng.Uint64())
+	return id
+}
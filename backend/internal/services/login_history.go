@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginHistoryLimit is the number of recent logins shown to the user.
+const LoginHistoryLimit = 50
+
+// Client describes where a login comes from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// LoginRecord is one successful login of an account. Unlike its session it
+// is kept after the session expires or is revoked.
+type LoginRecord struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Email     string             `bson:"email"`
+	SessionID primitive.ObjectID `bson:"sessionId"`
+	IP        string             `bson:"ip"`
+	UserAgent string             `bson:"userAgent"`
+	At        time.Time          `bson:"at"`
+}
+
+// LoginResponse is the representation exposed through the API.
+type LoginResponse struct {
+	SessionID string    `json:"sessionId" xml:"sessionId"`
+	IP        string    `json:"ip" xml:"ip"`
+	UserAgent string    `json:"userAgent" xml:"userAgent"`
+	At        time.Time `json:"at" xml:"at"`
+}
+
+// ToResponse converts a LoginRecord into an externally safe representation.
+func (l LoginRecord) ToResponse() LoginResponse {
+	return LoginResponse{SessionID: l.SessionID.Hex(), IP: l.IP, UserAgent: l.UserAgent, At: l.At}
+}
+
+// LoginRepository is the storage contract of the login history.
+type LoginRepository interface {
+	Record(ctx context.Context, login LoginRecord) error
+	// List returns the logins of email, newest first; limit zero means all.
+	List(ctx context.Context, email string, limit int) ([]LoginRecord, error)
+	// Delete removes the history of email and returns how many logins it
+	// held.
+	Delete(ctx context.Context, email string) (int64, error)
+}
+
+// MongoLoginRepository implements LoginRepository backed by MongoDB.
+type MongoLoginRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoLoginRepository creates a repository over collection.
+func NewMongoLoginRepository(collection *mongo.Collection) *MongoLoginRepository {
+	return &MongoLoginRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used to list the logins of an account.
+func (m *MongoLoginRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "email", Value: 1}, {Key: "at", Value: -1}},
+	})
+	return err
+}
+
+// Record implements LoginRepository.
+func (m *MongoLoginRepository) Record(ctx context.Context, login LoginRecord) error {
+	_, err := m.collection.InsertOne(ctx, login)
+	return err
+}
+
+// List implements LoginRepository.
+func (m *MongoLoginRepository) List(ctx context.Context, email string, limit int) ([]LoginRecord, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := m.collection.Find(ctx, bson.M{"email": email}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var logins []LoginRecord
+	if err := cursor.All(ctx, &logins); err != nil {
+		return nil, err
+	}
+	return logins, nil
+}
+
+// Delete implements LoginRepository.
+func (m *MongoLoginRepository) Delete(ctx context.Context, email string) (int64, error) {
+	res, err := m.collection.DeleteMany(ctx, bson.M{"email": email})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
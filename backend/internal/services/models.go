@@ -4,49 +4,573 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/linkpreview"
 )
 
 // User represents a registered user in the system.
 type User struct {
-	Email    string `json:"email" bson:"email"`
-	Password string `json:"password,omitempty" bson:"password"`
+	// ID is what the todos of the user refer to (see Todo.UserID), so they
+	// stay with it when its email changes.
+	ID       primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Email    string             `json:"email" bson:"email"`
+	Password string             `json:"password,omitempty" bson:"password"`
+	// Role is the staff role of the user; empty for regular accounts.
+	Role string `json:"role,omitempty" bson:"role,omitempty"`
+	// PropertyID binds staff to one hotel; staff without it work for all.
+	PropertyID *primitive.ObjectID `json:"propertyId,omitempty" bson:"propertyId,omitempty"`
+	// CreatedAt is when the user registered; zero for older accounts.
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt,omitempty"`
+	// Quota overrides the plan limits of the account when set.
+	Quota *QuotaOverride `json:"quota,omitempty" bson:"quota,omitempty"`
+	// SuspendedAt is set while an administrator keeps the account from
+	// signing in.
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty" bson:"suspendedAt,omitempty"`
+	// FeedToken reads the Atom feed of the user's todos.
+	FeedToken *UserFeedToken `json:"-" bson:"feedToken,omitempty"`
+	// EmailChange is the change of email waiting for confirmation, if any.
+	EmailChange *PendingEmailChange `json:"-" bson:"emailChange,omitempty"`
+	// AnonymizedAt marks the tombstone left by an anonymized account: its
+	// email is an ErasureAlias and it has no password.
+	AnonymizedAt *time.Time `json:"anonymizedAt,omitempty" bson:"anonymizedAt,omitempty"`
 }
 
 // PublicUser hides sensitive user data when returning it through the API.
 type PublicUser struct {
-	Email string `json:"email"`
+	Email       string     `json:"email" xml:"email"`
+	Role        string     `json:"role,omitempty" xml:"role,omitempty"`
+	PropertyID  string     `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	SuspendedAt *time.Time `json:"suspendedAt,omitempty" xml:"suspendedAt,omitempty"`
 }
 
 // ToPublic converts the User into a PublicUser without exposing the password.
 func (u User) ToPublic() PublicUser {
-	return PublicUser{Email: u.Email}
+	public := PublicUser{Email: u.Email, Role: u.Role, SuspendedAt: u.SuspendedAt}
+	if u.PropertyID != nil {
+		public.PropertyID = u.PropertyID.Hex()
+	}
+	return public
 }
 
-// Todo models a task stored in MongoDB.
-type Todo struct {
+// Property models a hotel of the chain stored in MongoDB.
+type Property struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Email     string             `json:"email" bson:"email"`
-	Title     string             `json:"title" bson:"title"`
-	Completed bool               `json:"completed" bson:"completed"`
+	Code      string             `json:"code" bson:"code"`
+	Name      string             `json:"name" bson:"name"`
+	City      string             `json:"city" bson:"city"`
 	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	// SSO is the identity provider the staff of the property signs in
+	// with, if any.
+	SSO *PropertySSO `json:"-" bson:"sso,omitempty"`
+	// SCIM holds the token the identity provider provisions the staff
+	// with.
+	SCIM *PropertySCIM `json:"-" bson:"scim,omitempty"`
+}
+
+// PropertyResponse is the representation exposed through the API.
+type PropertyResponse struct {
+	ID        string    `json:"id" xml:"id"`
+	Code      string    `json:"code" xml:"code"`
+	Name      string    `json:"name" xml:"name"`
+	City      string    `json:"city" xml:"city"`
+	CreatedAt time.Time `json:"createdAt" xml:"createdAt"`
+	// SSO tells the login page to offer the property's identity provider.
+	SSO bool `json:"sso" xml:"sso"`
+}
+
+// ToResponse converts a Property into an externally safe representation.
+func (p Property) ToResponse() PropertyResponse {
+	return PropertyResponse{ID: p.ID.Hex(), Code: p.Code, Name: p.Name, City: p.City, CreatedAt: p.CreatedAt, SSO: p.SSO != nil}
+}
+
+// Session is a login session. Only the SHA-256 hash of its bearer token is
+// stored, so a leaked collection does not expose usable tokens.
+type Session struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"tokenHash"`
+	Email     string             `bson:"email"`
+	// IP and UserAgent describe the client that opened the session.
+	IP        string    `bson:"ip,omitempty"`
+	UserAgent string    `bson:"userAgent,omitempty"`
+	CreatedAt time.Time `bson:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+	// ImpersonatedBy names the support operator of an impersonation
+	// session; empty for regular logins.
+	ImpersonatedBy string `bson:"impersonatedBy,omitempty"`
+}
+
+// SessionResponse is the representation exposed through the API. Current
+// flags the session of the request.
+type SessionResponse struct {
+	ID             string    `json:"id" xml:"id"`
+	IP             string    `json:"ip" xml:"ip"`
+	UserAgent      string    `json:"userAgent" xml:"userAgent"`
+	CreatedAt      time.Time `json:"createdAt" xml:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt" xml:"expiresAt"`
+	Current        bool      `json:"current" xml:"current"`
+	ImpersonatedBy string    `json:"impersonatedBy,omitempty" xml:"impersonatedBy,omitempty"`
+}
+
+// ToResponse converts a Session into an externally safe representation.
+func (s Session) ToResponse() SessionResponse {
+	return SessionResponse{
+		ID:             s.ID.Hex(),
+		IP:             s.IP,
+		UserAgent:      s.UserAgent,
+		CreatedAt:      s.CreatedAt,
+		ExpiresAt:      s.ExpiresAt,
+		ImpersonatedBy: s.ImpersonatedBy,
+	}
+}
+
+// Todo models a task stored in MongoDB.
+type Todo struct {
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// UserID is the account that owns the todo; Email is a copy of its
+	// email for display. UserID is nil while the owner has no account, as
+	// todos can be created for any email.
+	UserID *primitive.ObjectID `json:"-" bson:"userId,omitempty"`
+	Email  string              `json:"email" bson:"email"`
+	// ClientID is the UUID the client that created the todo named it with,
+	// so it can refer to the todo before it knows its ID, e.g. offline. It
+	// is unique among the todos of the owner.
+	ClientID  string    `json:"clientId,omitempty" bson:"clientId,omitempty"`
+	Title     string    `json:"title" bson:"title"`
+	Completed bool      `json:"completed" bson:"completed"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	// TitleVersion counts the changes of the title, which collaborative
+	// edits are written against.
+	TitleVersion int `json:"-" bson:"titleVersion,omitempty"`
+	// CompletedAt is when the todo was last marked as completed.
+	CompletedAt *time.Time `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	// RoomID links housekeeping todos to the room they refer to.
+	RoomID *primitive.ObjectID `json:"roomId,omitempty" bson:"roomId,omitempty"`
+	// PropertyID is the hotel of RoomID.
+	PropertyID *primitive.ObjectID `json:"propertyId,omitempty" bson:"propertyId,omitempty"`
+	// ListID is the shared list the todo belongs to, if any; its members
+	// reach it according to their role.
+	ListID *primitive.ObjectID `json:"listId,omitempty" bson:"listId,omitempty"`
+	// Recurrence repeats the todo (daily, weekly or monthly): at
+	// NextOccurrence a fresh copy is created, which carries the recurrence
+	// on.
+	Recurrence     string     `json:"recurrence,omitempty" bson:"recurrence,omitempty"`
+	NextOccurrence *time.Time `json:"nextOccurrence,omitempty" bson:"nextOccurrence,omitempty"`
+	// DueAt is when the todo is due; only todos imported from other apps
+	// have one so far.
+	DueAt *time.Time `json:"dueAt,omitempty" bson:"dueAt,omitempty"`
+	// Color and Icon group todos visually; they come from TodoColors and
+	// TodoIcons.
+	Color string `json:"color,omitempty" bson:"color,omitempty"`
+	Icon  string `json:"icon,omitempty" bson:"icon,omitempty"`
+	// Reactions are the emoji reactions of the users; only their counts are
+	// exposed.
+	Reactions []TodoReaction `json:"-" bson:"reactions,omitempty"`
+	// Assignee is who does the todo on behalf of its owner, when delegated;
+	// Approval tracks the completion they asked the owner to approve.
+	Assignee string `json:"assignee,omitempty" bson:"assignee,omitempty"`
+	Approval string `json:"approval,omitempty" bson:"approval,omitempty"`
+	// Previews describe the pages linked from the title.
+	Previews []linkpreview.Preview `json:"previews,omitempty" bson:"previews,omitempty"`
+	// CalDAV keeps the resource name and UID of the todos created by CalDAV
+	// clients, which find them again by those.
+	CalDAV *TodoCalDAV `json:"-" bson:"caldav,omitempty"`
+	// DeletedAt is set while the todo is in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
 
 // TodoResponse is the representation exposed through the API.
 type TodoResponse struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Title     string    `json:"title"`
-	Completed bool      `json:"completed"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID         string    `json:"id" xml:"id"`
+	Email      string    `json:"email" xml:"email"`
+	ClientID   string    `json:"clientId,omitempty" xml:"clientId,omitempty"`
+	Title      string    `json:"title" xml:"title"`
+	Completed  bool      `json:"completed" xml:"completed"`
+	CreatedAt  time.Time `json:"createdAt" xml:"createdAt"`
+	RoomID     string    `json:"roomId,omitempty" xml:"roomId,omitempty"`
+	PropertyID string    `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	ListID     string    `json:"listId,omitempty" xml:"listId,omitempty"`
+	Recurrence string    `json:"recurrence,omitempty" xml:"recurrence,omitempty"`
+	Color      string    `json:"color,omitempty" xml:"color,omitempty"`
+	Icon       string    `json:"icon,omitempty" xml:"icon,omitempty"`
+	// Reactions counts the reactions per emoji.
+	Reactions []ReactionCount `json:"reactions,omitempty" xml:"reactions>reaction,omitempty"`
+	Assignee  string          `json:"assignee,omitempty" xml:"assignee,omitempty"`
+	Approval  string          `json:"approval,omitempty" xml:"approval,omitempty"`
+	// Previews describe the pages linked from the title.
+	Previews []linkpreview.Preview `json:"previews,omitempty" xml:"previews>preview,omitempty"`
+	// CompletedAt, NextOccurrence, DueAt and DeletedAt are pointers so they
+	// are omitted when unset.
+	CompletedAt    *time.Time `json:"completedAt,omitempty" xml:"completedAt,omitempty"`
+	NextOccurrence *time.Time `json:"nextOccurrence,omitempty" xml:"nextOccurrence,omitempty"`
+	DueAt          *time.Time `json:"dueAt,omitempty" xml:"dueAt,omitempty"`
+	DeletedAt      *time.Time `json:"deletedAt,omitempty" xml:"deletedAt,omitempty"`
 }
 
 // ToResponse converts a Todo into an externally safe representation.
 func (t Todo) ToResponse() TodoResponse {
-	return TodoResponse{
-		ID:        t.ID.Hex(),
-		Email:     t.Email,
-		Title:     t.Title,
-		Completed: t.Completed,
-		CreatedAt: t.CreatedAt,
+	response := TodoResponse{
+		ID:             t.ID.Hex(),
+		Email:          t.Email,
+		ClientID:       t.ClientID,
+		Title:          t.Title,
+		Completed:      t.Completed,
+		CreatedAt:      t.CreatedAt,
+		CompletedAt:    t.CompletedAt,
+		Recurrence:     t.Recurrence,
+		Color:          t.Color,
+		Icon:           t.Icon,
+		Reactions:      countReactions(t.Reactions),
+		Assignee:       t.Assignee,
+		Approval:       t.Approval,
+		Previews:       t.Previews,
+		NextOccurrence: t.NextOccurrence,
+		DueAt:          t.DueAt,
+		DeletedAt:      t.DeletedAt,
+	}
+	if t.RoomID != nil {
+		response.RoomID = t.RoomID.Hex()
+	}
+	if t.PropertyID != nil {
+		response.PropertyID = t.PropertyID.Hex()
+	}
+	if t.ListID != nil {
+		response.ListID = t.ListID.Hex()
+	}
+	return response
+}
+
+// Room models a hotel room stored in MongoDB.
+type Room struct {
+	ID primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	// PropertyID is the hotel of the room; rooms created before the chain
+	// had several hotels have none.
+	PropertyID *primitive.ObjectID `json:"propertyId,omitempty" bson:"propertyId,omitempty"`
+	Number     string              `json:"number" bson:"number"`
+	Type       string              `json:"type" bson:"type"`
+	Capacity   int                 `json:"capacity" bson:"capacity"`
+	Price      float64             `json:"price" bson:"price"`
+	Amenities  []string            `json:"amenities" bson:"amenities"`
+	Status     string              `json:"status" bson:"status"`
+	CreatedAt  time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// RoomResponse is the representation exposed through the API.
+type RoomResponse struct {
+	ID         string   `json:"id" xml:"id"`
+	PropertyID string   `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	Number     string   `json:"number" xml:"number"`
+	Type       string   `json:"type" xml:"type"`
+	Capacity   int      `json:"capacity" xml:"capacity"`
+	Price      float64  `json:"price" xml:"price"`
+	Amenities  []string `json:"amenities" xml:"amenities>amenity"`
+	Status     string   `json:"status" xml:"status"`
+	// Rating summarizes the guest reviews; it is only set by RoomService.
+	Rating    *RoomRating `json:"rating,omitempty" xml:"rating,omitempty"`
+	CreatedAt time.Time   `json:"createdAt" xml:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt" xml:"updatedAt"`
+}
+
+// ToResponse converts a Room into an externally safe representation.
+func (r Room) ToResponse() RoomResponse {
+	amenities := r.Amenities
+	if amenities == nil {
+		amenities = []string{}
+	}
+	response := RoomResponse{
+		ID:        r.ID.Hex(),
+		Number:    r.Number,
+		Type:      r.Type,
+		Capacity:  r.Capacity,
+		Price:     r.Price,
+		Amenities: amenities,
+		Status:    r.Status,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+	if r.PropertyID != nil {
+		response.PropertyID = r.PropertyID.Hex()
+	}
+	return response
+}
+
+// Booking models a room reservation for the nights between CheckIn and
+// CheckOut (both stored as UTC midnights; CheckOut is exclusive).
+type Booking struct {
+	ID           primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	RoomID       primitive.ObjectID  `json:"roomId" bson:"roomId"`
+	PropertyID   *primitive.ObjectID `json:"propertyId,omitempty" bson:"propertyId,omitempty"`
+	GuestID      *primitive.ObjectID `json:"guestId,omitempty" bson:"guestId,omitempty"`
+	Email        string              `json:"email" bson:"email"`
+	Guests       int                 `json:"guests" bson:"guests"`
+	CheckIn      time.Time           `json:"checkIn" bson:"checkIn"`
+	CheckOut     time.Time           `json:"checkOut" bson:"checkOut"`
+	Status       string              `json:"status" bson:"status"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time           `json:"updatedAt" bson:"updatedAt"`
+	CancelledAt  *time.Time          `json:"cancelledAt,omitempty" bson:"cancelledAt,omitempty"`
+	CheckedInAt  *time.Time          `json:"checkedInAt,omitempty" bson:"checkedInAt,omitempty"`
+	CheckedOutAt *time.Time          `json:"checkedOutAt,omitempty" bson:"checkedOutAt,omitempty"`
+	// HeldUntil is when a held booking offered to the waitlist expires.
+	HeldUntil *time.Time `json:"heldUntil,omitempty" bson:"heldUntil,omitempty"`
+	Quote     *Quote     `json:"quote,omitempty" bson:"quote,omitempty"`
+	// Channel and ExternalRef identify bookings imported from an OTA.
+	Channel     string `json:"channel,omitempty" bson:"channel,omitempty"`
+	ExternalRef string `json:"externalRef,omitempty" bson:"externalRef,omitempty"`
+}
+
+// BookingResponse is the representation exposed through the API.
+type BookingResponse struct {
+	ID           string     `json:"id" xml:"id"`
+	RoomID       string     `json:"roomId" xml:"roomId"`
+	PropertyID   string     `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	GuestID      string     `json:"guestId,omitempty" xml:"guestId,omitempty"`
+	Email        string     `json:"email" xml:"email"`
+	Guests       int        `json:"guests" xml:"guests"`
+	CheckIn      string     `json:"checkIn" xml:"checkIn"`
+	CheckOut     string     `json:"checkOut" xml:"checkOut"`
+	Nights       int        `json:"nights" xml:"nights"`
+	Status       string     `json:"status" xml:"status"`
+	CreatedAt    time.Time  `json:"createdAt" xml:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt" xml:"updatedAt"`
+	CancelledAt  *time.Time `json:"cancelledAt,omitempty" xml:"cancelledAt,omitempty"`
+	CheckedInAt  *time.Time `json:"checkedInAt,omitempty" xml:"checkedInAt,omitempty"`
+	CheckedOutAt *time.Time `json:"checkedOutAt,omitempty" xml:"checkedOutAt,omitempty"`
+	HeldUntil    *time.Time `json:"heldUntil,omitempty" xml:"heldUntil,omitempty"`
+	Quote        *Quote     `json:"quote,omitempty" xml:"quote,omitempty"`
+	Channel      string     `json:"channel,omitempty" xml:"channel,omitempty"`
+	ExternalRef  string     `json:"externalRef,omitempty" xml:"externalRef,omitempty"`
+}
+
+// ToResponse converts a Booking into an externally safe representation.
+func (b Booking) ToResponse() BookingResponse {
+	response := BookingResponse{
+		ID:           b.ID.Hex(),
+		RoomID:       b.RoomID.Hex(),
+		Email:        b.Email,
+		Guests:       b.Guests,
+		CheckIn:      b.CheckIn.Format(DateLayout),
+		CheckOut:     b.CheckOut.Format(DateLayout),
+		Nights:       b.Nights(),
+		Status:       b.Status,
+		CreatedAt:    b.CreatedAt,
+		UpdatedAt:    b.UpdatedAt,
+		CancelledAt:  b.CancelledAt,
+		CheckedInAt:  b.CheckedInAt,
+		CheckedOutAt: b.CheckedOutAt,
+		HeldUntil:    b.HeldUntil,
+		Quote:        b.Quote,
+		Channel:      b.Channel,
+		ExternalRef:  b.ExternalRef,
+	}
+	if b.GuestID != nil {
+		response.GuestID = b.GuestID.Hex()
+	}
+	if b.PropertyID != nil {
+		response.PropertyID = b.PropertyID.Hex()
+	}
+	return response
+}
+
+// Nights returns the length of the stay.
+func (b Booking) Nights() int {
+	return int(b.CheckOut.Sub(b.CheckIn).Hours() / 24)
+}
+
+// Guest models a hotel guest profile stored in MongoDB.
+type Guest struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Document    string             `json:"document" bson:"document"`
+	Email       string             `json:"email" bson:"email"`
+	Phone       string             `json:"phone" bson:"phone"`
+	Preferences []string           `json:"preferences" bson:"preferences"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// GuestResponse is the representation exposed through the API.
+type GuestResponse struct {
+	ID          string    `json:"id" xml:"id"`
+	Name        string    `json:"name" xml:"name"`
+	Document    string    `json:"document" xml:"document"`
+	Email       string    `json:"email" xml:"email"`
+	Phone       string    `json:"phone" xml:"phone"`
+	Preferences []string  `json:"preferences" xml:"preferences>preference"`
+	CreatedAt   time.Time `json:"createdAt" xml:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" xml:"updatedAt"`
+}
+
+// ToResponse converts a Guest into an externally safe representation.
+func (g Guest) ToResponse() GuestResponse {
+	preferences := g.Preferences
+	if preferences == nil {
+		preferences = []string{}
+	}
+	return GuestResponse{
+		ID:          g.ID.Hex(),
+		Name:        g.Name,
+		Document:    g.Document,
+		Email:       g.Email,
+		Phone:       g.Phone,
+		Preferences: preferences,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+// StayDiscount applies Percent off the stay total from MinNights nights on.
+type StayDiscount struct {
+	MinNights int     `json:"minNights" bson:"minNights" xml:"minNights"`
+	Percent   float64 `json:"percent" bson:"percent" xml:"percent"`
+}
+
+// RatePlan overrides the room base price for a room type and, optionally, a
+// season. WeekendPrice applies to Friday and Saturday nights when set.
+type RatePlan struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name         string             `json:"name" bson:"name"`
+	RoomType     string             `json:"roomType" bson:"roomType"`
+	StartDate    *time.Time         `json:"startDate,omitempty" bson:"startDate,omitempty"`
+	EndDate      *time.Time         `json:"endDate,omitempty" bson:"endDate,omitempty"`
+	Price        float64            `json:"price" bson:"price"`
+	WeekendPrice *float64           `json:"weekendPrice,omitempty" bson:"weekendPrice,omitempty"`
+	Discounts    []StayDiscount     `json:"discounts" bson:"discounts"`
+	Priority     int                `json:"priority" bson:"priority"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// RatePlanResponse is the representation exposed through the API.
+type RatePlanResponse struct {
+	ID           string         `json:"id" xml:"id"`
+	Name         string         `json:"name" xml:"name"`
+	RoomType     string         `json:"roomType" xml:"roomType"`
+	StartDate    string         `json:"startDate,omitempty" xml:"startDate,omitempty"`
+	EndDate      string         `json:"endDate,omitempty" xml:"endDate,omitempty"`
+	Price        float64        `json:"price" xml:"price"`
+	WeekendPrice *float64       `json:"weekendPrice,omitempty" xml:"weekendPrice,omitempty"`
+	Discounts    []StayDiscount `json:"discounts" xml:"discounts>discount"`
+	Priority     int            `json:"priority" xml:"priority"`
+	CreatedAt    time.Time      `json:"createdAt" xml:"createdAt"`
+}
+
+// ToResponse converts a RatePlan into an externally safe representation.
+func (p RatePlan) ToResponse() RatePlanResponse {
+	response := RatePlanResponse{
+		ID:           p.ID.Hex(),
+		Name:         p.Name,
+		RoomType:     p.RoomType,
+		Price:        p.Price,
+		WeekendPrice: p.WeekendPrice,
+		Discounts:    p.Discounts,
+		Priority:     p.Priority,
+		CreatedAt:    p.CreatedAt,
+	}
+	if response.Discounts == nil {
+		response.Discounts = []StayDiscount{}
+	}
+	if p.StartDate != nil {
+		response.StartDate = p.StartDate.Format(DateLayout)
+	}
+	if p.EndDate != nil {
+		response.EndDate = p.EndDate.Format(DateLayout)
+	}
+	return response
+}
+
+// NightPrice is the price charged for one night of a stay.
+type NightPrice struct {
+	Date     string  `json:"date" xml:"date"`
+	Price    float64 `json:"price" xml:"price"`
+	RatePlan string  `json:"ratePlan,omitempty" xml:"ratePlan,omitempty"`
+}
+
+// Quote is the price breakdown of a stay. It is stored on bookings so later
+// rate changes do not alter confirmed prices.
+type Quote struct {
+	Nights   []NightPrice `json:"nights" bson:"nights" xml:"nights>night"`
+	Subtotal float64      `json:"subtotal" bson:"subtotal" xml:"subtotal"`
+	Discount float64      `json:"discount" bson:"discount" xml:"discount"`
+	Total    float64      `json:"total" bson:"total" xml:"total"`
+}
+
+// Payment records an amount charged for a booking, either at the front desk
+// or through an online payment provider.
+type Payment struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BookingID   primitive.ObjectID `json:"bookingId" bson:"bookingId"`
+	Amount      float64            `json:"amount" bson:"amount"`
+	Currency    string             `json:"currency" bson:"currency"`
+	Method      string             `json:"method" bson:"method"`
+	Status      string             `json:"status" bson:"status"`
+	ProviderRef string             `json:"providerRef,omitempty" bson:"providerRef,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PaymentResponse is the representation exposed through the API.
+type PaymentResponse struct {
+	ID          string    `json:"id" xml:"id"`
+	BookingID   string    `json:"bookingId" xml:"bookingId"`
+	Amount      float64   `json:"amount" xml:"amount"`
+	Currency    string    `json:"currency" xml:"currency"`
+	Method      string    `json:"method" xml:"method"`
+	Status      string    `json:"status" xml:"status"`
+	ProviderRef string    `json:"providerRef,omitempty" xml:"providerRef,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" xml:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" xml:"updatedAt"`
+}
+
+// ToResponse converts a Payment into an externally safe representation.
+func (p Payment) ToResponse() PaymentResponse {
+	return PaymentResponse{
+		ID:          p.ID.Hex(),
+		BookingID:   p.BookingID.Hex(),
+		Amount:      p.Amount,
+		Currency:    p.Currency,
+		Method:      p.Method,
+		Status:      p.Status,
+		ProviderRef: p.ProviderRef,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
 	}
 }
+
+// Review is a guest's rating of a completed stay.
+type Review struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BookingID primitive.ObjectID `json:"bookingId" bson:"bookingId"`
+	RoomID    primitive.ObjectID `json:"roomId" bson:"roomId"`
+	Rating    int                `json:"rating" bson:"rating"`
+	Comment   string             `json:"comment" bson:"comment"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// ReviewResponse is the representation exposed through the API. The guest
+// is left out since reviews are public.
+type ReviewResponse struct {
+	ID        string    `json:"id" xml:"id"`
+	BookingID string    `json:"bookingId" xml:"bookingId"`
+	RoomID    string    `json:"roomId" xml:"roomId"`
+	Rating    int       `json:"rating" xml:"rating"`
+	Comment   string    `json:"comment" xml:"comment"`
+	CreatedAt time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+// ToResponse converts a Review into an externally safe representation.
+func (r Review) ToResponse() ReviewResponse {
+	return ReviewResponse{
+		ID:        r.ID.Hex(),
+		BookingID: r.BookingID.Hex(),
+		RoomID:    r.RoomID.Hex(),
+		Rating:    r.Rating,
+		Comment:   r.Comment,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// RoomRating is the average review rating of a room.
+type RoomRating struct {
+	Average float64 `json:"average" bson:"average" xml:"average"`
+	Count   int     `json:"count" bson:"count" xml:"count"`
+}
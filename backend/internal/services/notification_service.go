@@ -0,0 +1,297 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Notification kinds.
+const (
+	// NotificationMention is sent to the users mentioned in a comment.
+	NotificationMention = "mention"
+	// NotificationAssignment is sent to whoever a todo is assigned to,
+	// like the housekeeping staff on check-out.
+	NotificationAssignment = "assignment"
+	// NotificationReminder is sent to the guest along the arrival
+	// reminder email.
+	NotificationReminder = "reminder"
+	// NotificationShare is sent to whoever a list is shared with.
+	NotificationShare = "share"
+)
+
+// Notification is an entry of the in-app inbox of Email. The IDs say what
+// it is about; a mention notification doubles as the record of the
+// mention.
+type Notification struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Email string             `bson:"email"`
+	Kind  string             `bson:"kind"`
+	// Key, when set, makes the notification unique: another one with the
+	// same key is dropped, so a retried job does not notify twice.
+	Key       string             `bson:"key,omitempty"`
+	TodoID    primitive.ObjectID `bson:"todoId,omitempty"`
+	CommentID primitive.ObjectID `bson:"commentId,omitempty"`
+	BookingID primitive.ObjectID `bson:"bookingId,omitempty"`
+	ListID    primitive.ObjectID `bson:"listId,omitempty"`
+	// Author is who caused it, when it was a user.
+	Author    string     `bson:"author,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt"`
+	ReadAt    *time.Time `bson:"readAt,omitempty"`
+}
+
+// NotificationResponse is the representation exposed through the API.
+type NotificationResponse struct {
+	ID        string     `json:"id" xml:"id"`
+	Kind      string     `json:"kind" xml:"kind"`
+	TodoID    string     `json:"todoId,omitempty" xml:"todoId,omitempty"`
+	CommentID string     `json:"commentId,omitempty" xml:"commentId,omitempty"`
+	BookingID string     `json:"bookingId,omitempty" xml:"bookingId,omitempty"`
+	ListID    string     `json:"listId,omitempty" xml:"listId,omitempty"`
+	Author    string     `json:"author,omitempty" xml:"author,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" xml:"createdAt"`
+	ReadAt    *time.Time `json:"readAt,omitempty" xml:"readAt,omitempty"`
+}
+
+// ToResponse converts a Notification into an externally safe
+// representation.
+func (n Notification) ToResponse() NotificationResponse {
+	return NotificationResponse{
+		ID:        n.ID.Hex(),
+		Kind:      n.Kind,
+		TodoID:    hexOrEmpty(n.TodoID),
+		CommentID: hexOrEmpty(n.CommentID),
+		BookingID: hexOrEmpty(n.BookingID),
+		ListID:    hexOrEmpty(n.ListID),
+		Author:    n.Author,
+		CreatedAt: n.CreatedAt,
+		ReadAt:    n.ReadAt,
+	}
+}
+
+func hexOrEmpty(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
+}
+
+// NotificationQuery selects a page of the inbox of Email.
+type NotificationQuery struct {
+	Email string
+	// Unread leaves out the notifications already read.
+	Unread bool
+	// Offset skips that many notifications; Limit caps the result (zero
+	// means all).
+	Offset int
+	Limit  int
+}
+
+// NotificationPage is one slice of an inbox plus the total matching count
+// and how many notifications of the inbox are unread.
+type NotificationPage struct {
+	Notifications []NotificationResponse
+	Total         int64
+	Unread        int64
+}
+
+// NotificationRepository is the storage contract of the in-app inbox.
+type NotificationRepository interface {
+	// List returns the notifications matching query, newest first.
+	List(ctx context.Context, query NotificationQuery) ([]Notification, error)
+	Count(ctx context.Context, query NotificationQuery) (int64, error)
+	// Create stores notifications, dropping those whose Key is taken.
+	Create(ctx context.Context, notifications []Notification) error
+	// MarkRead stamps a notification of email as read, keeping the first
+	// date, or returns ErrNotFound.
+	MarkRead(ctx context.Context, id primitive.ObjectID, email string, at time.Time) (Notification, error)
+	// MarkAllRead stamps every unread notification of email and returns
+	// how many.
+	MarkAllRead(ctx context.Context, email string, at time.Time) (int64, error)
+}
+
+// MongoNotificationRepository implements NotificationRepository backed by
+// MongoDB.
+type MongoNotificationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoNotificationRepository creates a new repository wrapper around a
+// Mongo collection.
+func NewMongoNotificationRepository(collection *mongo.Collection) *MongoNotificationRepository {
+	return &MongoNotificationRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used to list an inbox and the unique
+// index of the keys.
+func (m *MongoNotificationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("key_unique").
+				SetPartialFilterExpression(bson.M{"key": bson.M{"$type": "string"}}),
+		},
+	})
+	return err
+}
+
+func notificationFilter(query NotificationQuery) bson.M {
+	filter := bson.M{"email": query.Email}
+	if query.Unread {
+		filter["readAt"] = nil
+	}
+	return filter
+}
+
+// List implements NotificationRepository.
+func (m *MongoNotificationRepository) List(ctx context.Context, query NotificationQuery) ([]Notification, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	if query.Offset > 0 {
+		opts.SetSkip(int64(query.Offset))
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := m.collection.Find(ctx, notificationFilter(query), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var notifications []Notification
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// Count implements NotificationRepository, ignoring pagination.
+func (m *MongoNotificationRepository) Count(ctx context.Context, query NotificationQuery) (int64, error) {
+	return m.collection.CountDocuments(ctx, notificationFilter(query))
+}
+
+// Create inserts the notifications unordered, so a taken key only drops
+// its own notification.
+func (m *MongoNotificationRepository) Create(ctx context.Context, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	docs := make([]any, len(notifications))
+	for i, notification := range notifications {
+		docs[i] = notification
+	}
+	_, err := m.collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bulk mongo.BulkWriteException
+	if !errors.As(err, &bulk) || bulk.WriteConcernError != nil {
+		return err
+	}
+	for _, writeErr := range bulk.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkRead implements NotificationRepository.
+func (m *MongoNotificationRepository) MarkRead(ctx context.Context, id primitive.ObjectID, email string, at time.Time) (Notification, error) {
+	_, err := m.collection.UpdateOne(ctx, bson.M{"_id": id, "email": email, "readAt": nil}, bson.M{"$set": bson.M{"readAt": at}})
+	if err != nil {
+		return Notification{}, err
+	}
+	var notification Notification
+	err = m.collection.FindOne(ctx, bson.M{"_id": id, "email": email}).Decode(&notification)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Notification{}, ErrNotFound
+	}
+	return notification, err
+}
+
+// MarkAllRead implements NotificationRepository with a single UpdateMany.
+func (m *MongoNotificationRepository) MarkAllRead(ctx context.Context, email string, at time.Time) (int64, error) {
+	res, err := m.collection.UpdateMany(ctx, bson.M{"email": email, "readAt": nil}, bson.M{"$set": bson.M{"readAt": at}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// NotificationService handles the in-app inbox of each user. Comments,
+// housekeeping, the arrival reminders and the shared lists write to it.
+type NotificationService struct {
+	repo NotificationRepository
+	now  func() time.Time
+	ids  IDGenerator
+}
+
+// NewNotificationService builds a new NotificationService instance.
+func NewNotificationService(repo NotificationRepository, now func() time.Time, ids IDGenerator) *NotificationService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &NotificationService{repo: repo, now: now, ids: ids}
+}
+
+// Notify stores notification in the inbox of its Email, stamping its ID
+// and date. With a Key it is stored at most once.
+func (s *NotificationService) Notify(ctx context.Context, notification Notification) error {
+	notification.ID, notification.CreatedAt = s.ids.NewID(), s.now()
+	notification.Email = NormalizeEmail(notification.Email)
+	return s.repo.Create(ctx, []Notification{notification})
+}
+
+// List returns a page of the inbox of email, newest first, with the unread
+// count of the whole inbox.
+func (s *NotificationService) List(ctx context.Context, query NotificationQuery) (NotificationPage, error) {
+	if query.Offset < 0 || query.Limit < 0 {
+		return NotificationPage{}, ErrInvalidPagination
+	}
+	query.Email = NormalizeEmail(query.Email)
+
+	notifications, err := s.repo.List(ctx, query)
+	if err != nil {
+		return NotificationPage{}, err
+	}
+	total := int64(len(notifications))
+	if query.Limit > 0 || query.Offset > 0 {
+		if total, err = s.repo.Count(ctx, query); err != nil {
+			return NotificationPage{}, err
+		}
+	}
+	unread, err := s.repo.Count(ctx, NotificationQuery{Email: query.Email, Unread: true})
+	if err != nil {
+		return NotificationPage{}, err
+	}
+
+	responses := make([]NotificationResponse, 0, len(notifications))
+	for _, notification := range notifications {
+		responses = append(responses, notification.ToResponse())
+	}
+	return NotificationPage{Notifications: responses, Total: total, Unread: unread}, nil
+}
+
+// MarkRead marks a notification of email as read. Notifications of other
+// users are reported as ErrNotFound.
+func (s *NotificationService) MarkRead(ctx context.Context, id primitive.ObjectID, email string) (NotificationResponse, error) {
+	notification, err := s.repo.MarkRead(ctx, id, NormalizeEmail(email), s.now())
+	if err != nil {
+		return NotificationResponse{}, err
+	}
+	return notification.ToResponse(), nil
+}
+
+// MarkAllRead marks the whole inbox of email as read and returns how many
+// notifications were unread.
+func (s *NotificationService) MarkAllRead(ctx context.Context, email string) (int64, error) {
+	return s.repo.MarkAllRead(ctx, NormalizeEmail(email), s.now())
+}
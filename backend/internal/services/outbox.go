@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// Outbox stores domain events atomically with the change that produced
+// them, so an event is published if and only if its change was committed.
+type Outbox interface {
+	// Atomically runs change and stores the events it returns in the same
+	// transaction. Repository calls made with the ctx given to change take
+	// part in the transaction; change may run more than once when the
+	// transaction is retried.
+	Atomically(ctx context.Context, change func(ctx context.Context) ([]events.Event, error)) error
+}
+
+// OutboxMessage is an event waiting in the outbox. Its ID is the event ID,
+// which consumers use to discard the duplicates of an at-least-once
+// delivery.
+type OutboxMessage struct {
+	ID     string       `bson:"_id"`
+	Event  events.Event `bson:"event"`
+	Status string       `bson:"status"`
+	// Actor is who caused the event, for the audit trail; see WithActor.
+	Actor string `bson:"actor,omitempty"`
+	// NextAttemptAt is when the message may be claimed again: after the
+	// retry backoff of a failure, or the lease of the relay that holds it.
+	NextAttemptAt time.Time  `bson:"nextAttemptAt"`
+	Attempts      int        `bson:"attempts"`
+	LastError     string     `bson:"lastError,omitempty"`
+	CreatedAt     time.Time  `bson:"createdAt"`
+	PublishedAt   *time.Time `bson:"publishedAt,omitempty"`
+}
+
+// Outbox message statuses. A dead message exhausted its attempts and was
+// moved to the dead letters.
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+	OutboxDead      = "dead"
+)
+
+// NewOutboxMessage wraps event, caused by actor, as a pending message.
+func NewOutboxMessage(event events.Event, actor string, at time.Time) OutboxMessage {
+	return OutboxMessage{ID: event.ID, Event: event, Actor: actor, Status: OutboxPending, NextAttemptAt: at, CreatedAt: at}
+}
+
+// OutboxRepository is the storage contract required by the outbox relay.
+type OutboxRepository interface {
+	// Claim leases up to limit pending messages due at now, oldest first,
+	// until now+lease so other relays skip them meanwhile.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error)
+	MarkPublished(ctx context.Context, id string, at time.Time) error
+	// MarkFailed records a failed attempt and schedules the next one.
+	MarkFailed(ctx context.Context, id string, next time.Time, reason string) error
+	// MarkDead records the last failed attempt of a message that will not
+	// be retried.
+	MarkDead(ctx context.Context, id string, reason string) error
+}
+
+// MongoOutbox implements Outbox and OutboxRepository over an outbox
+// collection. Atomically runs a transaction, so MongoDB must be a replica
+// set.
+type MongoOutbox struct {
+	collection *mongo.Collection
+	now        func() time.Time
+}
+
+// NewMongoOutbox creates an outbox over collection.
+func NewMongoOutbox(collection *mongo.Collection, now func() time.Time) *MongoOutbox {
+	if now == nil {
+		now = time.Now
+	}
+	return &MongoOutbox{collection: collection, now: now}
+}
+
+// EnsureIndexes creates the indexes used to claim the due messages and to
+// report the deliveries per day.
+func (m *MongoOutbox) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}},
+	})
+	return err
+}
+
+// Atomically implements Outbox. It uses the booking transaction options, as
+// it also wraps booking writes, and joins the transaction of ctx if any.
+func (m *MongoOutbox) Atomically(ctx context.Context, change func(ctx context.Context) ([]events.Event, error)) error {
+	return runInTransaction(ctx, m.collection.Database().Client(), func(sc mongo.SessionContext) error {
+		pending, err := change(sc)
+		if err != nil || len(pending) == 0 {
+			return err
+		}
+		now := m.now()
+		docs := make([]interface{}, len(pending))
+		for i, event := range pending {
+			docs[i] = NewOutboxMessage(event, ActorFrom(ctx), now)
+		}
+		_, err = m.collection.InsertMany(sc, docs)
+		return err
+	})
+}
+
+// Claim implements OutboxRepository, leasing one message at a time so
+// concurrent relays never claim the same message.
+func (m *MongoOutbox) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	var claimed []OutboxMessage
+	for len(claimed) < limit {
+		var msg OutboxMessage
+		err := m.collection.FindOneAndUpdate(ctx,
+			bson.M{"status": OutboxPending, "nextAttemptAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"nextAttemptAt": now.Add(lease)}},
+			options.FindOneAndUpdate().
+				SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+				SetReturnDocument(options.After),
+		).Decode(&msg)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return claimed, err
+		}
+		claimed = append(claimed, msg)
+	}
+	return claimed, nil
+}
+
+// MarkPublished implements OutboxRepository.
+func (m *MongoOutbox) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	_, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"status": OutboxPublished, "publishedAt": at},
+		"$inc":   bson.M{"attempts": 1},
+		"$unset": bson.M{"lastError": ""},
+	})
+	return err
+}
+
+// MarkFailed implements OutboxRepository.
+func (m *MongoOutbox) MarkFailed(ctx context.Context, id string, next time.Time, reason string) error {
+	_, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"nextAttemptAt": next, "lastError": reason},
+		"$inc": bson.M{"attempts": 1},
+	})
+	return err
+}
+
+// MarkDead implements OutboxRepository.
+func (m *MongoOutbox) MarkDead(ctx context.Context, id string, reason string) error {
+	_, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"status": OutboxDead, "lastError": reason},
+		"$inc": bson.M{"attempts": 1},
+	})
+	return err
+}
+
+// runInTransaction runs fn in a transaction with the booking transaction
+// options, or inside the transaction ctx already belongs to, so an outbox
+// transaction and the repositories it calls commit together.
+func runInTransaction(ctx context.Context, client *mongo.Client, fn func(mongo.SessionContext) error) error {
+	if session := mongo.SessionFromContext(ctx); session != nil {
+		return fn(mongo.NewSessionContext(ctx, session))
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	}, bookingTxnOptions)
+	return err
+}
+
+// OutboxRelayConfig tunes the relay.
+type OutboxRelayConfig struct {
+	// Batch is how many messages are claimed per round.
+	Batch int
+	// Lease is how long a claimed message is reserved for this relay.
+	Lease time.Duration
+	// Backoff is the delay after the first failure; it doubles with every
+	// attempt up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxAttempts moves a message to the dead letters after that many
+	// failed attempts; zero retries forever.
+	MaxAttempts int
+}
+
+// OutboxRelay publishes the outbox messages with at-least-once semantics:
+// a message is marked published only after the publisher accepted it, and
+// failures are retried with exponential backoff. A message may therefore be
+// delivered more than once; its event ID lets consumers deduplicate.
+type OutboxRelay struct {
+	repo        OutboxRepository
+	publisher   events.Publisher
+	deadLetters DeadLetterRepository
+	cfg         OutboxRelayConfig
+	now         func() time.Time
+}
+
+// NewOutboxRelay builds a new OutboxRelay instance; deadLetters receives
+// the messages that exhaust cfg.MaxAttempts.
+func NewOutboxRelay(repo OutboxRepository, publisher events.Publisher, deadLetters DeadLetterRepository, cfg OutboxRelayConfig, now func() time.Time) *OutboxRelay {
+	if now == nil {
+		now = time.Now
+	}
+	if cfg.Batch < 1 {
+		cfg.Batch = 100
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = time.Minute
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	return &OutboxRelay{repo: repo, publisher: publisher, deadLetters: deadLetters, cfg: cfg, now: now}
+}
+
+// Relay publishes the due messages until none is left and returns how many
+// were published.
+func (r *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	published := 0
+	for {
+		messages, err := r.repo.Claim(ctx, r.now(), r.cfg.Lease, r.cfg.Batch)
+		if err != nil {
+			return published, err
+		}
+		for _, msg := range messages {
+			if r.publish(ctx, msg) {
+				published++
+			}
+		}
+		if len(messages) < r.cfg.Batch {
+			return published, nil
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, msg OutboxMessage) bool {
+	if err := r.publisher.Publish(ctx, msg.Event); err != nil {
+		log.Printf("no se pudo publicar el evento %s (%s), intento %d: %v", msg.ID, msg.Event.Type, msg.Attempts+1, err)
+		if r.exhausted(msg) && r.bury(ctx, msg, err) {
+			return false
+		}
+		next := r.now().Add(r.backoff(msg.Attempts))
+		if markErr := r.repo.MarkFailed(ctx, msg.ID, next, err.Error()); markErr != nil {
+			log.Printf("no se pudo reprogramar el evento %s: %v", msg.ID, markErr)
+		}
+		return false
+	}
+	if err := r.repo.MarkPublished(ctx, msg.ID, r.now()); err != nil {
+		// The lease expires and the message is sent again; consumers
+		// discard the duplicate by its ID.
+		log.Printf("no se pudo marcar publicado el evento %s: %v", msg.ID, err)
+	}
+	return true
+}
+
+// exhausted reports whether the failed attempt of msg was its last one.
+func (r *OutboxRelay) exhausted(msg OutboxMessage) bool {
+	return r.cfg.MaxAttempts > 0 && msg.Attempts+1 >= r.cfg.MaxAttempts
+}
+
+// bury moves msg to the dead letters and reports whether it did; otherwise
+// the message is retried as usual.
+func (r *OutboxRelay) bury(ctx context.Context, msg OutboxMessage, cause error) bool {
+	letter, err := NewDeadLetter(DeadLetterEvent, msg.Event.Type, msg.ID, msg.Event, msg.Attempts+1, cause, r.now())
+	if err == nil {
+		_, err = r.deadLetters.Create(ctx, letter)
+	}
+	if err != nil {
+		log.Printf("no se pudo mover el evento %s a los mensajes fallidos: %v", msg.ID, err)
+		return false
+	}
+	if err := r.repo.MarkDead(ctx, msg.ID, cause.Error()); err != nil {
+		// The message is relayed again and, if it fails, dead-lettered
+		// twice; retrying either copy delivers the same event ID.
+		log.Printf("no se pudo marcar descartado el evento %s: %v", msg.ID, err)
+	}
+	return true
+}
+
+// Redeliver publishes the event of a dead letter again, to the broker and
+// every webhook; destinations that already received it discard it by its
+// ID. It is meant to be registered with DeadLetterService.Handle.
+func (r *OutboxRelay) Redeliver(ctx context.Context, payload json.RawMessage) error {
+	var event events.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return err
+	}
+	return r.publisher.Publish(ctx, event)
+}
+
+// backoff returns the delay after attempts failed attempts.
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	wait := r.cfg.Backoff
+	for i := 0; i < attempts && wait < r.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, r.cfg.MaxBackoff)
+}
+
+// Run relays the outbox every interval until ctx is done.
+func (r *OutboxRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Relay(ctx); err != nil {
+			log.Printf("no se pudo procesar el outbox de eventos: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// newEvents builds a one-event slice for Outbox.Atomically.
+func newEvents(eventType, key string, data any, at time.Time) ([]events.Event, error) {
+	event, err := events.New(eventType, key, data, at)
+	if err != nil {
+		return nil, err
+	}
+	return []events.Event{event}, nil
+}
@@ -0,0 +1,512 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/webauthn"
+)
+
+// PasskeyCeremonyTTL is how long the browser has to answer a passkey
+// challenge.
+const PasskeyCeremonyTTL = 5 * time.Minute
+
+// Passkey ceremony kinds.
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+var (
+	// ErrInvalidCeremony is returned for unknown, expired or already used
+	// passkey ceremonies.
+	ErrInvalidCeremony = errors.New("invalid passkey ceremony")
+	// ErrInvalidPasskey indicates a passkey response that fails the WebAuthn
+	// checks.
+	ErrInvalidPasskey = errors.New("invalid passkey response")
+	// ErrPasskeyExists is returned when registering a credential twice.
+	ErrPasskeyExists = errors.New("passkey already registered")
+	// ErrInvalidPasskeyID indicates the passkey ID could not be parsed.
+	ErrInvalidPasskeyID = errors.New("invalid passkey id")
+)
+
+// Passkey is a WebAuthn credential that signs in its account without a
+// password. Only its public key is stored.
+type Passkey struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty"`
+	Email string             `bson:"email"`
+	// CredentialID is the base64url credential ID chosen by the
+	// authenticator.
+	CredentialID string     `bson:"credentialId"`
+	PublicKey    []byte     `bson:"publicKey"`
+	SignCount    uint32     `bson:"signCount"`
+	Name         string     `bson:"name"`
+	CreatedAt    time.Time  `bson:"createdAt"`
+	LastUsedAt   *time.Time `bson:"lastUsedAt,omitempty"`
+}
+
+// PasskeyResponse is the representation exposed through the API.
+type PasskeyResponse struct {
+	ID         string     `json:"id" xml:"id"`
+	Name       string     `json:"name" xml:"name"`
+	CreatedAt  time.Time  `json:"createdAt" xml:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" xml:"lastUsedAt,omitempty"`
+}
+
+// ToResponse converts a Passkey into an externally safe representation.
+func (p Passkey) ToResponse() PasskeyResponse {
+	return PasskeyResponse{ID: p.ID.Hex(), Name: p.Name, CreatedAt: p.CreatedAt, LastUsedAt: p.LastUsedAt}
+}
+
+// PasskeyCeremony is a pending challenge; it is used once. Email is empty
+// for logins that let the browser pick the passkey.
+type PasskeyCeremony struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Kind      string             `bson:"kind"`
+	Email     string             `bson:"email,omitempty"`
+	Challenge []byte             `bson:"challenge"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+}
+
+// PasskeyRepository is the storage contract required by the passkey
+// service.
+type PasskeyRepository interface {
+	// Create stores a passkey or returns ErrPasskeyExists.
+	Create(ctx context.Context, passkey Passkey) (Passkey, error)
+	// FindByCredentialID returns the passkey or ErrNotFound.
+	FindByCredentialID(ctx context.Context, credentialID string) (Passkey, error)
+	// ListByEmail returns the passkeys of email, oldest first.
+	ListByEmail(ctx context.Context, email string) ([]Passkey, error)
+	// MarkUsed saves the signature counter after a login.
+	MarkUsed(ctx context.Context, id primitive.ObjectID, signCount uint32, at time.Time) error
+	// Delete removes a passkey of email or returns ErrNotFound.
+	Delete(ctx context.Context, email string, id primitive.ObjectID) error
+}
+
+// CeremonyRepository keeps the pending passkey challenges.
+type CeremonyRepository interface {
+	Create(ctx context.Context, ceremony PasskeyCeremony) (PasskeyCeremony, error)
+	// Take removes and returns a ceremony, or returns ErrNotFound.
+	Take(ctx context.Context, id primitive.ObjectID) (PasskeyCeremony, error)
+}
+
+// MongoPasskeyRepository implements PasskeyRepository backed by MongoDB.
+type MongoPasskeyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoPasskeyRepository creates a repository over collection.
+func NewMongoPasskeyRepository(collection *mongo.Collection) *MongoPasskeyRepository {
+	return &MongoPasskeyRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique credential index and the index used to
+// list the passkeys of an account.
+func (m *MongoPasskeyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "credentialId", Value: 1}}, Options: options.Index().SetUnique(true).SetName("credential_unique")},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: 1}}},
+	})
+	return err
+}
+
+// Create implements PasskeyRepository.
+func (m *MongoPasskeyRepository) Create(ctx context.Context, passkey Passkey) (Passkey, error) {
+	res, err := m.collection.InsertOne(ctx, passkey)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Passkey{}, ErrPasskeyExists
+		}
+		return Passkey{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		passkey.ID = oid
+	}
+	return passkey, nil
+}
+
+// FindByCredentialID implements PasskeyRepository.
+func (m *MongoPasskeyRepository) FindByCredentialID(ctx context.Context, credentialID string) (Passkey, error) {
+	var passkey Passkey
+	err := m.collection.FindOne(ctx, bson.M{"credentialId": credentialID}).Decode(&passkey)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Passkey{}, ErrNotFound
+	}
+	return passkey, err
+}
+
+// ListByEmail implements PasskeyRepository.
+func (m *MongoPasskeyRepository) ListByEmail(ctx context.Context, email string) ([]Passkey, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"email": email},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var passkeys []Passkey
+	if err := cursor.All(ctx, &passkeys); err != nil {
+		return nil, err
+	}
+	return passkeys, nil
+}
+
+// MarkUsed implements PasskeyRepository.
+func (m *MongoPasskeyRepository) MarkUsed(ctx context.Context, id primitive.ObjectID, signCount uint32, at time.Time) error {
+	res, err := m.collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"signCount": signCount, "lastUsedAt": at}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete implements PasskeyRepository.
+func (m *MongoPasskeyRepository) Delete(ctx context.Context, email string, id primitive.ObjectID) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id, "email": email})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MongoCeremonyRepository implements CeremonyRepository backed by MongoDB.
+type MongoCeremonyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoCeremonyRepository creates a repository over collection.
+func NewMongoCeremonyRepository(collection *mongo.Collection) *MongoCeremonyRepository {
+	return &MongoCeremonyRepository{collection: collection}
+}
+
+// EnsureIndexes creates a TTL index that lets MongoDB purge abandoned
+// ceremonies.
+func (m *MongoCeremonyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// Create implements CeremonyRepository.
+func (m *MongoCeremonyRepository) Create(ctx context.Context, ceremony PasskeyCeremony) (PasskeyCeremony, error) {
+	res, err := m.collection.InsertOne(ctx, ceremony)
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		ceremony.ID = oid
+	}
+	return ceremony, nil
+}
+
+// Take implements CeremonyRepository; deleting on read keeps a challenge
+// from being answered twice.
+func (m *MongoCeremonyRepository) Take(ctx context.Context, id primitive.ObjectID) (PasskeyCeremony, error) {
+	var ceremony PasskeyCeremony
+	err := m.collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&ceremony)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return PasskeyCeremony{}, ErrNotFound
+	}
+	return ceremony, err
+}
+
+// PublicKeyCredentialDescriptor names a credential in the ceremony options.
+type PublicKeyCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// CredentialCreationOptions are the publicKey options of
+// navigator.credentials.create, with binary fields in base64url.
+type CredentialCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int                             `json:"timeout"`
+	Attestation            string                          `json:"attestation"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+}
+
+// CredentialRequestOptions are the publicKey options of
+// navigator.credentials.get, with binary fields in base64url.
+type CredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	RPID             string                          `json:"rpId"`
+	Timeout          int                             `json:"timeout"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+// PasskeyAttestation is the response of navigator.credentials.create.
+type PasskeyAttestation struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// PasskeyAssertion is the response of navigator.credentials.get.
+type PasskeyAssertion struct {
+	CredentialID      string
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// PasskeyService runs the WebAuthn ceremonies that register passkeys and
+// sign in with them.
+type PasskeyService struct {
+	passkeys   PasskeyRepository
+	ceremonies CeremonyRepository
+	users      UserRepository
+	rp         webauthn.Config
+	now        func() time.Time
+	ids        IDGenerator
+}
+
+// NewPasskeyService builds a PasskeyService for the relying party rp.
+func NewPasskeyService(passkeys PasskeyRepository, ceremonies CeremonyRepository, users UserRepository, rp webauthn.Config, now func() time.Time, ids IDGenerator) *PasskeyService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &PasskeyService{passkeys: passkeys, ceremonies: ceremonies, users: users, rp: rp, now: now, ids: ids}
+}
+
+// begin stores a new ceremony with a fresh challenge.
+func (s *PasskeyService) begin(ctx context.Context, kind, email string) (PasskeyCeremony, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+	return s.ceremonies.Create(ctx, PasskeyCeremony{
+		ID:        s.ids.NewID(),
+		Kind:      kind,
+		Email:     email,
+		Challenge: challenge,
+		ExpiresAt: s.now().Add(PasskeyCeremonyTTL),
+	})
+}
+
+// take consumes the ceremony id of kind.
+func (s *PasskeyService) take(ctx context.Context, id, kind string) (PasskeyCeremony, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return PasskeyCeremony{}, ErrInvalidCeremony
+	}
+	ceremony, err := s.ceremonies.Take(ctx, oid)
+	if errors.Is(err, ErrNotFound) {
+		return PasskeyCeremony{}, ErrInvalidCeremony
+	}
+	if err != nil {
+		return PasskeyCeremony{}, err
+	}
+	if ceremony.Kind != kind || !s.now().Before(ceremony.ExpiresAt) {
+		return PasskeyCeremony{}, ErrInvalidCeremony
+	}
+	return ceremony, nil
+}
+
+func (s *PasskeyService) descriptors(ctx context.Context, email string) ([]PublicKeyCredentialDescriptor, error) {
+	passkeys, err := s.passkeys.ListByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	descriptors := make([]PublicKeyCredentialDescriptor, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		descriptors = append(descriptors, PublicKeyCredentialDescriptor{Type: "public-key", ID: passkey.CredentialID})
+	}
+	return descriptors, nil
+}
+
+// userHandle identifies the account to authenticators without exposing
+// its email.
+func userHandle(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return webauthn.Encode(sum[:16])
+}
+
+// BeginRegistration starts adding a passkey to the account of email and
+// returns the ceremony ID with the options for the browser.
+func (s *PasskeyService) BeginRegistration(ctx context.Context, email string) (string, CredentialCreationOptions, error) {
+	email = NormalizeEmail(email)
+	exclude, err := s.descriptors(ctx, email)
+	if err != nil {
+		return "", CredentialCreationOptions{}, err
+	}
+	ceremony, err := s.begin(ctx, CeremonyRegistration, email)
+	if err != nil {
+		return "", CredentialCreationOptions{}, err
+	}
+
+	var opts CredentialCreationOptions
+	opts.Challenge = webauthn.Encode(ceremony.Challenge)
+	opts.RP.ID, opts.RP.Name = s.rp.RPID, s.rp.RPName
+	opts.User.ID, opts.User.Name, opts.User.DisplayName = userHandle(email), email, email
+	for _, alg := range webauthn.Algorithms {
+		opts.PubKeyCredParams = append(opts.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{Type: "public-key", Alg: alg})
+	}
+	opts.Timeout = int(PasskeyCeremonyTTL.Milliseconds())
+	opts.Attestation = "none"
+	opts.ExcludeCredentials = exclude
+	opts.AuthenticatorSelection.ResidentKey = "preferred"
+	opts.AuthenticatorSelection.UserVerification = "preferred"
+	return ceremony.ID.Hex(), opts, nil
+}
+
+// FinishRegistration verifies the browser response to ceremony id and
+// stores the new passkey of email under name.
+func (s *PasskeyService) FinishRegistration(ctx context.Context, email, id, name string, response PasskeyAttestation) (PasskeyResponse, error) {
+	email = NormalizeEmail(email)
+	ceremony, err := s.take(ctx, id, CeremonyRegistration)
+	if err != nil {
+		return PasskeyResponse{}, err
+	}
+	if ceremony.Email != email {
+		return PasskeyResponse{}, ErrInvalidCeremony
+	}
+
+	credential, err := s.rp.VerifyRegistration(ceremony.Challenge, response.ClientDataJSON, response.AttestationObject)
+	if err != nil {
+		return PasskeyResponse{}, ErrInvalidPasskey
+	}
+	name = NormalizeText(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	passkey, err := s.passkeys.Create(ctx, Passkey{
+		ID:           s.ids.NewID(),
+		Email:        email,
+		CredentialID: webauthn.Encode(credential.ID),
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		Name:         name,
+		CreatedAt:    s.now(),
+	})
+	if err != nil {
+		return PasskeyResponse{}, err
+	}
+	return passkey.ToResponse(), nil
+}
+
+// BeginLogin starts a passkey login. With an email the browser is limited
+// to the passkeys of that account; without it the browser offers the
+// discoverable passkeys it holds for the site.
+func (s *PasskeyService) BeginLogin(ctx context.Context, email string) (string, CredentialRequestOptions, error) {
+	email = NormalizeEmail(email)
+	allow := []PublicKeyCredentialDescriptor{}
+	if email != "" {
+		var err error
+		if allow, err = s.descriptors(ctx, email); err != nil {
+			return "", CredentialRequestOptions{}, err
+		}
+	}
+	ceremony, err := s.begin(ctx, CeremonyLogin, email)
+	if err != nil {
+		return "", CredentialRequestOptions{}, err
+	}
+	return ceremony.ID.Hex(), CredentialRequestOptions{
+		Challenge:        webauthn.Encode(ceremony.Challenge),
+		RPID:             s.rp.RPID,
+		Timeout:          int(PasskeyCeremonyTTL.Milliseconds()),
+		AllowCredentials: allow,
+		UserVerification: "preferred",
+	}, nil
+}
+
+// FinishLogin verifies the browser response to ceremony id and returns the
+// user owning the passkey. Unknown passkeys yield ErrInvalidCredentials.
+func (s *PasskeyService) FinishLogin(ctx context.Context, id string, response PasskeyAssertion) (User, error) {
+	ceremony, err := s.take(ctx, id, CeremonyLogin)
+	if err != nil {
+		return User{}, err
+	}
+	passkey, err := s.passkeys.FindByCredentialID(ctx, response.CredentialID)
+	if errors.Is(err, ErrNotFound) {
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if ceremony.Email != "" && ceremony.Email != passkey.Email {
+		return User{}, ErrInvalidCredentials
+	}
+
+	signCount, err := s.rp.VerifyLogin(ceremony.Challenge, webauthn.Credential{
+		PublicKey: passkey.PublicKey,
+		SignCount: passkey.SignCount,
+	}, response.ClientDataJSON, response.AuthenticatorData, response.Signature)
+	if err != nil {
+		return User{}, ErrInvalidPasskey
+	}
+
+	user, err := s.users.FindByEmail(ctx, passkey.Email)
+	if errors.Is(err, ErrNotFound) {
+		return User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return User{}, err
+	}
+	if user.SuspendedAt != nil {
+		return User{}, ErrAccountSuspended
+	}
+	if err := s.passkeys.MarkUsed(ctx, passkey.ID, signCount, s.now()); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// List returns the passkeys of email, oldest first.
+func (s *PasskeyService) List(ctx context.Context, email string) ([]PasskeyResponse, error) {
+	passkeys, err := s.passkeys.ListByEmail(ctx, NormalizeEmail(email))
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]PasskeyResponse, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		responses = append(responses, passkey.ToResponse())
+	}
+	return responses, nil
+}
+
+// Delete removes a passkey of email.
+func (s *PasskeyService) Delete(ctx context.Context, email, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidPasskeyID
+	}
+	return s.passkeys.Delete(ctx, NormalizeEmail(email), oid)
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidPaymentInput indicates missing or malformed payment data.
+	ErrInvalidPaymentInput = errors.New("invalid payment input")
+	// ErrInvalidPaymentNotification indicates a provider notification that
+	// cannot be processed.
+	ErrInvalidPaymentNotification = errors.New("invalid payment notification")
+	// ErrDuplicatePaymentEvent is returned when a provider event was already
+	// processed.
+	ErrDuplicatePaymentEvent = errors.New("payment event already processed")
+	// ErrPaymentStateConflict is returned when the payment is no longer in a
+	// status the change applies to.
+	ErrPaymentStateConflict = errors.New("payment status conflict")
+)
+
+// Payment statuses.
+const (
+	PaymentPending  = "pending"
+	PaymentApproved = "approved"
+	PaymentRejected = "rejected"
+	PaymentRefunded = "refunded"
+)
+
+// Payment methods: front-desk methods are recorded as already approved while
+// provider payments stay pending until the provider confirms them.
+var paymentMethods = map[string]bool{
+	"cash":        false,
+	"card":        false,
+	"transfer":    false,
+	"mercadopago": true,
+	"stripe":      true,
+}
+
+// paymentTransitions lists the statuses each provider status can be reached from.
+var paymentTransitions = map[string][]string{
+	PaymentApproved: {PaymentPending},
+	PaymentRejected: {PaymentPending},
+	PaymentRefunded: {PaymentApproved},
+}
+
+// PaymentInput holds the data required to record a payment.
+type PaymentInput struct {
+	Amount   float64
+	Currency string
+	Method   string
+}
+
+// PaymentNotification is a status change reported by a payment provider.
+// EventID identifies the notification itself so redeliveries are ignored.
+type PaymentNotification struct {
+	EventID     string
+	PaymentID   string
+	Status      string
+	ProviderRef string
+}
+
+// PaymentEvent is a processed provider notification, kept to detect
+// redeliveries.
+type PaymentEvent struct {
+	EventID    string             `bson:"eventId"`
+	PaymentID  primitive.ObjectID `bson:"paymentId"`
+	Status     string             `bson:"status"`
+	ReceivedAt time.Time          `bson:"receivedAt"`
+}
+
+// PaymentRepository is the storage contract required by the payment service.
+type PaymentRepository interface {
+	ListByBooking(ctx context.Context, bookingID primitive.ObjectID) ([]Payment, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (Payment, error)
+	Create(ctx context.Context, payment Payment) (Payment, error)
+	// Apply records event and moves the payment from one of the from statuses
+	// to event.Status atomically. It returns ErrDuplicatePaymentEvent when the
+	// event was already recorded and ErrPaymentStateConflict when the payment
+	// is in another status.
+	Apply(ctx context.Context, event PaymentEvent, from []string, providerRef string) (Payment, error)
+}
+
+// MongoPaymentRepository implements PaymentRepository backed by MongoDB.
+type MongoPaymentRepository struct {
+	payments *mongo.Collection
+	events   *mongo.Collection
+}
+
+// NewMongoPaymentRepository creates a repository over the payments collection
+// and the collection of processed provider events.
+func NewMongoPaymentRepository(payments, events *mongo.Collection) *MongoPaymentRepository {
+	return &MongoPaymentRepository{payments: payments, events: events}
+}
+
+// EnsureIndexes creates the booking index on payments and the unique event
+// index that makes provider notifications idempotent.
+func (m *MongoPaymentRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.payments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "bookingId", Value: 1}, {Key: "createdAt", Value: 1}},
+	}); err != nil {
+		return err
+	}
+	_, err := m.events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "eventId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("event_unique"),
+	})
+	return err
+}
+
+// ListByBooking returns the payments of a booking in creation order.
+func (m *MongoPaymentRepository) ListByBooking(ctx context.Context, bookingID primitive.ObjectID) ([]Payment, error) {
+	cursor, err := m.payments.Find(ctx, bson.M{"bookingId": bookingID}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var payments []Payment
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// FindByID retrieves a payment or returns ErrNotFound.
+func (m *MongoPaymentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Payment, error) {
+	var payment Payment
+	err := m.payments.FindOne(ctx, bson.M{"_id": id}).Decode(&payment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Payment{}, ErrNotFound
+	}
+	return payment, err
+}
+
+// Create stores a payment and returns it with the generated ID.
+func (m *MongoPaymentRepository) Create(ctx context.Context, payment Payment) (Payment, error) {
+	res, err := m.payments.InsertOne(ctx, payment)
+	if err != nil {
+		return Payment{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		payment.ID = oid
+	}
+	return payment, nil
+}
+
+// Apply inserts the event and updates the payment inside a transaction, so a
+// failed update does not leave the event marked as processed.
+func (m *MongoPaymentRepository) Apply(ctx context.Context, event PaymentEvent, from []string, providerRef string) (Payment, error) {
+	session, err := m.payments.Database().Client().StartSession()
+	if err != nil {
+		return Payment{}, err
+	}
+	defer session.EndSession(ctx)
+
+	var payment Payment
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if _, err := m.events.InsertOne(sc, event); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, ErrDuplicatePaymentEvent
+			}
+			return nil, err
+		}
+
+		set := bson.M{"status": event.Status, "updatedAt": event.ReceivedAt}
+		if providerRef != "" {
+			set["providerRef"] = providerRef
+		}
+		err := m.payments.FindOneAndUpdate(sc,
+			bson.M{"_id": event.PaymentID, "status": bson.M{"$in": from}},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&payment)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrPaymentStateConflict
+		}
+		return nil, err
+	})
+	if err != nil {
+		return Payment{}, err
+	}
+	return payment, nil
+}
+
+// PaymentService records booking payments and processes provider notifications.
+type PaymentService struct {
+	payments PaymentRepository
+	bookings BookingRepository
+	now      func() time.Time
+	ids      IDGenerator
+}
+
+// NewPaymentService builds a new PaymentService instance.
+func NewPaymentService(payments PaymentRepository, bookings BookingRepository, now func() time.Time, ids IDGenerator) *PaymentService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &PaymentService{payments: payments, bookings: bookings, now: now, ids: ids}
+}
+
+// List returns the payments of a booking.
+func (s *PaymentService) List(ctx context.Context, bookingID string) ([]PaymentResponse, error) {
+	booking, err := s.findBooking(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := s.payments.ListByBooking(ctx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]PaymentResponse, 0, len(payments))
+	for _, payment := range payments {
+		responses = append(responses, payment.ToResponse())
+	}
+	return responses, nil
+}
+
+// Create records a payment for a booking.
+func (s *PaymentService) Create(ctx context.Context, bookingID string, input PaymentInput) (PaymentResponse, error) {
+	input.Currency = strings.ToUpper(strings.TrimSpace(input.Currency))
+	input.Method = normalizeKeyword(input.Method)
+	online, known := paymentMethods[input.Method]
+	if !known || input.Amount <= 0 || len(input.Currency) != 3 {
+		return PaymentResponse{}, ErrInvalidPaymentInput
+	}
+
+	booking, err := s.findBooking(ctx, bookingID)
+	if err != nil {
+		return PaymentResponse{}, err
+	}
+
+	status := PaymentApproved
+	if online {
+		status = PaymentPending
+	}
+	now := s.now()
+	created, err := s.payments.Create(ctx, Payment{
+		ID:        s.ids.NewID(),
+		BookingID: booking.ID,
+		Amount:    roundCents(input.Amount),
+		Currency:  input.Currency,
+		Method:    input.Method,
+		Status:    status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return PaymentResponse{}, err
+	}
+	return created.ToResponse(), nil
+}
+
+// Notify applies a provider notification. It reports false, without error,
+// when the notification was already processed or no longer applies to the
+// payment, so providers stop redelivering it.
+func (s *PaymentService) Notify(ctx context.Context, notification PaymentNotification) (PaymentResponse, bool, error) {
+	eventID := strings.TrimSpace(notification.EventID)
+	status := normalizeKeyword(notification.Status)
+	from, known := paymentTransitions[status]
+	paymentID, err := primitive.ObjectIDFromHex(notification.PaymentID)
+	if eventID == "" || !known || err != nil {
+		return PaymentResponse{}, false, ErrInvalidPaymentNotification
+	}
+
+	payment, err := s.payments.FindByID(ctx, paymentID)
+	if err != nil {
+		return PaymentResponse{}, false, err
+	}
+
+	updated, err := s.payments.Apply(ctx, PaymentEvent{
+		EventID:    eventID,
+		PaymentID:  paymentID,
+		Status:     status,
+		ReceivedAt: s.now(),
+	}, from, strings.TrimSpace(notification.ProviderRef))
+	switch {
+	case errors.Is(err, ErrDuplicatePaymentEvent), errors.Is(err, ErrPaymentStateConflict):
+		return payment.ToResponse(), false, nil
+	case err != nil:
+		return PaymentResponse{}, false, err
+	}
+	return updated.ToResponse(), true, nil
+}
+
+// findBooking loads a booking, reporting bookings of other properties as
+// ErrNotFound.
+func (s *PaymentService) findBooking(ctx context.Context, id string) (Booking, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return Booking{}, ErrInvalidBookingID
+	}
+	booking, err := s.bookings.FindByID(ctx, objID)
+	if err != nil {
+		return Booking{}, err
+	}
+	if !inScope(ctx, booking.PropertyID) {
+		return Booking{}, ErrNotFound
+	}
+	return booking, nil
+}
@@ -0,0 +1,781 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// ErasureSyncLimit is the number of todos and bookings up to which an
+// account is erased within the request; larger accounts are erased in the
+// background.
+const ErasureSyncLimit = 100
+
+// Erasure statuses.
+const (
+	ErasureRunning   = "running"
+	ErasureCompleted = "completed"
+	ErasureFailed    = "failed"
+)
+
+// ErrInvalidErasureID indicates the erasure ID could not be parsed.
+var ErrInvalidErasureID = errors.New("invalid erasure id")
+
+// AccountRecord is the account data included in an export.
+type AccountRecord struct {
+	Email      string         `json:"email" xml:"email"`
+	Role       string         `json:"role,omitempty" xml:"role,omitempty"`
+	PropertyID string         `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	CreatedAt  time.Time      `json:"createdAt" xml:"createdAt"`
+	Quota      *QuotaOverride `json:"quota,omitempty" xml:"quota,omitempty"`
+}
+
+// AccountExport holds every record kept about one account: its todos
+// (trashed ones included), the comments of the reviews of its bookings, the
+// domain events about the account, its todos and its bookings, and its
+// login history.
+type AccountExport struct {
+	ExportedAt time.Time        `json:"exportedAt" xml:"exportedAt"`
+	Account    AccountRecord    `json:"account" xml:"account"`
+	Todos      []TodoResponse   `json:"todos" xml:"todos>todo"`
+	Comments   []ReviewResponse `json:"comments" xml:"comments>comment"`
+	Activity   []events.Event   `json:"activity" xml:"activity>event"`
+	Logins     []LoginResponse  `json:"logins" xml:"logins>login"`
+}
+
+// Erasure tracks the erasure of one account. It holds no personal data, so
+// its status can be read once the account is gone.
+type Erasure struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Status string             `bson:"status"`
+	// The counts are filled as each step finishes.
+	Todos      int64      `bson:"todos"`
+	Sessions   int64      `bson:"sessions"`
+	Logins     int64      `bson:"logins"`
+	Comments   int64      `bson:"comments"`
+	Bookings   int64      `bson:"bookings"`
+	Activity   int64      `bson:"activity"`
+	Error      string     `bson:"error,omitempty"`
+	CreatedAt  time.Time  `bson:"createdAt"`
+	FinishedAt *time.Time `bson:"finishedAt,omitempty"`
+}
+
+// ErasureResponse is the representation exposed through the API.
+type ErasureResponse struct {
+	ID         string     `json:"id" xml:"id"`
+	Status     string     `json:"status" xml:"status"`
+	Todos      int64      `json:"todos" xml:"todos"`
+	Sessions   int64      `json:"sessions" xml:"sessions"`
+	Logins     int64      `json:"logins" xml:"logins"`
+	Comments   int64      `json:"comments" xml:"comments"`
+	Bookings   int64      `json:"bookings" xml:"bookings"`
+	Activity   int64      `json:"activity" xml:"activity"`
+	Error      string     `json:"error,omitempty" xml:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt" xml:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty" xml:"finishedAt,omitempty"`
+}
+
+// ToResponse converts an Erasure into an externally safe representation.
+func (e Erasure) ToResponse() ErasureResponse {
+	return ErasureResponse{
+		ID:         e.ID.Hex(),
+		Status:     e.Status,
+		Todos:      e.Todos,
+		Sessions:   e.Sessions,
+		Logins:     e.Logins,
+		Comments:   e.Comments,
+		Bookings:   e.Bookings,
+		Activity:   e.Activity,
+		Error:      e.Error,
+		CreatedAt:  e.CreatedAt,
+		FinishedAt: e.FinishedAt,
+	}
+}
+
+// PrivacyRepository reads and erases the personal data spread over the
+// collections. Every erasing method is idempotent, so a failed erasure can
+// be run again.
+type PrivacyRepository interface {
+	// Comments returns the reviews of the bookings, oldest first.
+	Comments(ctx context.Context, bookingIDs []primitive.ObjectID) ([]Review, error)
+	// Activity returns the events whose key is one of keys, oldest first.
+	Activity(ctx context.Context, keys []string) ([]events.Event, error)
+	// EraseComments blanks the comment of the reviews of the bookings; the
+	// ratings are kept for the room averages.
+	EraseComments(ctx context.Context, bookingIDs []primitive.ObjectID) (int64, error)
+	// AnonymizeActivity replaces email with alias in the key and data of
+	// the events whose key is one of keys.
+	AnonymizeActivity(ctx context.Context, keys []string, email, alias string) (int64, error)
+	// AnonymizeBookings replaces email with alias on its bookings.
+	AnonymizeBookings(ctx context.Context, email, alias string) (int64, error)
+	// DeleteTodos deletes the todos of email and what email left on the
+	// todos of others: reactions, comments, mentions, notifications and
+	// assignments.
+	DeleteTodos(ctx context.Context, email string) (int64, error)
+	DeleteSessions(ctx context.Context, email string) (int64, error)
+	// DeleteUser removes the account together with its passkeys.
+	DeleteUser(ctx context.Context, email string) error
+	// AnonymizeTodos replaces email with alias on its todos and on what it
+	// left on the todos of others (reactions, comments, mentions,
+	// attachments and assignments) and in the shared lists; its
+	// notifications are deleted. It returns how many todos it owned.
+	AnonymizeTodos(ctx context.Context, email, alias string) (int64, error)
+	// AnonymizeUser replaces the account with a tombstone under alias,
+	// without password, feed token, quota or passkeys; its role, property
+	// and registration date are kept for the statistics.
+	AnonymizeUser(ctx context.Context, email, alias string, at time.Time) error
+}
+
+// MongoPrivacyRepository implements PrivacyRepository over the collections
+// of db.
+type MongoPrivacyRepository struct {
+	db *Database
+}
+
+// NewMongoPrivacyRepository creates a repository over db.
+func NewMongoPrivacyRepository(db *Database) *MongoPrivacyRepository {
+	return &MongoPrivacyRepository{db: db}
+}
+
+// Comments implements PrivacyRepository.
+func (m *MongoPrivacyRepository) Comments(ctx context.Context, bookingIDs []primitive.ObjectID) ([]Review, error) {
+	if len(bookingIDs) == 0 {
+		return nil, nil
+	}
+	cursor, err := m.db.Collection("reviews").Find(ctx, bson.M{"bookingId": bson.M{"$in": bookingIDs}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reviews []Review
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// Activity implements PrivacyRepository.
+func (m *MongoPrivacyRepository) Activity(ctx context.Context, keys []string) ([]events.Event, error) {
+	messages, err := m.activity(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	activity := make([]events.Event, 0, len(messages))
+	for _, msg := range messages {
+		activity = append(activity, msg.Event)
+	}
+	return activity, nil
+}
+
+func (m *MongoPrivacyRepository) activity(ctx context.Context, keys []string) ([]OutboxMessage, error) {
+	cursor, err := m.db.Collection("outbox").Find(ctx, bson.M{"event.key": bson.M{"$in": keys}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []OutboxMessage
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// EraseComments implements PrivacyRepository.
+func (m *MongoPrivacyRepository) EraseComments(ctx context.Context, bookingIDs []primitive.ObjectID) (int64, error) {
+	if len(bookingIDs) == 0 {
+		return 0, nil
+	}
+	res, err := m.db.Collection("reviews").UpdateMany(ctx,
+		bson.M{"bookingId": bson.M{"$in": bookingIDs}, "comment": bson.M{"$ne": ""}},
+		bson.M{"$set": bson.M{"comment": ""}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// AnonymizeActivity implements PrivacyRepository. The event data is
+// encoded JSON, so the email is replaced in its bytes one message at a time.
+func (m *MongoPrivacyRepository) AnonymizeActivity(ctx context.Context, keys []string, email, alias string) (int64, error) {
+	messages, err := m.activity(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+	var anonymized int64
+	for _, msg := range messages {
+		event, changed := anonymizeEvent(msg.Event, email, alias)
+		if !changed {
+			continue
+		}
+		if _, err := m.db.Collection("outbox").UpdateOne(ctx, bson.M{"_id": msg.ID}, bson.M{
+			"$set": bson.M{"event.key": event.Key, "event.data": event.Data},
+		}); err != nil {
+			return anonymized, err
+		}
+		anonymized++
+	}
+	return anonymized, nil
+}
+
+// AnonymizeBookings implements PrivacyRepository.
+func (m *MongoPrivacyRepository) AnonymizeBookings(ctx context.Context, email, alias string) (int64, error) {
+	res, err := m.db.Collection("bookings").UpdateMany(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"email": alias}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// DeleteTodos implements PrivacyRepository, trashed todos included. The
+// comments, attachments and notifications of the todos go with them; on
+// the todos of other users it removes the reactions, comments,
+// attachments, mentions and notifications of email. The todos are deleted last, so a retry finds
+// them again. The archived todos go the same way.
+func (m *MongoPrivacyRepository) DeleteTodos(ctx context.Context, email string) (int64, error) {
+	ids, err := m.db.Collection("todos").Distinct(ctx, "_id", bson.M{"email": email})
+	if err != nil {
+		return 0, err
+	}
+	archived, err := m.db.Collection(TodoArchiveCollection).Distinct(ctx, "_id", bson.M{"email": email})
+	if err != nil {
+		return 0, err
+	}
+	ids = append(ids, archived...)
+	ofTodos := bson.M{"todoId": bson.M{"$in": ids}}
+	comments := m.db.Collection("todo_comments")
+	if _, err := comments.DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"email": email}, ofTodos}}); err != nil {
+		return 0, err
+	}
+	if _, err := comments.UpdateMany(ctx, bson.M{"mentions": email}, bson.M{"$pull": bson.M{"mentions": email}}); err != nil {
+		return 0, err
+	}
+	if _, err := m.db.Collection("todo_attachments").DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"email": email}, ofTodos}}); err != nil {
+		return 0, err
+	}
+	_, err = m.db.Collection("notifications").DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"email": email}, bson.M{"author": email}, ofTodos}})
+	if err != nil {
+		return 0, err
+	}
+	for _, collection := range []string{"todos", TodoArchiveCollection} {
+		_, err = m.db.Collection(collection).UpdateMany(ctx,
+			bson.M{"reactions.email": email},
+			bson.M{"$pull": bson.M{"reactions": bson.M{"email": email}}})
+		if err != nil {
+			return 0, err
+		}
+		_, err = m.db.Collection(collection).UpdateMany(ctx, bson.M{"assignee": email}, bson.M{"$unset": bson.M{"assignee": ""}})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := m.leaveLists(ctx, email); err != nil {
+		return 0, err
+	}
+	deleted, err := m.deleteMany(ctx, TodoArchiveCollection, email)
+	if err != nil {
+		return 0, err
+	}
+	live, err := m.deleteMany(ctx, "todos", email)
+	return deleted + live, err
+}
+
+// leaveLists takes email out of the shared lists; the lists left without
+// members are deleted and their todos taken out of them.
+func (m *MongoPrivacyRepository) leaveLists(ctx context.Context, email string) error {
+	lists := m.db.Collection("todo_lists")
+	_, err := lists.UpdateMany(ctx, bson.M{"members.email": email}, bson.M{"$pull": bson.M{"members": bson.M{"email": email}}})
+	if err != nil {
+		return err
+	}
+	empty := bson.M{"members": bson.M{"$size": 0}}
+	ids, err := lists.Distinct(ctx, "_id", empty)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	for _, collection := range []string{"todos", TodoArchiveCollection} {
+		_, err = m.db.Collection(collection).UpdateMany(ctx, bson.M{"listId": bson.M{"$in": ids}}, bson.M{"$unset": bson.M{"listId": ""}})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = lists.DeleteMany(ctx, empty)
+	return err
+}
+
+// DeleteSessions implements PrivacyRepository.
+func (m *MongoPrivacyRepository) DeleteSessions(ctx context.Context, email string) (int64, error) {
+	return m.deleteMany(ctx, "sessions", email)
+}
+
+// DeleteUser implements PrivacyRepository.
+func (m *MongoPrivacyRepository) DeleteUser(ctx context.Context, email string) error {
+	if _, err := m.deleteMany(ctx, "passkeys", email); err != nil {
+		return err
+	}
+	_, err := m.deleteMany(ctx, "users", email)
+	return err
+}
+
+// AnonymizeTodos implements PrivacyRepository, archived todos included.
+// The todos are renamed last, so a retry finds them again.
+func (m *MongoPrivacyRepository) AnonymizeTodos(ctx context.Context, email, alias string) (int64, error) {
+	rename := func(collection string, filter, update bson.M, opts ...*options.UpdateOptions) error {
+		_, err := m.db.Collection(collection).UpdateMany(ctx, filter, update, opts...)
+		return err
+	}
+	var archived int64
+	err := runSteps(
+		func() error {
+			return rename("todos", bson.M{"reactions.email": email}, bson.M{"$set": bson.M{"reactions.$[r].email": alias}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"r.email": email}}}))
+		},
+		func() error {
+			return rename(TodoArchiveCollection, bson.M{"reactions.email": email}, bson.M{"$set": bson.M{"reactions.$[r].email": alias}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"r.email": email}}}))
+		},
+		func() error {
+			return rename("todos", bson.M{"assignee": email}, bson.M{"$set": bson.M{"assignee": alias}})
+		},
+		func() error {
+			return rename(TodoArchiveCollection, bson.M{"assignee": email}, bson.M{"$set": bson.M{"assignee": alias}})
+		},
+		func() error {
+			res, err := m.db.Collection(TodoArchiveCollection).UpdateMany(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"email": alias}})
+			if err == nil {
+				archived = res.ModifiedCount
+			}
+			return err
+		},
+		func() error {
+			return rename("todo_comments", bson.M{"email": email}, bson.M{"$set": bson.M{"email": alias}})
+		},
+		func() error {
+			return rename("todo_comments", bson.M{"mentions": email}, bson.M{"$set": bson.M{"mentions.$[m]": alias}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"m": email}}}))
+		},
+		func() error {
+			return rename("todo_attachments", bson.M{"email": email}, bson.M{"$set": bson.M{"email": alias}})
+		},
+		func() error {
+			return rename("todo_lists", bson.M{"members.email": email}, bson.M{"$set": bson.M{"members.$.email": alias}})
+		},
+		func() error {
+			_, err := m.db.Collection("notifications").DeleteMany(ctx, bson.M{"$or": bson.A{bson.M{"email": email}, bson.M{"author": email}}})
+			return err
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	res, err := m.db.Collection("todos").UpdateMany(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"email": alias}})
+	if err != nil {
+		return 0, err
+	}
+	return archived + res.ModifiedCount, nil
+}
+
+// AnonymizeUser implements PrivacyRepository.
+func (m *MongoPrivacyRepository) AnonymizeUser(ctx context.Context, email, alias string, at time.Time) error {
+	if _, err := m.deleteMany(ctx, "passkeys", email); err != nil {
+		return err
+	}
+	_, err := m.db.Collection("users").UpdateOne(ctx, bson.M{"email": email}, bson.M{
+		"$set":   bson.M{"email": alias, "password": "", "anonymizedAt": at},
+		"$unset": bson.M{"feedToken": "", "quota": ""},
+	})
+	return err
+}
+
+func (m *MongoPrivacyRepository) deleteMany(ctx context.Context, collection, email string) (int64, error) {
+	res, err := m.db.Collection(collection).DeleteMany(ctx, bson.M{"email": email})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// anonymizeEvent replaces email with alias in the key and the data of
+// event, and reports whether anything changed.
+func anonymizeEvent(event events.Event, email, alias string) (events.Event, bool) {
+	changed := false
+	if event.Key == email {
+		event.Key, changed = alias, true
+	}
+	if data := bytes.ReplaceAll(event.Data, []byte(email), []byte(alias)); !bytes.Equal(data, event.Data) {
+		event.Data, changed = data, true
+	}
+	return event, changed
+}
+
+// ErasureAlias is the pseudonym that replaces email in the records kept for
+// auditing. It is stable, so the records of one account stay related.
+func ErasureAlias(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return "erased-" + hex.EncodeToString(sum[:8]) + "@anonymized.invalid"
+}
+
+// ErasureRepository is the storage contract of the erasure runs.
+type ErasureRepository interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (Erasure, error)
+	Create(ctx context.Context, erasure Erasure) (Erasure, error)
+	// Save replaces the stored erasure with erasure.
+	Save(ctx context.Context, erasure Erasure) error
+}
+
+// MongoErasureRepository implements ErasureRepository backed by MongoDB.
+type MongoErasureRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoErasureRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoErasureRepository(collection *mongo.Collection) *MongoErasureRepository {
+	return &MongoErasureRepository{collection: collection}
+}
+
+// FindByID retrieves an erasure or returns ErrNotFound.
+func (m *MongoErasureRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Erasure, error) {
+	var erasure Erasure
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&erasure)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Erasure{}, ErrNotFound
+	}
+	return erasure, err
+}
+
+// Create stores an erasure and returns it with the generated ID.
+func (m *MongoErasureRepository) Create(ctx context.Context, erasure Erasure) (Erasure, error) {
+	res, err := m.collection.InsertOne(ctx, erasure)
+	if err != nil {
+		return Erasure{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		erasure.ID = oid
+	}
+	return erasure, nil
+}
+
+// Save replaces the erasure document.
+func (m *MongoErasureRepository) Save(ctx context.Context, erasure Erasure) error {
+	res, err := m.collection.ReplaceOne(ctx, bson.M{"_id": erasure.ID}, erasure)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PrivacyService exports the data of an account and erases it on request
+// (GDPR). Erasing deletes the account, its sessions and its todos, blanks
+// its review comments and replaces its email with ErasureAlias in the
+// bookings and events kept for auditing.
+type PrivacyService struct {
+	repo     PrivacyRepository
+	erasures ErasureRepository
+	users    UserRepository
+	todos    TodoRepository
+	bookings BookingRepository
+	logins   LoginRepository
+	outbox   Outbox
+	now      func() time.Time
+	ids      IDGenerator
+}
+
+// NewPrivacyService builds a new PrivacyService instance.
+func NewPrivacyService(repo PrivacyRepository, erasures ErasureRepository, users UserRepository, todos TodoRepository, bookings BookingRepository, logins LoginRepository, outbox Outbox, now func() time.Time, ids IDGenerator) *PrivacyService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &PrivacyService{repo: repo, erasures: erasures, users: users, todos: todos, bookings: bookings, logins: logins, outbox: outbox, now: now, ids: ids}
+}
+
+// accountRecords are the todos and bookings of an account, which the
+// export reads and the erasure removes.
+type accountRecords struct {
+	todos    []Todo
+	bookings []Booking
+}
+
+func (r accountRecords) bookingIDs() []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(r.bookings))
+	for _, booking := range r.bookings {
+		ids = append(ids, booking.ID)
+	}
+	return ids
+}
+
+// activityKeys are the event keys of the account: its email and the IDs of
+// its todos and bookings.
+func (r accountRecords) activityKeys(email string) []string {
+	keys := make([]string, 0, 1+len(r.todos)+len(r.bookings))
+	keys = append(keys, email)
+	for _, todo := range r.todos {
+		keys = append(keys, todo.ID.Hex())
+	}
+	for _, booking := range r.bookings {
+		keys = append(keys, booking.ID.Hex())
+	}
+	return keys
+}
+
+func (s *PrivacyService) records(ctx context.Context, email string) (accountRecords, error) {
+	live, err := s.todos.List(ctx, TodoQuery{Email: email})
+	if err != nil {
+		return accountRecords{}, err
+	}
+	trashed, err := s.todos.List(ctx, TodoQuery{Email: email, Trashed: true})
+	if err != nil {
+		return accountRecords{}, err
+	}
+	bookings, err := s.bookings.List(ctx, BookingQuery{Email: email})
+	if err != nil {
+		return accountRecords{}, err
+	}
+	return accountRecords{todos: append(live, trashed...), bookings: bookings}, nil
+}
+
+// Export gathers the data kept about the account of email.
+func (s *PrivacyService) Export(ctx context.Context, email string) (AccountExport, error) {
+	email = NormalizeEmail(email)
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	records, err := s.records(ctx, email)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	reviews, err := s.repo.Comments(ctx, records.bookingIDs())
+	if err != nil {
+		return AccountExport{}, err
+	}
+	activity, err := s.repo.Activity(ctx, records.activityKeys(email))
+	if err != nil {
+		return AccountExport{}, err
+	}
+	logins, err := s.logins.List(ctx, email, 0)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	export := AccountExport{
+		ExportedAt: s.now(),
+		Account:    AccountRecord{Email: user.Email, Role: user.Role, CreatedAt: user.CreatedAt, Quota: user.Quota},
+		Todos:      make([]TodoResponse, 0, len(records.todos)),
+		Comments:   make([]ReviewResponse, 0, len(reviews)),
+		Activity:   activity,
+		Logins:     make([]LoginResponse, 0, len(logins)),
+	}
+	if user.PropertyID != nil {
+		export.Account.PropertyID = user.PropertyID.Hex()
+	}
+	for _, todo := range records.todos {
+		export.Todos = append(export.Todos, todo.ToResponse())
+	}
+	for _, review := range reviews {
+		export.Comments = append(export.Comments, review.ToResponse())
+	}
+	for _, login := range logins {
+		export.Logins = append(export.Logins, login.ToResponse())
+	}
+	if export.Activity == nil {
+		export.Activity = []events.Event{}
+	}
+	return export, nil
+}
+
+// Erase records an erasure of the account of email and runs it. Accounts
+// with up to ErasureSyncLimit todos and bookings are erased before
+// returning; larger ones in the background, so the returned erasure is
+// still running.
+func (s *PrivacyService) Erase(ctx context.Context, email string) (ErasureResponse, error) {
+	email = NormalizeEmail(email)
+	if _, err := s.users.FindByEmail(ctx, email); err != nil {
+		return ErasureResponse{}, err
+	}
+	records, err := s.records(ctx, email)
+	if err != nil {
+		return ErasureResponse{}, err
+	}
+
+	erasure, err := s.erasures.Create(ctx, Erasure{ID: s.ids.NewID(), Status: ErasureRunning, CreatedAt: s.now()})
+	if err != nil {
+		return ErasureResponse{}, err
+	}
+	if len(records.todos)+len(records.bookings) > ErasureSyncLimit {
+		go s.process(context.WithoutCancel(ctx), erasure, email, records)
+		return erasure.ToResponse(), nil
+	}
+	return s.process(ctx, erasure, email, records).ToResponse(), nil
+}
+
+// GetErasure returns the status of an erasure.
+func (s *PrivacyService) GetErasure(ctx context.Context, id string) (ErasureResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErasureResponse{}, ErrInvalidErasureID
+	}
+	erasure, err := s.erasures.FindByID(ctx, objID)
+	if err != nil {
+		return ErasureResponse{}, err
+	}
+	return erasure.ToResponse(), nil
+}
+
+// process runs the erasure steps. Comments and events are found through
+// the bookings and todos, so they go before them; the account goes last so
+// a failed erasure can be requested again.
+func (s *PrivacyService) process(ctx context.Context, erasure Erasure, email string, records accountRecords) Erasure {
+	alias := ErasureAlias(email)
+	err := runSteps(
+		func() (err error) {
+			erasure.Comments, err = s.repo.EraseComments(ctx, records.bookingIDs())
+			return err
+		},
+		func() (err error) {
+			erasure.Activity, err = s.repo.AnonymizeActivity(ctx, records.activityKeys(email), email, alias)
+			return err
+		},
+		func() (err error) {
+			erasure.Bookings, err = s.repo.AnonymizeBookings(ctx, email, alias)
+			return err
+		},
+		func() (err error) {
+			erasure.Todos, err = s.repo.DeleteTodos(ctx, email)
+			return err
+		},
+		func() (err error) {
+			erasure.Sessions, err = s.repo.DeleteSessions(ctx, email)
+			return err
+		},
+		func() (err error) {
+			erasure.Logins, err = s.logins.Delete(ctx, email)
+			return err
+		},
+		func() error { return s.repo.DeleteUser(ctx, email) },
+	)
+
+	finished := s.now()
+	erasure.Status, erasure.FinishedAt = ErasureCompleted, &finished
+	if err != nil {
+		log.Printf("no se pudo completar el borrado %s: %v", erasure.ID.Hex(), err)
+		erasure.Status, erasure.Error = ErasureFailed, "internal_error"
+	}
+	if err := s.erasures.Save(ctx, erasure); err != nil {
+		log.Printf("no se pudo guardar el resultado del borrado %s: %v", erasure.ID.Hex(), err)
+	}
+	return erasure
+}
+
+// Anonymization counts what DELETE /users?mode=anonymize kept under an
+// alias instead of deleting it.
+type Anonymization struct {
+	Users    int64 `json:"users" xml:"users"`
+	Todos    int64 `json:"todos" xml:"todos"`
+	Bookings int64 `json:"bookings" xml:"bookings"`
+	Activity int64 `json:"activity" xml:"activity"`
+}
+
+// AnonymizeUsers is the soft alternative to UserService.Clear: every
+// account is replaced with a tombstone under its ErasureAlias, which also
+// replaces the email on its todos, bookings and events, so the statistics
+// and the audit trail keep adding up. Sessions, login history, review
+// comments and notifications are deleted. Each account is recorded with a
+// user.anonymized event keyed by its alias; the accounts anonymized before
+// are skipped.
+func (s *PrivacyService) AnonymizeUsers(ctx context.Context) (Anonymization, error) {
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return Anonymization{}, err
+	}
+	var result Anonymization
+	for _, user := range users {
+		if user.AnonymizedAt != nil {
+			continue
+		}
+		if err := s.anonymize(ctx, user.Email, &result); err != nil {
+			return result, err
+		}
+		result.Users++
+	}
+	return result, nil
+}
+
+// anonymize runs the steps of AnonymizeUsers for one account, in the order
+// of process: the account goes last, together with its event.
+func (s *PrivacyService) anonymize(ctx context.Context, email string, result *Anonymization) error {
+	records, err := s.records(ctx, email)
+	if err != nil {
+		return err
+	}
+	alias := ErasureAlias(email)
+	var todos, bookings int64
+	err = runSteps(
+		func() error {
+			_, err := s.repo.EraseComments(ctx, records.bookingIDs())
+			return err
+		},
+		func() error {
+			activity, err := s.repo.AnonymizeActivity(ctx, records.activityKeys(email), email, alias)
+			result.Activity += activity
+			return err
+		},
+		func() (err error) {
+			bookings, err = s.repo.AnonymizeBookings(ctx, email, alias)
+			return err
+		},
+		func() (err error) {
+			todos, err = s.repo.AnonymizeTodos(ctx, email, alias)
+			return err
+		},
+		func() error {
+			_, err := s.repo.DeleteSessions(ctx, email)
+			return err
+		},
+		func() error {
+			_, err := s.logins.Delete(ctx, email)
+			return err
+		},
+		func() error {
+			return s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+				now := s.now()
+				if err := s.repo.AnonymizeUser(ctx, email, alias, now); err != nil {
+					return nil, err
+				}
+				return newEvents(events.UserAnonymized, alias, map[string]int64{"todos": todos, "bookings": bookings}, now)
+			})
+		},
+	)
+	result.Todos += todos
+	result.Bookings += bookings
+	return err
+}
+
+// runSteps calls steps in order and stops at the first error.
+func runSteps(steps ...func() error) error {
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidPropertyInput indicates missing or malformed property data.
+	ErrInvalidPropertyInput = errors.New("invalid property input")
+	// ErrInvalidPropertyID indicates the property ID could not be parsed.
+	ErrInvalidPropertyID = errors.New("invalid property id")
+	// ErrPropertyNotFound is returned when the referenced property does not exist.
+	ErrPropertyNotFound = errors.New("property not found")
+	// ErrPropertyCodeTaken is returned when another property uses the code.
+	ErrPropertyCodeTaken = errors.New("property code already exists")
+)
+
+type propertyScopeKey struct{}
+
+// WithProperty scopes ctx to one property: rooms, bookings, todos and
+// reports read through services with that context only see the documents
+// of the property, and new rooms are created in it.
+func WithProperty(ctx context.Context, id primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, propertyScopeKey{}, id)
+}
+
+// ScopedProperty returns the property ctx is scoped to, or the zero ID when
+// it sees every property.
+func ScopedProperty(ctx context.Context) primitive.ObjectID {
+	id, _ := ctx.Value(propertyScopeKey{}).(primitive.ObjectID)
+	return id
+}
+
+// inScope reports whether a document of propertyID is visible from ctx.
+func inScope(ctx context.Context, propertyID *primitive.ObjectID) bool {
+	scope := ScopedProperty(ctx)
+	return scope.IsZero() || (propertyID != nil && *propertyID == scope)
+}
+
+// PropertyRepository is the storage contract required by the property service.
+type PropertyRepository interface {
+	List(ctx context.Context) ([]Property, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (Property, error)
+	Create(ctx context.Context, property Property) (Property, error)
+	// SetSSO replaces the identity provider of a property (nil removes it)
+	// and returns the property, or ErrNotFound.
+	SetSSO(ctx context.Context, id primitive.ObjectID, sso *PropertySSO) (Property, error)
+	// SetSCIM replaces the SCIM token of a property (nil removes it) and
+	// returns the property, or ErrNotFound.
+	SetSCIM(ctx context.Context, id primitive.ObjectID, scim *PropertySCIM) (Property, error)
+	// FindBySCIMToken returns the property with the token hash, or
+	// ErrNotFound.
+	FindBySCIMToken(ctx context.Context, tokenHash string) (Property, error)
+}
+
+// MongoPropertyRepository implements PropertyRepository backed by MongoDB.
+type MongoPropertyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoPropertyRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoPropertyRepository(collection *mongo.Collection) *MongoPropertyRepository {
+	return &MongoPropertyRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique indexes on the property code and on
+// the SCIM token.
+func (m *MongoPropertyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true).SetName("code_unique")},
+		{Keys: bson.D{{Key: "scim.tokenHash", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true).SetName("scim_token_unique")},
+	})
+	return err
+}
+
+// List returns every property ordered by code.
+func (m *MongoPropertyRepository) List(ctx context.Context) ([]Property, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"code": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var properties []Property
+	if err := cursor.All(ctx, &properties); err != nil {
+		return nil, err
+	}
+	return properties, nil
+}
+
+// FindByID retrieves a property or returns ErrNotFound.
+func (m *MongoPropertyRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Property, error) {
+	var property Property
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&property)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Property{}, ErrNotFound
+	}
+	return property, err
+}
+
+// Create stores a property and returns it with the generated ID.
+func (m *MongoPropertyRepository) Create(ctx context.Context, property Property) (Property, error) {
+	res, err := m.collection.InsertOne(ctx, property)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Property{}, ErrPropertyCodeTaken
+		}
+		return Property{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		property.ID = oid
+	}
+	return property, nil
+}
+
+// SetSSO implements PropertyRepository.
+func (m *MongoPropertyRepository) SetSSO(ctx context.Context, id primitive.ObjectID, sso *PropertySSO) (Property, error) {
+	update := bson.M{"$set": bson.M{"sso": sso}}
+	if sso == nil {
+		update = bson.M{"$unset": bson.M{"sso": ""}}
+	}
+	var property Property
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&property)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Property{}, ErrNotFound
+	}
+	return property, err
+}
+
+// SetSCIM implements PropertyRepository.
+func (m *MongoPropertyRepository) SetSCIM(ctx context.Context, id primitive.ObjectID, scim *PropertySCIM) (Property, error) {
+	update := bson.M{"$set": bson.M{"scim": scim}}
+	if scim == nil {
+		update = bson.M{"$unset": bson.M{"scim": ""}}
+	}
+	var property Property
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&property)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Property{}, ErrNotFound
+	}
+	return property, err
+}
+
+// FindBySCIMToken implements PropertyRepository.
+func (m *MongoPropertyRepository) FindBySCIMToken(ctx context.Context, tokenHash string) (Property, error) {
+	var property Property
+	err := m.collection.FindOne(ctx, bson.M{"scim.tokenHash": tokenHash}).Decode(&property)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Property{}, ErrNotFound
+	}
+	return property, err
+}
+
+// PropertyService manages the hotels of the chain and the property staff
+// members belong to.
+type PropertyService struct {
+	properties PropertyRepository
+	users      UserRepository
+	now        func() time.Time
+	ids        IDGenerator
+
+	// known caches the IDs confirmed by Resolve; properties are never
+	// deleted, so entries do not go stale.
+	mu    sync.RWMutex
+	known map[primitive.ObjectID]bool
+}
+
+// NewPropertyService builds a new PropertyService instance.
+func NewPropertyService(properties PropertyRepository, users UserRepository, now func() time.Time, ids IDGenerator) *PropertyService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &PropertyService{properties: properties, users: users, now: now, ids: ids, known: make(map[primitive.ObjectID]bool)}
+}
+
+// List returns every property.
+func (s *PropertyService) List(ctx context.Context) ([]PropertyResponse, error) {
+	properties, err := s.properties.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]PropertyResponse, 0, len(properties))
+	for _, property := range properties {
+		responses = append(responses, property.ToResponse())
+	}
+	return responses, nil
+}
+
+// Create validates input and stores a new property; codes are uppercased
+// and must be unique.
+func (s *PropertyService) Create(ctx context.Context, property Property) (PropertyResponse, error) {
+	property.Code = strings.ToUpper(NormalizeText(property.Code))
+	property.Name = NormalizeText(property.Name)
+	property.City = NormalizeText(property.City)
+	if property.Code == "" || property.Name == "" {
+		return PropertyResponse{}, ErrInvalidPropertyInput
+	}
+
+	property.ID = s.ids.NewID()
+	property.CreatedAt = s.now()
+	created, err := s.properties.Create(ctx, property)
+	if err != nil {
+		return PropertyResponse{}, err
+	}
+	return created.ToResponse(), nil
+}
+
+// Resolve parses a property ID and checks that the property exists.
+func (s *PropertyService) Resolve(ctx context.Context, id string) (primitive.ObjectID, error) {
+	objID, err := primitive.ObjectIDFromHex(NormalizeText(id))
+	if err != nil {
+		return primitive.NilObjectID, ErrInvalidPropertyID
+	}
+
+	s.mu.RLock()
+	known := s.known[objID]
+	s.mu.RUnlock()
+	if known {
+		return objID, nil
+	}
+
+	_, err = s.properties.FindByID(ctx, objID)
+	if errors.Is(err, ErrNotFound) {
+		return primitive.NilObjectID, ErrPropertyNotFound
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	s.mu.Lock()
+	s.known[objID] = true
+	s.mu.Unlock()
+	return objID, nil
+}
+
+// AssignStaff makes a user a member of one property; an empty ID lets the
+// user work for every property again.
+func (s *PropertyService) AssignStaff(ctx context.Context, email, propertyID string) (PublicUser, error) {
+	var id *primitive.ObjectID
+	if NormalizeText(propertyID) != "" {
+		objID, err := s.Resolve(ctx, propertyID)
+		if err != nil {
+			return PublicUser{}, err
+		}
+		id = &objID
+	}
+
+	user, err := s.users.SetProperty(ctx, NormalizeEmail(email), id)
+	if err != nil {
+		return PublicUser{}, err
+	}
+	return user.ToPublic(), nil
+}
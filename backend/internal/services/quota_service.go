@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrQuotaExceeded is returned when an account reached one of its limits.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInvalidQuota indicates a negative limit in a quota override.
+	ErrInvalidQuota = errors.New("invalid quota")
+)
+
+// Limits caps what one account may store; zero means unlimited.
+type Limits struct {
+	MaxTodos int
+}
+
+// QuotaOverride replaces the plan limits of one account; nil fields keep
+// the plan limit and zero lifts it.
+type QuotaOverride struct {
+	MaxTodos *int `json:"maxTodos,omitempty" bson:"maxTodos,omitempty"`
+}
+
+func (o QuotaOverride) empty() bool {
+	return o.MaxTodos == nil
+}
+
+// QuotaUsage is how much of one limit an account uses; Limit is nil when
+// there is none.
+type QuotaUsage struct {
+	Used  int64 `json:"used" xml:"used"`
+	Limit *int  `json:"limit" xml:"limit,omitempty"`
+}
+
+// AccountUsage reports the usage of every limit of an account and whether
+// an administrator overrode the plan limits.
+type AccountUsage struct {
+	Email      string     `json:"email" xml:"email"`
+	Todos      QuotaUsage `json:"todos" xml:"todos"`
+	Overridden bool       `json:"overridden" xml:"overridden"`
+}
+
+// QuotaService enforces the plan limits, with the overrides stored on each
+// account.
+type QuotaService struct {
+	users UserRepository
+	todos TodoRepository
+
+	mu     sync.RWMutex
+	limits Limits
+}
+
+// NewQuotaService builds a new QuotaService instance; limits are the plan
+// limits of every account without an override.
+func NewQuotaService(users UserRepository, todos TodoRepository, limits Limits) *QuotaService {
+	return &QuotaService{users: users, todos: todos, limits: limits}
+}
+
+// SetLimits replaces the plan limits, e.g. when the configuration is
+// reloaded. Overrides are kept.
+func (s *QuotaService) SetLimits(limits Limits) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = limits
+}
+
+// limitsFor returns the limits of email and whether they were overridden.
+// Todos may belong to emails without an account, which get the plan limits.
+func (s *QuotaService) limitsFor(ctx context.Context, email string) (Limits, bool, error) {
+	s.mu.RLock()
+	limits := s.limits
+	s.mu.RUnlock()
+	user, err := s.users.FindByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) || (err == nil && user.Quota == nil) {
+		return limits, false, nil
+	}
+	if err != nil {
+		return Limits{}, false, err
+	}
+	if user.Quota.MaxTodos != nil {
+		limits.MaxTodos = *user.Quota.MaxTodos
+	}
+	return limits, true, nil
+}
+
+// AllowTodo returns ErrQuotaExceeded when email cannot create another todo.
+// Todos in the trash do not count.
+func (s *QuotaService) AllowTodo(ctx context.Context, email string) error {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return nil
+	}
+	limits, _, err := s.limitsFor(ctx, email)
+	if err != nil || limits.MaxTodos == 0 {
+		return err
+	}
+	used, err := s.todos.Count(ctx, TodoQuery{Email: email})
+	if err != nil {
+		return err
+	}
+	if used >= int64(limits.MaxTodos) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Usage reports the usage and limits of email.
+func (s *QuotaService) Usage(ctx context.Context, email string) (AccountUsage, error) {
+	email = NormalizeEmail(email)
+	limits, overridden, err := s.limitsFor(ctx, email)
+	if err != nil {
+		return AccountUsage{}, err
+	}
+	todos, err := s.todos.Count(ctx, TodoQuery{Email: email})
+	if err != nil {
+		return AccountUsage{}, err
+	}
+	return AccountUsage{
+		Email:      email,
+		Todos:      QuotaUsage{Used: todos, Limit: limitOrNil(limits.MaxTodos)},
+		Overridden: overridden,
+	}, nil
+}
+
+// SetOverride replaces the quota override of an account and returns its
+// usage; an override without limits restores the plan limits.
+func (s *QuotaService) SetOverride(ctx context.Context, email string, override QuotaOverride) (AccountUsage, error) {
+	if override.MaxTodos != nil && *override.MaxTodos < 0 {
+		return AccountUsage{}, ErrInvalidQuota
+	}
+	var quota *QuotaOverride
+	if !override.empty() {
+		quota = &override
+	}
+	user, err := s.users.SetQuota(ctx, NormalizeEmail(email), quota)
+	if err != nil {
+		return AccountUsage{}, err
+	}
+	return s.Usage(ctx, user.Email)
+}
+
+func limitOrNil(limit int) *int {
+	if limit == 0 {
+		return nil
+	}
+	return &limit
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidRatePlanInput indicates missing or malformed rate plan data.
+	ErrInvalidRatePlanInput = errors.New("invalid rate plan input")
+	// ErrInvalidRatePlanID indicates the rate plan ID could not be parsed.
+	ErrInvalidRatePlanID = errors.New("invalid rate plan id")
+)
+
+// RatePlanInput carries the raw rate plan fields received from clients.
+type RatePlanInput struct {
+	Name         string
+	RoomType     string
+	StartDate    string
+	EndDate      string
+	Price        float64
+	WeekendPrice *float64
+	Discounts    []StayDiscount
+	Priority     int
+}
+
+// RatePlanRepository is the storage contract required by the rate service.
+type RatePlanRepository interface {
+	List(ctx context.Context) ([]RatePlan, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (RatePlan, error)
+	Create(ctx context.Context, plan RatePlan) (RatePlan, error)
+	Replace(ctx context.Context, plan RatePlan) (RatePlan, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoRatePlanRepository implements RatePlanRepository backed by MongoDB.
+type MongoRatePlanRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRatePlanRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoRatePlanRepository(collection *mongo.Collection) *MongoRatePlanRepository {
+	return &MongoRatePlanRepository{collection: collection}
+}
+
+// List returns every rate plan, highest priority first.
+func (m *MongoRatePlanRepository) List(ctx context.Context) ([]RatePlan, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var plans []RatePlan
+	if err := cursor.All(ctx, &plans); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// FindByID retrieves a rate plan or returns ErrNotFound.
+func (m *MongoRatePlanRepository) FindByID(ctx context.Context, id primitive.ObjectID) (RatePlan, error) {
+	var plan RatePlan
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&plan)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return RatePlan{}, ErrNotFound
+	}
+	return plan, err
+}
+
+// Create stores a rate plan and returns it with the generated ID.
+func (m *MongoRatePlanRepository) Create(ctx context.Context, plan RatePlan) (RatePlan, error) {
+	res, err := m.collection.InsertOne(ctx, plan)
+	if err != nil {
+		return RatePlan{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		plan.ID = oid
+	}
+	return plan, nil
+}
+
+// Replace overwrites a rate plan keeping its ID.
+func (m *MongoRatePlanRepository) Replace(ctx context.Context, plan RatePlan) (RatePlan, error) {
+	res, err := m.collection.ReplaceOne(ctx, bson.M{"_id": plan.ID}, plan)
+	if err != nil {
+		return RatePlan{}, err
+	}
+	if res.MatchedCount == 0 {
+		return RatePlan{}, ErrNotFound
+	}
+	return plan, nil
+}
+
+// Delete removes a rate plan by ID.
+func (m *MongoRatePlanRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RateService manages rate plans and prices stays.
+type RateService struct {
+	repo RatePlanRepository
+	now  func() time.Time
+	ids  IDGenerator
+}
+
+// NewRateService builds a new RateService instance.
+func NewRateService(repo RatePlanRepository, now func() time.Time, ids IDGenerator) *RateService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &RateService{repo: repo, now: now, ids: ids}
+}
+
+// List returns every rate plan.
+func (s *RateService) List(ctx context.Context) ([]RatePlanResponse, error) {
+	plans, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]RatePlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		responses = append(responses, plan.ToResponse())
+	}
+	return responses, nil
+}
+
+// Get returns a single rate plan.
+func (s *RateService) Get(ctx context.Context, id string) (RatePlanResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return RatePlanResponse{}, ErrInvalidRatePlanID
+	}
+
+	plan, err := s.repo.FindByID(ctx, objID)
+	if err != nil {
+		return RatePlanResponse{}, err
+	}
+	return plan.ToResponse(), nil
+}
+
+// Create validates input and stores a new rate plan.
+func (s *RateService) Create(ctx context.Context, input RatePlanInput) (RatePlanResponse, error) {
+	plan, err := newRatePlan(input)
+	if err != nil {
+		return RatePlanResponse{}, err
+	}
+	plan.ID = s.ids.NewID()
+	plan.CreatedAt = s.now()
+
+	created, err := s.repo.Create(ctx, plan)
+	if err != nil {
+		return RatePlanResponse{}, err
+	}
+	return created.ToResponse(), nil
+}
+
+// Replace validates input and overwrites an existing rate plan.
+func (s *RateService) Replace(ctx context.Context, id string, input RatePlanInput) (RatePlanResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return RatePlanResponse{}, ErrInvalidRatePlanID
+	}
+
+	current, err := s.repo.FindByID(ctx, objID)
+	if err != nil {
+		return RatePlanResponse{}, err
+	}
+
+	plan, err := newRatePlan(input)
+	if err != nil {
+		return RatePlanResponse{}, err
+	}
+	plan.ID = current.ID
+	plan.CreatedAt = current.CreatedAt
+
+	replaced, err := s.repo.Replace(ctx, plan)
+	if err != nil {
+		return RatePlanResponse{}, err
+	}
+	return replaced.ToResponse(), nil
+}
+
+// Delete removes a rate plan by ID. Existing bookings keep their quote.
+func (s *RateService) Delete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidRatePlanID
+	}
+	return s.repo.Delete(ctx, objID)
+}
+
+// QuoteRoom prices every night of the stay with the highest priority rate
+// plan that matches the room type and the night, falling back to the room
+// base price. The best length-of-stay discount among the plans used is then
+// applied to the subtotal.
+func (s *RateService) QuoteRoom(ctx context.Context, room Room, checkIn, checkOut time.Time) (Quote, error) {
+	plans, err := s.repo.List(ctx)
+	if err != nil {
+		return Quote{}, err
+	}
+	sort.SliceStable(plans, func(i, j int) bool { return plans[i].Priority > plans[j].Priority })
+
+	quote := Quote{}
+	used := make(map[primitive.ObjectID]RatePlan)
+	for night := checkIn; night.Before(checkOut); night = night.AddDate(0, 0, 1) {
+		price := NightPrice{Date: night.Format(DateLayout), Price: room.Price}
+		if plan, ok := planFor(plans, room.Type, night); ok {
+			price.Price = plan.Price
+			if plan.WeekendPrice != nil && isWeekendNight(night) {
+				price.Price = *plan.WeekendPrice
+			}
+			price.RatePlan = plan.Name
+			used[plan.ID] = plan
+		}
+		quote.Nights = append(quote.Nights, price)
+		quote.Subtotal += price.Price
+	}
+
+	percent := 0.0
+	for _, plan := range used {
+		for _, discount := range plan.Discounts {
+			if len(quote.Nights) >= discount.MinNights && discount.Percent > percent {
+				percent = discount.Percent
+			}
+		}
+	}
+
+	quote.Subtotal = roundCents(quote.Subtotal)
+	quote.Discount = roundCents(quote.Subtotal * percent / 100)
+	quote.Total = roundCents(quote.Subtotal - quote.Discount)
+	return quote, nil
+}
+
+// planFor returns the first plan (plans are sorted by priority) applying to
+// roomType on night.
+func planFor(plans []RatePlan, roomType string, night time.Time) (RatePlan, bool) {
+	for _, plan := range plans {
+		if plan.RoomType != "" && plan.RoomType != roomType {
+			continue
+		}
+		if plan.StartDate != nil && night.Before(*plan.StartDate) {
+			continue
+		}
+		if plan.EndDate != nil && !night.Before(*plan.EndDate) {
+			continue
+		}
+		return plan, true
+	}
+	return RatePlan{}, false
+}
+
+// isWeekendNight reports whether the night starts on Friday or Saturday.
+func isWeekendNight(night time.Time) bool {
+	day := night.Weekday()
+	return day == time.Friday || day == time.Saturday
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func newRatePlan(input RatePlanInput) (RatePlan, error) {
+	plan := RatePlan{
+		Name:         NormalizeText(input.Name),
+		RoomType:     normalizeKeyword(input.RoomType),
+		Price:        input.Price,
+		WeekendPrice: input.WeekendPrice,
+		Discounts:    input.Discounts,
+		Priority:     input.Priority,
+	}
+	if plan.Name == "" || plan.Price < 0 || (plan.RoomType != "" && !roomTypes[plan.RoomType]) {
+		return RatePlan{}, ErrInvalidRatePlanInput
+	}
+	if plan.WeekendPrice != nil && *plan.WeekendPrice < 0 {
+		return RatePlan{}, ErrInvalidRatePlanInput
+	}
+	for _, discount := range plan.Discounts {
+		if discount.MinNights < 1 || discount.Percent <= 0 || discount.Percent > 100 {
+			return RatePlan{}, ErrInvalidRatePlanInput
+		}
+	}
+	if plan.Discounts == nil {
+		plan.Discounts = []StayDiscount{}
+	}
+
+	var err error
+	if plan.StartDate, err = parseOptionalDate(input.StartDate); err != nil {
+		return RatePlan{}, ErrInvalidRatePlanInput
+	}
+	if plan.EndDate, err = parseOptionalDate(input.EndDate); err != nil {
+		return RatePlan{}, ErrInvalidRatePlanInput
+	}
+	if plan.StartDate != nil && plan.EndDate != nil && !plan.EndDate.After(*plan.StartDate) {
+		return RatePlan{}, ErrInvalidRatePlanInput
+	}
+	return plan, nil
+}
+
+func parseOptionalDate(value string) (*time.Time, error) {
+	value = NormalizeText(value)
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+)
+
+// realtimeRetention is how long the messages stay in the bridge
+// collection; the replicas read them as they are inserted, so they only
+// need to outlive a reconnection of the change stream.
+const realtimeRetention = 5 * time.Minute
+
+// MongoRealtimeBridge implements realtime.Bridge over a collection the
+// replicas insert the messages into and follow with a change stream (it
+// needs a replica set, like the transactions).
+type MongoRealtimeBridge struct {
+	collection *mongo.Collection
+	now        func() time.Time
+}
+
+// NewMongoRealtimeBridge creates a bridge over collection.
+func NewMongoRealtimeBridge(collection *mongo.Collection, now func() time.Time) *MongoRealtimeBridge {
+	if now == nil {
+		now = time.Now
+	}
+	return &MongoRealtimeBridge{collection: collection, now: now}
+}
+
+// EnsureIndexes expires the messages once no replica needs them.
+func (m *MongoRealtimeBridge) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "createdAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(realtimeRetention / time.Second)),
+	})
+	return err
+}
+
+// realtimeDocument is a message as stored in the bridge collection.
+type realtimeDocument struct {
+	realtime.Message `bson:",inline"`
+	CreatedAt        time.Time `bson:"createdAt"`
+}
+
+// Publish implements realtime.Bridge.
+func (m *MongoRealtimeBridge) Publish(ctx context.Context, msg realtime.Message) error {
+	_, err := m.collection.InsertOne(ctx, realtimeDocument{Message: msg, CreatedAt: m.now()})
+	return err
+}
+
+// Listen implements realtime.Bridge. When the change stream breaks it is
+// opened again from the last message seen, so none is missed or repeated.
+func (m *MongoRealtimeBridge) Listen(ctx context.Context, fn func(realtime.Message)) error {
+	stream, err := m.watch(ctx, nil)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			for stream.Next(ctx) {
+				var change struct {
+					Document realtimeDocument `bson:"fullDocument"`
+				}
+				if err := stream.Decode(&change); err != nil {
+					log.Printf("mensaje en tiempo real invalido: %v", err)
+					continue
+				}
+				fn(change.Document.Message)
+			}
+			resume := stream.ResumeToken()
+			err := stream.Err()
+			_ = stream.Close(context.WithoutCancel(ctx))
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("se corto el change stream de tiempo real: %v", err)
+			for {
+				if stream, err = m.watch(ctx, resume); err == nil {
+					break
+				}
+				log.Printf("no se pudo reabrir el change stream de tiempo real: %v", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Second):
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (m *MongoRealtimeBridge) watch(ctx context.Context, resume bson.Raw) (*mongo.ChangeStream, error) {
+	opts := options.ChangeStream()
+	if resume != nil {
+		opts.SetResumeAfter(resume)
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"operationType": "insert"}}}}
+	return m.collection.Watch(ctx, pipeline, opts)
+}
+
+// RealtimePublisher pushes the domain events to the channels of the users
+// they concern: the todo events to the owner and the assignee of the todo,
+// the booking events to the guest. It is meant to be one of the publishers
+// of the outbox relay, which publishes each event once across the
+// replicas; the hub takes it to every replica.
+type RealtimePublisher struct {
+	hub      *realtime.Hub
+	audience eventAudience
+}
+
+// NewRealtimePublisher builds a new RealtimePublisher instance.
+func NewRealtimePublisher(hub *realtime.Hub, todos TodoRepository, bookings BookingRepository) *RealtimePublisher {
+	return &RealtimePublisher{hub: hub, audience: eventAudience{todos: todos, bookings: bookings}}
+}
+
+// Publish implements events.Publisher. The realtime channel is best
+// effort: failures are logged and not returned, so they do not make the
+// relay publish the event again to the other publishers.
+func (p *RealtimePublisher) Publish(ctx context.Context, event events.Event) error {
+	users, err := p.audience.users(ctx, event)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("no se pudo resolver a quien enviar el evento %s en tiempo real: %v", event.ID, err)
+		return nil
+	}
+	for _, email := range users {
+		msg := realtime.Message{Channel: realtime.UserChannel(email), Type: event.Type, Data: event.Data, Time: event.Time}
+		if err := p.hub.Publish(ctx, msg); err != nil {
+			log.Printf("no se pudo enviar el evento %s en tiempo real: %v", event.ID, err)
+		}
+	}
+	return nil
+}
+
+// eventAudience finds the users a domain event concerns.
+type eventAudience struct {
+	todos    TodoRepository
+	bookings BookingRepository
+}
+
+// users returns the emails of the users event concerns.
+func (a eventAudience) users(ctx context.Context, event events.Event) ([]string, error) {
+	switch {
+	case strings.HasPrefix(event.Type, "todo."):
+		id, err := primitive.ObjectIDFromHex(event.Key)
+		if err != nil {
+			return nil, nil
+		}
+		todo, err := a.todos.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if todo.Assignee != "" && todo.Assignee != todo.Email {
+			return []string{todo.Email, todo.Assignee}, nil
+		}
+		return []string{todo.Email}, nil
+	case strings.HasPrefix(event.Type, "booking."):
+		id, err := primitive.ObjectIDFromHex(event.Key)
+		if err != nil {
+			return nil, nil
+		}
+		booking, err := a.bookings.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return []string{booking.Email}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RealtimeTicketTTL is how long a realtime ticket can be used to open the
+// WebSocket.
+const RealtimeTicketTTL = 30 * time.Second
+
+// ErrInvalidRealtimeTicket is returned for unknown, expired or already
+// used realtime tickets.
+var ErrInvalidRealtimeTicket = errors.New("invalid realtime ticket")
+
+// RealtimeTicket lets the browser of a signed-in user open the realtime
+// WebSocket, which cannot carry an Authorization header; it is used once.
+// Only the hash of the ticket is stored, in MongoDB so any replica can
+// take it.
+type RealtimeTicket struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	TicketHash string             `bson:"ticketHash"`
+	Email      string             `bson:"email"`
+	ExpiresAt  time.Time          `bson:"expiresAt"`
+}
+
+// RealtimeTicketRepository keeps the issued realtime tickets.
+type RealtimeTicketRepository interface {
+	Create(ctx context.Context, ticket RealtimeTicket) error
+	// Take removes and returns the ticket with the hash, or returns
+	// ErrNotFound.
+	Take(ctx context.Context, ticketHash string) (RealtimeTicket, error)
+}
+
+// MongoRealtimeTicketRepository implements RealtimeTicketRepository backed
+// by MongoDB.
+type MongoRealtimeTicketRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRealtimeTicketRepository creates a repository over collection.
+func NewMongoRealtimeTicketRepository(collection *mongo.Collection) *MongoRealtimeTicketRepository {
+	return &MongoRealtimeTicketRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique ticket index and a TTL index that lets
+// MongoDB purge the tickets nobody used.
+func (m *MongoRealtimeTicketRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ticketHash", Value: 1}}, Options: options.Index().SetUnique(true).SetName("ticket_unique")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create implements RealtimeTicketRepository.
+func (m *MongoRealtimeTicketRepository) Create(ctx context.Context, ticket RealtimeTicket) error {
+	_, err := m.collection.InsertOne(ctx, ticket)
+	return err
+}
+
+// Take implements RealtimeTicketRepository; deleting on read keeps a ticket
+// from being used twice, even by two replicas at once.
+func (m *MongoRealtimeTicketRepository) Take(ctx context.Context, ticketHash string) (RealtimeTicket, error) {
+	var ticket RealtimeTicket
+	err := m.collection.FindOneAndDelete(ctx, bson.M{"ticketHash": ticketHash}).Decode(&ticket)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return RealtimeTicket{}, ErrNotFound
+	}
+	return ticket, err
+}
+
+// RealtimeTicketService issues and redeems the realtime tickets.
+type RealtimeTicketService struct {
+	tickets RealtimeTicketRepository
+	now     func() time.Time
+	ids     IDGenerator
+}
+
+// NewRealtimeTicketService builds a new RealtimeTicketService instance.
+func NewRealtimeTicketService(tickets RealtimeTicketRepository, now func() time.Time, ids IDGenerator) *RealtimeTicketService {
+	return &RealtimeTicketService{tickets: tickets, now: now, ids: ids}
+}
+
+// Issue returns a new ticket for the user of email and when it expires.
+func (s *RealtimeTicketService) Issue(ctx context.Context, email string) (string, time.Time, error) {
+	ticket, err := newSessionToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := s.now().Add(RealtimeTicketTTL).UTC()
+	err = s.tickets.Create(ctx, RealtimeTicket{
+		ID:         s.ids.NewID(),
+		TicketHash: hashToken(ticket),
+		Email:      email,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return ticket, expiresAt, nil
+}
+
+// Redeem uses up ticket and returns the email of the user it was issued
+// to.
+func (s *RealtimeTicketService) Redeem(ctx context.Context, ticket string) (string, error) {
+	issued, err := s.tickets.Take(ctx, hashToken(ticket))
+	if errors.Is(err, ErrNotFound) || (err == nil && !s.now().Before(issued.ExpiresAt)) {
+		return "", ErrInvalidRealtimeTicket
+	}
+	if err != nil {
+		return "", err
+	}
+	return issued.Email, nil
+}
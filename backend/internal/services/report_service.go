@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidReportQuery indicates a malformed report range or granularity.
+var ErrInvalidReportQuery = errors.New("invalid report query")
+
+// maxReportDays caps the length of a report range.
+const maxReportDays = 731
+
+// Report granularities.
+const (
+	ReportDay   = "day"
+	ReportWeek  = "week"
+	ReportMonth = "month"
+)
+
+// ReportQuery selects the range [From, To) of a report, as YYYY-MM-DD dates,
+// and how it is grouped.
+type ReportQuery struct {
+	From    string
+	To      string
+	GroupBy string
+}
+
+// ReportPeriod holds the figures of one period of an occupancy or revenue
+// report. Room nights count each room once per night; ADR is the average
+// daily rate (revenue per sold room night) and RevPAR the revenue per
+// available room night.
+type ReportPeriod struct {
+	Period          string  `json:"period" xml:"period"`
+	Start           string  `json:"start" xml:"start"`
+	End             string  `json:"end" xml:"end"`
+	AvailableNights int     `json:"availableNights" xml:"availableNights"`
+	SoldNights      int     `json:"soldNights" xml:"soldNights"`
+	Occupancy       float64 `json:"occupancy" xml:"occupancy"`
+	Revenue         float64 `json:"revenue" xml:"revenue"`
+	ADR             float64 `json:"adr" xml:"adr"`
+	RevPAR          float64 `json:"revpar" xml:"revpar"`
+}
+
+// ReportService aggregates bookings into occupancy and revenue reports.
+type ReportService struct {
+	bookings BookingRepository
+	rooms    RoomRepository
+}
+
+// NewReportService builds a new ReportService instance.
+func NewReportService(bookings BookingRepository, rooms RoomRepository) *ReportService {
+	return &ReportService{bookings: bookings, rooms: rooms}
+}
+
+// Report returns one row per period of the range. Every booking that is not
+// cancelled counts as sold; revenue comes from the quote stored on the
+// booking, spreading its discount over the nights. Available nights use
+// the current room inventory.
+func (s *ReportService) Report(ctx context.Context, query ReportQuery) ([]ReportPeriod, error) {
+	from, err := time.Parse(DateLayout, NormalizeText(query.From))
+	if err != nil {
+		return nil, ErrInvalidReportQuery
+	}
+	to, err := time.Parse(DateLayout, NormalizeText(query.To))
+	if err != nil {
+		return nil, ErrInvalidReportQuery
+	}
+	groupBy := normalizeKeyword(query.GroupBy)
+	if groupBy == "" {
+		groupBy = ReportDay
+	}
+	if !to.After(from) || to.Sub(from) > maxReportDays*24*time.Hour {
+		return nil, ErrInvalidReportQuery
+	}
+	if groupBy != ReportDay && groupBy != ReportWeek && groupBy != ReportMonth {
+		return nil, ErrInvalidReportQuery
+	}
+
+	property := ScopedProperty(ctx)
+	rooms, err := s.rooms.List(ctx, RoomQuery{PropertyID: property})
+	if err != nil {
+		return nil, err
+	}
+	bookings, err := s.bookings.List(ctx, BookingQuery{PropertyID: property, StayFrom: from, StayTo: to})
+	if err != nil {
+		return nil, err
+	}
+
+	// Sold rooms and revenue per night, keyed by date.
+	sold := make(map[string]int)
+	revenue := make(map[string]float64)
+	for _, booking := range bookings {
+		if booking.Status == BookingCancelled || booking.Status == BookingHeld {
+			continue
+		}
+		nightly := nightlyRevenue(booking)
+		for night, i := booking.CheckIn, 0; night.Before(booking.CheckOut); night, i = night.AddDate(0, 0, 1), i+1 {
+			day := night.Format(DateLayout)
+			sold[day]++
+			if i < len(nightly) {
+				revenue[day] += nightly[i]
+			}
+		}
+	}
+
+	var periods []ReportPeriod
+	for start := from; start.Before(to); {
+		end := periodEnd(start, groupBy)
+		if end.After(to) {
+			end = to
+		}
+
+		period := ReportPeriod{
+			Period: periodLabel(start, groupBy),
+			Start:  start.Format(DateLayout),
+			End:    end.Format(DateLayout),
+		}
+		for night := start; night.Before(end); night = night.AddDate(0, 0, 1) {
+			period.AvailableNights += len(rooms)
+			day := night.Format(DateLayout)
+			period.SoldNights += sold[day]
+			period.Revenue += revenue[day]
+		}
+		period.Revenue = roundCents(period.Revenue)
+		if period.AvailableNights > 0 {
+			period.Occupancy = roundCents(float64(period.SoldNights) * 100 / float64(period.AvailableNights))
+			period.RevPAR = roundCents(period.Revenue / float64(period.AvailableNights))
+		}
+		if period.SoldNights > 0 {
+			period.ADR = roundCents(period.Revenue / float64(period.SoldNights))
+		}
+		periods = append(periods, period)
+		start = end
+	}
+	return periods, nil
+}
+
+// nightlyRevenue returns what each night of the booking earns, applying the
+// stay discount proportionally.
+func nightlyRevenue(booking Booking) []float64 {
+	if booking.Quote == nil || booking.Quote.Subtotal <= 0 {
+		return nil
+	}
+	factor := booking.Quote.Total / booking.Quote.Subtotal
+	nightly := make([]float64, 0, len(booking.Quote.Nights))
+	for _, night := range booking.Quote.Nights {
+		nightly = append(nightly, night.Price*factor)
+	}
+	return nightly
+}
+
+// periodEnd returns the exclusive end of the period starting at start:
+// weeks run Monday to Sunday and months follow the calendar.
+func periodEnd(start time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case ReportWeek:
+		days := (8 - int(start.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return start.AddDate(0, 0, days)
+	case ReportMonth:
+		return time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// periodLabel names the period containing start: the date, the ISO week
+// (2025-W07) or the month (2025-02).
+func periodLabel(start time.Time, groupBy string) string {
+	switch groupBy {
+	case ReportWeek:
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case ReportMonth:
+		return start.Format("2006-01")
+	default:
+		return start.Format(DateLayout)
+	}
+}
@@ -0,0 +1,1745 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/timing"
+)
+
+// ErrUnavailable is returned while the database circuit breaker is open.
+var ErrUnavailable = errors.New("service unavailable")
+
+// IsTransientError reports whether err is a network or availability problem
+// worth retrying, as opposed to a domain or validation error.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return mongo.IsNetworkError(err) ||
+		mongo.IsTimeout(err) ||
+		errors.Is(err, mongo.ErrClientDisconnected) ||
+		errors.As(err, &topology.ServerSelectionError{})
+}
+
+// CircuitBreaker stops calling the database after consecutive transient
+// failures, letting a single probe through once the cool-down elapses.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker builds a breaker that opens after threshold consecutive
+// failures and stays open for cooldown. A non-positive threshold disables it.
+func NewCircuitBreaker(threshold int, cooldown time.Duration, now func() time.Time) *CircuitBreaker {
+	if now == nil {
+		now = time.Now
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: now}
+}
+
+// Allow reports whether a call may proceed, and whether it is the probe
+// let through after the cool-down. The caller passes probe on to Record or
+// Release, so only the probe frees the way for the next one.
+func (b *CircuitBreaker) Allow() (ok, probe bool) {
+	if b == nil || b.threshold <= 0 {
+		return true, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true, false
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// Record updates the breaker with the outcome of a call; probe is what
+// Allow returned for it.
+func (b *CircuitBreaker) Record(failed, probe bool) {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// Release ends a call whose outcome says nothing about database health, such
+// as one abandoned by its caller; probe is what Allow returned for it.
+func (b *CircuitBreaker) Release(probe bool) {
+	if b == nil || b.threshold <= 0 || !probe {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// ResiliencePolicy configures retries and the shared circuit breaker applied
+// to repository calls.
+type ResiliencePolicy struct {
+	// MaxAttempts is the total number of tries for retryable operations.
+	MaxAttempts int
+	// Backoff is the initial wait between attempts; it doubles every retry.
+	Backoff time.Duration
+	Breaker *CircuitBreaker
+}
+
+func callWithPolicy[T any](ctx context.Context, p ResiliencePolicy, retryable bool, fn func() (T, error)) (T, error) {
+	defer timing.Track(ctx, timing.DB)()
+	attempts := p.MaxAttempts
+	if attempts < 1 || !retryable {
+		attempts = 1
+	}
+	wait := p.Backoff
+
+	var zero T
+	for attempt := 1; ; attempt++ {
+		ok, probe := p.Breaker.Allow()
+		if !ok {
+			return zero, ErrUnavailable
+		}
+
+		result, err := fn()
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up (request timeout or disconnect).
+			p.Breaker.Release(probe)
+			return result, err
+		}
+
+		transient := IsTransientError(err)
+		p.Breaker.Record(transient, probe)
+		if !transient || attempt >= attempts {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+func execWithPolicy(ctx context.Context, p ResiliencePolicy, retryable bool, fn func() error) error {
+	_, err := callWithPolicy(ctx, p, retryable, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// ResilientUserRepository decorates a UserRepository with the resilience policy.
+// Inserts are not retried here; the driver's retryable writes cover them.
+type ResilientUserRepository struct {
+	repo   UserRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientUserRepository wraps repo with retries and the circuit breaker.
+func NewResilientUserRepository(repo UserRepository, policy ResiliencePolicy) *ResilientUserRepository {
+	return &ResilientUserRepository{repo: repo, policy: policy}
+}
+
+// FindByEmail retries transient failures.
+func (r *ResilientUserRepository) FindByEmail(ctx context.Context, email string) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.FindByEmail(ctx, email)
+	})
+}
+
+// Insert runs once through the circuit breaker.
+func (r *ResilientUserRepository) Insert(ctx context.Context, user User) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Insert(ctx, user)
+	})
+}
+
+// List retries transient failures.
+func (r *ResilientUserRepository) List(ctx context.Context) ([]User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]User, error) {
+		return r.repo.List(ctx)
+	})
+}
+
+// Archive retries transient failures; a new run moves what is left.
+func (r *ResilientTodoRepository) Archive(ctx context.Context, before time.Time) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Archive(ctx, before)
+	})
+}
+
+// Clear retries transient failures; deleting twice is harmless.
+func (r *ResilientUserRepository) Clear(ctx context.Context) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Clear(ctx)
+	})
+}
+
+// SetRole retries transient failures; setting the same role twice is harmless.
+func (r *ResilientUserRepository) SetRole(ctx context.Context, email, role string) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.SetRole(ctx, email, role)
+	})
+}
+
+// SetProperty retries transient failures.
+func (r *ResilientUserRepository) SetProperty(ctx context.Context, email string, propertyID *primitive.ObjectID) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.SetProperty(ctx, email, propertyID)
+	})
+}
+
+// SetQuota retries transient failures; replacing the override is idempotent.
+func (r *ResilientUserRepository) SetQuota(ctx context.Context, email string, quota *QuotaOverride) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.SetQuota(ctx, email, quota)
+	})
+}
+
+// Search retries transient failures.
+func (r *ResilientUserRepository) Search(ctx context.Context, query UserQuery) ([]UserActivity, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]UserActivity, error) {
+		return r.repo.Search(ctx, query)
+	})
+}
+
+// Count retries transient failures.
+func (r *ResilientUserRepository) Count(ctx context.Context, query UserQuery) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Count(ctx, query)
+	})
+}
+
+// SetSuspended retries transient failures; setting the same date twice is
+// harmless.
+func (r *ResilientUserRepository) SetSuspended(ctx context.Context, email string, at *time.Time) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.SetSuspended(ctx, email, at)
+	})
+}
+
+// SetFeedToken retries transient failures; setting the same token twice
+// is harmless.
+func (r *ResilientUserRepository) SetFeedToken(ctx context.Context, email string, token *UserFeedToken) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.SetFeedToken(ctx, email, token)
+	})
+}
+
+// FindByFeedToken retries transient failures.
+func (r *ResilientUserRepository) FindByFeedToken(ctx context.Context, tokenHash string) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.FindByFeedToken(ctx, tokenHash)
+	})
+}
+
+// SetEmailChange retries transient failures; setting the same change
+// twice is harmless.
+func (r *ResilientUserRepository) SetEmailChange(ctx context.Context, email string, change *PendingEmailChange) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.SetEmailChange(ctx, email, change)
+	})
+}
+
+// FindByEmailChangeToken retries transient failures.
+func (r *ResilientUserRepository) FindByEmailChangeToken(ctx context.Context, tokenHash string) (User, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (User, error) {
+		return r.repo.FindByEmailChangeToken(ctx, tokenHash)
+	})
+}
+
+// ResilientPropertyRepository decorates a PropertyRepository with the
+// resilience policy.
+type ResilientPropertyRepository struct {
+	repo   PropertyRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientPropertyRepository wraps repo with retries and the circuit breaker.
+func NewResilientPropertyRepository(repo PropertyRepository, policy ResiliencePolicy) *ResilientPropertyRepository {
+	return &ResilientPropertyRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientPropertyRepository) List(ctx context.Context) ([]Property, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Property, error) {
+		return r.repo.List(ctx)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientPropertyRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Property, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Property, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientPropertyRepository) Create(ctx context.Context, property Property) (Property, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Property, error) {
+		return r.repo.Create(ctx, property)
+	})
+}
+
+// SetSSO retries transient failures; setting the same provider twice is
+// harmless.
+func (r *ResilientPropertyRepository) SetSSO(ctx context.Context, id primitive.ObjectID, sso *PropertySSO) (Property, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Property, error) {
+		return r.repo.SetSSO(ctx, id, sso)
+	})
+}
+
+// SetSCIM retries transient failures; setting the same token twice is
+// harmless.
+func (r *ResilientPropertyRepository) SetSCIM(ctx context.Context, id primitive.ObjectID, scim *PropertySCIM) (Property, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Property, error) {
+		return r.repo.SetSCIM(ctx, id, scim)
+	})
+}
+
+// FindBySCIMToken retries transient failures.
+func (r *ResilientPropertyRepository) FindBySCIMToken(ctx context.Context, tokenHash string) (Property, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Property, error) {
+		return r.repo.FindBySCIMToken(ctx, tokenHash)
+	})
+}
+
+// ResilientSessionRepository decorates a SessionRepository with the
+// resilience policy.
+type ResilientSessionRepository struct {
+	repo   SessionRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientSessionRepository wraps repo with retries and the circuit breaker.
+func NewResilientSessionRepository(repo SessionRepository, policy ResiliencePolicy) *ResilientSessionRepository {
+	return &ResilientSessionRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientSessionRepository) Create(ctx context.Context, session Session) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Create(ctx, session)
+	})
+}
+
+// Find retries transient failures.
+func (r *ResilientSessionRepository) Find(ctx context.Context, tokenHash string) (Session, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Session, error) {
+		return r.repo.Find(ctx, tokenHash)
+	})
+}
+
+// ListByEmail retries transient failures.
+func (r *ResilientSessionRepository) ListByEmail(ctx context.Context, email string) ([]Session, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Session, error) {
+		return r.repo.ListByEmail(ctx, email)
+	})
+}
+
+// Delete runs once through the circuit breaker: a retry after a lost
+// acknowledgement would report ErrNotFound.
+func (r *ResilientSessionRepository) Delete(ctx context.Context, email string, id primitive.ObjectID) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Delete(ctx, email, id)
+	})
+}
+
+// ResilientLoginRepository decorates a LoginRepository with the resilience
+// policy.
+type ResilientLoginRepository struct {
+	repo   LoginRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientLoginRepository wraps repo with retries and the circuit breaker.
+func NewResilientLoginRepository(repo LoginRepository, policy ResiliencePolicy) *ResilientLoginRepository {
+	return &ResilientLoginRepository{repo: repo, policy: policy}
+}
+
+// Record runs once through the circuit breaker; a retry could record the
+// login twice.
+func (r *ResilientLoginRepository) Record(ctx context.Context, login LoginRecord) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Record(ctx, login)
+	})
+}
+
+// List retries transient failures.
+func (r *ResilientLoginRepository) List(ctx context.Context, email string, limit int) ([]LoginRecord, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]LoginRecord, error) {
+		return r.repo.List(ctx, email, limit)
+	})
+}
+
+// Delete retries transient failures; deleting twice is harmless.
+func (r *ResilientLoginRepository) Delete(ctx context.Context, email string) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Delete(ctx, email)
+	})
+}
+
+// ResilientPasskeyRepository decorates a PasskeyRepository with the
+// resilience policy. Create and Delete are not retried: a lost
+// acknowledgement would turn a success into ErrPasskeyExists or ErrNotFound.
+type ResilientPasskeyRepository struct {
+	repo   PasskeyRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientPasskeyRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientPasskeyRepository(repo PasskeyRepository, policy ResiliencePolicy) *ResilientPasskeyRepository {
+	return &ResilientPasskeyRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientPasskeyRepository) Create(ctx context.Context, passkey Passkey) (Passkey, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Passkey, error) {
+		return r.repo.Create(ctx, passkey)
+	})
+}
+
+// FindByCredentialID retries transient failures.
+func (r *ResilientPasskeyRepository) FindByCredentialID(ctx context.Context, credentialID string) (Passkey, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Passkey, error) {
+		return r.repo.FindByCredentialID(ctx, credentialID)
+	})
+}
+
+// ListByEmail retries transient failures.
+func (r *ResilientPasskeyRepository) ListByEmail(ctx context.Context, email string) ([]Passkey, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Passkey, error) {
+		return r.repo.ListByEmail(ctx, email)
+	})
+}
+
+// MarkUsed retries transient failures; setting the same values twice is
+// harmless.
+func (r *ResilientPasskeyRepository) MarkUsed(ctx context.Context, id primitive.ObjectID, signCount uint32, at time.Time) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.MarkUsed(ctx, id, signCount, at)
+	})
+}
+
+// Delete runs once through the circuit breaker.
+func (r *ResilientPasskeyRepository) Delete(ctx context.Context, email string, id primitive.ObjectID) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Delete(ctx, email, id)
+	})
+}
+
+// ResilientCeremonyRepository decorates a CeremonyRepository with the
+// resilience policy. Take is not retried so a challenge is consumed once.
+type ResilientCeremonyRepository struct {
+	repo   CeremonyRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientCeremonyRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientCeremonyRepository(repo CeremonyRepository, policy ResiliencePolicy) *ResilientCeremonyRepository {
+	return &ResilientCeremonyRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientCeremonyRepository) Create(ctx context.Context, ceremony PasskeyCeremony) (PasskeyCeremony, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (PasskeyCeremony, error) {
+		return r.repo.Create(ctx, ceremony)
+	})
+}
+
+// Take runs once through the circuit breaker.
+func (r *ResilientCeremonyRepository) Take(ctx context.Context, id primitive.ObjectID) (PasskeyCeremony, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (PasskeyCeremony, error) {
+		return r.repo.Take(ctx, id)
+	})
+}
+
+// ResilientLoginFailureRepository decorates a LoginFailureRepository with
+// the resilience policy. Record is not retried so a failure counts once.
+type ResilientLoginFailureRepository struct {
+	repo   LoginFailureRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientLoginFailureRepository wraps repo with retries and the
+// circuit breaker.
+func NewResilientLoginFailureRepository(repo LoginFailureRepository, policy ResiliencePolicy) *ResilientLoginFailureRepository {
+	return &ResilientLoginFailureRepository{repo: repo, policy: policy}
+}
+
+// Record runs once through the circuit breaker.
+func (r *ResilientLoginFailureRepository) Record(ctx context.Context, email string, at time.Time, window time.Duration) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Record(ctx, email, at, window)
+	})
+}
+
+// Count retries transient failures.
+func (r *ResilientLoginFailureRepository) Count(ctx context.Context, email string, at time.Time) (int, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int, error) {
+		return r.repo.Count(ctx, email, at)
+	})
+}
+
+// Reset retries transient failures; deleting twice is harmless.
+func (r *ResilientLoginFailureRepository) Reset(ctx context.Context, email string) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Reset(ctx, email)
+	})
+}
+
+// ResilientTodoRepository decorates a TodoRepository with the resilience policy.
+// Create, Trash and Restore are not retried: a lost acknowledgement would
+// otherwise duplicate the todo or turn a successful change into ErrNotFound.
+type ResilientTodoRepository struct {
+	repo   TodoRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientTodoRepository wraps repo with retries and the circuit breaker.
+func NewResilientTodoRepository(repo TodoRepository, policy ResiliencePolicy) *ResilientTodoRepository {
+	return &ResilientTodoRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientTodoRepository) List(ctx context.Context, query TodoQuery) ([]Todo, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Todo, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// Count retries transient failures.
+func (r *ResilientTodoRepository) Count(ctx context.Context, query TodoQuery) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Count(ctx, query)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientTodoRepository) Create(ctx context.Context, todo Todo) (Todo, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Todo, error) {
+		return r.repo.Create(ctx, todo)
+	})
+}
+
+// Update retries transient failures; $set is idempotent.
+func (r *ResilientTodoRepository) Update(ctx context.Context, id primitive.ObjectID, update TodoUpdate) (Todo, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Todo, error) {
+		return r.repo.Update(ctx, id, update)
+	})
+}
+
+// Trash runs once through the circuit breaker: a retry after a lost reply
+// would find the todo already trashed and report it missing.
+func (r *ResilientTodoRepository) Trash(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Trash(ctx, id, at)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientTodoRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Todo, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Restore runs once through the circuit breaker, like Trash.
+func (r *ResilientTodoRepository) Restore(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Todo, error) {
+		return r.repo.Restore(ctx, id)
+	})
+}
+
+// CompleteAll retries transient failures; it only changes the todos not
+// yet in the requested state.
+func (r *ResilientTodoRepository) CompleteAll(ctx context.Context, email string, update TodoUpdate) ([]Todo, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Todo, error) {
+		return r.repo.CompleteAll(ctx, email, update)
+	})
+}
+
+// React retries transient failures; the update only matches when it
+// changes something.
+func (r *ResilientTodoRepository) React(ctx context.Context, id primitive.ObjectID, reaction TodoReaction, add bool) (Todo, bool, error) {
+	var changed bool
+	todo, err := callWithPolicy(ctx, r.policy, true, func() (Todo, error) {
+		var todo Todo
+		var err error
+		todo, changed, err = r.repo.React(ctx, id, reaction, add)
+		return todo, err
+	})
+	return todo, changed, err
+}
+
+// TransitionApproval runs once through the circuit breaker, like Trash: a
+// retry after a lost reply would find the todo already moved and report a
+// conflict.
+func (r *ResilientTodoRepository) TransitionApproval(ctx context.Context, id primitive.ObjectID, from, to string, at time.Time) (Todo, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Todo, error) {
+		return r.repo.TransitionApproval(ctx, id, from, to, at)
+	})
+}
+
+// TrashCompleted runs once through the circuit breaker, like Trash: a
+// retry after a lost reply would report nothing trashed.
+func (r *ResilientTodoRepository) TrashCompleted(ctx context.Context, email string, at time.Time) (int64, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (int64, error) {
+		return r.repo.TrashCompleted(ctx, email, at)
+	})
+}
+
+// Purge retries transient failures; deleting twice is harmless.
+func (r *ResilientTodoRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Purge(ctx, before)
+	})
+}
+
+// Clear retries transient failures; deleting twice is harmless.
+func (r *ResilientTodoRepository) Clear(ctx context.Context, email string, all bool) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Clear(ctx, email, all)
+	})
+}
+
+// ResilientRoomRepository decorates a RoomRepository with the resilience policy.
+// Create and Delete run once for the same reasons as todos.
+type ResilientRoomRepository struct {
+	repo   RoomRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientRoomRepository wraps repo with retries and the circuit breaker.
+func NewResilientRoomRepository(repo RoomRepository, policy ResiliencePolicy) *ResilientRoomRepository {
+	return &ResilientRoomRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientRoomRepository) List(ctx context.Context, query RoomQuery) ([]Room, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Room, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientRoomRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Room, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Room, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientRoomRepository) Create(ctx context.Context, room Room) (Room, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Room, error) {
+		return r.repo.Create(ctx, room)
+	})
+}
+
+// Update retries transient failures; $set is idempotent.
+func (r *ResilientRoomRepository) Update(ctx context.Context, id primitive.ObjectID, update RoomUpdate) (Room, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Room, error) {
+		return r.repo.Update(ctx, id, update)
+	})
+}
+
+// Delete runs once through the circuit breaker.
+func (r *ResilientRoomRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Delete(ctx, id)
+	})
+}
+
+// ResilientBookingRepository decorates a BookingRepository with the resilience
+// policy. Create and Update are not retried here: they run in transactions
+// whose transient errors the driver already retries.
+type ResilientBookingRepository struct {
+	repo   BookingRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientBookingRepository wraps repo with retries and the circuit breaker.
+func NewResilientBookingRepository(repo BookingRepository, policy ResiliencePolicy) *ResilientBookingRepository {
+	return &ResilientBookingRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientBookingRepository) List(ctx context.Context, query BookingQuery) ([]Booking, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Booking, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientBookingRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Booking, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Booking, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientBookingRepository) Create(ctx context.Context, booking Booking) (Booking, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Booking, error) {
+		return r.repo.Create(ctx, booking)
+	})
+}
+
+// Update runs once through the circuit breaker.
+func (r *ResilientBookingRepository) Update(ctx context.Context, booking Booking) (Booking, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Booking, error) {
+		return r.repo.Update(ctx, booking)
+	})
+}
+
+// Transition runs once through the circuit breaker; a retry after a lost
+// acknowledgement would report a state conflict.
+func (r *ResilientBookingRepository) Transition(ctx context.Context, id primitive.ObjectID, from, to string, at time.Time) (Booking, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Booking, error) {
+		return r.repo.Transition(ctx, id, from, to, at)
+	})
+}
+
+// Available retries transient failures.
+func (r *ResilientBookingRepository) Available(ctx context.Context, propertyID primitive.ObjectID, checkIn, checkOut time.Time) ([]Room, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Room, error) {
+		return r.repo.Available(ctx, propertyID, checkIn, checkOut)
+	})
+}
+
+// ResilientGuestRepository decorates a GuestRepository with the resilience policy.
+type ResilientGuestRepository struct {
+	repo   GuestRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientGuestRepository wraps repo with retries and the circuit breaker.
+func NewResilientGuestRepository(repo GuestRepository, policy ResiliencePolicy) *ResilientGuestRepository {
+	return &ResilientGuestRepository{repo: repo, policy: policy}
+}
+
+// Search retries transient failures.
+func (r *ResilientGuestRepository) Search(ctx context.Context, search string) ([]Guest, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Guest, error) {
+		return r.repo.Search(ctx, search)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientGuestRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Guest, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Guest, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientGuestRepository) Create(ctx context.Context, guest Guest) (Guest, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Guest, error) {
+		return r.repo.Create(ctx, guest)
+	})
+}
+
+// Update retries transient failures; $set is idempotent.
+func (r *ResilientGuestRepository) Update(ctx context.Context, id primitive.ObjectID, update GuestUpdate) (Guest, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Guest, error) {
+		return r.repo.Update(ctx, id, update)
+	})
+}
+
+// Delete runs once through the circuit breaker.
+func (r *ResilientGuestRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Delete(ctx, id)
+	})
+}
+
+// ResilientRatePlanRepository decorates a RatePlanRepository with the
+// resilience policy.
+type ResilientRatePlanRepository struct {
+	repo   RatePlanRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientRatePlanRepository wraps repo with retries and the circuit breaker.
+func NewResilientRatePlanRepository(repo RatePlanRepository, policy ResiliencePolicy) *ResilientRatePlanRepository {
+	return &ResilientRatePlanRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientRatePlanRepository) List(ctx context.Context) ([]RatePlan, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]RatePlan, error) {
+		return r.repo.List(ctx)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientRatePlanRepository) FindByID(ctx context.Context, id primitive.ObjectID) (RatePlan, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (RatePlan, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientRatePlanRepository) Create(ctx context.Context, plan RatePlan) (RatePlan, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (RatePlan, error) {
+		return r.repo.Create(ctx, plan)
+	})
+}
+
+// Replace retries transient failures; replacing twice is harmless.
+func (r *ResilientRatePlanRepository) Replace(ctx context.Context, plan RatePlan) (RatePlan, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (RatePlan, error) {
+		return r.repo.Replace(ctx, plan)
+	})
+}
+
+// Delete runs once through the circuit breaker.
+func (r *ResilientRatePlanRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Delete(ctx, id)
+	})
+}
+
+// ResilientPaymentRepository decorates a PaymentRepository with the
+// resilience policy.
+type ResilientPaymentRepository struct {
+	repo   PaymentRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientPaymentRepository wraps repo with retries and the circuit breaker.
+func NewResilientPaymentRepository(repo PaymentRepository, policy ResiliencePolicy) *ResilientPaymentRepository {
+	return &ResilientPaymentRepository{repo: repo, policy: policy}
+}
+
+// ListByBooking retries transient failures.
+func (r *ResilientPaymentRepository) ListByBooking(ctx context.Context, bookingID primitive.ObjectID) ([]Payment, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Payment, error) {
+		return r.repo.ListByBooking(ctx, bookingID)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientPaymentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Payment, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Payment, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientPaymentRepository) Create(ctx context.Context, payment Payment) (Payment, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Payment, error) {
+		return r.repo.Create(ctx, payment)
+	})
+}
+
+// Apply runs once through the circuit breaker; providers redeliver failed
+// notifications themselves.
+func (r *ResilientPaymentRepository) Apply(ctx context.Context, event PaymentEvent, from []string, providerRef string) (Payment, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Payment, error) {
+		return r.repo.Apply(ctx, event, from, providerRef)
+	})
+}
+
+// ResilientReviewRepository decorates a ReviewRepository with the
+// resilience policy.
+type ResilientReviewRepository struct {
+	repo   ReviewRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientReviewRepository wraps repo with retries and the circuit breaker.
+func NewResilientReviewRepository(repo ReviewRepository, policy ResiliencePolicy) *ResilientReviewRepository {
+	return &ResilientReviewRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientReviewRepository) List(ctx context.Context, query ReviewQuery) ([]Review, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Review, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// Count retries transient failures.
+func (r *ResilientReviewRepository) Count(ctx context.Context, query ReviewQuery) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Count(ctx, query)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientReviewRepository) Create(ctx context.Context, review Review) (Review, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Review, error) {
+		return r.repo.Create(ctx, review)
+	})
+}
+
+// Ratings retries transient failures.
+func (r *ResilientReviewRepository) Ratings(ctx context.Context, roomIDs []primitive.ObjectID) (map[primitive.ObjectID]RoomRating, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (map[primitive.ObjectID]RoomRating, error) {
+		return r.repo.Ratings(ctx, roomIDs)
+	})
+}
+
+// ResilientWaitlistRepository decorates a WaitlistRepository with the
+// resilience policy.
+type ResilientWaitlistRepository struct {
+	repo   WaitlistRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientWaitlistRepository wraps repo with retries and the circuit breaker.
+func NewResilientWaitlistRepository(repo WaitlistRepository, policy ResiliencePolicy) *ResilientWaitlistRepository {
+	return &ResilientWaitlistRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientWaitlistRepository) List(ctx context.Context, query WaitlistQuery) ([]WaitlistEntry, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]WaitlistEntry, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientWaitlistRepository) FindByID(ctx context.Context, id primitive.ObjectID) (WaitlistEntry, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (WaitlistEntry, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientWaitlistRepository) Create(ctx context.Context, entry WaitlistEntry) (WaitlistEntry, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (WaitlistEntry, error) {
+		return r.repo.Create(ctx, entry)
+	})
+}
+
+// Transition runs once through the circuit breaker; a retry after a lost
+// acknowledgement would report a state conflict.
+func (r *ResilientWaitlistRepository) Transition(ctx context.Context, id primitive.ObjectID, from string, update WaitlistUpdate) (WaitlistEntry, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (WaitlistEntry, error) {
+		return r.repo.Transition(ctx, id, from, update)
+	})
+}
+
+// ResilientMailRepository decorates a MailRepository with the resilience
+// policy. MarkSent is not retried: a lost acknowledgement would report the
+// email as already sent and skip it.
+type ResilientMailRepository struct {
+	repo   MailRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientMailRepository wraps repo with retries and the circuit breaker.
+func NewResilientMailRepository(repo MailRepository, policy ResiliencePolicy) *ResilientMailRepository {
+	return &ResilientMailRepository{repo: repo, policy: policy}
+}
+
+// MarkSent runs once through the circuit breaker.
+func (r *ResilientMailRepository) MarkSent(ctx context.Context, key string, at time.Time) (bool, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (bool, error) {
+		return r.repo.MarkSent(ctx, key, at)
+	})
+}
+
+// Forget retries transient failures.
+func (r *ResilientMailRepository) Forget(ctx context.Context, key string) error {
+	_, err := callWithPolicy(ctx, r.policy, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.Forget(ctx, key)
+	})
+	return err
+}
+
+// OptedOut retries transient failures.
+func (r *ResilientMailRepository) OptedOut(ctx context.Context, email string) (bool, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (bool, error) {
+		return r.repo.OptedOut(ctx, email)
+	})
+}
+
+// OptOut retries transient failures; the upsert is idempotent.
+func (r *ResilientMailRepository) OptOut(ctx context.Context, email string, at time.Time) error {
+	_, err := callWithPolicy(ctx, r.policy, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.OptOut(ctx, email, at)
+	})
+	return err
+}
+
+// ResilientImportRunRepository decorates an ImportRunRepository with the
+// resilience policy.
+type ResilientImportRunRepository struct {
+	repo   ImportRunRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientImportRunRepository wraps repo with retries and the circuit breaker.
+func NewResilientImportRunRepository(repo ImportRunRepository, policy ResiliencePolicy) *ResilientImportRunRepository {
+	return &ResilientImportRunRepository{repo: repo, policy: policy}
+}
+
+// FindByID retries transient failures.
+func (r *ResilientImportRunRepository) FindByID(ctx context.Context, id primitive.ObjectID) (ImportRun, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (ImportRun, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientImportRunRepository) Create(ctx context.Context, run ImportRun) (ImportRun, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (ImportRun, error) {
+		return r.repo.Create(ctx, run)
+	})
+}
+
+// Save retries transient failures; replacing the run is idempotent.
+func (r *ResilientImportRunRepository) Save(ctx context.Context, run ImportRun) error {
+	_, err := callWithPolicy(ctx, r.policy, true, func() (struct{}, error) {
+		return struct{}{}, r.repo.Save(ctx, run)
+	})
+	return err
+}
+
+// ResilientOutboxRepository decorates an OutboxRepository with the
+// resilience policy. MarkFailed is not retried: it counts the attempt.
+type ResilientOutboxRepository struct {
+	repo   OutboxRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientOutboxRepository wraps repo with retries and the circuit breaker.
+func NewResilientOutboxRepository(repo OutboxRepository, policy ResiliencePolicy) *ResilientOutboxRepository {
+	return &ResilientOutboxRepository{repo: repo, policy: policy}
+}
+
+// Claim retries transient failures; a lost claim only waits for its lease.
+func (r *ResilientOutboxRepository) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxMessage, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]OutboxMessage, error) {
+		return r.repo.Claim(ctx, now, lease, limit)
+	})
+}
+
+// MarkPublished retries transient failures.
+func (r *ResilientOutboxRepository) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.MarkPublished(ctx, id, at)
+	})
+}
+
+// MarkFailed runs once through the circuit breaker.
+func (r *ResilientOutboxRepository) MarkFailed(ctx context.Context, id string, next time.Time, reason string) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.MarkFailed(ctx, id, next, reason)
+	})
+}
+
+// MarkDead runs once through the circuit breaker, like MarkFailed.
+func (r *ResilientOutboxRepository) MarkDead(ctx context.Context, id string, reason string) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.MarkDead(ctx, id, reason)
+	})
+}
+
+// ResilientJobStore decorates a scheduler.Store with the resilience policy.
+type ResilientJobStore struct {
+	store  scheduler.Store
+	policy ResiliencePolicy
+}
+
+// NewResilientJobStore wraps store with retries and the circuit breaker.
+func NewResilientJobStore(store scheduler.Store, policy ResiliencePolicy) *ResilientJobStore {
+	return &ResilientJobStore{store: store, policy: policy}
+}
+
+// Save retries transient failures; replacing a status is idempotent.
+func (r *ResilientJobStore) Save(ctx context.Context, status scheduler.Status) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.store.Save(ctx, status)
+	})
+}
+
+// List retries transient failures.
+func (r *ResilientJobStore) List(ctx context.Context) ([]scheduler.Status, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]scheduler.Status, error) {
+		return r.store.List(ctx)
+	})
+}
+
+// ResilientDeadLetterRepository decorates a DeadLetterRepository with the
+// resilience policy.
+type ResilientDeadLetterRepository struct {
+	repo   DeadLetterRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientDeadLetterRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientDeadLetterRepository(repo DeadLetterRepository, policy ResiliencePolicy) *ResilientDeadLetterRepository {
+	return &ResilientDeadLetterRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientDeadLetterRepository) List(ctx context.Context, query DeadLetterQuery) ([]DeadLetter, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]DeadLetter, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// Count retries transient failures.
+func (r *ResilientDeadLetterRepository) Count(ctx context.Context, query DeadLetterQuery) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Count(ctx, query)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientDeadLetterRepository) FindByID(ctx context.Context, id primitive.ObjectID) (DeadLetter, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (DeadLetter, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientDeadLetterRepository) Create(ctx context.Context, letter DeadLetter) (DeadLetter, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (DeadLetter, error) {
+		return r.repo.Create(ctx, letter)
+	})
+}
+
+// Save retries transient failures; replacing a document is idempotent.
+func (r *ResilientDeadLetterRepository) Save(ctx context.Context, letter DeadLetter) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Save(ctx, letter)
+	})
+}
+
+// ResilientDashboardRepository decorates a DashboardRepository with the
+// resilience policy.
+type ResilientDashboardRepository struct {
+	repo   DashboardRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientDashboardRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientDashboardRepository(repo DashboardRepository, policy ResiliencePolicy) *ResilientDashboardRepository {
+	return &ResilientDashboardRepository{repo: repo, policy: policy}
+}
+
+// CountUsers retries transient failures.
+func (r *ResilientDashboardRepository) CountUsers(ctx context.Context) (UserSummary, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (UserSummary, error) {
+		return r.repo.CountUsers(ctx)
+	})
+}
+
+// SignupsPerDay retries transient failures.
+func (r *ResilientDashboardRepository) SignupsPerDay(ctx context.Context, from, to time.Time) ([]DailyCount, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]DailyCount, error) {
+		return r.repo.SignupsPerDay(ctx, from, to)
+	})
+}
+
+// TodoActivity retries transient failures.
+func (r *ResilientDashboardRepository) TodoActivity(ctx context.Context, from, to time.Time) (TodoActivity, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (TodoActivity, error) {
+		return r.repo.TodoActivity(ctx, from, to)
+	})
+}
+
+// DeliveriesPerDay retries transient failures.
+func (r *ResilientDashboardRepository) DeliveriesPerDay(ctx context.Context, from, to time.Time) ([]DeliveryDay, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]DeliveryDay, error) {
+		return r.repo.DeliveriesPerDay(ctx, from, to)
+	})
+}
+
+// Storage retries transient failures.
+func (r *ResilientDashboardRepository) Storage(ctx context.Context) (StorageSummary, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (StorageSummary, error) {
+		return r.repo.Storage(ctx)
+	})
+}
+
+// ResilientTodoStatsRepository decorates a TodoStatsRepository with the
+// resilience policy.
+type ResilientTodoStatsRepository struct {
+	repo   TodoStatsRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientTodoStatsRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientTodoStatsRepository(repo TodoStatsRepository, policy ResiliencePolicy) *ResilientTodoStatsRepository {
+	return &ResilientTodoStatsRepository{repo: repo, policy: policy}
+}
+
+// Breakdown retries transient failures.
+func (r *ResilientTodoStatsRepository) Breakdown(ctx context.Context, email string) (StatsRows, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (StatsRows, error) {
+		return r.repo.Breakdown(ctx, email)
+	})
+}
+
+// ResilientAuditRepository decorates an AuditRepository with the
+// resilience policy.
+type ResilientAuditRepository struct {
+	repo   AuditRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientAuditRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientAuditRepository(repo AuditRepository, policy ResiliencePolicy) *ResilientAuditRepository {
+	return &ResilientAuditRepository{repo: repo, policy: policy}
+}
+
+// Find retries transient failures.
+func (r *ResilientAuditRepository) Find(ctx context.Context, filter AuditFilter) ([]OutboxMessage, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]OutboxMessage, error) {
+		return r.repo.Find(ctx, filter)
+	})
+}
+
+// ResilientPrivacyRepository decorates a PrivacyRepository with the
+// resilience policy. Every method is retried: the erasing ones are
+// idempotent.
+type ResilientPrivacyRepository struct {
+	repo   PrivacyRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientPrivacyRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientPrivacyRepository(repo PrivacyRepository, policy ResiliencePolicy) *ResilientPrivacyRepository {
+	return &ResilientPrivacyRepository{repo: repo, policy: policy}
+}
+
+// Comments retries transient failures.
+func (r *ResilientPrivacyRepository) Comments(ctx context.Context, bookingIDs []primitive.ObjectID) ([]Review, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Review, error) {
+		return r.repo.Comments(ctx, bookingIDs)
+	})
+}
+
+// Activity retries transient failures.
+func (r *ResilientPrivacyRepository) Activity(ctx context.Context, keys []string) ([]events.Event, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]events.Event, error) {
+		return r.repo.Activity(ctx, keys)
+	})
+}
+
+// EraseComments retries transient failures.
+func (r *ResilientPrivacyRepository) EraseComments(ctx context.Context, bookingIDs []primitive.ObjectID) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.EraseComments(ctx, bookingIDs)
+	})
+}
+
+// AnonymizeActivity retries transient failures.
+func (r *ResilientPrivacyRepository) AnonymizeActivity(ctx context.Context, keys []string, email, alias string) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.AnonymizeActivity(ctx, keys, email, alias)
+	})
+}
+
+// AnonymizeBookings retries transient failures.
+func (r *ResilientPrivacyRepository) AnonymizeBookings(ctx context.Context, email, alias string) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.AnonymizeBookings(ctx, email, alias)
+	})
+}
+
+// DeleteTodos retries transient failures.
+func (r *ResilientPrivacyRepository) DeleteTodos(ctx context.Context, email string) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.DeleteTodos(ctx, email)
+	})
+}
+
+// DeleteSessions retries transient failures.
+func (r *ResilientPrivacyRepository) DeleteSessions(ctx context.Context, email string) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.DeleteSessions(ctx, email)
+	})
+}
+
+// DeleteUser retries transient failures.
+func (r *ResilientPrivacyRepository) DeleteUser(ctx context.Context, email string) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.DeleteUser(ctx, email)
+	})
+}
+
+// AnonymizeTodos retries transient failures.
+func (r *ResilientPrivacyRepository) AnonymizeTodos(ctx context.Context, email, alias string) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.AnonymizeTodos(ctx, email, alias)
+	})
+}
+
+// AnonymizeUser retries transient failures.
+func (r *ResilientPrivacyRepository) AnonymizeUser(ctx context.Context, email, alias string, at time.Time) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.AnonymizeUser(ctx, email, alias, at)
+	})
+}
+
+// ResilientAccountMergeRepository decorates an AccountMergeRepository with
+// the resilience policy. Merge is retried too: every step of it can run
+// again.
+type ResilientAccountMergeRepository struct {
+	repo   AccountMergeRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientAccountMergeRepository wraps repo with retries and the
+// circuit breaker.
+func NewResilientAccountMergeRepository(repo AccountMergeRepository, policy ResiliencePolicy) *ResilientAccountMergeRepository {
+	return &ResilientAccountMergeRepository{repo: repo, policy: policy}
+}
+
+// Count retries transient failures.
+func (r *ResilientAccountMergeRepository) Count(ctx context.Context, source string) (MergeSummary, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (MergeSummary, error) {
+		return r.repo.Count(ctx, source)
+	})
+}
+
+// Merge retries transient failures.
+func (r *ResilientAccountMergeRepository) Merge(ctx context.Context, source, target string) (MergeSummary, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (MergeSummary, error) {
+		return r.repo.Merge(ctx, source, target)
+	})
+}
+
+// ResilientErasureRepository decorates an ErasureRepository with the
+// resilience policy.
+type ResilientErasureRepository struct {
+	repo   ErasureRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientErasureRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientErasureRepository(repo ErasureRepository, policy ResiliencePolicy) *ResilientErasureRepository {
+	return &ResilientErasureRepository{repo: repo, policy: policy}
+}
+
+// FindByID retries transient failures.
+func (r *ResilientErasureRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Erasure, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Erasure, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientErasureRepository) Create(ctx context.Context, erasure Erasure) (Erasure, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (Erasure, error) {
+		return r.repo.Create(ctx, erasure)
+	})
+}
+
+// Save retries transient failures; replacing the erasure is idempotent.
+func (r *ResilientErasureRepository) Save(ctx context.Context, erasure Erasure) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Save(ctx, erasure)
+	})
+}
+
+// ResilientCommentRepository decorates a CommentRepository with the
+// resilience policy.
+type ResilientCommentRepository struct {
+	repo   CommentRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientCommentRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientCommentRepository(repo CommentRepository, policy ResiliencePolicy) *ResilientCommentRepository {
+	return &ResilientCommentRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientCommentRepository) List(ctx context.Context, todoID primitive.ObjectID) ([]TodoComment, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]TodoComment, error) {
+		return r.repo.List(ctx, todoID)
+	})
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientCommentRepository) Create(ctx context.Context, comment TodoComment) (TodoComment, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (TodoComment, error) {
+		return r.repo.Create(ctx, comment)
+	})
+}
+
+// ResilientAttachmentRepository decorates an AttachmentRepository with the
+// resilience policy.
+type ResilientAttachmentRepository struct {
+	repo   AttachmentRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientAttachmentRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientAttachmentRepository(repo AttachmentRepository, policy ResiliencePolicy) *ResilientAttachmentRepository {
+	return &ResilientAttachmentRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientAttachmentRepository) Create(ctx context.Context, attachment Attachment) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Create(ctx, attachment)
+	})
+}
+
+// List retries transient failures.
+func (r *ResilientAttachmentRepository) List(ctx context.Context, todoID primitive.ObjectID) ([]Attachment, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Attachment, error) {
+		return r.repo.List(ctx, todoID)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientAttachmentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Attachment, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Attachment, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// Delete retries transient failures; deleting twice reports ErrNotFound.
+func (r *ResilientAttachmentRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Delete(ctx, id)
+	})
+}
+
+// SetScan retries transient failures; recording the same result twice is
+// harmless.
+func (r *ResilientAttachmentRepository) SetScan(ctx context.Context, id primitive.ObjectID, scan, threat string, at time.Time) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.SetScan(ctx, id, scan, threat, at)
+	})
+}
+
+// ListPending retries transient failures.
+func (r *ResilientAttachmentRepository) ListPending(ctx context.Context, before time.Time, limit int) ([]Attachment, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Attachment, error) {
+		return r.repo.ListPending(ctx, before, limit)
+	})
+}
+
+// ResilientLinkPreviewCache decorates a LinkPreviewCache with the
+// resilience policy.
+type ResilientLinkPreviewCache struct {
+	cache  LinkPreviewCache
+	policy ResiliencePolicy
+}
+
+// NewResilientLinkPreviewCache wraps cache with retries and the circuit
+// breaker.
+func NewResilientLinkPreviewCache(cache LinkPreviewCache, policy ResiliencePolicy) *ResilientLinkPreviewCache {
+	return &ResilientLinkPreviewCache{cache: cache, policy: policy}
+}
+
+// Find retries transient failures.
+func (r *ResilientLinkPreviewCache) Find(ctx context.Context, url string) (CachedLinkPreview, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (CachedLinkPreview, error) {
+		return r.cache.Find(ctx, url)
+	})
+}
+
+// Save retries transient failures; it replaces the cached preview.
+func (r *ResilientLinkPreviewCache) Save(ctx context.Context, preview CachedLinkPreview) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.cache.Save(ctx, preview)
+	})
+}
+
+// ResilientNotificationRepository decorates a NotificationRepository with
+// the resilience policy.
+type ResilientNotificationRepository struct {
+	repo   NotificationRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientNotificationRepository wraps repo with retries and the
+// circuit breaker.
+func NewResilientNotificationRepository(repo NotificationRepository, policy ResiliencePolicy) *ResilientNotificationRepository {
+	return &ResilientNotificationRepository{repo: repo, policy: policy}
+}
+
+// List retries transient failures.
+func (r *ResilientNotificationRepository) List(ctx context.Context, query NotificationQuery) ([]Notification, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]Notification, error) {
+		return r.repo.List(ctx, query)
+	})
+}
+
+// Count retries transient failures.
+func (r *ResilientNotificationRepository) Count(ctx context.Context, query NotificationQuery) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.Count(ctx, query)
+	})
+}
+
+// Create runs once through the circuit breaker; notifications without a
+// key would be stored twice.
+func (r *ResilientNotificationRepository) Create(ctx context.Context, notifications []Notification) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Create(ctx, notifications)
+	})
+}
+
+// MarkRead retries transient failures; the first date is kept.
+func (r *ResilientNotificationRepository) MarkRead(ctx context.Context, id primitive.ObjectID, email string, at time.Time) (Notification, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (Notification, error) {
+		return r.repo.MarkRead(ctx, id, email, at)
+	})
+}
+
+// MarkAllRead retries transient failures; read notifications are skipped.
+func (r *ResilientNotificationRepository) MarkAllRead(ctx context.Context, email string, at time.Time) (int64, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (int64, error) {
+		return r.repo.MarkAllRead(ctx, email, at)
+	})
+}
+
+// ResilientListRepository decorates a ListRepository with the resilience
+// policy.
+type ResilientListRepository struct {
+	repo   ListRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientListRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientListRepository(repo ListRepository, policy ResiliencePolicy) *ResilientListRepository {
+	return &ResilientListRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientListRepository) Create(ctx context.Context, list TodoList) (TodoList, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (TodoList, error) {
+		return r.repo.Create(ctx, list)
+	})
+}
+
+// FindByID retries transient failures.
+func (r *ResilientListRepository) FindByID(ctx context.Context, id primitive.ObjectID) (TodoList, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (TodoList, error) {
+		return r.repo.FindByID(ctx, id)
+	})
+}
+
+// ListFor retries transient failures.
+func (r *ResilientListRepository) ListFor(ctx context.Context, email string) ([]TodoList, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]TodoList, error) {
+		return r.repo.ListFor(ctx, email)
+	})
+}
+
+// SetMembers retries transient failures; setting the same members twice
+// is harmless.
+func (r *ResilientListRepository) SetMembers(ctx context.Context, id primitive.ObjectID, members []ListMember) (TodoList, error) {
+	return callWithPolicy(ctx, r.policy, true, func() (TodoList, error) {
+		return r.repo.SetMembers(ctx, id, members)
+	})
+}
+
+// ResilientSSOStateRepository decorates an SSOStateRepository with the
+// resilience policy.
+type ResilientSSOStateRepository struct {
+	repo   SSOStateRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientSSOStateRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientSSOStateRepository(repo SSOStateRepository, policy ResiliencePolicy) *ResilientSSOStateRepository {
+	return &ResilientSSOStateRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientSSOStateRepository) Create(ctx context.Context, state SSOState) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Create(ctx, state)
+	})
+}
+
+// Take runs once through the circuit breaker: a retry after a lost reply
+// would find the state already taken.
+func (r *ResilientSSOStateRepository) Take(ctx context.Context, stateHash string) (SSOState, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (SSOState, error) {
+		return r.repo.Take(ctx, stateHash)
+	})
+}
+
+// ResilientRealtimeTicketRepository decorates a RealtimeTicketRepository
+// with the resilience policy.
+type ResilientRealtimeTicketRepository struct {
+	repo   RealtimeTicketRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientRealtimeTicketRepository wraps repo with retries and the
+// circuit breaker.
+func NewResilientRealtimeTicketRepository(repo RealtimeTicketRepository, policy ResiliencePolicy) *ResilientRealtimeTicketRepository {
+	return &ResilientRealtimeTicketRepository{repo: repo, policy: policy}
+}
+
+// Create runs once through the circuit breaker.
+func (r *ResilientRealtimeTicketRepository) Create(ctx context.Context, ticket RealtimeTicket) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Create(ctx, ticket)
+	})
+}
+
+// Take runs once through the circuit breaker: a retry after a lost reply
+// would find the ticket already taken.
+func (r *ResilientRealtimeTicketRepository) Take(ctx context.Context, ticketHash string) (RealtimeTicket, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (RealtimeTicket, error) {
+		return r.repo.Take(ctx, ticketHash)
+	})
+}
+
+// ResilientPresenceRepository decorates a PresenceRepository with the
+// resilience policy.
+type ResilientPresenceRepository struct {
+	repo   PresenceRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientPresenceRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientPresenceRepository(repo PresenceRepository, policy ResiliencePolicy) *ResilientPresenceRepository {
+	return &ResilientPresenceRepository{repo: repo, policy: policy}
+}
+
+// Touch runs once through the circuit breaker: a retry after a lost reply
+// would not see the viewer join.
+func (r *ResilientPresenceRepository) Touch(ctx context.Context, listID primitive.ObjectID, email string, now, expiresAt time.Time) (ListPresence, bool, error) {
+	var joined bool
+	presence, err := callWithPolicy(ctx, r.policy, false, func() (ListPresence, error) {
+		var presence ListPresence
+		var err error
+		presence, joined, err = r.repo.Touch(ctx, listID, email, now, expiresAt)
+		return presence, err
+	})
+	return presence, joined, err
+}
+
+// Remove runs once through the circuit breaker, for the same reason.
+func (r *ResilientPresenceRepository) Remove(ctx context.Context, listID primitive.ObjectID, email string, now time.Time) (bool, error) {
+	return callWithPolicy(ctx, r.policy, false, func() (bool, error) {
+		return r.repo.Remove(ctx, listID, email, now)
+	})
+}
+
+// Viewers retries transient failures.
+func (r *ResilientPresenceRepository) Viewers(ctx context.Context, listID primitive.ObjectID, now time.Time) ([]ListPresence, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]ListPresence, error) {
+		return r.repo.Viewers(ctx, listID, now)
+	})
+}
+
+// ResilientTitleEditRepository decorates a TitleEditRepository with the
+// resilience policy.
+type ResilientTitleEditRepository struct {
+	repo   TitleEditRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientTitleEditRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientTitleEditRepository(repo TitleEditRepository, policy ResiliencePolicy) *ResilientTitleEditRepository {
+	return &ResilientTitleEditRepository{repo: repo, policy: policy}
+}
+
+// Append runs once through the circuit breaker.
+func (r *ResilientTitleEditRepository) Append(ctx context.Context, edit TitleEdit) error {
+	return execWithPolicy(ctx, r.policy, false, func() error {
+		return r.repo.Append(ctx, edit)
+	})
+}
+
+// Since retries transient failures.
+func (r *ResilientTitleEditRepository) Since(ctx context.Context, todoID primitive.ObjectID, version int) ([]TitleEdit, error) {
+	return callWithPolicy(ctx, r.policy, true, func() ([]TitleEdit, error) {
+		return r.repo.Since(ctx, todoID, version)
+	})
+}
+
+// ResilientSyncOpRepository decorates a SyncOpRepository with the
+// resilience policy.
+type ResilientSyncOpRepository struct {
+	repo   SyncOpRepository
+	policy ResiliencePolicy
+}
+
+// NewResilientSyncOpRepository wraps repo with retries and the circuit
+// breaker.
+func NewResilientSyncOpRepository(repo SyncOpRepository, policy ResiliencePolicy) *ResilientSyncOpRepository {
+	return &ResilientSyncOpRepository{repo: repo, policy: policy}
+}
+
+// Claim runs once: a retried insert would find its own claim.
+func (r *ResilientSyncOpRepository) Claim(ctx context.Context, op SyncOp, stale time.Time) (SyncOp, bool, error) {
+	var claimed bool
+	record, err := callWithPolicy(ctx, r.policy, false, func() (SyncOp, error) {
+		var record SyncOp
+		var err error
+		record, claimed, err = r.repo.Claim(ctx, op, stale)
+		return record, err
+	})
+	return record, claimed, err
+}
+
+// Finish retries transient failures; storing the outcome is idempotent.
+func (r *ResilientSyncOpRepository) Finish(ctx context.Context, op SyncOp) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Finish(ctx, op)
+	})
+}
+
+// Release retries transient failures.
+func (r *ResilientSyncOpRepository) Release(ctx context.Context, email, opID string) error {
+	return execWithPolicy(ctx, r.policy, true, func() error {
+		return r.repo.Release(ctx, email, opID)
+	})
+}
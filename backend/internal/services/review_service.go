@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidReviewInput indicates a rating outside 1-5 or an overly long comment.
+	ErrInvalidReviewInput = errors.New("invalid review input")
+	// ErrReviewNotAllowed is returned when the booking has not been checked out.
+	ErrReviewNotAllowed = errors.New("booking cannot be reviewed yet")
+	// ErrReviewExists is returned when the booking was already reviewed.
+	ErrReviewExists = errors.New("booking already reviewed")
+)
+
+// maxReviewComment caps the length of review comments, in characters.
+const maxReviewComment = 1000
+
+// ReviewQuery selects a page of the reviews of a room.
+type ReviewQuery struct {
+	RoomID primitive.ObjectID
+	// Offset skips that many reviews; Limit caps the result (zero means all).
+	Offset int
+	Limit  int
+}
+
+// ReviewPage is one slice of a review listing plus the total matching count.
+type ReviewPage struct {
+	Reviews []ReviewResponse
+	Total   int64
+}
+
+// ReviewRepository is the storage contract required by the review service.
+type ReviewRepository interface {
+	List(ctx context.Context, query ReviewQuery) ([]Review, error)
+	Count(ctx context.Context, query ReviewQuery) (int64, error)
+	Create(ctx context.Context, review Review) (Review, error)
+	// Ratings aggregates the reviews of the given rooms; rooms without
+	// reviews are missing from the result.
+	Ratings(ctx context.Context, roomIDs []primitive.ObjectID) (map[primitive.ObjectID]RoomRating, error)
+}
+
+// MongoReviewRepository implements ReviewRepository backed by MongoDB.
+type MongoReviewRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoReviewRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoReviewRepository(collection *mongo.Collection) *MongoReviewRepository {
+	return &MongoReviewRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique booking index, which allows a single
+// review per stay, and the index used to list and aggregate room reviews.
+func (m *MongoReviewRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "bookingId", Value: 1}}, Options: options.Index().SetUnique(true).SetName("booking_unique")},
+		{Keys: bson.D{{Key: "roomId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return err
+}
+
+// List returns the reviews of a room, newest first.
+func (m *MongoReviewRepository) List(ctx context.Context, query ReviewQuery) ([]Review, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	if query.Offset > 0 {
+		opts.SetSkip(int64(query.Offset))
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	cursor, err := m.collection.Find(ctx, bson.M{"roomId": query.RoomID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reviews []Review
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// Count returns how many reviews the room has, ignoring pagination.
+func (m *MongoReviewRepository) Count(ctx context.Context, query ReviewQuery) (int64, error) {
+	return m.collection.CountDocuments(ctx, bson.M{"roomId": query.RoomID})
+}
+
+// Create stores a review and returns it with the generated ID.
+func (m *MongoReviewRepository) Create(ctx context.Context, review Review) (Review, error) {
+	res, err := m.collection.InsertOne(ctx, review)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Review{}, ErrReviewExists
+		}
+		return Review{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		review.ID = oid
+	}
+	return review, nil
+}
+
+// Ratings groups the reviews of the rooms computing their average rating.
+func (m *MongoReviewRepository) Ratings(ctx context.Context, roomIDs []primitive.ObjectID) (map[primitive.ObjectID]RoomRating, error) {
+	cursor, err := m.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"roomId": bson.M{"$in": roomIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$roomId",
+			"average": bson.M{"$avg": "$rating"},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		RoomID     primitive.ObjectID `bson:"_id"`
+		RoomRating `bson:",inline"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	ratings := make(map[primitive.ObjectID]RoomRating, len(rows))
+	for _, row := range rows {
+		ratings[row.RoomID] = row.RoomRating
+	}
+	return ratings, nil
+}
+
+// ReviewService handles reviews of completed stays and caches the room ratings.
+type ReviewService struct {
+	reviews  ReviewRepository
+	bookings BookingRepository
+	now      func() time.Time
+	ids      IDGenerator
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[primitive.ObjectID]cachedRating
+}
+
+type cachedRating struct {
+	rating  RoomRating
+	expires time.Time
+}
+
+// NewReviewService builds a new ReviewService. Room ratings are cached for
+// ttl; a non-positive ttl disables the cache.
+func NewReviewService(reviews ReviewRepository, bookings BookingRepository, ttl time.Duration, now func() time.Time, ids IDGenerator) *ReviewService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &ReviewService{
+		reviews:  reviews,
+		bookings: bookings,
+		now:      now,
+		ids:      ids,
+		ttl:      ttl,
+		cache:    make(map[primitive.ObjectID]cachedRating),
+	}
+}
+
+// Create stores the review of a checked-out booking; each booking can be
+// reviewed once.
+func (s *ReviewService) Create(ctx context.Context, bookingID string, rating int, comment string) (ReviewResponse, error) {
+	comment = NormalizeText(comment)
+	if rating < 1 || rating > 5 || utf8.RuneCountInString(comment) > maxReviewComment {
+		return ReviewResponse{}, ErrInvalidReviewInput
+	}
+
+	objID, err := primitive.ObjectIDFromHex(bookingID)
+	if err != nil {
+		return ReviewResponse{}, ErrInvalidBookingID
+	}
+	booking, err := s.bookings.FindByID(ctx, objID)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if booking.Status != BookingCheckedOut {
+		return ReviewResponse{}, ErrReviewNotAllowed
+	}
+
+	created, err := s.reviews.Create(ctx, Review{
+		ID:        s.ids.NewID(),
+		BookingID: booking.ID,
+		RoomID:    booking.RoomID,
+		Rating:    rating,
+		Comment:   comment,
+		CreatedAt: s.now(),
+	})
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+
+	s.mu.Lock()
+	delete(s.cache, booking.RoomID)
+	s.mu.Unlock()
+	return created.ToResponse(), nil
+}
+
+// List returns a page of the reviews of a room, newest first.
+func (s *ReviewService) List(ctx context.Context, roomID string, query ReviewQuery) (ReviewPage, error) {
+	objID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return ReviewPage{}, ErrInvalidRoomID
+	}
+	if query.Offset < 0 || query.Limit < 0 {
+		return ReviewPage{}, ErrInvalidPagination
+	}
+	query.RoomID = objID
+
+	reviews, err := s.reviews.List(ctx, query)
+	if err != nil {
+		return ReviewPage{}, err
+	}
+
+	total := int64(len(reviews))
+	if query.Limit > 0 {
+		if total, err = s.reviews.Count(ctx, query); err != nil {
+			return ReviewPage{}, err
+		}
+	}
+
+	responses := make([]ReviewResponse, 0, len(reviews))
+	for _, review := range reviews {
+		responses = append(responses, review.ToResponse())
+	}
+	return ReviewPage{Reviews: responses, Total: total}, nil
+}
+
+// Ratings returns the rating of each room, aggregating only the rooms that
+// are missing from the cache or expired.
+func (s *ReviewService) Ratings(ctx context.Context, roomIDs []primitive.ObjectID) (map[primitive.ObjectID]RoomRating, error) {
+	now := s.now()
+	ratings := make(map[primitive.ObjectID]RoomRating, len(roomIDs))
+	var missing []primitive.ObjectID
+
+	s.mu.Lock()
+	for _, id := range roomIDs {
+		if cached, ok := s.cache[id]; ok && now.Before(cached.expires) {
+			ratings[id] = cached.rating
+			continue
+		}
+		missing = append(missing, id)
+	}
+	s.mu.Unlock()
+
+	if len(missing) == 0 {
+		return ratings, nil
+	}
+	computed, err := s.reviews.Ratings(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range missing {
+		rating := computed[id]
+		rating.Average = math.Round(rating.Average*10) / 10
+		ratings[id] = rating
+		if s.ttl > 0 {
+			s.cache[id] = cachedRating{rating: rating, expires: now.Add(s.ttl)}
+		}
+	}
+	return ratings, nil
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrInvalidRoomInput indicates missing or malformed room data.
+	ErrInvalidRoomInput = errors.New("invalid room input")
+	// ErrInvalidRoomID indicates the room ID could not be parsed.
+	ErrInvalidRoomID = errors.New("invalid room id")
+	// ErrRoomNumberTaken is returned when another room already uses the number.
+	ErrRoomNumberTaken = errors.New("room number already exists")
+)
+
+// Room types accepted by the API.
+var roomTypes = map[string]bool{
+	"single": true,
+	"double": true,
+	"twin":   true,
+	"suite":  true,
+	"family": true,
+}
+
+// Room statuses accepted by the API.
+const (
+	RoomAvailable   = "available"
+	RoomOccupied    = "occupied"
+	RoomCleaning    = "cleaning"
+	RoomMaintenance = "maintenance"
+)
+
+var roomStatuses = map[string]bool{
+	RoomAvailable:   true,
+	RoomOccupied:    true,
+	RoomCleaning:    true,
+	RoomMaintenance: true,
+}
+
+// RoomQuery filters room listings; empty fields match every room.
+type RoomQuery struct {
+	// PropertyID restricts the listing to one property when not zero.
+	PropertyID primitive.ObjectID
+	Number     string
+	Type       string
+	Status     string
+}
+
+// RoomUpdate models the fields that can be updated on a Room.
+type RoomUpdate struct {
+	Number    *string
+	Type      *string
+	Capacity  *int
+	Price     *float64
+	Amenities *[]string
+	Status    *string
+	UpdatedAt time.Time
+}
+
+// RoomRepository is the storage contract required by the room service.
+type RoomRepository interface {
+	List(ctx context.Context, query RoomQuery) ([]Room, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (Room, error)
+	Create(ctx context.Context, room Room) (Room, error)
+	Update(ctx context.Context, id primitive.ObjectID, update RoomUpdate) (Room, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// RoomRatings supplies the review ratings shown on rooms.
+type RoomRatings interface {
+	Ratings(ctx context.Context, roomIDs []primitive.ObjectID) (map[primitive.ObjectID]RoomRating, error)
+}
+
+// MongoRoomRepository implements RoomRepository backed by MongoDB.
+type MongoRoomRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRoomRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoRoomRepository(collection *mongo.Collection) *MongoRoomRepository {
+	return &MongoRoomRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index keeping room numbers unique per property.
+// It replaces the former chain-wide "number_unique" index, which would stop
+// two hotels from having the same room number.
+func (m *MongoRoomRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := m.collection.Indexes().DropOne(ctx, "number_unique"); err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "propertyId", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("property_number_unique"),
+	})
+	return err
+}
+
+// isIndexNotFound reports whether dropping an index failed because it (or
+// its collection) does not exist.
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26)
+}
+
+// List returns rooms matching query ordered by number.
+func (m *MongoRoomRepository) List(ctx context.Context, query RoomQuery) ([]Room, error) {
+	filter := bson.M{}
+	if !query.PropertyID.IsZero() {
+		filter["propertyId"] = query.PropertyID
+	}
+	if query.Number != "" {
+		filter["number"] = query.Number
+	}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"number": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rooms []Room
+	if err := cursor.All(ctx, &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// FindByID retrieves a room or returns ErrNotFound.
+func (m *MongoRoomRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Room, error) {
+	var room Room
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&room)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Room{}, ErrNotFound
+	}
+	return room, err
+}
+
+// Create stores a room and returns it with the generated ID.
+func (m *MongoRoomRepository) Create(ctx context.Context, room Room) (Room, error) {
+	res, err := m.collection.InsertOne(ctx, room)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Room{}, ErrRoomNumberTaken
+		}
+		return Room{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		room.ID = oid
+	}
+	return room, nil
+}
+
+// Update modifies a room and returns the updated version.
+func (m *MongoRoomRepository) Update(ctx context.Context, id primitive.ObjectID, update RoomUpdate) (Room, error) {
+	updateDoc := bson.M{"updatedAt": update.UpdatedAt}
+	if update.Number != nil {
+		updateDoc["number"] = *update.Number
+	}
+	if update.Type != nil {
+		updateDoc["type"] = *update.Type
+	}
+	if update.Capacity != nil {
+		updateDoc["capacity"] = *update.Capacity
+	}
+	if update.Price != nil {
+		updateDoc["price"] = *update.Price
+	}
+	if update.Amenities != nil {
+		updateDoc["amenities"] = *update.Amenities
+	}
+	if update.Status != nil {
+		updateDoc["status"] = *update.Status
+	}
+
+	res := m.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": updateDoc},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	)
+
+	var room Room
+	if err := res.Decode(&room); err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			return Room{}, ErrNotFound
+		case mongo.IsDuplicateKeyError(err):
+			return Room{}, ErrRoomNumberTaken
+		}
+		return Room{}, err
+	}
+	return room, nil
+}
+
+// Delete removes a room by ID.
+func (m *MongoRoomRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RoomService encapsulates business logic for hotel rooms.
+type RoomService struct {
+	repo    RoomRepository
+	ratings RoomRatings
+	now     func() time.Time
+	ids     IDGenerator
+}
+
+// NewRoomService builds a new RoomService instance. Rooms are returned with
+// their rating when ratings is not nil.
+func NewRoomService(repo RoomRepository, ratings RoomRatings, now func() time.Time, ids IDGenerator) *RoomService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &RoomService{repo: repo, ratings: ratings, now: now, ids: ids}
+}
+
+// List returns the rooms of the scoped property filtered by type and status.
+func (s *RoomService) List(ctx context.Context, query RoomQuery) ([]RoomResponse, error) {
+	query.PropertyID = ScopedProperty(ctx)
+	query.Type = normalizeKeyword(query.Type)
+	query.Status = normalizeKeyword(query.Status)
+	if (query.Type != "" && !roomTypes[query.Type]) || (query.Status != "" && !roomStatuses[query.Status]) {
+		return nil, ErrInvalidRoomInput
+	}
+
+	rooms, err := s.repo.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return s.responses(ctx, rooms...)
+}
+
+// Get returns a single room.
+func (s *RoomService) Get(ctx context.Context, id string) (RoomResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return RoomResponse{}, ErrInvalidRoomID
+	}
+
+	room, err := s.find(ctx, objID)
+	if err != nil {
+		return RoomResponse{}, err
+	}
+	return s.response(ctx, room)
+}
+
+// Create validates input and stores a new room in the scoped property; new
+// rooms start available unless a status is given.
+func (s *RoomService) Create(ctx context.Context, room Room) (RoomResponse, error) {
+	room.Number = NormalizeText(room.Number)
+	room.Type = normalizeKeyword(room.Type)
+	room.Status = normalizeKeyword(room.Status)
+	room.Amenities = normalizeAmenities(room.Amenities)
+	if room.Status == "" {
+		room.Status = RoomAvailable
+	}
+
+	if room.Number == "" || !roomTypes[room.Type] || !roomStatuses[room.Status] || room.Capacity < 1 || room.Price < 0 {
+		return RoomResponse{}, ErrInvalidRoomInput
+	}
+
+	now := s.now()
+	room.ID = s.ids.NewID()
+	room.PropertyID = nil
+	if scope := ScopedProperty(ctx); !scope.IsZero() {
+		room.PropertyID = &scope
+	}
+	room.CreatedAt = now
+	room.UpdatedAt = now
+
+	created, err := s.repo.Create(ctx, room)
+	if err != nil {
+		return RoomResponse{}, err
+	}
+	return s.response(ctx, created)
+}
+
+// Update applies the provided modification to a room.
+func (s *RoomService) Update(ctx context.Context, id string, update RoomUpdate) (RoomResponse, error) {
+	if update.Number == nil && update.Type == nil && update.Capacity == nil &&
+		update.Price == nil && update.Amenities == nil && update.Status == nil {
+		return RoomResponse{}, ErrInvalidRoomInput
+	}
+
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return RoomResponse{}, ErrInvalidRoomID
+	}
+
+	if update.Number != nil {
+		number := NormalizeText(*update.Number)
+		if number == "" {
+			return RoomResponse{}, ErrInvalidRoomInput
+		}
+		update.Number = &number
+	}
+	if update.Type != nil {
+		roomType := normalizeKeyword(*update.Type)
+		if !roomTypes[roomType] {
+			return RoomResponse{}, ErrInvalidRoomInput
+		}
+		update.Type = &roomType
+	}
+	if update.Status != nil {
+		status := normalizeKeyword(*update.Status)
+		if !roomStatuses[status] {
+			return RoomResponse{}, ErrInvalidRoomInput
+		}
+		update.Status = &status
+	}
+	if (update.Capacity != nil && *update.Capacity < 1) || (update.Price != nil && *update.Price < 0) {
+		return RoomResponse{}, ErrInvalidRoomInput
+	}
+	if update.Amenities != nil {
+		amenities := normalizeAmenities(*update.Amenities)
+		update.Amenities = &amenities
+	}
+	update.UpdatedAt = s.now()
+
+	if _, err := s.find(ctx, objID); err != nil {
+		return RoomResponse{}, err
+	}
+	updated, err := s.repo.Update(ctx, objID, update)
+	if err != nil {
+		return RoomResponse{}, err
+	}
+	return s.response(ctx, updated)
+}
+
+// Delete removes a room by ID.
+func (s *RoomService) Delete(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidRoomID
+	}
+	if _, err := s.find(ctx, objID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, objID)
+}
+
+// find loads a room, reporting rooms of other properties as ErrNotFound.
+func (s *RoomService) find(ctx context.Context, id primitive.ObjectID) (Room, error) {
+	room, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Room{}, err
+	}
+	if !inScope(ctx, room.PropertyID) {
+		return Room{}, ErrNotFound
+	}
+	return room, nil
+}
+
+// response converts a single room attaching its rating.
+func (s *RoomService) response(ctx context.Context, room Room) (RoomResponse, error) {
+	responses, err := s.responses(ctx, room)
+	if err != nil {
+		return RoomResponse{}, err
+	}
+	return responses[0], nil
+}
+
+// responses converts rooms attaching their ratings.
+func (s *RoomService) responses(ctx context.Context, rooms ...Room) ([]RoomResponse, error) {
+	responses := make([]RoomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		responses = append(responses, room.ToResponse())
+	}
+	if s.ratings == nil || len(rooms) == 0 {
+		return responses, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(rooms))
+	for _, room := range rooms {
+		ids = append(ids, room.ID)
+	}
+	ratings, err := s.ratings.Ratings(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i, room := range rooms {
+		rating := ratings[room.ID]
+		responses[i].Rating = &rating
+	}
+	return responses, nil
+}
+
+// normalizeAmenities trims, lowercases and de-duplicates amenity names.
+func normalizeAmenities(amenities []string) []string {
+	seen := make(map[string]bool, len(amenities))
+	result := make([]string, 0, len(amenities))
+	for _, amenity := range amenities {
+		amenity = normalizeKeyword(amenity)
+		if amenity == "" || seen[amenity] {
+			continue
+		}
+		seen[amenity] = true
+		result = append(result, amenity)
+	}
+	return result
+}
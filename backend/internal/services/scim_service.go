@@ -0,0 +1,449 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// SCIM schema URNs (RFC 7643 and RFC 7644).
+const (
+	SCIMUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchSchema = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIMUsersPath is where the SCIM users are served; it makes the location
+// of each resource.
+const SCIMUsersPath = "/scim/v2/Users"
+
+// SCIMMaxCount caps the users returned by one SCIM listing.
+const SCIMMaxCount = 200
+
+var (
+	// ErrInvalidSCIMToken is returned for unknown or revoked SCIM tokens.
+	ErrInvalidSCIMToken = errors.New("invalid scim token")
+	// ErrInvalidSCIMUser indicates a user without a valid userName or with
+	// a role that is not a staff role.
+	ErrInvalidSCIMUser = errors.New("invalid scim user")
+	// ErrInvalidSCIMFilter is returned for filters other than "eq"
+	// comparisons of userName, emails.value or active joined with "and".
+	ErrInvalidSCIMFilter = errors.New("invalid scim filter")
+	// ErrInvalidSCIMPatch is returned for patch operations on attributes
+	// other than active and roles.
+	ErrInvalidSCIMPatch = errors.New("invalid scim patch")
+)
+
+// PropertySCIM lets the identity provider of a property provision its
+// staff through SCIM. Only the hash of the token is stored; users created
+// without a role get DefaultRole.
+type PropertySCIM struct {
+	TokenHash   string    `bson:"tokenHash"`
+	DefaultRole string    `bson:"defaultRole,omitempty"`
+	IssuedAt    time.Time `bson:"issuedAt"`
+}
+
+// SCIMToken is a SCIM token as issued; Token is only shown once.
+type SCIMToken struct {
+	PropertyID  string    `json:"propertyId" xml:"propertyId"`
+	Token       string    `json:"token" xml:"token"`
+	DefaultRole string    `json:"defaultRole,omitempty" xml:"defaultRole,omitempty"`
+	IssuedAt    time.Time `json:"issuedAt" xml:"issuedAt"`
+}
+
+// SCIMValue is a multi-valued attribute entry such as an email or a role.
+type SCIMValue struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMMeta describes a SCIM resource.
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	Location     string    `json:"location"`
+}
+
+// SCIMUser is a user in the SCIM core schema. The email is both the
+// userName and the id, since it is what identifies accounts.
+type SCIMUser struct {
+	Schemas  []string    `json:"schemas"`
+	ID       string      `json:"id"`
+	UserName string      `json:"userName"`
+	Active   bool        `json:"active"`
+	Emails   []SCIMValue `json:"emails"`
+	Roles    []SCIMValue `json:"roles,omitempty"`
+	Meta     SCIMMeta    `json:"meta"`
+}
+
+// ToSCIM converts the User into its SCIM representation.
+func (u User) ToSCIM() SCIMUser {
+	user := SCIMUser{
+		Schemas:  []string{SCIMUserSchema},
+		ID:       u.Email,
+		UserName: u.Email,
+		Active:   u.SuspendedAt == nil,
+		Emails:   []SCIMValue{{Value: u.Email, Primary: true}},
+		Meta:     SCIMMeta{ResourceType: "User", Created: u.CreatedAt, Location: SCIMUsersPath + "/" + url.PathEscape(u.Email)},
+	}
+	if u.Role != "" {
+		user.Roles = []SCIMValue{{Value: u.Role, Primary: true}}
+	}
+	return user
+}
+
+// SCIMUserInput is a user sent by the identity provider. Email falls back
+// to the primary email and Role to the default role of the property.
+type SCIMUserInput struct {
+	UserName string
+	Emails   []SCIMValue
+	Roles    []SCIMValue
+	Active   *bool
+}
+
+// SCIMPatchOperation is one operation of a SCIM PATCH request.
+type SCIMPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// SCIMService serves the SCIM 2.0 users of each property, so the identity
+// provider of a hotel creates, updates and deactivates its staff.
+type SCIMService struct {
+	properties PropertyRepository
+	users      UserRepository
+	outbox     Outbox
+	now        func() time.Time
+}
+
+// NewSCIMService builds a new SCIMService instance.
+func NewSCIMService(properties PropertyRepository, users UserRepository, outbox Outbox, now func() time.Time) *SCIMService {
+	if now == nil {
+		now = time.Now
+	}
+	return &SCIMService{properties: properties, users: users, outbox: outbox, now: now}
+}
+
+func (s *SCIMService) property(ctx context.Context, id string) (Property, error) {
+	oid, err := primitive.ObjectIDFromHex(NormalizeText(id))
+	if err != nil {
+		return Property{}, ErrInvalidPropertyID
+	}
+	property, err := s.properties.FindByID(ctx, oid)
+	if errors.Is(err, ErrNotFound) {
+		return Property{}, ErrPropertyNotFound
+	}
+	return property, err
+}
+
+// IssueToken creates the SCIM token of a property, replacing the previous
+// one.
+func (s *SCIMService) IssueToken(ctx context.Context, propertyID, defaultRole string) (SCIMToken, error) {
+	defaultRole = normalizeKeyword(defaultRole)
+	if defaultRole != "" && !staffRoles[defaultRole] {
+		return SCIMToken{}, ErrInvalidRole
+	}
+	property, err := s.property(ctx, propertyID)
+	if err != nil {
+		return SCIMToken{}, err
+	}
+	token, err := newSessionToken()
+	if err != nil {
+		return SCIMToken{}, err
+	}
+
+	scim := PropertySCIM{TokenHash: hashToken(token), DefaultRole: defaultRole, IssuedAt: s.now()}
+	if _, err := s.properties.SetSCIM(ctx, property.ID, &scim); err != nil {
+		return SCIMToken{}, err
+	}
+	return SCIMToken{PropertyID: property.ID.Hex(), Token: token, DefaultRole: defaultRole, IssuedAt: scim.IssuedAt}, nil
+}
+
+// RevokeToken removes the SCIM token of a property; the users it created
+// are kept.
+func (s *SCIMService) RevokeToken(ctx context.Context, propertyID string) error {
+	property, err := s.property(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	_, err = s.properties.SetSCIM(ctx, property.ID, nil)
+	return err
+}
+
+// Authenticate returns the property a SCIM token belongs to.
+func (s *SCIMService) Authenticate(ctx context.Context, token string) (Property, error) {
+	if token == "" {
+		return Property{}, ErrInvalidSCIMToken
+	}
+	property, err := s.properties.FindBySCIMToken(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return Property{}, ErrInvalidSCIMToken
+	}
+	return property, err
+}
+
+// scimRole picks the primary role of roles, or the first one.
+func scimRole(roles []SCIMValue) (string, error) {
+	if len(roles) == 0 {
+		return "", nil
+	}
+	role := roles[0]
+	for _, r := range roles {
+		if r.Primary {
+			role = r
+			break
+		}
+	}
+	value := normalizeKeyword(role.Value)
+	if !staffRoles[value] {
+		return "", ErrInvalidSCIMUser
+	}
+	return value, nil
+}
+
+// Create provisions a user as staff of property. Users that already
+// exist, at this property or elsewhere, return ErrUserAlreadyExists.
+func (s *SCIMService) Create(ctx context.Context, property Property, input SCIMUserInput) (User, error) {
+	email := NormalizeEmail(input.UserName)
+	if email == "" {
+		for _, e := range input.Emails {
+			if e.Primary || email == "" {
+				email = NormalizeEmail(e.Value)
+			}
+		}
+	}
+	if !strings.Contains(email, "@") {
+		return User{}, ErrInvalidSCIMUser
+	}
+	role, err := scimRole(input.Roles)
+	if err != nil {
+		return User{}, err
+	}
+	if role == "" && property.SCIM != nil {
+		role = property.SCIM.DefaultRole
+	}
+
+	_, err = s.users.FindByEmail(ctx, email)
+	if err == nil {
+		return User{}, ErrUserAlreadyExists
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return User{}, err
+	}
+
+	user := User{Email: email, Role: role, PropertyID: &property.ID, CreatedAt: s.now()}
+	if input.Active != nil && !*input.Active {
+		user.SuspendedAt = &user.CreatedAt
+	}
+	err = s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if err := s.users.Insert(ctx, user); err != nil {
+			return nil, err
+		}
+		return newEvents(events.UserRegistered, user.Email, user.ToPublic(), user.CreatedAt)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// Get returns a user of property, or ErrNotFound for users of other
+// properties and customers.
+func (s *SCIMService) Get(ctx context.Context, property Property, id string) (User, error) {
+	user, err := s.users.FindByEmail(ctx, NormalizeEmail(id))
+	if err != nil {
+		return User{}, err
+	}
+	if user.PropertyID == nil || *user.PropertyID != property.ID {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+var (
+	scimAnd        = regexp.MustCompile(`(?i)\s+and\s+`)
+	scimComparison = regexp.MustCompile(`(?i)^([a-z.]+)\s+eq\s+(.+)$`)
+)
+
+// parseSCIMFilter narrows query with filter, e.g.
+// `userName eq "ana@hotel.com" and active eq true`.
+func parseSCIMFilter(filter string, query *UserQuery) error {
+	filter = NormalizeText(filter)
+	if filter == "" {
+		return nil
+	}
+	for _, term := range scimAnd.Split(filter, -1) {
+		match := scimComparison.FindStringSubmatch(NormalizeText(term))
+		if match == nil {
+			return ErrInvalidSCIMFilter
+		}
+		switch attribute, value := strings.ToLower(match[1]), match[2]; attribute {
+		case "username", "emails.value":
+			email, err := strconv.Unquote(value)
+			if err != nil {
+				return ErrInvalidSCIMFilter
+			}
+			query.Email = NormalizeEmail(email)
+		case "active":
+			active, err := strconv.ParseBool(value)
+			if err != nil {
+				return ErrInvalidSCIMFilter
+			}
+			suspended := !active
+			query.Suspended = &suspended
+		default:
+			return ErrInvalidSCIMFilter
+		}
+	}
+	return nil
+}
+
+// List returns the page of the users of property matching filter that
+// starts at startIndex (1-based), with up to count users, and the total
+// of matching users.
+func (s *SCIMService) List(ctx context.Context, property Property, filter string, startIndex, count int) ([]User, int64, error) {
+	query := UserQuery{PropertyID: &property.ID, Offset: max(startIndex, 1) - 1, Limit: min(max(count, 0), SCIMMaxCount)}
+	if err := parseSCIMFilter(filter, &query); err != nil {
+		return nil, 0, err
+	}
+	total, err := s.users.Count(ctx, query)
+	if err != nil || query.Limit == 0 {
+		return []User{}, total, err
+	}
+	found, err := s.users.Search(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	users := make([]User, 0, len(found))
+	for _, u := range found {
+		users = append(users, u.User)
+	}
+	return users, total, nil
+}
+
+// scimBool reads booleans, which some providers send as strings.
+func scimBool(value any) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// scimRoles reads the value of a roles operation.
+func scimRoles(value any) ([]SCIMValue, bool) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, false
+	}
+	roles := make([]SCIMValue, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value, _ := entry["value"].(string)
+		primary, _ := scimBool(entry["primary"])
+		roles = append(roles, SCIMValue{Value: value, Primary: primary})
+	}
+	return roles, true
+}
+
+// Patch applies add and replace operations on active and roles to a user
+// of property. Every operation is checked before any is applied.
+func (s *SCIMService) Patch(ctx context.Context, property Property, id string, operations []SCIMPatchOperation) (User, error) {
+	user, err := s.Get(ctx, property, id)
+	if err != nil {
+		return User{}, err
+	}
+
+	var active *bool
+	role := user.Role
+	set := func(path string, value any) error {
+		switch strings.ToLower(path) {
+		case "active":
+			b, ok := scimBool(value)
+			if !ok {
+				return ErrInvalidSCIMPatch
+			}
+			active = &b
+		case "roles":
+			roles, ok := scimRoles(value)
+			if !ok {
+				return ErrInvalidSCIMPatch
+			}
+			if role, err = scimRole(roles); err != nil {
+				return ErrInvalidSCIMPatch
+			}
+		default:
+			return ErrInvalidSCIMPatch
+		}
+		return nil
+	}
+	for _, op := range operations {
+		if kind := strings.ToLower(op.Op); kind != "add" && kind != "replace" {
+			return User{}, ErrInvalidSCIMPatch
+		}
+		if op.Path != "" {
+			if err := set(op.Path, op.Value); err != nil {
+				return User{}, err
+			}
+			continue
+		}
+		values, ok := op.Value.(map[string]any)
+		if !ok {
+			return User{}, ErrInvalidSCIMPatch
+		}
+		for path, value := range values {
+			if err := set(path, value); err != nil {
+				return User{}, err
+			}
+		}
+	}
+
+	if role != user.Role {
+		if user, err = s.users.SetRole(ctx, user.Email, role); err != nil {
+			return User{}, err
+		}
+	}
+	if active != nil {
+		return s.setActive(ctx, user, *active)
+	}
+	return user, nil
+}
+
+// Deactivate suspends a user of property, which closes its sessions; the
+// account is kept so the provider can activate it again.
+func (s *SCIMService) Deactivate(ctx context.Context, property Property, id string) (User, error) {
+	user, err := s.Get(ctx, property, id)
+	if err != nil {
+		return User{}, err
+	}
+	return s.setActive(ctx, user, false)
+}
+
+// setActive suspends or reactivates user; suspending it again keeps the
+// original date.
+func (s *SCIMService) setActive(ctx context.Context, user User, active bool) (User, error) {
+	switch {
+	case active && user.SuspendedAt != nil:
+		return s.users.SetSuspended(ctx, user.Email, nil)
+	case !active && user.SuspendedAt == nil:
+		at := s.now()
+		return s.users.SetSuspended(ctx, user.Email, &at)
+	}
+	return user, nil
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+var (
+	// ErrInvalidSession is returned for unknown or expired session tokens.
+	ErrInvalidSession = errors.New("invalid session")
+	// ErrInvalidImpersonation indicates an impersonation without operator
+	// or reason.
+	ErrInvalidImpersonation = errors.New("invalid impersonation")
+	// ErrImpersonationForbidden is returned for staff accounts, which
+	// support cannot impersonate.
+	ErrImpersonationForbidden = errors.New("impersonation forbidden")
+	// ErrInvalidSessionID indicates the session ID could not be parsed.
+	ErrInvalidSessionID = errors.New("invalid session id")
+)
+
+// SessionRepository is the storage contract required by the session service.
+type SessionRepository interface {
+	Create(ctx context.Context, session Session) error
+	// Find returns the session with the token hash or ErrNotFound.
+	Find(ctx context.Context, tokenHash string) (Session, error)
+	// ListByEmail returns the sessions of email, newest first.
+	ListByEmail(ctx context.Context, email string) ([]Session, error)
+	// Delete removes a session of email or returns ErrNotFound.
+	Delete(ctx context.Context, email string, id primitive.ObjectID) error
+}
+
+// MongoSessionRepository implements SessionRepository backed by MongoDB.
+type MongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSessionRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoSessionRepository(collection *mongo.Collection) *MongoSessionRepository {
+	return &MongoSessionRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique token index, the index used to list the
+// sessions of an account and a TTL index that lets MongoDB purge expired
+// sessions.
+func (m *MongoSessionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true).SetName("token_unique")},
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create stores a session.
+func (m *MongoSessionRepository) Create(ctx context.Context, session Session) error {
+	_, err := m.collection.InsertOne(ctx, session)
+	return err
+}
+
+// Find retrieves a session by token hash or returns ErrNotFound.
+func (m *MongoSessionRepository) Find(ctx context.Context, tokenHash string) (Session, error) {
+	var session Session
+	err := m.collection.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Session{}, ErrNotFound
+	}
+	return session, err
+}
+
+// ListByEmail implements SessionRepository.
+func (m *MongoSessionRepository) ListByEmail(ctx context.Context, email string) ([]Session, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"email": email},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var sessions []Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Delete implements SessionRepository.
+func (m *MongoSessionRepository) Delete(ctx context.Context, email string, id primitive.ObjectID) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id, "email": email})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SessionService issues bearer tokens on login and resolves them back to
+// the user.
+type SessionService struct {
+	sessions SessionRepository
+	logins   LoginRepository
+	users    UserRepository
+	outbox   Outbox
+	ttl      time.Duration
+	// impersonationTTL bounds the sessions issued to support staff.
+	impersonationTTL time.Duration
+	now              func() time.Time
+	ids              IDGenerator
+}
+
+// NewSessionService builds a SessionService whose tokens last ttl and whose
+// impersonation tokens last impersonationTTL; logins keeps the login
+// history and outbox stores the user.impersonated audit events.
+func NewSessionService(sessions SessionRepository, logins LoginRepository, users UserRepository, outbox Outbox, ttl, impersonationTTL time.Duration, now func() time.Time, ids IDGenerator) *SessionService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &SessionService{
+		sessions:         sessions,
+		logins:           logins,
+		users:            users,
+		outbox:           outbox,
+		ttl:              ttl,
+		impersonationTTL: impersonationTTL,
+		now:              now,
+		ids:              ids,
+	}
+}
+
+// Start opens a session for email from client, records the login in the
+// history and returns the bearer token.
+func (s *SessionService) Start(ctx context.Context, email string, client Client) (string, time.Time, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := s.now()
+	session := Session{
+		ID:        s.ids.NewID(),
+		TokenHash: hashToken(token),
+		Email:     NormalizeEmail(email),
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	err = s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if err := s.sessions.Create(ctx, session); err != nil {
+			return nil, err
+		}
+		return nil, s.logins.Record(ctx, LoginRecord{
+			Email:     session.Email,
+			SessionID: session.ID,
+			IP:        session.IP,
+			UserAgent: session.UserAgent,
+			At:        now,
+		})
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, session.ExpiresAt, nil
+}
+
+// Impersonation is a short-lived session issued to a support operator to
+// act as a user.
+type Impersonation struct {
+	Token     string    `json:"token"`
+	Email     string    `json:"email"`
+	Operator  string    `json:"operator"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Impersonate opens a session as email for operator. The session lasts the
+// impersonation TTL and is audited through a user.impersonated event stored
+// with it, carrying the operator and the reason.
+func (s *SessionService) Impersonate(ctx context.Context, email, operator, reason string) (Impersonation, error) {
+	email = NormalizeEmail(email)
+	operator = NormalizeText(operator)
+	reason = NormalizeText(reason)
+	if operator == "" || reason == "" {
+		return Impersonation{}, ErrInvalidImpersonation
+	}
+
+	user, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		return Impersonation{}, err
+	}
+	if user.Role != "" {
+		return Impersonation{}, ErrImpersonationForbidden
+	}
+	if user.SuspendedAt != nil {
+		return Impersonation{}, ErrAccountSuspended
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		return Impersonation{}, err
+	}
+	now := s.now()
+	session := Session{
+		ID:             s.ids.NewID(),
+		TokenHash:      hashToken(token),
+		Email:          email,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.impersonationTTL),
+		ImpersonatedBy: operator,
+	}
+	err = s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if err := s.sessions.Create(ctx, session); err != nil {
+			return nil, err
+		}
+		return newEvents(events.UserImpersonated, email, map[string]any{
+			"email":     email,
+			"operator":  operator,
+			"reason":    reason,
+			"expiresAt": session.ExpiresAt.UTC(),
+		}, now)
+	})
+	if err != nil {
+		return Impersonation{}, err
+	}
+	return Impersonation{Token: token, Email: email, Operator: operator, ExpiresAt: session.ExpiresAt}, nil
+}
+
+// Resolve returns the user owning token and its session. The role and the
+// suspension are read from the user on every call, so they apply to open
+// sessions right away.
+func (s *SessionService) Resolve(ctx context.Context, token string) (User, Session, error) {
+	session, err := s.sessions.Find(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) || (err == nil && !s.now().Before(session.ExpiresAt)) {
+		return User{}, Session{}, ErrInvalidSession
+	}
+	if err != nil {
+		return User{}, Session{}, err
+	}
+
+	user, err := s.users.FindByEmail(ctx, session.Email)
+	if errors.Is(err, ErrNotFound) || (err == nil && user.SuspendedAt != nil) {
+		return User{}, Session{}, ErrInvalidSession
+	}
+	return user, session, err
+}
+
+// List returns the open sessions of email, newest first, flagging the one
+// with ID current.
+func (s *SessionService) List(ctx context.Context, email, current string) ([]SessionResponse, error) {
+	sessions, err := s.sessions.ListByEmail(ctx, NormalizeEmail(email))
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	responses := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		// MongoDB purges expired sessions only every minute or so.
+		if !now.Before(session.ExpiresAt) {
+			continue
+		}
+		response := session.ToResponse()
+		response.Current = response.ID == current
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// Revoke closes a session of email; its token stops working right away.
+func (s *SessionService) Revoke(ctx context.Context, email, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidSessionID
+	}
+	return s.sessions.Delete(ctx, NormalizeEmail(email), oid)
+}
+
+// Logins returns the most recent logins of email, newest first.
+func (s *SessionService) Logins(ctx context.Context, email string) ([]LoginResponse, error) {
+	logins, err := s.logins.List(ctx, NormalizeEmail(email), LoginHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]LoginResponse, 0, len(logins))
+	for _, login := range logins {
+		responses = append(responses, login.ToResponse())
+	}
+	return responses, nil
+}
+
+func newSessionToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
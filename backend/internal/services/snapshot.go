@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SnapshotCollections are the collections copied to QA by CopyAnonymized:
+// those of a backup except the passkeys, whose keys belong to real
+// authenticators, and the todo attachments, whose files cannot be
+// anonymized.
+var SnapshotCollections = slices.DeleteFunc(slices.Clone(BackupCollections), func(name string) bool {
+	return name == "passkeys" || name == "todo_attachments"
+})
+
+// anonymousDomain is the domain of the anonymized emails; .test is reserved
+// and never delivers.
+const anonymousDomain = "anon.test"
+
+// Anonymizer rewrites documents so they carry no personal data while
+// keeping volumes and relations: every email becomes the same alias in
+// every collection, passwords are replaced by one known password, tokens
+// and secrets are dropped, and free text is shuffled between documents.
+type Anonymizer struct {
+	secret   []byte
+	password string
+	random   *rand.Rand
+}
+
+// NewAnonymizer builds an anonymizer. The aliases are an HMAC of the email
+// with secret, so they cannot be reversed without it; password is set on
+// every account so QA can sign in as anyone. The same seed shuffles the
+// same way.
+func NewAnonymizer(secret []byte, password string, seed uint64) *Anonymizer {
+	return &Anonymizer{secret: secret, password: password, random: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Email returns the alias of email.
+func (a *Anonymizer) Email(email string) string {
+	if email == "" {
+		return ""
+	}
+	return "user-" + a.digest(NormalizeEmail(email))[:16] + "@" + anonymousDomain
+}
+
+func (a *Anonymizer) digest(value string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// shuffledFields are the free-text fields shuffled between the documents of
+// a collection.
+var shuffledFields = map[string][]string{
+	"todos":         {"title", "previews"},
+	"reviews":       {"comment"},
+	"todo_comments": {"body"},
+	"todo_lists":    {"name"},
+}
+
+// Collection anonymizes the documents of collection.
+func (a *Anonymizer) Collection(collection string, docs []bson.Raw) ([]bson.Raw, error) {
+	parsed := make([]bson.D, len(docs))
+	for i, raw := range docs {
+		if err := bson.Unmarshal(raw, &parsed[i]); err != nil {
+			return nil, err
+		}
+		parsed[i] = a.document(parsed[i])
+		a.scrubCollection(collection, parsed[i])
+	}
+	for _, field := range shuffledFields[collection] {
+		a.shuffle(parsed, field)
+	}
+
+	out := make([]bson.Raw, len(parsed))
+	for i, doc := range parsed {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = raw
+	}
+	return out, nil
+}
+
+// document applies the rules that hold in every collection and at any
+// depth.
+func (a *Anonymizer) document(doc bson.D) bson.D {
+	kept := doc[:0]
+	for _, field := range doc {
+		key := strings.ToLower(field.Key)
+		switch {
+		case strings.Contains(key, "token") || strings.Contains(key, "secret"):
+			continue
+		case key == "password":
+			field.Value = a.password
+		case key == "email" || key == "impersonatedby":
+			if email, ok := field.Value.(string); ok {
+				field.Value = a.Email(email)
+			}
+		default:
+			field.Value = a.value(field.Value)
+		}
+		kept = append(kept, field)
+	}
+	return kept
+}
+
+func (a *Anonymizer) value(value any) any {
+	switch v := value.(type) {
+	case bson.D:
+		return a.document(v)
+	case bson.A:
+		for i := range v {
+			v[i] = a.value(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// scrubCollection applies the rules of the fields that only hold personal
+// data in collection.
+func (a *Anonymizer) scrubCollection(collection string, doc bson.D) {
+	for i, field := range doc {
+		text, _ := field.Value.(string)
+		switch {
+		case collection == "mail_opt_outs" && field.Key == "_id":
+			doc[i].Value = a.Email(text)
+		case collection == "guests" && field.Key == "name":
+			doc[i].Value = "Huesped " + a.digest(text)[:8]
+		case collection == "guests" && field.Key == "document":
+			doc[i].Value = digits(a.digest(text), len(text))
+		case collection == "guests" && field.Key == "phone" && text != "":
+			doc[i].Value = "+00 " + digits(a.digest(text), 10)
+		case collection == "logins" && field.Key == "ip":
+			doc[i].Value = "192.0.2.1"
+		case collection == "todos" && field.Key == "assignee":
+			doc[i].Value = a.Email(text)
+		case collection == "notifications" && field.Key == "author":
+			doc[i].Value = a.Email(text)
+		case collection == "todo_comments" && field.Key == "body":
+			doc[i].Value = replaceMentions(text, a.Email)
+		case collection == "todo_comments" && field.Key == "mentions":
+			mentions, _ := field.Value.(bson.A)
+			for j, mention := range mentions {
+				if email, ok := mention.(string); ok {
+					mentions[j] = a.Email(email)
+				}
+			}
+		}
+	}
+}
+
+// digits maps a hex digest to n decimal digits.
+func digits(digest string, n int) string {
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		out.WriteByte('0' + digest[i%len(digest)]%10)
+	}
+	return out.String()
+}
+
+// shuffle permutes the values of field between docs.
+func (a *Anonymizer) shuffle(docs []bson.D, field string) {
+	type position struct{ doc, index int }
+	var positions []position
+	var values []any
+	for i, doc := range docs {
+		for j, f := range doc {
+			if f.Key == field {
+				positions = append(positions, position{i, j})
+				values = append(values, f.Value)
+			}
+		}
+	}
+	a.random.Shuffle(len(values), func(i, j int) { values[i], values[j] = values[j], values[i] })
+	for k, p := range positions {
+		docs[p.doc][p.index].Value = values[k]
+	}
+}
+
+// CopyAnonymized replaces the SnapshotCollections of target with the
+// anonymized documents of source and returns how many were copied of each.
+// A collection is held in memory while it is shuffled.
+func CopyAnonymized(ctx context.Context, source, target BackupRepository, anonymizer *Anonymizer) (map[string]int64, error) {
+	copied := map[string]int64{}
+	for _, collection := range SnapshotCollections {
+		var docs []bson.Raw
+		err := source.Export(ctx, collection, func(doc bson.Raw) error {
+			docs = append(docs, slices.Clone(doc))
+			return nil
+		})
+		if err != nil {
+			return copied, fmt.Errorf("%s: %w", collection, err)
+		}
+		anonymized, err := anonymizer.Collection(collection, docs)
+		if err != nil {
+			return copied, fmt.Errorf("%s: %w", collection, err)
+		}
+		if err := target.Replace(ctx, collection, anonymized); err != nil {
+			return copied, fmt.Errorf("%s: %w", collection, err)
+		}
+		copied[collection] = int64(len(anonymized))
+	}
+	return copied, nil
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/oidc"
+)
+
+// SSOStateTTL is how long the browser has to come back from the identity
+// provider.
+const SSOStateTTL = 10 * time.Minute
+
+var (
+	// ErrInvalidSSOConfig indicates a missing or malformed issuer or client
+	// ID, or an unknown default role.
+	ErrInvalidSSOConfig = errors.New("invalid sso config")
+	// ErrSSONotConfigured is returned for properties without an identity
+	// provider.
+	ErrSSONotConfigured = errors.New("sso not configured")
+	// ErrInvalidSSOState is returned for unknown, expired or already used
+	// login states.
+	ErrInvalidSSOState = errors.New("invalid sso state")
+	// ErrSSORejected is returned when the provider refuses the login, or
+	// for accounts that are not staff of the property.
+	ErrSSORejected = errors.New("sso login rejected")
+	// ErrSSOUnavailable wraps the failures reaching the provider.
+	ErrSSOUnavailable = errors.New("sso provider unavailable")
+)
+
+// PropertySSO is the OpenID Connect provider of a property. New users
+// signing in through it become staff of the property with DefaultRole.
+type PropertySSO struct {
+	// Issuer is the issuer URL or its discovery URL.
+	Issuer       string `bson:"issuer"`
+	ClientID     string `bson:"clientId"`
+	ClientSecret string `bson:"clientSecret"`
+	DefaultRole  string `bson:"defaultRole,omitempty"`
+}
+
+// PropertySSOResponse is the provider configuration exposed to the
+// administrator; the client secret is never returned.
+type PropertySSOResponse struct {
+	PropertyID  string `json:"propertyId" xml:"propertyId"`
+	Issuer      string `json:"issuer" xml:"issuer"`
+	ClientID    string `json:"clientId" xml:"clientId"`
+	DefaultRole string `json:"defaultRole,omitempty" xml:"defaultRole,omitempty"`
+}
+
+func (s PropertySSO) config() oidc.Config {
+	return oidc.Config{Issuer: s.Issuer, ClientID: s.ClientID, ClientSecret: s.ClientSecret}
+}
+
+// SSOState is a pending login at a provider; it is used once. Only the
+// hash of the state sent through the browser is stored.
+type SSOState struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	StateHash  string             `bson:"stateHash"`
+	PropertyID primitive.ObjectID `bson:"propertyId"`
+	Nonce      string             `bson:"nonce"`
+	// LinkEmail is the signed-in account that started the login to bind
+	// itself to the property (see BeginLink).
+	LinkEmail string    `bson:"linkEmail,omitempty"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// SSOStateRepository keeps the pending SSO logins.
+type SSOStateRepository interface {
+	Create(ctx context.Context, state SSOState) error
+	// Take removes and returns the state with the hash, or returns
+	// ErrNotFound.
+	Take(ctx context.Context, stateHash string) (SSOState, error)
+}
+
+// MongoSSOStateRepository implements SSOStateRepository backed by MongoDB.
+type MongoSSOStateRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSSOStateRepository creates a repository over collection.
+func NewMongoSSOStateRepository(collection *mongo.Collection) *MongoSSOStateRepository {
+	return &MongoSSOStateRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique state index and a TTL index that lets
+// MongoDB purge abandoned logins.
+func (m *MongoSSOStateRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "stateHash", Value: 1}}, Options: options.Index().SetUnique(true).SetName("state_unique")},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// Create implements SSOStateRepository.
+func (m *MongoSSOStateRepository) Create(ctx context.Context, state SSOState) error {
+	_, err := m.collection.InsertOne(ctx, state)
+	return err
+}
+
+// Take implements SSOStateRepository; deleting on read keeps a state from
+// being used twice.
+func (m *MongoSSOStateRepository) Take(ctx context.Context, stateHash string) (SSOState, error) {
+	var state SSOState
+	err := m.collection.FindOneAndDelete(ctx, bson.M{"stateHash": stateHash}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return SSOState{}, ErrNotFound
+	}
+	return state, err
+}
+
+// SSOService signs the staff of a property in through the OpenID Connect
+// provider of the property, creating their account on the first login.
+type SSOService struct {
+	properties PropertyRepository
+	states     SSOStateRepository
+	users      UserRepository
+	outbox     Outbox
+	idp        *oidc.Client
+	// redirectURL is the page of the frontend the provider sends the
+	// browser back to.
+	redirectURL string
+	now         func() time.Time
+	ids         IDGenerator
+}
+
+// NewSSOService builds a new SSOService instance; a nil idp uses a default
+// client.
+func NewSSOService(properties PropertyRepository, states SSOStateRepository, users UserRepository, outbox Outbox, idp *oidc.Client, redirectURL string, now func() time.Time, ids IDGenerator) *SSOService {
+	if idp == nil {
+		idp = oidc.NewClient(nil)
+	}
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &SSOService{properties: properties, states: states, users: users, outbox: outbox, idp: idp, redirectURL: redirectURL, now: now, ids: ids}
+}
+
+func (s *SSOService) property(ctx context.Context, id string) (Property, error) {
+	oid, err := primitive.ObjectIDFromHex(NormalizeText(id))
+	if err != nil {
+		return Property{}, ErrInvalidPropertyID
+	}
+	property, err := s.properties.FindByID(ctx, oid)
+	if errors.Is(err, ErrNotFound) {
+		return Property{}, ErrPropertyNotFound
+	}
+	return property, err
+}
+
+// Configure sets the provider of a property. The discovery document is
+// read right away, so a wrong issuer is reported to the administrator
+// instead of to the staff.
+func (s *SSOService) Configure(ctx context.Context, propertyID string, sso PropertySSO) (PropertySSOResponse, error) {
+	sso.Issuer, sso.ClientID = NormalizeText(sso.Issuer), NormalizeText(sso.ClientID)
+	sso.DefaultRole = normalizeKeyword(sso.DefaultRole)
+	issuer, err := url.Parse(sso.Issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" ||
+		sso.ClientID == "" || (sso.DefaultRole != "" && !staffRoles[sso.DefaultRole]) {
+		return PropertySSOResponse{}, ErrInvalidSSOConfig
+	}
+	property, err := s.property(ctx, propertyID)
+	if err != nil {
+		return PropertySSOResponse{}, err
+	}
+	if _, err := s.idp.Discover(ctx, sso.config()); err != nil {
+		return PropertySSOResponse{}, fmt.Errorf("%w: %v", ErrSSOUnavailable, err)
+	}
+
+	if _, err := s.properties.SetSSO(ctx, property.ID, &sso); err != nil {
+		return PropertySSOResponse{}, err
+	}
+	return PropertySSOResponse{PropertyID: property.ID.Hex(), Issuer: sso.Issuer, ClientID: sso.ClientID, DefaultRole: sso.DefaultRole}, nil
+}
+
+// Disable removes the provider of a property; its staff keeps the accounts
+// created through it.
+func (s *SSOService) Disable(ctx context.Context, propertyID string) error {
+	property, err := s.property(ctx, propertyID)
+	if err != nil {
+		return err
+	}
+	_, err = s.properties.SetSSO(ctx, property.ID, nil)
+	return err
+}
+
+// Begin starts a login at the provider of a property and returns the URL
+// the browser goes to.
+func (s *SSOService) Begin(ctx context.Context, propertyID string) (string, error) {
+	return s.begin(ctx, propertyID, "")
+}
+
+// BeginLink starts a login at the provider of a property for the signed-in
+// account of email, which has no property yet. If the provider asserts the
+// same email, Finish binds the account to the property: the session proves
+// the user controls the account and the provider that it belongs to the
+// property.
+func (s *SSOService) BeginLink(ctx context.Context, propertyID, email string) (string, error) {
+	return s.begin(ctx, propertyID, NormalizeEmail(email))
+}
+
+func (s *SSOService) begin(ctx context.Context, propertyID, linkEmail string) (string, error) {
+	property, err := s.property(ctx, propertyID)
+	if err != nil {
+		return "", err
+	}
+	if property.SSO == nil {
+		return "", ErrSSONotConfigured
+	}
+	cfg := property.SSO.config()
+	meta, err := s.idp.Discover(ctx, cfg)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrSSOUnavailable, err)
+	}
+
+	state, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := newSessionToken()
+	if err != nil {
+		return "", err
+	}
+	err = s.states.Create(ctx, SSOState{
+		ID:         s.ids.NewID(),
+		StateHash:  hashToken(state),
+		PropertyID: property.ID,
+		Nonce:      nonce,
+		LinkEmail:  linkEmail,
+		ExpiresAt:  s.now().Add(SSOStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return oidc.AuthorizationURL(meta, cfg, s.redirectURL, state, nonce), nil
+}
+
+// Finish completes the login the provider sent back with state and code,
+// and returns the user to open a session for. A user signing in for the
+// first time is created as staff of the property with its default role.
+// Existing accounts must already be staff of the property, except the
+// account that started the login with BeginLink, which is bound to it;
+// any other account is rejected, as the provider only vouches for the
+// email and not for who controls the account.
+func (s *SSOService) Finish(ctx context.Context, state, code string) (User, error) {
+	pending, err := s.states.Take(ctx, hashToken(NormalizeText(state)))
+	if errors.Is(err, ErrNotFound) || (err == nil && !s.now().Before(pending.ExpiresAt)) {
+		return User{}, ErrInvalidSSOState
+	}
+	if err != nil {
+		return User{}, err
+	}
+	property, err := s.properties.FindByID(ctx, pending.PropertyID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return User{}, err
+	}
+	if err != nil || property.SSO == nil {
+		return User{}, ErrSSONotConfigured
+	}
+
+	cfg := property.SSO.config()
+	meta, err := s.idp.Discover(ctx, cfg)
+	if err != nil {
+		return User{}, fmt.Errorf("%w: %v", ErrSSOUnavailable, err)
+	}
+	claims, err := s.idp.Login(ctx, meta, cfg, NormalizeText(code), s.redirectURL, pending.Nonce, s.now())
+	if errors.Is(err, oidc.ErrRejected) {
+		return User{}, ErrSSORejected
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("%w: %v", ErrSSOUnavailable, err)
+	}
+
+	email := NormalizeEmail(claims.Email)
+	if pending.LinkEmail != "" && pending.LinkEmail != email {
+		return User{}, ErrSSORejected
+	}
+	user, err := s.users.FindByEmail(ctx, email)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return s.provision(ctx, email, property)
+	case err != nil:
+		return User{}, err
+	case user.SuspendedAt != nil:
+		return User{}, ErrAccountSuspended
+	case user.PropertyID == nil && pending.LinkEmail == email:
+		return s.users.SetProperty(ctx, email, &property.ID)
+	case user.PropertyID == nil || *user.PropertyID != property.ID:
+		return User{}, ErrSSORejected
+	}
+	return user, nil
+}
+
+// provision creates the account of a user signing in through the provider
+// of property for the first time. It has no password: it only signs in
+// through the provider (or with the passkeys added later).
+func (s *SSOService) provision(ctx context.Context, email string, property Property) (User, error) {
+	user := User{Email: email, Role: property.SSO.DefaultRole, PropertyID: &property.ID, CreatedAt: s.now()}
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if err := s.users.Insert(ctx, user); err != nil {
+			return nil, err
+		}
+		return newEvents(events.UserRegistered, user.Email, user.ToPublic(), user.CreatedAt)
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
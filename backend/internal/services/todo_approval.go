@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// Approval statuses of a delegated todo. A todo without Approval was never
+// submitted.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+var (
+	// ErrApprovalForbidden is returned when someone other than the
+	// assignee submits a todo, or other than the owner reviews it.
+	ErrApprovalForbidden = errors.New("approval forbidden")
+	// ErrApprovalStateConflict is returned when the todo is not in the
+	// approval status required by the operation (e.g. approving one that
+	// was not submitted).
+	ErrApprovalStateConflict = errors.New("approval state conflict")
+)
+
+// TransitionApproval moves a live todo from approval status from (empty
+// for a todo never submitted) to status to; approving it also completes
+// it. The update only matches while the todo is still in from, so two
+// concurrent reviews cannot both succeed.
+func (m *MongoTodoRepository) TransitionApproval(ctx context.Context, id primitive.ObjectID, from, to string, at time.Time) (Todo, error) {
+	filter := bson.M{"_id": id, "deletedAt": nil, "approval": from}
+	if from == "" {
+		filter["approval"] = nil
+	}
+	set := bson.M{"approval": to}
+	if to == ApprovalApproved {
+		set["completed"], set["completedAt"] = true, at
+	}
+
+	var todo Todo
+	err := m.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&todo)
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return todo, err
+	}
+	if _, err := m.FindByID(ctx, id); err != nil {
+		return Todo{}, err
+	}
+	return Todo{}, ErrApprovalStateConflict
+}
+
+// ApprovalService runs the approval workflow of delegated todos: the
+// assignee submits the todo as done, and the owner approves it, which
+// completes it, or rejects it back to the assignee.
+type ApprovalService struct {
+	todos    TodoRepository
+	comments CommentRepository
+	outbox   Outbox
+	now      func() time.Time
+	ids      IDGenerator
+}
+
+// NewApprovalService builds a new ApprovalService instance; the review
+// comments are left on the todo through comments.
+func NewApprovalService(todos TodoRepository, comments CommentRepository, outbox Outbox, now func() time.Time, ids IDGenerator) *ApprovalService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &ApprovalService{todos: todos, comments: comments, outbox: outbox, now: now, ids: ids}
+}
+
+// todoApprovalEvent is the payload of the todo.approval_requested,
+// todo.approved and todo.rejected events.
+type todoApprovalEvent struct {
+	TodoID   string `json:"todoId"`
+	Approval string `json:"approval"`
+	Owner    string `json:"owner"`
+	Assignee string `json:"assignee"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// Request marks a todo as done pending the approval of its owner. Only its
+// assignee may do it, on an open todo never submitted or rejected before.
+func (s *ApprovalService) Request(ctx context.Context, id primitive.ObjectID, email string) (TodoResponse, error) {
+	return s.transition(ctx, id, email, ApprovalPending, "")
+}
+
+// Approve completes a todo submitted by its assignee. Only its owner may do
+// it; comment is optional.
+func (s *ApprovalService) Approve(ctx context.Context, id primitive.ObjectID, email, comment string) (TodoResponse, error) {
+	return s.transition(ctx, id, email, ApprovalApproved, comment)
+}
+
+// Reject sends a submitted todo back to its assignee, explaining why in
+// comment. Only its owner may do it and the comment is required.
+func (s *ApprovalService) Reject(ctx context.Context, id primitive.ObjectID, email, comment string) (TodoResponse, error) {
+	if strings.TrimSpace(comment) == "" {
+		return TodoResponse{}, ErrInvalidComment
+	}
+	return s.transition(ctx, id, email, ApprovalRejected, comment)
+}
+
+var approvalEvents = map[string]string{
+	ApprovalPending:  events.TodoApprovalRequested,
+	ApprovalApproved: events.TodoApproved,
+	ApprovalRejected: events.TodoRejected,
+}
+
+// transition checks who may move the todo to status to and from which
+// status, then stores the change, the review comment and its events in one
+// transaction. The events are keyed by the todo, so they make up its
+// activity along the other todo events.
+func (s *ApprovalService) transition(ctx context.Context, id primitive.ObjectID, email, to, comment string) (TodoResponse, error) {
+	email, comment = NormalizeEmail(email), strings.TrimSpace(comment)
+	if email == "" {
+		return TodoResponse{}, ErrInvalidTodoInput
+	}
+	if utf8.RuneCountInString(comment) > maxCommentBody {
+		return TodoResponse{}, ErrInvalidComment
+	}
+
+	var todo Todo
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		current, err := s.todos.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		from, err := approvalSource(current, email, to)
+		if err != nil {
+			return nil, err
+		}
+
+		at := s.now()
+		if todo, err = s.todos.TransitionApproval(ctx, id, from, to, at); err != nil {
+			return nil, err
+		}
+		if comment != "" {
+			_, err := s.comments.Create(ctx, TodoComment{ID: s.ids.NewID(), TodoID: id, Email: email, Body: comment, CreatedAt: at})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		pending, err := newEvents(approvalEvents[to], todo.ID.Hex(), todoApprovalEvent{
+			TodoID:   todo.ID.Hex(),
+			Approval: to,
+			Owner:    todo.Email,
+			Assignee: todo.Assignee,
+			Comment:  comment,
+		}, at)
+		if err != nil || to != ApprovalApproved {
+			return pending, err
+		}
+		completed, err := events.New(events.TodoCompleted, todo.ID.Hex(), todo.ToResponse(), at)
+		if err != nil {
+			return nil, err
+		}
+		return append(pending, completed), nil
+	})
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	return todo.ToResponse(), nil
+}
+
+// approvalSource validates that email may move todo to status to and
+// returns the status it moves from.
+func approvalSource(todo Todo, email, to string) (string, error) {
+	if to == ApprovalPending {
+		if todo.Assignee == "" || email != todo.Assignee {
+			return "", ErrApprovalForbidden
+		}
+		if todo.Completed || todo.Approval == ApprovalPending {
+			return "", ErrApprovalStateConflict
+		}
+		return todo.Approval, nil
+	}
+	if email != todo.Email {
+		return "", ErrApprovalForbidden
+	}
+	if todo.Approval != ApprovalPending {
+		return "", ErrApprovalStateConflict
+	}
+	return ApprovalPending, nil
+}
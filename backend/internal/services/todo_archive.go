@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TodoArchiveCollection keeps the todos completed long ago, out of the
+// collection the application works on.
+const TodoArchiveCollection = "todos_archive"
+
+// archiveBatch caps how many todos Archive moves per round trip.
+const archiveBatch = 500
+
+// SetArchive makes the repository move the old completed todos to archive,
+// a collection of the same database, and include them in the listings
+// that ask for them. EnsureIndexes creates its indexes too.
+func (m *MongoTodoRepository) SetArchive(archive *mongo.Collection) {
+	m.archive = archive
+}
+
+// ensureArchiveIndexes creates the indexes of the listings of the archive
+// by owner.
+func (m *MongoTodoRepository) ensureArchiveIndexes(ctx context.Context) error {
+	if m.archive == nil {
+		return nil
+	}
+	_, err := m.archive.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "listId", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
+
+// withArchive is the pipeline that brings the todos matching filter from
+// the collection and from the archive. It skips the archived copies of
+// todos still in the collection, which Archive leaves when it fails
+// halfway through a batch.
+func (m *MongoTodoRepository) withArchive(filter bson.M) bson.A {
+	return bson.A{
+		bson.M{"$match": filter},
+		bson.M{"$unionWith": bson.M{"coll": m.archive.Name(), "pipeline": bson.A{
+			bson.M{"$match": filter},
+			bson.M{"$lookup": bson.M{"from": m.collection.Name(), "localField": "_id", "foreignField": "_id", "as": "live"}},
+			bson.M{"$match": bson.M{"live": bson.M{"$size": 0}}},
+			bson.M{"$unset": "live"},
+		}}},
+	}
+}
+
+// listWithArchive implements List for the queries that include the
+// archived todos.
+func (m *MongoTodoRepository) listWithArchive(ctx context.Context, filter bson.M, query TodoQuery) ([]Todo, error) {
+	pipeline := append(m.withArchive(filter), bson.M{"$sort": bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}})
+	if query.Offset > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": query.Offset})
+	}
+	if query.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": query.Limit})
+	}
+	return aggregate[Todo](ctx, m.collection, pipeline)
+}
+
+// countWithArchive implements Count for the queries that include the
+// archived todos.
+func (m *MongoTodoRepository) countWithArchive(ctx context.Context, filter bson.M) (int64, error) {
+	rows, err := aggregate[struct {
+		Count int64 `bson:"count"`
+	}](ctx, m.collection, append(m.withArchive(filter), bson.M{"$count": "count"}))
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return rows[0].Count, nil
+}
+
+// Archive implements TodoRepository in batches: each one is copied to the
+// archive with $merge and then deleted here. A todo reopened or trashed
+// in between stays here and its copy is dropped.
+func (m *MongoTodoRepository) Archive(ctx context.Context, before time.Time) (int64, error) {
+	if m.archive == nil {
+		return 0, nil
+	}
+	old := bson.M{"completed": true, "completedAt": bson.M{"$lt": before}, "deletedAt": nil}
+	var archived int64
+	for {
+		cursor, err := m.collection.Find(ctx, old, options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(archiveBatch))
+		if err != nil {
+			return archived, err
+		}
+		var batch []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.All(ctx, &batch); err != nil {
+			return archived, err
+		}
+		if len(batch) == 0 {
+			return archived, nil
+		}
+		ids := make(bson.A, 0, len(batch))
+		for _, todo := range batch {
+			ids = append(ids, todo.ID)
+		}
+
+		inBatch := bson.M{"_id": bson.M{"$in": ids}}
+		_, err = aggregate[bson.M](ctx, m.collection, bson.A{
+			bson.M{"$match": inBatch},
+			bson.M{"$merge": bson.M{"into": m.archive.Name(), "on": "_id", "whenMatched": "replace", "whenNotMatched": "insert"}},
+		})
+		if err != nil {
+			return archived, err
+		}
+		res, err := m.collection.DeleteMany(ctx, bson.M{"$and": bson.A{inBatch, old}})
+		if err != nil {
+			return archived, err
+		}
+		archived += res.DeletedCount
+		if res.DeletedCount < int64(len(ids)) {
+			kept, err := m.collection.Distinct(ctx, "_id", inBatch)
+			if err != nil {
+				return archived, err
+			}
+			if _, err := m.archive.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": kept}}); err != nil {
+				return archived, err
+			}
+		}
+		if len(batch) < archiveBatch {
+			return archived, nil
+		}
+	}
+}
+
+// ArchiveCompleted moves to the archive the todos completed more than age
+// ago, so the collection the application works on stays small.
+func (s *TodoService) ArchiveCompleted(ctx context.Context, age time.Duration) error {
+	archived, err := s.repo.Archive(ctx, s.now().Add(-age))
+	if archived > 0 {
+		log.Printf("archivo de tareas: %d tareas archivadas", archived)
+	}
+	return err
+}
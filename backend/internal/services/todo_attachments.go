@@ -0,0 +1,469 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scanner"
+)
+
+var (
+	// ErrInvalidAttachment indicates an empty file or a missing or overly
+	// long name.
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	// ErrAttachmentTooLarge is returned for files over the size limit.
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrSignedURLsDisabled is returned when no secret signs the download
+	// URLs.
+	ErrSignedURLsDisabled = errors.New("signed urls disabled")
+	// ErrInvalidAttachmentLink is returned for download URLs with a wrong
+	// signature or that expired.
+	ErrInvalidAttachmentLink = errors.New("invalid attachment link")
+	// ErrAttachmentPending is returned for attachments the scanner has not
+	// cleared yet.
+	ErrAttachmentPending = errors.New("attachment scan pending")
+	// ErrAttachmentBlocked is returned for attachments the scanner flagged.
+	ErrAttachmentBlocked = errors.New("attachment blocked")
+)
+
+// Scan states of an attachment.
+const (
+	AttachmentPending = "pending"
+	AttachmentClean   = "clean"
+	AttachmentBlocked = "blocked"
+)
+
+// attachmentScanBatch caps the pending attachments rescanned per run of
+// the scan job.
+const attachmentScanBatch = 100
+
+// maxAttachmentName caps the length of file names, in characters.
+const maxAttachmentName = 255
+
+// Attachment is a file attached to a todo. The content is stored with it
+// and left out of the listings.
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	TodoID      primitive.ObjectID `bson:"todoId"`
+	Email       string             `bson:"email"`
+	Name        string             `bson:"name"`
+	ContentType string             `bson:"contentType"`
+	Size        int64              `bson:"size"`
+	Data        []byte             `bson:"data,omitempty"`
+	// Scan is AttachmentPending until the scanner clears or blocks the
+	// file. Attachments uploaded before scanning existed have none and
+	// count as clean.
+	Scan      string     `bson:"scan,omitempty"`
+	Threat    string     `bson:"threat,omitempty"`
+	ScannedAt *time.Time `bson:"scannedAt,omitempty"`
+	CreatedAt time.Time  `bson:"createdAt"`
+}
+
+// available returns why an attachment cannot be downloaded, if anything.
+func (a Attachment) available() error {
+	switch a.Scan {
+	case AttachmentPending:
+		return ErrAttachmentPending
+	case AttachmentBlocked:
+		return ErrAttachmentBlocked
+	}
+	return nil
+}
+
+// AttachmentResponse is the representation exposed through the API.
+type AttachmentResponse struct {
+	ID          string    `json:"id" xml:"id"`
+	TodoID      string    `json:"todoId" xml:"todoId"`
+	Email       string    `json:"email" xml:"email"`
+	Name        string    `json:"name" xml:"name"`
+	ContentType string    `json:"contentType" xml:"contentType"`
+	Size        int64     `json:"size" xml:"size"`
+	Scan        string    `json:"scan" xml:"scan"`
+	Threat      string    `json:"threat,omitempty" xml:"threat,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+// ToResponse converts an Attachment into an externally safe representation.
+func (a Attachment) ToResponse() AttachmentResponse {
+	scan := a.Scan
+	if scan == "" {
+		scan = AttachmentClean
+	}
+	return AttachmentResponse{
+		ID:          a.ID.Hex(),
+		TodoID:      a.TodoID.Hex(),
+		Email:       a.Email,
+		Name:        a.Name,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		Scan:        scan,
+		Threat:      a.Threat,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// SignedURL is a download URL that works without credentials until
+// ExpiresAt.
+type SignedURL struct {
+	URL       string    `json:"url" xml:"url"`
+	ExpiresAt time.Time `json:"expiresAt" xml:"expiresAt"`
+}
+
+// AttachmentRepository is the storage contract of the todo attachments.
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment Attachment) error
+	// List returns the attachments of a todo without their content, oldest
+	// first.
+	List(ctx context.Context, todoID primitive.ObjectID) ([]Attachment, error)
+	// FindByID returns an attachment with its content, or ErrNotFound.
+	FindByID(ctx context.Context, id primitive.ObjectID) (Attachment, error)
+	// Delete removes an attachment, or returns ErrNotFound.
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// SetScan records the scan result of an attachment, or returns
+	// ErrNotFound.
+	SetScan(ctx context.Context, id primitive.ObjectID, scan, threat string, at time.Time) error
+	// ListPending returns, with their content, up to limit attachments
+	// uploaded before before that are still pending, oldest first.
+	ListPending(ctx context.Context, before time.Time, limit int) ([]Attachment, error)
+}
+
+// MongoAttachmentRepository implements AttachmentRepository backed by
+// MongoDB.
+type MongoAttachmentRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAttachmentRepository creates a new repository wrapper around a
+// Mongo collection.
+func NewMongoAttachmentRepository(collection *mongo.Collection) *MongoAttachmentRepository {
+	return &MongoAttachmentRepository{collection: collection}
+}
+
+// EnsureIndexes creates the indexes used to list the attachments of a todo
+// and the ones waiting for the scanner.
+func (m *MongoAttachmentRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "createdAt", Value: 1}},
+			Options: options.Index().SetPartialFilterExpression(bson.M{"scan": AttachmentPending}),
+		},
+	})
+	return err
+}
+
+// Create implements AttachmentRepository.
+func (m *MongoAttachmentRepository) Create(ctx context.Context, attachment Attachment) error {
+	_, err := m.collection.InsertOne(ctx, attachment)
+	return err
+}
+
+// List implements AttachmentRepository.
+func (m *MongoAttachmentRepository) List(ctx context.Context, todoID primitive.ObjectID) ([]Attachment, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"todoId": todoID}, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"data": 0}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var attachments []Attachment
+	if err := cursor.All(ctx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// FindByID implements AttachmentRepository.
+func (m *MongoAttachmentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Attachment, error) {
+	var attachment Attachment
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&attachment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Attachment{}, ErrNotFound
+	}
+	return attachment, err
+}
+
+// Delete implements AttachmentRepository.
+func (m *MongoAttachmentRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetScan implements AttachmentRepository.
+func (m *MongoAttachmentRepository) SetScan(ctx context.Context, id primitive.ObjectID, scan, threat string, at time.Time) error {
+	set := bson.M{"scan": scan, "scannedAt": at}
+	update := bson.M{"$set": set, "$unset": bson.M{"threat": ""}}
+	if threat != "" {
+		set["threat"] = threat
+		update = bson.M{"$set": set}
+	}
+	res, err := m.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListPending implements AttachmentRepository.
+func (m *MongoAttachmentRepository) ListPending(ctx context.Context, before time.Time, limit int) ([]Attachment, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"scan": AttachmentPending, "createdAt": bson.M{"$lt": before}}, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var attachments []Attachment
+	if err := cursor.All(ctx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// AttachmentConfig tunes the attachments.
+type AttachmentConfig struct {
+	// MaxBytes caps the size of each file.
+	MaxBytes int64
+	// URLSecret signs the download URLs; they are disabled when empty.
+	URLSecret string
+	// URLTTL is how long a signed URL works.
+	URLTTL time.Duration
+	// BaseURL prefixes the signed URLs.
+	BaseURL string
+}
+
+// AttachmentService stores the files attached to todos and signs the URLs
+// that download them without credentials, for emails and shared views.
+// With a scanner, uploads are scanned in the background and only served
+// once cleared.
+type AttachmentService struct {
+	todos       TodoRepository
+	attachments AttachmentRepository
+	scanner     scanner.Scanner
+	cfg         AttachmentConfig
+	now         func() time.Time
+	ids         IDGenerator
+}
+
+// NewAttachmentService builds a new AttachmentService instance; a nil
+// scanner serves the uploads right away.
+func NewAttachmentService(todos TodoRepository, attachments AttachmentRepository, scanner scanner.Scanner, cfg AttachmentConfig, now func() time.Time, ids IDGenerator) *AttachmentService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &AttachmentService{todos: todos, attachments: attachments, scanner: scanner, cfg: cfg, now: now, ids: ids}
+}
+
+// Upload attaches the file read from body to a live todo on behalf of
+// email. An empty contentType is sniffed from the content. With a scanner
+// the attachment stays pending until the background scan finishes.
+func (s *AttachmentService) Upload(ctx context.Context, todoID primitive.ObjectID, email, name, contentType string, body io.Reader) (AttachmentResponse, error) {
+	name = path.Base(strings.ReplaceAll(NormalizeText(name), `\`, "/"))
+	if name == "" || name == "." || name == "/" || utf8.RuneCountInString(name) > maxAttachmentName {
+		return AttachmentResponse{}, ErrInvalidAttachment
+	}
+	data, err := io.ReadAll(io.LimitReader(body, s.cfg.MaxBytes+1))
+	if err != nil {
+		return AttachmentResponse{}, err
+	}
+	if int64(len(data)) > s.cfg.MaxBytes {
+		return AttachmentResponse{}, ErrAttachmentTooLarge
+	}
+	if len(data) == 0 {
+		return AttachmentResponse{}, ErrInvalidAttachment
+	}
+	if _, err := s.todos.FindByID(ctx, todoID); err != nil {
+		return AttachmentResponse{}, err
+	}
+	if contentType = NormalizeText(contentType); contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	attachment := Attachment{
+		ID:          s.ids.NewID(),
+		TodoID:      todoID,
+		Email:       NormalizeEmail(email),
+		Name:        name,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Data:        data,
+		Scan:        AttachmentClean,
+		CreatedAt:   s.now(),
+	}
+	if s.scanner != nil {
+		attachment.Scan = AttachmentPending
+	}
+	if err := s.attachments.Create(ctx, attachment); err != nil {
+		return AttachmentResponse{}, err
+	}
+	if s.scanner != nil {
+		go s.scan(context.WithoutCancel(ctx), attachment)
+	}
+	return attachment.ToResponse(), nil
+}
+
+// scan runs the scanner on an attachment and records the result. Failures
+// leave it pending for ScanPending.
+func (s *AttachmentService) scan(ctx context.Context, attachment Attachment) {
+	verdict, err := s.scanner.Scan(ctx, attachment.Name, attachment.Data)
+	if err != nil {
+		log.Printf("no se pudo analizar el adjunto %s: %v", attachment.ID.Hex(), err)
+		return
+	}
+	status := AttachmentClean
+	if !verdict.Clean {
+		status = AttachmentBlocked
+		log.Printf("adjunto %s bloqueado: %s", attachment.ID.Hex(), verdict.Threat)
+	}
+	if err := s.attachments.SetScan(ctx, attachment.ID, status, verdict.Threat, s.now()); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("no se pudo guardar el analisis del adjunto %s: %v", attachment.ID.Hex(), err)
+	}
+}
+
+// ScanPending retries the scan of the attachments that stayed pending for
+// over a minute, e.g. because the scanner was down. It is meant to run as
+// a background job.
+func (s *AttachmentService) ScanPending(ctx context.Context) error {
+	if s.scanner == nil {
+		return nil
+	}
+	pending, err := s.attachments.ListPending(ctx, s.now().Add(-time.Minute), attachmentScanBatch)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range pending {
+		s.scan(ctx, attachment)
+	}
+	return nil
+}
+
+// List returns the attachments of a live todo, oldest first.
+func (s *AttachmentService) List(ctx context.Context, todoID primitive.ObjectID) ([]AttachmentResponse, error) {
+	if _, err := s.todos.FindByID(ctx, todoID); err != nil {
+		return nil, err
+	}
+	attachments, err := s.attachments.List(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AttachmentResponse, 0, len(attachments))
+	for _, attachment := range attachments {
+		out = append(out, attachment.ToResponse())
+	}
+	return out, nil
+}
+
+// find returns an attachment of a todo with its content, or ErrNotFound.
+func (s *AttachmentService) find(ctx context.Context, todoID, id primitive.ObjectID) (Attachment, error) {
+	attachment, err := s.attachments.FindByID(ctx, id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if attachment.TodoID != todoID {
+		return Attachment{}, ErrNotFound
+	}
+	return attachment, nil
+}
+
+// Open returns an attachment of a todo with its content, or ErrNotFound.
+// Attachments not cleared by the scanner return ErrAttachmentPending or
+// ErrAttachmentBlocked.
+func (s *AttachmentService) Open(ctx context.Context, todoID, id primitive.ObjectID) (Attachment, error) {
+	attachment, err := s.find(ctx, todoID, id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if err := attachment.available(); err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
+
+// Delete removes an attachment of a todo, blocked ones included.
+func (s *AttachmentService) Delete(ctx context.Context, todoID, id primitive.ObjectID) error {
+	if _, err := s.find(ctx, todoID, id); err != nil {
+		return err
+	}
+	return s.attachments.Delete(ctx, id)
+}
+
+// signature is the hex HMAC-SHA256 of the attachment ID and the expiry.
+func (s *AttachmentService) signature(id primitive.ObjectID, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.URLSecret))
+	mac.Write([]byte(id.Hex() + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns a URL that downloads an attachment of a todo without
+// credentials during the configured TTL.
+func (s *AttachmentService) Sign(ctx context.Context, todoID, id primitive.ObjectID) (SignedURL, error) {
+	if s.cfg.URLSecret == "" {
+		return SignedURL{}, ErrSignedURLsDisabled
+	}
+	if _, err := s.Open(ctx, todoID, id); err != nil {
+		return SignedURL{}, err
+	}
+	expiresAt := s.now().Add(s.cfg.URLTTL).Truncate(time.Second)
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expiresAt.Unix(), 10)},
+		"signature": {s.signature(id, expiresAt.Unix())},
+	}
+	return SignedURL{URL: s.cfg.BaseURL + "/attachments/" + id.Hex() + "?" + query.Encode(), ExpiresAt: expiresAt}, nil
+}
+
+// OpenSigned returns the attachment of a signed URL, checking its
+// signature and expiry. The attachments of trashed todos are not served,
+// nor those not cleared by the scanner.
+func (s *AttachmentService) OpenSigned(ctx context.Context, id, expires, signature string) (Attachment, error) {
+	oid, errID := primitive.ObjectIDFromHex(id)
+	unix, errExpires := strconv.ParseInt(expires, 10, 64)
+	if s.cfg.URLSecret == "" || errID != nil || errExpires != nil ||
+		!hmac.Equal([]byte(s.signature(oid, unix)), []byte(strings.ToLower(signature))) ||
+		!s.now().Before(time.Unix(unix, 0)) {
+		return Attachment{}, ErrInvalidAttachmentLink
+	}
+	attachment, err := s.attachments.FindByID(ctx, oid)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if err := attachment.available(); err != nil {
+		return Attachment{}, err
+	}
+	if _, err := s.todos.FindByID(ctx, attachment.TodoID); err != nil {
+		return Attachment{}, err
+	}
+	return attachment, nil
+}
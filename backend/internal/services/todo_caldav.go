@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/ical"
+)
+
+var (
+	// ErrInvalidCalendarData is returned for PUTs whose body is not a
+	// VTODO with a UID and a summary.
+	ErrInvalidCalendarData = errors.New("invalid calendar data")
+	// ErrPreconditionFailed is returned when the If-Match or If-None-Match
+	// condition of a CalDAV request does not hold.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// calDAVProdID identifies the server in the iCalendar objects it writes.
+const calDAVProdID = "-//tp6ingsoft3//Tareas//ES"
+
+// TodoCalDAV is how a CalDAV client named a todo it created.
+type TodoCalDAV struct {
+	Name string `bson:"name"`
+	UID  string `bson:"uid"`
+}
+
+// CalDAVResource is a todo as a CalDAV resource: Name is the file name
+// without the .ics extension, Data the iCalendar object.
+type CalDAVResource struct {
+	Name string
+	ETag string
+	Data []byte
+}
+
+// CalDAVConditions are the If-Match and If-None-Match headers of a write.
+type CalDAVConditions struct {
+	IfMatch     string
+	IfNoneMatch string
+}
+
+// holds reports whether the conditions allow writing over current (nil
+// when the resource does not exist).
+func (c CalDAVConditions) holds(current *CalDAVResource) bool {
+	if c.IfNoneMatch != "" && current != nil && MatchesETag(c.IfNoneMatch, current.ETag) {
+		return false
+	}
+	if c.IfMatch != "" && (current == nil || !MatchesETag(c.IfMatch, current.ETag)) {
+		return false
+	}
+	return true
+}
+
+// MatchesETag reports whether the If-Match or If-None-Match header names
+// etag, comparing the tags without their weak W/ prefix.
+func MatchesETag(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// rruleRecurrences maps the recurrence rules todos support.
+var rruleRecurrences = map[string]string{
+	"FREQ=DAILY":   RecurDaily,
+	"FREQ=WEEKLY":  RecurWeekly,
+	"FREQ=MONTHLY": RecurMonthly,
+}
+
+// recurrenceOf returns the recurrence of rule; rules todos cannot follow
+// (intervals, counts, other frequencies) give none.
+func recurrenceOf(rule string) string {
+	parts := strings.Split(rule, ";")
+	kept := parts[:0]
+	for _, part := range parts {
+		if part != "INTERVAL=1" && !strings.HasPrefix(part, "WKST=") {
+			kept = append(kept, part)
+		}
+	}
+	return rruleRecurrences[strings.Join(kept, ";")]
+}
+
+// CalDAVService maps the todos of a user to the VTODO resources of a
+// CalDAV calendar, so native clients sync them both ways.
+type CalDAVService struct {
+	todos  *TodoService
+	quotas *QuotaService
+}
+
+// NewCalDAVService builds a new CalDAVService instance; quotas limits the
+// todos clients create.
+func NewCalDAVService(todos *TodoService, quotas *QuotaService) *CalDAVService {
+	return &CalDAVService{todos: todos, quotas: quotas}
+}
+
+// resource encodes todo as a CalDAV resource.
+func (s *CalDAVService) resource(todo Todo) CalDAVResource {
+	vtodo := ical.Todo{
+		UID:       todo.ID.Hex(),
+		Summary:   todo.Title,
+		Created:   todo.CreatedAt,
+		Stamp:     todo.CreatedAt,
+		Completed: todo.Completed,
+	}
+	name := todo.ID.Hex()
+	if todo.CalDAV != nil {
+		name, vtodo.UID = todo.CalDAV.Name, todo.CalDAV.UID
+	}
+	if todo.Completed && todo.CompletedAt != nil {
+		vtodo.CompletedAt = *todo.CompletedAt
+		if vtodo.CompletedAt.After(vtodo.Stamp) {
+			vtodo.Stamp = vtodo.CompletedAt
+		}
+	}
+	for rule, recurrence := range rruleRecurrences {
+		if todo.Recurrence == recurrence {
+			vtodo.RRule = rule
+		}
+	}
+
+	data := ical.Encode(calDAVProdID, vtodo)
+	sum := sha256.Sum256(data)
+	return CalDAVResource{Name: name, ETag: `"` + hex.EncodeToString(sum[:16]) + `"`, Data: data}
+}
+
+// find returns the live todo of email named name, by its id or by the name
+// the client created it with.
+func (s *CalDAVService) find(ctx context.Context, email, name string) (Todo, error) {
+	if id, err := primitive.ObjectIDFromHex(name); err == nil {
+		todos, err := s.todos.repo.List(ctx, TodoQuery{ID: id, Email: email})
+		if err != nil || len(todos) > 0 {
+			return firstTodo(todos, err)
+		}
+	}
+	return firstTodo(s.todos.repo.List(ctx, TodoQuery{Email: email, CalDAVName: name}))
+}
+
+func firstTodo(todos []Todo, err error) (Todo, error) {
+	if err != nil {
+		return Todo{}, err
+	}
+	if len(todos) == 0 {
+		return Todo{}, ErrNotFound
+	}
+	return todos[0], nil
+}
+
+// Resources returns the live todos of email as CalDAV resources, oldest
+// first.
+func (s *CalDAVService) Resources(ctx context.Context, email string) ([]CalDAVResource, error) {
+	todos, err := s.todos.repo.List(ctx, TodoQuery{Email: NormalizeEmail(email)})
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]CalDAVResource, 0, len(todos))
+	for _, todo := range todos {
+		resources = append(resources, s.resource(todo))
+	}
+	return resources, nil
+}
+
+// CTag changes whenever a todo of resources is added, changed or removed,
+// so clients know when to sync the calendar again.
+func CTag(resources []CalDAVResource) string {
+	tags := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		tags = append(tags, resource.Name+resource.ETag)
+	}
+	sort.Strings(tags)
+	sum := sha256.Sum256([]byte(strings.Join(tags, "\n")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Resource returns the todo of email named name, or ErrNotFound.
+func (s *CalDAVService) Resource(ctx context.Context, email, name string) (CalDAVResource, error) {
+	todo, err := s.find(ctx, NormalizeEmail(email), name)
+	if err != nil {
+		return CalDAVResource{}, err
+	}
+	return s.resource(todo), nil
+}
+
+// Put creates or updates the todo of email named name from a VTODO and
+// reports whether it was created. Only the summary, the completion and the
+// end of a recurrence are taken from updates.
+func (s *CalDAVService) Put(ctx context.Context, email, name string, data []byte, conditions CalDAVConditions) (CalDAVResource, bool, error) {
+	email = NormalizeEmail(email)
+	vtodo, err := ical.DecodeTodo(data)
+	if err != nil || vtodo.UID == "" || NormalizeText(vtodo.Summary) == "" {
+		return CalDAVResource{}, false, ErrInvalidCalendarData
+	}
+
+	existing, err := s.find(ctx, email, name)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if !conditions.holds(nil) {
+			return CalDAVResource{}, false, ErrPreconditionFailed
+		}
+		created, err := s.create(ctx, email, name, vtodo)
+		return created, err == nil, err
+	case err != nil:
+		return CalDAVResource{}, false, err
+	}
+
+	current := s.resource(existing)
+	if !conditions.holds(&current) {
+		return CalDAVResource{}, false, ErrPreconditionFailed
+	}
+	var update TodoUpdate
+	if title := NormalizeText(vtodo.Summary); title != existing.Title {
+		update.Title = &title
+	}
+	if vtodo.Completed != existing.Completed {
+		update.Completed = &vtodo.Completed
+	}
+	update.EndRecurrence = existing.Recurrence != "" && recurrenceOf(vtodo.RRule) == ""
+	if update.Title == nil && update.Completed == nil && !update.EndRecurrence {
+		return current, false, nil
+	}
+	if _, err := s.todos.Update(ctx, existing.ID, update); err != nil {
+		return CalDAVResource{}, false, err
+	}
+	updated, err := s.Resource(ctx, email, name)
+	return updated, false, err
+}
+
+// create stores the todo a client put under name.
+func (s *CalDAVService) create(ctx context.Context, email, name string, vtodo ical.Todo) (CalDAVResource, error) {
+	if err := s.quotas.AllowTodo(ctx, email); err != nil {
+		return CalDAVResource{}, err
+	}
+	todo, err := s.todos.newTodo(ctx, email, vtodo.Summary, recurrenceOf(vtodo.RRule), TodoLabel{}, nil)
+	if err != nil {
+		return CalDAVResource{}, err
+	}
+	todo.CalDAV = &TodoCalDAV{Name: name, UID: vtodo.UID}
+	if todo, err = s.todos.repo.Create(ctx, todo); err != nil {
+		return CalDAVResource{}, err
+	}
+	if vtodo.Completed {
+		completed := true
+		if _, err := s.todos.Update(ctx, todo.ID, TodoUpdate{Completed: &completed}); err != nil {
+			return CalDAVResource{}, err
+		}
+	}
+	return s.Resource(ctx, email, name)
+}
+
+// Delete moves the todo of email named name to the trash.
+func (s *CalDAVService) Delete(ctx context.Context, email, name string, conditions CalDAVConditions) error {
+	todo, err := s.find(ctx, NormalizeEmail(email), name)
+	if err != nil {
+		return err
+	}
+	current := s.resource(todo)
+	if !conditions.holds(&current) {
+		return ErrPreconditionFailed
+	}
+	return s.todos.Delete(ctx, todo.ID)
+}
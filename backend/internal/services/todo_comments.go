@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// ErrInvalidComment indicates an empty or overly long comment.
+var ErrInvalidComment = errors.New("invalid comment")
+
+// maxCommentBody caps the length of todo comments, in characters.
+const maxCommentBody = 2000
+
+// mentionPattern matches an @ followed by an email, at the start of the
+// text or after a character that cannot be part of an email.
+var mentionPattern = regexp.MustCompile(`(^|[^\w.@+-])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)*\.[A-Za-z]{2,})`)
+
+// ParseMentions returns the emails mentioned as @email in body, normalized
+// and without repetitions, in order of appearance.
+func ParseMentions(body string) []string {
+	var mentions []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		if email := NormalizeEmail(match[2]); !slices.Contains(mentions, email) {
+			mentions = append(mentions, email)
+		}
+	}
+	return mentions
+}
+
+// replaceMentions rewrites the email of every mention in body with replace.
+func replaceMentions(body string, replace func(email string) string) string {
+	return mentionPattern.ReplaceAllStringFunc(body, func(match string) string {
+		parts := mentionPattern.FindStringSubmatch(match)
+		return parts[1] + "@" + replace(parts[2])
+	})
+}
+
+// TodoComment is a comment left on a todo. Mentions holds the mentioned
+// users that were notified.
+type TodoComment struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TodoID    primitive.ObjectID `bson:"todoId"`
+	Email     string             `bson:"email"`
+	Body      string             `bson:"body"`
+	Mentions  []string           `bson:"mentions,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// TodoCommentResponse is the representation exposed through the API.
+type TodoCommentResponse struct {
+	ID        string    `json:"id" xml:"id"`
+	TodoID    string    `json:"todoId" xml:"todoId"`
+	Email     string    `json:"email" xml:"email"`
+	Body      string    `json:"body" xml:"body"`
+	Mentions  []string  `json:"mentions" xml:"mentions>mention"`
+	CreatedAt time.Time `json:"createdAt" xml:"createdAt"`
+}
+
+// ToResponse converts a TodoComment into an externally safe representation.
+func (c TodoComment) ToResponse() TodoCommentResponse {
+	return TodoCommentResponse{
+		ID:        c.ID.Hex(),
+		TodoID:    c.TodoID.Hex(),
+		Email:     c.Email,
+		Body:      c.Body,
+		Mentions:  append([]string{}, c.Mentions...),
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+// CommentRepository is the storage contract of the todo comments.
+type CommentRepository interface {
+	// List returns the comments of a todo, oldest first.
+	List(ctx context.Context, todoID primitive.ObjectID) ([]TodoComment, error)
+	Create(ctx context.Context, comment TodoComment) (TodoComment, error)
+}
+
+// MongoCommentRepository implements CommentRepository backed by MongoDB.
+type MongoCommentRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoCommentRepository creates a new repository wrapper around a Mongo
+// collection.
+func NewMongoCommentRepository(collection *mongo.Collection) *MongoCommentRepository {
+	return &MongoCommentRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used to list the comments of a todo.
+func (m *MongoCommentRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+// List implements CommentRepository.
+func (m *MongoCommentRepository) List(ctx context.Context, todoID primitive.ObjectID) ([]TodoComment, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{"todoId": todoID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var comments []TodoComment
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// Create implements CommentRepository.
+func (m *MongoCommentRepository) Create(ctx context.Context, comment TodoComment) (TodoComment, error) {
+	res, err := m.collection.InsertOne(ctx, comment)
+	if err != nil {
+		return TodoComment{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		comment.ID = oid
+	}
+	return comment, nil
+}
+
+// CommentService handles the comments on todos and notifies the users
+// mentioned in them, in their inbox and by email.
+type CommentService struct {
+	todos         TodoRepository
+	comments      CommentRepository
+	notifications NotificationRepository
+	outbox        Outbox
+	mail          *BookingMailer
+	now           func() time.Time
+	ids           IDGenerator
+}
+
+// NewCommentService builds a new CommentService instance. The mention
+// emails go through mail, sharing its log and opt-outs; a nil mail only
+// fills the inbox.
+func NewCommentService(todos TodoRepository, comments CommentRepository, notifications NotificationRepository, outbox Outbox, mail *BookingMailer, now func() time.Time, ids IDGenerator) *CommentService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &CommentService{todos: todos, comments: comments, notifications: notifications, outbox: outbox, mail: mail, now: now, ids: ids}
+}
+
+// List returns the comments of a live todo, oldest first.
+func (s *CommentService) List(ctx context.Context, todoID primitive.ObjectID) ([]TodoCommentResponse, error) {
+	if _, err := s.todos.FindByID(ctx, todoID); err != nil {
+		return nil, err
+	}
+	comments, err := s.comments.List(ctx, todoID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TodoCommentResponse, 0, len(comments))
+	for _, comment := range comments {
+		out = append(out, comment.ToResponse())
+	}
+	return out, nil
+}
+
+// collaborators are the users taking part in a todo: its owner and whoever
+// already reacted to it or commented on it.
+func collaborators(todo Todo, comments []TodoComment) []string {
+	users := []string{todo.Email}
+	for _, reaction := range todo.Reactions {
+		users = append(users, reaction.Email)
+	}
+	for _, comment := range comments {
+		users = append(users, comment.Email)
+	}
+	return users
+}
+
+// Create stores the comment of email on a live todo. The @email mentions
+// of collaborators other than the author are recorded on the comment and
+// each one gets a notification in the same transaction; mentions of anyone
+// else stay as plain text. The emails are sent in the background once the
+// comment is stored.
+func (s *CommentService) Create(ctx context.Context, todoID primitive.ObjectID, email, body string) (TodoCommentResponse, error) {
+	email, body = NormalizeEmail(email), strings.TrimSpace(body)
+	if email == "" || body == "" || utf8.RuneCountInString(body) > maxCommentBody {
+		return TodoCommentResponse{}, ErrInvalidComment
+	}
+
+	var todo Todo
+	var comment TodoComment
+	var notifications []Notification
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		var err error
+		if todo, err = s.todos.FindByID(ctx, todoID); err != nil {
+			return nil, err
+		}
+		previous, err := s.comments.List(ctx, todoID)
+		if err != nil {
+			return nil, err
+		}
+
+		users := collaborators(todo, previous)
+		comment = TodoComment{ID: s.ids.NewID(), TodoID: todoID, Email: email, Body: body, CreatedAt: s.now()}
+		notifications = nil
+		for _, mention := range ParseMentions(body) {
+			if mention == email || !slices.Contains(users, mention) {
+				continue
+			}
+			comment.Mentions = append(comment.Mentions, mention)
+			notifications = append(notifications, Notification{
+				ID:        s.ids.NewID(),
+				Email:     mention,
+				Kind:      NotificationMention,
+				TodoID:    todoID,
+				CommentID: comment.ID,
+				Author:    email,
+				CreatedAt: comment.CreatedAt,
+			})
+		}
+		if comment, err = s.comments.Create(ctx, comment); err != nil {
+			return nil, err
+		}
+		return nil, s.notifications.Create(ctx, notifications)
+	})
+	if err != nil {
+		return TodoCommentResponse{}, err
+	}
+
+	if s.mail != nil {
+		s.sendMentions(ctx, todo, comment, notifications)
+	}
+	return comment.ToResponse(), nil
+}
+
+// mentionMailData is the data available to the mention email template.
+type mentionMailData struct {
+	Author    string
+	Todo      string
+	Body      string
+	OptOutURL string
+}
+
+// sendMentions emails every mentioned user once per comment, in the
+// background.
+func (s *CommentService) sendMentions(ctx context.Context, todo Todo, comment TodoComment, notifications []Notification) {
+	for _, notification := range notifications {
+		data := mentionMailData{Author: comment.Email, Todo: todo.Title, Body: comment.Body, OptOutURL: s.mail.optOutURL(notification.Email)}
+		key := MailMention + ":" + comment.ID.Hex() + ":" + notification.Email
+		s.mail.background(ctx, notification.Email, func(ctx context.Context) {
+			if err := s.mail.send(ctx, key, notification.Email, MailMention, func() any { return data }); err != nil {
+				log.Printf("no se pudo enviar la mencion del comentario %s a %s: %v", comment.ID.Hex(), notification.Email, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// TodoDigest emails every user the list of their open todos. It shares the
+// sent-email log and the opt-outs of the booking emails, so a user gets at
+// most one digest per day.
+type TodoDigest struct {
+	todos TodoRepository
+	mail  *BookingMailer
+	now   func() time.Time
+}
+
+// NewTodoDigest builds a new TodoDigest instance.
+func NewTodoDigest(todos TodoRepository, mail *BookingMailer, now func() time.Time) *TodoDigest {
+	if now == nil {
+		now = time.Now
+	}
+	return &TodoDigest{todos: todos, mail: mail, now: now}
+}
+
+// todoDigestData is the data available to the digest template.
+type todoDigestData struct {
+	Email     string
+	Todos     []TodoResponse
+	OptOutURL string
+}
+
+// Send emails today's digest to every user with open todos.
+func (d *TodoDigest) Send(ctx context.Context) error {
+	open, err := d.todos.List(ctx, TodoQuery{Open: true, All: true})
+	if err != nil {
+		return err
+	}
+
+	var owners []string
+	byOwner := map[string][]TodoResponse{}
+	for _, todo := range open {
+		if _, seen := byOwner[todo.Email]; !seen {
+			owners = append(owners, todo.Email)
+		}
+		byOwner[todo.Email] = append(byOwner[todo.Email], todo.ToResponse())
+	}
+
+	day := d.now().UTC().Format(time.DateOnly)
+	d.mail.fanOut(ctx, owners, func(ctx context.Context, i int) {
+		email := owners[i]
+		data := todoDigestData{Email: email, Todos: byOwner[email], OptOutURL: d.mail.optOutURL(email)}
+		err := d.mail.send(ctx, MailDigest+":"+email+":"+day, email, MailDigest, func() any { return data })
+		if err != nil {
+			log.Printf("no se pudo enviar el resumen de tareas a %s: %v", email, err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+)
+
+// ErrInvalidFeedToken is returned for unknown or revoked feed tokens.
+var ErrInvalidFeedToken = errors.New("invalid feed token")
+
+const (
+	// feedWindow is how far back the feed looks for created and completed
+	// todos.
+	feedWindow = 30 * 24 * time.Hour
+	// maxFeedEntries caps the entries of a feed.
+	maxFeedEntries = 50
+)
+
+// Kinds of feed entries.
+const (
+	FeedCreated   = "created"
+	FeedCompleted = "completed"
+)
+
+// UserFeedToken lets feed readers, which cannot sign in, read the feed of
+// a user's todos. Only the hash of the token is stored.
+type UserFeedToken struct {
+	TokenHash string    `bson:"tokenHash"`
+	IssuedAt  time.Time `bson:"issuedAt"`
+}
+
+// FeedToken is a feed token as issued; Token is only shown once.
+type FeedToken struct {
+	Token    string    `json:"token" xml:"token"`
+	IssuedAt time.Time `json:"issuedAt" xml:"issuedAt"`
+}
+
+// FeedQuery narrows a feed to one shared list or to one label.
+type FeedQuery struct {
+	ListID primitive.ObjectID
+	Color  string
+	Icon   string
+}
+
+// FeedEntry is a todo that was created or completed at At.
+type FeedEntry struct {
+	Kind string
+	At   time.Time
+	Todo TodoResponse
+}
+
+// TodoFeedService issues the feed tokens of the users and builds the feed
+// of their recently created and completed todos.
+type TodoFeedService struct {
+	users UserRepository
+	todos *TodoService
+	lists *ListService
+	now   func() time.Time
+}
+
+// NewTodoFeedService builds a new TodoFeedService instance.
+func NewTodoFeedService(users UserRepository, todos *TodoService, lists *ListService, now func() time.Time) *TodoFeedService {
+	if now == nil {
+		now = time.Now
+	}
+	return &TodoFeedService{users: users, todos: todos, lists: lists, now: now}
+}
+
+// IssueToken creates the feed token of a user, replacing the previous one.
+func (s *TodoFeedService) IssueToken(ctx context.Context, email string) (FeedToken, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return FeedToken{}, err
+	}
+	feed := UserFeedToken{TokenHash: hashToken(token), IssuedAt: s.now()}
+	if _, err := s.users.SetFeedToken(ctx, NormalizeEmail(email), &feed); err != nil {
+		return FeedToken{}, err
+	}
+	return FeedToken{Token: token, IssuedAt: feed.IssuedAt}, nil
+}
+
+// RevokeToken removes the feed token of a user, so the URLs handed to
+// feed readers stop working.
+func (s *TodoFeedService) RevokeToken(ctx context.Context, email string) error {
+	_, err := s.users.SetFeedToken(ctx, NormalizeEmail(email), nil)
+	return err
+}
+
+// Authenticate returns the user a feed token belongs to. Suspended users
+// get ErrInvalidFeedToken, like a revoked token.
+func (s *TodoFeedService) Authenticate(ctx context.Context, token string) (User, error) {
+	if token == "" {
+		return User{}, ErrInvalidFeedToken
+	}
+	user, err := s.users.FindByFeedToken(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) || (err == nil && user.SuspendedAt != nil) {
+		return User{}, ErrInvalidFeedToken
+	}
+	return user, err
+}
+
+// Entries returns the todos of email created or completed in the last
+// days, newest first. With a list in query the feed shows the todos of
+// that list, which email needs to be able to read.
+func (s *TodoFeedService) Entries(ctx context.Context, email string, query FeedQuery) ([]FeedEntry, error) {
+	todoQuery := TodoQuery{Email: email, Color: query.Color, Icon: query.Icon}
+	if !query.ListID.IsZero() {
+		if _, err := s.lists.Authorize(ctx, query.ListID, email, policy.Read); err != nil {
+			return nil, err
+		}
+		todoQuery.Email, todoQuery.ListID = "", query.ListID
+	}
+	page, err := s.todos.List(ctx, todoQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	since := s.now().Add(-feedWindow)
+	var entries []FeedEntry
+	for _, todo := range page.Todos {
+		if todo.CreatedAt.After(since) {
+			entries = append(entries, FeedEntry{Kind: FeedCreated, At: todo.CreatedAt, Todo: todo})
+		}
+		if todo.Completed && todo.CompletedAt != nil && todo.CompletedAt.After(since) {
+			entries = append(entries, FeedEntry{Kind: FeedCompleted, At: *todo.CompletedAt, Todo: todo})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.After(entries[j].At) })
+	if len(entries) > maxFeedEntries {
+		entries = entries[:maxFeedEntries]
+	}
+	return entries, nil
+}
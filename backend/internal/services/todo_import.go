@@ -0,0 +1,455 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+)
+
+// Sources todos are imported from.
+const (
+	ImportTodoist = "todoist"
+	ImportGoogle  = "google"
+)
+
+// MaxTodoImport caps the todos read from a single export file.
+const MaxTodoImport = 1000
+
+// maxTodoImportBytes caps the size of the export files.
+const maxTodoImportBytes = 5 << 20
+
+// Todo import outcomes: ready todos would be created by the same import
+// without the dry run.
+const (
+	ImportTodoReady   = "ready"
+	ImportTodoCreated = "created"
+	ImportTodoSkipped = "skipped"
+)
+
+var (
+	// ErrInvalidTodoImport indicates an unreadable or oversized export
+	// file, one without todos or one with more than MaxTodoImport.
+	ErrInvalidTodoImport = errors.New("invalid todo import")
+	// ErrUnknownImportSource indicates a source other than ImportTodoist
+	// and ImportGoogle.
+	ErrUnknownImportSource = errors.New("unknown import source")
+)
+
+// importedTodo is a todo read from an export file, before mapping it.
+type importedTodo struct {
+	// List is the project or task list; empty for the Todoist inbox.
+	List      string
+	Title     string
+	Labels    []string
+	Due       string
+	Completed bool
+	Deleted   bool
+}
+
+// TodoImportItem is the outcome of one todo of an export file (Row is
+// 1-based). Warnings name what could not be carried over, e.g. a label
+// outside the palette.
+type TodoImportItem struct {
+	Row        int        `json:"row" xml:"row"`
+	List       string     `json:"list,omitempty" xml:"list,omitempty"`
+	Title      string     `json:"title" xml:"title"`
+	Status     string     `json:"status" xml:"status"`
+	Reason     string     `json:"reason,omitempty" xml:"reason,omitempty"`
+	TodoID     string     `json:"todoId,omitempty" xml:"todoId,omitempty"`
+	Completed  bool       `json:"completed" xml:"completed"`
+	Color      string     `json:"color,omitempty" xml:"color,omitempty"`
+	Icon       string     `json:"icon,omitempty" xml:"icon,omitempty"`
+	Recurrence string     `json:"recurrence,omitempty" xml:"recurrence,omitempty"`
+	DueAt      *time.Time `json:"dueAt,omitempty" xml:"dueAt,omitempty"`
+	Warnings   []string   `json:"warnings,omitempty" xml:"warnings>warning,omitempty"`
+}
+
+// TodoImportList is a project or task list of the export file and the
+// shared list it maps to; Exists tells whether the user already had it.
+type TodoImportList struct {
+	Name   string `json:"name" xml:"name"`
+	ID     string `json:"id,omitempty" xml:"id,omitempty"`
+	Exists bool   `json:"exists" xml:"exists"`
+	Todos  int    `json:"todos" xml:"todos"`
+}
+
+// TodoImportReport describes what an import did or, on a dry run, would
+// do.
+type TodoImportReport struct {
+	Source   string           `json:"source" xml:"source"`
+	DryRun   bool             `json:"dryRun" xml:"dryRun"`
+	Imported int              `json:"imported" xml:"imported"`
+	Skipped  int              `json:"skipped" xml:"skipped"`
+	Lists    []TodoImportList `json:"lists" xml:"lists>list"`
+	Todos    []TodoImportItem `json:"todos" xml:"todos>todo"`
+}
+
+// TodoImportService moves todos over from the export files of Todoist and
+// Google Tasks: projects and task lists become shared lists, labels become
+// the color and icon labels they name and due dates are kept.
+type TodoImportService struct {
+	todos  *TodoService
+	lists  *ListService
+	quotas *QuotaService
+}
+
+// NewTodoImportService builds a new TodoImportService instance.
+func NewTodoImportService(todos *TodoService, lists *ListService, quotas *QuotaService) *TodoImportService {
+	return &TodoImportService{todos: todos, lists: lists, quotas: quotas}
+}
+
+// Import reads the export file of source and creates its todos for email.
+// name is the file name, which names the project of a single Todoist CSV.
+// With dryRun nothing is stored and the report tells what would be.
+func (s *TodoImportService) Import(ctx context.Context, email, source, name string, data io.Reader, dryRun bool) (TodoImportReport, error) {
+	email = NormalizeEmail(email)
+	raw, err := io.ReadAll(io.LimitReader(data, maxTodoImportBytes+1))
+	if err != nil || len(raw) > maxTodoImportBytes {
+		return TodoImportReport{}, ErrInvalidTodoImport
+	}
+
+	var todos []importedTodo
+	switch strings.ToLower(source) {
+	case ImportTodoist:
+		todos, err = parseTodoist(name, raw)
+	case ImportGoogle:
+		todos, err = parseGoogleTasks(raw)
+	default:
+		return TodoImportReport{}, ErrUnknownImportSource
+	}
+	if err != nil || len(todos) == 0 || len(todos) > MaxTodoImport {
+		return TodoImportReport{}, ErrInvalidTodoImport
+	}
+
+	report := TodoImportReport{Source: strings.ToLower(source), DryRun: dryRun, Lists: []TodoImportList{}}
+	lists, err := s.matchLists(ctx, email)
+	if err != nil {
+		return TodoImportReport{}, err
+	}
+	listIndex := map[string]int{}
+	for i, todo := range todos {
+		item := planImport(i+1, todo)
+		if item.Status == ImportTodoReady && item.List != "" {
+			key := strings.ToLower(item.List)
+			index, seen := listIndex[key]
+			if !seen {
+				index = len(report.Lists)
+				listIndex[key] = index
+				list := TodoImportList{Name: item.List}
+				if id, ok := lists[key]; ok {
+					list.ID, list.Exists = id, true
+				}
+				report.Lists = append(report.Lists, list)
+			}
+			report.Lists[index].Todos++
+		}
+		report.Todos = append(report.Todos, item)
+	}
+
+	ready := 0
+	for _, item := range report.Todos {
+		if item.Status == ImportTodoReady {
+			ready++
+		}
+	}
+	report.Skipped = len(report.Todos) - ready
+	usage, err := s.quotas.Usage(ctx, email)
+	if err != nil {
+		return TodoImportReport{}, err
+	}
+	if limit := usage.Todos.Limit; limit != nil && usage.Todos.Used+int64(ready) > int64(*limit) {
+		return TodoImportReport{}, ErrQuotaExceeded
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for i, list := range report.Lists {
+		if list.Exists {
+			continue
+		}
+		created, err := s.lists.Create(ctx, email, list.Name)
+		if err != nil {
+			return TodoImportReport{}, err
+		}
+		report.Lists[i].ID = created.ID
+	}
+	for i, item := range report.Todos {
+		if item.Status != ImportTodoReady {
+			continue
+		}
+		var listID *primitive.ObjectID
+		if item.List != "" {
+			id, _ := primitive.ObjectIDFromHex(report.Lists[listIndex[strings.ToLower(item.List)]].ID)
+			listID = &id
+		}
+		todo, err := s.create(ctx, email, item, listID)
+		if err != nil {
+			return TodoImportReport{}, err
+		}
+		report.Todos[i].Status, report.Todos[i].TodoID = ImportTodoCreated, todo.ID
+		report.Imported++
+	}
+	return report, nil
+}
+
+// matchLists returns the ids of the lists email may add todos to, by their
+// lowercased name; the first list wins when several share a name.
+func (s *TodoImportService) matchLists(ctx context.Context, email string) (map[string]string, error) {
+	lists, err := s.lists.ListFor(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(lists))
+	for _, list := range lists {
+		key := strings.ToLower(list.Name)
+		if _, taken := ids[key]; !taken && policy.Allows(policy.Role(list.Role), policy.Write) {
+			ids[key] = list.ID
+		}
+	}
+	return ids, nil
+}
+
+// planImport maps an imported todo to the todo it becomes.
+func planImport(row int, todo importedTodo) TodoImportItem {
+	item := TodoImportItem{
+		Row:       row,
+		List:      NormalizeText(todo.List),
+		Title:     NormalizeText(todo.Title),
+		Status:    ImportTodoReady,
+		Completed: todo.Completed,
+	}
+	switch {
+	case todo.Deleted:
+		item.Status, item.Reason = ImportTodoSkipped, "deleted"
+		return item
+	case item.Title == "":
+		item.Status, item.Reason = ImportTodoSkipped, "missing_title"
+		return item
+	case len([]rune(item.List)) > maxListName:
+		item.Status, item.Reason = ImportTodoSkipped, "invalid_list"
+		return item
+	}
+
+	for _, label := range todo.Labels {
+		label = strings.ToLower(label)
+		switch {
+		case item.Color == "" && slices.Contains(TodoColors, label):
+			item.Color = label
+		case item.Icon == "" && slices.Contains(TodoIcons, label):
+			item.Icon = label
+		default:
+			item.Warnings = append(item.Warnings, "label_not_mapped:"+label)
+		}
+	}
+	if due := strings.TrimSpace(todo.Due); due != "" {
+		recurrence, at, ok := parseDue(due)
+		switch {
+		case !ok:
+			item.Warnings = append(item.Warnings, "due_not_recognized")
+		case recurrence != "" && !todo.Completed:
+			item.Recurrence = recurrence
+		case !at.IsZero():
+			item.DueAt = &at
+		}
+	}
+	return item
+}
+
+// create stores the todo of a ready item.
+func (s *TodoImportService) create(ctx context.Context, email string, item TodoImportItem, listID *primitive.ObjectID) (TodoResponse, error) {
+	todo, err := s.todos.newTodo(ctx, email, item.Title, item.Recurrence, TodoLabel{Color: item.Color, Icon: item.Icon}, listID)
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	todo.DueAt = item.DueAt
+	if todo, err = s.todos.repo.Create(ctx, todo); err != nil {
+		return TodoResponse{}, err
+	}
+	if !item.Completed {
+		return todo.ToResponse(), nil
+	}
+	completed := true
+	return s.todos.Update(ctx, todo.ID, TodoUpdate{Completed: &completed})
+}
+
+// dueRecurrences maps the recurring due dates of Todoist, in English and
+// Spanish, to todo recurrences.
+var dueRecurrences = []struct {
+	prefixes   []string
+	recurrence string
+}{
+	{[]string{"every day", "daily", "cada dia", "cada día", "todos los dias", "todos los días"}, RecurDaily},
+	{[]string{"every week", "weekly", "cada semana", "todas las semanas"}, RecurWeekly},
+	{[]string{"every month", "monthly", "cada mes", "todos los meses"}, RecurMonthly},
+}
+
+var dueLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// parseDue reads a due date: either a recurrence or a date, which without
+// a zone is taken as UTC.
+func parseDue(due string) (string, time.Time, bool) {
+	lower := strings.ToLower(strings.ReplaceAll(due, "!", ""))
+	for _, rule := range dueRecurrences {
+		for _, prefix := range rule.prefixes {
+			if strings.HasPrefix(lower, prefix) {
+				return rule.recurrence, time.Time{}, true
+			}
+		}
+	}
+	for _, layout := range dueLayouts {
+		if at, err := time.Parse(layout, due); err == nil {
+			return "", at.UTC(), true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// todoistLabel matches the @labels Todoist writes in the task content.
+var todoistLabel = regexp.MustCompile(`(^|\s)@([\p{L}\p{N}_-]+)`)
+
+// todoistProjectID matches the " [123]" Todoist appends to the files of a
+// backup.
+var todoistProjectID = regexp.MustCompile(`\s*\[\d+\]$`)
+
+// parseTodoist reads a project exported as CSV or a backup, a zip with the
+// CSV of every project. Projects are named after their file; the inbox
+// maps to no list.
+func parseTodoist(name string, data []byte) ([]importedTodo, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return parseTodoistCSV(todoistProject(name), bytes.NewReader(data))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrInvalidTodoImport
+	}
+	var todos []importedTodo
+	for _, file := range archive.File {
+		if !strings.EqualFold(path.Ext(file.Name), ".csv") {
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return nil, ErrInvalidTodoImport
+		}
+		project, err := parseTodoistCSV(todoistProject(file.Name), io.LimitReader(content, maxTodoImportBytes))
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, project...)
+		if len(todos) > MaxTodoImport {
+			return nil, ErrInvalidTodoImport
+		}
+	}
+	return todos, nil
+}
+
+// todoistProject returns the project a file is the export of.
+func todoistProject(name string) string {
+	name = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	name = todoistProjectID.ReplaceAllString(name, "")
+	if name == "." || strings.EqualFold(name, "inbox") || strings.EqualFold(name, "bandeja de entrada") {
+		return ""
+	}
+	return name
+}
+
+// parseTodoistCSV reads the tasks of a project; sections and notes are
+// left out.
+func parseTodoistCSV(project string, r io.Reader) ([]importedTodo, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrInvalidTodoImport
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))] = i
+	}
+	typeColumn, hasType := columns["TYPE"]
+	contentColumn, hasContent := columns["CONTENT"]
+	if !hasType || !hasContent {
+		return nil, ErrInvalidTodoImport
+	}
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	var todos []importedTodo
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return todos, nil
+		}
+		if err != nil {
+			return nil, ErrInvalidTodoImport
+		}
+		if typeColumn >= len(record) || contentColumn >= len(record) || !strings.EqualFold(record[typeColumn], "task") {
+			continue
+		}
+		content := record[contentColumn]
+		var labels []string
+		for _, match := range todoistLabel.FindAllStringSubmatch(content, -1) {
+			labels = append(labels, match[2])
+		}
+		todos = append(todos, importedTodo{
+			List:   project,
+			Title:  todoistLabel.ReplaceAllString(content, "$1"),
+			Labels: labels,
+			Due:    field(record, "DATE"),
+		})
+	}
+}
+
+// googleTasksExport is the Tasks.json of Google Takeout.
+type googleTasksExport struct {
+	Items []struct {
+		Title string `json:"title"`
+		Items []struct {
+			Title   string `json:"title"`
+			Status  string `json:"status"`
+			Due     string `json:"due"`
+			Deleted bool   `json:"deleted"`
+		} `json:"items"`
+	} `json:"items"`
+}
+
+// parseGoogleTasks reads the task lists of a Google Takeout export; every
+// task list maps to a list.
+func parseGoogleTasks(data []byte) ([]importedTodo, error) {
+	var export googleTasksExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, ErrInvalidTodoImport
+	}
+	var todos []importedTodo
+	for _, list := range export.Items {
+		for _, task := range list.Items {
+			todos = append(todos, importedTodo{
+				List:      list.Title,
+				Title:     task.Title,
+				Due:       task.Due,
+				Completed: task.Status == "completed",
+				Deleted:   task.Deleted,
+			})
+		}
+	}
+	return todos, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+)
+
+// ErrInvalidReaction indicates an emoji outside ReactionEmojis.
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+// ReactionEmojis are the emojis todos can be reacted with.
+var ReactionEmojis = []string{"👍", "👎", "❤️", "🎉", "😄", "😮", "😢", "🙏", "👀", "✅"}
+
+// TodoReaction is the reaction of one user on a todo. A user reacts at
+// most once with each emoji.
+type TodoReaction struct {
+	Email string `bson:"email"`
+	Emoji string `bson:"emoji"`
+}
+
+// ReactionCount is how many users reacted to a todo with one emoji.
+type ReactionCount struct {
+	Emoji string `json:"emoji" xml:"emoji"`
+	Count int    `json:"count" xml:"count"`
+}
+
+// countReactions aggregates reactions per emoji, in the order of
+// ReactionEmojis.
+func countReactions(reactions []TodoReaction) []ReactionCount {
+	if len(reactions) == 0 {
+		return nil
+	}
+	var counts []ReactionCount
+	for _, emoji := range ReactionEmojis {
+		count := 0
+		for _, reaction := range reactions {
+			if reaction.Emoji == emoji {
+				count++
+			}
+		}
+		if count > 0 {
+			counts = append(counts, ReactionCount{Emoji: emoji, Count: count})
+		}
+	}
+	return counts
+}
+
+// React adds or removes the reaction of a user on a live todo with one
+// update that only matches when it changes something, and reports whether
+// it did.
+func (m *MongoTodoRepository) React(ctx context.Context, id primitive.ObjectID, reaction TodoReaction, add bool) (Todo, bool, error) {
+	elem := bson.M{"$elemMatch": bson.M{"email": reaction.Email, "emoji": reaction.Emoji}}
+	filter := bson.M{"_id": id, "deletedAt": nil, "reactions": elem}
+	change := bson.M{"$pull": bson.M{"reactions": bson.M{"email": reaction.Email, "emoji": reaction.Emoji}}}
+	if add {
+		filter["reactions"] = bson.M{"$not": elem}
+		change = bson.M{"$push": bson.M{"reactions": reaction}}
+	}
+
+	var todo Todo
+	err := m.collection.FindOneAndUpdate(ctx, filter, change, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&todo)
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return todo, err == nil, err
+	}
+	err = m.collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": nil}).Decode(&todo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Todo{}, false, ErrNotFound
+	}
+	return todo, false, err
+}
+
+// todoReactionEvent is the payload of the todo.reaction_* events. It
+// carries the counts but not who reacted, so the events hold no personal
+// data of other users.
+type todoReactionEvent struct {
+	TodoID    string          `json:"todoId"`
+	Emoji     string          `json:"emoji"`
+	Reactions []ReactionCount `json:"reactions"`
+}
+
+// React adds (or, with add false, removes) the emoji reaction of email on
+// a todo. Repeating it changes nothing; when it does change, a
+// todo.reaction_added or todo.reaction_removed event with the new counts
+// is stored, keyed by the todo so the subscribers of a list see them in
+// order.
+func (s *TodoService) React(ctx context.Context, id primitive.ObjectID, email, emoji string, add bool) (TodoResponse, error) {
+	email, emoji = NormalizeEmail(email), strings.TrimSpace(emoji)
+	if email == "" {
+		return TodoResponse{}, ErrInvalidTodoInput
+	}
+	if !slices.Contains(ReactionEmojis, emoji) {
+		return TodoResponse{}, ErrInvalidReaction
+	}
+
+	eventType := events.TodoReactionRemoved
+	if add {
+		eventType = events.TodoReactionAdded
+	}
+	var todo Todo
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		var changed bool
+		var err error
+		todo, changed, err = s.repo.React(ctx, id, TodoReaction{Email: email, Emoji: emoji}, add)
+		if err != nil || !changed {
+			return nil, err
+		}
+		return newEvents(eventType, todo.ID.Hex(), todoReactionEvent{
+			TodoID:    todo.ID.Hex(),
+			Emoji:     emoji,
+			Reactions: append([]ReactionCount{}, countReactions(todo.Reactions)...),
+		}, s.now())
+	})
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	return todo.ToResponse(), nil
+}
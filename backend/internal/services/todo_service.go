@@ -2,55 +2,393 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"log"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/linkpreview"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
 )
 
 var (
 	// ErrInvalidTodoInput indicates missing or malformed todo data.
 	ErrInvalidTodoInput = errors.New("invalid todo input")
-	// ErrInvalidTodoID indicates the todo ID could not be parsed.
-	ErrInvalidTodoID = errors.New("invalid todo id")
+	// ErrInvalidPagination indicates negative or malformed offset/limit values.
+	ErrInvalidPagination = errors.New("invalid pagination")
+	// ErrUnboundedQuery is returned for todo queries that would read or
+	// delete every todo without asking for it explicitly (see TodoQuery.All).
+	ErrUnboundedQuery = errors.New("unbounded query")
+	// ErrInvalidRecurrence indicates an unknown todo recurrence.
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+	// ErrInvalidClientID indicates a client ID that is not a UUID.
+	ErrInvalidClientID = errors.New("invalid client id")
+	// ErrDuplicateClientID is returned when the owner already has a todo
+	// with the client ID of a new one.
+	ErrDuplicateClientID = errors.New("duplicate client id")
+	// ErrInvalidTodoLabel indicates a color or icon outside TodoColors and
+	// TodoIcons.
+	ErrInvalidTodoLabel = errors.New("invalid todo label")
+)
+
+// TodoColors is the palette todos can be labelled with; the frontend maps
+// each name to its own shade.
+var TodoColors = []string{"red", "orange", "yellow", "green", "teal", "blue", "purple", "pink", "gray"}
+
+// TodoIcons is the icon set todos can be labelled with.
+var TodoIcons = []string{"bed", "broom", "wrench", "key", "bell", "cart", "phone", "star", "calendar", "luggage"}
+
+// TodoLabel is the optional color and icon of a todo.
+type TodoLabel struct {
+	Color string
+	Icon  string
+}
+
+// normalize lowercases the label and checks it against the palette and
+// the icon set; empty values are allowed.
+func (l TodoLabel) normalize() (TodoLabel, error) {
+	l.Color = strings.ToLower(NormalizeText(l.Color))
+	l.Icon = strings.ToLower(NormalizeText(l.Icon))
+	if (l.Color != "" && !slices.Contains(TodoColors, l.Color)) || (l.Icon != "" && !slices.Contains(TodoIcons, l.Icon)) {
+		return TodoLabel{}, ErrInvalidTodoLabel
+	}
+	return l, nil
+}
+
+// Todo recurrences.
+const (
+	RecurDaily   = "daily"
+	RecurWeekly  = "weekly"
+	RecurMonthly = "monthly"
 )
 
+// nextOccurrence returns the first occurrence of recurrence after t.
+func nextOccurrence(recurrence string, t time.Time) time.Time {
+	switch recurrence {
+	case RecurDaily:
+		return t.AddDate(0, 0, 1)
+	case RecurWeekly:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
 // TodoUpdate models the fields that can be updated on a Todo.
 type TodoUpdate struct {
 	Title     *string
 	Completed *bool
+	// Color and Icon replace the label; an empty string removes it.
+	Color *string
+	Icon  *string
+	// Assignee delegates the todo to another user; an empty string takes
+	// it back.
+	Assignee *string
+	// CompletedAt is recorded when Completed is true; reopening a todo
+	// clears it.
+	CompletedAt time.Time
+	// EndRecurrence stops the todo from repeating, once its next occurrence
+	// was created.
+	EndRecurrence bool
+	// Previews replaces the link previews, along with a new Title; an
+	// empty slice removes them.
+	Previews *[]linkpreview.Preview
+	// TitleVersion, when set, applies the update only while the title is
+	// at that version; otherwise ErrTitleVersionConflict is returned. Every
+	// new Title moves the version forward.
+	TitleVersion *int
+}
+
+// TodoQuery selects the todos returned by a listing.
+type TodoQuery struct {
+	// ID restricts the listing to one todo when not zero.
+	ID primitive.ObjectID
+	// Email restricts the listing to one owner when not empty.
+	Email string
+	// ListID restricts the listing to the todos of one shared list when
+	// not zero.
+	ListID primitive.ObjectID
+	// RoomID restricts the listing to the todos of one room when not zero.
+	RoomID primitive.ObjectID
+	// RoomsOnly restricts the listing to todos linked to any room.
+	RoomsOnly bool
+	// PropertyID restricts the listing to the todos of one property when
+	// not zero.
+	PropertyID primitive.ObjectID
+	// Open restricts the listing to the todos not completed yet.
+	Open bool
+	// Color and Icon restrict the listing to the todos with that label when
+	// not empty.
+	Color string
+	Icon  string
+	// Trashed lists the todos in the trash instead of the live ones.
+	Trashed bool
+	// IncludeArchived also lists the todos moved to the archive.
+	IncludeArchived bool
+	// CalDAVName restricts the listing to the todo a CalDAV client created
+	// under that resource name when not empty.
+	CalDAVName string
+	// ClientID restricts the listing to the todo its client named so when
+	// not empty.
+	ClientID string
+	// RecurringDue restricts the listing to recurring todos whose next
+	// occurrence is due at that time, when not zero.
+	RecurringDue time.Time
+	// Offset skips that many todos; Limit caps the result (zero means all).
+	Offset int
+	Limit  int
+	// All must be set to list the todos without any of the filters above
+	// that narrow the scan down; the repositories reject such queries
+	// otherwise, so no caller reads the whole collection by mistake.
+	All bool
+}
+
+// Bounded reports whether query narrows the todos down by owner, list,
+// room, property, ID, CalDAV name or due recurrence, or asks for all of
+// them with All.
+func (q TodoQuery) Bounded() bool {
+	return q.All || q.Email != "" || !q.ID.IsZero() || !q.ListID.IsZero() || !q.RoomID.IsZero() ||
+		!q.PropertyID.IsZero() || q.CalDAVName != "" || !q.RecurringDue.IsZero()
+}
+
+// TodoPage is one slice of a todo listing plus the total matching count.
+type TodoPage struct {
+	Todos []TodoResponse
+	Total int64
 }
 
 // TodoRepository is the storage contract required by the todo service.
 type TodoRepository interface {
-	List(ctx context.Context, email string) ([]Todo, error)
+	List(ctx context.Context, query TodoQuery) ([]Todo, error)
+	Count(ctx context.Context, query TodoQuery) (int64, error)
+	// FindByID returns a todo that is not in the trash or ErrNotFound.
+	FindByID(ctx context.Context, id primitive.ObjectID) (Todo, error)
 	Create(ctx context.Context, todo Todo) (Todo, error)
+	// Update modifies a todo that is not in the trash.
 	Update(ctx context.Context, id primitive.ObjectID, update TodoUpdate) (Todo, error)
-	Delete(ctx context.Context, id primitive.ObjectID) error
-	Clear(ctx context.Context, email string) error
+	// Trash moves a live todo to the trash; Restore takes it back out.
+	Trash(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID) (Todo, error)
+	// CompleteAll applies update.Completed to every live todo of email not
+	// already in that state and returns the todos it changed.
+	CompleteAll(ctx context.Context, email string, update TodoUpdate) ([]Todo, error)
+	// React adds or removes the reaction of a user on a live todo and
+	// reports whether it changed.
+	React(ctx context.Context, id primitive.ObjectID, reaction TodoReaction, add bool) (Todo, bool, error)
+	// TransitionApproval moves a live todo between approval statuses or
+	// returns ErrApprovalStateConflict.
+	TransitionApproval(ctx context.Context, id primitive.ObjectID, from, to string, at time.Time) (Todo, error)
+	// TrashCompleted moves the completed live todos of email to the trash
+	// and returns how many.
+	TrashCompleted(ctx context.Context, email string, at time.Time) (int64, error)
+	// Purge deletes the todos trashed before before and returns how many.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// Archive moves the live todos completed before before to the archive,
+	// where only the listings with IncludeArchived find them, and returns
+	// how many.
+	Archive(ctx context.Context, before time.Time) (int64, error)
+	// Clear deletes the todos of email, or every todo with all; it fails
+	// with ErrUnboundedQuery when given neither.
+	Clear(ctx context.Context, email string, all bool) error
 }
 
-// MongoTodoRepository implements TodoRepository backed by MongoDB.
+// MongoTodoRepository implements TodoRepository backed by MongoDB. The
+// todos of an email are looked up by the ID of its account in the users
+// collection, when it has one.
 type MongoTodoRepository struct {
 	collection *mongo.Collection
+	users      *mongo.Collection
+	archive    *mongo.Collection
+	explain    bool
 }
 
 // NewMongoTodoRepository creates a new repository wrapper around a Mongo collection.
-func NewMongoTodoRepository(collection *mongo.Collection) *MongoTodoRepository {
-	return &MongoTodoRepository{collection: collection}
+func NewMongoTodoRepository(collection, users *mongo.Collection) *MongoTodoRepository {
+	return &MongoTodoRepository{collection: collection, users: users}
+}
+
+// SetExplain makes List log the plan MongoDB picks for each listing and
+// whether it uses an index. It costs an extra command per listing, so it
+// is meant for debugging.
+func (m *MongoTodoRepository) SetExplain(explain bool) {
+	m.explain = explain
+}
+
+// EnsureIndexes creates the indexes used to list the todos of a property
+// or a user (all of them, the open ones or by due date) and to find the
+// trashed, recurring and recently completed ones. The email index serves
+// the todos of emails without an account. The client IDs are unique per
+// account, or per email for the todos of emails without one. There is no
+// userId+position index: todos have no manual order, and the listings sort
+// by createdAt, which userId+createdAt serves.
+func (m *MongoTodoRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "propertyId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "completed", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "dueAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "nextOccurrence", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "completedAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "caldav.name", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "clientId", Value: 1}, {Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("client_id_unique").
+				SetPartialFilterExpression(bson.M{"clientId": bson.M{"$type": "string"}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return m.ensureArchiveIndexes(ctx)
 }
 
-// List returns todos optionally filtered by email.
-func (m *MongoTodoRepository) List(ctx context.Context, email string) ([]Todo, error) {
+// BackfillOwners sets the user ID of the todos stored before todos had
+// one, or created for an email before it had an account, and returns how
+// many it updated. It is safe to run again.
+func (m *MongoTodoRepository) BackfillOwners(ctx context.Context) (int64, error) {
+	cursor, err := m.users.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"email": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	for cursor.Next(ctx) {
+		var user User
+		if err := cursor.Decode(&user); err != nil {
+			return updated, err
+		}
+		res, err := m.collection.UpdateMany(ctx, bson.M{"email": user.Email, "userId": nil}, bson.M{"$set": bson.M{"userId": user.ID}})
+		if err != nil {
+			return updated, err
+		}
+		updated += res.ModifiedCount
+	}
+	return updated, cursor.Err()
+}
+
+// todoOwner returns the ID of the account of email in users, or nil when
+// email has none.
+func todoOwner(ctx context.Context, users *mongo.Collection, email string) (*primitive.ObjectID, error) {
+	var user User
+	err := users.FindOne(ctx, bson.M{"email": email}, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user.ID, nil
+}
+
+// ownerFilter matches the todos of email: by the ID of its account, or by
+// the email itself when it has none.
+func ownerFilter(ctx context.Context, users *mongo.Collection, email string) (bson.M, error) {
+	id, err := todoOwner(ctx, users, email)
+	if err != nil {
+		return nil, err
+	}
+	if id == nil {
+		return bson.M{"email": email}, nil
+	}
+	return bson.M{"userId": *id}, nil
+}
+
+// filter is todoFilter with the owner of query.Email resolved.
+func (m *MongoTodoRepository) filter(ctx context.Context, query TodoQuery) (bson.M, error) {
+	filter := todoFilter(query)
+	if query.Email == "" {
+		return filter, nil
+	}
+	owner, err := ownerFilter(ctx, m.users, query.Email)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(filter, owner)
+	return filter, nil
+}
+
+// todoFilter translates query, except its owner (see filter).
+func todoFilter(query TodoQuery) bson.M {
 	filter := bson.M{}
-	if email != "" {
-		filter["email"] = email
+	if !query.ID.IsZero() {
+		filter["_id"] = query.ID
+	}
+	if !query.ListID.IsZero() {
+		filter["listId"] = query.ListID
+	}
+	if !query.RoomID.IsZero() {
+		filter["roomId"] = query.RoomID
+	} else if query.RoomsOnly {
+		filter["roomId"] = bson.M{"$ne": nil}
+	}
+	if !query.PropertyID.IsZero() {
+		filter["propertyId"] = query.PropertyID
+	}
+	if query.Open {
+		filter["completed"] = false
+	}
+	if query.Color != "" {
+		filter["color"] = query.Color
+	}
+	if query.Icon != "" {
+		filter["icon"] = query.Icon
+	}
+	if query.Trashed {
+		filter["deletedAt"] = bson.M{"$ne": nil}
+	} else {
+		filter["deletedAt"] = nil
+	}
+	if !query.RecurringDue.IsZero() {
+		filter["nextOccurrence"] = bson.M{"$lte": query.RecurringDue}
+	}
+	if query.CalDAVName != "" {
+		filter["caldav.name"] = query.CalDAVName
 	}
+	if query.ClientID != "" {
+		filter["clientId"] = query.ClientID
+	}
+	return filter
+}
 
-	cursor, err := m.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": 1}))
+// List returns the todos matching query ordered by creation date.
+func (m *MongoTodoRepository) List(ctx context.Context, query TodoQuery) ([]Todo, error) {
+	if !query.Bounded() {
+		return nil, ErrUnboundedQuery
+	}
+	sort := bson.D{{Key: "createdAt", Value: 1}}
+	opts := options.Find().SetSort(sort)
+	if query.Offset > 0 {
+		opts.SetSkip(int64(query.Offset))
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+
+	filter, err := m.filter(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if query.IncludeArchived && m.archive != nil {
+		return m.listWithArchive(ctx, filter, query)
+	}
+	if m.explain {
+		m.logPlan(ctx, filter, sort)
+	}
+	cursor, err := m.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +401,49 @@ func (m *MongoTodoRepository) List(ctx context.Context, email string) ([]Todo, e
 	return todos, nil
 }
 
-// Create stores a todo in MongoDB and returns it with the generated ID.
+// logPlan logs the plan of a listing, warning when it scans the whole
+// collection.
+func (m *MongoTodoRepository) logPlan(ctx context.Context, filter bson.M, sort bson.D) {
+	shape, plan, indexed, err := ExplainFind(ctx, m.collection, filter, sort)
+	switch {
+	case err != nil:
+		log.Printf("no se pudo obtener el plan de %s: %v", shape, err)
+	case indexed:
+		log.Printf("plan de consulta con indice: %s -> %s", shape, plan)
+	default:
+		log.Printf("plan de consulta SIN indice: %s -> %s", shape, plan)
+	}
+}
+
+// Count returns how many todos match query, ignoring pagination.
+func (m *MongoTodoRepository) Count(ctx context.Context, query TodoQuery) (int64, error) {
+	if !query.Bounded() {
+		return 0, ErrUnboundedQuery
+	}
+	filter, err := m.filter(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	if query.IncludeArchived && m.archive != nil {
+		return m.countWithArchive(ctx, filter)
+	}
+	return m.collection.CountDocuments(ctx, filter)
+}
+
+// Create stores a todo in MongoDB and returns it with the generated ID. A
+// todo without UserID gets the one of the account of its email, if any.
 func (m *MongoTodoRepository) Create(ctx context.Context, todo Todo) (Todo, error) {
+	if todo.UserID == nil {
+		owner, err := todoOwner(ctx, m.users, todo.Email)
+		if err != nil {
+			return Todo{}, err
+		}
+		todo.UserID = owner
+	}
 	res, err := m.collection.InsertOne(ctx, todo)
+	if mongo.IsDuplicateKeyError(err) && todo.ClientID != "" {
+		return Todo{}, ErrDuplicateClientID
+	}
 	if err != nil {
 		return Todo{}, err
 	}
@@ -81,112 +459,428 @@ func (m *MongoTodoRepository) Update(ctx context.Context, id primitive.ObjectID,
 	if update.Title != nil {
 		updateDoc["title"] = *update.Title
 	}
+	unset := bson.M{}
+	for field, value := range map[string]*string{"color": update.Color, "icon": update.Icon, "assignee": update.Assignee} {
+		switch {
+		case value == nil:
+		case *value == "":
+			unset[field] = ""
+		default:
+			updateDoc[field] = *value
+		}
+	}
 	if update.Completed != nil {
 		updateDoc["completed"] = *update.Completed
+		if *update.Completed {
+			updateDoc["completedAt"] = update.CompletedAt
+		} else {
+			unset["completedAt"] = ""
+		}
+	}
+	if update.EndRecurrence {
+		unset["recurrence"], unset["nextOccurrence"] = "", ""
+	}
+	if update.Previews != nil {
+		if len(*update.Previews) == 0 {
+			unset["previews"] = ""
+		} else {
+			updateDoc["previews"] = *update.Previews
+		}
+	}
+	change := bson.M{"$set": updateDoc}
+	if len(unset) > 0 {
+		change["$unset"] = unset
+	}
+	if update.Title != nil {
+		change["$inc"] = bson.M{"titleVersion": 1}
+	}
+	filter := bson.M{"_id": id, "deletedAt": nil}
+	if update.TitleVersion != nil {
+		// Todos whose title never changed have no version.
+		filter["titleVersion"] = *update.TitleVersion
+		if *update.TitleVersion == 0 {
+			filter["titleVersion"] = bson.M{"$in": bson.A{0, nil}}
+		}
 	}
 
 	res := m.collection.FindOneAndUpdate(
 		ctx,
-		bson.M{"_id": id},
-		bson.M{"$set": updateDoc},
+		filter,
+		change,
 		options.FindOneAndUpdate().SetReturnDocument(options.After),
 	)
 
 	var todo Todo
 	if err := res.Decode(&todo); err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return Todo{}, ErrNotFound
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return Todo{}, err
 		}
-		return Todo{}, err
+		if update.TitleVersion != nil {
+			if _, err := m.FindByID(ctx, id); err == nil {
+				return Todo{}, ErrTitleVersionConflict
+			}
+		}
+		return Todo{}, ErrNotFound
 	}
 	return todo, nil
 }
 
-// Delete removes a todo by ID.
-func (m *MongoTodoRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
-	res, err := m.collection.DeleteOne(ctx, bson.M{"_id": id})
+// Trash sets the deletion date of a live todo.
+func (m *MongoTodoRepository) Trash(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	res, err := m.collection.UpdateOne(ctx, bson.M{"_id": id, "deletedAt": nil}, bson.M{"$set": bson.M{"deletedAt": at}})
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return ErrNotFound
 	}
 	return nil
 }
 
-// Clear remove todos optionally filtered by email.
-func (m *MongoTodoRepository) Clear(ctx context.Context, email string) error {
+// FindByID retrieves a live todo or returns ErrNotFound.
+func (m *MongoTodoRepository) FindByID(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	var todo Todo
+	err := m.collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": nil}).Decode(&todo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Todo{}, ErrNotFound
+	}
+	return todo, err
+}
+
+// Restore clears the deletion date of a trashed todo.
+func (m *MongoTodoRepository) Restore(ctx context.Context, id primitive.ObjectID) (Todo, error) {
+	var todo Todo
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "deletedAt": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deletedAt": ""}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&todo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Todo{}, ErrNotFound
+	}
+	return todo, err
+}
+
+// CompleteAll finds the todos to change and updates them with a single
+// UpdateMany. It runs inside the transaction of TodoService.CompleteAll, so
+// a concurrent change to the same todos aborts and retries one of them.
+func (m *MongoTodoRepository) CompleteAll(ctx context.Context, email string, update TodoUpdate) ([]Todo, error) {
+	completed := *update.Completed
+	filter, err := ownerFilter(ctx, m.users, email)
+	if err != nil {
+		return nil, err
+	}
+	filter["deletedAt"], filter["completed"] = nil, !completed
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var todos []Todo
+	if err := cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+	if len(todos) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(todos))
+	for i := range todos {
+		ids[i] = todos[i].ID
+		todos[i].Completed, todos[i].CompletedAt = completed, nil
+		if completed {
+			todos[i].CompletedAt = &update.CompletedAt
+		}
+	}
+	change := bson.M{"$set": bson.M{"completed": completed, "completedAt": update.CompletedAt}}
+	if !completed {
+		change = bson.M{"$set": bson.M{"completed": false}, "$unset": bson.M{"completedAt": ""}}
+	}
+	filter["_id"] = bson.M{"$in": ids}
+	if _, err := m.collection.UpdateMany(ctx, filter, change); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// TrashCompleted sets the deletion date of the completed live todos of
+// email with a single UpdateMany.
+func (m *MongoTodoRepository) TrashCompleted(ctx context.Context, email string, at time.Time) (int64, error) {
+	filter, err := ownerFilter(ctx, m.users, email)
+	if err != nil {
+		return 0, err
+	}
+	filter["deletedAt"], filter["completed"] = nil, true
+	res, err := m.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"deletedAt": at}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// Purge deletes the todos trashed before before.
+func (m *MongoTodoRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := m.collection.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// Clear implements TodoRepository.
+func (m *MongoTodoRepository) Clear(ctx context.Context, email string, all bool) error {
+	if email == "" && !all {
+		return ErrUnboundedQuery
+	}
 	filter := bson.M{}
 	if email != "" {
-		filter["email"] = email
+		var err error
+		if filter, err = ownerFilter(ctx, m.users, email); err != nil {
+			return err
+		}
+	}
+	if _, err := m.collection.DeleteMany(ctx, filter); err != nil {
+		return err
+	}
+	if m.archive == nil {
+		return nil
 	}
-	_, err := m.collection.DeleteMany(ctx, filter)
+	_, err := m.archive.DeleteMany(ctx, filter)
 	return err
 }
 
 // TodoService encapsulates business logic for todo operations.
 type TodoService struct {
-	repo TodoRepository
-	now  func() time.Time
+	repo     TodoRepository
+	reads    TodoRepository
+	outbox   Outbox
+	previews *LinkPreviewService
+	now      func() time.Time
+	ids      IDGenerator
+	// titleEdits and titleHub are set by SetTitleEdits.
+	titleEdits TitleEditRepository
+	titleHub   *realtime.Hub
 }
 
-// NewTodoService builds a new TodoService instance.
-func NewTodoService(repo TodoRepository, now func() time.Time) *TodoService {
+// NewTodoService builds a new TodoService instance; outbox stores the
+// todo.completed events.
+func NewTodoService(repo TodoRepository, outbox Outbox, now func() time.Time, ids IDGenerator) *TodoService {
 	if now == nil {
 		now = time.Now
 	}
-	return &TodoService{repo: repo, now: now}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	return &TodoService{repo: repo, reads: repo, outbox: outbox, now: now, ids: ids}
 }
 
-// List returns todos optionally filtered by user email.
-func (s *TodoService) List(ctx context.Context, email string) ([]TodoResponse, error) {
-	email = NormalizeEmail(email)
+// SetListRepository makes List and ListForRoom read from reads, typically
+// the same collection routed to secondaries. Those pages may then lag the
+// writes by up to the staleness the read preference tolerates, so a todo
+// just created can be missing for a moment; writes, quotas and the jobs
+// keep using the primary.
+func (s *TodoService) SetListRepository(reads TodoRepository) {
+	s.reads = reads
+}
+
+// SetLinkPreviews makes the todos carry the previews of the URLs in their
+// titles, resolved when they are created or retitled.
+func (s *TodoService) SetLinkPreviews(previews *LinkPreviewService) {
+	s.previews = previews
+}
+
+// linkPreviews returns the previews of the URLs in title, if enabled.
+func (s *TodoService) linkPreviews(ctx context.Context, title string) []linkpreview.Preview {
+	if s.previews == nil {
+		return nil
+	}
+	return s.previews.Resolve(ctx, title)
+}
 
-	todos, err := s.repo.List(ctx, email)
+// List returns a page of todos optionally filtered by user email; scoped
+// requests only see the todos of their property.
+func (s *TodoService) List(ctx context.Context, query TodoQuery) (TodoPage, error) {
+	query.Email = NormalizeEmail(query.Email)
+	query.PropertyID = ScopedProperty(ctx)
+	if query.Offset < 0 || query.Limit < 0 {
+		return TodoPage{}, ErrInvalidPagination
+	}
+	label, err := TodoLabel{Color: query.Color, Icon: query.Icon}.normalize()
 	if err != nil {
-		return nil, err
+		return TodoPage{}, err
+	}
+	query.Color, query.Icon = label.Color, label.Icon
+
+	todos, err := s.reads.List(ctx, query)
+	if err != nil {
+		return TodoPage{}, err
+	}
+
+	total := int64(len(todos))
+	if query.Limit > 0 {
+		if total, err = s.reads.Count(ctx, query); err != nil {
+			return TodoPage{}, err
+		}
 	}
 
 	responses := make([]TodoResponse, 0, len(todos))
 	for _, todo := range todos {
 		responses = append(responses, todo.ToResponse())
 	}
-	return responses, nil
+	return TodoPage{Todos: responses, Total: total}, nil
+}
+
+// ListForRoom returns a page of the todos linked to a room.
+func (s *TodoService) ListForRoom(ctx context.Context, roomID string, query TodoQuery) (TodoPage, error) {
+	objID, err := primitive.ObjectIDFromHex(roomID)
+	if err != nil {
+		return TodoPage{}, ErrInvalidRoomID
+	}
+	query.RoomID = objID
+	return s.List(ctx, query)
 }
 
-// Create validates input and stores a new todo.
-func (s *TodoService) Create(ctx context.Context, email, title string) (TodoResponse, error) {
+// Create validates input and stores a new todo; recurrence, when not
+// empty, repeats it daily, weekly or monthly. A non-nil listID adds it to
+// that shared list, which the caller must have authorized.
+func (s *TodoService) Create(ctx context.Context, email, title, recurrence string, label TodoLabel, listID *primitive.ObjectID) (TodoResponse, error) {
+	return s.CreateForClient(ctx, email, "", title, recurrence, label, listID)
+}
+
+// CreateForClient is Create for a todo the client named with the UUID
+// clientID, or none when empty. It returns ErrDuplicateClientID when the
+// owner already has a todo with clientID.
+func (s *TodoService) CreateForClient(ctx context.Context, email, clientID, title, recurrence string, label TodoLabel, listID *primitive.ObjectID) (TodoResponse, error) {
+	clientID = strings.ToLower(NormalizeText(clientID))
+	if clientID != "" && !IsUUID(clientID) {
+		return TodoResponse{}, ErrInvalidClientID
+	}
+	todo, err := s.newTodo(ctx, email, title, recurrence, label, listID)
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	todo.ClientID = clientID
+	created, err := s.repo.Create(ctx, todo)
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	return created.ToResponse(), nil
+}
+
+// FindByClientID returns the live todo of email its client named clientID
+// or ErrNotFound.
+func (s *TodoService) FindByClientID(ctx context.Context, email, clientID string) (Todo, error) {
+	email, clientID = NormalizeEmail(email), strings.ToLower(NormalizeText(clientID))
+	if email == "" || !IsUUID(clientID) {
+		return Todo{}, ErrNotFound
+	}
+	return firstTodo(s.repo.List(ctx, TodoQuery{Email: email, ClientID: clientID}))
+}
+
+// newTodo validates the input of Create and builds the todo to store.
+func (s *TodoService) newTodo(ctx context.Context, email, title, recurrence string, label TodoLabel, listID *primitive.ObjectID) (Todo, error) {
 	email = NormalizeEmail(email)
 	title = NormalizeText(title)
+	recurrence = strings.ToLower(NormalizeText(recurrence))
 
 	if email == "" || title == "" {
-		return TodoResponse{}, ErrInvalidTodoInput
+		return Todo{}, ErrInvalidTodoInput
+	}
+	label, err := label.normalize()
+	if err != nil {
+		return Todo{}, err
 	}
 
 	todo := Todo{
+		ID:        s.ids.NewID(),
 		Email:     email,
 		Title:     title,
 		Completed: false,
 		CreatedAt: s.now(),
+		Color:     label.Color,
+		Icon:      label.Icon,
+		ListID:    listID,
+	}
+	switch recurrence {
+	case "":
+	case RecurDaily, RecurWeekly, RecurMonthly:
+		next := nextOccurrence(recurrence, todo.CreatedAt)
+		todo.Recurrence, todo.NextOccurrence = recurrence, &next
+	default:
+		return Todo{}, ErrInvalidRecurrence
 	}
 
-	created, err := s.repo.Create(ctx, todo)
+	todo.Previews = s.linkPreviews(ctx, title)
+	return todo, nil
+}
+
+// CreateForRoom stores a todo linked to a room, e.g. a cleaning task;
+// propertyID is the hotel of the room, if any.
+func (s *TodoService) CreateForRoom(ctx context.Context, email, title string, roomID primitive.ObjectID, propertyID *primitive.ObjectID) (TodoResponse, error) {
+	email = NormalizeEmail(email)
+	title = NormalizeText(title)
+
+	if email == "" || title == "" || roomID.IsZero() {
+		return TodoResponse{}, ErrInvalidTodoInput
+	}
+
+	created, err := s.repo.Create(ctx, Todo{
+		ID:         s.ids.NewID(),
+		Email:      email,
+		Title:      title,
+		CreatedAt:  s.now(),
+		RoomID:     &roomID,
+		PropertyID: propertyID,
+	})
 	if err != nil {
 		return TodoResponse{}, err
 	}
-
 	return created.ToResponse(), nil
 }
 
+// Get returns a live todo; one in the trash is ErrNotFound, like a missing
+// one.
+func (s *TodoService) Get(ctx context.Context, id primitive.ObjectID) (TodoResponse, error) {
+	todo, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	return todo.ToResponse(), nil
+}
+
+// TodoETag returns the weak ETag of todo. Todos keep no version of their
+// own, so it hashes every field the API shows; it is weak because the JSON,
+// XML and JSON:API representations of the same todo share it.
+func TodoETag(todo TodoResponse) string {
+	data, _ := json.Marshal(todo)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
 // Update applies the provided modification to a todo and returns the updated todo.
-func (s *TodoService) Update(ctx context.Context, id string, update TodoUpdate) (TodoResponse, error) {
-	if update.Title == nil && update.Completed == nil {
+func (s *TodoService) Update(ctx context.Context, id primitive.ObjectID, update TodoUpdate) (TodoResponse, error) {
+	if update.Title == nil && update.Completed == nil && update.Color == nil && update.Icon == nil && update.Assignee == nil && !update.EndRecurrence {
 		return TodoResponse{}, ErrInvalidTodoInput
 	}
-
-	objID, err := primitive.ObjectIDFromHex(id)
+	if update.Assignee != nil {
+		assignee := NormalizeEmail(*update.Assignee)
+		update.Assignee = &assignee
+	}
+	var label TodoLabel
+	if update.Color != nil {
+		label.Color = *update.Color
+	}
+	if update.Icon != nil {
+		label.Icon = *update.Icon
+	}
+	label, err := label.normalize()
 	if err != nil {
-		return TodoResponse{}, ErrInvalidTodoID
+		return TodoResponse{}, err
+	}
+	if update.Color != nil {
+		update.Color = &label.Color
+	}
+	if update.Icon != nil {
+		update.Icon = &label.Icon
 	}
 
 	if update.Title != nil {
@@ -195,27 +889,151 @@ func (s *TodoService) Update(ctx context.Context, id string, update TodoUpdate)
 			return TodoResponse{}, ErrInvalidTodoInput
 		}
 		update.Title = &title
+		previews := s.linkPreviews(ctx, title)
+		update.Previews = &previews
+	}
+	if update.Completed != nil && *update.Completed {
+		update.CompletedAt = s.now()
 	}
 
-	updated, err := s.repo.Update(ctx, objID, update)
+	var updated Todo
+	err = s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		var err error
+		if updated, err = s.repo.Update(ctx, id, update); err != nil {
+			return nil, err
+		}
+		if update.Completed == nil || !*update.Completed {
+			return nil, nil
+		}
+		return newEvents(events.TodoCompleted, updated.ID.Hex(), updated.ToResponse(), update.CompletedAt)
+	})
 	if err != nil {
 		return TodoResponse{}, err
 	}
-
 	return updated.ToResponse(), nil
 }
 
-// Delete removes a todo by ID.
-func (s *TodoService) Delete(ctx context.Context, id string) error {
-	objID, err := primitive.ObjectIDFromHex(id)
+// Delete moves a todo to the trash, from where it can be restored until
+// PurgeTrash removes it.
+func (s *TodoService) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return s.repo.Trash(ctx, id, s.now())
+}
+
+// Restore takes a todo out of the trash.
+func (s *TodoService) Restore(ctx context.Context, id primitive.ObjectID) (TodoResponse, error) {
+	todo, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return TodoResponse{}, err
+	}
+	return todo.ToResponse(), nil
+}
+
+// CompleteAll completes or reopens every live todo of email, as the
+// "toggle all" checkbox of a todo list does, and returns how many changed.
+// Todos already in that state are left alone, so concurrent calls never
+// count or announce a todo twice; a todo.completed event is stored for
+// each todo completed.
+func (s *TodoService) CompleteAll(ctx context.Context, email string, completed bool) (int64, error) {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return 0, ErrInvalidTodoInput
+	}
+	update := TodoUpdate{Completed: &completed}
+	if completed {
+		update.CompletedAt = s.now()
+	}
+
+	var changed []Todo
+	err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		var err error
+		if changed, err = s.repo.CompleteAll(ctx, email, update); err != nil || !completed {
+			return nil, err
+		}
+		var pending []events.Event
+		for _, todo := range changed {
+			event, err := events.New(events.TodoCompleted, todo.ID.Hex(), todo.ToResponse(), update.CompletedAt)
+			if err != nil {
+				return nil, err
+			}
+			pending = append(pending, event)
+		}
+		return pending, nil
+	})
 	if err != nil {
-		return ErrInvalidTodoID
+		return 0, err
+	}
+	return int64(len(changed)), nil
+}
+
+// ClearCompleted moves the completed todos of email to the trash and
+// returns how many.
+func (s *TodoService) ClearCompleted(ctx context.Context, email string) (int64, error) {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return 0, ErrInvalidTodoInput
 	}
-	return s.repo.Delete(ctx, objID)
+	return s.repo.TrashCompleted(ctx, email, s.now())
+}
+
+// PurgeTrash deletes for good the todos trashed more than retention ago.
+func (s *TodoService) PurgeTrash(ctx context.Context, retention time.Duration) error {
+	purged, err := s.repo.Purge(ctx, s.now().Add(-retention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("papelera de tareas: %d tareas eliminadas", purged)
+	}
+	return nil
+}
+
+// MaterializeRecurring creates the next occurrence of every recurring todo
+// that is due. The new todo carries the recurrence on and the old one stops
+// repeating, in one transaction so an occurrence is never created twice.
+// Occurrences missed while the job was not running collapse into one.
+func (s *TodoService) MaterializeRecurring(ctx context.Context) error {
+	now := s.now()
+	due, err := s.repo.List(ctx, TodoQuery{RecurringDue: now})
+	if err != nil {
+		return err
+	}
+
+	for _, todo := range due {
+		next := *todo.NextOccurrence
+		for !next.After(now) {
+			next = nextOccurrence(todo.Recurrence, next)
+		}
+		occurrence := Todo{
+			ID:             s.ids.NewID(),
+			UserID:         todo.UserID,
+			Email:          todo.Email,
+			Title:          todo.Title,
+			CreatedAt:      now,
+			RoomID:         todo.RoomID,
+			PropertyID:     todo.PropertyID,
+			ListID:         todo.ListID,
+			Recurrence:     todo.Recurrence,
+			NextOccurrence: &next,
+			Color:          todo.Color,
+			Icon:           todo.Icon,
+			Previews:       todo.Previews,
+		}
+		err := s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+			if _, err := s.repo.Update(ctx, todo.ID, TodoUpdate{EndRecurrence: true}); err != nil {
+				return nil, err
+			}
+			_, err := s.repo.Create(ctx, occurrence)
+			return nil, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Clear removes todos optionally filtered by email.
-func (s *TodoService) Clear(ctx context.Context, email string) error {
+// Clear removes the todos of email, or every todo with all.
+func (s *TodoService) Clear(ctx context.Context, email string, all bool) error {
 	email = NormalizeEmail(email)
-	return s.repo.Clear(ctx, email)
+	return s.repo.Clear(ctx, email, all)
 }
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StatsGroup counts the todos of one tag, list or weekday: how many there
+// are, how many were completed and the median time from creation to
+// completion, in seconds (zero without dated completions).
+type StatsGroup struct {
+	Key                     string `json:"key" xml:"key"`
+	Todos                   int64  `json:"todos" xml:"todos"`
+	Completed               int64  `json:"completed" xml:"completed"`
+	MedianCompletionSeconds int64  `json:"medianCompletionSeconds" xml:"medianCompletionSeconds"`
+}
+
+// TodoBreakdown groups the todos of an account by tag (their color and
+// icon labels), by list (the key is the list ID, empty for the todos
+// outside lists) and by the weekday they were created on.
+type TodoBreakdown struct {
+	Tags     []StatsGroup `json:"tags" xml:"tags>tag"`
+	Lists    []StatsGroup `json:"lists" xml:"lists>list"`
+	Weekdays []StatsGroup `json:"weekdays" xml:"weekdays>weekday"`
+}
+
+// StatsRow is a group as aggregated by a TodoStatsRepository: Durations
+// holds the milliseconds from creation to completion of each dated
+// completion, for the service to take the median.
+type StatsRow struct {
+	Key       string  `bson:"_id"`
+	Todos     int64   `bson:"todos"`
+	Completed int64   `bson:"completed"`
+	Durations []int64 `bson:"durations"`
+}
+
+// StatsRows are the groups of a TodoBreakdown before the medians.
+type StatsRows struct {
+	Tags     []StatsRow `bson:"tags"`
+	Lists    []StatsRow `bson:"lists"`
+	Weekdays []StatsRow `bson:"weekdays"`
+}
+
+// TodoStatsRepository aggregates the live todos owned by email; weekday keys
+// are time.Weekday numbers.
+type TodoStatsRepository interface {
+	Breakdown(ctx context.Context, email string) (StatsRows, error)
+}
+
+// MongoTodoStatsRepository implements TodoStatsRepository with a $facet
+// pipeline over the todos collection; users resolves their owner.
+type MongoTodoStatsRepository struct {
+	collection *mongo.Collection
+	users      *mongo.Collection
+}
+
+// NewMongoTodoStatsRepository creates a repository over the todos and
+// users collections.
+func NewMongoTodoStatsRepository(collection, users *mongo.Collection) *MongoTodoStatsRepository {
+	return &MongoTodoStatsRepository{collection: collection, users: users}
+}
+
+// statsGroup groups by key, collecting the completion durations; $median
+// needs MongoDB 7, so the service takes the median instead.
+func statsGroup(key any) bson.A {
+	return bson.A{
+		bson.M{"$group": bson.M{
+			"_id":       key,
+			"todos":     bson.M{"$sum": 1},
+			"completed": countIf("completed", true),
+			"durations": bson.M{"$push": "$duration"},
+		}},
+		bson.M{"$project": bson.M{
+			"todos": 1, "completed": 1,
+			"durations": bson.M{"$filter": bson.M{"input": "$durations", "cond": bson.M{"$ne": bson.A{"$$this", nil}}}},
+		}},
+	}
+}
+
+// Breakdown implements TodoStatsRepository.
+func (m *MongoTodoStatsRepository) Breakdown(ctx context.Context, email string) (StatsRows, error) {
+	match, err := ownerFilter(ctx, m.users, email)
+	if err != nil {
+		return StatsRows{}, err
+	}
+	match["deletedAt"] = nil
+	rows, err := aggregate[StatsRows](ctx, m.collection, bson.A{
+		bson.M{"$match": match},
+		bson.M{"$set": bson.M{"duration": bson.M{"$cond": bson.A{
+			bson.M{"$and": bson.A{"$completed", bson.M{"$gt": bson.A{"$completedAt", nil}}}},
+			bson.M{"$subtract": bson.A{"$completedAt", "$createdAt"}},
+			nil,
+		}}}},
+		bson.M{"$facet": bson.M{
+			"tags": append(bson.A{
+				bson.M{"$set": bson.M{"tag": bson.A{"$color", "$icon"}}},
+				bson.M{"$unwind": "$tag"},
+				bson.M{"$match": bson.M{"tag": bson.M{"$nin": bson.A{nil, ""}}}},
+			}, statsGroup("$tag")...),
+			"lists": statsGroup(bson.M{"$ifNull": bson.A{bson.M{"$toString": "$listId"}, ""}}),
+			// $dayOfWeek counts from Sunday = 1, time.Weekday from 0.
+			"weekdays": statsGroup(bson.M{"$toString": bson.M{"$subtract": bson.A{bson.M{"$dayOfWeek": "$createdAt"}, 1}}}),
+		}},
+	})
+	if err != nil || len(rows) == 0 {
+		return StatsRows{}, err
+	}
+	return rows[0], nil
+}
+
+// TodoStatsService computes the productivity breakdown of an account and
+// caches it for a short while, since it scans all of its todos.
+type TodoStatsService struct {
+	repo TodoStatsRepository
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedBreakdown
+}
+
+type cachedBreakdown struct {
+	breakdown TodoBreakdown
+	expires   time.Time
+}
+
+// NewTodoStatsService builds a new TodoStatsService. Breakdowns are cached
+// for ttl; a non-positive ttl disables the cache.
+func NewTodoStatsService(repo TodoStatsRepository, ttl time.Duration, now func() time.Time) *TodoStatsService {
+	if now == nil {
+		now = time.Now
+	}
+	return &TodoStatsService{repo: repo, ttl: ttl, now: now, cache: make(map[string]cachedBreakdown)}
+}
+
+// Breakdown returns the todos of email grouped by tag and list, the
+// largest groups first, and by weekday from Monday to Sunday.
+func (s *TodoStatsService) Breakdown(ctx context.Context, email string) (TodoBreakdown, error) {
+	email = NormalizeEmail(email)
+	now := s.now()
+	s.mu.Lock()
+	cached, ok := s.cache[email]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.breakdown, nil
+	}
+
+	rows, err := s.repo.Breakdown(ctx, email)
+	if err != nil {
+		return TodoBreakdown{}, err
+	}
+	breakdown := TodoBreakdown{Tags: statsGroups(rows.Tags), Lists: statsGroups(rows.Lists)}
+	weekdays := map[string]StatsRow{}
+	for _, row := range rows.Weekdays {
+		weekdays[row.Key] = row
+	}
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		row := weekdays[strconv.Itoa(int(day))]
+		row.Key = strings.ToLower(day.String())
+		breakdown.Weekdays = append(breakdown.Weekdays, statsGroupOf(row))
+	}
+
+	if s.ttl > 0 {
+		s.mu.Lock()
+		s.cache[email] = cachedBreakdown{breakdown: breakdown, expires: now.Add(s.ttl)}
+		s.mu.Unlock()
+	}
+	return breakdown, nil
+}
+
+func statsGroups(rows []StatsRow) []StatsGroup {
+	groups := make([]StatsGroup, 0, len(rows))
+	for _, row := range rows {
+		groups = append(groups, statsGroupOf(row))
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Todos != groups[j].Todos {
+			return groups[i].Todos > groups[j].Todos
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+func statsGroupOf(row StatsRow) StatsGroup {
+	group := StatsGroup{Key: row.Key, Todos: row.Todos, Completed: row.Completed}
+	if n := len(row.Durations); n > 0 {
+		durations := append([]int64(nil), row.Durations...)
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		median := durations[n/2]
+		if n%2 == 0 {
+			median = (durations[n/2-1] + durations[n/2]) / 2
+		}
+		group.MedianCompletionSeconds = median / int64(time.Second/time.Millisecond)
+	}
+	return group
+}
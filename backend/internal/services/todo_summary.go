@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// maxSummaryDays caps the range of a todo summary.
+const maxSummaryDays = 92
+
+// maxSummaryOwners caps the owners listed in a todo summary.
+const maxSummaryOwners = 10
+
+// SummaryDay counts the todos created and completed on one day.
+type SummaryDay struct {
+	Date      string `json:"date" xml:"date"`
+	Created   int    `json:"created" xml:"created"`
+	Completed int    `json:"completed" xml:"completed"`
+}
+
+// SummaryOwner counts the todos of one owner in a summary.
+type SummaryOwner struct {
+	Email     string `json:"email" xml:"email"`
+	Completed int    `json:"completed" xml:"completed"`
+	Pending   int    `json:"pending" xml:"pending"`
+	Overdue   int    `json:"overdue" xml:"overdue"`
+}
+
+// TodoSummary sums up the todos of a range [From, To): the todos created
+// and completed in it, and the ones pending and overdue at its end (or
+// now, for ranges reaching the present).
+type TodoSummary struct {
+	From      string         `json:"from" xml:"from"`
+	To        string         `json:"to" xml:"to"`
+	Created   int            `json:"created" xml:"created"`
+	Completed int            `json:"completed" xml:"completed"`
+	Pending   int            `json:"pending" xml:"pending"`
+	Overdue   int            `json:"overdue" xml:"overdue"`
+	Days      []SummaryDay   `json:"days" xml:"days>day"`
+	Owners    []SummaryOwner `json:"owners" xml:"owners>owner"`
+}
+
+// TodoSummaryService sums up the todos for the weekly reports of the
+// managers.
+type TodoSummaryService struct {
+	todos TodoRepository
+	now   func() time.Time
+}
+
+// NewTodoSummaryService builds a new TodoSummaryService instance.
+func NewTodoSummaryService(todos TodoRepository, now func() time.Time) *TodoSummaryService {
+	if now == nil {
+		now = time.Now
+	}
+	return &TodoSummaryService{todos: todos, now: now}
+}
+
+// Summary sums up the todos of the range of query, by default the last
+// seven days including today; GroupBy is ignored. Scoped requests only see
+// the todos of their property.
+func (s *TodoSummaryService) Summary(ctx context.Context, query ReportQuery) (TodoSummary, error) {
+	now := s.now().UTC()
+	to := now.Truncate(24*time.Hour).AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -7)
+	var err error
+	if value := NormalizeText(query.To); value != "" {
+		if to, err = time.Parse(DateLayout, value); err != nil {
+			return TodoSummary{}, ErrInvalidReportQuery
+		}
+		from = to.AddDate(0, 0, -7)
+	}
+	if value := NormalizeText(query.From); value != "" {
+		if from, err = time.Parse(DateLayout, value); err != nil {
+			return TodoSummary{}, ErrInvalidReportQuery
+		}
+	}
+	if !to.After(from) || to.Sub(from) > maxSummaryDays*24*time.Hour {
+		return TodoSummary{}, ErrInvalidReportQuery
+	}
+
+	todos, err := s.todos.List(ctx, TodoQuery{PropertyID: ScopedProperty(ctx), All: true})
+	if err != nil {
+		return TodoSummary{}, err
+	}
+
+	// Pending and overdue are taken at the end of the range, or now.
+	at := to
+	if now.Before(at) {
+		at = now
+	}
+	summary := TodoSummary{From: from.Format(DateLayout), To: to.Format(DateLayout)}
+	days := map[string]*SummaryDay{}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		summary.Days = append(summary.Days, SummaryDay{Date: day.Format(DateLayout)})
+	}
+	for i := range summary.Days {
+		days[summary.Days[i].Date] = &summary.Days[i]
+	}
+	owners := map[string]*SummaryOwner{}
+	owner := func(email string) *SummaryOwner {
+		if owners[email] == nil {
+			owners[email] = &SummaryOwner{Email: email}
+		}
+		return owners[email]
+	}
+
+	for _, todo := range todos {
+		if day := days[todo.CreatedAt.UTC().Format(DateLayout)]; day != nil {
+			summary.Created++
+			day.Created++
+		}
+		if todo.Completed && todo.CompletedAt == nil {
+			// Completed before completions were dated.
+			continue
+		}
+		if todo.Completed && todo.CompletedAt.Before(at) {
+			if day := days[todo.CompletedAt.UTC().Format(DateLayout)]; day != nil {
+				summary.Completed++
+				day.Completed++
+				owner(todo.Email).Completed++
+			}
+			continue
+		}
+		if !todo.CreatedAt.Before(at) {
+			continue
+		}
+		summary.Pending++
+		owner(todo.Email).Pending++
+		if todo.DueAt != nil && todo.DueAt.Before(at) {
+			summary.Overdue++
+			owner(todo.Email).Overdue++
+		}
+	}
+
+	summary.Owners = make([]SummaryOwner, 0, len(owners))
+	for _, o := range owners {
+		summary.Owners = append(summary.Owners, *o)
+	}
+	sort.Slice(summary.Owners, func(i, j int) bool {
+		a, b := summary.Owners[i], summary.Owners[j]
+		if a.Pending+a.Completed != b.Pending+b.Completed {
+			return a.Pending+a.Completed > b.Pending+b.Completed
+		}
+		return a.Email < b.Email
+	})
+	if len(summary.Owners) > maxSummaryOwners {
+		summary.Owners = summary.Owners[:maxSummaryOwners]
+	}
+	return summary, nil
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+)
+
+// MaxSyncOps caps the ops of a single sync batch.
+const MaxSyncOps = 100
+
+// SyncOpRetention is how long the applied ops are remembered: a client
+// that replays an op later than that applies it again.
+const SyncOpRetention = 30 * 24 * time.Hour
+
+// syncClaimTimeout is how long an op stays claimed by a request that
+// neither finished nor released it, e.g. because its replica died.
+const syncClaimTimeout = time.Minute
+
+// Sync op types.
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// Sync op outcomes. A rejected op will never apply and the client drops
+// it; a pending one was not tried, because of a server error on it or on
+// an earlier op of the batch, and the client sends it again later.
+const (
+	SyncApplied  = "applied"
+	SyncRejected = "rejected"
+	SyncPending  = "pending"
+	// syncRunning marks an op claimed by a request that is applying it.
+	syncRunning = "running"
+)
+
+var (
+	// ErrInvalidSyncBatch indicates an empty or oversized batch, or one
+	// with an op without a valid opId.
+	ErrInvalidSyncBatch = errors.New("invalid sync batch")
+	// ErrInvalidSyncOp indicates an op of an unknown type or without the
+	// IDs its type needs.
+	ErrInvalidSyncOp = errors.New("invalid sync op")
+)
+
+// SyncOpInput is an operation a client queued while offline. OpID is a
+// UUID of the client that makes replaying the op idempotent. A create
+// names the new todo with the UUID ClientID; update and delete name their
+// todo in TodoID by its server ID or by the ClientID it was created with,
+// so they can follow a create of the same batch.
+type SyncOpInput struct {
+	OpID       string  `json:"opId"`
+	Type       string  `json:"type"`
+	ClientID   string  `json:"clientId"`
+	TodoID     string  `json:"todoId"`
+	Title      *string `json:"title"`
+	Completed  *bool   `json:"completed"`
+	Color      *string `json:"color"`
+	Icon       *string `json:"icon"`
+	Assignee   *string `json:"assignee"`
+	Recurrence string  `json:"recurrence"`
+	ListID     string  `json:"listId"`
+}
+
+// SyncOpResult is the outcome of an op. Reason says why it was rejected:
+// invalid_op, duplicate_client_id, not_found, forbidden, invalid_todo,
+// invalid_label, invalid_recurrence or quota_exceeded.
+type SyncOpResult struct {
+	OpID     string `json:"opId"`
+	Status   string `json:"status"`
+	Replayed bool   `json:"replayed"`
+	TodoID   string `json:"todoId,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SyncReport is the outcome of a batch, in the order of its ops. IDs maps
+// the client IDs of the todos the batch created to their server IDs.
+type SyncReport struct {
+	Results []SyncOpResult    `json:"results"`
+	IDs     map[string]string `json:"ids"`
+	// Created counts the todos the batch created, without the replayed
+	// creates.
+	Created int `json:"-"`
+}
+
+// SyncOp is the record of an op of a user, kept to replay it idempotently.
+type SyncOp struct {
+	ID       primitive.ObjectID  `bson:"_id,omitempty"`
+	Email    string              `bson:"email"`
+	OpID     string              `bson:"opId"`
+	Type     string              `bson:"type"`
+	ClientID string              `bson:"clientId,omitempty"`
+	TodoID   *primitive.ObjectID `bson:"todoId,omitempty"`
+	Status   string              `bson:"status"`
+	Reason   string              `bson:"reason,omitempty"`
+	At       time.Time           `bson:"at"`
+}
+
+// SyncOpRepository keeps the ops replayed by each user.
+type SyncOpRepository interface {
+	// Claim records op as running and reports true, unless the user
+	// already has it: then it returns the existing record and false. A
+	// running record older than stale is claimed again.
+	Claim(ctx context.Context, op SyncOp, stale time.Time) (SyncOp, bool, error)
+	// Finish stores the outcome of a claimed op.
+	Finish(ctx context.Context, op SyncOp) error
+	// Release forgets a claimed op, so it can be tried again.
+	Release(ctx context.Context, email, opID string) error
+}
+
+// MongoSyncOpRepository implements SyncOpRepository backed by MongoDB.
+type MongoSyncOpRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSyncOpRepository creates a repository over collection.
+func NewMongoSyncOpRepository(collection *mongo.Collection) *MongoSyncOpRepository {
+	return &MongoSyncOpRepository{collection: collection}
+}
+
+// EnsureIndexes creates the unique op index and a TTL index that forgets
+// the ops after SyncOpRetention.
+func (m *MongoSyncOpRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "opId", Value: 1}}, Options: options.Index().SetUnique(true).SetName("sync_op_unique")},
+		{Keys: bson.D{{Key: "at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(SyncOpRetention / time.Second))},
+	})
+	return err
+}
+
+// Claim implements SyncOpRepository.
+func (m *MongoSyncOpRepository) Claim(ctx context.Context, op SyncOp, stale time.Time) (SyncOp, bool, error) {
+	_, err := m.collection.InsertOne(ctx, op)
+	if err == nil {
+		return op, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return SyncOp{}, false, err
+	}
+	filter := bson.M{"email": op.Email, "opId": op.OpID}
+	var existing SyncOp
+	err = m.collection.FindOneAndUpdate(ctx,
+		bson.M{"email": op.Email, "opId": op.OpID, "status": syncRunning, "at": bson.M{"$lt": stale}},
+		bson.M{"$set": bson.M{"at": op.At}},
+	).Decode(&existing)
+	if err == nil {
+		existing.At = op.At
+		return existing, true, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return SyncOp{}, false, err
+	}
+	if err := m.collection.FindOne(ctx, filter).Decode(&existing); err != nil {
+		return SyncOp{}, false, err
+	}
+	return existing, false, nil
+}
+
+// Finish implements SyncOpRepository.
+func (m *MongoSyncOpRepository) Finish(ctx context.Context, op SyncOp) error {
+	set := bson.M{"status": op.Status, "at": op.At}
+	if op.TodoID != nil {
+		set["todoId"] = op.TodoID
+	}
+	if op.Reason != "" {
+		set["reason"] = op.Reason
+	}
+	_, err := m.collection.UpdateOne(ctx, bson.M{"email": op.Email, "opId": op.OpID}, bson.M{"$set": set})
+	return err
+}
+
+// Release implements SyncOpRepository.
+func (m *MongoSyncOpRepository) Release(ctx context.Context, email, opID string) error {
+	_, err := m.collection.DeleteOne(ctx, bson.M{"email": email, "opId": opID, "status": syncRunning})
+	return err
+}
+
+// TodoSyncService replays the operations offline clients queued on their
+// todos. The ops of a batch apply one at a time in their order, and an op
+// is never applied before the ones queued ahead of it: after a server
+// error the rest of the batch is left pending.
+type TodoSyncService struct {
+	ops    SyncOpRepository
+	todos  *TodoService
+	lists  *ListService
+	quotas *QuotaService
+	now    func() time.Time
+	ids    IDGenerator
+}
+
+// NewTodoSyncService builds a new TodoSyncService instance.
+func NewTodoSyncService(ops SyncOpRepository, todos *TodoService, lists *ListService, quotas *QuotaService, now func() time.Time, ids IDGenerator) *TodoSyncService {
+	return &TodoSyncService{ops: ops, todos: todos, lists: lists, quotas: quotas, now: now, ids: ids}
+}
+
+// Replay applies the ops of email in order. An op replayed from an earlier
+// batch is not applied again: its result is the one it had then.
+func (s *TodoSyncService) Replay(ctx context.Context, email string, ops []SyncOpInput) (SyncReport, error) {
+	email = NormalizeEmail(email)
+	if email == "" || len(ops) == 0 || len(ops) > MaxSyncOps {
+		return SyncReport{}, ErrInvalidSyncBatch
+	}
+	for i, op := range ops {
+		if !IsUUID(op.OpID) {
+			return SyncReport{}, ErrInvalidSyncBatch
+		}
+		// UUIDs compare in lowercase, whatever case the client sent.
+		ops[i].OpID, ops[i].ClientID = strings.ToLower(op.OpID), strings.ToLower(op.ClientID)
+		if IsUUID(op.TodoID) {
+			ops[i].TodoID = strings.ToLower(op.TodoID)
+		}
+	}
+
+	report := SyncReport{Results: make([]SyncOpResult, 0, len(ops)), IDs: map[string]string{}}
+	created := map[string]primitive.ObjectID{}
+	for i, input := range ops {
+		result, err := s.replay(ctx, email, input, created)
+		if err != nil {
+			for _, rest := range ops[i:] {
+				report.Results = append(report.Results, SyncOpResult{OpID: rest.OpID, Status: SyncPending})
+			}
+			break
+		}
+		if input.Type == SyncCreate && result.Status == SyncApplied {
+			report.IDs[input.ClientID] = result.TodoID
+			if !result.Replayed {
+				report.Created++
+			}
+		}
+		report.Results = append(report.Results, result)
+		if result.Status == SyncPending {
+			// Another request is applying the op; the ones behind it wait.
+			for _, rest := range ops[i+1:] {
+				report.Results = append(report.Results, SyncOpResult{OpID: rest.OpID, Status: SyncPending})
+			}
+			break
+		}
+	}
+	return report, nil
+}
+
+// replay applies one op, unless it was already, and returns an error only
+// when the op must be retried.
+func (s *TodoSyncService) replay(ctx context.Context, email string, input SyncOpInput, created map[string]primitive.ObjectID) (SyncOpResult, error) {
+	now := s.now()
+	record, claimed, err := s.ops.Claim(ctx, SyncOp{
+		ID:       s.ids.NewID(),
+		Email:    email,
+		OpID:     input.OpID,
+		Type:     input.Type,
+		ClientID: input.ClientID,
+		Status:   syncRunning,
+		At:       now,
+	}, now.Add(-syncClaimTimeout))
+	if err != nil {
+		return SyncOpResult{}, err
+	}
+	if !claimed {
+		result := record.result()
+		if record.Status == syncRunning {
+			return SyncOpResult{OpID: input.OpID, Status: SyncPending}, nil
+		}
+		result.Replayed = true
+		if record.Type == SyncCreate && record.Status == SyncApplied {
+			created[record.ClientID] = *record.TodoID
+		}
+		return result, nil
+	}
+
+	todoID, err := s.apply(ctx, email, input, created)
+	if reason := syncReason(err); reason != "" {
+		record.Status, record.Reason = SyncRejected, reason
+	} else if err != nil {
+		if releaseErr := s.ops.Release(context.WithoutCancel(ctx), email, input.OpID); releaseErr != nil {
+			return SyncOpResult{}, errors.Join(err, releaseErr)
+		}
+		return SyncOpResult{}, err
+	} else {
+		record.Status, record.TodoID = SyncApplied, &todoID
+		if input.Type == SyncCreate {
+			created[input.ClientID] = todoID
+		}
+	}
+	record.At = s.now()
+	// The op already changed the todo; a failure here only costs its
+	// idempotency, and the claim times out.
+	if err := s.ops.Finish(context.WithoutCancel(ctx), record); err != nil {
+		return SyncOpResult{}, err
+	}
+	return record.result(), nil
+}
+
+// apply runs the op with the same checks as the matching endpoint and
+// returns the ID of its todo.
+func (s *TodoSyncService) apply(ctx context.Context, email string, input SyncOpInput, created map[string]primitive.ObjectID) (primitive.ObjectID, error) {
+	switch input.Type {
+	case SyncCreate:
+		if !IsUUID(input.ClientID) || input.TodoID != "" {
+			return primitive.NilObjectID, ErrInvalidSyncOp
+		}
+		var listID *primitive.ObjectID
+		if input.ListID != "" {
+			id, err := primitive.ObjectIDFromHex(input.ListID)
+			if err != nil {
+				return primitive.NilObjectID, ErrInvalidSyncOp
+			}
+			if _, err := s.lists.Authorize(ctx, id, email, policy.Write); err != nil {
+				return primitive.NilObjectID, err
+			}
+			listID = &id
+		}
+		if err := s.quotas.AllowTodo(ctx, email); err != nil {
+			return primitive.NilObjectID, err
+		}
+		var title string
+		if input.Title != nil {
+			title = *input.Title
+		}
+		label := TodoLabel{}
+		if input.Color != nil {
+			label.Color = *input.Color
+		}
+		if input.Icon != nil {
+			label.Icon = *input.Icon
+		}
+		todo, err := s.todos.CreateForClient(ctx, email, input.ClientID, title, input.Recurrence, label, listID)
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		id, _ := primitive.ObjectIDFromHex(todo.ID)
+		return id, nil
+
+	case SyncUpdate, SyncDelete:
+		id, err := s.resolve(ctx, email, input.TodoID, created)
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		if err := s.authorize(ctx, email, id); err != nil {
+			return primitive.NilObjectID, err
+		}
+		if input.Type == SyncDelete {
+			return id, s.todos.Delete(ctx, id)
+		}
+		_, err = s.todos.Update(ctx, id, TodoUpdate{
+			Title:     input.Title,
+			Completed: input.Completed,
+			Color:     input.Color,
+			Icon:      input.Icon,
+			Assignee:  input.Assignee,
+		})
+		return id, err
+	}
+	return primitive.NilObjectID, ErrInvalidSyncOp
+}
+
+// resolve returns the server ID of the todo ref names: a server ID, or the
+// client ID of a todo of the user.
+func (s *TodoSyncService) resolve(ctx context.Context, email, ref string, created map[string]primitive.ObjectID) (primitive.ObjectID, error) {
+	if id, err := primitive.ObjectIDFromHex(ref); err == nil {
+		return id, nil
+	}
+	if !IsUUID(ref) {
+		return primitive.NilObjectID, ErrInvalidSyncOp
+	}
+	if id, ok := created[ref]; ok {
+		return id, nil
+	}
+	todo, err := s.todos.FindByClientID(ctx, email, ref)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return todo.ID, nil
+}
+
+// authorize checks that email may change the todo: as a member of its list
+// who may write, or as its owner or assignee when it is not in a list.
+func (s *TodoSyncService) authorize(ctx context.Context, email string, id primitive.ObjectID) error {
+	todo, err := s.todos.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if todo.ListID != nil {
+		_, err := s.lists.Authorize(ctx, *todo.ListID, email, policy.Write)
+		return err
+	}
+	if todo.Email != email && todo.Assignee != email {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// result converts the record to the outcome of its op.
+func (op SyncOp) result() SyncOpResult {
+	result := SyncOpResult{OpID: op.OpID, Status: op.Status, Reason: op.Reason}
+	if op.TodoID != nil {
+		result.TodoID = op.TodoID.Hex()
+	}
+	return result
+}
+
+// syncReason returns why err rejects an op for good, or "" for success
+// and for the errors worth retrying.
+func syncReason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrInvalidSyncOp):
+		return "invalid_op"
+	case errors.Is(err, ErrDuplicateClientID):
+		return "duplicate_client_id"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrListForbidden):
+		return "forbidden"
+	case errors.Is(err, ErrInvalidTodoInput):
+		return "invalid_todo"
+	case errors.Is(err, ErrInvalidTodoLabel):
+		return "invalid_label"
+	case errors.Is(err, ErrInvalidRecurrence):
+		return "invalid_recurrence"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	}
+	return ""
+}
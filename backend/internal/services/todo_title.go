@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/collab"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+)
+
+// TitleEditRetention is how long the applied title edits are kept; an edit
+// written against an older version can no longer be merged.
+const TitleEditRetention = 24 * time.Hour
+
+// titleEditAttempts bounds the retries of an edit that keeps losing the
+// race against other edits of the same title.
+const titleEditAttempts = 5
+
+// TitleEdited is the type of the realtime messages with the title edits.
+const TitleEdited = "todo.title_edited"
+
+var (
+	// ErrInvalidTitleEdit indicates an op that does not fit the title at
+	// its base version, a base version from the future or an edit that
+	// leaves the title blank.
+	ErrInvalidTitleEdit = errors.New("invalid title edit")
+	// ErrTitleVersionConflict is returned when the title changed since the
+	// version an update was written against.
+	ErrTitleVersionConflict = errors.New("title version conflict")
+	// ErrTitleHistoryGone is returned for edits written against a version
+	// whose following edits are no longer kept, e.g. because the title was
+	// replaced with PUT /todos/:id; the client reloads the title.
+	ErrTitleHistoryGone = errors.New("title history gone")
+)
+
+// TitleEdit is an edit applied to the title of a todo, which took it to
+// Version.
+type TitleEdit struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty"`
+	TodoID  primitive.ObjectID `bson:"todoId"`
+	Version int                `bson:"version"`
+	Op      collab.Op          `bson:"op"`
+	Author  string             `bson:"author"`
+	At      time.Time          `bson:"at"`
+}
+
+// TitleState is the title of a todo at a version.
+type TitleState struct {
+	TodoID  string `json:"todoId"`
+	Title   string `json:"title"`
+	Version int    `json:"version"`
+}
+
+// TitleEditMessage is the realtime message of an applied edit: Op takes the
+// title from Version-1 to Version.
+type TitleEditMessage struct {
+	TodoID  string    `json:"todoId"`
+	Version int       `json:"version"`
+	Op      collab.Op `json:"op"`
+	Title   string    `json:"title"`
+	Author  string    `json:"author"`
+}
+
+// TitleEditRepository keeps the recent edits of the titles.
+type TitleEditRepository interface {
+	Append(ctx context.Context, edit TitleEdit) error
+	// Since returns the edits of the todo after version, oldest first.
+	Since(ctx context.Context, todoID primitive.ObjectID, version int) ([]TitleEdit, error)
+}
+
+// MongoTitleEditRepository implements TitleEditRepository backed by
+// MongoDB.
+type MongoTitleEditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoTitleEditRepository creates a repository over collection.
+func NewMongoTitleEditRepository(collection *mongo.Collection) *MongoTitleEditRepository {
+	return &MongoTitleEditRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index of the edits of each todo, unique per
+// version, and a TTL index that drops them after TitleEditRetention.
+func (m *MongoTitleEditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetUnique(true).SetName("edit_version_unique")},
+		{Keys: bson.D{{Key: "at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(TitleEditRetention / time.Second))},
+	})
+	return err
+}
+
+// Append implements TitleEditRepository.
+func (m *MongoTitleEditRepository) Append(ctx context.Context, edit TitleEdit) error {
+	_, err := m.collection.InsertOne(ctx, edit)
+	return err
+}
+
+// Since implements TitleEditRepository.
+func (m *MongoTitleEditRepository) Since(ctx context.Context, todoID primitive.ObjectID, version int) ([]TitleEdit, error) {
+	cursor, err := m.collection.Find(ctx,
+		bson.M{"todoId": todoID, "version": bson.M{"$gt": version}},
+		options.Find().SetSort(bson.D{{Key: "version", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	edits := []TitleEdit{}
+	if err := cursor.All(ctx, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// SetTitleEdits enables the collaborative editing of the titles, keeping
+// the edits in edits and streaming them through hub.
+func (s *TodoService) SetTitleEdits(edits TitleEditRepository, hub *realtime.Hub) {
+	s.titleEdits = edits
+	s.titleHub = hub
+}
+
+// Title returns the title of a todo and its version, to start editing it.
+func (s *TodoService) Title(ctx context.Context, id primitive.ObjectID) (TitleState, error) {
+	todo, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return TitleState{}, err
+	}
+	return TitleState{TodoID: todo.ID.Hex(), Title: todo.Title, Version: todo.TitleVersion}, nil
+}
+
+// EditTitle applies op, written by author against the title at version
+// base, and returns the new title. The edits applied since base are merged
+// in, so concurrent edits of several users all survive; the applied edit
+// is streamed to whoever follows the todo. Unlike PUT /todos/:id, the
+// title is not trimmed, so the positions of the clients stay valid, and
+// the link previews are left as they are.
+func (s *TodoService) EditTitle(ctx context.Context, id primitive.ObjectID, author string, base int, op collab.Op) (TitleState, error) {
+	if err := op.Validate(); err != nil {
+		return TitleState{}, ErrInvalidTitleEdit
+	}
+	return s.editTitle(ctx, id, author, func(Todo) (int, collab.Op) { return base, op })
+}
+
+// ReplaceTitle is the last-writer-wins edit: it replaces the whole title,
+// whatever its version, for clients that do not merge edits. It is still
+// recorded as an edit, so the other clients merge it.
+func (s *TodoService) ReplaceTitle(ctx context.Context, id primitive.ObjectID, author, title string) (TitleState, error) {
+	return s.editTitle(ctx, id, author, func(todo Todo) (int, collab.Op) {
+		return todo.TitleVersion, collab.Replace(todo.Title, title)
+	})
+}
+
+// editTitle applies the op edit returns for the current todo, retrying
+// when another edit wins the race for the next version.
+func (s *TodoService) editTitle(ctx context.Context, id primitive.ObjectID, author string, edit func(Todo) (int, collab.Op)) (TitleState, error) {
+	if s.titleEdits == nil {
+		return TitleState{}, ErrInvalidTitleEdit
+	}
+	author = NormalizeEmail(author)
+	for attempt := 1; ; attempt++ {
+		todo, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return TitleState{}, err
+		}
+		base, op := edit(todo)
+		op, err = s.rebase(ctx, todo, base, op)
+		if err != nil {
+			return TitleState{}, err
+		}
+		title, err := op.Apply(todo.Title)
+		if err != nil || strings.TrimSpace(title) == "" {
+			return TitleState{}, ErrInvalidTitleEdit
+		}
+
+		updated, err := s.repo.Update(ctx, id, TodoUpdate{Title: &title, TitleVersion: &todo.TitleVersion})
+		if errors.Is(err, ErrTitleVersionConflict) && attempt < titleEditAttempts {
+			continue
+		}
+		if err != nil {
+			return TitleState{}, err
+		}
+		applied := TitleEdit{ID: s.ids.NewID(), TodoID: id, Version: updated.TitleVersion, Op: op, Author: author, At: s.now()}
+		if err := s.titleEdits.Append(ctx, applied); err != nil {
+			// The title changed anyway; the clients behind it will reload.
+			log.Printf("no se pudo guardar la edicion %d del titulo de la tarea %s: %v", applied.Version, id.Hex(), err)
+		}
+		s.streamTitleEdit(ctx, updated, applied)
+		return TitleState{TodoID: id.Hex(), Title: updated.Title, Version: updated.TitleVersion}, nil
+	}
+}
+
+// rebase transforms op, written against version base of the title of
+// todo, over the edits applied since.
+func (s *TodoService) rebase(ctx context.Context, todo Todo, base int, op collab.Op) (collab.Op, error) {
+	if base < 0 || base > todo.TitleVersion {
+		return nil, ErrInvalidTitleEdit
+	}
+	if base == todo.TitleVersion {
+		return op, nil
+	}
+	edits, err := s.titleEdits.Since(ctx, todo.ID, base)
+	if err != nil {
+		return nil, err
+	}
+	version := base
+	for _, edit := range edits {
+		if edit.Version > todo.TitleVersion {
+			break
+		}
+		if edit.Version != version+1 {
+			return nil, ErrTitleHistoryGone
+		}
+		if op, _, err = collab.Transform(op, edit.Op); err != nil {
+			return nil, ErrInvalidTitleEdit
+		}
+		version = edit.Version
+	}
+	if version != todo.TitleVersion {
+		return nil, ErrTitleHistoryGone
+	}
+	return op, nil
+}
+
+// streamTitleEdit publishes edit to the members viewing the list of todo,
+// or to its owner and assignee when it is not in a list.
+func (s *TodoService) streamTitleEdit(ctx context.Context, todo Todo, edit TitleEdit) {
+	if s.titleHub == nil {
+		return
+	}
+	channels := []string{realtime.UserChannel(todo.Email)}
+	if todo.Assignee != "" && todo.Assignee != todo.Email {
+		channels = append(channels, realtime.UserChannel(todo.Assignee))
+	}
+	if todo.ListID != nil {
+		channels = []string{realtime.ListChannel(todo.ListID.Hex())}
+	}
+	data := TitleEditMessage{TodoID: todo.ID.Hex(), Version: edit.Version, Op: edit.Op, Title: todo.Title, Author: edit.Author}
+	for _, channel := range channels {
+		msg, err := realtime.NewMessage(channel, TitleEdited, data, edit.At)
+		if err == nil {
+			err = s.titleHub.Publish(ctx, msg)
+		}
+		if err != nil {
+			log.Printf("no se pudo enviar la edicion del titulo de la tarea %s en tiempo real: %v", todo.ID.Hex(), err)
+		}
+	}
+}
@@ -3,9 +3,15 @@ package services
 import (
 	"context"
 	"errors"
+	"regexp"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
 )
 
 var (
@@ -17,24 +23,121 @@ var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 	// ErrInvalidCredentials is returned when the email/password combination is wrong.
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrInvalidRole indicates a role other than the staff roles.
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrAccountSuspended is returned when a suspended account signs in or
+	// is impersonated.
+	ErrAccountSuspended = errors.New("account suspended")
+)
+
+// Staff roles. Users without a role are regular accounts.
+const (
+	RoleManager      = "manager"
+	RoleFrontDesk    = "front_desk"
+	RoleHousekeeping = "housekeeping"
 )
 
+var staffRoles = map[string]bool{
+	RoleManager:      true,
+	RoleFrontDesk:    true,
+	RoleHousekeeping: true,
+}
+
 // UserRepository is the storage contract required by the user service.
 type UserRepository interface {
 	FindByEmail(ctx context.Context, email string) (User, error)
 	Insert(ctx context.Context, user User) error
 	List(ctx context.Context) ([]User, error)
 	Clear(ctx context.Context) error
+	// SetRole changes the role of a user and returns it, or ErrNotFound.
+	SetRole(ctx context.Context, email, role string) (User, error)
+	// SetProperty changes the property of a user (nil removes it) and
+	// returns it, or ErrNotFound.
+	SetProperty(ctx context.Context, email string, propertyID *primitive.ObjectID) (User, error)
+	// SetQuota replaces the quota override of a user (nil removes it) and
+	// returns it, or ErrNotFound.
+	SetQuota(ctx context.Context, email string, quota *QuotaOverride) (User, error)
+	// Search returns the matching users ordered by email, with their todo
+	// activity.
+	Search(ctx context.Context, query UserQuery) ([]UserActivity, error)
+	Count(ctx context.Context, query UserQuery) (int64, error)
+	// SetSuspended suspends a user since at (nil lifts the suspension) and
+	// returns it, or ErrNotFound.
+	SetSuspended(ctx context.Context, email string, at *time.Time) (User, error)
+	// SetFeedToken replaces the feed token of a user (nil removes it) and
+	// returns it, or ErrNotFound.
+	SetFeedToken(ctx context.Context, email string, token *UserFeedToken) (User, error)
+	// FindByFeedToken returns the user with the token hash, or ErrNotFound.
+	FindByFeedToken(ctx context.Context, tokenHash string) (User, error)
+	// SetEmailChange replaces the pending email change of a user (nil
+	// removes it) and returns it, or ErrNotFound.
+	SetEmailChange(ctx context.Context, email string, change *PendingEmailChange) (User, error)
+	// FindByEmailChangeToken returns the user whose pending email change
+	// has the confirmation or cancellation token hash, or ErrNotFound.
+	FindByEmailChangeToken(ctx context.Context, tokenHash string) (User, error)
+}
+
+// UserQuery selects the users returned by an admin search.
+type UserQuery struct {
+	// Search matches part of the email, ignoring case; empty matches all.
+	Search string
+	// Email matches the whole email.
+	Email string
+	// PropertyID keeps the staff of a property; Suspended the suspended
+	// (true) or active (false) users.
+	PropertyID *primitive.ObjectID
+	Suspended  *bool
+	// Offset skips that many users; Limit caps the result (zero means all).
+	Offset int
+	Limit  int
+}
+
+// UserActivity is a user with the number of todos it has outside the trash
+// and the last time it created, completed or trashed one.
+type UserActivity struct {
+	User           `bson:",inline"`
+	TodoCount      int64      `bson:"todoCount"`
+	LastActivityAt *time.Time `bson:"lastActivityAt,omitempty"`
+}
+
+// UserListItem is a user of the admin listing.
+type UserListItem struct {
+	PublicUser
+	TodoCount      int64      `json:"todoCount" xml:"todoCount"`
+	LastActivityAt *time.Time `json:"lastActivityAt,omitempty" xml:"lastActivityAt,omitempty"`
+}
+
+// UserPage is one slice of a user search plus the total matching count.
+type UserPage struct {
+	Users []UserListItem
+	Total int64
 }
 
 // MongoUserRepository implements UserRepository backed by MongoDB.
 type MongoUserRepository struct {
 	collection *mongo.Collection
+	todos      *mongo.Collection
 }
 
-// NewMongoUserRepository creates a new repository wrapper around a Mongo collection.
-func NewMongoUserRepository(collection *mongo.Collection) *MongoUserRepository {
-	return &MongoUserRepository{collection: collection}
+// NewMongoUserRepository creates a new repository wrapper around the users
+// collection; todos is read by Search to add the activity of each user and
+// updated by Insert to hand the user the todos of its email.
+func NewMongoUserRepository(collection, todos *mongo.Collection) *MongoUserRepository {
+	return &MongoUserRepository{collection: collection, todos: todos}
+}
+
+// EnsureIndexes creates the indexes used to list the staff of a property,
+// to count the signups per day and to find the owner of a feed token or
+// of an email change link.
+func (m *MongoUserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "propertyId", Value: 1}, {Key: "role", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "feedToken.tokenHash", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true).SetName("feed_token_unique")},
+		{Keys: bson.D{{Key: "emailChange.tokenHash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "emailChange.cancelHash", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
 }
 
 // FindByEmail retrieves a user by email or returns ErrNotFound.
@@ -47,9 +150,18 @@ func (m *MongoUserRepository) FindByEmail(ctx context.Context, email string) (Us
 	return user, err
 }
 
-// Insert stores the provided user in MongoDB.
+// Insert stores the provided user in MongoDB, with a new ID unless it has
+// one.
 func (m *MongoUserRepository) Insert(ctx context.Context, user User) error {
-	_, err := m.collection.InsertOne(ctx, user)
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if _, err := m.collection.InsertOne(ctx, user); err != nil {
+		return err
+	}
+	// The todos created for the email before it had an account become the
+	// user's.
+	_, err := m.todos.UpdateMany(ctx, bson.M{"email": user.Email, "userId": nil}, bson.M{"$set": bson.M{"userId": user.ID}})
 	return err
 }
 
@@ -74,20 +186,236 @@ func (m *MongoUserRepository) Clear(ctx context.Context) error {
 	return err
 }
 
+// SetRole updates the role of a user; an empty role removes it.
+func (m *MongoUserRepository) SetRole(ctx context.Context, email, role string) (User, error) {
+	update := bson.M{"$set": bson.M{"role": role}}
+	if role == "" {
+		update = bson.M{"$unset": bson.M{"role": ""}}
+	}
+
+	var user User
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// SetProperty updates the property a user belongs to; nil removes it.
+func (m *MongoUserRepository) SetProperty(ctx context.Context, email string, propertyID *primitive.ObjectID) (User, error) {
+	update := bson.M{"$set": bson.M{"propertyId": propertyID}}
+	if propertyID == nil {
+		update = bson.M{"$unset": bson.M{"propertyId": ""}}
+	}
+
+	var user User
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// SetQuota updates the quota override of a user; nil removes it.
+func (m *MongoUserRepository) SetQuota(ctx context.Context, email string, quota *QuotaOverride) (User, error) {
+	update := bson.M{"$set": bson.M{"quota": quota}}
+	if quota == nil {
+		update = bson.M{"$unset": bson.M{"quota": ""}}
+	}
+
+	var user User
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+func userFilter(query UserQuery) bson.M {
+	filter := bson.M{}
+	if query.Search != "" {
+		filter["email"] = primitive.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+	}
+	if query.Email != "" {
+		filter["email"] = query.Email
+	}
+	if query.PropertyID != nil {
+		filter["propertyId"] = *query.PropertyID
+	}
+	if query.Suspended != nil {
+		filter["suspendedAt"] = bson.M{"$ne": nil}
+		if !*query.Suspended {
+			filter["suspendedAt"] = nil
+		}
+	}
+	return filter
+}
+
+// Search implements UserRepository. The page is cut before the $lookup,
+// so only the todos of the users returned are grouped, using the user
+// index of the todos.
+func (m *MongoUserRepository) Search(ctx context.Context, query UserQuery) ([]UserActivity, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: userFilter(query)}},
+		{{Key: "$sort", Value: bson.M{"email": 1}}},
+	}
+	if query.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: query.Offset}})
+	}
+	if query.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: query.Limit}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from": m.todos.Name(),
+			// let + $expr rather than localField/foreignField next to a
+			// pipeline, which needs MongoDB 5.0.
+			"let": bson.M{"userId": "$_id"},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$userId", "$$userId"}}}},
+				bson.M{"$group": bson.M{
+					"_id": nil,
+					"todoCount": bson.M{"$sum": bson.M{"$cond": bson.A{
+						bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$deletedAt", nil}}, nil}}, 1, 0,
+					}}},
+					"lastActivityAt": bson.M{"$max": bson.M{"$max": bson.A{"$createdAt", "$completedAt", "$deletedAt"}}},
+				}},
+			},
+			"as": "activity",
+		}}},
+		bson.D{{Key: "$set", Value: bson.M{
+			"todoCount":      bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$activity.todoCount", 0}}, 0}},
+			"lastActivityAt": bson.M{"$arrayElemAt": bson.A{"$activity.lastActivityAt", 0}},
+		}}},
+		bson.D{{Key: "$unset", Value: "activity"}},
+	)
+
+	cursor, err := m.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []UserActivity
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Count implements UserRepository.
+func (m *MongoUserRepository) Count(ctx context.Context, query UserQuery) (int64, error) {
+	return m.collection.CountDocuments(ctx, userFilter(query))
+}
+
+// SetSuspended updates the suspension of a user; nil lifts it.
+func (m *MongoUserRepository) SetSuspended(ctx context.Context, email string, at *time.Time) (User, error) {
+	update := bson.M{"$set": bson.M{"suspendedAt": at}}
+	if at == nil {
+		update = bson.M{"$unset": bson.M{"suspendedAt": ""}}
+	}
+
+	var user User
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// SetFeedToken implements UserRepository.
+func (m *MongoUserRepository) SetFeedToken(ctx context.Context, email string, token *UserFeedToken) (User, error) {
+	update := bson.M{"$set": bson.M{"feedToken": token}}
+	if token == nil {
+		update = bson.M{"$unset": bson.M{"feedToken": ""}}
+	}
+
+	var user User
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// FindByFeedToken implements UserRepository.
+func (m *MongoUserRepository) FindByFeedToken(ctx context.Context, tokenHash string) (User, error) {
+	var user User
+	err := m.collection.FindOne(ctx, bson.M{"feedToken.tokenHash": tokenHash}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// SetEmailChange implements UserRepository.
+func (m *MongoUserRepository) SetEmailChange(ctx context.Context, email string, change *PendingEmailChange) (User, error) {
+	update := bson.M{"$set": bson.M{"emailChange": change}}
+	if change == nil {
+		update = bson.M{"$unset": bson.M{"emailChange": ""}}
+	}
+
+	var user User
+	err := m.collection.FindOneAndUpdate(ctx, bson.M{"email": email}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// FindByEmailChangeToken implements UserRepository.
+func (m *MongoUserRepository) FindByEmailChangeToken(ctx context.Context, tokenHash string) (User, error) {
+	var user User
+	err := m.collection.FindOne(ctx, bson.M{"$or": bson.A{
+		bson.M{"emailChange.tokenHash": tokenHash},
+		bson.M{"emailChange.cancelHash": tokenHash},
+	}}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, ErrNotFound
+	}
+	return user, err
+}
+
 // UserService encapsulates business logic for user operations.
 type UserService struct {
-	repo UserRepository
+	repo   UserRepository
+	reads  UserRepository
+	outbox Outbox
+	now    func() time.Time
 }
 
-// NewUserService builds a new UserService instance.
-func NewUserService(repo UserRepository) *UserService {
-	return &UserService{repo: repo}
+// NewUserService builds a new UserService instance; outbox stores the
+// user.registered events.
+func NewUserService(repo UserRepository, outbox Outbox, now func() time.Time) *UserService {
+	if now == nil {
+		now = time.Now
+	}
+	return &UserService{repo: repo, reads: repo, outbox: outbox, now: now}
+}
+
+// SetListRepository makes List and Search read from reads, typically the
+// same collection routed to secondaries, so the admin listings may lag the
+// writes by up to the staleness the read preference tolerates. Sign-up and
+// login keep reading from the primary.
+func (s *UserService) SetListRepository(reads UserRepository) {
+	s.reads = reads
 }
 
 // Register validates and stores a user; returns high-level domain errors.
 func (s *UserService) Register(ctx context.Context, user User) error {
 	user.Email = NormalizeEmail(user.Email)
 	user.Password = NormalizeText(user.Password)
+	user.Role = ""
+	user.PropertyID = nil
+	user.Quota = nil
+	user.SuspendedAt = nil
+	user.CreatedAt = s.now()
 
 	if user.Email == "" || user.Password == "" {
 		return ErrInvalidUserInput
@@ -101,34 +429,42 @@ func (s *UserService) Register(ctx context.Context, user User) error {
 		return err
 	}
 
-	return s.repo.Insert(ctx, user)
+	return s.outbox.Atomically(ctx, func(ctx context.Context) ([]events.Event, error) {
+		if err := s.repo.Insert(ctx, user); err != nil {
+			return nil, err
+		}
+		return newEvents(events.UserRegistered, user.Email, user.ToPublic(), user.CreatedAt)
+	})
 }
 
-// Login validates the provided credentials.
-func (s *UserService) Login(ctx context.Context, email, password string) error {
+// Login validates the provided credentials and returns the user.
+func (s *UserService) Login(ctx context.Context, email, password string) (User, error) {
 	email = NormalizeEmail(email)
 	password = NormalizeText(password)
 
 	if email == "" || password == "" {
-		return ErrInvalidCredentials
+		return User{}, ErrInvalidCredentials
 	}
 
 	user, err := s.repo.FindByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
-			return ErrInvalidCredentials
+			return User{}, ErrInvalidCredentials
 		}
-		return err
+		return User{}, err
 	}
 	if user.Password != password {
-		return ErrInvalidCredentials
+		return User{}, ErrInvalidCredentials
 	}
-	return nil
+	if user.SuspendedAt != nil {
+		return User{}, ErrAccountSuspended
+	}
+	return user, nil
 }
 
 // List returns all users in their public representation.
 func (s *UserService) List(ctx context.Context) ([]PublicUser, error) {
-	users, err := s.repo.List(ctx)
+	users, err := s.reads.List(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -144,3 +480,72 @@ func (s *UserService) List(ctx context.Context) ([]PublicUser, error) {
 func (s *UserService) Clear(ctx context.Context) error {
 	return s.repo.Clear(ctx)
 }
+
+// SetRole assigns a staff role to a user; an empty role makes it a regular
+// account again.
+func (s *UserService) SetRole(ctx context.Context, email, role string) (PublicUser, error) {
+	role = normalizeKeyword(role)
+	if role != "" && !staffRoles[role] {
+		return PublicUser{}, ErrInvalidRole
+	}
+
+	user, err := s.repo.SetRole(ctx, NormalizeEmail(email), role)
+	if err != nil {
+		return PublicUser{}, err
+	}
+	return user.ToPublic(), nil
+}
+
+// Search returns a page of users whose email contains query.Search.
+func (s *UserService) Search(ctx context.Context, query UserQuery) (UserPage, error) {
+	if query.Offset < 0 || query.Limit < 0 {
+		return UserPage{}, ErrInvalidPagination
+	}
+	query.Search = NormalizeText(query.Search)
+
+	users, err := s.reads.Search(ctx, query)
+	if err != nil {
+		return UserPage{}, err
+	}
+	total := int64(len(users))
+	if query.Limit > 0 {
+		if total, err = s.reads.Count(ctx, query); err != nil {
+			return UserPage{}, err
+		}
+	}
+
+	items := make([]UserListItem, 0, len(users))
+	for _, u := range users {
+		items = append(items, UserListItem{PublicUser: u.ToPublic(), TodoCount: u.TodoCount, LastActivityAt: u.LastActivityAt})
+	}
+	return UserPage{Users: items, Total: total}, nil
+}
+
+// Suspend keeps a user from signing in and closes its open sessions.
+// Suspending it again keeps the original date.
+func (s *UserService) Suspend(ctx context.Context, email string) (PublicUser, error) {
+	email = NormalizeEmail(email)
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return PublicUser{}, err
+	}
+	if user.SuspendedAt != nil {
+		return user.ToPublic(), nil
+	}
+
+	at := s.now()
+	user, err = s.repo.SetSuspended(ctx, email, &at)
+	if err != nil {
+		return PublicUser{}, err
+	}
+	return user.ToPublic(), nil
+}
+
+// Reactivate lifts the suspension of a user.
+func (s *UserService) Reactivate(ctx context.Context, email string) (PublicUser, error) {
+	user, err := s.repo.SetSuspended(ctx, NormalizeEmail(email), nil)
+	if err != nil {
+		return PublicUser{}, err
+	}
+	return user.ToPublic(), nil
+}
@@ -1,13 +1,44 @@
 package services
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
 // NormalizeEmail trims spaces and lowercases an email value.
 func NormalizeEmail(email string) string {
 	return strings.ToLower(strings.TrimSpace(email))
 }
 
+// MailboxKey reduces email to the mailbox it delivers to, so its variants
+// compare equal: the +tag of the local part is dropped and, for Gmail,
+// also the dots and the googlemail.com domain.
+func MailboxKey(email string) string {
+	local, domain, ok := strings.Cut(NormalizeEmail(email), "@")
+	if !ok {
+		return NormalizeEmail(email)
+	}
+	local, _, _ = strings.Cut(local, "+")
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		local, domain = strings.ReplaceAll(local, ".", ""), "gmail.com"
+	}
+	return local + "@" + domain
+}
+
 // NormalizeText trims spaces from free-form text.
 func NormalizeText(value string) string {
 	return strings.TrimSpace(value)
 }
+
+// uuidPattern matches the textual form of a UUID.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsUUID reports whether s is a UUID such as the ones clients generate.
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// normalizeKeyword trims and lowercases enum-like values such as room types.
+func normalizeKeyword(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Validation actions of MONGO_VALIDATION_ACTION: the database rejects the
+// writes that break a schema, only logs them, or the schemas are left as
+// they are.
+const (
+	ValidationError = "error"
+	ValidationWarn  = "warn"
+	ValidationOff   = "off"
+)
+
+// optionalDate is the schema of the dates that are unset with $unset but
+// that older documents may hold as null.
+var optionalDate = bson.M{"bsonType": bson.A{"date", "null"}}
+
+// integer accepts the 32 and 64-bit integers the driver writes a Go int as.
+var integer = bson.A{"int", "long"}
+
+// CollectionSchemas returns the JSON Schema each validated collection is
+// held to; the archived todos share the schema of the todos. They only
+// cover the fields the code relies on and leave the rest open, so a
+// document written by an older version still passes.
+func CollectionSchemas() map[string]bson.M {
+	schemas := map[string]bson.M{
+		"users": {
+			"bsonType": "object",
+			"required": bson.A{"email", "password"},
+			"properties": bson.M{
+				"email":        bson.M{"bsonType": "string", "minLength": 1},
+				"password":     bson.M{"bsonType": "string"},
+				"role":         bson.M{"enum": bson.A{RoleManager, RoleFrontDesk, RoleHousekeeping}},
+				"propertyId":   bson.M{"bsonType": "objectId"},
+				"createdAt":    bson.M{"bsonType": "date"},
+				"suspendedAt":  optionalDate,
+				"anonymizedAt": optionalDate,
+			},
+		},
+		"todos": {
+			"bsonType": "object",
+			"required": bson.A{"email", "title", "completed", "createdAt"},
+			"properties": bson.M{
+				"userId":         bson.M{"bsonType": "objectId"},
+				"email":          bson.M{"bsonType": "string"},
+				"clientId":       bson.M{"bsonType": "string"},
+				"title":          bson.M{"bsonType": "string", "minLength": 1},
+				"completed":      bson.M{"bsonType": "bool"},
+				"createdAt":      bson.M{"bsonType": "date"},
+				"titleVersion":   bson.M{"bsonType": integer, "minimum": 0},
+				"completedAt":    optionalDate,
+				"roomId":         bson.M{"bsonType": "objectId"},
+				"propertyId":     bson.M{"bsonType": "objectId"},
+				"listId":         bson.M{"bsonType": "objectId"},
+				"recurrence":     bson.M{"enum": bson.A{RecurDaily, RecurWeekly, RecurMonthly}},
+				"nextOccurrence": optionalDate,
+				"dueAt":          optionalDate,
+				"color":          bson.M{"enum": stringsArray(TodoColors)},
+				"icon":           bson.M{"enum": stringsArray(TodoIcons)},
+				"reactions":      bson.M{"bsonType": "array"},
+				"assignee":       bson.M{"bsonType": "string"},
+				"approval":       bson.M{"enum": bson.A{ApprovalPending, ApprovalApproved, ApprovalRejected}},
+				"deletedAt":      optionalDate,
+			},
+		},
+		"bookings": {
+			"bsonType": "object",
+			"required": bson.A{"roomId", "email", "guests", "checkIn", "checkOut", "status", "createdAt"},
+			"properties": bson.M{
+				"roomId":       bson.M{"bsonType": "objectId"},
+				"propertyId":   bson.M{"bsonType": "objectId"},
+				"guestId":      bson.M{"bsonType": "objectId"},
+				"email":        bson.M{"bsonType": "string"},
+				"guests":       bson.M{"bsonType": integer, "minimum": 1},
+				"checkIn":      bson.M{"bsonType": "date"},
+				"checkOut":     bson.M{"bsonType": "date"},
+				"status":       bson.M{"enum": bson.A{BookingHeld, BookingBooked, BookingCheckedIn, BookingCheckedOut, BookingCancelled}},
+				"createdAt":    bson.M{"bsonType": "date"},
+				"updatedAt":    bson.M{"bsonType": "date"},
+				"cancelledAt":  optionalDate,
+				"checkedInAt":  optionalDate,
+				"checkedOutAt": optionalDate,
+				"heldUntil":    optionalDate,
+			},
+		},
+	}
+	schemas[TodoArchiveCollection] = schemas["todos"]
+	return schemas
+}
+
+func stringsArray(values []string) bson.A {
+	array := make(bson.A, 0, len(values))
+	for _, value := range values {
+		array = append(array, value)
+	}
+	return array
+}
+
+// ApplySchemas sets the validators of CollectionSchemas on the collections
+// of db, creating the missing ones, with the validation action action.
+// The level is moderate: documents that already break a schema can still
+// be updated, so data written before is not locked, but every insert and
+// every update of a valid document is checked.
+func ApplySchemas(ctx context.Context, db *Database, action string) error {
+	if action == ValidationOff {
+		return nil
+	}
+	if action != ValidationError && action != ValidationWarn {
+		return fmt.Errorf("unknown validation action %q", action)
+	}
+	for name, schema := range CollectionSchemas() {
+		collection := db.Collection(name)
+		validator := bson.M{"$jsonSchema": schema}
+		err := collection.Database().CreateCollection(ctx, collection.Name(), options.CreateCollection().
+			SetValidator(validator).SetValidationLevel("moderate").SetValidationAction(action))
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Code == namespaceExists {
+			err = collection.Database().RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: collection.Name()},
+				{Key: "validator", Value: validator},
+				{Key: "validationLevel", Value: "moderate"},
+				{Key: "validationAction", Value: action},
+			}).Err()
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
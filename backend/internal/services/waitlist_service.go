@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Waitlist entry statuses. An entry moves waiting -> offered when a room is
+// held for it, then offered -> booked when the guest confirms or
+// offered -> expired when the hold runs out. Waiting entries whose arrival
+// date has passed also expire.
+const (
+	WaitlistWaiting = "waiting"
+	WaitlistOffered = "offered"
+	WaitlistBooked  = "booked"
+	WaitlistExpired = "expired"
+)
+
+var waitlistStatuses = map[string]bool{
+	WaitlistWaiting: true,
+	WaitlistOffered: true,
+	WaitlistBooked:  true,
+	WaitlistExpired: true,
+}
+
+var (
+	// ErrInvalidWaitlistInput indicates missing or malformed waitlist data.
+	ErrInvalidWaitlistInput = errors.New("invalid waitlist input")
+	// ErrInvalidWaitlistID indicates the entry ID could not be parsed.
+	ErrInvalidWaitlistID = errors.New("invalid waitlist id")
+	// ErrRoomsAvailable is returned when joining the waitlist for a stay that
+	// can be booked right away.
+	ErrRoomsAvailable = errors.New("rooms available for the stay")
+	// ErrWaitlistStateConflict is returned when the entry is not in the
+	// status required by the operation.
+	ErrWaitlistStateConflict = errors.New("waitlist state conflict")
+	// ErrWaitlistHoldExpired is returned when confirming after the hold ran out.
+	ErrWaitlistHoldExpired = errors.New("waitlist hold expired")
+)
+
+// WaitlistEntry is a guest waiting for a room on a fully booked stay.
+type WaitlistEntry struct {
+	ID         primitive.ObjectID  `bson:"_id,omitempty"`
+	PropertyID *primitive.ObjectID `bson:"propertyId,omitempty"`
+	Email      string              `bson:"email"`
+	Guests     int                 `bson:"guests"`
+	// RoomType optionally restricts the rooms that may be offered.
+	RoomType  string              `bson:"roomType,omitempty"`
+	CheckIn   time.Time           `bson:"checkIn"`
+	CheckOut  time.Time           `bson:"checkOut"`
+	Status    string              `bson:"status"`
+	BookingID *primitive.ObjectID `bson:"bookingId,omitempty"`
+	HoldUntil *time.Time          `bson:"holdUntil,omitempty"`
+	CreatedAt time.Time           `bson:"createdAt"`
+	UpdatedAt time.Time           `bson:"updatedAt"`
+}
+
+// WaitlistEntryResponse is the representation exposed through the API.
+type WaitlistEntryResponse struct {
+	ID         string     `json:"id" xml:"id"`
+	PropertyID string     `json:"propertyId,omitempty" xml:"propertyId,omitempty"`
+	Email      string     `json:"email" xml:"email"`
+	Guests     int        `json:"guests" xml:"guests"`
+	RoomType   string     `json:"roomType,omitempty" xml:"roomType,omitempty"`
+	CheckIn    string     `json:"checkIn" xml:"checkIn"`
+	CheckOut   string     `json:"checkOut" xml:"checkOut"`
+	Status     string     `json:"status" xml:"status"`
+	BookingID  string     `json:"bookingId,omitempty" xml:"bookingId,omitempty"`
+	HoldUntil  *time.Time `json:"holdUntil,omitempty" xml:"holdUntil,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt" xml:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt" xml:"updatedAt"`
+}
+
+// ToResponse converts a WaitlistEntry into an externally safe representation.
+func (e WaitlistEntry) ToResponse() WaitlistEntryResponse {
+	response := WaitlistEntryResponse{
+		ID:        e.ID.Hex(),
+		Email:     e.Email,
+		Guests:    e.Guests,
+		RoomType:  e.RoomType,
+		CheckIn:   e.CheckIn.Format(DateLayout),
+		CheckOut:  e.CheckOut.Format(DateLayout),
+		Status:    e.Status,
+		HoldUntil: e.HoldUntil,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+	}
+	if e.PropertyID != nil {
+		response.PropertyID = e.PropertyID.Hex()
+	}
+	if e.BookingID != nil {
+		response.BookingID = e.BookingID.Hex()
+	}
+	return response
+}
+
+// fits reports whether room can be offered to the entry.
+func (e WaitlistEntry) fits(room Room) bool {
+	return room.Capacity >= e.Guests && (e.RoomType == "" || room.Type == e.RoomType)
+}
+
+// WaitlistInput carries the raw waitlist fields received from clients.
+type WaitlistInput struct {
+	Email    string
+	Guests   int
+	RoomType string
+	CheckIn  string
+	CheckOut string
+}
+
+// WaitlistQuery filters waitlist listings; empty fields match every entry.
+type WaitlistQuery struct {
+	PropertyID primitive.ObjectID
+	Status     string
+	// HoldBefore keeps the entries whose hold ends at or before it.
+	HoldBefore time.Time
+}
+
+// WaitlistUpdate models the fields changed when an entry moves to Status.
+type WaitlistUpdate struct {
+	Status    string
+	BookingID *primitive.ObjectID
+	HoldUntil *time.Time
+	UpdatedAt time.Time
+}
+
+// WaitlistRepository is the storage contract required by the waitlist service.
+type WaitlistRepository interface {
+	// List returns the entries matching query in the order they joined.
+	List(ctx context.Context, query WaitlistQuery) ([]WaitlistEntry, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (WaitlistEntry, error)
+	Create(ctx context.Context, entry WaitlistEntry) (WaitlistEntry, error)
+	// Transition applies update when the entry is still in status from and
+	// returns ErrWaitlistStateConflict otherwise.
+	Transition(ctx context.Context, id primitive.ObjectID, from string, update WaitlistUpdate) (WaitlistEntry, error)
+}
+
+// MongoWaitlistRepository implements WaitlistRepository backed by MongoDB.
+type MongoWaitlistRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoWaitlistRepository creates a new repository wrapper around a Mongo collection.
+func NewMongoWaitlistRepository(collection *mongo.Collection) *MongoWaitlistRepository {
+	return &MongoWaitlistRepository{collection: collection}
+}
+
+// EnsureIndexes creates the index used to walk the entries of a status in
+// the order they joined.
+func (m *MongoWaitlistRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+// List returns entries matching query ordered by creation.
+func (m *MongoWaitlistRepository) List(ctx context.Context, query WaitlistQuery) ([]WaitlistEntry, error) {
+	filter := bson.M{}
+	if !query.PropertyID.IsZero() {
+		filter["propertyId"] = query.PropertyID
+	}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	if !query.HoldBefore.IsZero() {
+		filter["holdUntil"] = bson.M{"$lte": query.HoldBefore}
+	}
+
+	cursor, err := m.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []WaitlistEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindByID retrieves an entry or returns ErrNotFound.
+func (m *MongoWaitlistRepository) FindByID(ctx context.Context, id primitive.ObjectID) (WaitlistEntry, error) {
+	var entry WaitlistEntry
+	err := m.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return WaitlistEntry{}, ErrNotFound
+	}
+	return entry, err
+}
+
+// Create stores an entry and returns it with the generated ID.
+func (m *MongoWaitlistRepository) Create(ctx context.Context, entry WaitlistEntry) (WaitlistEntry, error) {
+	res, err := m.collection.InsertOne(ctx, entry)
+	if err != nil {
+		return WaitlistEntry{}, err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		entry.ID = oid
+	}
+	return entry, nil
+}
+
+// Transition moves an entry from status from to update.Status.
+func (m *MongoWaitlistRepository) Transition(ctx context.Context, id primitive.ObjectID, from string, update WaitlistUpdate) (WaitlistEntry, error) {
+	set := bson.M{"status": update.Status, "updatedAt": update.UpdatedAt}
+	if update.BookingID != nil {
+		set["bookingId"] = update.BookingID
+	}
+	if update.HoldUntil != nil {
+		set["holdUntil"] = update.HoldUntil
+	}
+
+	var entry WaitlistEntry
+	err := m.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": from},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&entry)
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return entry, err
+	}
+
+	if _, err := m.FindByID(ctx, id); err != nil {
+		return WaitlistEntry{}, err
+	}
+	return WaitlistEntry{}, ErrWaitlistStateConflict
+}
+
+// WaitlistNotifier tells a guest that a room is held for them.
+type WaitlistNotifier interface {
+	NotifyWaitlist(ctx context.Context, entry WaitlistEntry, hold Booking) error
+}
+
+// LogWaitlistNotifier only logs the offers; it is used until guest
+// messaging is configured.
+type LogWaitlistNotifier struct{}
+
+// NotifyWaitlist implements WaitlistNotifier.
+func (LogWaitlistNotifier) NotifyWaitlist(_ context.Context, entry WaitlistEntry, hold Booking) error {
+	log.Printf("lista de espera: habitacion %s reservada para %s hasta %s", hold.RoomID.Hex(), entry.Email, hold.HeldUntil.Format(time.RFC3339))
+	return nil
+}
+
+// WaitlistService queues guests for fully booked stays and offers them the
+// rooms freed by cancellations, in the order they joined.
+type WaitlistService struct {
+	entries  WaitlistRepository
+	bookings *BookingService
+	notifier WaitlistNotifier
+	hold     time.Duration
+	now      func() time.Time
+	ids      IDGenerator
+
+	// mu serializes Process so an entry is never offered two rooms.
+	mu   sync.Mutex
+	wake chan struct{}
+}
+
+// NewWaitlistService builds a new WaitlistService instance; offered rooms
+// are held for the hold duration.
+func NewWaitlistService(entries WaitlistRepository, bookings *BookingService, notifier WaitlistNotifier, hold time.Duration, now func() time.Time, ids IDGenerator) *WaitlistService {
+	if now == nil {
+		now = time.Now
+	}
+	if ids == nil {
+		ids = SystemClock{}
+	}
+	if notifier == nil {
+		notifier = LogWaitlistNotifier{}
+	}
+	return &WaitlistService{
+		entries:  entries,
+		bookings: bookings,
+		notifier: notifier,
+		hold:     hold,
+		now:      now,
+		ids:      ids,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Join adds a guest to the waitlist of the scoped property. It is only
+// allowed when no room fits the stay.
+func (s *WaitlistService) Join(ctx context.Context, input WaitlistInput) (WaitlistEntryResponse, error) {
+	entry := WaitlistEntry{
+		Email:    NormalizeEmail(input.Email),
+		Guests:   input.Guests,
+		RoomType: normalizeKeyword(input.RoomType),
+	}
+	if entry.Email == "" || entry.Guests < 1 || (entry.RoomType != "" && !roomTypes[entry.RoomType]) {
+		return WaitlistEntryResponse{}, ErrInvalidWaitlistInput
+	}
+
+	var err error
+	entry.CheckIn, entry.CheckOut, err = s.bookings.ParseStay(input.CheckIn, input.CheckOut)
+	if err != nil {
+		return WaitlistEntryResponse{}, err
+	}
+
+	property := ScopedProperty(ctx)
+	rooms, err := s.bookings.bookings.Available(ctx, property, entry.CheckIn, entry.CheckOut)
+	if err != nil {
+		return WaitlistEntryResponse{}, err
+	}
+	for _, room := range rooms {
+		if entry.fits(room) {
+			return WaitlistEntryResponse{}, ErrRoomsAvailable
+		}
+	}
+
+	if !property.IsZero() {
+		entry.PropertyID = &property
+	}
+	now := s.now()
+	entry.ID = s.ids.NewID()
+	entry.Status = WaitlistWaiting
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+
+	created, err := s.entries.Create(ctx, entry)
+	if err != nil {
+		return WaitlistEntryResponse{}, err
+	}
+	return created.ToResponse(), nil
+}
+
+// List returns the entries of the scoped property, optionally filtered by
+// status, in the order they joined.
+func (s *WaitlistService) List(ctx context.Context, status string) ([]WaitlistEntryResponse, error) {
+	status = normalizeKeyword(status)
+	if status != "" && !waitlistStatuses[status] {
+		return nil, ErrInvalidWaitlistInput
+	}
+
+	entries, err := s.entries.List(ctx, WaitlistQuery{PropertyID: ScopedProperty(ctx), Status: status})
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]WaitlistEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, entry.ToResponse())
+	}
+	return responses, nil
+}
+
+// Confirm turns the room held for an offered entry into a regular booking.
+func (s *WaitlistService) Confirm(ctx context.Context, id string) (BookingResponse, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return BookingResponse{}, ErrInvalidWaitlistID
+	}
+	entry, err := s.entries.FindByID(ctx, objID)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	if !inScope(ctx, entry.PropertyID) {
+		return BookingResponse{}, ErrNotFound
+	}
+	if entry.Status != WaitlistOffered || entry.BookingID == nil {
+		return BookingResponse{}, ErrWaitlistStateConflict
+	}
+
+	now := s.now()
+	if entry.HoldUntil != nil && !entry.HoldUntil.After(now) {
+		return BookingResponse{}, ErrWaitlistHoldExpired
+	}
+
+	booking, err := s.bookings.confirmHold(ctx, *entry.BookingID)
+	if err != nil {
+		return BookingResponse{}, err
+	}
+	if _, err := s.entries.Transition(ctx, entry.ID, WaitlistOffered, WaitlistUpdate{Status: WaitlistBooked, UpdatedAt: now}); err != nil {
+		log.Printf("no se pudo cerrar la entrada %s de la lista de espera: %v", entry.ID.Hex(), err)
+	}
+	return booking.ToResponse(), nil
+}
+
+// HandleBookingEvent wakes the worker when a cancellation frees a room. It
+// is meant to be registered with BookingService.Subscribe.
+func (s *WaitlistService) HandleBookingEvent(_ context.Context, event BookingEvent) {
+	if event.Type != EventBookingCancelled {
+		return
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes the waitlist every interval and after each cancellation
+// until ctx is done.
+func (s *WaitlistService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Process(ctx); err != nil {
+			log.Printf("no se pudo procesar la lista de espera: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// Process releases the expired holds and then walks the waiting entries in
+// the order they joined, holding the first free room that fits each one and
+// notifying the guest.
+func (s *WaitlistService) Process(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if err := s.expireHolds(ctx, now); err != nil {
+		return err
+	}
+
+	waiting, err := s.entries.List(ctx, WaitlistQuery{Status: WaitlistWaiting})
+	if err != nil {
+		return err
+	}
+	today := now.UTC().Truncate(24 * time.Hour)
+	for _, entry := range waiting {
+		if entry.CheckIn.Before(today) {
+			s.expire(ctx, entry, WaitlistWaiting, now)
+			continue
+		}
+		if err := s.offer(ctx, entry, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireHolds cancels the held bookings whose entry was not confirmed in
+// time. The cancellation frees the room for the next entry in line.
+func (s *WaitlistService) expireHolds(ctx context.Context, now time.Time) error {
+	offered, err := s.entries.List(ctx, WaitlistQuery{Status: WaitlistOffered, HoldBefore: now})
+	if err != nil {
+		return err
+	}
+	for _, entry := range offered {
+		if entry.BookingID != nil {
+			_, err := s.bookings.cancel(ctx, *entry.BookingID, BookingHeld)
+			if err != nil && !errors.Is(err, ErrBookingStateConflict) && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		s.expire(ctx, entry, WaitlistOffered, now)
+	}
+	return nil
+}
+
+func (s *WaitlistService) expire(ctx context.Context, entry WaitlistEntry, from string, now time.Time) {
+	if _, err := s.entries.Transition(ctx, entry.ID, from, WaitlistUpdate{Status: WaitlistExpired, UpdatedAt: now}); err != nil {
+		log.Printf("no se pudo vencer la entrada %s de la lista de espera: %v", entry.ID.Hex(), err)
+	}
+}
+
+// offer holds the first free room that fits entry, if any.
+func (s *WaitlistService) offer(ctx context.Context, entry WaitlistEntry, now time.Time) error {
+	var property primitive.ObjectID
+	if entry.PropertyID != nil {
+		property = *entry.PropertyID
+	}
+	rooms, err := s.bookings.bookings.Available(ctx, property, entry.CheckIn, entry.CheckOut)
+	if err != nil {
+		return err
+	}
+
+	until := now.Add(s.hold)
+	for _, room := range rooms {
+		if !entry.fits(room) {
+			continue
+		}
+		hold, err := s.bookings.hold(ctx, entry, room.ID, until)
+		if errors.Is(err, ErrBookingOverlap) {
+			// Booked since the availability check; try the next room.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		offered, err := s.entries.Transition(ctx, entry.ID, WaitlistWaiting, WaitlistUpdate{
+			Status:    WaitlistOffered,
+			BookingID: &hold.ID,
+			HoldUntil: &until,
+			UpdatedAt: now,
+		})
+		if err != nil {
+			if _, cancelErr := s.bookings.cancel(ctx, hold.ID, BookingHeld); cancelErr != nil {
+				log.Printf("no se pudo liberar la habitacion %s retenida: %v", room.ID.Hex(), cancelErr)
+			}
+			return err
+		}
+		if err := s.notifier.NotifyWaitlist(ctx, offered, hold); err != nil {
+			log.Printf("no se pudo avisar a %s de la habitacion retenida: %v", entry.Email, err)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,979 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+type MemoryUserRepo struct {
+	mu    sync.Mutex
+	users map[string]services.User
+	// todos, when set, gives Search the activity of each user.
+	todos services.TodoRepository
+}
+
+func NewMemoryUserRepo() *MemoryUserRepo {
+	return &MemoryUserRepo{users: make(map[string]services.User)}
+}
+
+func (m *MemoryUserRepo) FindByEmail(_ context.Context, email string) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	return user, nil
+}
+
+func (m *MemoryUserRepo) Insert(_ context.Context, user services.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	m.mu.Lock()
+	m.users[user.Email] = user
+	m.mu.Unlock()
+
+	// The todos created for the email before it had an account become the
+	// user's.
+	if memory, ok := m.todos.(*MemoryTodoRepo); ok {
+		memory.adopt(user)
+	}
+	return nil
+}
+
+func (m *MemoryUserRepo) List(_ context.Context) ([]services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := make([]services.User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Email < users[j].Email
+	})
+	return users, nil
+}
+
+func (m *MemoryUserRepo) Clear(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = make(map[string]services.User)
+	return nil
+}
+
+func (m *MemoryUserRepo) SetRole(_ context.Context, email, role string) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	user.Role = role
+	m.users[email] = user
+	return user, nil
+}
+
+func (m *MemoryUserRepo) SetProperty(_ context.Context, email string, propertyID *primitive.ObjectID) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	user.PropertyID = propertyID
+	m.users[email] = user
+	return user, nil
+}
+
+func (m *MemoryUserRepo) SetQuota(_ context.Context, email string, quota *services.QuotaOverride) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	user.Quota = quota
+	m.users[email] = user
+	return user, nil
+}
+
+func (m *MemoryUserRepo) Search(ctx context.Context, query services.UserQuery) ([]services.UserActivity, error) {
+	users, _ := m.List(ctx)
+	matched := make([]services.UserActivity, 0, len(users))
+	for _, user := range users {
+		switch {
+		case !strings.Contains(strings.ToLower(user.Email), strings.ToLower(query.Search)),
+			query.Email != "" && user.Email != query.Email,
+			query.PropertyID != nil && (user.PropertyID == nil || *user.PropertyID != *query.PropertyID),
+			query.Suspended != nil && (user.SuspendedAt != nil) != *query.Suspended:
+		default:
+			matched = append(matched, services.UserActivity{User: user})
+		}
+	}
+	start := min(query.Offset, len(matched))
+	matched = matched[start:]
+	if query.Limit > 0 && query.Limit < len(matched) {
+		matched = matched[:query.Limit]
+	}
+	if m.todos == nil {
+		return matched, nil
+	}
+	for i := range matched {
+		live, err := m.todos.List(ctx, services.TodoQuery{Email: matched[i].Email})
+		if err != nil {
+			return nil, err
+		}
+		trashed, err := m.todos.List(ctx, services.TodoQuery{Email: matched[i].Email, Trashed: true})
+		if err != nil {
+			return nil, err
+		}
+		matched[i].TodoCount = int64(len(live))
+		for _, todo := range append(live, trashed...) {
+			for _, at := range []*time.Time{&todo.CreatedAt, todo.CompletedAt, todo.DeletedAt} {
+				if at != nil && (matched[i].LastActivityAt == nil || at.After(*matched[i].LastActivityAt)) {
+					last := *at
+					matched[i].LastActivityAt = &last
+				}
+			}
+		}
+	}
+	return matched, nil
+}
+
+func (m *MemoryUserRepo) Count(ctx context.Context, query services.UserQuery) (int64, error) {
+	query.Offset, query.Limit = 0, 0
+	users, _ := m.Search(ctx, query)
+	return int64(len(users)), nil
+}
+
+func (m *MemoryUserRepo) SetSuspended(_ context.Context, email string, at *time.Time) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	user.SuspendedAt = at
+	m.users[email] = user
+	return user, nil
+}
+
+func (m *MemoryUserRepo) SetFeedToken(_ context.Context, email string, token *services.UserFeedToken) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	user.FeedToken = token
+	m.users[email] = user
+	return user, nil
+}
+
+func (m *MemoryUserRepo) FindByFeedToken(_ context.Context, tokenHash string) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if user.FeedToken != nil && user.FeedToken.TokenHash == tokenHash {
+			return user, nil
+		}
+	}
+	return services.User{}, services.ErrNotFound
+}
+
+func (m *MemoryUserRepo) SetEmailChange(_ context.Context, email string, change *services.PendingEmailChange) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[email]
+	if !ok {
+		return services.User{}, services.ErrNotFound
+	}
+	user.EmailChange = change
+	m.users[email] = user
+	return user, nil
+}
+
+func (m *MemoryUserRepo) FindByEmailChangeToken(_ context.Context, tokenHash string) (services.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, user := range m.users {
+		if change := user.EmailChange; change != nil && (change.TokenHash == tokenHash || change.CancelHash == tokenHash) {
+			return user, nil
+		}
+	}
+	return services.User{}, services.ErrNotFound
+}
+
+// sameProperty compares optional property IDs like the Mongo filters do.
+func sameProperty(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+type MemoryPropertyRepo struct {
+	mu         sync.Mutex
+	properties map[primitive.ObjectID]services.Property
+}
+
+func NewMemoryPropertyRepo() *MemoryPropertyRepo {
+	return &MemoryPropertyRepo{properties: make(map[primitive.ObjectID]services.Property)}
+}
+
+func (m *MemoryPropertyRepo) List(_ context.Context) ([]services.Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	properties := make([]services.Property, 0, len(m.properties))
+	for _, property := range m.properties {
+		properties = append(properties, property)
+	}
+	sort.Slice(properties, func(i, j int) bool { return properties[i].Code < properties[j].Code })
+	return properties, nil
+}
+
+func (m *MemoryPropertyRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	property, ok := m.properties[id]
+	if !ok {
+		return services.Property{}, services.ErrNotFound
+	}
+	return property, nil
+}
+
+func (m *MemoryPropertyRepo) Create(_ context.Context, property services.Property) (services.Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.properties {
+		if existing.Code == property.Code {
+			return services.Property{}, services.ErrPropertyCodeTaken
+		}
+	}
+	if property.ID.IsZero() {
+		property.ID = primitive.NewObjectID()
+	}
+	m.properties[property.ID] = property
+	return property, nil
+}
+
+func (m *MemoryPropertyRepo) SetSSO(_ context.Context, id primitive.ObjectID, sso *services.PropertySSO) (services.Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	property, ok := m.properties[id]
+	if !ok {
+		return services.Property{}, services.ErrNotFound
+	}
+	property.SSO = sso
+	m.properties[id] = property
+	return property, nil
+}
+
+func (m *MemoryPropertyRepo) SetSCIM(_ context.Context, id primitive.ObjectID, scim *services.PropertySCIM) (services.Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	property, ok := m.properties[id]
+	if !ok {
+		return services.Property{}, services.ErrNotFound
+	}
+	property.SCIM = scim
+	m.properties[id] = property
+	return property, nil
+}
+
+func (m *MemoryPropertyRepo) FindBySCIMToken(_ context.Context, tokenHash string) (services.Property, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, property := range m.properties {
+		if property.SCIM != nil && property.SCIM.TokenHash == tokenHash {
+			return property, nil
+		}
+	}
+	return services.Property{}, services.ErrNotFound
+}
+
+type MemorySessionRepo struct {
+	mu       sync.Mutex
+	sessions map[string]services.Session
+}
+
+func NewMemorySessionRepo() *MemorySessionRepo {
+	return &MemorySessionRepo{sessions: make(map[string]services.Session)}
+}
+
+func (m *MemorySessionRepo) Create(_ context.Context, session services.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[session.TokenHash] = session
+	return nil
+}
+
+func (m *MemorySessionRepo) Find(_ context.Context, tokenHash string) (services.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[tokenHash]
+	if !ok {
+		return services.Session{}, services.ErrNotFound
+	}
+	return session, nil
+}
+
+func (m *MemorySessionRepo) ListByEmail(_ context.Context, email string) ([]services.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []services.Session
+	for _, session := range m.sessions {
+		if session.Email == email {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID.Hex() > sessions[j].ID.Hex()
+	})
+	return sessions, nil
+}
+
+func (m *MemorySessionRepo) Delete(_ context.Context, email string, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, session := range m.sessions {
+		if session.ID == id && session.Email == email {
+			delete(m.sessions, hash)
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
+
+type MemoryLoginRepo struct {
+	mu     sync.Mutex
+	logins []services.LoginRecord
+}
+
+func (m *MemoryLoginRepo) Record(_ context.Context, login services.LoginRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	login.ID = primitive.NewObjectID()
+	m.logins = append(m.logins, login)
+	return nil
+}
+
+func (m *MemoryLoginRepo) List(_ context.Context, email string, limit int) ([]services.LoginRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var logins []services.LoginRecord
+	for i := len(m.logins) - 1; i >= 0; i-- {
+		if m.logins[i].Email == email && (limit == 0 || len(logins) < limit) {
+			logins = append(logins, m.logins[i])
+		}
+	}
+	return logins, nil
+}
+
+func (m *MemoryLoginRepo) Delete(_ context.Context, email string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.logins[:0]
+	for _, login := range m.logins {
+		if login.Email != email {
+			kept = append(kept, login)
+		}
+	}
+	deleted := int64(len(m.logins) - len(kept))
+	m.logins = kept
+	return deleted, nil
+}
+
+type MemoryPasskeyRepo struct {
+	mu       sync.Mutex
+	passkeys []services.Passkey
+}
+
+func (m *MemoryPasskeyRepo) Create(_ context.Context, passkey services.Passkey) (services.Passkey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.passkeys {
+		if existing.CredentialID == passkey.CredentialID {
+			return services.Passkey{}, services.ErrPasskeyExists
+		}
+	}
+	if passkey.ID.IsZero() {
+		passkey.ID = primitive.NewObjectID()
+	}
+	m.passkeys = append(m.passkeys, passkey)
+	return passkey, nil
+}
+
+func (m *MemoryPasskeyRepo) FindByCredentialID(_ context.Context, credentialID string) (services.Passkey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, passkey := range m.passkeys {
+		if passkey.CredentialID == credentialID {
+			return passkey, nil
+		}
+	}
+	return services.Passkey{}, services.ErrNotFound
+}
+
+func (m *MemoryPasskeyRepo) ListByEmail(_ context.Context, email string) ([]services.Passkey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var passkeys []services.Passkey
+	for _, passkey := range m.passkeys {
+		if passkey.Email == email {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (m *MemoryPasskeyRepo) MarkUsed(_ context.Context, id primitive.ObjectID, signCount uint32, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.passkeys {
+		if m.passkeys[i].ID == id {
+			m.passkeys[i].SignCount = signCount
+			m.passkeys[i].LastUsedAt = &at
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
+
+func (m *MemoryPasskeyRepo) Delete(_ context.Context, email string, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, passkey := range m.passkeys {
+		if passkey.ID == id && passkey.Email == email {
+			m.passkeys = slices.Delete(m.passkeys, i, i+1)
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
+
+type MemoryCeremonyRepo struct {
+	mu         sync.Mutex
+	ceremonies map[primitive.ObjectID]services.PasskeyCeremony
+}
+
+func (m *MemoryCeremonyRepo) Create(_ context.Context, ceremony services.PasskeyCeremony) (services.PasskeyCeremony, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ceremonies == nil {
+		m.ceremonies = make(map[primitive.ObjectID]services.PasskeyCeremony)
+	}
+	if ceremony.ID.IsZero() {
+		ceremony.ID = primitive.NewObjectID()
+	}
+	m.ceremonies[ceremony.ID] = ceremony
+	return ceremony, nil
+}
+
+func (m *MemoryCeremonyRepo) Take(_ context.Context, id primitive.ObjectID) (services.PasskeyCeremony, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ceremony, ok := m.ceremonies[id]
+	if !ok {
+		return services.PasskeyCeremony{}, services.ErrNotFound
+	}
+	delete(m.ceremonies, id)
+	return ceremony, nil
+}
+
+type MemorySSOStateRepo struct {
+	mu     sync.Mutex
+	states map[string]services.SSOState
+}
+
+func (m *MemorySSOStateRepo) Create(_ context.Context, state services.SSOState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.states == nil {
+		m.states = make(map[string]services.SSOState)
+	}
+	m.states[state.StateHash] = state
+	return nil
+}
+
+func (m *MemorySSOStateRepo) Take(_ context.Context, stateHash string) (services.SSOState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.states[stateHash]
+	if !ok {
+		return services.SSOState{}, services.ErrNotFound
+	}
+	delete(m.states, stateHash)
+	return state, nil
+}
+
+type MemoryRealtimeTicketRepo struct {
+	mu      sync.Mutex
+	tickets map[string]services.RealtimeTicket
+}
+
+func (m *MemoryRealtimeTicketRepo) Create(_ context.Context, ticket services.RealtimeTicket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tickets == nil {
+		m.tickets = make(map[string]services.RealtimeTicket)
+	}
+	m.tickets[ticket.TicketHash] = ticket
+	return nil
+}
+
+func (m *MemoryRealtimeTicketRepo) Take(_ context.Context, ticketHash string) (services.RealtimeTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticket, ok := m.tickets[ticketHash]
+	if !ok {
+		return services.RealtimeTicket{}, services.ErrNotFound
+	}
+	delete(m.tickets, ticketHash)
+	return ticket, nil
+}
+
+type MemoryLoginFailureRepo struct {
+	mu       sync.Mutex
+	failures map[string]services.LoginFailures
+}
+
+func (m *MemoryLoginFailureRepo) Record(_ context.Context, email string, at time.Time, window time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failures == nil {
+		m.failures = make(map[string]services.LoginFailures)
+	}
+	failures, ok := m.failures[email]
+	if !ok || !at.Before(failures.ExpiresAt) {
+		failures = services.LoginFailures{Email: email, ExpiresAt: at.Add(window)}
+	}
+	failures.Count++
+	m.failures[email] = failures
+	return nil
+}
+
+func (m *MemoryLoginFailureRepo) Count(_ context.Context, email string, at time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	failures, ok := m.failures[email]
+	if !ok || !at.Before(failures.ExpiresAt) {
+		return 0, nil
+	}
+	return failures.Count, nil
+}
+
+func (m *MemoryLoginFailureRepo) Reset(_ context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, email)
+	return nil
+}
+
+// MemoryPrivacyRepo reads and erases personal data over the memory
+// repositories.
+type MemoryPrivacyRepo struct {
+	users    *MemoryUserRepo
+	todos    services.TodoRepository
+	sessions *MemorySessionRepo
+	passkeys *MemoryPasskeyRepo
+	bookings *MemoryBookingRepo
+	reviews  *MemoryReviewRepo
+	outbox   *MemoryOutbox
+
+	comments      *MemoryCommentRepo
+	attachments   *MemoryAttachmentRepo
+	notifications *MemoryNotificationRepo
+	lists         *MemoryListRepo
+}
+
+func (m *MemoryPrivacyRepo) Comments(_ context.Context, bookingIDs []primitive.ObjectID) ([]services.Review, error) {
+	m.reviews.mu.Lock()
+	defer m.reviews.mu.Unlock()
+	var reviews []services.Review
+	for _, review := range m.reviews.reviews {
+		if slices.Contains(bookingIDs, review.BookingID) {
+			reviews = append(reviews, review)
+		}
+	}
+	return reviews, nil
+}
+
+func (m *MemoryPrivacyRepo) Activity(_ context.Context, keys []string) ([]events.Event, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	var activity []events.Event
+	for _, msg := range m.outbox.messages {
+		if slices.Contains(keys, msg.Event.Key) {
+			activity = append(activity, msg.Event)
+		}
+	}
+	return activity, nil
+}
+
+func (m *MemoryPrivacyRepo) EraseComments(_ context.Context, bookingIDs []primitive.ObjectID) (int64, error) {
+	m.reviews.mu.Lock()
+	defer m.reviews.mu.Unlock()
+	var erased int64
+	for i, review := range m.reviews.reviews {
+		if slices.Contains(bookingIDs, review.BookingID) && review.Comment != "" {
+			m.reviews.reviews[i].Comment = ""
+			erased++
+		}
+	}
+	return erased, nil
+}
+
+func (m *MemoryPrivacyRepo) AnonymizeActivity(_ context.Context, keys []string, email, alias string) (int64, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	var anonymized int64
+	for i, msg := range m.outbox.messages {
+		if !slices.Contains(keys, msg.Event.Key) {
+			continue
+		}
+		event := &m.outbox.messages[i].Event
+		data := bytes.ReplaceAll(event.Data, []byte(email), []byte(alias))
+		if event.Key != email && bytes.Equal(data, event.Data) {
+			continue
+		}
+		if event.Key == email {
+			event.Key = alias
+		}
+		event.Data = data
+		anonymized++
+	}
+	return anonymized, nil
+}
+
+func (m *MemoryPrivacyRepo) AnonymizeBookings(_ context.Context, email, alias string) (int64, error) {
+	m.bookings.mu.Lock()
+	defer m.bookings.mu.Unlock()
+	var anonymized int64
+	for id, booking := range m.bookings.bookings {
+		if booking.Email == email {
+			booking.Email = alias
+			m.bookings.bookings[id] = booking
+			anonymized++
+		}
+	}
+	return anonymized, nil
+}
+
+func (m *MemoryPrivacyRepo) DeleteTodos(ctx context.Context, email string) (int64, error) {
+	live, err := m.todos.Count(ctx, services.TodoQuery{Email: email, IncludeArchived: true})
+	if err != nil {
+		return 0, err
+	}
+	trashed, err := m.todos.Count(ctx, services.TodoQuery{Email: email, Trashed: true})
+	if err != nil {
+		return 0, err
+	}
+	owned, err := m.todos.List(ctx, services.TodoQuery{Email: email, IncludeArchived: true})
+	if err != nil {
+		return 0, err
+	}
+	inTrash, err := m.todos.List(ctx, services.TodoQuery{Email: email, Trashed: true})
+	if err != nil {
+		return 0, err
+	}
+	var ids []primitive.ObjectID
+	for _, todo := range append(owned, inTrash...) {
+		ids = append(ids, todo.ID)
+	}
+	m.comments.forget(email, ids)
+	m.attachments.forget(email, ids)
+	m.notifications.forget(email, ids)
+	emptied := m.lists.forget(email)
+	if memory, ok := m.todos.(*MemoryTodoRepo); ok {
+		memory.forget(email, emptied)
+	}
+	return live + trashed, m.todos.Clear(ctx, email, false)
+}
+
+func (m *MemoryPrivacyRepo) DeleteSessions(_ context.Context, email string) (int64, error) {
+	m.sessions.mu.Lock()
+	defer m.sessions.mu.Unlock()
+	var deleted int64
+	for hash, session := range m.sessions.sessions {
+		if session.Email == email {
+			delete(m.sessions.sessions, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryPrivacyRepo) DeleteUser(_ context.Context, email string) error {
+	m.passkeys.mu.Lock()
+	m.passkeys.passkeys = slices.DeleteFunc(m.passkeys.passkeys, func(passkey services.Passkey) bool {
+		return passkey.Email == email
+	})
+	m.passkeys.mu.Unlock()
+
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	delete(m.users.users, email)
+	return nil
+}
+
+func (m *MemoryPrivacyRepo) AnonymizeTodos(_ context.Context, email, alias string) (int64, error) {
+	m.comments.rename(email, alias)
+	m.attachments.rename(email, alias)
+	m.notifications.forget(email, nil)
+	m.lists.rename(email, alias)
+	if memory, ok := m.todos.(*MemoryTodoRepo); ok {
+		return memory.rename(email, alias), nil
+	}
+	return 0, nil
+}
+
+func (m *MemoryPrivacyRepo) AnonymizeUser(_ context.Context, email, alias string, at time.Time) error {
+	m.passkeys.mu.Lock()
+	m.passkeys.passkeys = slices.DeleteFunc(m.passkeys.passkeys, func(passkey services.Passkey) bool {
+		return passkey.Email == email
+	})
+	m.passkeys.mu.Unlock()
+
+	m.users.mu.Lock()
+	defer m.users.mu.Unlock()
+	user, ok := m.users.users[email]
+	if !ok {
+		return nil
+	}
+	delete(m.users.users, email)
+	user.Email, user.Password, user.FeedToken, user.Quota, user.AnonymizedAt = alias, "", nil, nil, &at
+	m.users.users[alias] = user
+	return nil
+}
+
+// MemoryErasureRepo keeps account erasures in memory.
+type MemoryErasureRepo struct {
+	mu       sync.Mutex
+	erasures []services.Erasure
+}
+
+func (m *MemoryErasureRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Erasure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, erasure := range m.erasures {
+		if erasure.ID == id {
+			return erasure, nil
+		}
+	}
+	return services.Erasure{}, services.ErrNotFound
+}
+
+func (m *MemoryErasureRepo) Create(_ context.Context, erasure services.Erasure) (services.Erasure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if erasure.ID.IsZero() {
+		erasure.ID = primitive.NewObjectID()
+	}
+	m.erasures = append(m.erasures, erasure)
+	return erasure, nil
+}
+
+func (m *MemoryErasureRepo) Save(_ context.Context, erasure services.Erasure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.erasures {
+		if m.erasures[i].ID == erasure.ID {
+			m.erasures[i] = erasure
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
+
+// MemoryAccountMergeRepo moves the records of an account over the memory
+// repositories like MongoAccountMergeRepository.
+type MemoryAccountMergeRepo struct {
+	users         *MemoryUserRepo
+	todos         services.TodoRepository
+	sessions      *MemorySessionRepo
+	logins        *MemoryLoginRepo
+	passkeys      *MemoryPasskeyRepo
+	bookings      *MemoryBookingRepo
+	comments      *MemoryCommentRepo
+	attachments   *MemoryAttachmentRepo
+	notifications *MemoryNotificationRepo
+	lists         *MemoryListRepo
+}
+
+func (m *MemoryAccountMergeRepo) Count(ctx context.Context, source string) (services.MergeSummary, error) {
+	var summary services.MergeSummary
+	for _, trashed := range []bool{false, true} {
+		todos, err := m.todos.Count(ctx, services.TodoQuery{Email: source, Trashed: trashed, IncludeArchived: !trashed})
+		if err != nil {
+			return services.MergeSummary{}, err
+		}
+		summary.Todos += todos
+	}
+	lists, err := m.lists.ListFor(ctx, source)
+	if err != nil {
+		return services.MergeSummary{}, err
+	}
+	summary.Lists = int64(len(lists))
+
+	m.comments.mu.Lock()
+	for _, comment := range m.comments.comments {
+		if comment.Email == source {
+			summary.Comments++
+		}
+	}
+	m.comments.mu.Unlock()
+	m.bookings.mu.Lock()
+	for _, booking := range m.bookings.bookings {
+		if booking.Email == source {
+			summary.Bookings++
+		}
+	}
+	m.bookings.mu.Unlock()
+	m.logins.mu.Lock()
+	for _, login := range m.logins.logins {
+		if login.Email == source {
+			summary.Logins++
+		}
+	}
+	m.logins.mu.Unlock()
+	m.passkeys.mu.Lock()
+	for _, passkey := range m.passkeys.passkeys {
+		if passkey.Email == source {
+			summary.Passkeys++
+		}
+	}
+	m.passkeys.mu.Unlock()
+	return summary, nil
+}
+
+func (m *MemoryAccountMergeRepo) Merge(ctx context.Context, source, target string) (services.MergeSummary, error) {
+	summary, err := m.Count(ctx, source)
+	if err != nil {
+		return services.MergeSummary{}, err
+	}
+
+	if memory, ok := m.todos.(*MemoryTodoRepo); ok {
+		// The todos follow the account of target, if it is stored.
+		owner := memory.owner(target)
+		memory.mu.Lock()
+		for _, store := range memory.stores(true) {
+			for id, todo := range store {
+				if todo.Email == source {
+					todo.Email = target
+					if owner != nil {
+						todo.UserID = owner
+					}
+				}
+				if todo.Assignee == source {
+					todo.Assignee = target
+				}
+				todo.Reactions = services.MergeReactions(slices.Clone(todo.Reactions), source, target)
+				store[id] = todo
+			}
+		}
+		memory.mu.Unlock()
+	}
+	m.lists.mu.Lock()
+	for i, list := range m.lists.lists {
+		m.lists.lists[i].Members = services.MergeMembers(slices.Clone(list.Members), source, target)
+	}
+	m.lists.mu.Unlock()
+	m.comments.rename(source, target)
+	m.attachments.rename(source, target)
+
+	m.notifications.mu.Lock()
+	for i, notification := range m.notifications.notifications {
+		if notification.Email == source {
+			m.notifications.notifications[i].Email = target
+		}
+		if notification.Author == source {
+			m.notifications.notifications[i].Author = target
+		}
+	}
+	m.notifications.mu.Unlock()
+	m.bookings.mu.Lock()
+	for id, booking := range m.bookings.bookings {
+		if booking.Email == source {
+			booking.Email = target
+			m.bookings.bookings[id] = booking
+		}
+	}
+	m.bookings.mu.Unlock()
+	m.logins.mu.Lock()
+	for i, login := range m.logins.logins {
+		if login.Email == source {
+			m.logins.logins[i].Email = target
+		}
+	}
+	m.logins.mu.Unlock()
+	m.passkeys.mu.Lock()
+	for i, passkey := range m.passkeys.passkeys {
+		if passkey.Email == source {
+			m.passkeys.passkeys[i].Email = target
+		}
+	}
+	m.passkeys.mu.Unlock()
+
+	m.sessions.mu.Lock()
+	for hash, session := range m.sessions.sessions {
+		if session.Email == source {
+			delete(m.sessions.sessions, hash)
+		}
+	}
+	m.sessions.mu.Unlock()
+	m.users.mu.Lock()
+	delete(m.users.users, source)
+	m.users.mu.Unlock()
+	return summary, nil
+}
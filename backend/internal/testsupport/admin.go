@@ -0,0 +1,367 @@
+package testsupport
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// MemoryDashboardRepo computes the dashboard aggregations over the memory
+// repositories; the storage sizes are made up from the document counts.
+type MemoryDashboardRepo struct {
+	users  *MemoryUserRepo
+	todos  services.TodoRepository
+	outbox *MemoryOutbox
+}
+
+func (m *MemoryDashboardRepo) CountUsers(ctx context.Context) (services.UserSummary, error) {
+	users, _ := m.users.List(ctx)
+	summary := services.UserSummary{Total: int64(len(users)), ByRole: map[string]int64{}}
+	for _, user := range users {
+		if user.Role != "" {
+			summary.ByRole[user.Role]++
+			summary.Staff++
+		}
+	}
+	return summary, nil
+}
+
+func (m *MemoryDashboardRepo) SignupsPerDay(ctx context.Context, from, to time.Time) ([]services.DailyCount, error) {
+	users, _ := m.users.List(ctx)
+	counts := map[string]int64{}
+	for _, user := range users {
+		if !user.CreatedAt.Before(from) && user.CreatedAt.Before(to) {
+			counts[user.CreatedAt.Format(services.DateLayout)]++
+		}
+	}
+	var rows []services.DailyCount
+	for day, count := range counts {
+		rows = append(rows, services.DailyCount{Day: day, Count: count})
+	}
+	return rows, nil
+}
+
+func (m *MemoryDashboardRepo) TodoActivity(ctx context.Context, from, to time.Time) (services.TodoActivity, error) {
+	live, err := m.todos.List(ctx, services.TodoQuery{All: true})
+	if err != nil {
+		return services.TodoActivity{}, err
+	}
+	trashed, err := m.todos.List(ctx, services.TodoQuery{Trashed: true, All: true})
+	if err != nil {
+		return services.TodoActivity{}, err
+	}
+	var activity services.TodoActivity
+	days := map[string]*services.TodoDay{}
+	day := func(at time.Time) *services.TodoDay {
+		name := at.Format(services.DateLayout)
+		if days[name] == nil {
+			days[name] = &services.TodoDay{Day: name}
+		}
+		return days[name]
+	}
+	for _, todo := range append(live, trashed...) {
+		if !todo.CreatedAt.Before(from) && todo.CreatedAt.Before(to) {
+			day(todo.CreatedAt).Created++
+		}
+		if todo.CompletedAt != nil && !todo.CompletedAt.Before(from) && todo.CompletedAt.Before(to) {
+			day(*todo.CompletedAt).Completed++
+			activity.Durations = append(activity.Durations, todo.CompletedAt.Sub(todo.CreatedAt).Milliseconds())
+		}
+	}
+	for _, row := range days {
+		activity.Days = append(activity.Days, *row)
+	}
+	return activity, nil
+}
+
+func (m *MemoryDashboardRepo) DeliveriesPerDay(_ context.Context, from, to time.Time) ([]services.DeliveryDay, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	days := map[string]*services.DeliveryDay{}
+	for _, msg := range m.outbox.messages {
+		if msg.CreatedAt.Before(from) || !msg.CreatedAt.Before(to) {
+			continue
+		}
+		name := msg.CreatedAt.Format(services.DateLayout)
+		if days[name] == nil {
+			days[name] = &services.DeliveryDay{Day: name}
+		}
+		row := days[name]
+		row.Events++
+		row.Attempts += int64(msg.Attempts)
+		switch msg.Status {
+		case services.OutboxPublished:
+			row.Delivered++
+		case services.OutboxDead:
+			row.Dead++
+		}
+	}
+	var rows []services.DeliveryDay
+	for _, row := range days {
+		rows = append(rows, *row)
+	}
+	return rows, nil
+}
+
+func (m *MemoryDashboardRepo) Storage(ctx context.Context) (services.StorageSummary, error) {
+	users, _ := m.users.List(ctx)
+	todos, err := m.todos.Count(ctx, services.TodoQuery{All: true})
+	if err != nil {
+		return services.StorageSummary{}, err
+	}
+	collection := func(name string, documents int64) services.CollectionStorage {
+		return services.CollectionStorage{Name: name, Documents: documents, DataBytes: documents * 100, StorageBytes: documents * 128, IndexBytes: 4096}
+	}
+	return services.StorageSummary{Collections: []services.CollectionStorage{
+		collection("users", int64(len(users))),
+		collection("todos", todos),
+	}}, nil
+}
+
+// MemoryJobStore is a scheduler.Store shared by the replicas of a test.
+type MemoryJobStore struct {
+	mu       sync.Mutex
+	statuses map[string]scheduler.Status
+}
+
+func (m *MemoryJobStore) Save(_ context.Context, status scheduler.Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statuses == nil {
+		m.statuses = map[string]scheduler.Status{}
+	}
+	status.NextRun = time.Time{}
+	m.statuses[status.Name] = status
+	return nil
+}
+
+func (m *MemoryJobStore) List(_ context.Context) ([]scheduler.Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]scheduler.Status, 0, len(m.statuses))
+	for _, status := range m.statuses {
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// MemoryLease grants the leadership to the first replica that asks for it
+// until it is released.
+type MemoryLease struct {
+	mu     sync.Mutex
+	holder string
+}
+
+func (l *MemoryLease) Elector(instance string) scheduler.Elector {
+	return leaseElector{lease: l, instance: instance}
+}
+
+func (l *MemoryLease) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.holder = ""
+}
+
+type leaseElector struct {
+	lease    *MemoryLease
+	instance string
+}
+
+func (e leaseElector) Lead(context.Context) (bool, error) {
+	e.lease.mu.Lock()
+	defer e.lease.mu.Unlock()
+	if e.lease.holder == "" {
+		e.lease.holder = e.instance
+	}
+	return e.lease.holder == e.instance, nil
+}
+
+// MemoryJobLocks is a scheduler.Locker shared by the replicas of a test;
+// each replica gets its own with Instance. Locks expire by Now.
+type MemoryJobLocks struct {
+	Now func() time.Time
+
+	mu    sync.Mutex
+	locks map[string]*memoryJobLock
+}
+
+type memoryJobLock struct {
+	holder    string
+	due       time.Time
+	expiresAt time.Time
+}
+
+func (m *MemoryJobLocks) Instance(instance string) scheduler.Locker {
+	return jobLocker{locks: m, instance: instance}
+}
+
+type jobLocker struct {
+	locks    *MemoryJobLocks
+	instance string
+}
+
+func (l jobLocker) Lock(_ context.Context, name string, due time.Time, ttl time.Duration) (bool, error) {
+	m := l.locks
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks == nil {
+		m.locks = map[string]*memoryJobLock{}
+	}
+	now := m.Now()
+	if lock, ok := m.locks[name]; ok && (!lock.due.Before(due) || lock.expiresAt.After(now)) {
+		return false, nil
+	}
+	m.locks[name] = &memoryJobLock{holder: l.instance, due: due, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (l jobLocker) Extend(_ context.Context, name string, due time.Time, ttl time.Duration) (bool, error) {
+	m := l.locks
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lock, ok := m.locks[name]
+	if !ok || lock.holder != l.instance || !lock.due.Equal(due) {
+		return false, nil
+	}
+	lock.expiresAt = m.Now().Add(ttl)
+	return true, nil
+}
+
+func (l jobLocker) Unlock(_ context.Context, name string, due time.Time) error {
+	m := l.locks
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if lock, ok := m.locks[name]; ok && lock.holder == l.instance && lock.due.Equal(due) {
+		lock.expiresAt = m.Now()
+	}
+	return nil
+}
+
+// MemoryBackupRepo backs up the users and todos of the memory repositories
+// and keeps the documents of any other collection as they were restored.
+type MemoryBackupRepo struct {
+	users *MemoryUserRepo
+	todos *MemoryTodoRepo
+
+	mu          sync.Mutex
+	collections map[string][]bson.Raw
+}
+
+func (m *MemoryBackupRepo) Export(ctx context.Context, collection string, fn func(doc bson.Raw) error) error {
+	var docs []any
+	switch collection {
+	case "users":
+		users, _ := m.users.List(ctx)
+		for _, user := range users {
+			docs = append(docs, user)
+		}
+	case "todos":
+		live, _ := m.todos.List(ctx, services.TodoQuery{All: true})
+		trashed, _ := m.todos.List(ctx, services.TodoQuery{Trashed: true, All: true})
+		for _, todo := range append(live, trashed...) {
+			docs = append(docs, todo)
+		}
+	default:
+		m.mu.Lock()
+		for _, doc := range m.collections[collection] {
+			docs = append(docs, doc)
+		}
+		m.mu.Unlock()
+	}
+	for _, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if err := fn(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryBackupRepo) Replace(ctx context.Context, collection string, docs []bson.Raw) error {
+	switch collection {
+	case "users":
+		_ = m.users.Clear(ctx)
+		for _, doc := range docs {
+			var user services.User
+			if err := bson.Unmarshal(doc, &user); err != nil {
+				return err
+			}
+			_ = m.users.Insert(ctx, user)
+		}
+	case "todos":
+		_ = m.todos.Clear(ctx, "", true)
+		for _, doc := range docs {
+			var todo services.Todo
+			if err := bson.Unmarshal(doc, &todo); err != nil {
+				return err
+			}
+			_, _ = m.todos.Create(ctx, todo)
+		}
+	default:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.collections == nil {
+			m.collections = map[string][]bson.Raw{}
+		}
+		m.collections[collection] = docs
+	}
+	return nil
+}
+
+// MemoryTodoStatsRepo groups the todos of the memory repository like the
+// $facet pipeline of MongoTodoStatsRepository.
+type MemoryTodoStatsRepo struct {
+	todos services.TodoRepository
+}
+
+func (m *MemoryTodoStatsRepo) Breakdown(ctx context.Context, email string) (services.StatsRows, error) {
+	todos, err := m.todos.List(ctx, services.TodoQuery{Email: email})
+	if err != nil {
+		return services.StatsRows{}, err
+	}
+	tags, lists, weekdays := map[string]*services.StatsRow{}, map[string]*services.StatsRow{}, map[string]*services.StatsRow{}
+	add := func(groups map[string]*services.StatsRow, key string, todo services.Todo) {
+		if groups[key] == nil {
+			groups[key] = &services.StatsRow{Key: key}
+		}
+		row := groups[key]
+		row.Todos++
+		if todo.Completed {
+			row.Completed++
+			if todo.CompletedAt != nil {
+				row.Durations = append(row.Durations, todo.CompletedAt.Sub(todo.CreatedAt).Milliseconds())
+			}
+		}
+	}
+	for _, todo := range todos {
+		for _, tag := range []string{todo.Color, todo.Icon} {
+			if tag != "" {
+				add(tags, tag, todo)
+			}
+		}
+		list := ""
+		if todo.ListID != nil {
+			list = todo.ListID.Hex()
+		}
+		add(lists, list, todo)
+		add(weekdays, strconv.Itoa(int(todo.CreatedAt.UTC().Weekday())), todo)
+	}
+
+	rows := func(groups map[string]*services.StatsRow) []services.StatsRow {
+		var out []services.StatsRow
+		for _, row := range groups {
+			out = append(out, *row)
+		}
+		return out
+	}
+	return services.StatsRows{Tags: rows(tags), Lists: rows(lists), Weekdays: rows(weekdays)}, nil
+}
@@ -0,0 +1,366 @@
+// Package testsupport runs the API router against in-memory fakes of every
+// repository, so handler tests need neither MongoDB nor Docker. It also
+// provides the request helpers and golden-file assertions the tests share.
+package testsupport
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mock"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scanner"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/webauthn"
+)
+
+// App is the API router wired to in-memory repositories, with handles on
+// the fakes and services that tests inspect or drive. Todos is nil when
+// the app runs on another todo repository.
+type App struct {
+	Router      *gin.Engine
+	Users       *MemoryUserRepo
+	Todos       *MemoryTodoRepo
+	Rooms       *MemoryRoomRepo
+	Bookings    *MemoryBookingRepo
+	Reviews     *MemoryReviewRepo
+	Waitlist    *services.WaitlistService
+	Notifier    *RecordingWaitlistNotifier
+	Mailer      *services.BookingMailer
+	Mailbox     *RecordingMailer
+	Events      *RecordingEvents
+	Outbox      *MemoryOutbox
+	Relay       *services.OutboxRelay
+	Jobs        *scheduler.Scheduler
+	DeadLetters *MemoryDeadLetterRepo
+	Captcha     *Captcha
+	Realtime    *realtime.Hub
+	Activity    *MemoryActivityRepo
+	// Links serves the pages linked from todo titles.
+	Links *LinkPages
+	// Backups is nil when the app runs on another todo repository.
+	Backups *MemoryBackupRepo
+	// Clock drives the services, so tests can let holds and sessions expire.
+	Clock *Clock
+	// staff caches the manager headers returned by StaffHeaders.
+	staff map[string]string
+}
+
+// NewApp validates every response against the OpenAPI spec so handler
+// changes that drift from the published contract fail the suite.
+func NewApp() *App {
+	return NewAppWithConfig(handlers.RouterConfig{ContractMode: middleware.ContractFail})
+}
+
+// NewAppWithConfig is NewApp with a custom router configuration.
+func NewAppWithConfig(cfg handlers.RouterConfig) *App {
+	return NewAppWithOptions(cfg, Options{})
+}
+
+// NewAppWithTodos wires the router around a custom todo repository
+// (e.g. a flaky or decorated one); every other repository is in memory.
+func NewAppWithTodos(cfg handlers.RouterConfig, todos services.TodoRepository) *App {
+	return NewAppWithOptions(cfg, Options{Todos: todos})
+}
+
+// Options replaces some in-memory repositories of NewAppWithOptions, e.g.
+// with MongoDB ones for benchmarks. Nil fields keep the fakes.
+type Options struct {
+	Todos     services.TodoRepository
+	Dashboard services.DashboardRepository
+	// Scanner scans the attachments; nil serves them unscanned.
+	Scanner *Scanner
+	// Realtime carries the realtime messages; apps sharing one act as
+	// replicas of the same deployment. Nil keeps them to the app.
+	Realtime realtime.Bridge
+	// RealtimeTickets keeps the realtime tickets; apps sharing it accept
+	// each other's. Nil keeps them in memory.
+	RealtimeTickets services.RealtimeTicketRepository
+}
+
+// NewAppWithOptions wires the router around the repositories of opts; every
+// other repository is in memory.
+func NewAppWithOptions(cfg handlers.RouterConfig, opts Options) *App {
+	gin.SetMode(gin.TestMode)
+	var memoryTodos *MemoryTodoRepo
+	todos := opts.Todos
+	if todos == nil {
+		memoryTodos = NewMemoryTodoRepo()
+		todos = memoryTodos
+	}
+	now := func() time.Time { return FixedTime }
+
+	users := NewMemoryUserRepo()
+	users.todos = todos
+	if memoryTodos != nil {
+		memoryTodos.users = users
+	}
+	properties := NewMemoryPropertyRepo()
+	sessions := NewMemorySessionRepo()
+	logins := &MemoryLoginRepo{}
+	passkeys := &MemoryPasskeyRepo{}
+	captchaProvider := &Captcha{}
+	rooms := NewMemoryRoomRepo()
+	bookings := NewMemoryBookingRepo(rooms)
+	guests := NewMemoryGuestRepo()
+	ratePlans := NewMemoryRatePlanRepo()
+	reviews := &MemoryReviewRepo{}
+	comments := &MemoryCommentRepo{}
+	attachments := &MemoryAttachmentRepo{}
+	notifications := &MemoryNotificationRepo{}
+	lists := &MemoryListRepo{}
+
+	clock := NewClock(FixedTime)
+	bus := events.NewBus()
+	published := &RecordingEvents{}
+	bus.Subscribe(published.record)
+	outbox := &MemoryOutbox{}
+	deadLetters := &MemoryDeadLetterRepo{}
+	// With cfg.Mocks the integrations are wired as in MOCK_INTEGRATIONS mode:
+	// emails and waitlist offers only reach the recorder, not Mailbox and
+	// Notifier.
+	hub := realtime.NewHub(opts.Realtime)
+	tickets := opts.RealtimeTickets
+	if tickets == nil {
+		tickets = &MemoryRealtimeTicketRepo{}
+	}
+	_ = hub.Start(context.Background())
+	realtimePublisher := services.NewRealtimePublisher(hub, todos, bookings)
+	activity := &MemoryActivityRepo{Max: ActivityMaxEvents}
+	activityFeed := services.NewActivityFeed(activity, todos, bookings, clock)
+	publisher := events.Fanout{bus, realtimePublisher, activityFeed}
+	if cfg.Mocks != nil {
+		publisher = events.Fanout{bus, mock.Publisher{Recorder: cfg.Mocks, To: events.BrokerMemory}, realtimePublisher, activityFeed}
+	}
+	relay := services.NewOutboxRelay(outbox, publisher, deadLetters, services.OutboxRelayConfig{Backoff: time.Second, MaxBackoff: time.Minute}, clock.Now)
+
+	todoService := services.NewTodoService(todos, outbox, clock.Now, clock)
+	todoService.SetTitleEdits(&MemoryTitleEditRepo{}, hub)
+	links := &LinkPages{}
+	todoService.SetLinkPreviews(services.NewLinkPreviewService(links, &MemoryLinkPreviewCache{}, LinkPreviewTTL, clock.Now))
+	quotas := services.NewQuotaService(users, todos, services.Limits{MaxTodos: MaxTodos})
+	rateService := services.NewRateService(ratePlans, now, clock)
+	bookingService := services.NewBookingService(bookings, rooms, guests, rateService, outbox, now, clock)
+	notificationService := services.NewNotificationService(notifications, clock.Now, clock)
+	bookingService.Subscribe(services.NewHousekeeping(todoService, rooms, notificationService, Housekeepers).HandleBookingEvent)
+	listService := services.NewListService(lists, todos, outbox, notificationService, clock.Now, clock)
+	reviewService := services.NewReviewService(reviews, bookings, time.Minute, now, clock)
+	notifier := &RecordingWaitlistNotifier{}
+	var waitlistNotifier services.WaitlistNotifier = notifier
+	if cfg.Mocks != nil {
+		waitlistNotifier = mock.WaitlistNotifier{Recorder: cfg.Mocks}
+	}
+	waitlist := services.NewWaitlistService(&MemoryWaitlistRepo{}, bookingService, waitlistNotifier, WaitlistHold, clock.Now, clock)
+	bookingService.Subscribe(waitlist.HandleBookingEvent)
+	templates, err := services.LoadMailTemplates("")
+	if err != nil {
+		panic(err)
+	}
+	mailbox := &RecordingMailer{}
+	var sender mailer.Mailer = mailbox
+	if cfg.Mocks != nil {
+		sender = mock.Mailer{Recorder: cfg.Mocks}
+	}
+	bookingMailer := services.NewBookingMailer(NewMemoryMailRepo(), sender, deadLetters, bookings, rooms, templates, services.BookingMailerConfig{
+		ReminderDays: 3,
+		BaseURL:      "https://hotel.test/",
+		OptOutSecret: "opt-out-secret",
+	}, now)
+	bookingMailer.SetNotifications(notificationService)
+	bookingService.Subscribe(bookingMailer.HandleBookingEvent)
+	deadLetterService := services.NewDeadLetterService(deadLetters, clock.Now)
+	deadLetterService.Handle(services.DeadLetterEvent, relay.Redeliver)
+	deadLetterService.Handle(services.DeadLetterEmail, bookingMailer.Redeliver)
+
+	var attachmentScanner scanner.Scanner
+	if opts.Scanner != nil {
+		attachmentScanner = opts.Scanner
+	}
+	attachmentService := services.NewAttachmentService(todos, attachments, attachmentScanner, services.AttachmentConfig{
+		MaxBytes:  AttachmentMaxBytes,
+		URLSecret: AttachmentURLSecret,
+		URLTTL:    AttachmentURLTTL,
+		BaseURL:   "https://hotel.test/",
+	}, clock.Now, clock)
+
+	jobs := scheduler.New(nil, nil, "test-1", clock.Now)
+	for _, err := range []error{
+		jobs.Add(services.JobReminders, "0 * * * *", bookingMailer.SendReminders),
+		jobs.Add(services.JobTodoDigest, "0 8 * * *", services.NewTodoDigest(todos, bookingMailer, clock.Now).Send),
+		jobs.Add(services.JobTrashPurge, "30 3 * * *", func(ctx context.Context) error {
+			return todoService.PurgeTrash(ctx, TrashRetention)
+		}),
+		jobs.Add(services.JobRecurringTodos, "*/5 * * * *", todoService.MaterializeRecurring),
+		jobs.Add(services.JobAttachmentScan, "*/10 * * * *", attachmentService.ScanPending),
+		jobs.Add(services.JobTodoArchive, "0 4 * * *", func(ctx context.Context) error {
+			return todoService.ArchiveCompleted(ctx, ArchiveAfter)
+		}),
+	} {
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	sessionService := services.NewSessionService(sessions, logins, users, outbox, time.Hour, ImpersonationTTL, now, clock)
+	passkeyService := services.NewPasskeyService(passkeys, &MemoryCeremonyRepo{}, users, webauthn.Config{
+		RPID:    RPID,
+		RPName:  "Hotel",
+		Origins: []string{Origin},
+	}, clock.Now, clock)
+
+	merges := &MemoryAccountMergeRepo{
+		users: users, todos: todos, sessions: sessions, logins: logins, passkeys: passkeys, bookings: bookings,
+		comments: comments, attachments: attachments, notifications: notifications, lists: lists,
+	}
+
+	ssoService := services.NewSSOService(properties, &MemorySSOStateRepo{}, users, outbox, nil, SSORedirectURL, clock.Now, clock)
+
+	dashboard := opts.Dashboard
+	if dashboard == nil {
+		dashboard = &MemoryDashboardRepo{users: users, todos: todos, outbox: outbox}
+	}
+
+	var backups services.BackupRepository
+	var memoryBackups *MemoryBackupRepo
+	if memoryTodos != nil {
+		memoryBackups = &MemoryBackupRepo{users: users, todos: memoryTodos}
+		backups = memoryBackups
+	}
+
+	userService := services.NewUserService(users, outbox, clock.Now)
+	captchaGuard := services.NewCaptchaGuard(captchaProvider, &MemoryLoginFailureRepo{}, CaptchaFailures, 15*time.Minute, clock.Now)
+	router := handlers.SetupRouter(handlers.Handlers{
+		Auth:        handlers.NewAuthHandler(userService, sessionService, captchaGuard),
+		Todos:       handlers.NewTodoHandler(todoService, quotas, listService),
+		Rooms:       handlers.NewRoomHandler(services.NewRoomService(rooms, reviewService, now, clock)),
+		Bookings:    handlers.NewBookingHandler(bookingService),
+		Guests:      handlers.NewGuestHandler(services.NewGuestService(guests, bookings, now, clock)),
+		Rates:       handlers.NewRateHandler(rateService),
+		Payments:    handlers.NewPaymentHandler(services.NewPaymentService(NewMemoryPaymentRepo(), bookings, now, clock), WebhookSecret),
+		Reviews:     handlers.NewReviewHandler(reviewService),
+		Reports:     handlers.NewReportHandler(services.NewReportService(bookings, rooms), services.NewTodoSummaryService(todos, clock.Now)),
+		Properties:  handlers.NewPropertyHandler(services.NewPropertyService(properties, users, now, clock)),
+		Waitlist:    handlers.NewWaitlistHandler(waitlist),
+		Mail:        handlers.NewMailHandler(bookingMailer),
+		Imports:     handlers.NewImportHandler(services.NewImportService(&MemoryImportRunRepo{}, bookingService, rooms, now, clock)),
+		Jobs:        handlers.NewJobHandler(jobs),
+		DeadLetters: handlers.NewDeadLetterHandler(deadLetterService),
+		Quotas:      handlers.NewQuotaHandler(quotas),
+		Privacy: handlers.NewPrivacyHandler(services.NewPrivacyService(&MemoryPrivacyRepo{
+			users: users, todos: todos, sessions: sessions, passkeys: passkeys, bookings: bookings, reviews: reviews, outbox: outbox,
+			comments: comments, notifications: notifications, lists: lists, attachments: attachments,
+		}, &MemoryErasureRepo{}, users, todos, bookings, logins, outbox, clock.Now, clock), listService),
+		Passkeys:      handlers.NewPasskeyHandler(passkeyService, sessionService),
+		SSO:           handlers.NewSSOHandler(ssoService, sessionService),
+		SCIM:          handlers.NewSCIMHandler(services.NewSCIMService(properties, users, outbox, clock.Now)),
+		Dashboard:     handlers.NewDashboardHandler(services.NewDashboardService(dashboard, clock.Now)),
+		Backups:       handlers.NewBackupHandler(services.NewBackupService(backups, clock.Now)),
+		Comments:      handlers.NewCommentHandler(services.NewCommentService(todos, comments, notifications, outbox, bookingMailer, clock.Now, clock)),
+		Attachments:   handlers.NewAttachmentHandler(attachmentService),
+		Notifications: handlers.NewNotificationHandler(notificationService),
+		Approvals:     handlers.NewApprovalHandler(services.NewApprovalService(todos, comments, outbox, clock.Now, clock)),
+		Lists:         handlers.NewListHandler(listService, todoService),
+		CalDAV:        handlers.NewCalDAVHandler(services.NewCalDAVService(todoService, quotas), userService, captchaGuard),
+		Feeds:         handlers.NewFeedHandler(services.NewTodoFeedService(users, todoService, listService, clock.Now), "https://hotel.test/"),
+		TodoImports:   handlers.NewTodoImportHandler(services.NewTodoImportService(todoService, listService, quotas)),
+		Stats:         handlers.NewStatsHandler(services.NewTodoStatsService(&MemoryTodoStatsRepo{todos: todos}, time.Minute, clock.Now)),
+		Audit:         handlers.NewAuditHandler(services.NewAuditService(&MemoryAuditRepo{outbox: outbox})),
+		Merges:        handlers.NewAccountMergeHandler(services.NewAccountMergeService(merges, users, sessionService, outbox, clock.Now)),
+		EmailChanges:  handlers.NewEmailChangeHandler(services.NewEmailChangeService(users, merges, bookingMailer, outbox, clock.Now)),
+		Sync:          handlers.NewSyncHandler(services.NewTodoSyncService(&MemorySyncOpRepo{}, todoService, listService, quotas, clock.Now, clock)),
+		Realtime:      handlers.NewRealtimeHandler(hub, services.NewRealtimeTicketService(tickets, clock.Now, clock), services.NewPresenceService(&MemoryPresenceRepo{}, listService, hub, clock.Now), activityFeed),
+	}, cfg)
+
+	return &App{
+		Router:      router,
+		Users:       users,
+		Todos:       memoryTodos,
+		Rooms:       rooms,
+		Bookings:    bookings,
+		Reviews:     reviews,
+		Waitlist:    waitlist,
+		Notifier:    notifier,
+		Clock:       clock,
+		Mailer:      bookingMailer,
+		Mailbox:     mailbox,
+		Events:      published,
+		Outbox:      outbox,
+		Relay:       relay,
+		Jobs:        jobs,
+		DeadLetters: deadLetters,
+		Captcha:     captchaProvider,
+		Realtime:    hub,
+		Activity:    activity,
+		Links:       links,
+		Backups:     memoryBackups,
+	}
+}
+
+// CaptchaToken is the only token Captcha accepts once enabled;
+// logins need it after CaptchaFailures failed attempts.
+const (
+	CaptchaToken    = "captcha-ok"
+	CaptchaFailures = 3
+)
+
+// RPID and Origin identify the site to the simulated passkey
+// authenticators.
+const (
+	RPID   = "localhost"
+	Origin = "http://localhost:3000"
+)
+
+// AdminToken unlocks the admin and testing endpoints of apps configured
+// with it.
+const AdminToken = "admin-secret"
+
+// MaxTodos is the plan limit of todos per account in tests.
+const MaxTodos = 100
+
+// ImpersonationTTL is how long the test impersonation tokens last.
+const ImpersonationTTL = 15 * time.Minute
+
+// TrashRetention is how long the test todos stay in the trash.
+const TrashRetention = 7 * 24 * time.Hour
+
+// ArchiveAfter is how long after completed the test todos move to the
+// archive.
+const ArchiveAfter = 90 * 24 * time.Hour
+
+// ActivityMaxEvents is how many entries the test activity feed keeps.
+const ActivityMaxEvents = 50
+
+// WaitlistHold is how long the test waitlist holds a freed room.
+const WaitlistHold = 2 * time.Hour
+
+// SSORedirectURL is the frontend page the identity providers send the
+// browser back to in tests.
+const SSORedirectURL = "https://hotel.test/sso/callback"
+
+// AttachmentMaxBytes, AttachmentURLSecret and AttachmentURLTTL configure
+// the todo attachments in tests.
+const (
+	AttachmentMaxBytes  = 1 << 10
+	AttachmentURLSecret = "attachment-secret"
+	AttachmentURLTTL    = 15 * time.Minute
+)
+
+// LinkPreviewTTL is how long the previews of linked pages are cached in
+// tests.
+const LinkPreviewTTL = 24 * time.Hour
+
+// WebhookSecret signs the payment provider notifications sent by tests.
+const WebhookSecret = "webhook-secret"
+
+// Housekeepers receive the cleaning todos created on check-out.
+var Housekeepers = []string{"limpieza1@hotel.com", "limpieza2@hotel.com"}
+
+// FixedTime is when every App clock starts.
+var FixedTime = time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC)
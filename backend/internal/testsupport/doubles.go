@@ -0,0 +1,158 @@
+package testsupport
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/captcha"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/linkpreview"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scanner"
+)
+
+// Captcha stands in for the CAPTCHA provider. It accepts everything
+// until a test enables it; then only CaptchaToken passes.
+type Captcha struct {
+	mu      sync.Mutex
+	enabled bool
+	down    bool
+}
+
+func (t *Captcha) Enable() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enabled = true
+}
+
+// SetDown makes the enabled provider unreachable, or reachable again.
+func (t *Captcha) SetDown(down bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.down = down
+}
+
+func (t *Captcha) Verify(_ context.Context, token, _ string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case !t.enabled:
+		return nil
+	case t.down:
+		return errors.New("captcha provider unreachable")
+	case token == CaptchaToken:
+		return nil
+	}
+	return captcha.ErrRejected
+}
+
+// EICAR is the standard antivirus test file, which Scanner blocks.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// Scanner stands in for the attachment scanner: it blocks the files that
+// contain EICAR and fails while down.
+type Scanner struct {
+	mu   sync.Mutex
+	down bool
+}
+
+// SetDown makes the scanner unreachable, or reachable again.
+func (s *Scanner) SetDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *Scanner) Scan(_ context.Context, _ string, data []byte) (scanner.Verdict, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.down:
+		return scanner.Verdict{}, errors.New("scanner unreachable")
+	case strings.Contains(string(data), EICAR):
+		return scanner.Verdict{Threat: "Eicar-Test-Signature"}, nil
+	}
+	return scanner.Verdict{Clean: true}, nil
+}
+
+// LinkPages stands in for the web pages linked from todos: it serves the
+// previews of the pages a test publishes and counts the fetches.
+type LinkPages struct {
+	mu      sync.Mutex
+	pages   map[string]linkpreview.Preview
+	fetches map[string]int
+}
+
+// Publish makes url answer with preview.
+func (l *LinkPages) Publish(url string, preview linkpreview.Preview) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.pages == nil {
+		l.pages = map[string]linkpreview.Preview{}
+	}
+	preview.URL = url
+	l.pages[url] = preview
+}
+
+// Fetches returns how many times url was fetched.
+func (l *LinkPages) Fetches(url string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fetches[url]
+}
+
+func (l *LinkPages) Fetch(_ context.Context, url string) (linkpreview.Preview, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fetches == nil {
+		l.fetches = map[string]int{}
+	}
+	l.fetches[url]++
+	preview, ok := l.pages[url]
+	if !ok {
+		return linkpreview.Preview{}, errors.New("page not found")
+	}
+	return preview, nil
+}
+
+// Clock starts at FixedTime and only moves when advanced.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+	ids uint64
+}
+
+// NewClock returns a Clock stopped at now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewID issues sequential IDs stamped with the current test time, so the
+// IDs of a test are the same on every run.
+func (c *Clock) NewID() primitive.ObjectID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids++
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[:4], uint32(c.now.Unix()))
+	binary.BigEndian.PutUint64(id[4:], c.ids)
+	return id
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
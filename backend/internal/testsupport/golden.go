@@ -0,0 +1,45 @@
+package testsupport
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files checked by AssertGolden")
+
+// AssertGolden compares a JSON response body with testdata/golden/name.json
+// in the package under test. The request ID of the envelope is dropped
+// first, since it changes on every run; IDs and times come from the App
+// clock and are stable. Run the tests with -update to rewrite the files
+// after an intended change and review the diff.
+func AssertGolden(t testing.TB, name string, body []byte) {
+	t.Helper()
+	var value interface{}
+	require.NoError(t, json.Unmarshal(body, &value), string(body))
+	if envelope, ok := value.(map[string]interface{}); ok {
+		if meta, ok := envelope["meta"].(map[string]interface{}); ok {
+			delete(meta, "requestId")
+		}
+	}
+	var normalized bytes.Buffer
+	encoder := json.NewEncoder(&normalized)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	require.NoError(t, encoder.Encode(value))
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, normalized.Bytes(), 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "run the tests with -update to create %s", path)
+	require.JSONEq(t, string(expected), normalized.String(), "golden file %s", path)
+}
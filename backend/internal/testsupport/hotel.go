@@ -0,0 +1,735 @@
+package testsupport
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+type MemoryRoomRepo struct {
+	mu    sync.Mutex
+	rooms map[primitive.ObjectID]services.Room
+}
+
+func NewMemoryRoomRepo() *MemoryRoomRepo {
+	return &MemoryRoomRepo{rooms: make(map[primitive.ObjectID]services.Room)}
+}
+
+func (m *MemoryRoomRepo) List(_ context.Context, query services.RoomQuery) ([]services.Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []services.Room
+	for _, room := range m.rooms {
+		propertyMatches := query.PropertyID.IsZero() || sameProperty(room.PropertyID, &query.PropertyID)
+		numberMatches := query.Number == "" || room.Number == query.Number
+		if (query.Type == "" || room.Type == query.Type) && (query.Status == "" || room.Status == query.Status) && propertyMatches && numberMatches {
+			result = append(result, room)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+	return result, nil
+}
+
+func (m *MemoryRoomRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return services.Room{}, services.ErrNotFound
+	}
+	return room, nil
+}
+
+// numberTaken mimics the unique index on the property and room number.
+func (m *MemoryRoomRepo) numberTaken(propertyID *primitive.ObjectID, number string, except primitive.ObjectID) bool {
+	for id, room := range m.rooms {
+		if id != except && room.Number == number && sameProperty(room.PropertyID, propertyID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryRoomRepo) Create(_ context.Context, room services.Room) (services.Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.numberTaken(room.PropertyID, room.Number, primitive.NilObjectID) {
+		return services.Room{}, services.ErrRoomNumberTaken
+	}
+	if room.ID.IsZero() {
+		room.ID = primitive.NewObjectID()
+	}
+	m.rooms[room.ID] = room
+	return room, nil
+}
+
+func (m *MemoryRoomRepo) Update(_ context.Context, id primitive.ObjectID, update services.RoomUpdate) (services.Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return services.Room{}, services.ErrNotFound
+	}
+	if update.Number != nil {
+		if m.numberTaken(room.PropertyID, *update.Number, id) {
+			return services.Room{}, services.ErrRoomNumberTaken
+		}
+		room.Number = *update.Number
+	}
+	if update.Type != nil {
+		room.Type = *update.Type
+	}
+	if update.Capacity != nil {
+		room.Capacity = *update.Capacity
+	}
+	if update.Price != nil {
+		room.Price = *update.Price
+	}
+	if update.Amenities != nil {
+		room.Amenities = *update.Amenities
+	}
+	if update.Status != nil {
+		room.Status = *update.Status
+	}
+	room.UpdatedAt = update.UpdatedAt
+	m.rooms[id] = room
+	return room, nil
+}
+
+func (m *MemoryRoomRepo) Delete(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[id]; !ok {
+		return services.ErrNotFound
+	}
+	delete(m.rooms, id)
+	return nil
+}
+
+type MemoryBookingRepo struct {
+	mu       sync.Mutex
+	bookings map[primitive.ObjectID]services.Booking
+	rooms    *MemoryRoomRepo
+}
+
+func NewMemoryBookingRepo(rooms *MemoryRoomRepo) *MemoryBookingRepo {
+	return &MemoryBookingRepo{bookings: make(map[primitive.ObjectID]services.Booking), rooms: rooms}
+}
+
+func (m *MemoryBookingRepo) List(_ context.Context, query services.BookingQuery) ([]services.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []services.Booking
+	for _, booking := range m.bookings {
+		guestMatches := query.GuestID.IsZero() || (booking.GuestID != nil && *booking.GuestID == query.GuestID)
+		stayMatches := query.StayFrom.IsZero() || query.StayTo.IsZero() ||
+			(booking.CheckIn.Before(query.StayTo) && booking.CheckOut.After(query.StayFrom))
+		propertyMatches := query.PropertyID.IsZero() || sameProperty(booking.PropertyID, &query.PropertyID)
+		refMatches := query.ExternalRef == "" || (booking.Channel == query.Channel && booking.ExternalRef == query.ExternalRef)
+		if (query.RoomID.IsZero() || booking.RoomID == query.RoomID) && (query.Email == "" || booking.Email == query.Email) && guestMatches && stayMatches && propertyMatches && refMatches {
+			result = append(result, booking)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CheckIn.Equal(result[j].CheckIn) {
+			return result[i].CheckIn.Before(result[j].CheckIn)
+		}
+		return result[i].ID.Hex() < result[j].ID.Hex()
+	})
+	return result, nil
+}
+
+func (m *MemoryBookingRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	booking, ok := m.bookings[id]
+	if !ok {
+		return services.Booking{}, services.ErrNotFound
+	}
+	return booking, nil
+}
+
+// occupied reports whether an active booking other than except holds the room
+// for some night in [checkIn, checkOut).
+func (m *MemoryBookingRepo) occupied(roomID primitive.ObjectID, checkIn, checkOut time.Time, except primitive.ObjectID) bool {
+	for id, booking := range m.bookings {
+		active := booking.Status == services.BookingHeld || booking.Status == services.BookingBooked || booking.Status == services.BookingCheckedIn
+		if id != except && booking.RoomID == roomID && active &&
+			booking.CheckIn.Before(checkOut) && booking.CheckOut.After(checkIn) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryBookingRepo) reserve(booking services.Booking) error {
+	if _, err := m.rooms.FindByID(context.Background(), booking.RoomID); err != nil {
+		return services.ErrBookingRoomNotFound
+	}
+	if m.occupied(booking.RoomID, booking.CheckIn, booking.CheckOut, booking.ID) {
+		return services.ErrBookingOverlap
+	}
+	return nil
+}
+
+func (m *MemoryBookingRepo) Create(_ context.Context, booking services.Booking) (services.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.reserve(booking); err != nil {
+		return services.Booking{}, err
+	}
+	for _, existing := range m.bookings {
+		if booking.ExternalRef != "" && existing.Channel == booking.Channel && existing.ExternalRef == booking.ExternalRef {
+			return services.Booking{}, services.ErrDuplicateExternalRef
+		}
+	}
+	if booking.ID.IsZero() {
+		booking.ID = primitive.NewObjectID()
+	}
+	m.bookings[booking.ID] = booking
+	return booking, nil
+}
+
+func (m *MemoryBookingRepo) Update(_ context.Context, booking services.Booking) (services.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.bookings[booking.ID]
+	if !ok || current.Status != services.BookingBooked {
+		return services.Booking{}, services.ErrBookingStateConflict
+	}
+	if err := m.reserve(booking); err != nil {
+		return services.Booking{}, err
+	}
+	current.RoomID = booking.RoomID
+	current.Guests = booking.Guests
+	current.CheckIn = booking.CheckIn
+	current.CheckOut = booking.CheckOut
+	current.Quote = booking.Quote
+	current.UpdatedAt = booking.UpdatedAt
+	m.bookings[booking.ID] = current
+	return current, nil
+}
+
+func (m *MemoryBookingRepo) Transition(_ context.Context, id primitive.ObjectID, from, to string, at time.Time) (services.Booking, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	booking, ok := m.bookings[id]
+	if !ok {
+		return services.Booking{}, services.ErrNotFound
+	}
+	if booking.Status != from {
+		return services.Booking{}, services.ErrBookingStateConflict
+	}
+	booking.Status = to
+	booking.UpdatedAt = at
+	switch to {
+	case services.BookingCancelled:
+		booking.CancelledAt = &at
+	case services.BookingCheckedIn:
+		booking.CheckedInAt = &at
+	case services.BookingCheckedOut:
+		booking.CheckedOutAt = &at
+	}
+	m.bookings[id] = booking
+	return booking, nil
+}
+
+func (m *MemoryBookingRepo) Available(ctx context.Context, propertyID primitive.ObjectID, checkIn, checkOut time.Time) ([]services.Room, error) {
+	rooms, err := m.rooms.List(ctx, services.RoomQuery{PropertyID: propertyID})
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var free []services.Room
+	for _, room := range rooms {
+		if !m.occupied(room.ID, checkIn, checkOut, primitive.NilObjectID) {
+			free = append(free, room)
+		}
+	}
+	return free, nil
+}
+
+type MemoryGuestRepo struct {
+	mu     sync.Mutex
+	guests map[primitive.ObjectID]services.Guest
+}
+
+func NewMemoryGuestRepo() *MemoryGuestRepo {
+	return &MemoryGuestRepo{guests: make(map[primitive.ObjectID]services.Guest)}
+}
+
+func (m *MemoryGuestRepo) Search(_ context.Context, search string) ([]services.Guest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result []services.Guest
+	for _, guest := range m.guests {
+		if search == "" || strings.Contains(strings.ToLower(guest.Name), strings.ToLower(search)) ||
+			guest.Document == services.NormalizeDocument(search) {
+			result = append(result, guest)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *MemoryGuestRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Guest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	guest, ok := m.guests[id]
+	if !ok {
+		return services.Guest{}, services.ErrNotFound
+	}
+	return guest, nil
+}
+
+// documentTaken mimics the unique index on the guest document.
+func (m *MemoryGuestRepo) documentTaken(document string, except primitive.ObjectID) bool {
+	for id, guest := range m.guests {
+		if id != except && guest.Document == document {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryGuestRepo) Create(_ context.Context, guest services.Guest) (services.Guest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.documentTaken(guest.Document, primitive.NilObjectID) {
+		return services.Guest{}, services.ErrGuestDocumentTaken
+	}
+	if guest.ID.IsZero() {
+		guest.ID = primitive.NewObjectID()
+	}
+	m.guests[guest.ID] = guest
+	return guest, nil
+}
+
+func (m *MemoryGuestRepo) Update(_ context.Context, id primitive.ObjectID, update services.GuestUpdate) (services.Guest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	guest, ok := m.guests[id]
+	if !ok {
+		return services.Guest{}, services.ErrNotFound
+	}
+	if update.Document != nil {
+		if m.documentTaken(*update.Document, id) {
+			return services.Guest{}, services.ErrGuestDocumentTaken
+		}
+		guest.Document = *update.Document
+	}
+	if update.Name != nil {
+		guest.Name = *update.Name
+	}
+	if update.Email != nil {
+		guest.Email = *update.Email
+	}
+	if update.Phone != nil {
+		guest.Phone = *update.Phone
+	}
+	if update.Preferences != nil {
+		guest.Preferences = *update.Preferences
+	}
+	guest.UpdatedAt = update.UpdatedAt
+	m.guests[id] = guest
+	return guest, nil
+}
+
+func (m *MemoryGuestRepo) Delete(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.guests[id]; !ok {
+		return services.ErrNotFound
+	}
+	delete(m.guests, id)
+	return nil
+}
+
+type MemoryRatePlanRepo struct {
+	mu    sync.Mutex
+	plans map[primitive.ObjectID]services.RatePlan
+}
+
+func NewMemoryRatePlanRepo() *MemoryRatePlanRepo {
+	return &MemoryRatePlanRepo{plans: make(map[primitive.ObjectID]services.RatePlan)}
+}
+
+func (m *MemoryRatePlanRepo) List(_ context.Context) ([]services.RatePlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	plans := make([]services.RatePlan, 0, len(m.plans))
+	for _, plan := range m.plans {
+		plans = append(plans, plan)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].Priority != plans[j].Priority {
+			return plans[i].Priority > plans[j].Priority
+		}
+		return plans[i].ID.Hex() < plans[j].ID.Hex()
+	})
+	return plans, nil
+}
+
+func (m *MemoryRatePlanRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.RatePlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	plan, ok := m.plans[id]
+	if !ok {
+		return services.RatePlan{}, services.ErrNotFound
+	}
+	return plan, nil
+}
+
+func (m *MemoryRatePlanRepo) Create(_ context.Context, plan services.RatePlan) (services.RatePlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if plan.ID.IsZero() {
+		plan.ID = primitive.NewObjectID()
+	}
+	m.plans[plan.ID] = plan
+	return plan, nil
+}
+
+func (m *MemoryRatePlanRepo) Replace(_ context.Context, plan services.RatePlan) (services.RatePlan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.plans[plan.ID]; !ok {
+		return services.RatePlan{}, services.ErrNotFound
+	}
+	m.plans[plan.ID] = plan
+	return plan, nil
+}
+
+func (m *MemoryRatePlanRepo) Delete(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.plans[id]; !ok {
+		return services.ErrNotFound
+	}
+	delete(m.plans, id)
+	return nil
+}
+
+type MemoryPaymentRepo struct {
+	mu       sync.Mutex
+	payments map[primitive.ObjectID]services.Payment
+	events   map[string]bool
+}
+
+func NewMemoryPaymentRepo() *MemoryPaymentRepo {
+	return &MemoryPaymentRepo{
+		payments: make(map[primitive.ObjectID]services.Payment),
+		events:   make(map[string]bool),
+	}
+}
+
+func (m *MemoryPaymentRepo) ListByBooking(_ context.Context, bookingID primitive.ObjectID) ([]services.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var payments []services.Payment
+	for _, payment := range m.payments {
+		if payment.BookingID == bookingID {
+			payments = append(payments, payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].ID.Hex() < payments[j].ID.Hex()
+	})
+	return payments, nil
+}
+
+func (m *MemoryPaymentRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	payment, ok := m.payments[id]
+	if !ok {
+		return services.Payment{}, services.ErrNotFound
+	}
+	return payment, nil
+}
+
+func (m *MemoryPaymentRepo) Create(_ context.Context, payment services.Payment) (services.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if payment.ID.IsZero() {
+		payment.ID = primitive.NewObjectID()
+	}
+	m.payments[payment.ID] = payment
+	return payment, nil
+}
+
+func (m *MemoryPaymentRepo) Apply(_ context.Context, event services.PaymentEvent, from []string, providerRef string) (services.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.events[event.EventID] {
+		return services.Payment{}, services.ErrDuplicatePaymentEvent
+	}
+	payment, ok := m.payments[event.PaymentID]
+	if !ok || !slices.Contains(from, payment.Status) {
+		return services.Payment{}, services.ErrPaymentStateConflict
+	}
+
+	m.events[event.EventID] = true
+	payment.Status = event.Status
+	payment.UpdatedAt = event.ReceivedAt
+	if providerRef != "" {
+		payment.ProviderRef = providerRef
+	}
+	m.payments[payment.ID] = payment
+	return payment, nil
+}
+
+type MemoryReviewRepo struct {
+	mu      sync.Mutex
+	reviews []services.Review
+	// ratingCalls counts the aggregations, to observe the rating cache.
+	ratingCalls int
+}
+
+// All returns a copy of the stored reviews, oldest first.
+func (m *MemoryReviewRepo) All() []services.Review {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.reviews)
+}
+
+// RatingCalls counts the rating aggregations so far.
+func (m *MemoryReviewRepo) RatingCalls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ratingCalls
+}
+
+func (m *MemoryReviewRepo) List(_ context.Context, query services.ReviewQuery) ([]services.Review, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reviews []services.Review
+	for i := len(m.reviews) - 1; i >= 0; i-- {
+		if m.reviews[i].RoomID == query.RoomID {
+			reviews = append(reviews, m.reviews[i])
+		}
+	}
+	start := min(query.Offset, len(reviews))
+	end := len(reviews)
+	if query.Limit > 0 {
+		end = min(start+query.Limit, end)
+	}
+	return reviews[start:end], nil
+}
+
+func (m *MemoryReviewRepo) Count(_ context.Context, query services.ReviewQuery) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, review := range m.reviews {
+		if review.RoomID == query.RoomID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryReviewRepo) Create(_ context.Context, review services.Review) (services.Review, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.reviews {
+		if existing.BookingID == review.BookingID {
+			return services.Review{}, services.ErrReviewExists
+		}
+	}
+	if review.ID.IsZero() {
+		review.ID = primitive.NewObjectID()
+	}
+	m.reviews = append(m.reviews, review)
+	return review, nil
+}
+
+func (m *MemoryReviewRepo) Ratings(_ context.Context, roomIDs []primitive.ObjectID) (map[primitive.ObjectID]services.RoomRating, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ratingCalls++
+	sums := make(map[primitive.ObjectID]int)
+	ratings := make(map[primitive.ObjectID]services.RoomRating)
+	for _, review := range m.reviews {
+		if !slices.Contains(roomIDs, review.RoomID) {
+			continue
+		}
+		sums[review.RoomID] += review.Rating
+		rating := ratings[review.RoomID]
+		rating.Count++
+		rating.Average = float64(sums[review.RoomID]) / float64(rating.Count)
+		ratings[review.RoomID] = rating
+	}
+	return ratings, nil
+}
+
+type MemoryWaitlistRepo struct {
+	mu      sync.Mutex
+	entries []services.WaitlistEntry
+}
+
+func (m *MemoryWaitlistRepo) List(_ context.Context, query services.WaitlistQuery) ([]services.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var entries []services.WaitlistEntry
+	for _, entry := range m.entries {
+		propertyMatches := query.PropertyID.IsZero() || sameProperty(entry.PropertyID, &query.PropertyID)
+		holdMatches := query.HoldBefore.IsZero() || (entry.HoldUntil != nil && !entry.HoldUntil.After(query.HoldBefore))
+		if propertyMatches && holdMatches && (query.Status == "" || entry.Status == query.Status) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryWaitlistRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return services.WaitlistEntry{}, services.ErrNotFound
+}
+
+func (m *MemoryWaitlistRepo) Create(_ context.Context, entry services.WaitlistEntry) (services.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	m.entries = append(m.entries, entry)
+	return entry, nil
+}
+
+func (m *MemoryWaitlistRepo) Transition(_ context.Context, id primitive.ObjectID, from string, update services.WaitlistUpdate) (services.WaitlistEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, entry := range m.entries {
+		if entry.ID != id {
+			continue
+		}
+		if entry.Status != from {
+			return services.WaitlistEntry{}, services.ErrWaitlistStateConflict
+		}
+		entry.Status = update.Status
+		entry.UpdatedAt = update.UpdatedAt
+		if update.BookingID != nil {
+			entry.BookingID = update.BookingID
+		}
+		if update.HoldUntil != nil {
+			entry.HoldUntil = update.HoldUntil
+		}
+		m.entries[i] = entry
+		return entry, nil
+	}
+	return services.WaitlistEntry{}, services.ErrNotFound
+}
+
+// RecordingWaitlistNotifier keeps the entries the waitlist offered a room to.
+type RecordingWaitlistNotifier struct {
+	mu      sync.Mutex
+	offered []services.WaitlistEntry
+}
+
+func (r *RecordingWaitlistNotifier) NotifyWaitlist(_ context.Context, entry services.WaitlistEntry, _ services.Booking) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offered = append(r.offered, entry)
+	return nil
+}
+
+func (r *RecordingWaitlistNotifier) Emails() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	emails := make([]string, 0, len(r.offered))
+	for _, entry := range r.offered {
+		emails = append(emails, entry.Email)
+	}
+	return emails
+}
+
+// MemoryImportRunRepo keeps import runs in memory. Runs are stored by
+// value so the background import and the tests never share a slice.
+type MemoryImportRunRepo struct {
+	mu   sync.Mutex
+	runs []services.ImportRun
+}
+
+func (m *MemoryImportRunRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.ImportRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, run := range m.runs {
+		if run.ID == id {
+			return run, nil
+		}
+	}
+	return services.ImportRun{}, services.ErrNotFound
+}
+
+func (m *MemoryImportRunRepo) Create(_ context.Context, run services.ImportRun) (services.ImportRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	m.runs = append(m.runs, run)
+	return run, nil
+}
+
+func (m *MemoryImportRunRepo) Save(_ context.Context, run services.ImportRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.runs {
+		if m.runs[i].ID == run.ID {
+			run.Results = append([]services.ImportResult(nil), run.Results...)
+			m.runs[i] = run
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
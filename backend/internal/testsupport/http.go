@@ -0,0 +1,114 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// PerformRequest sends body (JSON-encoded when not nil) through the router.
+func PerformRequest(router http.Handler, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// Do sends a request through the app router; see PerformRequest.
+func (a *App) Do(method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	return PerformRequest(a.Router, method, path, body, headers)
+}
+
+// Register signs email up with the password "secret".
+func (a *App) Register(t testing.TB, email string) {
+	t.Helper()
+	rec := a.Do(http.MethodPost, "/register", map[string]string{"email": email, "password": "secret"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+}
+
+// LoginAs creates a user with role straight in the repository and returns
+// the Authorization header of a fresh session.
+func (a *App) LoginAs(t testing.TB, email, role string) map[string]string {
+	t.Helper()
+	require.NoError(t, a.Users.Insert(context.Background(), services.User{Email: email, Password: "secret", Role: role}))
+
+	rec := a.Do(http.MethodPost, "/login", map[string]string{"email": email, "password": "secret"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body map[string]string
+	DecodeData(t, rec.Body.Bytes(), &body)
+	require.NotEmpty(t, body["token"])
+	require.Equal(t, role, body["role"])
+	return Bearer(body["token"])
+}
+
+// StaffHeaders signs in as a manager on first use and returns the headers
+// for staff-only endpoints. Call it before spawning goroutines.
+func (a *App) StaffHeaders(t testing.TB) map[string]string {
+	t.Helper()
+	if a.staff == nil {
+		a.staff = a.LoginAs(t, "gerencia@hotel.com", services.RoleManager)
+	}
+	return a.staff
+}
+
+// Bearer returns the Authorization header of a session token.
+func Bearer(token string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + token}
+}
+
+// DecodeData unmarshals the data member of the envelope of a successful
+// response into out.
+func DecodeData(t testing.TB, body []byte, out interface{}) {
+	t.Helper()
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	require.NotEmpty(t, envelope.Data, string(body))
+	require.NoError(t, json.Unmarshal(envelope.Data, out))
+}
+
+// DataJSON returns the data member of the envelope of a successful response
+// as JSON text.
+func DataJSON(t testing.TB, body []byte) string {
+	t.Helper()
+	var data json.RawMessage
+	DecodeData(t, body, &data)
+	return string(data)
+}
+
+// DecodeMeta returns the meta member of the envelope of a successful
+// response.
+func DecodeMeta(t testing.TB, body []byte) respond.Meta {
+	t.Helper()
+	var envelope struct {
+		Meta respond.Meta `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	return envelope.Meta
+}
@@ -0,0 +1,110 @@
+package testsupport
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// IdP is a fake OpenID Connect provider: it publishes its discovery
+// document and RS256 key, and exchanges the codes registered with Grant
+// for ID tokens.
+type IdP struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key    *rsa.PrivateKey
+	mu     sync.Mutex
+	grants map[string]map[string]any
+}
+
+// NewIdP starts a provider for the client ClientID/ClientSecret; close it
+// with Close.
+func NewIdP() *IdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	idp := &IdP{ClientID: "hotel-app", ClientSecret: "idp-secret", key: key, grants: make(map[string]map[string]any)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		e := big.NewInt(int64(key.PublicKey.E)).Bytes()
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(e),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+// Grant registers code as signing in email with nonce, the one in the
+// authorization URL. extra overrides or adds claims of the ID token.
+func (p *IdP) Grant(code, email, nonce string, extra map[string]any) {
+	claims := map[string]any{
+		"iss":            p.URL,
+		"sub":            "sub-" + email,
+		"aud":            p.ClientID,
+		"exp":            FixedTime.Add(time.Hour).Unix(),
+		"iat":            FixedTime.Unix(),
+		"nonce":          nonce,
+		"email":          email,
+		"email_verified": true,
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	p.mu.Lock()
+	p.grants[code] = claims
+	p.mu.Unlock()
+}
+
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	p.mu.Lock()
+	claims, ok := p.grants[r.FormValue("code")]
+	delete(p.grants, r.FormValue("code"))
+	p.mu.Unlock()
+	if !ok || id != p.ClientID || secret != p.ClientSecret {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	writeJSON(w, map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": p.sign(claims)})
+}
+
+func (p *IdP) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,385 @@
+package testsupport
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+type MemoryMailRepo struct {
+	mu      sync.Mutex
+	sent    map[string]bool
+	optOuts map[string]bool
+}
+
+func NewMemoryMailRepo() *MemoryMailRepo {
+	return &MemoryMailRepo{sent: make(map[string]bool), optOuts: make(map[string]bool)}
+}
+
+func (m *MemoryMailRepo) MarkSent(_ context.Context, key string, _ time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sent[key] {
+		return false, nil
+	}
+	m.sent[key] = true
+	return true, nil
+}
+
+func (m *MemoryMailRepo) Forget(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sent, key)
+	return nil
+}
+
+func (m *MemoryMailRepo) OptedOut(_ context.Context, email string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.optOuts[email], nil
+}
+
+func (m *MemoryMailRepo) OptOut(_ context.Context, email string, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.optOuts[email] = true
+	return nil
+}
+
+// RecordingEvents keeps the domain events published on the test bus.
+type RecordingEvents struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *RecordingEvents) record(_ context.Context, event events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// ofType returns the published events of eventType in order.
+func (r *RecordingEvents) OfType(eventType string) []events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []events.Event
+	for _, event := range r.events {
+		if event.Type == eventType {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
+
+// MemoryOutbox stores the outbox messages in memory. Atomically only keeps
+// the events of changes that succeeded, like the Mongo transaction.
+type MemoryOutbox struct {
+	mu       sync.Mutex
+	messages []services.OutboxMessage
+}
+
+func (m *MemoryOutbox) Atomically(ctx context.Context, change func(ctx context.Context) ([]events.Event, error)) error {
+	pending, err := change(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, event := range pending {
+		m.messages = append(m.messages, services.NewOutboxMessage(event, services.ActorFrom(ctx), FixedTime))
+	}
+	return nil
+}
+
+func (m *MemoryOutbox) Claim(_ context.Context, now time.Time, lease time.Duration, limit int) ([]services.OutboxMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var claimed []services.OutboxMessage
+	for i := range m.messages {
+		msg := &m.messages[i]
+		if len(claimed) == limit {
+			break
+		}
+		if msg.Status == services.OutboxPending && !msg.NextAttemptAt.After(now) {
+			msg.NextAttemptAt = now.Add(lease)
+			claimed = append(claimed, *msg)
+		}
+	}
+	return claimed, nil
+}
+
+func (m *MemoryOutbox) MarkPublished(_ context.Context, id string, at time.Time) error {
+	return m.update(id, func(msg *services.OutboxMessage) {
+		msg.Status, msg.PublishedAt, msg.LastError = services.OutboxPublished, &at, ""
+		msg.Attempts++
+	})
+}
+
+func (m *MemoryOutbox) MarkFailed(_ context.Context, id string, next time.Time, reason string) error {
+	return m.update(id, func(msg *services.OutboxMessage) {
+		msg.NextAttemptAt, msg.LastError = next, reason
+		msg.Attempts++
+	})
+}
+
+func (m *MemoryOutbox) MarkDead(_ context.Context, id string, reason string) error {
+	return m.update(id, func(msg *services.OutboxMessage) {
+		msg.Status, msg.LastError = services.OutboxDead, reason
+		msg.Attempts++
+	})
+}
+
+func (m *MemoryOutbox) update(id string, fn func(*services.OutboxMessage)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.messages {
+		if m.messages[i].ID == id {
+			fn(&m.messages[i])
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
+
+// ofType returns the stored messages of eventType in order.
+func (m *MemoryOutbox) OfType(eventType string) []services.OutboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []services.OutboxMessage
+	for _, msg := range m.messages {
+		if msg.Event.Type == eventType {
+			matched = append(matched, msg)
+		}
+	}
+	return matched
+}
+
+// WithKey returns the stored messages keyed by key in order, like the
+// activity of a todo.
+func (m *MemoryOutbox) WithKey(key string) []services.OutboxMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var matched []services.OutboxMessage
+	for _, msg := range m.messages {
+		if msg.Event.Key == key {
+			matched = append(matched, msg)
+		}
+	}
+	return matched
+}
+
+// RecordingMailer keeps every email instead of sending it.
+type RecordingMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+	// failure, when set, is returned instead of sending.
+	failure error
+}
+
+func (r *RecordingMailer) Send(_ context.Context, msg mailer.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failure != nil {
+		return r.failure
+	}
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *RecordingMailer) Fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failure = err
+}
+
+func (r *RecordingMailer) Sent() []mailer.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.messages)
+}
+
+// MemoryDeadLetterRepo keeps dead letters in insertion order.
+type MemoryDeadLetterRepo struct {
+	mu      sync.Mutex
+	letters []services.DeadLetter
+}
+
+func (m *MemoryDeadLetterRepo) matching(query services.DeadLetterQuery) []services.DeadLetter {
+	var letters []services.DeadLetter
+	for _, letter := range m.letters {
+		if (query.Kind == "" || letter.Kind == query.Kind) && (query.Status == "" || letter.Status == query.Status) {
+			letters = append(letters, letter)
+		}
+	}
+	return letters
+}
+
+func (m *MemoryDeadLetterRepo) List(_ context.Context, query services.DeadLetterQuery) ([]services.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	letters := m.matching(query)
+	if query.Offset >= len(letters) {
+		return nil, nil
+	}
+	letters = letters[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(letters) {
+		letters = letters[:query.Limit]
+	}
+	return letters, nil
+}
+
+func (m *MemoryDeadLetterRepo) Count(_ context.Context, query services.DeadLetterQuery) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.matching(query))), nil
+}
+
+func (m *MemoryDeadLetterRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, letter := range m.letters {
+		if letter.ID == id {
+			return letter, nil
+		}
+	}
+	return services.DeadLetter{}, services.ErrNotFound
+}
+
+func (m *MemoryDeadLetterRepo) Create(_ context.Context, letter services.DeadLetter) (services.DeadLetter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	letter.ID = primitive.NewObjectID()
+	m.letters = append(m.letters, letter)
+	return letter, nil
+}
+
+func (m *MemoryDeadLetterRepo) Save(_ context.Context, letter services.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.letters {
+		if m.letters[i].ID == letter.ID {
+			m.letters[i] = letter
+			return nil
+		}
+	}
+	return services.ErrNotFound
+}
+
+// all returns a copy of the stored dead letters.
+func (m *MemoryDeadLetterRepo) All() []services.DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.letters)
+}
+
+// MemoryAuditRepo reads the audit trail from a MemoryOutbox like
+// MongoAuditRepository does from the outbox collection.
+type MemoryAuditRepo struct {
+	outbox *MemoryOutbox
+}
+
+func (m *MemoryAuditRepo) Find(_ context.Context, filter services.AuditFilter) ([]services.OutboxMessage, error) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	var matched []services.OutboxMessage
+	for _, msg := range m.outbox.messages {
+		at := msg.Event.Time
+		switch {
+		case filter.Actor != "" && msg.Actor != filter.Actor,
+			filter.Type != "" && msg.Event.Type != filter.Type,
+			filter.Resource != "" && msg.Event.Key != filter.Resource,
+			!filter.From.IsZero() && at.Before(filter.From),
+			!filter.To.IsZero() && !at.Before(filter.To),
+			filter.After != nil && (at.After(filter.After.Time) || at.Equal(filter.After.Time) && msg.ID >= filter.After.ID):
+			continue
+		}
+		matched = append(matched, msg)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].Event.Time.Equal(matched[j].Event.Time) {
+			return matched[i].Event.Time.After(matched[j].Event.Time)
+		}
+		return matched[i].ID > matched[j].ID
+	})
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+	return matched, nil
+}
+
+// MemoryActivityRepo keeps the last Max entries of the activity feed
+// (all of them when Max is zero), like the capped collection.
+type MemoryActivityRepo struct {
+	Max int
+
+	mu      sync.Mutex
+	entries []services.ActivityEntry
+	// appended is closed on the next Append, waking up the tails.
+	appended chan struct{}
+}
+
+func (m *MemoryActivityRepo) Append(_ context.Context, entry services.ActivityEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+	if m.Max > 0 && len(m.entries) > m.Max {
+		m.entries = slices.Delete(m.entries, 0, len(m.entries)-m.Max)
+	}
+	if m.appended != nil {
+		close(m.appended)
+		m.appended = nil
+	}
+	return nil
+}
+
+func (m *MemoryActivityRepo) Tail(ctx context.Context, email string, after primitive.ObjectID, fn func(services.ActivityEntry) error) error {
+	m.mu.Lock()
+	if after.IsZero() && len(m.entries) > 0 {
+		after = m.entries[len(m.entries)-1].ID
+	}
+	m.mu.Unlock()
+	for {
+		m.mu.Lock()
+		var pending []services.ActivityEntry
+		for _, entry := range m.entries {
+			if bytes.Compare(entry.ID[:], after[:]) > 0 && slices.Contains(entry.Users, email) {
+				pending = append(pending, entry)
+			}
+		}
+		if len(m.entries) > 0 {
+			after = m.entries[len(m.entries)-1].ID
+		}
+		if m.appended == nil {
+			m.appended = make(chan struct{})
+		}
+		appended := m.appended
+		m.mu.Unlock()
+
+		for _, entry := range pending {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-appended:
+		}
+	}
+}
+
+// Len returns how many entries the feed holds.
+func (m *MemoryActivityRepo) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
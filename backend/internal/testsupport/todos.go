@@ -0,0 +1,909 @@
+package testsupport
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+type MemoryTodoRepo struct {
+	mu    sync.Mutex
+	todos map[primitive.ObjectID]services.Todo
+	// archive holds the todos Archive moved out of todos.
+	archive map[primitive.ObjectID]services.Todo
+	// users, when set, resolves the email of a query to the account that
+	// owns its todos, like MongoTodoRepository.
+	users *MemoryUserRepo
+}
+
+func NewMemoryTodoRepo() *MemoryTodoRepo {
+	return &MemoryTodoRepo{todos: make(map[primitive.ObjectID]services.Todo), archive: make(map[primitive.ObjectID]services.Todo)}
+}
+
+// stores returns the maps query looks into.
+func (m *MemoryTodoRepo) stores(includeArchived bool) []map[primitive.ObjectID]services.Todo {
+	if includeArchived {
+		return []map[primitive.ObjectID]services.Todo{m.todos, m.archive}
+	}
+	return []map[primitive.ObjectID]services.Todo{m.todos}
+}
+
+// owner returns the ID of the account of email, or nil when email has
+// none.
+func (m *MemoryTodoRepo) owner(email string) *primitive.ObjectID {
+	if m.users == nil || email == "" {
+		return nil
+	}
+	user, err := m.users.FindByEmail(context.Background(), email)
+	if err != nil {
+		return nil
+	}
+	return &user.ID
+}
+
+// owned tells whether todo belongs to email, whose account is owner: by
+// its user ID, or by its email when email has no account.
+func owned(todo services.Todo, email string, owner *primitive.ObjectID) bool {
+	if owner == nil {
+		return todo.Email == email
+	}
+	return todo.UserID != nil && *todo.UserID == *owner
+}
+
+// adopt hands user the todos created for its email before it had an
+// account.
+func (m *MemoryTodoRepo) adopt(user services.User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, store := range m.stores(true) {
+		for id, todo := range store {
+			if todo.Email == user.Email && todo.UserID == nil {
+				todo.UserID = &user.ID
+				store[id] = todo
+			}
+		}
+	}
+}
+
+func (m *MemoryTodoRepo) matching(query services.TodoQuery) []services.Todo {
+	owner := m.owner(query.Email)
+	todos := make([]services.Todo, 0, len(m.todos))
+	for _, store := range m.stores(query.IncludeArchived) {
+		for _, todo := range store {
+			if (query.Email == "" || owned(todo, query.Email, owner)) && m.matches(todo, query) {
+				todos = append(todos, todo)
+			}
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		if todos[i].CreatedAt.Equal(todos[j].CreatedAt) {
+			return todos[i].ID.Hex() < todos[j].ID.Hex()
+		}
+		return todos[i].CreatedAt.Before(todos[j].CreatedAt)
+	})
+	return todos
+}
+
+func (m *MemoryTodoRepo) matches(todo services.Todo, query services.TodoQuery) bool {
+	roomMatches := query.RoomID.IsZero() || (todo.RoomID != nil && *todo.RoomID == query.RoomID)
+	roomMatches = roomMatches && (!query.RoomsOnly || todo.RoomID != nil) &&
+		(query.PropertyID.IsZero() || sameProperty(todo.PropertyID, &query.PropertyID))
+	stateMatches := (todo.DeletedAt != nil) == query.Trashed && (!query.Open || !todo.Completed) &&
+		(query.Color == "" || todo.Color == query.Color) && (query.Icon == "" || todo.Icon == query.Icon) &&
+		(query.RecurringDue.IsZero() || (todo.NextOccurrence != nil && !todo.NextOccurrence.After(query.RecurringDue))) &&
+		(query.CalDAVName == "" || (todo.CalDAV != nil && todo.CalDAV.Name == query.CalDAVName)) &&
+		(query.ClientID == "" || todo.ClientID == query.ClientID)
+	idMatches := (query.ID.IsZero() || todo.ID == query.ID) &&
+		(query.ListID.IsZero() || (todo.ListID != nil && *todo.ListID == query.ListID))
+	return idMatches && roomMatches && stateMatches
+}
+
+func (m *MemoryTodoRepo) List(_ context.Context, query services.TodoQuery) ([]services.Todo, error) {
+	if !query.Bounded() {
+		return nil, services.ErrUnboundedQuery
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todos := m.matching(query)
+	if query.Offset >= len(todos) {
+		return []services.Todo{}, nil
+	}
+	todos = todos[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(todos) {
+		todos = todos[:query.Limit]
+	}
+	return todos, nil
+}
+
+func (m *MemoryTodoRepo) Count(_ context.Context, query services.TodoQuery) (int64, error) {
+	if !query.Bounded() {
+		return 0, services.ErrUnboundedQuery
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return int64(len(m.matching(query))), nil
+}
+
+func (m *MemoryTodoRepo) Create(_ context.Context, todo services.Todo) (services.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if todo.ID.IsZero() {
+		todo.ID = primitive.NewObjectID()
+	}
+	if todo.UserID == nil {
+		todo.UserID = m.owner(todo.Email)
+	}
+	for _, existing := range m.todos {
+		if todo.ClientID != "" && existing.ClientID == todo.ClientID && existing.Email == todo.Email {
+			return services.Todo{}, services.ErrDuplicateClientID
+		}
+	}
+	m.todos[todo.ID] = todo
+	return todo, nil
+}
+
+func (m *MemoryTodoRepo) Update(_ context.Context, id primitive.ObjectID, update services.TodoUpdate) (services.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || todo.DeletedAt != nil {
+		return services.Todo{}, services.ErrNotFound
+	}
+	if update.TitleVersion != nil && *update.TitleVersion != todo.TitleVersion {
+		return services.Todo{}, services.ErrTitleVersionConflict
+	}
+
+	if update.Title != nil {
+		todo.Title = *update.Title
+		todo.TitleVersion++
+	}
+	if update.Color != nil {
+		todo.Color = *update.Color
+	}
+	if update.Icon != nil {
+		todo.Icon = *update.Icon
+	}
+	if update.Assignee != nil {
+		todo.Assignee = *update.Assignee
+	}
+	if update.Completed != nil {
+		todo.Completed, todo.CompletedAt = *update.Completed, nil
+		if *update.Completed {
+			completedAt := update.CompletedAt
+			todo.CompletedAt = &completedAt
+		}
+	}
+	if update.EndRecurrence {
+		todo.Recurrence, todo.NextOccurrence = "", nil
+	}
+	if update.Previews != nil {
+		todo.Previews = *update.Previews
+		if len(todo.Previews) == 0 {
+			todo.Previews = nil
+		}
+	}
+
+	m.todos[id] = todo
+	return todo, nil
+}
+
+func (m *MemoryTodoRepo) Trash(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || todo.DeletedAt != nil {
+		return services.ErrNotFound
+	}
+	todo.DeletedAt = &at
+	m.todos[id] = todo
+	return nil
+}
+
+func (m *MemoryTodoRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || todo.DeletedAt != nil {
+		return services.Todo{}, services.ErrNotFound
+	}
+	return todo, nil
+}
+
+func (m *MemoryTodoRepo) Restore(_ context.Context, id primitive.ObjectID) (services.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || todo.DeletedAt == nil {
+		return services.Todo{}, services.ErrNotFound
+	}
+	todo.DeletedAt = nil
+	m.todos[id] = todo
+	return todo, nil
+}
+
+func (m *MemoryTodoRepo) CompleteAll(_ context.Context, email string, update services.TodoUpdate) ([]services.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var changed []services.Todo
+	for _, todo := range m.matching(services.TodoQuery{Email: email}) {
+		if todo.Completed == *update.Completed {
+			continue
+		}
+		todo.Completed, todo.CompletedAt = *update.Completed, nil
+		if todo.Completed {
+			completedAt := update.CompletedAt
+			todo.CompletedAt = &completedAt
+		}
+		m.todos[todo.ID] = todo
+		changed = append(changed, todo)
+	}
+	return changed, nil
+}
+
+func (m *MemoryTodoRepo) React(_ context.Context, id primitive.ObjectID, reaction services.TodoReaction, add bool) (services.Todo, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || todo.DeletedAt != nil {
+		return services.Todo{}, false, services.ErrNotFound
+	}
+	index := slices.Index(todo.Reactions, reaction)
+	switch {
+	case add && index < 0:
+		todo.Reactions = append(slices.Clone(todo.Reactions), reaction)
+	case !add && index >= 0:
+		todo.Reactions = slices.Delete(slices.Clone(todo.Reactions), index, index+1)
+	default:
+		return todo, false, nil
+	}
+	m.todos[id] = todo
+	return todo, true, nil
+}
+
+func (m *MemoryTodoRepo) TransitionApproval(_ context.Context, id primitive.ObjectID, from, to string, at time.Time) (services.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	todo, ok := m.todos[id]
+	if !ok || todo.DeletedAt != nil {
+		return services.Todo{}, services.ErrNotFound
+	}
+	if todo.Approval != from {
+		return services.Todo{}, services.ErrApprovalStateConflict
+	}
+	todo.Approval = to
+	if to == services.ApprovalApproved {
+		todo.Completed, todo.CompletedAt = true, &at
+	}
+	m.todos[id] = todo
+	return todo, nil
+}
+
+// forget removes the reactions and the assignments of email from
+// every todo, and takes out of lists the todos of the deleted lists.
+func (m *MemoryTodoRepo) forget(email string, lists []primitive.ObjectID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, store := range m.stores(true) {
+		for id, todo := range store {
+			todo.Reactions = slices.DeleteFunc(slices.Clone(todo.Reactions), func(r services.TodoReaction) bool { return r.Email == email })
+			if todo.Assignee == email {
+				todo.Assignee = ""
+			}
+			if todo.ListID != nil && slices.Contains(lists, *todo.ListID) {
+				todo.ListID = nil
+			}
+			store[id] = todo
+		}
+	}
+}
+
+// rename replaces email with alias as owner, assignee and reactor, and
+// returns how many todos it owned.
+func (m *MemoryTodoRepo) rename(email, alias string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var owned int64
+	for _, store := range m.stores(true) {
+		for id, todo := range store {
+			if todo.Email == email {
+				todo.Email = alias
+				owned++
+			}
+			if todo.Assignee == email {
+				todo.Assignee = alias
+			}
+			todo.Reactions = slices.Clone(todo.Reactions)
+			for i := range todo.Reactions {
+				if todo.Reactions[i].Email == email {
+					todo.Reactions[i].Email = alias
+				}
+			}
+			store[id] = todo
+		}
+	}
+	return owned
+}
+
+func (m *MemoryTodoRepo) TrashCompleted(_ context.Context, email string, at time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var trashed int64
+	for _, todo := range m.matching(services.TodoQuery{Email: email}) {
+		if todo.Completed {
+			todo.DeletedAt = &at
+			m.todos[todo.ID] = todo
+			trashed++
+		}
+	}
+	return trashed, nil
+}
+
+func (m *MemoryTodoRepo) Purge(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for id, todo := range m.todos {
+		if todo.DeletedAt != nil && todo.DeletedAt.Before(before) {
+			delete(m.todos, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MemoryTodoRepo) Clear(_ context.Context, email string, all bool) error {
+	if email == "" && !all {
+		return services.ErrUnboundedQuery
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner := m.owner(email)
+	for _, store := range m.stores(true) {
+		for id, todo := range store {
+			if email == "" || owned(todo, email, owner) {
+				delete(store, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Archive moves the live todos completed before before to the archive.
+func (m *MemoryTodoRepo) Archive(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var archived int64
+	for id, todo := range m.todos {
+		if todo.Completed && todo.DeletedAt == nil && todo.CompletedAt != nil && todo.CompletedAt.Before(before) {
+			m.archive[id] = todo
+			delete(m.todos, id)
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+// MemoryCommentRepo keeps todo comments in insertion order.
+type MemoryCommentRepo struct {
+	mu       sync.Mutex
+	comments []services.TodoComment
+}
+
+func (m *MemoryCommentRepo) List(_ context.Context, todoID primitive.ObjectID) ([]services.TodoComment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var comments []services.TodoComment
+	for _, comment := range m.comments {
+		if comment.TodoID == todoID {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (m *MemoryCommentRepo) Create(_ context.Context, comment services.TodoComment) (services.TodoComment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	m.comments = append(m.comments, comment)
+	return comment, nil
+}
+
+// forget deletes the comments of email and those on todos, and removes
+// email from the mentions of the rest.
+func (m *MemoryCommentRepo) forget(email string, todos []primitive.ObjectID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.comments = slices.DeleteFunc(m.comments, func(comment services.TodoComment) bool {
+		return comment.Email == email || slices.Contains(todos, comment.TodoID)
+	})
+	for i, comment := range m.comments {
+		m.comments[i].Mentions = slices.DeleteFunc(slices.Clone(comment.Mentions), func(mention string) bool { return mention == email })
+	}
+}
+
+func (m *MemoryCommentRepo) rename(email, alias string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, comment := range m.comments {
+		if comment.Email == email {
+			m.comments[i].Email = alias
+		}
+		mentions := slices.Clone(comment.Mentions)
+		for j := range mentions {
+			if mentions[j] == email {
+				mentions[j] = alias
+			}
+		}
+		m.comments[i].Mentions = mentions
+	}
+}
+
+// MemoryAttachmentRepo keeps todo attachments in insertion order.
+type MemoryAttachmentRepo struct {
+	mu          sync.Mutex
+	attachments []services.Attachment
+}
+
+func (m *MemoryAttachmentRepo) Create(_ context.Context, attachment services.Attachment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attachments = append(m.attachments, attachment)
+	return nil
+}
+
+func (m *MemoryAttachmentRepo) List(_ context.Context, todoID primitive.ObjectID) ([]services.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var attachments []services.Attachment
+	for _, attachment := range m.attachments {
+		if attachment.TodoID == todoID {
+			attachment.Data = nil
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func (m *MemoryAttachmentRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, attachment := range m.attachments {
+		if attachment.ID == id {
+			return attachment, nil
+		}
+	}
+	return services.Attachment{}, services.ErrNotFound
+}
+
+func (m *MemoryAttachmentRepo) Delete(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.attachments, func(a services.Attachment) bool { return a.ID == id })
+	if i < 0 {
+		return services.ErrNotFound
+	}
+	m.attachments = slices.Delete(m.attachments, i, i+1)
+	return nil
+}
+
+func (m *MemoryAttachmentRepo) SetScan(_ context.Context, id primitive.ObjectID, scan, threat string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.attachments, func(a services.Attachment) bool { return a.ID == id })
+	if i < 0 {
+		return services.ErrNotFound
+	}
+	m.attachments[i].Scan = scan
+	m.attachments[i].Threat = threat
+	m.attachments[i].ScannedAt = &at
+	return nil
+}
+
+func (m *MemoryAttachmentRepo) ListPending(_ context.Context, before time.Time, limit int) ([]services.Attachment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var pending []services.Attachment
+	for _, attachment := range m.attachments {
+		if attachment.Scan == services.AttachmentPending && attachment.CreatedAt.Before(before) && len(pending) < limit {
+			pending = append(pending, attachment)
+		}
+	}
+	return pending, nil
+}
+
+// forget deletes the attachments uploaded by email and those on todos.
+func (m *MemoryAttachmentRepo) forget(email string, todos []primitive.ObjectID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attachments = slices.DeleteFunc(m.attachments, func(a services.Attachment) bool {
+		return a.Email == email || slices.Contains(todos, a.TodoID)
+	})
+}
+
+func (m *MemoryAttachmentRepo) rename(email, alias string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.attachments {
+		if m.attachments[i].Email == email {
+			m.attachments[i].Email = alias
+		}
+	}
+}
+
+// MemoryLinkPreviewCache keeps the fetched previews by URL.
+type MemoryLinkPreviewCache struct {
+	mu       sync.Mutex
+	previews map[string]services.CachedLinkPreview
+}
+
+func (m *MemoryLinkPreviewCache) Find(_ context.Context, url string) (services.CachedLinkPreview, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cached, ok := m.previews[url]
+	if !ok {
+		return services.CachedLinkPreview{}, services.ErrNotFound
+	}
+	return cached, nil
+}
+
+func (m *MemoryLinkPreviewCache) Save(_ context.Context, preview services.CachedLinkPreview) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.previews == nil {
+		m.previews = map[string]services.CachedLinkPreview{}
+	}
+	m.previews[preview.URL] = preview
+	return nil
+}
+
+// MemoryNotificationRepo keeps the inbox in insertion order.
+type MemoryNotificationRepo struct {
+	mu            sync.Mutex
+	notifications []services.Notification
+}
+
+func (m *MemoryNotificationRepo) matching(query services.NotificationQuery) []services.Notification {
+	var notifications []services.Notification
+	for i := len(m.notifications) - 1; i >= 0; i-- {
+		notification := m.notifications[i]
+		if notification.Email == query.Email && (!query.Unread || notification.ReadAt == nil) {
+			notifications = append(notifications, notification)
+		}
+	}
+	return notifications
+}
+
+func (m *MemoryNotificationRepo) List(_ context.Context, query services.NotificationQuery) ([]services.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	notifications := m.matching(query)
+	if query.Offset >= len(notifications) {
+		return nil, nil
+	}
+	notifications = notifications[query.Offset:]
+	if query.Limit > 0 && query.Limit < len(notifications) {
+		notifications = notifications[:query.Limit]
+	}
+	return notifications, nil
+}
+
+func (m *MemoryNotificationRepo) Count(_ context.Context, query services.NotificationQuery) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.matching(query))), nil
+}
+
+func (m *MemoryNotificationRepo) Create(_ context.Context, notifications []services.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, notification := range notifications {
+		taken := notification.Key != "" && slices.ContainsFunc(m.notifications, func(n services.Notification) bool {
+			return n.Key == notification.Key
+		})
+		if !taken {
+			m.notifications = append(m.notifications, notification)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryNotificationRepo) MarkRead(_ context.Context, id primitive.ObjectID, email string, at time.Time) (services.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, notification := range m.notifications {
+		if notification.ID != id || notification.Email != email {
+			continue
+		}
+		if notification.ReadAt == nil {
+			m.notifications[i].ReadAt = &at
+		}
+		return m.notifications[i], nil
+	}
+	return services.Notification{}, services.ErrNotFound
+}
+
+func (m *MemoryNotificationRepo) MarkAllRead(_ context.Context, email string, at time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var marked int64
+	for i, notification := range m.notifications {
+		if notification.Email == email && notification.ReadAt == nil {
+			m.notifications[i].ReadAt = &at
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// forget deletes the notifications to or from email and those about todos.
+func (m *MemoryNotificationRepo) forget(email string, todos []primitive.ObjectID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notifications = slices.DeleteFunc(m.notifications, func(notification services.Notification) bool {
+		return notification.Email == email || notification.Author == email || slices.Contains(todos, notification.TodoID)
+	})
+}
+
+// MemoryListRepo is an in-memory ListRepository.
+type MemoryListRepo struct {
+	mu    sync.Mutex
+	lists []services.TodoList
+}
+
+func (m *MemoryListRepo) Create(_ context.Context, list services.TodoList) (services.TodoList, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list.Members = slices.Clone(list.Members)
+	m.lists = append(m.lists, list)
+	return list, nil
+}
+
+func (m *MemoryListRepo) FindByID(_ context.Context, id primitive.ObjectID) (services.TodoList, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, list := range m.lists {
+		if list.ID == id {
+			list.Members = slices.Clone(list.Members)
+			return list, nil
+		}
+	}
+	return services.TodoList{}, services.ErrNotFound
+}
+
+func (m *MemoryListRepo) ListFor(_ context.Context, email string) ([]services.TodoList, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var lists []services.TodoList
+	for _, list := range m.lists {
+		if slices.ContainsFunc(list.Members, func(member services.ListMember) bool { return member.Email == email }) {
+			list.Members = slices.Clone(list.Members)
+			lists = append(lists, list)
+		}
+	}
+	return lists, nil
+}
+
+func (m *MemoryListRepo) SetMembers(_ context.Context, id primitive.ObjectID, members []services.ListMember) (services.TodoList, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, list := range m.lists {
+		if list.ID == id {
+			m.lists[i].Members = slices.Clone(members)
+			list.Members = slices.Clone(members)
+			return list, nil
+		}
+	}
+	return services.TodoList{}, services.ErrNotFound
+}
+
+// forget takes email out of every list, deletes the lists left without
+// members and returns their IDs.
+func (m *MemoryListRepo) forget(email string) []primitive.ObjectID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var kept []services.TodoList
+	var emptied []primitive.ObjectID
+	for _, list := range m.lists {
+		list.Members = slices.DeleteFunc(slices.Clone(list.Members), func(member services.ListMember) bool { return member.Email == email })
+		if len(list.Members) == 0 {
+			emptied = append(emptied, list.ID)
+			continue
+		}
+		kept = append(kept, list)
+	}
+	m.lists = kept
+	return emptied
+}
+
+func (m *MemoryListRepo) rename(email, alias string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, list := range m.lists {
+		list.Members = slices.Clone(list.Members)
+		for j := range list.Members {
+			if list.Members[j].Email == email {
+				list.Members[j].Email = alias
+			}
+		}
+		m.lists[i] = list
+	}
+}
+
+// MemoryPresenceRepo is an in-memory PresenceRepository.
+type MemoryPresenceRepo struct {
+	mu      sync.Mutex
+	viewers []services.ListPresence
+}
+
+func (m *MemoryPresenceRepo) Touch(_ context.Context, listID primitive.ObjectID, email string, now, expiresAt time.Time) (services.ListPresence, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, viewer := range m.viewers {
+		if viewer.ListID == listID && viewer.Email == email {
+			joined := !viewer.ExpiresAt.After(now)
+			if joined {
+				m.viewers[i].Since = now
+			}
+			m.viewers[i].ExpiresAt = expiresAt
+			return m.viewers[i], joined, nil
+		}
+	}
+	viewer := services.ListPresence{ListID: listID, Email: email, Since: now, ExpiresAt: expiresAt}
+	m.viewers = append(m.viewers, viewer)
+	return viewer, true, nil
+}
+
+func (m *MemoryPresenceRepo) Remove(_ context.Context, listID primitive.ObjectID, email string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	present := false
+	m.viewers = slices.DeleteFunc(m.viewers, func(viewer services.ListPresence) bool {
+		if viewer.ListID != listID || viewer.Email != email {
+			return false
+		}
+		present = viewer.ExpiresAt.After(now)
+		return true
+	})
+	return present, nil
+}
+
+func (m *MemoryPresenceRepo) Viewers(_ context.Context, listID primitive.ObjectID, now time.Time) ([]services.ListPresence, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var viewers []services.ListPresence
+	for _, viewer := range m.viewers {
+		if viewer.ListID == listID && viewer.ExpiresAt.After(now) {
+			viewers = append(viewers, viewer)
+		}
+	}
+	sort.SliceStable(viewers, func(i, j int) bool {
+		if !viewers[i].Since.Equal(viewers[j].Since) {
+			return viewers[i].Since.Before(viewers[j].Since)
+		}
+		return viewers[i].Email < viewers[j].Email
+	})
+	return viewers, nil
+}
+
+// MemoryTitleEditRepo is an in-memory TitleEditRepository.
+type MemoryTitleEditRepo struct {
+	mu    sync.Mutex
+	edits []services.TitleEdit
+}
+
+func (m *MemoryTitleEditRepo) Append(_ context.Context, edit services.TitleEdit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.edits = append(m.edits, edit)
+	return nil
+}
+
+func (m *MemoryTitleEditRepo) Since(_ context.Context, todoID primitive.ObjectID, version int) ([]services.TitleEdit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var edits []services.TitleEdit
+	for _, edit := range m.edits {
+		if edit.TodoID == todoID && edit.Version > version {
+			edits = append(edits, edit)
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Version < edits[j].Version })
+	return edits, nil
+}
+
+// MemorySyncOpRepo is an in-memory SyncOpRepository.
+type MemorySyncOpRepo struct {
+	mu  sync.Mutex
+	ops []services.SyncOp
+}
+
+func (m *MemorySyncOpRepo) Claim(_ context.Context, op services.SyncOp, stale time.Time) (services.SyncOp, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.ops {
+		if existing.Email != op.Email || existing.OpID != op.OpID {
+			continue
+		}
+		if existing.Status == "running" && existing.At.Before(stale) {
+			m.ops[i].At = op.At
+			return m.ops[i], true, nil
+		}
+		return existing, false, nil
+	}
+	m.ops = append(m.ops, op)
+	return op, true, nil
+}
+
+func (m *MemorySyncOpRepo) Finish(_ context.Context, op services.SyncOp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.ops {
+		if existing.Email == op.Email && existing.OpID == op.OpID {
+			m.ops[i].Status, m.ops[i].At = op.Status, op.At
+			if op.TodoID != nil {
+				m.ops[i].TodoID = op.TodoID
+			}
+			if op.Reason != "" {
+				m.ops[i].Reason = op.Reason
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemorySyncOpRepo) Release(_ context.Context, email, opID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = slices.DeleteFunc(m.ops, func(op services.SyncOp) bool {
+		return op.Email == email && op.OpID == opID && op.Status == "running"
+	})
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package timing breaks down the time a request takes into phases, such as
+// the database calls and the serialization of the response, for the
+// Server-Timing debug header.
+package timing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phases recorded by the API.
+const (
+	// DB is the time spent in repository calls, retries included.
+	DB = "db"
+	// Serialize is the time spent encoding the response body.
+	Serialize = "serialize"
+	// App is the time from the start of the request to the first byte of
+	// the response.
+	App = "app"
+)
+
+// Header is the response header that carries the breakdown.
+const Header = "Server-Timing"
+
+// Recorder accumulates the time of each phase of one request. A nil
+// Recorder discards everything, so callers need not check for one.
+type Recorder struct {
+	start time.Time
+
+	mu     sync.Mutex
+	phases []string
+	totals map[string]time.Duration
+	counts map[string]int
+}
+
+// NewRecorder starts timing a request.
+func NewRecorder() *Recorder {
+	return &Recorder{start: time.Now(), totals: map[string]time.Duration{}, counts: map[string]int{}}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying r.
+func NewContext(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the Recorder of ctx, or nil when timing is off.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(contextKey{}).(*Recorder)
+	return r
+}
+
+// Add adds d to phase.
+func (r *Recorder) Add(phase string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, seen := r.totals[phase]; !seen {
+		r.phases = append(r.phases, phase)
+	}
+	r.totals[phase] += d
+	r.counts[phase]++
+}
+
+// Track starts timing phase for the Recorder of ctx; call the returned
+// function when the phase ends.
+func Track(ctx context.Context, phase string) func() {
+	r := FromContext(ctx)
+	if r == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { r.Add(phase, time.Since(start)) }
+}
+
+// Header formats the phases recorded so far plus App, in milliseconds,
+// e.g. `db;dur=1.52;desc="2", serialize;dur=0.31, app;dur=2.40`. The
+// description of a phase timed more than once is the number of times.
+func (r *Recorder) Header() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]string, 0, len(r.phases)+1)
+	for _, phase := range r.phases {
+		entry := fmt.Sprintf("%s;dur=%s", phase, millis(r.totals[phase]))
+		if r.counts[phase] > 1 {
+			entry += fmt.Sprintf(";desc=\"%d\"", r.counts[phase])
+		}
+		entries = append(entries, entry)
+	}
+	entries = append(entries, fmt.Sprintf("%s;dur=%s", App, millis(time.Since(r.start))))
+	return strings.Join(entries, ", ")
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.2f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,102 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/ugorji/go/codec"
+)
+
+// COSE algorithms accepted for passkeys, in order of preference.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// Algorithms lists the accepted COSE algorithms for the creation options.
+var Algorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9053).
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2 // also the RSA modulus n
+	coseY   = -3 // also the RSA exponent e
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+type publicKey struct {
+	alg    int
+	ecKey  *ecdsa.PublicKey
+	rsaKey *rsa.PublicKey
+	edKey  ed25519.PublicKey
+}
+
+// parsePublicKey decodes a COSE key of one of the accepted algorithms.
+func parsePublicKey(raw []byte) (publicKey, error) {
+	var params map[int]any
+	if err := codec.NewDecoderBytes(raw, new(codec.CborHandle)).Decode(&params); err != nil {
+		return publicKey{}, invalid("public key: %v", err)
+	}
+	kty, _ := coseInt(params[coseKty])
+	alg, _ := coseInt(params[coseAlg])
+	crv, _ := coseInt(params[coseCrv])
+	x, _ := params[coseX].([]byte)
+	y, _ := params[coseY].([]byte)
+
+	key := publicKey{alg: alg}
+	switch {
+	case kty == ktyEC2 && alg == AlgES256 && crv == crvP256 && len(x) == 32 && len(y) == 32:
+		key.ecKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.ecKey.Curve.IsOnCurve(key.ecKey.X, key.ecKey.Y) {
+			return publicKey{}, invalid("public key not on curve")
+		}
+	case kty == ktyOKP && alg == AlgEdDSA && crv == crvEd25519 && len(x) == ed25519.PublicKeySize:
+		key.edKey = ed25519.PublicKey(x)
+	case kty == ktyRSA && alg == AlgRS256 && len(x) > 0 && len(y) > 0 && len(y) <= 4:
+		key.rsaKey = &rsa.PublicKey{N: new(big.Int).SetBytes(x), E: int(new(big.Int).SetBytes(y).Int64())}
+	default:
+		return publicKey{}, invalid("unsupported public key (kty %d, alg %d)", kty, alg)
+	}
+	return key, nil
+}
+
+// verify checks signature over data with the algorithm of the key.
+func (k publicKey) verify(data, signature []byte) bool {
+	switch k.alg {
+	case AlgES256:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(k.ecKey, digest[:], signature)
+	case AlgEdDSA:
+		return ed25519.Verify(k.edKey, data, signature)
+	case AlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k.rsaKey, crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
+
+// coseInt reads an integer label or value, which the CBOR decoder returns
+// as int64 or uint64.
+func coseInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	}
+	return 0, false
+}
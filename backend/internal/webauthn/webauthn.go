@@ -0,0 +1,206 @@
+// Package webauthn verifies the responses of the WebAuthn (passkey)
+// registration and authentication ceremonies. It implements the relying
+// party checks of the W3C specification needed by the backend on top of the
+// standard library: client data, authenticator data and the ES256, RS256
+// and EdDSA signatures. Attestation statements are not verified, as the
+// ceremonies ask for "none" attestation.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ugorji/go/codec"
+)
+
+// ErrInvalidResponse wraps every verification failure.
+var ErrInvalidResponse = errors.New("invalid webauthn response")
+
+// Client data types of each ceremony.
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagAttestedData = 0x40
+)
+
+// Config identifies the relying party: RPID is the domain the passkeys are
+// bound to and Origins the web origins allowed to run the ceremonies.
+type Config struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// Credential is a public key credential created by an authenticator.
+// PublicKey holds the COSE encoded key.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+}
+
+// NewChallenge returns a random challenge for a ceremony.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// Encode returns data as unpadded base64url, the encoding WebAuthn uses
+// for binary fields in JSON.
+func Encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses base64url with or without padding.
+func Decode(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(string(bytes.TrimRight([]byte(value), "=")))
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidResponse, fmt.Sprintf(format, args...))
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// verifyClientData checks the type, challenge and origin signed by the
+// browser.
+func (c Config) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return invalid("client data: %v", err)
+	}
+	if data.Type != ceremony {
+		return invalid("client data type %q", data.Type)
+	}
+	got, err := Decode(data.Challenge)
+	if err != nil || !bytes.Equal(got, challenge) {
+		return invalid("challenge mismatch")
+	}
+	if !slices.Contains(c.Origins, data.Origin) {
+		return invalid("origin %q not allowed", data.Origin)
+	}
+	return nil
+}
+
+type authenticatorData struct {
+	flags      byte
+	signCount  uint32
+	credential Credential
+}
+
+// parseAuthenticatorData checks the RP ID hash and user presence and reads
+// the attested credential when present.
+func (c Config) parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < 37 {
+		return authenticatorData{}, invalid("authenticator data too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(raw[:32], rpIDHash[:]) {
+		return authenticatorData{}, invalid("rp id mismatch")
+	}
+	data := authenticatorData{flags: raw[32], signCount: binary.BigEndian.Uint32(raw[33:37])}
+	if data.flags&flagUserPresent == 0 {
+		return authenticatorData{}, invalid("user not present")
+	}
+	if data.flags&flagAttestedData == 0 {
+		return data, nil
+	}
+
+	// aaguid (16) | credential ID length (2) | credential ID | COSE key
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return authenticatorData{}, invalid("attested credential data too short")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return authenticatorData{}, invalid("credential id too short")
+	}
+	data.credential.ID = rest[:idLen]
+	rest = rest[idLen:]
+
+	var key map[int]any
+	decoder := codec.NewDecoderBytes(rest, new(codec.CborHandle))
+	if err := decoder.Decode(&key); err != nil {
+		return authenticatorData{}, invalid("credential public key: %v", err)
+	}
+	data.credential.PublicKey = rest[:decoder.NumBytesRead()]
+	if _, err := parsePublicKey(data.credential.PublicKey); err != nil {
+		return authenticatorData{}, err
+	}
+	data.credential.SignCount = data.signCount
+	return data, nil
+}
+
+type attestationObject struct {
+	Format   string `codec:"fmt"`
+	AuthData []byte `codec:"authData"`
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create
+// against challenge and returns the new credential.
+func (c Config) VerifyRegistration(challenge, clientDataJSON, rawAttestation []byte) (Credential, error) {
+	if err := c.verifyClientData(clientDataJSON, typeCreate, challenge); err != nil {
+		return Credential{}, err
+	}
+
+	var attestation attestationObject
+	if err := codec.NewDecoderBytes(rawAttestation, new(codec.CborHandle)).Decode(&attestation); err != nil {
+		return Credential{}, invalid("attestation object: %v", err)
+	}
+	data, err := c.parseAuthenticatorData(attestation.AuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if len(data.credential.ID) == 0 {
+		return Credential{}, invalid("no attested credential")
+	}
+	return data.credential, nil
+}
+
+// VerifyLogin checks the response of navigator.credentials.get against
+// challenge and the stored credential, and returns the new signature
+// counter. A counter that does not grow signals a cloned authenticator.
+func (c Config) VerifyLogin(challenge []byte, credential Credential, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	if err := c.verifyClientData(clientDataJSON, typeGet, challenge); err != nil {
+		return 0, err
+	}
+	data, err := c.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(slices.Clip(rawAuthData), clientDataHash[:]...)
+	if !key.verify(signed, signature) {
+		return 0, invalid("bad signature")
+	}
+
+	if (data.signCount != 0 || credential.SignCount != 0) && data.signCount <= credential.SignCount {
+		return 0, invalid("signature counter did not increase")
+	}
+	return data.signCount, nil
+}
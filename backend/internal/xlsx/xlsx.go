@@ -0,0 +1,272 @@
+// Package xlsx writes Excel workbooks (Office Open XML spreadsheets) on top
+// of the standard library. It covers what the exports need: several sheets
+// with a styled header, dates, booleans and a conditional format that
+// highlights rows. Strings are written inline, so the workbook has no
+// shared string table.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MIMEType is the content type of the workbooks.
+const MIMEType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// maxSheetName is the longest sheet name Excel accepts.
+const maxSheetName = 31
+
+// Column is a column of a sheet; Width is in characters, zero for the
+// default.
+type Column struct {
+	Header string
+	Width  float64
+}
+
+// Sheet is a worksheet. Each row holds strings, bools, ints, float64s,
+// time.Times (written as UTC dates) or nils for empty cells.
+//
+// Highlight is an Excel formula written for the first data row, with
+// absolute columns and relative rows (e.g. `$B2<TODAY()`); the rows where it
+// holds are filled in red.
+type Sheet struct {
+	Name      string
+	Columns   []Column
+	Rows      [][]any
+	Highlight string
+}
+
+// Style indexes of styles.xml.
+const (
+	styleDefault = 0
+	styleHeader  = 1
+	styleDate    = 2
+)
+
+// part is a file of the workbook package.
+type part struct {
+	name string
+	data string
+}
+
+// Write writes a workbook with sheets to w. Sheet names are made valid and
+// unique, as Excel refuses the workbook otherwise.
+func Write(w io.Writer, sheets []Sheet) error {
+	archive := zip.NewWriter(w)
+	names := sheetNames(sheets)
+	files := []part{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbook(names)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+		{"xl/styles.xml", styles},
+	}
+	for i, sheet := range sheets {
+		files = append(files, part{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(sheet)})
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// sheetNames strips the characters Excel forbids in sheet names, truncates
+// them and numbers repeated ones.
+func sheetNames(sheets []Sheet) []string {
+	names := make([]string, len(sheets))
+	used := map[string]bool{}
+	for i, sheet := range sheets {
+		base := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return ' '
+			}
+			return r
+		}, sheet.Name)
+		base = strings.Trim(strings.TrimSpace(base), "'")
+		if base == "" {
+			base = "Sheet" + strconv.Itoa(i+1)
+		}
+		name := truncate(base, maxSheetName)
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			suffix := " (" + strconv.Itoa(n) + ")"
+			name = truncate(base, maxSheetName-len(suffix)) + suffix
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func truncate(text string, runes int) string {
+	if utf8.RuneCountInString(text) <= runes {
+		return text
+	}
+	return string([]rune(text)[:runes])
+}
+
+// ColumnName returns the letters of the 0-based column index, e.g. "AA"
+// for 26.
+func ColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// excelEpoch is day zero of the serial dates of Excel, which counts the
+// nonexistent 29 February 1900; dates from March 1900 on come out right.
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+func serialDate(t time.Time) float64 {
+	return t.UTC().Sub(excelEpoch).Hours() / 24
+}
+
+func escape(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+func worksheet(sheet Sheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// The header stays visible while scrolling.
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	if len(sheet.Columns) > 0 {
+		b.WriteString("<cols>")
+		for i, column := range sheet.Columns {
+			if column.Width > 0 {
+				fmt.Fprintf(&b, `<col min="%d" max="%d" width="%s" customWidth="1"/>`, i+1, i+1, strconv.FormatFloat(column.Width, 'f', -1, 64))
+			}
+		}
+		b.WriteString("</cols>")
+	}
+
+	b.WriteString("<sheetData>")
+	b.WriteString(`<row r="1">`)
+	for i, column := range sheet.Columns {
+		writeCell(&b, ColumnName(i)+"1", column.Header, styleHeader)
+	}
+	b.WriteString("</row>")
+	for r, row := range sheet.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+2)
+		for i, value := range row {
+			writeCell(&b, ColumnName(i)+strconv.Itoa(r+2), value, styleDefault)
+		}
+		b.WriteString("</row>")
+	}
+	b.WriteString("</sheetData>")
+
+	if sheet.Highlight != "" && len(sheet.Rows) > 0 {
+		lastColumn := ColumnName(max(len(sheet.Columns), 1) - 1)
+		lastRow := len(sheet.Rows) + 1
+		fmt.Fprintf(&b, `<conditionalFormatting sqref="A2:%s%d"><cfRule type="expression" dxfId="0" priority="1"><formula>%s</formula></cfRule></conditionalFormatting>`,
+			lastColumn, lastRow, escape(sheet.Highlight))
+	}
+	b.WriteString("</worksheet>")
+	return b.String()
+}
+
+func writeCell(b *strings.Builder, ref string, value any, style int) {
+	styleAttr := ""
+	if style != styleDefault {
+		styleAttr = fmt.Sprintf(` s="%d"`, style)
+	}
+	switch v := value.(type) {
+	case nil:
+		if style != styleDefault {
+			fmt.Fprintf(b, `<c r="%s"%s/>`, ref, styleAttr)
+		}
+	case string:
+		fmt.Fprintf(b, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, ref, styleAttr, escape(v))
+	case bool:
+		flag := "0"
+		if v {
+			flag = "1"
+		}
+		fmt.Fprintf(b, `<c r="%s" t="b"%s><v>%s</v></c>`, ref, styleAttr, flag)
+	case int:
+		fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+	case int64:
+		fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, styleAttr, v)
+	case float64:
+		fmt.Fprintf(b, `<c r="%s"%s><v>%s</v></c>`, ref, styleAttr, strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, styleDate, strconv.FormatFloat(serialDate(v), 'f', -1, 64))
+	default:
+		fmt.Fprintf(b, `<c r="%s" t="inlineStr"%s><is><t>%s</t></is></c>`, ref, styleAttr, escape(fmt.Sprint(v)))
+	}
+}
+
+func contentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func workbook(names []string) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, name := range names {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+func workbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// styles defines the default cell, the bold shaded header and the date
+// cell, in that order, and the red fill of the highlighted rows (dxf 0).
+const styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill></fills>` +
+	`<borders count="2"><border><left/><right/><top/><bottom/><diagonal/></border>` +
+	`<border><left/><right/><top/><bottom style="thin"><color auto="1"/></bottom><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="1" xfId="0" applyFont="1" applyFill="1" applyBorder="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`<dxfs count="1"><dxf><font><color rgb="FF9C0006"/></font><fill><patternFill><bgColor rgb="FFFFC7CE"/></patternFill></fill></dxf></dxfs>` +
+	`</styleSheet>`
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// runLoadgen implements `app loadgen`: it writes synthetic users and todos
+// straight through the MongoDB repositories, to benchmark index and
+// pagination changes against production-like volumes.
+func runLoadgen(ctx context.Context, cfg config.Config, args []string) {
+	gen := services.LoadGenConfig{}
+	flags := flag.NewFlagSet("loadgen", flag.ExitOnError)
+	flags.IntVar(&gen.Users, "users", 1000, "cantidad de usuarios")
+	flags.IntVar(&gen.Todos, "todos", 100000, "cantidad de tareas")
+	flags.StringVar(&gen.Distribution, "distribution", services.DistributionZipf, "reparto de las tareas entre los usuarios: uniform o zipf")
+	flags.Float64Var(&gen.Completed, "completed", 0.6, "fraccion de tareas completadas")
+	flags.Float64Var(&gen.Recurring, "recurring", 0.05, "fraccion de tareas recurrentes")
+	flags.Float64Var(&gen.Trashed, "trashed", 0.02, "fraccion de tareas en la papelera")
+	flags.IntVar(&gen.Days, "days", 365, "dias hacia atras en los que se reparten las altas")
+	flags.StringVar(&gen.Domain, "domain", "loadgen.test", "dominio de los emails generados")
+	flags.Uint64Var(&gen.Seed, "seed", 1, "semilla; la misma semilla genera los mismos datos")
+	flags.IntVar(&gen.Workers, "workers", 8, "escrituras simultaneas")
+	_ = flags.Parse(args)
+
+	client, db := openDatabase(ctx, cfg)
+	defer func() {
+		_ = client.Disconnect(context.Background())
+	}()
+
+	users := services.NewMongoUserRepository(db.Collection("users"), db.Collection("todos"))
+	if err := users.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de usuarios: %v", err)
+	}
+	todos := services.NewMongoTodoRepository(db.Collection("todos"), db.Collection("users"))
+	if err := todos.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de tareas: %v", err)
+	}
+
+	started := time.Now()
+	stats, err := services.NewLoadGenerator(users, todos, time.Now).Run(ctx, gen)
+	log.Printf("loadgen: %d usuarios y %d tareas escritos en %s", stats.Users, stats.Todos, time.Since(started).Round(time.Millisecond))
+	if err != nil {
+		log.Printf("loadgen: %v", err)
+		os.Exit(1)
+	}
+}
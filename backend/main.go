@@ -4,52 +4,575 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/captcha"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
 	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/linkpreview"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mock"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scanner"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/secrets"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/server"
 	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/webauthn"
 )
 
 func main() {
 	ctx := context.Background()
-
-	mongoURI := os.Getenv("MONGO_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
+	cfg := config.Load()
+	if cfg.Secrets.Provider != "" {
+		cfg = loadSecrets(ctx, cfg)
 	}
 
-	dbName := os.Getenv("MONGO_DB")
-	if dbName == "" {
-		dbName = services.DefaultDatabaseName
+	if len(os.Args) > 1 && os.Args[1] == "loadgen" {
+		runLoadgen(ctx, cfg, os.Args[2:])
+		return
 	}
-
-	client, err := services.ConnectMongo(ctx, mongoURI)
-	if err != nil {
-		log.Fatalf("no se pudo conectar a MongoDB: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "snapshot" {
+		runSnapshot(ctx, cfg, os.Args[2:])
+		return
 	}
+
+	client, db := openDatabase(ctx, cfg)
 	defer func() {
 		_ = client.Disconnect(context.Background())
 	}()
+	analytics := analyticsDatabase(cfg, db)
+	if err := services.ApplySchemas(ctx, db, cfg.MongoValidationAction); err != nil {
+		log.Fatalf("no se pudieron aplicar los esquemas de validacion: %v", err)
+	}
+
+	policy := services.ResiliencePolicy{
+		MaxAttempts: cfg.Resilience.RetryAttempts,
+		Backoff:     cfg.Resilience.RetryBackoff,
+		Breaker:     services.NewCircuitBreaker(cfg.Resilience.BreakerThreshold, cfg.Resilience.BreakerCooldown, time.Now),
+	}
+
+	mongoUsers := services.NewMongoUserRepository(db.Collection("users"), db.Collection("todos"))
+	if err := mongoUsers.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de usuarios: %v", err)
+	}
+	userRepo := services.NewResilientUserRepository(mongoUsers, policy)
+
+	mongoTodos := services.NewMongoTodoRepository(db.Collection("todos"), db.Collection("users"))
+	mongoTodos.SetArchive(db.Collection(services.TodoArchiveCollection))
+	if err := mongoTodos.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de tareas: %v", err)
+	}
+	if backfilled, err := mongoTodos.BackfillOwners(ctx); err != nil {
+		log.Fatalf("no se pudo asignar el usuario de las tareas: %v", err)
+	} else if backfilled > 0 {
+		log.Printf("migracion: %d tareas asociadas al id de su usuario", backfilled)
+	}
+	todoRepo := services.NewResilientTodoRepository(mongoTodos, policy)
+
+	mongoProperties := services.NewMongoPropertyRepository(db.Collection("properties"))
+	if err := mongoProperties.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de propiedades: %v", err)
+	}
+	propertyRepo := services.NewResilientPropertyRepository(mongoProperties, policy)
+
+	mongoRooms := services.NewMongoRoomRepository(db.Collection("rooms"))
+	if err := mongoRooms.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de habitaciones: %v", err)
+	}
+	roomRepo := services.NewResilientRoomRepository(mongoRooms, policy)
+
+	mongoBookings := services.NewMongoBookingRepository(db.Collection("bookings"), db.Collection("rooms"))
+	if err := mongoBookings.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de reservas: %v", err)
+	}
+	bookingRepo := services.NewResilientBookingRepository(mongoBookings, policy)
+
+	mongoGuests := services.NewMongoGuestRepository(db.Collection("guests"))
+	if err := mongoGuests.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de huespedes: %v", err)
+	}
+	guestRepo := services.NewResilientGuestRepository(mongoGuests, policy)
+	mongoPayments := services.NewMongoPaymentRepository(db.Collection("payments"), db.Collection("payment_events"))
+	if err := mongoPayments.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de pagos: %v", err)
+	}
+	paymentRepo := services.NewResilientPaymentRepository(mongoPayments, policy)
+	mongoReviews := services.NewMongoReviewRepository(db.Collection("reviews"))
+	if err := mongoReviews.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de calificaciones: %v", err)
+	}
+	reviewRepo := services.NewResilientReviewRepository(mongoReviews, policy)
+	mongoSessions := services.NewMongoSessionRepository(db.Collection("sessions"))
+	if err := mongoSessions.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de sesiones: %v", err)
+	}
+	sessionRepo := services.NewResilientSessionRepository(mongoSessions, policy)
+	mongoLogins := services.NewMongoLoginRepository(db.Collection("logins"))
+	if err := mongoLogins.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices del historial de accesos: %v", err)
+	}
+	loginRepo := services.NewResilientLoginRepository(mongoLogins, policy)
+	mongoPasskeys := services.NewMongoPasskeyRepository(db.Collection("passkeys"))
+	if err := mongoPasskeys.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de passkeys: %v", err)
+	}
+	passkeyRepo := services.NewResilientPasskeyRepository(mongoPasskeys, policy)
+	mongoCeremonies := services.NewMongoCeremonyRepository(db.Collection("webauthn_ceremonies"))
+	if err := mongoCeremonies.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de los desafios de passkeys: %v", err)
+	}
+	ceremonyRepo := services.NewResilientCeremonyRepository(mongoCeremonies, policy)
+	mongoSSOStates := services.NewMongoSSOStateRepository(db.Collection("sso_states"))
+	if err := mongoSSOStates.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de los inicios de sesion unico: %v", err)
+	}
+	ssoStateRepo := services.NewResilientSSOStateRepository(mongoSSOStates, policy)
+	mongoRealtimeTickets := services.NewMongoRealtimeTicketRepository(db.Collection("realtime_tickets"))
+	if err := mongoRealtimeTickets.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de los tickets de tiempo real: %v", err)
+	}
+	realtimeTicketRepo := services.NewResilientRealtimeTicketRepository(mongoRealtimeTickets, policy)
+	mongoLoginFailures := services.NewMongoLoginFailureRepository(db.Collection("login_failures"))
+	if err := mongoLoginFailures.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de los intentos fallidos: %v", err)
+	}
+	loginFailureRepo := services.NewResilientLoginFailureRepository(mongoLoginFailures, policy)
+	mongoWaitlist := services.NewMongoWaitlistRepository(db.Collection("waitlist"))
+	if err := mongoWaitlist.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de la lista de espera: %v", err)
+	}
+	waitlistRepo := services.NewResilientWaitlistRepository(mongoWaitlist, policy)
+	mongoComments := services.NewMongoCommentRepository(db.Collection("todo_comments"))
+	if err := mongoComments.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de comentarios: %v", err)
+	}
+	commentRepo := services.NewResilientCommentRepository(mongoComments, policy)
+
+	mongoAttachments := services.NewMongoAttachmentRepository(db.Collection("todo_attachments"))
+	if err := mongoAttachments.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de adjuntos: %v", err)
+	}
+	attachmentRepo := services.NewResilientAttachmentRepository(mongoAttachments, policy)
+
+	mongoNotifications := services.NewMongoNotificationRepository(db.Collection("notifications"))
+	if err := mongoNotifications.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de notificaciones: %v", err)
+	}
+	notificationRepo := services.NewResilientNotificationRepository(mongoNotifications, policy)
+	mongoLists := services.NewMongoListRepository(db.Collection("todo_lists"))
+	if err := mongoLists.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de listas: %v", err)
+	}
+	listRepo := services.NewResilientListRepository(mongoLists, policy)
+	mongoPresence := services.NewMongoPresenceRepository(db.Collection("list_presence"))
+	if err := mongoPresence.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de la presencia en listas: %v", err)
+	}
+	presenceRepo := services.NewResilientPresenceRepository(mongoPresence, policy)
+	mongoTitleEdits := services.NewMongoTitleEditRepository(db.Collection("todo_title_edits"))
+	if err := mongoTitleEdits.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de las ediciones de titulos: %v", err)
+	}
+	titleEditRepo := services.NewResilientTitleEditRepository(mongoTitleEdits, policy)
+	mongoSyncOps := services.NewMongoSyncOpRepository(db.Collection("sync_ops"))
+	if err := mongoSyncOps.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de las operaciones sincronizadas: %v", err)
+	}
+	syncOpRepo := services.NewResilientSyncOpRepository(mongoSyncOps, policy)
+	mailRepo := services.NewResilientMailRepository(services.NewMongoMailRepository(db.Collection("mail_log"), db.Collection("mail_opt_outs")), policy)
+	importRunRepo := services.NewResilientImportRunRepository(services.NewMongoImportRunRepository(db.Collection("import_runs")), policy)
+	ratePlanRepo := services.NewResilientRatePlanRepository(services.NewMongoRatePlanRepository(db.Collection("rate_plans")), policy)
+	dashboardRepo := services.NewResilientDashboardRepository(services.NewMongoDashboardRepository(analytics), policy)
+	statsRepo := services.NewResilientTodoStatsRepository(services.NewMongoTodoStatsRepository(analytics.Collection("todos"), analytics.Collection("users")), policy)
+	privacyRepo := services.NewResilientPrivacyRepository(services.NewMongoPrivacyRepository(db), policy)
+	erasureRepo := services.NewResilientErasureRepository(services.NewMongoErasureRepository(db.Collection("erasures")), policy)
+	mergeRepo := services.NewResilientAccountMergeRepository(services.NewMongoAccountMergeRepository(db), policy)
+
+	mongoDeadLetters := services.NewMongoDeadLetterRepository(db.Collection("dead_letters"))
+	if err := mongoDeadLetters.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de los mensajes fallidos: %v", err)
+	}
+	deadLetterRepo := services.NewResilientDeadLetterRepository(mongoDeadLetters, policy)
+
+	outbox := services.NewMongoOutbox(db.Collection("outbox"), time.Now)
+	if err := outbox.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices del outbox: %v", err)
+	}
+	mongoAudit := services.NewMongoAuditRepository(db.Collection("outbox"))
+	if err := mongoAudit.EnsureIndexes(ctx); err != nil {
+		log.Fatalf("no se pudieron crear los indices de la auditoria: %v", err)
+	}
+	auditRepo := services.NewResilientAuditRepository(mongoAudit, policy)
+	broker, err := events.Open(cfg.Events.Broker, cfg.Events.URL, cfg.Events.TopicPrefix)
+	if err != nil {
+		log.Fatalf("no se pudo configurar el broker de eventos: %v", err)
+	}
+	publisher := events.Fanout{broker}
+	for _, url := range cfg.Events.Webhooks {
+		publisher = append(publisher, events.NewWebhookPublisher(url, cfg.Events.WebhookSecret, nil))
+	}
+	var mocks *mock.Recorder
+	if cfg.MockIntegrations {
+		log.Println("integraciones simuladas: no se envia nada al exterior")
+		mocks = mock.NewRecorder(cfg.MockOutboxSize, time.Now)
+		publisher = events.Fanout{mock.Publisher{Recorder: mocks, To: cfg.Events.Broker}}
+		for _, url := range cfg.Events.Webhooks {
+			publisher = append(publisher, mock.Publisher{Recorder: mocks, To: url})
+		}
+	}
+	var bridge realtime.Bridge
+	switch cfg.RealtimeBridge {
+	case "memory":
+		bridge = realtime.NewLocalBridge()
+	case "mongo":
+		mongoBridge := services.NewMongoRealtimeBridge(db.Collection("realtime"), time.Now)
+		if err := mongoBridge.EnsureIndexes(ctx); err != nil {
+			log.Fatalf("no se pudieron crear los indices de tiempo real: %v", err)
+		}
+		bridge = mongoBridge
+	default:
+		log.Fatalf("REALTIME_BRIDGE desconocido: %q", cfg.RealtimeBridge)
+	}
+	hub := realtime.NewHub(bridge)
+	if err := hub.Start(ctx); err != nil {
+		log.Fatalf("no se pudo escuchar el canal de tiempo real: %v", err)
+	}
+	mongoActivity := services.NewMongoActivityRepository(db.Collection(services.ActivityCollection))
+	if err := mongoActivity.EnsureCollection(ctx, int64(cfg.ActivityMaxBytes), int64(cfg.ActivityMaxEvents)); err != nil {
+		log.Fatalf("no se pudo crear la coleccion de actividad: %v", err)
+	}
+	activityFeed := services.NewActivityFeed(mongoActivity, todoRepo, bookingRepo, services.SystemClock{})
+	publisher = append(publisher, services.NewRealtimePublisher(hub, todoRepo, bookingRepo), activityFeed)
+	relay := services.NewOutboxRelay(services.NewResilientOutboxRepository(outbox, policy), publisher, deadLetterRepo, services.OutboxRelayConfig{
+		Backoff:     cfg.Events.RetryBackoff,
+		MaxBackoff:  cfg.Events.MaxBackoff,
+		MaxAttempts: cfg.Events.MaxAttempts,
+	}, time.Now)
+	go relay.Run(ctx, cfg.Events.RelayInterval)
 
-	db := client.Database(dbName)
+	ids := services.SystemClock{}
+	userService := services.NewUserService(userRepo, outbox, time.Now)
+	userService.SetListRepository(services.NewResilientUserRepository(services.NewMongoUserRepository(analytics.Collection("users"), analytics.Collection("todos")), policy))
+	sessionService := services.NewSessionService(sessionRepo, loginRepo, userRepo, outbox, cfg.SessionTTL, cfg.ImpersonationTTL, time.Now, ids)
+	passkeyService := services.NewPasskeyService(passkeyRepo, ceremonyRepo, userRepo, webauthn.Config{
+		RPID:    cfg.WebAuthn.RPID,
+		RPName:  cfg.WebAuthn.RPName,
+		Origins: cfg.WebAuthn.Origins,
+	}, time.Now, ids)
+	todoService := services.NewTodoService(todoRepo, outbox, time.Now, ids)
+	listTodos := services.NewMongoTodoRepository(analytics.Collection("todos"), analytics.Collection("users"))
+	listTodos.SetExplain(cfg.ExplainQueries)
+	listTodos.SetArchive(analytics.Collection(services.TodoArchiveCollection))
+	todoService.SetListRepository(services.NewResilientTodoRepository(listTodos, policy))
+	todoService.SetTitleEdits(titleEditRepo, hub)
+	if cfg.LinkPreviews.Enabled {
+		previewCache := services.NewMongoLinkPreviewCache(db.Collection("link_previews"))
+		if err := previewCache.EnsureIndexes(ctx, cfg.LinkPreviews.TTL); err != nil {
+			log.Fatalf("no se pudieron crear los indices de vistas previas: %v", err)
+		}
+		fetcher := linkpreview.NewFetcher(linkpreview.SafeClient(cfg.LinkPreviews.Timeout))
+		todoService.SetLinkPreviews(services.NewLinkPreviewService(fetcher, services.NewResilientLinkPreviewCache(previewCache, policy), cfg.LinkPreviews.TTL, time.Now))
+	}
+	reviewService := services.NewReviewService(reviewRepo, bookingRepo, cfg.RatingCacheTTL, time.Now, ids)
+	roomService := services.NewRoomService(roomRepo, reviewService, time.Now, ids)
+	rateService := services.NewRateService(ratePlanRepo, time.Now, ids)
+	bookingService := services.NewBookingService(bookingRepo, roomRepo, guestRepo, rateService, outbox, time.Now, ids)
+	reportService := services.NewReportService(
+		services.NewResilientBookingRepository(services.NewMongoBookingRepository(analytics.Collection("bookings"), analytics.Collection("rooms")), policy),
+		services.NewResilientRoomRepository(services.NewMongoRoomRepository(analytics.Collection("rooms")), policy),
+	)
+	bookingService.Subscribe(func(_ context.Context, event services.BookingEvent) {
+		log.Printf("evento %s: reserva %s, habitacion %s", event.Type, event.Booking.ID.Hex(), event.Booking.RoomID.Hex())
+	})
+	notificationService := services.NewNotificationService(notificationRepo, time.Now, ids)
+	bookingService.Subscribe(services.NewHousekeeping(todoService, roomRepo, notificationService, cfg.HousekeepingEmails).HandleBookingEvent)
+	var waitlistNotifier services.WaitlistNotifier = services.LogWaitlistNotifier{}
+	if mocks != nil {
+		waitlistNotifier = mock.WaitlistNotifier{Recorder: mocks}
+	}
+	waitlistService := services.NewWaitlistService(waitlistRepo, bookingService, waitlistNotifier, cfg.WaitlistHold, time.Now, ids)
+	bookingService.Subscribe(waitlistService.HandleBookingEvent)
+	go waitlistService.Run(ctx, cfg.WaitlistInterval)
 
-	userRepo := services.NewMongoUserRepository(db.Collection("users"))
-	todoRepo := services.NewMongoTodoRepository(db.Collection("todos"))
+	mailTemplates, err := services.LoadMailTemplates(cfg.Mail.TemplatesDir)
+	if err != nil {
+		log.Fatalf("no se pudieron cargar las plantillas de email: %v", err)
+	}
+	var sender mailer.Mailer = mailer.LogMailer{}
+	if cfg.Mail.SMTPAddr != "" {
+		sender = mailer.NewSMTPMailer(cfg.Mail.SMTPAddr, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From)
+	}
+	if mocks != nil {
+		sender = mock.Mailer{Recorder: mocks}
+	}
+	bookingMailer := services.NewBookingMailer(mailRepo, sender, deadLetterRepo, bookingRepo, roomRepo, mailTemplates, services.BookingMailerConfig{
+		ReminderDays: cfg.Mail.ReminderDays,
+		BaseURL:      cfg.Mail.BaseURL,
+		OptOutSecret: cfg.Mail.OptOutSecret,
+	}, time.Now)
+	bookingMailer.SetNotifications(notificationService)
+	deliveries := services.NewDeliveryPool(services.DeliveryPoolConfig{
+		Workers:  cfg.Mail.DeliveryWorkers,
+		Queue:    cfg.Mail.DeliveryQueue,
+		Interval: cfg.Mail.DeliveryInterval,
+	})
+	bookingMailer.SetDeliveryPool(deliveries)
+	bookingService.Subscribe(bookingMailer.HandleBookingEvent)
+	deadLetterService := services.NewDeadLetterService(deadLetterRepo, time.Now)
+	deadLetterService.Handle(services.DeadLetterEvent, relay.Redeliver)
+	deadLetterService.Handle(services.DeadLetterEmail, bookingMailer.Redeliver)
 
-	userService := services.NewUserService(userRepo)
-	todoService := services.NewTodoService(todoRepo, time.Now)
+	attachmentScanner, err := scanner.Open(cfg.Attachments.Scanner, cfg.Attachments.ScannerAddr, cfg.Attachments.ScannerToken, cfg.Attachments.ScannerTimeout)
+	if err != nil {
+		log.Fatalf("no se pudo configurar el escaner de adjuntos: %v", err)
+	}
+	attachmentService := services.NewAttachmentService(todoRepo, attachmentRepo, attachmentScanner, services.AttachmentConfig{
+		MaxBytes:  int64(cfg.Attachments.MaxBytes),
+		URLSecret: cfg.Attachments.URLSecret,
+		URLTTL:    cfg.Attachments.URLTTL,
+		BaseURL:   cfg.Mail.BaseURL,
+	}, time.Now, ids)
 
-	authHandler := handlers.NewAuthHandler(userService)
-	todoHandler := handlers.NewTodoHandler(todoService)
+	jobStore := services.NewMongoJobStore(db.Collection("jobs"))
+	lease := services.NewMongoLeaderLease(db.Collection("scheduler_leases"), cfg.Jobs.Instance, cfg.Jobs.LeaseTTL, time.Now)
+	jobs := scheduler.New(services.NewResilientJobStore(jobStore, policy), lease, cfg.Jobs.Instance, time.Now)
+	jobs.SetLocker(services.NewMongoJobLocks(db.Collection("job_locks"), cfg.Jobs.Instance, time.Now), cfg.Jobs.LeaseTTL)
+	todoDigest := services.NewTodoDigest(todoRepo, bookingMailer, time.Now)
+	for _, err := range []error{
+		jobs.Add(services.JobReminders, cfg.Jobs.Reminders, bookingMailer.SendReminders),
+		jobs.Add(services.JobTodoDigest, cfg.Jobs.TodoDigest, todoDigest.Send),
+		jobs.Add(services.JobTrashPurge, cfg.Jobs.TrashPurge, func(ctx context.Context) error {
+			return todoService.PurgeTrash(ctx, cfg.Jobs.TrashRetention)
+		}),
+		jobs.Add(services.JobRecurringTodos, cfg.Jobs.RecurringTodos, todoService.MaterializeRecurring),
+		jobs.Add(services.JobAttachmentScan, cfg.Jobs.AttachmentScan, attachmentService.ScanPending),
+		jobs.Add(services.JobTodoArchive, cfg.Jobs.TodoArchive, func(ctx context.Context) error {
+			return todoService.ArchiveCompleted(ctx, cfg.Jobs.ArchiveAfter)
+		}),
+	} {
+		if err != nil {
+			log.Fatalf("configuracion de trabajos invalida: %v", err)
+		}
+	}
+	go jobs.Run(ctx)
 
-	router := handlers.SetupRouter(authHandler, todoHandler, handlers.RouterConfig{})
+	verifier, err := captcha.Open(cfg.Captcha.Provider, cfg.Captcha.Secret, cfg.Captcha.TestToken)
+	if err != nil {
+		log.Fatalf("no se pudo configurar el captcha: %v", err)
+	}
+	if verifier != nil && mocks != nil {
+		verifier = mock.Verifier{Recorder: mocks}
+	}
+	captchaGuard := services.NewCaptchaGuard(verifier, loginFailureRepo, cfg.Captcha.LoginFailures, cfg.Captcha.FailureWindow, time.Now)
+	authHandler := handlers.NewAuthHandler(userService, sessionService, captchaGuard)
+	propertyHandler := handlers.NewPropertyHandler(services.NewPropertyService(propertyRepo, userRepo, time.Now, ids))
+	quotaService := services.NewQuotaService(userRepo, todoRepo, services.Limits{MaxTodos: cfg.Quotas.MaxTodos})
+	listService := services.NewListService(listRepo, todoRepo, outbox, notificationService, time.Now, ids)
+	todoHandler := handlers.NewTodoHandler(todoService, quotaService, listService)
+	roomHandler := handlers.NewRoomHandler(roomService)
+	bookingHandler := handlers.NewBookingHandler(bookingService)
+	guestHandler := handlers.NewGuestHandler(services.NewGuestService(guestRepo, bookingRepo, time.Now, ids))
+	paymentHandler := handlers.NewPaymentHandler(services.NewPaymentService(paymentRepo, bookingRepo, time.Now, ids), cfg.PaymentWebhookSecret)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	ipRules, err := middleware.ParseIPRules(cfg.IPAccess.Allow, cfg.IPAccess.Deny)
+	if err != nil {
+		log.Fatalf("reglas de IP invalidas: %v", err)
 	}
+	adminIPRules, err := middleware.ParseIPRules(cfg.IPAccess.AdminAllow, cfg.IPAccess.AdminDeny)
+	if err != nil {
+		log.Fatalf("reglas de IP de /admin invalidas: %v", err)
+	}
+	testingIPRules, err := middleware.ParseIPRules(cfg.IPAccess.TestingAllow, cfg.IPAccess.TestingDeny)
+	if err != nil {
+		log.Fatalf("reglas de IP de los endpoints de prueba invalidas: %v", err)
+	}
+	routerCfg := handlers.RouterConfig{
+		TrustedProxies: cfg.TrustedProxies,
+		RequestTimeout: cfg.RequestTimeout,
+		RouteTimeouts:  cfg.RouteTimeouts,
+		AdminToken:     cfg.AdminToken,
+		Maintenance:    middleware.NewMaintenanceMode(cfg.MaintenanceMode, cfg.MaintenanceRetryAfter),
+		ContractMode:   cfg.ContractValidation,
+		IPRules:        ipRules,
+		AdminIPRules:   adminIPRules,
+		TestingIPRules: testingIPRules,
+		ServerTiming:   cfg.ServerTiming,
+		Deprecations:   middleware.NewDeprecations(time.Now, handlers.Deprecations(cfg.DeprecationSunsets)...),
+	}
+	if cfg.FaultInjection {
+		log.Println("inyeccion de fallas habilitada: no usar en produccion")
+		routerCfg.Faults = middleware.NewFaultInjector(nil)
+	}
+	if cfg.AlertWebhookURL != "" {
+		routerCfg.Alerts = alerts.NewWebhookNotifier(cfg.AlertWebhookURL, nil)
+	}
+	if mocks != nil {
+		routerCfg.Alerts = mock.Notifier{Recorder: mocks}
+		routerCfg.Mocks = mocks
+	}
+	if routerCfg.Alerts != nil {
+		routerCfg.Monitor = alerts.NewMonitor(alerts.MonitorConfig{
+			Window:        cfg.AlertMonitor.Window,
+			ErrorRate:     float64(cfg.AlertMonitor.ErrorRate),
+			MinRequests:   cfg.AlertMonitor.MinRequests,
+			LoginFailures: cfg.AlertMonitor.LoginFailures,
+			Cooldown:      cfg.AlertMonitor.Cooldown,
+		}, routerCfg.Alerts, time.Now)
+	}
+	routerCfg.Origins = middleware.NewOrigins(cfg.AllowedOrigins)
+	routerCfg.BodyLog = middleware.NewBodyLog(bodyLogConfig(cfg.BodyLog))
+	routerCfg.RateLimiter = middleware.NewRateLimiter(rateLimits(cfg.RateLimit), nil, nil)
+	watcher := config.NewWatcher(cfg, func(next config.Config, changes []config.Change, source string) {
+		routerCfg.Origins.Set(next.AllowedOrigins)
+		routerCfg.BodyLog.Set(bodyLogConfig(next.BodyLog))
+		routerCfg.RateLimiter.SetLimits(rateLimits(next.RateLimit))
+		quotaService.SetLimits(services.Limits{MaxTodos: next.Quotas.MaxTodos})
+		auditReload(ctx, outbox, next.Jobs.Instance, source, changes)
+	})
+	go watcher.Run(ctx)
+
+	ssoService := services.NewSSOService(propertyRepo, ssoStateRepo, userRepo, outbox, nil, cfg.SSORedirectURL, time.Now, ids)
+	router := handlers.SetupRouter(handlers.Handlers{
+		Auth:          authHandler,
+		Todos:         todoHandler,
+		Rooms:         roomHandler,
+		Bookings:      bookingHandler,
+		Guests:        guestHandler,
+		Rates:         handlers.NewRateHandler(rateService),
+		Payments:      paymentHandler,
+		Reviews:       handlers.NewReviewHandler(reviewService),
+		Reports:       handlers.NewReportHandler(reportService, services.NewTodoSummaryService(todoRepo, time.Now)),
+		Properties:    propertyHandler,
+		Waitlist:      handlers.NewWaitlistHandler(waitlistService),
+		Mail:          handlers.NewMailHandler(bookingMailer),
+		Imports:       handlers.NewImportHandler(services.NewImportService(importRunRepo, bookingService, roomRepo, time.Now, ids)),
+		Jobs:          handlers.NewJobHandler(jobs),
+		DeadLetters:   handlers.NewDeadLetterHandler(deadLetterService),
+		Dashboard:     handlers.NewDashboardHandler(services.NewDashboardService(dashboardRepo, time.Now)),
+		Quotas:        handlers.NewQuotaHandler(quotaService),
+		Privacy:       handlers.NewPrivacyHandler(services.NewPrivacyService(privacyRepo, erasureRepo, userRepo, todoRepo, bookingRepo, loginRepo, outbox, time.Now, ids), listService),
+		Passkeys:      handlers.NewPasskeyHandler(passkeyService, sessionService),
+		SSO:           handlers.NewSSOHandler(ssoService, sessionService),
+		SCIM:          handlers.NewSCIMHandler(services.NewSCIMService(propertyRepo, userRepo, outbox, time.Now)),
+		Backups:       handlers.NewBackupHandler(services.NewBackupService(services.NewMongoBackupRepository(db), time.Now)),
+		Comments:      handlers.NewCommentHandler(services.NewCommentService(todoRepo, commentRepo, notificationRepo, outbox, bookingMailer, time.Now, ids)),
+		Attachments:   handlers.NewAttachmentHandler(attachmentService),
+		Notifications: handlers.NewNotificationHandler(notificationService),
+		Approvals:     handlers.NewApprovalHandler(services.NewApprovalService(todoRepo, commentRepo, outbox, time.Now, ids)),
+		Lists:         handlers.NewListHandler(listService, todoService),
+		CalDAV:        handlers.NewCalDAVHandler(services.NewCalDAVService(todoService, quotaService), userService, captchaGuard),
+		Feeds:         handlers.NewFeedHandler(services.NewTodoFeedService(userRepo, todoService, listService, time.Now), cfg.Mail.BaseURL),
+		TodoImports:   handlers.NewTodoImportHandler(services.NewTodoImportService(todoService, listService, quotaService)),
+		Stats:         handlers.NewStatsHandler(services.NewTodoStatsService(statsRepo, cfg.StatsCacheTTL, time.Now)),
+		Audit:         handlers.NewAuditHandler(services.NewAuditService(auditRepo)),
+		Merges:        handlers.NewAccountMergeHandler(services.NewAccountMergeService(mergeRepo, userRepo, sessionService, outbox, time.Now)),
+		EmailChanges:  handlers.NewEmailChangeHandler(services.NewEmailChangeService(userRepo, mergeRepo, bookingMailer, outbox, time.Now)),
+		Sync:          handlers.NewSyncHandler(services.NewTodoSyncService(syncOpRepo, todoService, listService, quotaService, time.Now, ids)),
+		Realtime:      handlers.NewRealtimeHandler(hub, services.NewRealtimeTicketService(realtimeTicketRepo, time.Now, ids), services.NewPresenceService(presenceRepo, listService, hub, time.Now), activityFeed),
+	}, routerCfg)
 
-	if err := router.Run(":" + port); err != nil {
+	serveCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.Run(serveCtx, router, cfg); err != nil {
 		log.Fatalf("no se pudo iniciar el servidor: %v", err)
 	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := deliveries.Shutdown(drainCtx); err != nil {
+		log.Printf("se descartaron entregas pendientes al detener el servidor: %v", err)
+	}
+}
+
+// loadSecrets fetches the credentials from the configured secrets manager
+// and loads the configuration again with them.
+func loadSecrets(ctx context.Context, cfg config.Config) config.Config {
+	provider, err := secrets.Open(cfg.Secrets.Provider, secrets.Options{
+		Path:        cfg.Secrets.Path,
+		VaultAddr:   cfg.Secrets.VaultAddr,
+		VaultToken:  cfg.Secrets.VaultToken,
+		AWSRegion:   cfg.Secrets.AWSRegion,
+		AWSEndpoint: cfg.Secrets.AWSEndpoint,
+		AWS: secrets.Credentials{
+			AccessKeyID:     cfg.Secrets.AWSAccessKeyID,
+			SecretAccessKey: cfg.Secrets.AWSSecretAccessKey,
+			SessionToken:    cfg.Secrets.AWSSessionToken,
+		},
+	})
+	if err != nil {
+		log.Fatalf("no se pudo configurar el proveedor de secretos: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	values, err := provider.Values(ctx)
+	if err != nil {
+		log.Fatalf("no se pudieron leer los secretos de %s: %v", cfg.Secrets.Provider, err)
+	}
+	log.Printf("%d secretos cargados de %s", len(values), cfg.Secrets.Provider)
+	config.SetSecrets(values)
+	return config.Load()
+}
+
+// openDatabase connects to the configured MongoDB deployment and returns
+// the database of this environment.
+func openDatabase(ctx context.Context, cfg config.Config) (*mongo.Client, *services.Database) {
+	dbName := cfg.MongoDB
+	if dbName == "" {
+		dbName = services.DefaultDatabaseName
+	}
+
+	pool := services.PoolOptions{
+		MaxPoolSize:            uint64(max(cfg.MongoPool.MaxPoolSize, 0)),
+		MinPoolSize:            uint64(max(cfg.MongoPool.MinPoolSize, 0)),
+		SocketTimeout:          cfg.MongoPool.SocketTimeout,
+		ServerSelectionTimeout: cfg.MongoPool.ServerSelectionTimeout,
+		ReadPreference:         cfg.MongoPool.ReadPreference,
+	}
+	clientOpts, err := pool.ClientOptions()
+	if err != nil {
+		log.Fatalf("configuracion de MongoDB invalida: %v", err)
+	}
+	log.Printf("opciones de MongoDB: %s", pool)
+
+	if cfg.SlowQueryThreshold > 0 {
+		clientOpts.SetMonitor(services.NewSlowQueryMonitor(cfg.SlowQueryThreshold))
+	}
+
+	client, err := services.ConnectMongo(ctx, cfg.MongoURI, clientOpts)
+	if err != nil {
+		log.Fatalf("no se pudo conectar a MongoDB: %v", err)
+	}
+	db, err := services.NewDatabase(client.Database(dbName), cfg.MongoCollectionPrefix)
+	if err != nil {
+		log.Fatalf("MONGO_COLLECTION_PREFIX invalido: %v", err)
+	}
+	if cfg.MongoCollectionPrefix != "" {
+		log.Printf("base de datos %s con colecciones %s*", dbName, cfg.MongoCollectionPrefix)
+	}
+	return client, db
+}
+
+// analyticsDatabase returns the database used by the listings, reports and
+// dashboard. With MONGO_ANALYTICS_READS it reads from the secondaries
+// (MONGO_ANALYTICS_READ_PREFERENCE) over the same client, so these heavy
+// queries stay off the primary at the price of results that may lag the
+// writes by up to MONGO_ANALYTICS_MAX_STALENESS (plus the replication
+// heartbeat). Otherwise it is db itself.
+func analyticsDatabase(cfg config.Config, db *services.Database) *services.Database {
+	if !cfg.MongoPool.AnalyticsReads {
+		return db
+	}
+	pref, err := services.ReadPreference(cfg.MongoPool.AnalyticsReadPreference, cfg.MongoPool.AnalyticsMaxStaleness)
+	if err != nil {
+		log.Fatalf("lecturas analiticas de MongoDB invalidas: %v", err)
+	}
+	log.Printf("listados, reportes y dashboard leen con %s (maxStaleness=%s)", cfg.MongoPool.AnalyticsReadPreference, cfg.MongoPool.AnalyticsMaxStaleness)
+	return db.ReadingFrom(pref)
 }
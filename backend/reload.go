@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// bodyLogConfig returns the body logger settings of cfg, nil when it is
+// disabled.
+func bodyLogConfig(cfg config.BodyLogConfig) *middleware.BodyLogConfig {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &middleware.BodyLogConfig{MaxBytes: cfg.MaxBytes, SkipRoutes: cfg.SkipRoutes}
+}
+
+// rateLimits returns the request budgets of cfg.
+func rateLimits(cfg config.RateLimitConfig) middleware.RateLimits {
+	return middleware.RateLimits{Window: cfg.Window, Anonymous: cfg.Anonymous, User: cfg.User, Admin: cfg.Admin}
+}
+
+// auditReload stores a config.reloaded event describing the changes of a
+// reload in the outbox, keyed by the replica that applied them.
+func auditReload(ctx context.Context, outbox services.Outbox, instance, source string, changes []config.Change) {
+	err := outbox.Atomically(ctx, func(context.Context) ([]events.Event, error) {
+		event, err := events.New(events.ConfigReloaded, instance, map[string]any{
+			"instance": instance,
+			"source":   source,
+			"changes":  changes,
+		}, time.Now())
+		return []events.Event{event}, err
+	})
+	if err != nil {
+		log.Printf("no se pudo auditar la recarga de configuracion: %v", err)
+	}
+}
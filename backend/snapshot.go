@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// runSnapshot implements `app snapshot`: it copies the configured database
+// into a QA database with the personal data anonymized, so QA can test
+// against production volumes.
+func runSnapshot(ctx context.Context, cfg config.Config, args []string) {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	targetURI := flags.String("target-uri", "", "URI de MongoDB de destino (por defecto la de origen)")
+	targetDB := flags.String("target-db", "", "base de datos de destino")
+	targetPrefix := flags.String("target-prefix", "", "prefijo de las colecciones de destino")
+	password := flags.String("password", "qa", "contraseña que reciben todas las cuentas")
+	secret := flags.String("secret", "", "clave de los alias de los emails; vacía usa una al azar")
+	seed := flags.Uint64("seed", uint64(time.Now().UnixNano()), "semilla con la que se mezclan los textos")
+	_ = flags.Parse(args)
+
+	if *targetDB == "" {
+		log.Fatal("snapshot: falta --target-db")
+	}
+	if *targetURI == "" {
+		*targetURI = cfg.MongoURI
+	}
+	sourceDB := cfg.MongoDB
+	if sourceDB == "" {
+		sourceDB = services.DefaultDatabaseName
+	}
+	if *targetURI == cfg.MongoURI && *targetDB == sourceDB && *targetPrefix == cfg.MongoCollectionPrefix {
+		log.Fatal("snapshot: el destino es la misma base de origen")
+	}
+	key := []byte(*secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatalf("snapshot: %v", err)
+		}
+	}
+
+	sourceClient, source := openDatabase(ctx, cfg)
+	defer func() {
+		_ = sourceClient.Disconnect(context.Background())
+	}()
+	targetCfg := cfg
+	targetCfg.MongoURI, targetCfg.MongoDB, targetCfg.MongoCollectionPrefix = *targetURI, *targetDB, *targetPrefix
+	targetClient, target := openDatabase(ctx, targetCfg)
+	defer func() {
+		_ = targetClient.Disconnect(context.Background())
+	}()
+
+	started := time.Now()
+	copied, err := services.CopyAnonymized(ctx, services.NewMongoBackupRepository(source), services.NewMongoBackupRepository(target),
+		services.NewAnonymizer(key, *password, *seed))
+	log.Printf("snapshot: %v copiados a %s en %s", copied, *targetDB, time.Since(started).Round(time.Millisecond))
+	if err != nil {
+		log.Printf("snapshot: %v", err)
+		os.Exit(1)
+	}
+}
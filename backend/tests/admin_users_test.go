@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func newAdminUsersApp() *testsupport.App {
+	return testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+}
+
+func TestSearchUsers(t *testing.T) {
+	app := newAdminUsersApp()
+	for _, email := range []string{"ana@example.com", "beto@example.com", "carla@hotel.com"} {
+		app.Register(t, email)
+	}
+
+	rec := app.Do(http.MethodGet, "/admin/users", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/admin/users?q=EXAMPLE&limit=1&offset=1", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Users []services.UserListItem `json:"users"`
+		Links map[string]struct {
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Equal(t, respond.Pagination{Offset: 1, Limit: 1, Total: 2}, *testsupport.DecodeMeta(t, rec.Body.Bytes()).Pagination)
+	require.Len(t, payload.Users, 1)
+	require.Equal(t, "beto@example.com", payload.Users[0].Email)
+	require.Contains(t, payload.Links, "prev")
+
+	rec = app.Do(http.MethodGet, "/admin/users?limit=0", nil, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_PAGINATION")
+}
+
+func TestSearchUsersIncludesTodoActivity(t *testing.T) {
+	app := newAdminUsersApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	first := createTodo(t, app.Router, "ana@example.com", "Uno")
+	createTodo(t, app.Router, "ana@example.com", "Dos")
+	app.Clock.Advance(time.Hour)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/todos/"+first.ID, nil, nil).Code)
+
+	rec := app.Do(http.MethodGet, "/admin/users", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Users []services.UserListItem `json:"users"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Len(t, payload.Users, 2)
+	require.Equal(t, "ana@example.com", payload.Users[0].Email)
+	require.EqualValues(t, 1, payload.Users[0].TodoCount, "trashed todos are not counted")
+	require.Equal(t, testsupport.FixedTime.Add(time.Hour), *payload.Users[0].LastActivityAt, "trashing a todo counts as activity")
+	require.EqualValues(t, 0, payload.Users[1].TodoCount)
+	require.Nil(t, payload.Users[1].LastActivityAt)
+}
+
+func TestSuspendUser(t *testing.T) {
+	app := newAdminUsersApp()
+	ana := app.LoginAs(t, "ana@example.com", "")
+	require.Equal(t, http.StatusOK, app.Do(http.MethodGet, "/users/me/usage", nil, ana).Code)
+
+	rec := app.Do(http.MethodPost, "/admin/users/ana@example.com/suspend", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "suspendedAt")
+
+	// Open sessions stop working and logging in again is rejected.
+	rec = app.Do(http.MethodGet, "/users/me/usage", nil, ana)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = app.Do(http.MethodPost, "/login", map[string]string{"email": "ana@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "ACCOUNT_SUSPENDED")
+	rec = app.Do(http.MethodPost, "/login", map[string]string{"email": "ana@example.com", "password": "otra"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = app.Do(http.MethodDelete, "/admin/users/ana@example.com/suspend", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotContains(t, rec.Body.String(), "suspendedAt")
+	rec = app.Do(http.MethodPost, "/login", map[string]string{"email": "ana@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = app.Do(http.MethodPost, "/admin/users/nadie@example.com/suspend", nil, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestImpersonateUser(t *testing.T) {
+	app := newAdminUsersApp()
+	app.Register(t, "ana@example.com")
+	app.LoginAs(t, "gerente@hotel.com", services.RoleManager)
+	path := "/admin/users/ana@example.com/impersonate"
+	body := map[string]string{"operator": "soporte@hotel.com", "reason": "Ticket 42: no ve sus tareas"}
+
+	rec := app.Do(http.MethodPost, path, body, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = app.Do(http.MethodPost, path, map[string]string{"operator": "soporte@hotel.com"}, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_IMPERSONATION")
+	rec = app.Do(http.MethodPost, "/admin/users/gerente@hotel.com/impersonate", body, adminHeaders)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "IMPERSONATION_FORBIDDEN")
+	rec = app.Do(http.MethodPost, "/admin/users/nadie@example.com/impersonate", body, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Empty(t, app.Outbox.OfType("user.impersonated"))
+
+	rec = app.Do(http.MethodPost, path, body, adminHeaders)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var payload struct {
+		Impersonation services.Impersonation `json:"impersonation"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Equal(t, "ana@example.com", payload.Impersonation.Email)
+	require.True(t, testsupport.FixedTime.Add(testsupport.ImpersonationTTL).Equal(payload.Impersonation.ExpiresAt))
+
+	headers := testsupport.Bearer(payload.Impersonation.Token)
+	require.Equal(t, "ana@example.com", accountUsage(t, app, headers).Email)
+
+	audit := app.Outbox.OfType("user.impersonated")
+	require.Len(t, audit, 1)
+	require.Equal(t, "ana@example.com", audit[0].Event.Key)
+	var data struct {
+		Operator  string    `json:"operator"`
+		Reason    string    `json:"reason"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	require.NoError(t, json.Unmarshal(audit[0].Event.Data, &data))
+	require.Equal(t, "soporte@hotel.com", data.Operator)
+	require.Equal(t, "Ticket 42: no ve sus tareas", data.Reason)
+	require.True(t, payload.Impersonation.ExpiresAt.Equal(data.ExpiresAt))
+
+	rec = app.Do(http.MethodPost, "/admin/users/ana@example.com/suspend", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = app.Do(http.MethodPost, path, body, adminHeaders)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "ACCOUNT_SUSPENDED")
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type approvalBody struct {
+	Todo struct {
+		Completed bool   `json:"completed"`
+		Assignee  string `json:"assignee"`
+		Approval  string `json:"approval"`
+	} `json:"todo"`
+}
+
+func TestTodoApprovalWorkflow(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID
+
+	step := func(action string, body any, headers map[string]string, status int) approvalBody {
+		t.Helper()
+		rec := app.Do(http.MethodPost, path+"/"+action, body, headers)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		var out approvalBody
+		if status == http.StatusOK {
+			testsupport.DecodeData(t, rec.Body.Bytes(), &out)
+		}
+		return out
+	}
+
+	// Nobody can submit a todo that was not delegated.
+	step("approval", nil, ana, http.StatusForbidden)
+
+	rec := app.Do(http.MethodPut, path, map[string]string{"assignee": " Beto@Hotel.com "}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	step("approval", nil, ana, http.StatusForbidden)
+	step("approve", nil, ana, http.StatusConflict)
+	body := step("approval", nil, beto, http.StatusOK)
+	require.Equal(t, "beto@hotel.com", body.Todo.Assignee)
+	require.Equal(t, "pending", body.Todo.Approval)
+	require.False(t, body.Todo.Completed)
+	step("approval", nil, beto, http.StatusConflict)
+
+	step("reject", map[string]string{"comment": "  "}, ana, http.StatusBadRequest)
+	step("reject", map[string]string{"comment": "Faltan las toallas"}, beto, http.StatusForbidden)
+	body = step("reject", map[string]string{"comment": "Faltan las toallas"}, ana, http.StatusOK)
+	require.Equal(t, "rejected", body.Todo.Approval)
+	require.False(t, body.Todo.Completed)
+
+	step("approval", nil, beto, http.StatusOK)
+	body = step("approve", nil, ana, http.StatusOK)
+	require.Equal(t, "approved", body.Todo.Approval)
+	require.True(t, body.Todo.Completed)
+	step("reject", map[string]string{"comment": "Tarde"}, ana, http.StatusConflict)
+
+	rec = app.Do(http.MethodGet, path+"/comments", nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "Faltan las toallas")
+
+	var activity []string
+	for _, msg := range app.Outbox.WithKey(todo.ID) {
+		activity = append(activity, msg.Event.Type)
+	}
+	require.Equal(t, []string{
+		events.TodoApprovalRequested, events.TodoRejected,
+		events.TodoApprovalRequested, events.TodoApproved, events.TodoCompleted,
+	}, activity)
+
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPost, path+"/approval", nil, nil).Code)
+	require.Equal(t, http.StatusNotFound, app.Do(http.MethodPost, "/todos/000000000000000000000000/approve", nil, ana).Code)
+}
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scanner"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// uploadAttachment sends content as the raw body of an attachment upload.
+func uploadAttachment(app *testsupport.App, todoID, name, contentType, content string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/todos/"+todoID+"/attachments?name="+url.QueryEscape(name), strings.NewReader(content))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTodoAttachmentsWithSignedURLs(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Cambiar la alfombra")
+	path := "/todos/" + todo.ID + "/attachments"
+
+	require.Equal(t, http.StatusUnauthorized, uploadAttachment(app, todo.ID, "plano.txt", "", "hola", nil).Code)
+	require.Equal(t, http.StatusBadRequest, uploadAttachment(app, todo.ID, "", "", "hola", ana).Code)
+	require.Equal(t, http.StatusBadRequest, uploadAttachment(app, todo.ID, "vacio.txt", "", "", ana).Code)
+	rec := uploadAttachment(app, todo.ID, "grande.bin", "", strings.Repeat("x", testsupport.AttachmentMaxBytes+1), ana)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+
+	// Directories in the name are dropped and the type is sniffed when missing.
+	rec = uploadAttachment(app, todo.ID, `C:\fotos\alfombra.txt`, "", "medidas: 3x4", ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Attachment services.AttachmentResponse `json:"attachment"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, "alfombra.txt", created.Attachment.Name)
+	require.Equal(t, "text/plain; charset=utf-8", created.Attachment.ContentType)
+	require.EqualValues(t, 12, created.Attachment.Size)
+	require.Equal(t, "ana@hotel.com", created.Attachment.Email)
+
+	rec = app.Do(http.MethodGet, path, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var listed struct {
+		Attachments []services.AttachmentResponse `json:"attachments"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &listed)
+	require.Equal(t, []services.AttachmentResponse{created.Attachment}, listed.Attachments)
+
+	rec = app.Do(http.MethodGet, path+"/"+created.Attachment.ID, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "medidas: 3x4", rec.Body.String())
+	require.Equal(t, `attachment; filename=alfombra.txt`, rec.Header().Get("Content-Disposition"))
+	require.Equal(t, "private, no-store", rec.Header().Get("Cache-Control"))
+
+	// Signed URLs download without a session until they expire.
+	rec = app.Do(http.MethodPost, path+"/"+created.Attachment.ID+"/url", nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var signed struct {
+		Download services.SignedURL `json:"download"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &signed)
+	require.True(t, strings.HasPrefix(signed.Download.URL, "https://hotel.test/attachments/"+created.Attachment.ID+"?"), signed.Download.URL)
+	require.Equal(t, app.Clock.Now().Add(testsupport.AttachmentURLTTL), signed.Download.ExpiresAt)
+	link := strings.TrimPrefix(signed.Download.URL, "https://hotel.test")
+
+	rec = app.Do(http.MethodGet, link, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "medidas: 3x4", rec.Body.String())
+
+	tampered := strings.Replace(link, "expires=", "expires=9", 1)
+	require.Equal(t, http.StatusForbidden, app.Do(http.MethodGet, tampered, nil, nil).Code)
+	require.Equal(t, http.StatusForbidden, app.Do(http.MethodGet, "/attachments/"+created.Attachment.ID, nil, nil).Code)
+
+	app.Clock.Advance(testsupport.AttachmentURLTTL)
+	require.Equal(t, http.StatusForbidden, app.Do(http.MethodGet, link, nil, nil).Code)
+
+	rec = app.Do(http.MethodDelete, path+"/"+created.Attachment.ID, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, http.StatusNotFound, app.Do(http.MethodGet, path+"/"+created.Attachment.ID, nil, ana).Code)
+	require.Equal(t, http.StatusNotFound, app.Do(http.MethodPost, path+"/"+created.Attachment.ID+"/url", nil, ana).Code)
+}
+
+// attachmentScan returns the scan state of an attachment of todoID.
+func attachmentScan(t *testing.T, app *testsupport.App, todoID, id string, headers map[string]string) services.AttachmentResponse {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/todos/"+todoID+"/attachments", nil, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var listed struct {
+		Attachments []services.AttachmentResponse `json:"attachments"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &listed)
+	for _, attachment := range listed.Attachments {
+		if attachment.ID == id {
+			return attachment
+		}
+	}
+	t.Fatalf("attachment %s not listed", id)
+	return services.AttachmentResponse{}
+}
+
+func TestAttachmentScanning(t *testing.T) {
+	scan := &testsupport.Scanner{}
+	app := testsupport.NewAppWithOptions(handlers.RouterConfig{ContractMode: middleware.ContractFail}, testsupport.Options{Scanner: scan})
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Revisar facturas")
+	path := "/todos/" + todo.ID + "/attachments/"
+
+	upload := func(content string) services.AttachmentResponse {
+		rec := uploadAttachment(app, todo.ID, "factura.txt", "text/plain", content, ana)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created struct {
+			Attachment services.AttachmentResponse `json:"attachment"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+		require.Equal(t, services.AttachmentPending, created.Attachment.Scan)
+		return created.Attachment
+	}
+	settled := func(id string) services.AttachmentResponse {
+		var attachment services.AttachmentResponse
+		require.Eventually(t, func() bool {
+			attachment = attachmentScan(t, app, todo.ID, id, ana)
+			return attachment.Scan != services.AttachmentPending
+		}, time.Second, 10*time.Millisecond)
+		return attachment
+	}
+
+	clean := upload("total: 100")
+	require.Equal(t, services.AttachmentClean, settled(clean.ID).Scan)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodGet, path+clean.ID, nil, ana).Code)
+
+	infected := upload("adjunto " + testsupport.EICAR)
+	blocked := settled(infected.ID)
+	require.Equal(t, services.AttachmentBlocked, blocked.Scan)
+	require.Equal(t, "Eicar-Test-Signature", blocked.Threat)
+	rec := app.Do(http.MethodGet, path+infected.ID, nil, ana)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "ATTACHMENT_BLOCKED")
+	require.Equal(t, http.StatusForbidden, app.Do(http.MethodPost, path+infected.ID+"/url", nil, ana).Code)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, path+infected.ID, nil, ana).Code)
+
+	// Uploads stay pending while the scanner is down, until the scan job
+	// retries them.
+	scan.SetDown(true)
+	pending := upload("total: 200")
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, services.AttachmentPending, attachmentScan(t, app, todo.ID, pending.ID, ana).Scan)
+	rec = app.Do(http.MethodGet, path+pending.ID, nil, ana)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "ATTACHMENT_SCAN_PENDING")
+
+	scan.SetDown(false)
+	app.Clock.Advance(10 * time.Minute)
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+	require.Equal(t, services.AttachmentClean, attachmentScan(t, app, todo.ID, pending.ID, ana).Scan)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodGet, path+pending.ID, nil, ana).Code)
+}
+
+// fakeClamd answers one INSTREAM command with reply and sends the streamed
+// content to received.
+func fakeClamd(t *testing.T, reply string, received chan<- string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		command, _ := r.ReadString(0)
+		var content []byte
+		for {
+			var size uint32
+			if binary.Read(r, binary.BigEndian, &size) != nil || size == 0 {
+				break
+			}
+			chunk := make([]byte, size)
+			io.ReadFull(r, chunk)
+			content = append(content, chunk...)
+		}
+		received <- command + string(content)
+		conn.Write([]byte(reply + "\x00"))
+	}()
+	return listener.Addr().String()
+}
+
+func TestScannerProviders(t *testing.T) {
+	ctx := context.Background()
+
+	received := make(chan string, 1)
+	addr := fakeClamd(t, "stream: OK", received)
+	verdict, err := scanner.NewClamdScanner(addr, time.Second).Scan(ctx, "a.txt", []byte("hola"))
+	require.NoError(t, err)
+	require.True(t, verdict.Clean)
+	require.Equal(t, "zINSTREAM\x00hola", <-received)
+
+	addr = fakeClamd(t, "stream: Eicar-Signature FOUND", received)
+	verdict, err = scanner.NewClamdScanner(addr, time.Second).Scan(ctx, "a.txt", []byte(testsupport.EICAR))
+	require.NoError(t, err)
+	require.Equal(t, scanner.Verdict{Threat: "Eicar-Signature"}, verdict)
+
+	addr = fakeClamd(t, "INSTREAM size limit exceeded. ERROR", received)
+	_, err = scanner.NewClamdScanner(addr, time.Second).Scan(ctx, "a.txt", []byte("hola"))
+	require.Error(t, err)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer scan-token", r.Header.Get("Authorization"))
+		require.Equal(t, "factura.pdf", r.Header.Get("X-File-Name"))
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]any{"clean": string(body) == "ok", "threat": "Trojan.Test"})
+	}))
+	defer api.Close()
+	httpScanner := scanner.NewHTTPScanner(api.URL, "scan-token", nil)
+	verdict, err = httpScanner.Scan(ctx, "factura.pdf", []byte("ok"))
+	require.NoError(t, err)
+	require.True(t, verdict.Clean)
+	verdict, err = httpScanner.Scan(ctx, "factura.pdf", []byte("malo"))
+	require.NoError(t, err)
+	require.Equal(t, scanner.Verdict{Threat: "Trojan.Test"}, verdict)
+
+	_, err = scanner.Open("otro", "", "", time.Second)
+	require.Error(t, err)
+	none, err := scanner.Open("", "", "", time.Second)
+	require.NoError(t, err)
+	require.Nil(t, none)
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestAuditTrail(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+	for _, email := range []string{"ana@hotel.com", "bruno@hotel.com", "carla@hotel.com"} {
+		app.Register(t, email)
+		app.Clock.Advance(time.Hour)
+	}
+	dani := app.LoginAs(t, "dani@hotel.com", "")
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "dani@hotel.com", "title": "Revisar minibar"}, dani)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Todo struct {
+			ID string `json:"id"`
+		} `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	rec = app.Do(http.MethodPut, "/todos/"+created.Todo.ID, map[string]any{"completed": true}, dani)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	list := func(query string) services.AuditPage {
+		t.Helper()
+		rec := app.Do(http.MethodGet, "/admin/audit"+query, nil, adminHeaders)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page struct {
+			Entries    []services.AuditEntry `json:"entries"`
+			NextCursor string                `json:"nextCursor"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &page)
+		return services.AuditPage{Entries: page.Entries, NextCursor: page.NextCursor}
+	}
+
+	// The completion names its actor; the sign-ups, made anonymously, do not.
+	page := list("?actor=dani@hotel.com")
+	require.Len(t, page.Entries, 1)
+	require.Equal(t, events.TodoCompleted, page.Entries[0].Type)
+	require.Equal(t, created.Todo.ID, page.Entries[0].Resource)
+	require.Empty(t, page.NextCursor)
+
+	require.Len(t, list("?resource=bruno@hotel.com").Entries, 1)
+
+	// Cursor pages walk the sign-ups newest first without repeating them.
+	page = list("?type=" + events.UserRegistered + "&limit=2")
+	require.Len(t, page.Entries, 2)
+	require.Equal(t, "carla@hotel.com", page.Entries[0].Resource)
+	require.NotEmpty(t, page.NextCursor)
+	rec = app.Do(http.MethodGet, "/admin/audit?type="+events.UserRegistered+"&limit=2", nil, adminHeaders)
+	require.Contains(t, rec.Header().Get("Link"), `rel="next"`)
+	next := list("?type=" + events.UserRegistered + "&limit=2&cursor=" + page.NextCursor)
+	require.Len(t, next.Entries, 1)
+	require.Equal(t, "ana@hotel.com", next.Entries[0].Resource)
+	require.Empty(t, next.NextCursor)
+
+	// The time range leaves the first sign-up out.
+	from := testsupport.FixedTime.Add(30 * time.Minute).Format(time.RFC3339)
+	require.Len(t, list("?type="+events.UserRegistered+"&from="+from).Entries, 2)
+
+	rec = app.Do(http.MethodGet, "/admin/audit?type="+events.UserRegistered+"&format=csv", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Header().Get("Content-Type"), handlers.MIMECSV)
+	records, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, []string{"id", "time", "type", "actor", "resource", "data"}, records[0])
+	require.Equal(t, "ana@hotel.com", records[3][4])
+
+	for _, query := range []string{"?cursor=nope", "?limit=500", "?from=ayer", "?from=2025-01-02&to=2025-01-01"} {
+		rec = app.Do(http.MethodGet, "/admin/audit"+query, nil, adminHeaders)
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+	rec = app.Do(http.MethodGet, "/admin/audit", nil, dani)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
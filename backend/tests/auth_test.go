@@ -8,10 +8,12 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
 )
 
 func TestRegisterAndLoginFlow(t *testing.T) {
-	app := newTestApp()
+	app := testsupport.NewApp()
 
 	registerPayload := map[string]string{
 		"email":    "User@example.com",
@@ -23,12 +25,12 @@ func TestRegisterAndLoginFlow(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(registerBody))
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
-	app.router.ServeHTTP(rec, req)
+	app.Router.ServeHTTP(rec, req)
 
 	require.Equal(t, http.StatusCreated, rec.Code)
 
 	var registerResp map[string]string
-	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &registerResp))
+	testsupport.DecodeData(t, rec.Body.Bytes(), &registerResp)
 	require.NotEmpty(t, registerResp["message"])
 
 	loginPayload := map[string]string{
@@ -41,31 +43,31 @@ func TestRegisterAndLoginFlow(t *testing.T) {
 	loginReq := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(loginBody))
 	loginReq.Header.Set("Content-Type", "application/json")
 	loginRec := httptest.NewRecorder()
-	app.router.ServeHTTP(loginRec, loginReq)
+	app.Router.ServeHTTP(loginRec, loginReq)
 
 	require.Equal(t, http.StatusOK, loginRec.Code)
 
 	var loginResp map[string]string
-	require.NoError(t, json.Unmarshal(loginRec.Body.Bytes(), &loginResp))
+	testsupport.DecodeData(t, loginRec.Body.Bytes(), &loginResp)
 	require.Equal(t, "login exitoso", loginResp["message"])
 
 	listReq := httptest.NewRequest(http.MethodGet, "/users", nil)
 	listRec := httptest.NewRecorder()
-	app.router.ServeHTTP(listRec, listReq)
+	app.Router.ServeHTTP(listRec, listReq)
 
 	require.Equal(t, http.StatusOK, listRec.Code)
 
 	var listResp struct {
 		Users []map[string]string `json:"users"`
 	}
-	require.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &listResp))
+	testsupport.DecodeData(t, listRec.Body.Bytes(), &listResp)
 	require.Len(t, listResp.Users, 1)
 	require.NotContains(t, listResp.Users[0], "password")
 	require.Equal(t, "user@example.com", listResp.Users[0]["email"])
 }
 
 func TestRegisterRejectsDuplicates(t *testing.T) {
-	app := newTestApp()
+	app := testsupport.NewApp()
 
 	payload := map[string]string{
 		"email":    "duplicate@example.com",
@@ -77,20 +79,20 @@ func TestRegisterRejectsDuplicates(t *testing.T) {
 	req1 := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
 	req1.Header.Set("Content-Type", "application/json")
 	rec1 := httptest.NewRecorder()
-	app.router.ServeHTTP(rec1, req1)
+	app.Router.ServeHTTP(rec1, req1)
 
 	require.Equal(t, http.StatusCreated, rec1.Code)
 
 	req2 := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(body))
 	req2.Header.Set("Content-Type", "application/json")
 	rec2 := httptest.NewRecorder()
-	app.router.ServeHTTP(rec2, req2)
+	app.Router.ServeHTTP(rec2, req2)
 
 	require.Equal(t, http.StatusConflict, rec2.Code)
 }
 
 func TestLoginInvalidCredentials(t *testing.T) {
-	app := newTestApp()
+	app := testsupport.NewApp()
 
 	payload := map[string]string{
 		"email":    "unknown@example.com",
@@ -102,7 +104,7 @@ func TestLoginInvalidCredentials(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	rec := httptest.NewRecorder()
-	app.router.ServeHTTP(rec, req)
+	app.Router.ServeHTTP(rec, req)
 
 	require.Equal(t, http.StatusUnauthorized, rec.Code)
 }
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func newBackupApp() *testsupport.App {
+	return testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+}
+
+// restore posts archive to /admin/restore.
+func restore(app *testsupport.App, archive []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/restore", bytes.NewReader(archive))
+	req.Header.Set("Content-Type", handlers.MIMEGzip)
+	req.Header.Set(middleware.AdminTokenHeader, testsupport.AdminToken)
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// archiveEntries returns the contents of each entry of a backup.
+func archiveEntries(t *testing.T, archive []byte) map[string]string {
+	t.Helper()
+	compressed, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+	entries := map[string]string{}
+	reader := tar.NewReader(compressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		entries[header.Name] = string(data)
+	}
+}
+
+func TestBackupAndRestoreCloneTheCollections(t *testing.T) {
+	source := newBackupApp()
+	source.Register(t, "ana@example.com")
+	todo := createTodo(t, source.Router, "ana@example.com", "Revisar minibar")
+
+	require.Equal(t, http.StatusUnauthorized, source.Do(http.MethodPost, "/admin/backup", nil, nil).Code)
+	rec := source.Do(http.MethodPost, "/admin/backup", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, handlers.MIMEGzip, rec.Header().Get("Content-Type"))
+	archive := rec.Body.Bytes()
+
+	entries := archiveEntries(t, archive)
+	require.Len(t, entries, len(services.BackupCollections)+1)
+	require.Contains(t, entries["todos.jsonl"], `"$oid":"`+todo.ID+`"`, "ObjectIDs are kept as Extended JSON")
+	require.Contains(t, entries["manifest.json"], `"todos": 1`)
+
+	target := newBackupApp()
+	target.Register(t, "local@example.com")
+	rec = restore(target, archive)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"users":1`)
+
+	rec = target.Do(http.MethodGet, "/todos?all=true", nil, adminHeaders)
+	require.Contains(t, rec.Body.String(), todo.ID)
+	rec = target.Do(http.MethodGet, "/users", nil, nil)
+	require.Contains(t, rec.Body.String(), "ana@example.com")
+	require.NotContains(t, rec.Body.String(), "local@example.com", "restored collections are replaced")
+}
+
+func TestRestoreRejectsInvalidArchives(t *testing.T) {
+	source := newBackupApp()
+	createTodo(t, source.Router, "ana@example.com", "Uno")
+	archive := source.Do(http.MethodPost, "/admin/backup", nil, adminHeaders).Body.Bytes()
+
+	target := newBackupApp()
+	createTodo(t, target.Router, "local@example.com", "Local")
+	for name, body := range map[string][]byte{
+		"not gzip":  []byte("hello"),
+		"truncated": archive[:len(archive)/2],
+	} {
+		rec := restore(target, body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, name)
+		require.Contains(t, rec.Body.String(), "INVALID_BACKUP", name)
+	}
+
+	// An entry whose count differs from the manifest.
+	var tampered bytes.Buffer
+	compressed := gzip.NewWriter(&tampered)
+	writer := tar.NewWriter(compressed)
+	for name, data := range map[string]string{
+		"todos.jsonl":   `{"_id":{"$oid":"650000000000000000000001"},"title":"Extra"}` + "\n",
+		"manifest.json": `{"version":1,"createdAt":"2025-01-01T00:00:00Z","collections":{"todos":2}}`,
+	} {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data))}))
+		_, err := writer.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, compressed.Close())
+	require.Equal(t, http.StatusBadRequest, restore(target, tampered.Bytes()).Code)
+
+	rec := target.Do(http.MethodGet, "/todos?all=true", nil, adminHeaders)
+	require.Contains(t, rec.Body.String(), "Local", "a rejected archive changes nothing")
+}
+
+func TestDumpStreamsACollection(t *testing.T) {
+	app := newBackupApp()
+	for i := 0; i < 1200; i++ {
+		// Spread over several owners so no plan quota gets in the way.
+		email := fmt.Sprintf("user%d@example.com", i/50)
+		rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": email, "title": fmt.Sprintf("Tarea %d", i)}, nil)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	rec := app.Do(http.MethodGet, "/admin/export/todos", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, handlers.MIMENDJSON+"; charset=utf-8", rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	require.Len(t, lines, 1200)
+	var todo struct {
+		ID    map[string]string `json:"_id"`
+		Title string            `json:"title"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &todo))
+	require.NotEmpty(t, todo.ID["$oid"])
+	require.True(t, strings.HasPrefix(todo.Title, "Tarea "))
+
+	rec = app.Do(http.MethodGet, "/admin/export/todos?format=json", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var docs []map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &docs))
+	require.Len(t, docs, 1200)
+
+	rec = app.Do(http.MethodGet, "/admin/export/rooms", nil, map[string]string{middleware.AdminTokenHeader: testsupport.AdminToken, "Accept": "application/json"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "[]", rec.Body.String())
+
+	rec = app.Do(http.MethodGet, "/admin/export/sessions", nil, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "UNKNOWN_COLLECTION")
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/admin/export/todos", nil, nil).Code)
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/timing"
+)
+
+// The benchmarks run against the in-memory repositories. With
+// BENCH_MONGO_URI set they use a scratch database on that server instead,
+// dropped afterwards. BENCH_USERS and BENCH_TODOS size the data, written by
+// the load generator.
+
+const benchDomain = "bench.test"
+
+// benchSize reads a positive integer from the environment.
+func benchSize(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// benchLoad is the data the benchmarks run over.
+func benchLoad() services.LoadGenConfig {
+	return services.LoadGenConfig{
+		Users:        benchSize("BENCH_USERS", 200),
+		Todos:        benchSize("BENCH_TODOS", 20000),
+		Distribution: services.DistributionZipf,
+		Completed:    0.6,
+		Recurring:    0.05,
+		Days:         90,
+		Domain:       benchDomain,
+		Seed:         1,
+	}
+}
+
+func newBenchApp(b *testing.B) *testsupport.App {
+	b.Helper()
+	gin.DefaultWriter = io.Discard
+	logs := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() {
+		gin.DefaultWriter = os.Stdout
+		log.SetOutput(logs)
+	})
+
+	ctx := context.Background()
+	gen := benchLoad()
+	cfg := handlers.RouterConfig{AdminToken: testsupport.AdminToken, ServerTiming: true}
+	// The resilience decorators time the repository calls.
+	var policy services.ResiliencePolicy
+
+	uri := os.Getenv("BENCH_MONGO_URI")
+	if uri == "" {
+		todos := testsupport.NewMemoryTodoRepo()
+		app := testsupport.NewAppWithTodos(cfg, services.NewResilientTodoRepository(todos, policy))
+		if _, err := services.NewLoadGenerator(app.Users, todos, nil).Run(ctx, gen); err != nil {
+			b.Fatal(err)
+		}
+		return app
+	}
+
+	db := newBenchDatabase(b, uri, gen)
+	return testsupport.NewAppWithOptions(cfg, testsupport.Options{
+		Todos:     services.NewResilientTodoRepository(services.NewMongoTodoRepository(db.Collection("todos"), db.Collection("users")), policy),
+		Dashboard: services.NewResilientDashboardRepository(services.NewMongoDashboardRepository(db), policy),
+	})
+}
+
+// newBenchDatabase creates a scratch database on the server at uri, filled
+// by the load generator with gen.
+func newBenchDatabase(b *testing.B, uri string, gen services.LoadGenConfig) *services.Database {
+	b.Helper()
+	ctx := context.Background()
+	client, err := services.ConnectMongo(ctx, uri)
+	if err != nil {
+		b.Fatal(err)
+	}
+	database := client.Database(fmt.Sprintf("bench_%d", time.Now().UnixNano()))
+	b.Cleanup(func() {
+		_ = database.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	db, err := services.NewDatabase(database, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	users := services.NewMongoUserRepository(db.Collection("users"), db.Collection("todos"))
+	todos := services.NewMongoTodoRepository(db.Collection("todos"), db.Collection("users"))
+	for _, err := range []error{users.EnsureIndexes(ctx), todos.EnsureIndexes(ctx)} {
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	if _, err := services.NewLoadGenerator(users, todos, nil).Run(ctx, gen); err != nil {
+		b.Fatal(err)
+	}
+	return db
+}
+
+// benchEndpoint requests path b.N times and reports, besides the usual
+// metrics, the database and serialization time per request taken from the
+// Server-Timing header.
+func benchEndpoint(b *testing.B, app *testsupport.App, path string, headers map[string]string) {
+	b.Helper()
+	b.ReportAllocs()
+	var db, serialize float64
+	b.ResetTimer()
+	for range b.N {
+		rec := app.Do(http.MethodGet, path, nil, headers)
+		if rec.Code != http.StatusOK {
+			b.Fatalf("GET %s: %d %s", path, rec.Code, rec.Body.String())
+		}
+		phases := serverTiming(b, rec.Header().Get(timing.Header))
+		db += phases[timing.DB]
+		serialize += phases[timing.Serialize]
+	}
+	b.ReportMetric(db*float64(time.Millisecond)/float64(b.N), "db-ns/op")
+	b.ReportMetric(serialize*float64(time.Millisecond)/float64(b.N), "serialize-ns/op")
+}
+
+func BenchmarkListTodos(b *testing.B) {
+	app := newBenchApp(b)
+	// The load generator gives its first user the most todos.
+	owner := "user000000@" + benchDomain
+	for _, format := range []struct{ name, accept string }{
+		{"json", "application/json"},
+		{"xml", "application/xml"},
+		{"msgpack", "application/msgpack"},
+	} {
+		// Without a limit the listing brings every todo of the owner.
+		for _, page := range []string{"limit=20", "limit=100", "all"} {
+			b.Run(format.name+"/"+page, func(b *testing.B) {
+				path := "/todos?email=" + owner
+				if page != "all" {
+					path += "&" + page
+				}
+				benchEndpoint(b, app, path, map[string]string{"Accept": format.accept})
+			})
+		}
+	}
+}
+
+func BenchmarkDashboard(b *testing.B) {
+	app := newBenchApp(b)
+	for _, stat := range []string{"users", "signups", "todos", "storage"} {
+		b.Run(stat, func(b *testing.B) {
+			benchEndpoint(b, app, "/admin/dashboard/"+stat, adminHeaders)
+		})
+	}
+}
+
+// BenchmarkDashboardTodoActivity compares the $facet pipeline behind
+// /admin/dashboard/todos with separate aggregations, one round trip per
+// series as before plus the one the completion times would need. It needs
+// BENCH_MONGO_URI.
+func BenchmarkDashboardTodoActivity(b *testing.B) {
+	uri := os.Getenv("BENCH_MONGO_URI")
+	if uri == "" {
+		b.Skip("BENCH_MONGO_URI is not set")
+	}
+	gen := benchLoad()
+	db := newBenchDatabase(b, uri, gen)
+	ctx := context.Background()
+	to := time.Now().UTC().AddDate(0, 0, 1)
+	from := to.AddDate(0, 0, -gen.Days-1)
+
+	b.Run("facet", func(b *testing.B) {
+		repo := services.NewMongoDashboardRepository(db)
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := repo.TodoActivity(ctx, from, to); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("separate", func(b *testing.B) {
+		todos := db.Collection("todos")
+		inRange := func(field string) bson.M { return bson.M{field: bson.M{"$gte": from, "$lt": to}} }
+		pipelines := []bson.A{
+			{
+				bson.M{"$match": inRange("createdAt")},
+				bson.M{"$group": bson.M{"_id": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$createdAt"}}, "count": bson.M{"$sum": 1}}},
+			},
+			{
+				bson.M{"$match": inRange("completedAt")},
+				bson.M{"$group": bson.M{"_id": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$completedAt"}}, "count": bson.M{"$sum": 1}}},
+			},
+			{
+				bson.M{"$match": inRange("completedAt")},
+				bson.M{"$group": bson.M{"_id": nil, "durations": bson.M{"$push": bson.M{"$subtract": bson.A{"$completedAt", "$createdAt"}}}}},
+			},
+		}
+		b.ReportAllocs()
+		for range b.N {
+			for _, pipeline := range pipelines {
+				cursor, err := todos.Aggregate(ctx, pipeline)
+				if err != nil {
+					b.Fatal(err)
+				}
+				var rows []bson.M
+				if err := cursor.All(ctx, &rows); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestHandlersNormalizeTheirInput(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "  Ana@Example.COM ", "title": "  Pedir toallas  ", "color": " Blue"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var payload struct {
+		Todo struct {
+			Email string `json:"email"`
+			Title string `json:"title"`
+			Color string `json:"color"`
+		} `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Equal(t, "ana@example.com", payload.Todo.Email)
+	require.Equal(t, "Pedir toallas", payload.Todo.Title)
+	require.Equal(t, "blue", payload.Todo.Color)
+	createTodo(t, app.Router, "beto@example.com", "Revisar minibar")
+
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ANA@example.com"), 1)
+	rec = app.Do(http.MethodDelete, "/todos?email=%20Ana@Example.com", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@example.com"))
+	require.Len(t, listTodos(t, app.Router, "/todos?email=beto@example.com"), 1)
+}
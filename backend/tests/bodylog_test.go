@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestBodyLoggerRedactsSensitiveFields(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{BodyLog: middleware.NewBodyLog(&middleware.BodyLogConfig{})})
+	logs := captureLog(t)
+
+	rec := app.Do(http.MethodPost, "/register", map[string]string{
+		"email":    "log@example.com",
+		"password": "super-secret",
+	}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	output := logs.String()
+	require.Contains(t, output, "POST /register -> 201")
+	require.Contains(t, output, `"password":"[REDACTED]"`)
+	require.Contains(t, output, "log@example.com")
+	require.NotContains(t, output, "super-secret")
+}
+
+func TestBodyLoggerSkipsConfiguredRoutes(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{BodyLog: middleware.NewBodyLog(&middleware.BodyLogConfig{SkipRoutes: []string{"POST /login"}})})
+	logs := captureLog(t)
+
+	app.Do(http.MethodPost, "/login", map[string]string{"email": "a@b.com", "password": "x"}, nil)
+
+	require.NotContains(t, logs.String(), "[debug]")
+}
+
+func TestRedactBodyTruncatesLargePayloads(t *testing.T) {
+	body := []byte(`{"title":"` + strings.Repeat("a", 100) + `"}`)
+
+	out := middleware.RedactBody(body, 20)
+	require.True(t, strings.HasPrefix(out, `{"title":"aaaaaaaaaa`))
+	require.Contains(t, out, "...(112 bytes)")
+	require.Equal(t, "[5 bytes no JSON]", middleware.RedactBody([]byte("hello"), 20))
+}
@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type bookingBody struct {
+	ID          string  `json:"id"`
+	RoomID      string  `json:"roomId"`
+	PropertyID  string  `json:"propertyId"`
+	Email       string  `json:"email"`
+	Guests      int     `json:"guests"`
+	CheckIn     string  `json:"checkIn"`
+	CheckOut    string  `json:"checkOut"`
+	Nights      int     `json:"nights"`
+	Status      string  `json:"status"`
+	CancelledAt *string `json:"cancelledAt"`
+}
+
+func decodeBooking(t *testing.T, body []byte) bookingBody {
+	t.Helper()
+	var payload struct {
+		Booking bookingBody `json:"booking"`
+	}
+	testsupport.DecodeData(t, body, &payload)
+	return payload.Booking
+}
+
+func createBooking(t *testing.T, app *testsupport.App, roomID, checkIn, checkOut string) bookingBody {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+		"roomId":   roomID,
+		"email":    "Guest@Example.com",
+		"guests":   2,
+		"checkIn":  checkIn,
+		"checkOut": checkOut,
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	return decodeBooking(t, rec.Body.Bytes())
+}
+
+func TestCreateBooking(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-13")
+	require.Equal(t, "guest@example.com", booking.Email)
+	require.Equal(t, 3, booking.Nights)
+	require.Equal(t, "booked", booking.Status)
+
+	rec := app.Do(http.MethodGet, "/bookings/"+booking.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/bookings?roomId="+room.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), booking.ID)
+}
+
+func TestCreateBookingValidation(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "single", "capacity": 1, "price": 60})
+
+	cases := []struct {
+		payload map[string]interface{}
+		status  int
+		code    string
+	}{
+		{map[string]interface{}{"roomId": room.ID, "email": "a@b.com", "guests": 2, "checkIn": "2025-02-10", "checkOut": "2025-02-11"}, http.StatusBadRequest, "INVALID_BOOKING_INPUT"},
+		{map[string]interface{}{"roomId": room.ID, "email": "a@b.com", "guests": 1, "checkIn": "2025-02-11", "checkOut": "2025-02-11"}, http.StatusBadRequest, "INVALID_STAY_DATES"},
+		{map[string]interface{}{"roomId": room.ID, "email": "a@b.com", "guests": 1, "checkIn": "2024-12-30", "checkOut": "2025-01-02"}, http.StatusBadRequest, "INVALID_STAY_DATES"},
+		{map[string]interface{}{"roomId": room.ID, "email": "a@b.com", "guests": 1, "checkIn": "10/02/2025", "checkOut": "2025-02-11"}, http.StatusBadRequest, "INVALID_STAY_DATES"},
+		{map[string]interface{}{"roomId": "65a000000000000000000000", "email": "a@b.com", "guests": 1, "checkIn": "2025-02-10", "checkOut": "2025-02-11"}, http.StatusUnprocessableEntity, "ROOM_NOT_FOUND"},
+	}
+	for _, tc := range cases {
+		rec := app.Do(http.MethodPost, "/bookings", tc.payload, app.StaffHeaders(t))
+		require.Equal(t, tc.status, rec.Code, tc.payload)
+		require.Contains(t, rec.Body.String(), tc.code)
+	}
+}
+
+func TestOverlappingBookingsAreRejected(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-02-10", "2025-02-13")
+
+	rec := app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+		"roomId": room.ID, "email": "other@example.com", "guests": 1, "checkIn": "2025-02-12", "checkOut": "2025-02-14",
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "ROOM_NOT_AVAILABLE")
+
+	// Check-out day is free for the next guest.
+	createBooking(t, app, room.ID, "2025-02-13", "2025-02-15")
+}
+
+func TestModifyAndCancelBooking(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	first := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+	second := createBooking(t, app, room.ID, "2025-02-14", "2025-02-16")
+
+	rec := app.Do(http.MethodPut, "/bookings/"+second.ID, map[string]interface{}{"checkIn": "2025-02-11"}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = app.Do(http.MethodPut, "/bookings/"+second.ID, map[string]interface{}{"checkIn": "2025-02-12", "guests": 1}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	modified := decodeBooking(t, rec.Body.Bytes())
+	require.Equal(t, "2025-02-12", modified.CheckIn)
+	require.Equal(t, 4, modified.Nights)
+	require.Equal(t, 1, modified.Guests)
+
+	rec = app.Do(http.MethodPost, "/bookings/"+first.ID+"/cancel", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	cancelled := decodeBooking(t, rec.Body.Bytes())
+	require.Equal(t, "cancelled", cancelled.Status)
+	require.NotNil(t, cancelled.CancelledAt)
+
+	rec = app.Do(http.MethodPost, "/bookings/"+first.ID+"/cancel", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "BOOKING_STATE_CONFLICT")
+
+	rec = app.Do(http.MethodPut, "/bookings/"+first.ID, map[string]interface{}{"guests": 1}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	// The cancelled nights can be booked again.
+	createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+}
+
+func TestRoomAvailability(t *testing.T) {
+	app := testsupport.NewApp()
+	booked := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	free := createRoom(t, app, map[string]interface{}{"number": "102", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, booked.ID, "2025-02-10", "2025-02-13")
+
+	rec := app.Do(http.MethodGet, "/rooms/availability?from=2025-02-12&to=2025-02-14", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Rooms []roomBody `json:"rooms"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Rooms, 1)
+	require.Equal(t, free.ID, body.Rooms[0].ID)
+
+	rec = app.Do(http.MethodGet, "/rooms/availability?from=2025-02-13&to=2025-02-14", nil, nil)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Rooms, 2)
+
+	rec = app.Do(http.MethodGet, "/rooms/availability?from=2025-02-14", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// parallelStatuses runs request concurrently n times and returns the status codes.
+func parallelStatuses(n int, request func(i int) int) []int {
+	statuses := make([]int, n)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			statuses[i] = request(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return statuses
+}
+
+func countStatus(statuses []int, status int) int {
+	count := 0
+	for _, s := range statuses {
+		if s == status {
+			count++
+		}
+	}
+	return count
+}
+
+// The concurrency tests below run against the memory repository, which
+// serializes writes with a lock; the Mongo transactions they stand for are
+// covered by the TestMongoConcurrent* tests, which need MONGO_URI.
+
+func TestConcurrentBookingsCannotOverbook(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+
+	// Every request shares at least the night of 2025-02-12.
+	ranges := [][2]string{
+		{"2025-02-10", "2025-02-13"},
+		{"2025-02-12", "2025-02-13"},
+		{"2025-02-11", "2025-02-15"},
+		{"2025-02-12", "2025-02-20"},
+	}
+	const attempts = 40
+	staff := app.StaffHeaders(t)
+	statuses := parallelStatuses(attempts, func(i int) int {
+		stay := ranges[i%len(ranges)]
+		return app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+			"roomId":   room.ID,
+			"email":    "guest@example.com",
+			"guests":   1,
+			"checkIn":  stay[0],
+			"checkOut": stay[1],
+		}, staff).Code
+	})
+
+	require.Equal(t, 1, countStatus(statuses, http.StatusCreated), statuses)
+	require.Equal(t, attempts-1, countStatus(statuses, http.StatusConflict), statuses)
+
+	rec := app.Do(http.MethodGet, "/bookings?roomId="+room.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Bookings []bookingBody `json:"bookings"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Bookings, 1)
+}
+
+func TestConcurrentModificationsCannotOverbook(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+
+	var ids []string
+	for _, stay := range [][2]string{
+		{"2025-03-10", "2025-03-11"},
+		{"2025-03-11", "2025-03-12"},
+		{"2025-03-12", "2025-03-13"},
+		{"2025-03-13", "2025-03-14"},
+		{"2025-03-14", "2025-03-15"},
+	} {
+		ids = append(ids, createBooking(t, app, room.ID, stay[0], stay[1]).ID)
+	}
+
+	// All bookings try to move onto the same night at once.
+	staff := app.StaffHeaders(t)
+	statuses := parallelStatuses(len(ids), func(i int) int {
+		return app.Do(http.MethodPut, "/bookings/"+ids[i], map[string]interface{}{
+			"checkIn":  "2025-03-20",
+			"checkOut": "2025-03-21",
+		}, staff).Code
+	})
+
+	require.Equal(t, 1, countStatus(statuses, http.StatusOK), statuses)
+	require.Equal(t, len(ids)-1, countStatus(statuses, http.StatusConflict), statuses)
+}
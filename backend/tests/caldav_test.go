@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/ical"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestICalTodoRoundTrip(t *testing.T) {
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	todo := ical.Todo{
+		UID:         "abc-123",
+		Summary:     "Revisar minibar; reponer agua, gaseosas y snacks de la habitacion 101 antes del check-in\nurgente",
+		Created:     created,
+		Stamp:       created,
+		Completed:   true,
+		CompletedAt: created.Add(time.Hour),
+		RRule:       "FREQ=WEEKLY",
+	}
+	data := ical.Encode("-//test//ES", todo)
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), 75, line)
+	}
+	require.Contains(t, string(data), `SUMMARY:Revisar minibar\; reponer agua\, gaseosas`)
+
+	decoded, err := ical.DecodeTodo(data)
+	require.NoError(t, err)
+	require.Equal(t, todo, decoded)
+
+	// Alarms nested in the todo are skipped and STATUS wins over
+	// PERCENT-COMPLETE.
+	decoded, err = ical.DecodeTodo([]byte("BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:x\nSUMMARY:Llamar\n  al tecnico\nPERCENT-COMPLETE:100\n" +
+		"BEGIN:VALARM\nSUMMARY:alarma\nEND:VALARM\nSTATUS:IN-PROCESS\nEND:VTODO\nEND:VCALENDAR\n"))
+	require.NoError(t, err)
+	require.Equal(t, ical.Todo{UID: "x", Summary: "Llamar al tecnico"}, decoded)
+
+	_, err = ical.DecodeTodo([]byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:x\nEND:VEVENT\nEND:VCALENDAR\n"))
+	require.ErrorIs(t, err, ical.ErrNoTodo)
+}
+
+func TestCalDAVSync(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@hotel.com")
+	app.Register(t, "beto@hotel.com")
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("ana@hotel.com:secret"))
+
+	dav := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", basic)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		app.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := dav(http.MethodOptions, "/caldav/", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("DAV"), "calendar-access")
+	rec = dav(http.MethodGet, "/.well-known/caldav", "", nil)
+	require.Equal(t, http.StatusMovedPermanently, rec.Code)
+	require.Equal(t, "/caldav/", rec.Header().Get("Location"))
+
+	req := httptest.NewRequest("PROPFIND", "/caldav/", nil)
+	rec = httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Header().Get("WWW-Authenticate"), "Basic")
+
+	// Discovery: principal, calendar home and the todos calendar.
+	rec = dav("PROPFIND", "/caldav/", `<d:propfind xmlns:d="DAV:"><d:prop><d:current-user-principal/><d:quota-used-bytes/></d:prop></d:propfind>`, map[string]string{"Depth": "0"})
+	require.Equal(t, http.StatusMultiStatus, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "<d:href>/caldav/principals/ana@hotel.com/</d:href>")
+	require.Contains(t, rec.Body.String(), "404 Not Found", "unknown properties are reported missing")
+	rec = dav("PROPFIND", "/caldav/principals/ana@hotel.com/", `<propfind xmlns="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><prop><c:calendar-home-set/></prop></propfind>`, map[string]string{"Depth": "0"})
+	require.Contains(t, rec.Body.String(), "<c:calendar-home-set><d:href>/caldav/calendars/ana@hotel.com/</d:href>")
+	rec = dav("PROPFIND", "/caldav/calendars/ana@hotel.com/", "", map[string]string{"Depth": "1"})
+	require.Contains(t, rec.Body.String(), `<c:comp name="VTODO"/>`)
+	require.Equal(t, http.StatusForbidden, dav("PROPFIND", "/caldav/calendars/beto@hotel.com/todos/", "", nil).Code)
+
+	// Todos created through the API show up as VTODO resources.
+	todoID := createTodo(t, app.Router, "ana@hotel.com", "Pedir toallas").ID
+	calendar := "/caldav/calendars/ana@hotel.com/todos/"
+	rec = dav("PROPFIND", calendar, "", map[string]string{"Depth": "1"})
+	require.Equal(t, http.StatusMultiStatus, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "<d:href>"+calendar+todoID+".ics</d:href>")
+	ctag := between(rec.Body.String(), "<cs:getctag>", "</cs:getctag>")
+
+	rec = dav(http.MethodGet, calendar+todoID+".ics", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "UID:"+todoID+"\r\n")
+	require.Contains(t, rec.Body.String(), "SUMMARY:Pedir toallas\r\n")
+	require.Contains(t, rec.Body.String(), "STATUS:NEEDS-ACTION\r\n")
+	etag := rec.Header().Get("ETag")
+
+	// A client completes it; a stale ETag is refused.
+	completed := strings.Replace(rec.Body.String(), "STATUS:NEEDS-ACTION", "STATUS:COMPLETED", 1)
+	require.Equal(t, http.StatusPreconditionFailed, dav(http.MethodPut, calendar+todoID+".ics", completed, map[string]string{"If-Match": `"viejo"`}).Code)
+	rec = dav(http.MethodPut, calendar+todoID+".ics", completed, map[string]string{"If-Match": etag})
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	require.NotEqual(t, etag, rec.Header().Get("ETag"))
+	require.Contains(t, app.Do(http.MethodGet, "/todos?email=ana@hotel.com", nil, nil).Body.String(), `"completed":true`)
+
+	// A client creates a todo under its own name and UID.
+	vtodo := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Apple Inc.//Reminders//EN\r\nBEGIN:VTODO\r\nUID:7F3A-11\r\n" +
+		"DTSTAMP:20240501T100000Z\r\nSUMMARY:Llamar al tecnico\r\nRRULE:FREQ=WEEKLY;INTERVAL=1\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	rec = dav(http.MethodPut, calendar+"7F3A-11.ics", vtodo, map[string]string{"If-None-Match": "*"})
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Equal(t, http.StatusPreconditionFailed, dav(http.MethodPut, calendar+"7F3A-11.ics", vtodo, map[string]string{"If-None-Match": "*"}).Code)
+	list := app.Do(http.MethodGet, "/todos?email=ana@hotel.com", nil, nil).Body.String()
+	require.Contains(t, list, `"title":"Llamar al tecnico"`)
+	require.Contains(t, list, `"recurrence":"weekly"`)
+	rec = dav(http.MethodGet, calendar+"7F3A-11.ics", "", nil)
+	require.Contains(t, rec.Body.String(), "UID:7F3A-11\r\n")
+	require.Equal(t, http.StatusBadRequest, dav(http.MethodPut, calendar+"otra.ics", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", nil).Code)
+
+	rec = dav("REPORT", calendar, `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/><c:calendar-data/></d:prop>`+
+		`<d:href>`+calendar+`7F3A-11.ics</d:href><d:href>`+calendar+`nada.ics</d:href></c:calendar-multiget>`, map[string]string{"Depth": "1"})
+	require.Equal(t, http.StatusMultiStatus, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "SUMMARY:Llamar al tecnico")
+	require.Contains(t, rec.Body.String(), "<d:status>HTTP/1.1 404 Not Found</d:status>")
+	rec = dav("REPORT", calendar, `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/></d:prop>`+
+		`<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO"/></c:comp-filter></c:filter></c:calendar-query>`, nil)
+	require.Equal(t, 2, strings.Count(rec.Body.String(), "<d:response>"))
+	require.Equal(t, http.StatusForbidden, dav("REPORT", calendar, `<d:sync-collection xmlns:d="DAV:"/>`, nil).Code)
+
+	// Deleting from the client moves the todo to the trash.
+	require.Equal(t, http.StatusNoContent, dav(http.MethodDelete, calendar+"7F3A-11.ics", "", nil).Code)
+	require.Equal(t, http.StatusNotFound, dav(http.MethodGet, calendar+"7F3A-11.ics", "", nil).Code)
+	require.Contains(t, app.Do(http.MethodGet, "/todos?email=ana@hotel.com&trashed=true", nil, nil).Body.String(), "Llamar al tecnico")
+
+	rec = dav("PROPFIND", calendar, `<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop><cs:getctag/></d:prop></d:propfind>`, map[string]string{"Depth": "0"})
+	require.NotEqual(t, ctag, between(rec.Body.String(), "<cs:getctag>", "</cs:getctag>"))
+	require.Equal(t, 1, strings.Count(rec.Body.String(), "<d:response>"))
+}
+
+func between(text, start, end string) string {
+	_, rest, _ := strings.Cut(text, start)
+	value, _, _ := strings.Cut(rest, end)
+	return value
+}
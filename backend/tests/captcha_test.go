@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/captcha"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestRegistrationRequiresCaptcha(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Captcha.Enable()
+
+	rec := app.Do(http.MethodPost, "/register", map[string]string{"email": "ana@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "CAPTCHA_REQUIRED")
+
+	rec = app.Do(http.MethodPost, "/register", map[string]string{
+		"email": "ana@example.com", "password": "secret", "captcha": "bot",
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_CAPTCHA")
+
+	app.Captcha.SetDown(true)
+	rec = app.Do(http.MethodPost, "/register", map[string]string{
+		"email": "ana@example.com", "password": "secret", "captcha": testsupport.CaptchaToken,
+	}, nil)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), "CAPTCHA_UNAVAILABLE")
+	app.Captcha.SetDown(false)
+
+	rec = app.Do(http.MethodPost, "/register", map[string]string{
+		"email": "ana@example.com", "password": "secret", "captcha": testsupport.CaptchaToken,
+	}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+}
+
+func TestLoginRequiresCaptchaAfterFailures(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	app.Captcha.Enable()
+	login := func(password, token string) *httptest.ResponseRecorder {
+		return app.Do(http.MethodPost, "/login", map[string]string{
+			"email": "ana@example.com", "password": password, "captcha": token,
+		}, nil)
+	}
+
+	// A correct login clears the earlier failures.
+	require.Equal(t, http.StatusUnauthorized, login("wrong", "").Code)
+	require.Equal(t, http.StatusOK, login("secret", "").Code)
+
+	for range testsupport.CaptchaFailures {
+		require.Equal(t, http.StatusUnauthorized, login("wrong", "").Code)
+	}
+	rec := login("secret", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "CAPTCHA_REQUIRED")
+	rec = login("secret", "bot")
+	require.Contains(t, rec.Body.String(), "INVALID_CAPTCHA")
+
+	// Other accounts are not affected.
+	rec = app.Do(http.MethodPost, "/login", map[string]string{"email": "beto@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = login("secret", testsupport.CaptchaToken)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, http.StatusOK, login("secret", "").Code)
+
+	// The failures are forgotten once the window closes.
+	for range testsupport.CaptchaFailures {
+		login("wrong", "")
+	}
+	app.Clock.Advance(16 * time.Minute)
+	require.Equal(t, http.StatusOK, login("secret", "").Code)
+}
+
+func TestSiteVerifier(t *testing.T) {
+	var form map[string]string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		form = map[string]string{
+			"secret":   r.PostForm.Get("secret"),
+			"response": r.PostForm.Get("response"),
+			"remoteip": r.PostForm.Get("remoteip"),
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":     r.PostForm.Get("response") == "solved",
+			"error-codes": []string{"invalid-input-response"},
+		})
+	}))
+	defer provider.Close()
+
+	ctx := context.Background()
+	verifier := captcha.NewSiteVerifier(provider.URL, "site-secret", nil)
+	require.NoError(t, verifier.Verify(ctx, "solved", "192.0.2.1"))
+	require.Equal(t, map[string]string{"secret": "site-secret", "response": "solved", "remoteip": "192.0.2.1"}, form)
+	require.ErrorIs(t, verifier.Verify(ctx, "robot", ""), captcha.ErrRejected)
+	require.ErrorIs(t, verifier.Verify(ctx, "", ""), captcha.ErrRejected)
+
+	bypass := captcha.Bypass{Verifier: verifier, Token: "e2e-secret"}
+	form = nil
+	require.NoError(t, bypass.Verify(ctx, "e2e-secret", ""))
+	require.Nil(t, form)
+	require.ErrorIs(t, bypass.Verify(ctx, "robot", ""), captcha.ErrRejected)
+
+	_, err := captcha.Open("clippy", "", "")
+	require.Error(t, err)
+	disabled, err := captcha.Open("", "", "")
+	require.NoError(t, err)
+	require.Nil(t, disabled)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	err = captcha.NewSiteVerifier(down.URL, "site-secret", nil).Verify(ctx, "solved", "")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, captcha.ErrRejected)
+}
@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func roomStatus(t *testing.T, app *testsupport.App, id string) string {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/rooms/"+id, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Room roomBody `json:"room"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Room.Status
+}
+
+func TestCheckInAndCheckOutUpdateRoomStatus(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-01", "2025-01-03")
+
+	rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-out", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "BOOKING_STATE_CONFLICT")
+
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-in", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	checkedIn := decodeBooking(t, rec.Body.Bytes())
+	require.Equal(t, "checked_in", checkedIn.Status)
+	require.Equal(t, "occupied", roomStatus(t, app, room.ID))
+
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/cancel", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-out", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "checked_out", decodeBooking(t, rec.Body.Bytes()).Status)
+	require.Equal(t, "cleaning", roomStatus(t, app, room.ID))
+
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-in", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestCheckInBeforeArrivalIsRejected(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+
+	rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-in", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "CHECK_IN_OUTSIDE_STAY")
+	require.Equal(t, "available", roomStatus(t, app, room.ID))
+}
+
+func TestCheckOutCreatesCleaningTodos(t *testing.T) {
+	app := testsupport.NewApp()
+	first := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	second := createRoom(t, app, map[string]interface{}{"number": "102", "type": "double", "capacity": 2, "price": 100})
+
+	for _, room := range []roomBody{first, second} {
+		booking := createBooking(t, app, room.ID, "2025-01-01", "2025-01-02")
+		require.Equal(t, http.StatusOK, app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-in", nil, app.StaffHeaders(t)).Code)
+		require.Equal(t, http.StatusOK, app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-out", nil, app.StaffHeaders(t)).Code)
+	}
+
+	rec := app.Do(http.MethodGet, "/rooms/"+first.ID+"/todos", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Todos []struct {
+			Email  string `json:"email"`
+			Title  string `json:"title"`
+			RoomID string `json:"roomId"`
+		} `json:"todos"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Todos, 1)
+	require.Equal(t, "Limpiar habitacion 101", body.Todos[0].Title)
+	require.Equal(t, first.ID, body.Todos[0].RoomID)
+	require.Equal(t, testsupport.Housekeepers[0], body.Todos[0].Email)
+
+	// Assignments rotate through the housekeeping staff.
+	rec = app.Do(http.MethodGet, "/todos?email="+testsupport.Housekeepers[1], nil, nil)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Todos, 1)
+	require.Equal(t, second.ID, body.Todos[0].RoomID)
+
+	rec = app.Do(http.MethodGet, "/rooms/bad-id/todos", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
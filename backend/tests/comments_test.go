@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := map[string][]string{
+		"@Ana@Hotel.com mira esto":                {"ana@hotel.com"},
+		"hola @ana@hotel.com, y @beto@hotel.com.": {"ana@hotel.com", "beto@hotel.com"},
+		"(@ana@hotel.com) @ana@hotel.com":         {"ana@hotel.com"},
+		"ana@hotel.com sin arroba":                nil,
+		"correo@@ana@hotel.com":                   nil,
+		"@ana sin dominio":                        nil,
+	}
+	for body, want := range cases {
+		require.Equal(t, want, services.ParseMentions(body), body)
+	}
+}
+
+type notificationBody struct {
+	ID        string  `json:"id"`
+	Kind      string  `json:"kind"`
+	TodoID    string  `json:"todoId"`
+	CommentID string  `json:"commentId"`
+	Author    string  `json:"author"`
+	ReadAt    *string `json:"readAt"`
+}
+
+func inbox(t *testing.T, app *testsupport.App, headers map[string]string, query string) []notificationBody {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/notifications"+query, nil, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		Notifications []notificationBody `json:"notifications"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Notifications
+}
+
+func TestCommentMentionsNotifyCollaborators(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	carla := app.LoginAs(t, "carla@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID + "/comments"
+
+	rec := app.Do(http.MethodPost, "/todos/"+todo.ID+"/reactions", map[string]string{"emoji": "👀"}, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = app.Do(http.MethodPost, path, map[string]string{"body": "@beto@hotel.com y @carla@hotel.com: faltan toallas. @ana@hotel.com"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Comment struct {
+			ID       string   `json:"id"`
+			Mentions []string `json:"mentions"`
+		} `json:"comment"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, []string{"beto@hotel.com"}, created.Comment.Mentions, "only collaborators other than the author are mentioned")
+
+	notifications := inbox(t, app, beto, "")
+	require.Len(t, notifications, 1)
+	require.Equal(t, services.NotificationMention, notifications[0].Kind)
+	require.Equal(t, todo.ID, notifications[0].TodoID)
+	require.Equal(t, created.Comment.ID, notifications[0].CommentID)
+	require.Equal(t, "ana@hotel.com", notifications[0].Author)
+	require.Empty(t, inbox(t, app, carla, ""))
+	require.Empty(t, inbox(t, app, ana, ""))
+
+	msg := waitForMail(t, app, "ana@hotel.com te menciono")
+	require.Equal(t, "beto@hotel.com", msg.To)
+	require.Contains(t, msg.Body, "faltan toallas")
+
+	rec = app.Do(http.MethodPost, "/notifications/"+notifications[0].ID+"/read", nil, carla)
+	require.Equal(t, http.StatusNotFound, rec.Code, "notifications of other users are not found")
+	rec = app.Do(http.MethodPost, "/notifications/"+notifications[0].ID+"/read", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Empty(t, inbox(t, app, beto, "?unread=true"))
+	require.NotNil(t, inbox(t, app, beto, "")[0].ReadAt)
+
+	// Anyone signed in may comment, and the owner can always be mentioned.
+	rec = app.Do(http.MethodPost, path, map[string]string{"body": "Listo @ana@hotel.com"}, carla)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Len(t, inbox(t, app, ana, ""), 1)
+
+	rec = app.Do(http.MethodGet, path, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var listed struct {
+		Comments []struct {
+			Email string `json:"email"`
+		} `json:"comments"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &listed)
+	require.Len(t, listed.Comments, 2)
+	require.Equal(t, "ana@hotel.com", listed.Comments[0].Email)
+}
+
+func TestCommentErrors(t *testing.T) {
+	app := testsupport.NewApp()
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID + "/comments"
+
+	rec := app.Do(http.MethodPost, path, map[string]string{"body": "hola"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = app.Do(http.MethodGet, "/notifications", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	rec = app.Do(http.MethodPost, path, map[string]string{"body": "  "}, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_COMMENT")
+	rec = app.Do(http.MethodPost, "/todos/000000000000000000000000/comments", map[string]string{"body": "hola"}, ana)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestErasureRemovesCommentsAndMentions(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID + "/comments"
+
+	rec := app.Do(http.MethodPost, path, map[string]string{"body": "Voy yo, @ana@hotel.com"}, beto)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = app.Do(http.MethodPost, path, map[string]string{"body": "Gracias @beto@hotel.com"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Len(t, inbox(t, app, ana, ""), 1)
+
+	rec = app.Do(http.MethodDelete, "/users/me?mode=gdpr", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	require.Empty(t, inbox(t, app, ana, ""), "notifications from the erased user are gone")
+	rec = app.Do(http.MethodGet, path, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var listed struct {
+		Comments []struct {
+			Email    string   `json:"email"`
+			Mentions []string `json:"mentions"`
+		} `json:"comments"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &listed)
+	require.Len(t, listed.Comments, 1)
+	require.Equal(t, "ana@hotel.com", listed.Comments[0].Email)
+	require.Empty(t, listed.Comments[0].Mentions)
+}
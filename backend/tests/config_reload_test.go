@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// writeConfigFile writes a config file and resets the overrides after the
+// test.
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Cleanup(func() { _, _ = config.LoadFile("") })
+}
+
+func TestConfigFileOverridesTheEnvironment(t *testing.T) {
+	t.Setenv("QUOTA_MAX_TODOS", "100")
+	t.Setenv("PORT", "9000")
+	path := filepath.Join(t.TempDir(), "api.env")
+	writeConfigFile(t, path, "# limites\nQUOTA_MAX_TODOS=50\nALLOWED_ORIGINS=\"https://app.hotel.com, https://admin.hotel.com\"\n")
+
+	cfg, err := config.LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 50, cfg.Quotas.MaxTodos)
+	require.Equal(t, []string{"https://app.hotel.com", "https://admin.hotel.com"}, cfg.AllowedOrigins)
+	require.Equal(t, "9000", cfg.Port, "variables missing from the file come from the environment")
+
+	writeConfigFile(t, path, "QUOTA_MAX_TODOS\n")
+	_, err = config.LoadFile(path)
+	require.Error(t, err)
+}
+
+func TestWatcherAppliesOnlyReloadableChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.env")
+	writeConfigFile(t, path, "QUOTA_MAX_TODOS=50\n")
+	current, err := config.LoadFile(path)
+	require.NoError(t, err)
+
+	var applied []config.Config
+	watcher := config.NewWatcher(current, func(next config.Config, changes []config.Change, source string) {
+		require.Equal(t, config.ReloadSignal, source)
+		applied = append(applied, next)
+	})
+
+	changes, err := watcher.Reload(config.ReloadSignal)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+	require.Empty(t, applied, "nothing changed")
+
+	writeConfigFile(t, path, "QUOTA_MAX_TODOS=10\nLOG_BODIES=true\nPORT=9999\n")
+	changes, err = watcher.Reload(config.ReloadSignal)
+	require.NoError(t, err)
+	require.Equal(t, []config.Change{
+		{Key: "LOG_BODIES", Old: "false", New: "true"},
+		{Key: "QUOTA_MAX_TODOS", Old: "50", New: "10"},
+	}, changes)
+	require.Len(t, applied, 1)
+	require.True(t, current.RequiresRestart(applied[0]), "PORT is only read at startup")
+	require.Equal(t, 10, watcher.Current().Quotas.MaxTodos)
+
+	writeConfigFile(t, path, "not a setting\n")
+	_, err = watcher.Reload(config.ReloadSignal)
+	require.Error(t, err)
+	require.Equal(t, 10, watcher.Current().Quotas.MaxTodos, "a broken file keeps the configuration in use")
+}
+
+func TestAllowedOriginsCanBeReplacedAtRuntime(t *testing.T) {
+	origins := middleware.NewOrigins([]string{"https://app.hotel.com"})
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{Origins: origins})
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		return app.Do(http.MethodOptions, "/todos", nil, map[string]string{
+			"Origin":                        origin,
+			"Access-Control-Request-Method": http.MethodGet,
+		})
+	}
+
+	require.Equal(t, "https://app.hotel.com", preflight("https://app.hotel.com").Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, preflight("https://new.hotel.com").Header().Get("Access-Control-Allow-Origin"))
+
+	origins.Set([]string{"https://new.hotel.com"})
+	require.Equal(t, "https://new.hotel.com", preflight("https://new.hotel.com").Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, preflight("https://app.hotel.com").Header().Get("Access-Control-Allow-Origin"))
+}
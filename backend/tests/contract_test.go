@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/api"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func newContractRouter(t *testing.T, mode string, handler gin.HandlerFunc) *gin.Engine {
+	validator, err := middleware.ContractValidator(api.OpenAPISpec, mode)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(validator)
+	router.GET("/healthz", handler)
+	return router
+}
+
+func TestContractValidatorFailsOnSchemaDrift(t *testing.T) {
+	logs := captureLog(t)
+	router := newContractRouter(t, middleware.ContractFail, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": 1})
+	})
+
+	rec := testsupport.PerformRequest(router, http.MethodGet, "/healthz", nil, nil)
+
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Contains(t, rec.Body.String(), "CONTRACT_VIOLATION")
+	require.Contains(t, logs.String(), "respuesta fuera de contrato")
+}
+
+func TestContractValidatorLogModeKeepsResponse(t *testing.T) {
+	logs := captureLog(t)
+	router := newContractRouter(t, middleware.ContractLog, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"estado": "ok"})
+	})
+
+	rec := testsupport.PerformRequest(router, http.MethodGet, "/healthz", nil, nil)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"estado":"ok"}`, rec.Body.String())
+	require.Contains(t, logs.String(), "respuesta fuera de contrato")
+}
+
+func TestOpenAPISpecIsPublished(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/openapi.yaml", nil, nil)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "openapi: 3.0.3")
+}
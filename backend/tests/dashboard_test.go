@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// dashboard fetches a dashboard summary as the manager and decodes it
+// into out.
+func dashboard(t *testing.T, app *testsupport.App, path string, out interface{}) {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/admin/dashboard/"+path, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	testsupport.DecodeData(t, rec.Body.Bytes(), out)
+}
+
+func TestDashboardUsersAndSignups(t *testing.T) {
+	app := testsupport.NewApp()
+	app.StaffHeaders(t)
+	app.LoginAs(t, "recepcion@hotel.com", services.RoleFrontDesk)
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	app.Clock.Advance(24 * time.Hour)
+	app.Register(t, "carla@example.com")
+
+	var users services.UserSummary
+	dashboard(t, app, "users", &users)
+	require.Equal(t, int64(5), users.Total)
+	require.Equal(t, int64(2), users.Staff)
+	require.Equal(t, map[string]int64{services.RoleManager: 1, services.RoleFrontDesk: 1}, users.ByRole)
+
+	var signups struct {
+		Days []services.DailyCount `json:"days"`
+	}
+	dashboard(t, app, "signups?from=2024-12-31&to=2025-01-03", &signups)
+	require.Equal(t, []services.DailyCount{
+		{Day: "2024-12-31", Count: 0},
+		{Day: "2025-01-01", Count: 2},
+		{Day: "2025-01-02", Count: 1},
+	}, signups.Days, "staff created without registering have no signup date")
+
+	dashboard(t, app, "signups", &signups)
+	require.Len(t, signups.Days, 30, "the default range is the last 30 days")
+	require.Equal(t, "2025-01-02", signups.Days[29].Day)
+	require.Equal(t, int64(1), signups.Days[29].Count)
+}
+
+func TestDashboardTodos(t *testing.T) {
+	app := testsupport.NewApp()
+	first := createTodo(t, app.Router, "ana@example.com", "Primera")
+	second := createTodo(t, app.Router, "ana@example.com", "Segunda")
+	app.Clock.Advance(24 * time.Hour)
+	third := createTodo(t, app.Router, "ana@example.com", "Tercera")
+	for _, id := range []string{first.ID, second.ID} {
+		rec := app.Do(http.MethodPut, "/todos/"+id, map[string]interface{}{"completed": true}, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `"completedAt":"2025-01-02T10:00:00Z"`)
+	}
+	rec := app.Do(http.MethodPut, "/todos/"+second.ID, map[string]interface{}{"completed": false}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "completedAt", "reopening a todo clears its completion date")
+	rec = app.Do(http.MethodDelete, "/todos/"+first.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	app.Clock.Advance(2 * time.Hour)
+	rec = app.Do(http.MethodPut, "/todos/"+third.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var todos struct {
+		Totals services.TodoTotals `json:"totals"`
+		Days   []services.TodoDay  `json:"days"`
+	}
+	dashboard(t, app, "todos?from=2025-01-01&to=2025-01-03", &todos)
+	require.Equal(t, []services.TodoDay{
+		{Day: "2025-01-01", Created: 2},
+		{Day: "2025-01-02", Created: 1, Completed: 2},
+	}, todos.Days, "trashed todos still count")
+	require.Equal(t, services.TodoTotals{
+		Created:   3,
+		Completed: 2,
+		// The todos took 24 and 2 hours.
+		CompletionSeconds: services.CompletionTimes{P50: 2 * 3600, P90: 24 * 3600, P99: 24 * 3600},
+	}, todos.Totals)
+
+	dashboard(t, app, "todos?from=2025-01-03&to=2025-01-04", &todos)
+	require.Equal(t, services.TodoTotals{}, todos.Totals)
+}
+
+// percentileDashboardRepo answers like a server that computes the
+// percentiles with $percentile.
+type percentileDashboardRepo struct {
+	*testsupport.MemoryDashboardRepo
+}
+
+func (percentileDashboardRepo) TodoActivity(context.Context, time.Time, time.Time) (services.TodoActivity, error) {
+	return services.TodoActivity{
+		Days:        []services.TodoDay{{Day: "2025-01-01", Created: 3, Completed: 2}},
+		Percentiles: []float64{1500.5, 60000, 3.6e6},
+	}, nil
+}
+
+func TestDashboardTodosWithServerPercentiles(t *testing.T) {
+	app := testsupport.NewAppWithOptions(handlers.RouterConfig{ContractMode: middleware.ContractFail},
+		testsupport.Options{Dashboard: percentileDashboardRepo{}})
+
+	var todos struct {
+		Totals services.TodoTotals `json:"totals"`
+	}
+	dashboard(t, app, "todos?from=2025-01-01&to=2025-01-02", &todos)
+	require.Equal(t, services.TodoTotals{
+		Created: 3, Completed: 2, CompletionSeconds: services.CompletionTimes{P50: 1, P90: 60, P99: 3600},
+	}, todos.Totals)
+}
+
+func TestDashboardWebhookFailureRate(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+
+	broker := &flakyPublisher{failures: 3}
+	relay := services.NewOutboxRelay(app.Outbox, broker, app.DeadLetters, services.OutboxRelayConfig{Backoff: time.Second, MaxAttempts: 2}, app.Clock.Now)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, _ = relay.Relay(ctx)
+		app.Clock.Advance(time.Second)
+	}
+	require.Len(t, app.Outbox.OfType(events.UserRegistered), 2)
+
+	var webhooks struct {
+		Totals services.DeliveryStats `json:"totals"`
+		Days   []services.DeliveryDay `json:"days"`
+	}
+	dashboard(t, app, "webhooks?from=2025-01-01&to=2025-01-02", &webhooks)
+	require.Equal(t, services.DeliveryStats{
+		Events: 2, Delivered: 1, Dead: 1, Attempts: 4, FailedAttempts: 3, FailureRate: 75,
+	}, webhooks.Totals)
+	require.Len(t, webhooks.Days, 1)
+	require.Equal(t, "2025-01-01", webhooks.Days[0].Day)
+	require.Equal(t, webhooks.Totals, webhooks.Days[0].DeliveryStats)
+}
+
+func TestDashboardStorage(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	createTodo(t, app.Router, "ana@example.com", "Tarea")
+
+	var storage services.StorageSummary
+	dashboard(t, app, "storage", &storage)
+	require.Len(t, storage.Collections, 2)
+	require.Equal(t, "users", storage.Collections[0].Name, "the largest collection comes first")
+	require.Equal(t, int64(1), storage.Collections[1].Documents)
+	require.Equal(t, storage.Collections[0].StorageBytes+storage.Collections[1].StorageBytes, storage.StorageBytes)
+	require.Equal(t, int64(8192), storage.IndexBytes)
+}
+
+func TestDashboardAccess(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+
+	rec := app.Do(http.MethodGet, "/admin/dashboard/users", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	frontDesk := app.LoginAs(t, "recepcion@hotel.com", services.RoleFrontDesk)
+	rec = app.Do(http.MethodGet, "/admin/dashboard/users", nil, frontDesk)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = app.Do(http.MethodGet, "/admin/dashboard/users", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = app.Do(http.MethodGet, "/admin/dashboard/users", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	for _, query := range []string{"?from=ayer", "?to=2025-13-01", "?from=2025-01-02&to=2025-01-01", "?from=2023-01-01&to=2025-01-01"} {
+		rec = app.Do(http.MethodGet, "/admin/dashboard/todos"+query, nil, adminHeaders)
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+		require.Contains(t, rec.Body.String(), "INVALID_DASHBOARD_RANGE")
+	}
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type deadLetterBody struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Target    string          `json:"target"`
+	Reference string          `json:"reference"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError"`
+}
+
+func newDeadLetterApp() *testsupport.App {
+	return testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+}
+
+func listDeadLetters(t *testing.T, app *testsupport.App, query string) []deadLetterBody {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/admin/dead-letters"+query, nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		DeadLetters []deadLetterBody `json:"deadLetters"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Len(t, payload.DeadLetters, int(testsupport.DecodeMeta(t, rec.Body.Bytes()).Pagination.Total))
+	return payload.DeadLetters
+}
+
+func retryDeadLetter(t *testing.T, app *testsupport.App, id string) deadLetterBody {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/admin/dead-letters/"+id+"/retry", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		DeadLetter deadLetterBody `json:"deadLetter"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	return payload.DeadLetter
+}
+
+func TestExhaustedEventsAreDeadLettered(t *testing.T) {
+	app := newDeadLetterApp()
+	rec := app.Do(http.MethodPost, "/register", map[string]string{"email": "ana@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	broker := &flakyPublisher{failures: 10}
+	relay := services.NewOutboxRelay(app.Outbox, broker, app.DeadLetters, services.OutboxRelayConfig{Backoff: time.Second, MaxAttempts: 2}, app.Clock.Now)
+	ctx := context.Background()
+	published, _ := relay.Relay(ctx)
+	require.Zero(t, published)
+	require.Empty(t, app.DeadLetters.All())
+	app.Clock.Advance(time.Second)
+	published, _ = relay.Relay(ctx)
+	require.Zero(t, published)
+
+	msg := app.Outbox.OfType(events.UserRegistered)[0]
+	require.Equal(t, services.OutboxDead, msg.Status)
+	app.Clock.Advance(time.Hour)
+	published, _ = relay.Relay(ctx)
+	require.Zero(t, published)
+	require.Len(t, broker.received, 2, "dead messages are not relayed again")
+
+	letters := listDeadLetters(t, app, "?kind=event&status=pending")
+	require.Len(t, letters, 1)
+	require.Equal(t, events.UserRegistered, letters[0].Target)
+	require.Equal(t, msg.ID, letters[0].Reference)
+	require.Equal(t, 2, letters[0].Attempts)
+	require.Equal(t, "broker unavailable", letters[0].LastError)
+	var event events.Event
+	require.NoError(t, json.Unmarshal(letters[0].Payload, &event))
+	require.Equal(t, msg.ID, event.ID)
+
+	rec = app.Do(http.MethodGet, "/admin/dead-letters/"+letters[0].ID, nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	retried := retryDeadLetter(t, app, letters[0].ID)
+	require.Equal(t, services.DeadLetterRetried, retried.Status)
+	require.Equal(t, 3, retried.Attempts)
+	delivered := app.Events.OfType(events.UserRegistered)
+	require.Len(t, delivered, 1)
+	require.Equal(t, msg.ID, delivered[0].ID, "the retry keeps the deduplication ID")
+
+	rec = app.Do(http.MethodPost, "/admin/dead-letters/"+letters[0].ID+"/retry", nil, adminHeaders)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "DEAD_LETTER_RETRIED")
+	require.Empty(t, listDeadLetters(t, app, "?status=pending"))
+}
+
+func TestRejectedEmailsAreDeadLettered(t *testing.T) {
+	app := newDeadLetterApp()
+	app.Mailbox.Fail(errors.New("smtp: 451 try later"))
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-01-10", "2025-01-12")
+
+	require.Eventually(t, func() bool { return len(app.DeadLetters.All()) == 1 }, time.Second, 10*time.Millisecond)
+	letters := listDeadLetters(t, app, "?kind=email")
+	require.Equal(t, "guest@example.com", letters[0].Target)
+	require.Contains(t, string(letters[0].Payload), "Reserva confirmada")
+
+	retried := retryDeadLetter(t, app, letters[0].ID)
+	require.Equal(t, services.DeadLetterPending, retried.Status, "a failed retry keeps the dead letter pending")
+	require.Equal(t, 2, retried.Attempts)
+
+	app.Mailbox.Fail(nil)
+	rec := app.Do(http.MethodPost, "/admin/dead-letters/retry", map[string]string{"kind": "email"}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.JSONEq(t, `{"retried":1,"failed":0}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+	sent := app.Mailbox.Sent()
+	require.Len(t, sent, 1)
+	require.Equal(t, "guest@example.com", sent[0].To)
+
+	rec = app.Do(http.MethodPost, "/admin/dead-letters/retry", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"retried":0,"failed":0}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+}
+
+func TestDeadLetterEndpointsValidation(t *testing.T) {
+	app := newDeadLetterApp()
+
+	rec := app.Do(http.MethodGet, "/admin/dead-letters", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = app.Do(http.MethodGet, "/admin/dead-letters?kind=push", nil, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_DEAD_LETTER_FILTER")
+	rec = app.Do(http.MethodGet, "/admin/dead-letters/nope", nil, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodPost, "/admin/dead-letters/000000000000000000000000/retry", nil, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "DEAD_LETTER_NOT_FOUND")
+}
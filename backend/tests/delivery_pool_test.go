@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+func TestDeliveryPoolBoundsConcurrencyAndDrains(t *testing.T) {
+	pool := services.NewDeliveryPool(services.DeliveryPoolConfig{Workers: 3, Queue: 100})
+	var running, peak, done atomic.Int64
+	for i := 0; i < 60; i++ {
+		err := pool.Submit(context.Background(), fmt.Sprintf("user%d@example.com", i), func(context.Context) {
+			now := running.Add(1)
+			for {
+				seen := peak.Load()
+				if now <= seen || peak.CompareAndSwap(seen, now) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, pool.Shutdown(context.Background()))
+	require.EqualValues(t, 60, done.Load())
+	require.LessOrEqual(t, peak.Load(), int64(3))
+	require.ErrorIs(t, pool.Submit(context.Background(), "tarde@example.com", func(context.Context) {}), services.ErrDeliveryPoolClosed)
+}
+
+func TestDeliveryPoolSpacesOutEachDestination(t *testing.T) {
+	interval := 30 * time.Millisecond
+	pool := services.NewDeliveryPool(services.DeliveryPoolConfig{Workers: 4, Queue: 10, Interval: interval})
+	var mu sync.Mutex
+	at := map[string][]time.Time{}
+	for _, to := range []string{"ana@example.com", "ana@example.com", "ana@example.com", "beto@example.com"} {
+		require.NoError(t, pool.Submit(context.Background(), to, func(context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			at[to] = append(at[to], time.Now())
+		}))
+	}
+	require.NoError(t, pool.Shutdown(context.Background()))
+
+	require.Len(t, at["ana@example.com"], 3)
+	require.Len(t, at["beto@example.com"], 1)
+	first := at["ana@example.com"][0]
+	require.Less(t, at["beto@example.com"][0].Sub(first), interval)
+	require.GreaterOrEqual(t, at["ana@example.com"][2].Sub(first), 2*interval-5*time.Millisecond)
+}
+
+func TestDeliveryPoolShutdownCancelsAfterTimeout(t *testing.T) {
+	pool := services.NewDeliveryPool(services.DeliveryPoolConfig{Workers: 1, Queue: 10})
+	var cancelled, ran atomic.Int64
+	for i := 0; i < 3; i++ {
+		require.NoError(t, pool.Submit(context.Background(), "ana@example.com", func(ctx context.Context) {
+			ran.Add(1)
+			<-ctx.Done()
+			cancelled.Add(1)
+		}))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+	// The running delivery is cancelled and the queued ones are dropped.
+	require.EqualValues(t, 1, ran.Load())
+	require.EqualValues(t, 1, cancelled.Load())
+}
+
+func TestDeliveryPoolSubmitWaitsForRoom(t *testing.T) {
+	pool := services.NewDeliveryPool(services.DeliveryPoolConfig{Workers: 1})
+	release := make(chan struct{})
+	block := func(context.Context) { <-release }
+	require.NoError(t, pool.Submit(context.Background(), "ana@example.com", block))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// The worker may not have taken the first delivery yet.
+	err := pool.Submit(ctx, "beto@example.com", block)
+	if err == nil {
+		err = pool.Submit(ctx, "carla@example.com", block)
+	}
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, pool.Shutdown(context.Background()))
+}
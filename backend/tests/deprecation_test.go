@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestLegacyTodoEmailIsDeprecatedAndCounted(t *testing.T) {
+	sunset := time.Date(2027, time.March, 31, 0, 0, 0, 0, time.UTC)
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		AdminToken:   testsupport.AdminToken,
+		ContractMode: middleware.ContractFail,
+		Deprecations: middleware.NewDeprecations(nil, handlers.Deprecations(map[string]time.Time{handlers.LegacyTodoEmail: sunset})...),
+	})
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "legacy@hotel.com", "title": "x"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.True(t, strings.HasPrefix(rec.Header().Get(middleware.DeprecationHeader), "@"))
+	require.Equal(t, "Wed, 31 Mar 2027 00:00:00 GMT", rec.Header().Get(middleware.SunsetHeader))
+	require.Contains(t, rec.Header().Values("Link"), `</openapi.yaml>; rel="deprecation"`)
+
+	rec = app.Do(http.MethodGet, "/todos?email=legacy@hotel.com", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotEmpty(t, rec.Header().Get(middleware.DeprecationHeader))
+
+	staff := app.LoginAs(t, "gerencia@hotel.com", services.RoleManager)
+	rec = app.Do(http.MethodGet, "/todos?email=legacy@hotel.com", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Empty(t, rec.Header().Get(middleware.DeprecationHeader), "a session is not the legacy mode")
+
+	rec = app.Do(http.MethodGet, "/admin/deprecations", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		Deprecations []struct {
+			Name       string           `json:"name"`
+			Sunset     time.Time        `json:"sunset"`
+			Count      int64            `json:"count"`
+			LastUsedAt *time.Time       `json:"lastUsedAt"`
+			Clients    map[string]int64 `json:"clients"`
+		} `json:"deprecations"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Deprecations, 1)
+	usage := body.Deprecations[0]
+	require.Equal(t, handlers.LegacyTodoEmail, usage.Name)
+	require.True(t, sunset.Equal(usage.Sunset))
+	require.EqualValues(t, 2, usage.Count)
+	require.NotNil(t, usage.LastUsedAt)
+	require.Len(t, usage.Clients, 1)
+}
+
+func TestDeprecationWithoutSunsetOmitsHeader(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/todos?email=legacy@hotel.com", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, rec.Header().Get(middleware.DeprecationHeader))
+	require.Empty(t, rec.Header().Get(middleware.SunsetHeader))
+
+	rec = app.Do(http.MethodGet, "/todos", nil, nil)
+	require.Empty(t, rec.Header().Get(middleware.DeprecationHeader), "listing without ?email= is not deprecated")
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+var emailChangeLink = regexp.MustCompile(`/users/email/(confirm|cancel)\?token=(\S+)`)
+
+// emailChangeToken returns the token of the link in the last email sent to
+// to.
+func emailChangeToken(t *testing.T, sent []mailer.Message, to string) string {
+	t.Helper()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].To == to {
+			match := emailChangeLink.FindStringSubmatch(sent[i].Body)
+			require.NotNil(t, match, sent[i].Body)
+			return match[2]
+		}
+	}
+	t.Fatalf("no email sent to %s", to)
+	return ""
+}
+
+func TestChangeEmail(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@example.com", "")
+	app.LoginAs(t, "otra@example.com", "")
+	createTodo(t, app.Router, "ana@example.com", "Pedir toallas")
+	account, err := app.Users.FindByEmail(context.Background(), "ana@example.com")
+	require.NoError(t, err)
+
+	change := func(body map[string]string, status int) {
+		t.Helper()
+		rec := app.Do(http.MethodPut, "/users/me/email", body, ana)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+	}
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPut, "/users/me/email", map[string]string{"email": "nueva@example.com"}, nil).Code)
+	change(map[string]string{"email": "Ana@example.com", "password": "secret"}, http.StatusBadRequest)
+	change(map[string]string{"email": "nueva@example.com", "password": "wrong"}, http.StatusForbidden)
+	change(map[string]string{"email": "otra@example.com", "password": "secret"}, http.StatusConflict)
+
+	// The old address can cancel the change.
+	change(map[string]string{"email": "nueva@example.com", "password": "secret"}, http.StatusAccepted)
+	cancel := emailChangeToken(t, app.Mailbox.Sent(), "ana@example.com")
+	confirm := emailChangeToken(t, app.Mailbox.Sent(), "nueva@example.com")
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodGet, "/users/email/cancel?token="+confirm, nil, nil).Code)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodGet, "/users/email/cancel?token="+cancel, nil, nil).Code)
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodGet, "/users/email/confirm?token="+confirm, nil, nil).Code)
+
+	// The links expire.
+	change(map[string]string{"email": "nueva@example.com", "password": "secret"}, http.StatusAccepted)
+	app.Clock.Advance(services.EmailChangeTTL)
+	confirm = emailChangeToken(t, app.Mailbox.Sent(), "nueva@example.com")
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodGet, "/users/email/confirm?token="+confirm, nil, nil).Code)
+
+	// Nothing changes until the new address confirms.
+	rec := app.Do(http.MethodPut, "/users/me/email", map[string]string{"email": "Nueva@Example.com", "password": "secret"}, ana)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var payload struct {
+		EmailChange services.EmailChange `json:"emailChange"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Equal(t, "nueva@example.com", payload.EmailChange.NewEmail)
+	require.WithinDuration(t, app.Clock.Now().Add(services.EmailChangeTTL), payload.EmailChange.ExpiresAt, 0)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodGet, "/users/me/usage", nil, ana).Code)
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@example.com"), 1)
+
+	confirm = emailChangeToken(t, app.Mailbox.Sent(), "nueva@example.com")
+	rec = app.Do(http.MethodGet, "/users/email/confirm?token="+confirm, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodGet, "/users/email/confirm?token="+confirm, nil, nil).Code)
+
+	require.Len(t, listTodos(t, app.Router, "/todos?email=nueva@example.com"), 1)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@example.com"))
+	_, err = app.Users.FindByEmail(context.Background(), "ana@example.com")
+	require.ErrorIs(t, err, services.ErrNotFound)
+	user, err := app.Users.FindByEmail(context.Background(), "nueva@example.com")
+	require.NoError(t, err)
+	require.Nil(t, user.EmailChange)
+	// The account keeps its ID and with it its todos.
+	require.Equal(t, account.ID, user.ID)
+	todos := ownedTodos(t, app, "nueva@example.com")
+	require.Len(t, todos, 1)
+	require.Equal(t, &account.ID, todos[0].UserID)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/users/me/usage", nil, ana).Code)
+	rec = app.Do(http.MethodPost, "/login", map[string]string{"email": "nueva@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	changed := app.Outbox.OfType(events.UserEmailChanged)
+	require.Len(t, changed, 1)
+	require.Equal(t, "nueva@example.com", changed[0].Event.Key)
+}
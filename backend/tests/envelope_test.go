@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestSuccessfulResponsesAreEnveloped(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/healthz", nil, map[string]string{middleware.RequestIDHeader: "req-42"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"data":{"status":"ok"},"meta":{"requestId":"req-42","apiVersion":"`+respond.APIVersion+`"}}`, rec.Body.String())
+
+	// Listings without a limit still describe their size.
+	app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Uno"}, nil)
+	rec = app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, nil)
+	require.Equal(t, respond.Pagination{Total: 1}, *testsupport.DecodeMeta(t, rec.Body.Bytes()).Pagination)
+
+	// Errors keep their own shape.
+	rec = app.Do(http.MethodGet, "/todos?limit=0", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var failure map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &failure))
+	require.NotContains(t, failure, "data")
+	require.Contains(t, failure, "code")
+}
+
+func TestEnvelopeInOtherFormats(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/healthz", nil, map[string]string{
+		"Accept": "application/xml", middleware.RequestIDHeader: "req-42",
+	})
+	var document struct {
+		XMLName xml.Name `xml:"response"`
+		Status  string   `xml:"data>status"`
+		Meta    struct {
+			RequestID  string `xml:"requestId"`
+			APIVersion string `xml:"apiVersion"`
+		} `xml:"meta"`
+	}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &document))
+	require.Equal(t, "ok", document.Status)
+	require.Equal(t, "req-42", document.Meta.RequestID)
+	require.Equal(t, respond.APIVersion, document.Meta.APIVersion)
+
+	rec = app.Do(http.MethodGet, "/healthz", nil, map[string]string{"Accept": "application/msgpack"})
+	var packed struct {
+		Data map[string]string `codec:"data"`
+		Meta map[string]string `codec:"meta"`
+	}
+	require.NoError(t, codec.NewDecoderBytes(rec.Body.Bytes(), new(codec.MsgpackHandle)).Decode(&packed))
+	require.Equal(t, "ok", packed.Data["status"])
+	require.Equal(t, respond.APIVersion, packed.Meta["apiVersion"])
+}
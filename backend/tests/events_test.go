@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// relayEvents runs the outbox relay of app and returns how many events it
+// published.
+func relayEvents(t *testing.T, app *testsupport.App) int {
+	t.Helper()
+	published, err := app.Relay.Relay(context.Background())
+	require.NoError(t, err)
+	return published
+}
+
+func TestDomainEventsArePublished(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/register", map[string]string{"email": "Ana@Example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Len(t, app.Outbox.OfType(events.UserRegistered), 1)
+	require.Empty(t, app.Events.OfType(events.UserRegistered), "events wait in the outbox for the relay")
+	require.Equal(t, 1, relayEvents(t, app))
+	registered := app.Events.OfType(events.UserRegistered)
+	require.Len(t, registered, 1)
+	require.Equal(t, "ana@example.com", registered[0].Key)
+	require.NotContains(t, string(registered[0].Data), "secret")
+
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Tarea"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created struct {
+		Todo struct {
+			ID string `json:"id"`
+		} `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+
+	rec = app.Do(http.MethodPut, "/todos/"+created.Todo.ID, map[string]interface{}{"title": "Renombrada"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, app.Outbox.OfType(events.TodoCompleted))
+	rec = app.Do(http.MethodPut, "/todos/"+created.Todo.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 1, relayEvents(t, app))
+	completed := app.Events.OfType(events.TodoCompleted)
+	require.Len(t, completed, 1)
+	require.Equal(t, created.Todo.ID, completed[0].Key)
+
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-10", "2025-01-12")
+	require.Equal(t, 1, relayEvents(t, app))
+	require.Zero(t, relayEvents(t, app), "published events are not sent again")
+	booked := app.Events.OfType(events.BookingCreated)
+	require.Len(t, booked, 1)
+	require.Equal(t, booking.ID, booked[0].Key)
+	require.Equal(t, testsupport.FixedTime, booked[0].Time)
+	require.NotEmpty(t, booked[0].ID)
+
+	var data struct {
+		RoomID string `json:"roomId"`
+	}
+	require.NoError(t, json.Unmarshal(booked[0].Data, &data))
+	require.Equal(t, room.ID, data.RoomID)
+}
+
+func TestFailedChangeStoresNoEvent(t *testing.T) {
+	app := testsupport.NewApp()
+	user := map[string]string{"email": "ana@example.com", "password": "secret"}
+
+	rec := app.Do(http.MethodPost, "/register", user, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = app.Do(http.MethodPost, "/register", user, nil)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Len(t, app.Outbox.OfType(events.UserRegistered), 1)
+
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-01-10", "2025-01-12")
+	rec = app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+		"roomId": room.ID, "email": "otro@example.com", "guests": 1, "checkIn": "2025-01-11", "checkOut": "2025-01-13",
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Len(t, app.Outbox.OfType(events.BookingCreated), 1)
+}
+
+// flakyPublisher fails the first failures events it receives.
+type flakyPublisher struct {
+	failures int
+	received []events.Event
+}
+
+func (f *flakyPublisher) Publish(_ context.Context, event events.Event) error {
+	f.received = append(f.received, event)
+	if len(f.received) <= f.failures {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func TestOutboxRelayRetriesWithBackoff(t *testing.T) {
+	app := testsupport.NewApp()
+	rec := app.Do(http.MethodPost, "/register", map[string]string{"email": "ana@example.com", "password": "secret"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	broker := &flakyPublisher{failures: 2}
+	relay := services.NewOutboxRelay(app.Outbox, broker, app.DeadLetters, services.OutboxRelayConfig{Backoff: time.Second, MaxBackoff: time.Minute}, app.Clock.Now)
+	ctx := context.Background()
+
+	published, err := relay.Relay(ctx)
+	require.NoError(t, err)
+	require.Zero(t, published)
+	msg := app.Outbox.OfType(events.UserRegistered)[0]
+	require.Equal(t, services.OutboxPending, msg.Status)
+	require.Equal(t, "broker unavailable", msg.LastError)
+
+	published, _ = relay.Relay(ctx)
+	require.Zero(t, published, "the retry waits for the backoff")
+	app.Clock.Advance(time.Second)
+	published, _ = relay.Relay(ctx)
+	require.Zero(t, published)
+	app.Clock.Advance(time.Second)
+	published, _ = relay.Relay(ctx)
+	require.Zero(t, published, "the backoff doubles after each failure")
+	app.Clock.Advance(time.Second)
+	published, _ = relay.Relay(ctx)
+	require.Equal(t, 1, published)
+
+	require.Len(t, broker.received, 3)
+	for _, event := range broker.received {
+		require.Equal(t, msg.ID, event.ID, "every attempt carries the same deduplication ID")
+	}
+	msg = app.Outbox.OfType(events.UserRegistered)[0]
+	require.Equal(t, services.OutboxPublished, msg.Status)
+	require.Equal(t, 3, msg.Attempts)
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hook.Close()
+
+	event, err := events.New(events.TodoCompleted, "t1", map[string]string{"title": "Tarea"}, testsupport.FixedTime)
+	require.NoError(t, err)
+	require.NoError(t, events.NewWebhookPublisher(hook.URL, "hook-secret", nil).Publish(context.Background(), event))
+
+	require.Equal(t, event.ID, headers.Get(events.EventIDHeader))
+	require.Equal(t, events.TodoCompleted, headers.Get(events.EventTypeHeader))
+	mac := hmac.New(sha256.New, []byte("hook-secret"))
+	mac.Write(body)
+	require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), headers.Get(events.SignatureHeader))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	fanout := events.Fanout{events.NewBus(), events.NewWebhookPublisher(failing.URL, "", nil)}
+	require.Error(t, fanout.Publish(context.Background(), event))
+}
+
+// fakeNATS accepts one client at a time, answers the handshake and sends
+// every published subject and payload to pubs.
+func fakeNATS(t *testing.T) (string, <-chan [2]string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	pubs := make(chan [2]string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveNATS(conn, pubs)
+		}
+	}()
+	return "nats://" + listener.Addr().String(), pubs
+}
+
+func serveNATS(conn net.Conn, pubs chan<- [2]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	io.WriteString(conn, "INFO {\"server_id\":\"test\"}\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "PING":
+			io.WriteString(conn, "PONG\r\n")
+		case fields[0] == "PUB" && len(fields) == 3:
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			pubs <- [2]string{fields[1], string(payload[:size])}
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	url, pubs := fakeNATS(t)
+	publisher, err := events.Open(events.BrokerNATS, url, "hotel.")
+	require.NoError(t, err)
+	defer publisher.(*events.NATSPublisher).Close()
+
+	event, err := events.New(events.BookingCreated, "b1", map[string]string{"roomId": "r1"}, testsupport.FixedTime)
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(context.Background(), event))
+
+	select {
+	case pub := <-pubs:
+		require.Equal(t, "hotel.booking.created", pub[0])
+		var received events.Event
+		require.NoError(t, json.Unmarshal([]byte(pub[1]), &received))
+		require.Equal(t, event.ID, received.ID)
+		require.JSONEq(t, `{"roomId":"r1"}`, string(received.Data))
+	case <-time.After(time.Second):
+		t.Fatal("the event did not reach NATS")
+	}
+
+	_, err = events.Open(events.BrokerNATS, "http://localhost:4222", "")
+	require.Error(t, err)
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var path, contentType string
+	var body struct {
+		Records []struct {
+			Key   string       `json:"key"`
+			Value events.Event `json:"value"`
+		} `json:"records"`
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	publisher, err := events.Open(events.BrokerKafka, proxy.URL+"/", "hotel.")
+	require.NoError(t, err)
+	event, err := events.New(events.UserRegistered, "ana@example.com", map[string]string{"email": "ana@example.com"}, testsupport.FixedTime)
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(context.Background(), event))
+
+	require.Equal(t, "/topics/hotel.user.registered", path)
+	require.Equal(t, "application/vnd.kafka.json.v2+json", contentType)
+	require.Len(t, body.Records, 1)
+	require.Equal(t, "ana@example.com", body.Records[0].Key)
+	require.Equal(t, event.ID, body.Records[0].Value.ID)
+
+	_, err = events.Open("rabbitmq", "", "")
+	require.Error(t, err)
+}
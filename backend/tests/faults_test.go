@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func newFaultApp(random func() float64) *testsupport.App {
+	return testsupport.NewAppWithConfig(handlers.RouterConfig{
+		AdminToken:   testsupport.AdminToken,
+		ContractMode: middleware.ContractFail,
+		Faults:       middleware.NewFaultInjector(random),
+	})
+}
+
+func TestFaultHeadersFailASingleRequest(t *testing.T) {
+	app := newFaultApp(nil)
+
+	rec := app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, map[string]string{middleware.FaultStatusHeader: "503"})
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Contains(t, rec.Body.String(), string(i18n.FaultInjected))
+
+	rec = app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	start := time.Now()
+	rec = app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, map[string]string{middleware.FaultDelayHeader: "50"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	rec = app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, map[string]string{middleware.FaultStatusHeader: "404"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, map[string]string{middleware.FaultStatusHeader: "500", middleware.FaultRateHeader: "0"})
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAdminFaultsHitAShareOfTheRequests(t *testing.T) {
+	draws := []float64{0.1, 0.6, 0.2, 0.9}
+	app := newFaultApp(func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	})
+
+	rec := app.Do(http.MethodPut, "/admin/faults", map[string]interface{}{"rate": 50, "status": 502}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.JSONEq(t, `{"rate":50,"delayMs":0,"status":502,"drop":false}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+
+	var codes []int
+	for range 4 {
+		codes = append(codes, app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, nil).Code)
+	}
+	require.Equal(t, []int{http.StatusBadGateway, http.StatusOK, http.StatusBadGateway, http.StatusOK}, codes)
+
+	// the admin API is never faulted, so the faults can always be removed
+	rec = app.Do(http.MethodPut, "/admin/faults", map[string]interface{}{"rate": 0}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = app.Do(http.MethodGet, "/admin/faults", nil, adminHeaders)
+	require.JSONEq(t, `{"rate":0,"delayMs":0,"status":0,"drop":false}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+
+	rec = app.Do(http.MethodPut, "/admin/faults", map[string]interface{}{"rate": 150}, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodPut, "/admin/faults", map[string]interface{}{"rate": 10, "status": 418}, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestFaultDropClosesTheConnection(t *testing.T) {
+	server := httptest.NewServer(newFaultApp(nil).Router)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/todos?email=ana@example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set(middleware.FaultDropHeader, "true")
+	_, err = server.Client().Do(req)
+	require.Error(t, err)
+
+	res, err := server.Client().Get(server.URL + "/todos?email=ana@example.com")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestFaultInjectionIsOffByDefault(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken})
+
+	rec := app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, map[string]string{middleware.FaultStatusHeader: "503"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = app.Do(http.MethodGet, "/admin/faults", nil, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
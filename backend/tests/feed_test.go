@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type atomFeed struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Entries []struct {
+		ID         string `xml:"id"`
+		Title      string `xml:"title"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+	} `xml:"entry"`
+}
+
+func TestTodoFeed(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+
+	create := func(headers map[string]string, body map[string]string) string {
+		t.Helper()
+		rec := app.Do(http.MethodPost, "/todos", body, headers)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created struct {
+			Todo struct {
+				ID string `json:"id"`
+			} `json:"todo"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+		return created.Todo.ID
+	}
+	create(ana, map[string]string{"email": "ana@hotel.com", "title": "Tarea vieja"})
+	app.Clock.Advance(31 * 24 * time.Hour)
+	towels := create(ana, map[string]string{"email": "ana@hotel.com", "title": "Pedir toallas", "color": "blue"})
+	app.Clock.Advance(time.Hour)
+	create(ana, map[string]string{"email": "ana@hotel.com", "title": "Llamar al tecnico"})
+	app.Clock.Advance(time.Hour)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodPut, "/todos/"+towels, map[string]bool{"completed": true}, ana).Code)
+	create(beto, map[string]string{"email": "beto@hotel.com", "title": "Tarea de beto"})
+
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPost, "/users/me/feed-token", nil, nil).Code)
+	issue := func(headers map[string]string) string {
+		t.Helper()
+		rec := app.Do(http.MethodPost, "/users/me/feed-token", nil, headers)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var issued struct {
+			Feed struct {
+				Token string `json:"token"`
+			} `json:"feed"`
+			URL string `json:"url"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &issued)
+		require.Equal(t, "https://hotel.test/todos/feed.xml?token="+url.QueryEscape(issued.Feed.Token), issued.URL)
+		return issued.Feed.Token
+	}
+	token := issue(ana)
+
+	feed := func(query string, status int) atomFeed {
+		t.Helper()
+		rec := app.Do(http.MethodGet, "/todos/feed.xml?"+query, nil, nil)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		var feed atomFeed
+		if status == http.StatusOK {
+			require.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+			require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+			require.NotContains(t, rec.Body.String(), token, "the feed must not leak its token")
+		}
+		return feed
+	}
+	feed("", http.StatusUnauthorized)
+	feed("token=otro", http.StatusUnauthorized)
+
+	all := feed("token="+url.QueryEscape(token), http.StatusOK)
+	require.Equal(t, "tareas de ana@hotel.com", all.Title)
+	require.Equal(t, "https://hotel.test/todos/feed.xml", all.ID)
+	var titles []string
+	for _, entry := range all.Entries {
+		titles = append(titles, entry.Title)
+	}
+	require.Equal(t, []string{
+		"tarea completada: Pedir toallas",
+		"nueva tarea: Llamar al tecnico",
+		"nueva tarea: Pedir toallas",
+	}, titles, "newest first, without old todos nor the todos of others")
+	require.Equal(t, "https://hotel.test/todos/"+towels+"#completed", all.Entries[0].ID)
+	require.Equal(t, "completed", all.Entries[0].Categories[0].Term)
+	require.Equal(t, "blue", all.Entries[0].Categories[1].Term)
+
+	blue := feed("token="+url.QueryEscape(token)+"&color=blue", http.StatusOK)
+	require.Len(t, blue.Entries, 2)
+	require.Equal(t, "https://hotel.test/todos/feed.xml?color=blue", blue.ID)
+	feed("token="+url.QueryEscape(token)+"&color=chartreuse", http.StatusBadRequest)
+
+	// A list feed shows the todos of the list to its members.
+	rec := app.Do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3"}, beto)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var list struct {
+		List struct {
+			ID string `json:"id"`
+		} `json:"list"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &list)
+	create(beto, map[string]string{"email": "beto@hotel.com", "title": "Revisar minibar", "listId": list.List.ID})
+	listQuery := "token=" + url.QueryEscape(token) + "&list=" + list.List.ID
+	feed(listQuery, http.StatusNotFound)
+	feed("token="+url.QueryEscape(token)+"&list=nope", http.StatusBadRequest)
+	rec = app.Do(http.MethodPost, "/lists/"+list.List.ID+"/members", map[string]string{"email": "ana@hotel.com", "role": "viewer"}, beto)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	shared := feed(listQuery, http.StatusOK)
+	require.Len(t, shared.Entries, 1)
+	require.True(t, strings.HasSuffix(shared.Entries[0].Title, "Revisar minibar"))
+
+	// Issuing a new token or revoking it invalidates the URL handed out.
+	renewed := issue(ana)
+	feed("token="+url.QueryEscape(token), http.StatusUnauthorized)
+	feed("token="+url.QueryEscape(renewed), http.StatusOK)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/users/me/feed-token", nil, ana).Code)
+	feed("token="+url.QueryEscape(renewed), http.StatusUnauthorized)
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestTodoResponsesMatchGoldenFiles(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Comprar pan"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	testsupport.AssertGolden(t, "todo_created", rec.Body.Bytes())
+
+	app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Pagar luz"}, nil)
+	rec = app.Do(http.MethodGet, "/todos?email=ana@example.com&limit=1", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	testsupport.AssertGolden(t, "todo_page", rec.Body.Bytes())
+
+	rec = app.Do(http.MethodDelete, "/todos/invalid-id", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	testsupport.AssertGolden(t, "invalid_id", rec.Body.Bytes())
+}
+
+func TestRoomResponsesMatchGoldenFiles(t *testing.T) {
+	app := testsupport.NewApp()
+	createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 120})
+
+	rec := app.Do(http.MethodGet, "/rooms", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	testsupport.AssertGolden(t, "rooms", rec.Body.Bytes())
+}
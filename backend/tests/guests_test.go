@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type guestBody struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Document    string   `json:"document"`
+	Email       string   `json:"email"`
+	Preferences []string `json:"preferences"`
+}
+
+func createGuest(t *testing.T, app *testsupport.App, payload map[string]interface{}) guestBody {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/guests", payload, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var body struct {
+		Guest guestBody `json:"guest"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Guest
+}
+
+func TestGuestCRUD(t *testing.T) {
+	app := testsupport.NewApp()
+	guest := createGuest(t, app, map[string]interface{}{
+		"name":        "Ana Perez",
+		"document":    "30.123.456",
+		"email":       "Ana@Example.com",
+		"preferences": []string{"piso alto", "piso alto", " sin plumas "},
+	})
+	require.Equal(t, "30123456", guest.Document)
+	require.Equal(t, "ana@example.com", guest.Email)
+	require.Equal(t, []string{"piso alto", "sin plumas"}, guest.Preferences)
+
+	rec := app.Do(http.MethodPost, "/guests", map[string]interface{}{"name": "Otra", "document": "30123456"}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "GUEST_DOCUMENT_TAKEN")
+
+	rec = app.Do(http.MethodPost, "/guests", map[string]interface{}{"name": "Sin documento"}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = app.Do(http.MethodPut, "/guests/"+guest.ID, map[string]interface{}{"phone": "+54 11 5555"}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "+54 11 5555")
+
+	rec = app.Do(http.MethodDelete, "/guests/"+guest.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/guests/"+guest.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSearchGuestsByNameOrDocument(t *testing.T) {
+	app := testsupport.NewApp()
+	createGuest(t, app, map[string]interface{}{"name": "Ana Perez", "document": "30123456"})
+	createGuest(t, app, map[string]interface{}{"name": "Bruno Diaz", "document": "AB-998877"})
+
+	var body struct {
+		Guests []guestBody `json:"guests"`
+	}
+
+	rec := app.Do(http.MethodGet, "/guests?q=perez", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Guests, 1)
+	require.Equal(t, "Ana Perez", body.Guests[0].Name)
+
+	rec = app.Do(http.MethodGet, "/guests?q=ab998877", nil, app.StaffHeaders(t))
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Guests, 1)
+	require.Equal(t, "Bruno Diaz", body.Guests[0].Name)
+
+	rec = app.Do(http.MethodGet, "/guests", nil, app.StaffHeaders(t))
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Guests, 2)
+}
+
+func TestGuestBookingHistory(t *testing.T) {
+	app := testsupport.NewApp()
+	guest := createGuest(t, app, map[string]interface{}{"name": "Ana Perez", "document": "30123456", "email": "ana@example.com"})
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-02-01", "2025-02-03")
+
+	rec := app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+		"roomId": room.ID, "guestId": guest.ID, "guests": 1, "checkIn": "2025-02-10", "checkOut": "2025-02-12",
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	booking := decodeBooking(t, rec.Body.Bytes())
+	require.Equal(t, "ana@example.com", booking.Email)
+
+	rec = app.Do(http.MethodGet, "/guests/"+guest.ID+"/bookings", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Bookings []bookingBody `json:"bookings"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Bookings, 1)
+	require.Equal(t, booking.ID, body.Bookings[0].ID)
+
+	rec = app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+		"roomId": room.ID, "guestId": "65a000000000000000000000", "guests": 1, "checkIn": "2025-03-10", "checkOut": "2025-03-12",
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.Contains(t, rec.Body.String(), "GUEST_NOT_FOUND")
+}
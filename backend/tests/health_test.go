@@ -1,25 +1,26 @@
 package tests
 
 import (
-	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
 )
 
 func TestHealthEndpoint(t *testing.T) {
-	app := newTestApp()
+	app := testsupport.NewApp()
 
 	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
 	rec := httptest.NewRecorder()
 
-	app.router.ServeHTTP(rec, req)
+	app.Router.ServeHTTP(rec, req)
 
 	require.Equal(t, http.StatusOK, rec.Code)
 
 	var body map[string]string
-	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
 	require.Equal(t, "ok", body["status"])
 }
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestMessagesDefaultToSpanish(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/login", map[string]string{"email": "nobody@example.com", "password": "x"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, "es", rec.Header().Get("Content-Language"))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "credenciales invalidas", body["error"])
+	require.Equal(t, "INVALID_CREDENTIALS", body["code"])
+}
+
+func TestMessagesFollowAcceptLanguage(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/login", map[string]string{"email": "nobody@example.com", "password": "x"},
+		map[string]string{"Accept-Language": "en-US,en;q=0.9,es;q=0.5"})
+	require.Equal(t, "en", rec.Header().Get("Content-Language"))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "invalid credentials", body["error"])
+	require.Equal(t, "INVALID_CREDENTIALS", body["code"])
+}
+
+func TestLangQueryOverridesAcceptLanguage(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodDelete, "/todos/invalid-id?lang=es", nil,
+		map[string]string{"Accept-Language": "en"})
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "id invalido", body["error"])
+	require.Equal(t, "INVALID_ID", body["code"])
+}
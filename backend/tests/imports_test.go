@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type importResultBody struct {
+	Row         int    `json:"row"`
+	ExternalRef string `json:"externalRef"`
+	Status      string `json:"status"`
+	BookingID   string `json:"bookingId"`
+	Reason      string `json:"reason"`
+}
+
+type importRunBody struct {
+	ID         string             `json:"id"`
+	Channel    string             `json:"channel"`
+	Status     string             `json:"status"`
+	Total      int                `json:"total"`
+	Created    int                `json:"created"`
+	Duplicates int                `json:"duplicates"`
+	Conflicts  int                `json:"conflicts"`
+	Invalid    int                `json:"invalid"`
+	Results    []importResultBody `json:"results"`
+}
+
+func decodeImportRun(t *testing.T, body []byte) importRunBody {
+	t.Helper()
+	var payload struct {
+		Run importRunBody `json:"run"`
+	}
+	testsupport.DecodeData(t, body, &payload)
+	return payload.Run
+}
+
+// waitForImport polls the run at location until it completes.
+func waitForImport(t *testing.T, app *testsupport.App, location string) importRunBody {
+	t.Helper()
+	staff := app.StaffHeaders(t)
+	var run importRunBody
+	require.Eventually(t, func() bool {
+		rec := app.Do(http.MethodGet, location, nil, staff)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		run = decodeImportRun(t, rec.Body.Bytes())
+		return run.Status == "completed"
+	}, time.Second, 10*time.Millisecond)
+	return run
+}
+
+func TestImportBookingsFromJSON(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createRoom(t, app, map[string]interface{}{"number": "102", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-02-01", "2025-02-03")
+
+	rec := app.Do(http.MethodPost, "/integrations/bookings/import", map[string]interface{}{
+		"channel": "Booking.com",
+		"bookings": []map[string]interface{}{
+			{"externalRef": "BK-1", "roomId": room.ID, "email": "ana@example.com", "guests": 2, "checkIn": "2025-01-10", "checkOut": "2025-01-12"},
+			{"externalRef": "BK-2", "roomNumber": "102", "email": "juan@example.com", "guests": 1, "checkIn": "2025-01-10", "checkOut": "2025-01-11"},
+			{"externalRef": "BK-3", "roomId": room.ID, "email": "eva@example.com", "guests": 2, "checkIn": "2025-02-02", "checkOut": "2025-02-04"},
+			{"externalRef": "BK-4", "roomNumber": "999", "email": "eva@example.com", "guests": 2, "checkIn": "2025-03-01", "checkOut": "2025-03-02"},
+			{"externalRef": "BK-5", "roomId": room.ID, "email": "eva@example.com", "guests": 2, "checkIn": "2025-03-02", "checkOut": "2025-03-01"},
+			{"externalRef": "BK-1", "roomId": room.ID, "email": "ana@example.com", "guests": 2, "checkIn": "2025-01-10", "checkOut": "2025-01-12"},
+		},
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	location := rec.Header().Get("Location")
+	require.Equal(t, "/integrations/bookings/imports/"+decodeImportRun(t, rec.Body.Bytes()).ID, location)
+
+	run := waitForImport(t, app, location)
+	require.Equal(t, "booking.com", run.Channel)
+	require.Equal(t, 6, run.Total)
+	require.Equal(t, 2, run.Created)
+	require.Equal(t, 1, run.Conflicts)
+	require.Equal(t, 2, run.Invalid)
+	require.Equal(t, 1, run.Duplicates)
+
+	statuses := make([]string, len(run.Results))
+	for i, result := range run.Results {
+		require.Equal(t, i+1, result.Row)
+		statuses[i] = result.Status + ":" + result.Reason
+	}
+	require.Equal(t, []string{"created:", "created:", "conflict:room_not_available", "invalid:room_not_found", "invalid:invalid_dates", "duplicate:"}, statuses)
+	require.Equal(t, run.Results[0].BookingID, run.Results[5].BookingID)
+
+	rec = app.Do(http.MethodGet, "/bookings/"+run.Results[0].BookingID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"externalRef":"BK-1"`)
+	require.Contains(t, rec.Body.String(), `"channel":"booking.com"`)
+}
+
+func TestImportIsIdempotentAcrossRuns(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	payload := map[string]interface{}{
+		"channel": "expedia",
+		"bookings": []map[string]interface{}{
+			{"externalRef": "EX-1", "roomId": room.ID, "email": "ana@example.com", "guests": 2, "checkIn": "2025-01-10", "checkOut": "2025-01-12"},
+		},
+	}
+
+	rec := app.Do(http.MethodPost, "/integrations/bookings/import", payload, app.StaffHeaders(t))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Equal(t, 1, waitForImport(t, app, rec.Header().Get("Location")).Created)
+
+	rec = app.Do(http.MethodPost, "/integrations/bookings/import", payload, app.StaffHeaders(t))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	second := waitForImport(t, app, rec.Header().Get("Location"))
+	require.Equal(t, 0, second.Created)
+	require.Equal(t, 1, second.Duplicates)
+	require.Equal(t, 0, second.Conflicts, "a re-sent booking is not a conflict with itself")
+}
+
+func TestImportBookingsFromCSV(t *testing.T) {
+	app := testsupport.NewApp()
+	createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+
+	body := "External Ref,Room Number,Email,Guests,Check In,Check Out,Notes\n" +
+		"AB-1,101,ana@example.com,2,2025-01-10,2025-01-12,late arrival\n" +
+		",101,juan@example.com,1,2025-01-20,2025-01-21,\n"
+	req := httptest.NewRequest(http.MethodPost, "/integrations/bookings/import?channel=airbnb", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	for key, value := range app.StaffHeaders(t) {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+
+	run := waitForImport(t, app, rec.Header().Get("Location"))
+	require.Equal(t, "airbnb", run.Channel)
+	require.Equal(t, 1, run.Created)
+	require.Equal(t, "invalid", run.Results[1].Status)
+	require.Equal(t, "missing_external_ref", run.Results[1].Reason)
+}
+
+func TestImportValidation(t *testing.T) {
+	app := testsupport.NewApp()
+	staff := app.StaffHeaders(t)
+
+	rec := app.Do(http.MethodPost, "/integrations/bookings/import", map[string]interface{}{
+		"bookings": []map[string]interface{}{{"externalRef": "X"}},
+	}, staff)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_IMPORT")
+
+	rec = app.Do(http.MethodPost, "/integrations/bookings/import", map[string]interface{}{
+		"channel": "expedia", "bookings": []map[string]interface{}{},
+	}, staff)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/integrations/bookings/imports/000000000000000000000000", nil, staff)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "IMPORT_NOT_FOUND")
+
+	rec = app.Do(http.MethodPost, "/integrations/bookings/import", map[string]interface{}{
+		"channel": "expedia", "bookings": []map[string]interface{}{{"externalRef": "X"}},
+	}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
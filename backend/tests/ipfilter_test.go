@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func ipRules(t *testing.T, allow, deny []string) middleware.IPRules {
+	t.Helper()
+	rules, err := middleware.ParseIPRules(allow, deny)
+	require.NoError(t, err)
+	return rules
+}
+
+// requestFrom sends a request through a proxy at 10.0.0.1 that forwards
+// clientIP.
+func requestFrom(app *testsupport.App, method, path, clientIP string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", clientIP)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGlobalIPRules(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		ContractMode:   middleware.ContractFail,
+		TrustedProxies: []string{"10.0.0.0/8"},
+		IPRules:        ipRules(t, nil, []string{"198.51.100.0/24", "2001:db8::1"}),
+	})
+
+	rec := requestFrom(app, http.MethodGet, "/healthz", "198.51.100.20", nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "IP_FORBIDDEN")
+	require.Equal(t, http.StatusForbidden, requestFrom(app, http.MethodGet, "/healthz", "2001:db8::1", nil).Code)
+	require.Equal(t, http.StatusOK, requestFrom(app, http.MethodGet, "/healthz", "203.0.113.7", nil).Code)
+}
+
+func TestAdminAndTestingIPRules(t *testing.T) {
+	vpn := ipRules(t, []string{"10.8.0.0/16"}, []string{"10.8.99.0/24"})
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		ContractMode:   middleware.ContractFail,
+		TrustedProxies: []string{"10.0.0.0/16"},
+		AdminToken:     testsupport.AdminToken,
+		AdminIPRules:   vpn,
+		TestingIPRules: vpn,
+	})
+	admin := map[string]string{middleware.AdminTokenHeader: testsupport.AdminToken}
+
+	// The admin token alone is not enough outside the VPN.
+	rec := requestFrom(app, http.MethodGet, "/admin/jobs", "203.0.113.7", admin)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "IP_FORBIDDEN")
+	require.Equal(t, http.StatusForbidden, requestFrom(app, http.MethodGet, "/admin/dashboard/users", "203.0.113.7", admin).Code)
+	require.Equal(t, http.StatusForbidden, requestFrom(app, http.MethodGet, "/admin/jobs", "10.8.99.4", admin).Code)
+	require.Equal(t, http.StatusOK, requestFrom(app, http.MethodGet, "/admin/jobs", "10.8.1.4", admin).Code)
+
+	require.Equal(t, http.StatusForbidden, requestFrom(app, http.MethodDelete, "/todos", "203.0.113.7", nil).Code)
+	require.Equal(t, http.StatusForbidden, requestFrom(app, http.MethodDelete, "/users", "203.0.113.7", nil).Code)
+	require.Equal(t, http.StatusOK, requestFrom(app, http.MethodDelete, "/users", "10.8.1.4", nil).Code)
+
+	// The rest of the API stays open.
+	require.Equal(t, http.StatusOK, requestFrom(app, http.MethodGet, "/rooms", "203.0.113.7", nil).Code)
+}
+
+func TestParseIPRules(t *testing.T) {
+	_, err := middleware.ParseIPRules([]string{"10.0.0.0/33"}, nil)
+	require.Error(t, err)
+	_, err = middleware.ParseIPRules(nil, []string{"oficina"})
+	require.Error(t, err)
+	rules := ipRules(t, []string{" 192.0.2.1 "}, nil)
+	require.False(t, rules.Empty())
+	require.True(t, ipRules(t, nil, nil).Empty())
+}
@@ -0,0 +1,361 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/scheduler"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestCronSchedule(t *testing.T) {
+	cases := []struct {
+		spec string
+		from string
+		next string
+	}{
+		{"*/5 * * * *", "2025-01-01T10:02:30Z", "2025-01-01T10:05:00Z"},
+		{"*/5 * * * *", "2025-01-01T10:05:00Z", "2025-01-01T10:10:00Z"},
+		{"30 3 * * *", "2025-01-01T10:00:00Z", "2025-01-02T03:30:00Z"},
+		{"0 9-17/4 * * 1-5", "2025-01-03T14:00:00Z", "2025-01-03T17:00:00Z"},
+		{"0 9-17/4 * * 1-5", "2025-01-03T17:00:00Z", "2025-01-06T09:00:00Z"},
+		{"0 0 1,15 * *", "2025-01-02T00:00:00Z", "2025-01-15T00:00:00Z"},
+		{"0 12 13 * 5", "2025-01-01T00:00:00Z", "2025-01-03T12:00:00Z"},
+		{"0 0 29 2 *", "2025-01-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"0 0 * * 7", "2025-01-01T00:00:00Z", "2025-01-05T00:00:00Z"},
+		{"@hourly", "2025-01-01T10:59:59Z", "2025-01-01T11:00:00Z"},
+		{"@monthly", "2025-01-31T12:00:00Z", "2025-02-01T00:00:00Z"},
+		{"@every 90s", "2025-01-01T10:00:00Z", "2025-01-01T10:01:30Z"},
+	}
+	for _, tc := range cases {
+		schedule, err := scheduler.Parse(tc.spec)
+		require.NoError(t, err, tc.spec)
+		from, _ := time.Parse(time.RFC3339, tc.from)
+		require.Equal(t, tc.next, schedule.Next(from).Format(time.RFC3339), tc.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *", "@every 1ms", "@sometimes"} {
+		_, err := scheduler.Parse(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+type jobsBody struct {
+	Instance string `json:"instance"`
+	Leader   bool   `json:"leader"`
+	Jobs     []struct {
+		Name      string     `json:"name"`
+		Schedule  string     `json:"schedule"`
+		LastRun   *time.Time `json:"lastRun"`
+		LastError string     `json:"lastError"`
+		Runs      int        `json:"runs"`
+		Failures  int        `json:"failures"`
+		NextRun   time.Time  `json:"nextRun"`
+	} `json:"jobs"`
+}
+
+func TestAdminJobsEndpoint(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+
+	rec := app.Do(http.MethodGet, "/admin/jobs", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	app.Clock.Advance(5 * time.Minute)
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+
+	rec = app.Do(http.MethodGet, "/admin/jobs", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body jobsBody
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Equal(t, "test-1", body.Instance)
+	require.True(t, body.Leader)
+
+	names := make([]string, len(body.Jobs))
+	for i, job := range body.Jobs {
+		names[i] = job.Name
+	}
+	require.Equal(t, []string{services.JobAttachmentScan, services.JobRecurringTodos, services.JobReminders, services.JobTodoArchive, services.JobTodoDigest, services.JobTrashPurge}, names)
+
+	recurring := body.Jobs[1]
+	require.Equal(t, "*/5 * * * *", recurring.Schedule)
+	require.Equal(t, 1, recurring.Runs)
+	require.Equal(t, testsupport.FixedTime.Add(5*time.Minute), *recurring.LastRun)
+	require.Equal(t, testsupport.FixedTime.Add(10*time.Minute), recurring.NextRun)
+	require.Nil(t, body.Jobs[2].LastRun, "the reminders are not due until 11:00")
+	require.Equal(t, testsupport.FixedTime.Add(time.Hour), body.Jobs[2].NextRun)
+}
+
+func TestSchedulerLeaderElection(t *testing.T) {
+	clock := testsupport.NewClock(testsupport.FixedTime)
+	store := &testsupport.MemoryJobStore{}
+	lease := &testsupport.MemoryLease{}
+	ctx := context.Background()
+
+	runs := map[string]int{}
+	release := make(chan struct{})
+	newReplica := func(instance string) *scheduler.Scheduler {
+		replica := scheduler.New(store, lease.Elector(instance), instance, clock.Now)
+		require.NoError(t, replica.Add("sync", "@every 1m", func(context.Context) error {
+			runs[instance]++
+			<-release
+			return errors.New("channel manager down")
+		}))
+		require.NoError(t, replica.Add("disabled", scheduler.Off, nil))
+		return replica
+	}
+	first, second := newReplica("a"), newReplica("b")
+
+	clock.Advance(time.Minute)
+	first.Tick(ctx)
+	second.Tick(ctx)
+	require.True(t, first.Leader())
+	require.False(t, second.Leader())
+
+	clock.Advance(time.Minute)
+	first.Tick(ctx)
+	close(release)
+	first.Wait()
+	second.Wait()
+	require.Equal(t, map[string]int{"a": 1}, runs, "only the leader runs, and never twice at once")
+
+	statuses, err := second.Statuses(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	require.Equal(t, 1, statuses[0].Runs)
+	require.Equal(t, 1, statuses[0].Failures)
+	require.Equal(t, "channel manager down", statuses[0].LastError)
+	require.Equal(t, testsupport.FixedTime.Add(2*time.Minute), statuses[0].NextRun, "followers keep their own schedule")
+
+	lease.Release()
+	second.Tick(ctx)
+	second.Wait()
+	require.True(t, second.Leader())
+	require.Equal(t, map[string]int{"a": 1, "b": 1}, runs)
+
+	require.Error(t, first.Add("sync", "@daily", nil))
+	require.Error(t, first.Add("broken", "0 25 * * *", nil))
+}
+
+type todoBody struct {
+	ID             string     `json:"id"`
+	Title          string     `json:"title"`
+	Completed      bool       `json:"completed"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	Recurrence     string     `json:"recurrence"`
+	NextOccurrence *time.Time `json:"nextOccurrence"`
+	DeletedAt      *time.Time `json:"deletedAt"`
+}
+
+func createTodo(t *testing.T, router http.Handler, email, title string) todoBody {
+	t.Helper()
+	rec := testsupport.PerformRequest(router, http.MethodPost, "/todos", map[string]string{"email": email, "title": title}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var payload struct {
+		Todo todoBody `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	return payload.Todo
+}
+
+func listTodos(t *testing.T, router http.Handler, path string) []todoBody {
+	t.Helper()
+	rec := testsupport.PerformRequest(router, http.MethodGet, path, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Todos []todoBody `json:"todos"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	return payload.Todos
+}
+
+func TestTodoTrash(t *testing.T) {
+	app := testsupport.NewApp()
+	todo := createTodo(t, app.Router, "ana@example.com", "Comprar toallas")
+
+	rec := app.Do(http.MethodDelete, "/todos/"+todo.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@example.com"))
+	rec = app.Do(http.MethodPut, "/todos/"+todo.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code, "trashed todos cannot be edited")
+
+	trashed := listTodos(t, app.Router, "/todos?email=ana@example.com&trashed=true")
+	require.Len(t, trashed, 1)
+	require.NotNil(t, trashed[0].DeletedAt)
+
+	rec = app.Do(http.MethodPost, "/todos/"+todo.ID+"/restore", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@example.com"), 1)
+	rec = app.Do(http.MethodPost, "/todos/"+todo.ID+"/restore", nil, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	kept := createTodo(t, app.Router, "ana@example.com", "Revisar minibar")
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/todos/"+todo.ID, nil, nil).Code)
+	app.Clock.Advance(testsupport.TrashRetention + time.Hour)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/todos/"+kept.ID, nil, nil).Code)
+
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+	trashed = listTodos(t, app.Router, "/todos?email=ana@example.com&trashed=true")
+	require.Len(t, trashed, 1, "only the todos past the retention are purged")
+	require.Equal(t, kept.ID, trashed[0].ID)
+}
+
+func TestTodoArchive(t *testing.T) {
+	app := testsupport.NewApp()
+	done := createTodo(t, app.Router, "ana@example.com", "Comprar toallas")
+	open := createTodo(t, app.Router, "ana@example.com", "Revisar minibar")
+	rec := app.Do(http.MethodPut, "/todos/"+done.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	app.Clock.Advance(testsupport.ArchiveAfter)
+	recent := createTodo(t, app.Router, "ana@example.com", "Cambiar sabanas")
+	rec = app.Do(http.MethodPut, "/todos/"+recent.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	app.Clock.Advance(24 * time.Hour)
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+
+	ids := func(todos []todoBody) []string {
+		var ids []string
+		for _, todo := range todos {
+			ids = append(ids, todo.ID)
+		}
+		return ids
+	}
+	require.Equal(t, []string{open.ID, recent.ID}, ids(listTodos(t, app.Router, "/todos?email=ana@example.com")),
+		"only the todos completed before the cutoff are archived")
+	archived := listTodos(t, app.Router, "/todos?email=ana@example.com&includeArchived=true")
+	require.Equal(t, []string{done.ID, open.ID, recent.ID}, ids(archived))
+	require.True(t, archived[0].Completed)
+
+	rec = app.Do(http.MethodPut, "/todos/"+done.ID, map[string]interface{}{"completed": false}, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code, "archived todos are read-only")
+}
+
+func TestRecurringTodos(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Controlar caldera", "recurrence": "daily"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Otra", "recurrence": "sometimes"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	app.Clock.Advance(24*time.Hour + 5*time.Minute)
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+
+	todos := listTodos(t, app.Router, "/todos?email=ana@example.com")
+	require.Len(t, todos, 2)
+	require.Empty(t, todos[0].Recurrence, "the old occurrence stops repeating")
+	require.Nil(t, todos[0].NextOccurrence)
+	require.Equal(t, "daily", todos[1].Recurrence)
+	require.Equal(t, "Controlar caldera", todos[1].Title)
+	require.Equal(t, testsupport.FixedTime.AddDate(0, 0, 2), *todos[1].NextOccurrence)
+
+	app.Clock.Advance(5 * time.Minute)
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@example.com"), 2, "the next occurrence is not due yet")
+}
+
+func TestTodoDigest(t *testing.T) {
+	app := testsupport.NewApp()
+	createTodo(t, app.Router, "ana@example.com", "Comprar toallas")
+	done := createTodo(t, app.Router, "ana@example.com", "Revisar minibar")
+	createTodo(t, app.Router, "juan@example.com", "Cambiar sabanas")
+	rec := app.Do(http.MethodPut, "/todos/"+done.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	app.Clock.Advance(22 * time.Hour)
+	app.Jobs.Tick(context.Background())
+	app.Jobs.Wait()
+	digest := services.NewTodoDigest(app.Todos, app.Mailer, app.Clock.Now)
+	require.NoError(t, digest.Send(context.Background()), "a second run the same day sends nothing")
+
+	var digests []string
+	for _, msg := range app.Mailbox.Sent() {
+		if strings.Contains(msg.Subject, "pendientes") {
+			digests = append(digests, msg.To)
+			if msg.To == "ana@example.com" {
+				require.Equal(t, "Tenes 1 tareas pendientes", msg.Subject)
+				require.Contains(t, msg.Body, "- Comprar toallas")
+				require.NotContains(t, msg.Body, "Revisar minibar")
+				require.Contains(t, msg.Body, "/mail/opt-out?")
+			}
+		}
+	}
+	require.ElementsMatch(t, []string{"ana@example.com", "juan@example.com"}, digests)
+}
+
+func TestSchedulerLocksEachActivation(t *testing.T) {
+	clock := testsupport.NewClock(testsupport.FixedTime)
+	locks := &testsupport.MemoryJobLocks{Now: clock.Now}
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	runs := 0
+	block := make(chan struct{})
+	close(block)
+	// Both replicas believe they lead, as a paused leader whose lease
+	// another replica took over.
+	newReplica := func(instance string) *scheduler.Scheduler {
+		replica := scheduler.New(nil, scheduler.Solo{}, instance, clock.Now)
+		replica.SetLocker(locks.Instance(instance), 5*time.Minute)
+		require.NoError(t, replica.Add("sync", "@every 1m", func(context.Context) error {
+			mu.Lock()
+			runs++
+			wait := block
+			mu.Unlock()
+			<-wait
+			return nil
+		}))
+		return replica
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return runs
+	}
+	first, second := newReplica("a"), newReplica("b")
+
+	clock.Advance(time.Minute)
+	first.Tick(ctx)
+	second.Tick(ctx)
+	first.Wait()
+	second.Wait()
+	require.Equal(t, 1, count(), "each activation runs once")
+
+	mu.Lock()
+	block = make(chan struct{})
+	release := block
+	mu.Unlock()
+	clock.Advance(time.Minute)
+	first.Tick(ctx)
+	require.Eventually(t, func() bool { return count() == 2 }, time.Second, time.Millisecond)
+
+	clock.Advance(time.Minute)
+	second.Tick(ctx)
+	second.Wait()
+	require.Equal(t, 2, count(), "a run in progress on another replica holds the lock")
+	statuses, err := second.Statuses(ctx)
+	require.NoError(t, err)
+	require.False(t, statuses[0].Running)
+
+	close(release)
+	first.Wait()
+	clock.Advance(time.Minute)
+	second.Tick(ctx)
+	second.Wait()
+	require.Equal(t, 3, count())
+}
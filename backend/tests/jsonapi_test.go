@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+var jsonAPIHeaders = map[string]string{"Accept": "application/vnd.api+json"}
+
+func TestTodosAsJSONAPIDocuments(t *testing.T) {
+	app := testsupport.NewApp()
+
+	createRec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "api@example.com", "title": "JSON:API"}, jsonAPIHeaders)
+	require.Equal(t, http.StatusCreated, createRec.Code)
+	require.Equal(t, "application/vnd.api+json", createRec.Header().Get("Content-Type"))
+
+	var created struct {
+		Data struct {
+			Type       string `json:"type"`
+			ID         string `json:"id"`
+			Attributes struct {
+				Title string `json:"title"`
+			} `json:"attributes"`
+			Relationships struct {
+				Owner struct {
+					Data struct {
+						Type string `json:"type"`
+						ID   string `json:"id"`
+					} `json:"data"`
+				} `json:"owner"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &created))
+	require.Equal(t, "todos", created.Data.Type)
+	require.NotEmpty(t, created.Data.ID)
+	require.Equal(t, "JSON:API", created.Data.Attributes.Title)
+	require.Equal(t, "users", created.Data.Relationships.Owner.Data.Type)
+	require.Equal(t, "api@example.com", created.Data.Relationships.Owner.Data.ID)
+
+	listRec := app.Do(http.MethodGet, "/todos?email=api@example.com&limit=10", nil, jsonAPIHeaders)
+	var list struct {
+		Data  []map[string]interface{} `json:"data"`
+		Meta  map[string]int           `json:"meta"`
+		Links map[string]string        `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	require.Equal(t, 1, list.Meta["total"])
+	require.Equal(t, "/todos?email=api%40example.com&limit=10&offset=0", list.Links["self"])
+}
+
+func TestJSONAPIErrorsDocument(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodDelete, "/todos/invalid-id", nil, jsonAPIHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var body struct {
+		Errors []struct {
+			Status string `json:"status"`
+			Code   string `json:"code"`
+			Title  string `json:"title"`
+		} `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Errors, 1)
+	require.Equal(t, "400", body.Errors[0].Status)
+	require.Equal(t, "INVALID_ID", body.Errors[0].Code)
+}
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/linkpreview"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestFindURLs(t *testing.T) {
+	cases := map[string][]string{
+		"Ver https://hotel.test/menu.":                            {"https://hotel.test/menu"},
+		"(http://a.test/x) y http://a.test/x, https://b.test?q=1": {"http://a.test/x", "https://b.test?q=1"},
+		"ftp://a.test y www.hotel.test no son enlaces":            nil,
+		"https://a.test https://b.test https://c.test https://d.test": {
+			"https://a.test", "https://b.test", "https://c.test",
+		},
+	}
+	for text, want := range cases {
+		require.Equal(t, want, linkpreview.FindURLs(text, 3), text)
+	}
+}
+
+func TestLinkPreviewsInTodos(t *testing.T) {
+	app := testsupport.NewApp()
+	menu := "https://restaurante.test/menu"
+	app.Links.Publish(menu, linkpreview.Preview{Title: "Menu de otono", Image: "https://restaurante.test/plato.jpg"})
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{
+		"email": "ana@hotel.com",
+		"title": "Imprimir " + menu + " y https://caido.test/pagina",
+	}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Todo services.TodoResponse `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, []linkpreview.Preview{{URL: menu, Title: "Menu de otono", Image: "https://restaurante.test/plato.jpg"}}, created.Todo.Previews,
+		"pages that cannot be fetched get no preview")
+
+	// The page is fetched once per TTL.
+	createTodo(t, app.Router, "beto@hotel.com", "Revisar "+menu)
+	require.Equal(t, 1, app.Links.Fetches(menu))
+	app.Clock.Advance(testsupport.LinkPreviewTTL)
+	createTodo(t, app.Router, "beto@hotel.com", "Revisar "+menu)
+	require.Equal(t, 2, app.Links.Fetches(menu))
+
+	update := func(body map[string]any) services.TodoResponse {
+		rec := app.Do(http.MethodPut, "/todos/"+created.Todo.ID, body, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var updated struct {
+			Todo services.TodoResponse `json:"todo"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &updated)
+		return updated.Todo
+	}
+	require.Len(t, update(map[string]any{"completed": true}).Previews, 1, "only a new title changes the previews")
+	require.Empty(t, update(map[string]any{"title": "Imprimir el menu"}).Previews)
+}
+
+func TestLinkPreviewFetcher(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/og":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<!doctype html><html><head><title>Titulo de respaldo</title>
+<meta property="og:title" content=" Suite presidencial ">
+<meta property="og:image" content="/img/suite.jpg">
+<meta property="og:site_name" content="Hotel">
+<meta name="description" content="Vista al mar">
+</head><body><meta property="og:title" content="ignorado"></body></html>`)
+		case "/plain":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><title>Solo titulo</title><meta property="og:image" content="javascript:alert(1)"></head></html>`)
+		default:
+			w.Header().Set("Content-Type", "application/pdf")
+		}
+	}))
+	defer page.Close()
+	ctx := context.Background()
+
+	fetcher := linkpreview.NewFetcher(page.Client())
+	preview, err := fetcher.Fetch(ctx, page.URL+"/og")
+	require.NoError(t, err)
+	require.Equal(t, linkpreview.Preview{
+		URL:         page.URL + "/og",
+		Title:       "Suite presidencial",
+		Description: "Vista al mar",
+		Image:       page.URL + "/img/suite.jpg",
+		SiteName:    "Hotel",
+	}, preview)
+
+	preview, err = fetcher.Fetch(ctx, page.URL+"/plain")
+	require.NoError(t, err)
+	require.Equal(t, linkpreview.Preview{URL: page.URL + "/plain", Title: "Solo titulo"}, preview)
+
+	_, err = fetcher.Fetch(ctx, page.URL+"/menu.pdf")
+	require.ErrorIs(t, err, linkpreview.ErrNotHTML)
+
+	// The default client refuses the loopback address of the test server.
+	_, err = linkpreview.NewFetcher(linkpreview.SafeClient(time.Second)).Fetch(ctx, page.URL+"/og")
+	require.ErrorIs(t, err, linkpreview.ErrBlockedAddress)
+
+	for addr, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	} {
+		require.Equal(t, public, linkpreview.Public(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type linkBody struct {
+	Href   string `json:"href"`
+	Method string `json:"method"`
+}
+
+func TestTodoListPaginationLinks(t *testing.T) {
+	app := testsupport.NewApp()
+	for i := 0; i < 5; i++ {
+		rec := app.Do(http.MethodPost, "/todos", map[string]string{
+			"email": "pages@example.com",
+			"title": fmt.Sprintf("Tarea %d", i),
+		}, nil)
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+
+	rec := app.Do(http.MethodGet, "/todos?email=pages@example.com&offset=2&limit=2", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Todos []struct {
+			Title string              `json:"title"`
+			Links map[string]linkBody `json:"links"`
+		} `json:"todos"`
+		Links map[string]linkBody `json:"links"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Todos, 2)
+	require.Equal(t, "Tarea 2", body.Todos[0].Title)
+	require.Equal(t, respond.Pagination{Offset: 2, Limit: 2, Total: 5}, *testsupport.DecodeMeta(t, rec.Body.Bytes()).Pagination)
+	require.Equal(t, "/todos?email=pages%40example.com&limit=2&offset=4", body.Links["next"].Href)
+	require.Equal(t, "/todos?email=pages%40example.com&limit=2&offset=0", body.Links["prev"].Href)
+	require.Equal(t, "DELETE", body.Todos[0].Links["delete"].Method)
+	require.Contains(t, body.Todos[0].Links, "complete")
+
+	require.Equal(t,
+		`</todos?email=pages%40example.com&limit=2&offset=2>; rel="self", `+
+			`</todos?email=pages%40example.com&limit=2&offset=4>; rel="next", `+
+			`</todos?email=pages%40example.com&limit=2&offset=0>; rel="prev"`,
+		rec.Header().Get("Link"))
+}
+
+func TestTodoListWithoutLimitReturnsEverything(t *testing.T) {
+	app := testsupport.NewApp()
+	for i := 0; i < 3; i++ {
+		app.Do(http.MethodPost, "/todos", map[string]string{"email": "all@example.com", "title": "x"}, nil)
+	}
+
+	rec := app.Do(http.MethodGet, "/todos?email=all@example.com", nil, nil)
+
+	var body struct {
+		Todos []map[string]interface{} `json:"todos"`
+		Links map[string]linkBody      `json:"links"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Todos, 3)
+	require.NotContains(t, body.Links, "next")
+	require.Equal(t, "/todos?email=all%40example.com", body.Links["self"].Href)
+}
+
+func TestTodoQueriesNeedAFilter(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken})
+	createTodo(t, app.Router, "ana@example.com", "Uno")
+	createTodo(t, app.Router, "beto@example.com", "Dos")
+
+	for _, path := range []string{"/todos", "/todos?all=true", "/todos?trashed=true"} {
+		rec := app.Do(http.MethodGet, path, nil, nil)
+		require.Equal(t, http.StatusBadRequest, rec.Code, path)
+		require.Contains(t, rec.Body.String(), "TODO_FILTER_REQUIRED")
+	}
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodGet, "/todos", nil, adminHeaders).Code)
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@example.com"), 1)
+
+	rec := app.Do(http.MethodGet, "/todos?all=true", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, int64(2), testsupport.DecodeMeta(t, rec.Body.Bytes()).Pagination.Total)
+
+	rec = app.Do(http.MethodDelete, "/todos", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "TODO_FILTER_REQUIRED")
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/todos?email=ana@example.com", nil, nil).Code)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@example.com"))
+	require.Len(t, listTodos(t, app.Router, "/todos?email=beto@example.com"), 1)
+	for _, headers := range []map[string]string{nil, app.LoginAs(t, "ana@example.com", "")} {
+		rec = app.Do(http.MethodDelete, "/todos?all=true", nil, headers)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		require.Contains(t, rec.Body.String(), "CLEAR_ALL_FORBIDDEN")
+	}
+	require.Len(t, listTodos(t, app.Router, "/todos?email=beto@example.com"), 1)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/todos?all=true", nil, adminHeaders).Code)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=beto@example.com"))
+}
+
+func TestSignedInTodoListingIsOwnTodos(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken})
+	ana := app.LoginAs(t, "ana@example.com", "")
+	createTodo(t, app.Router, "ana@example.com", "Uno")
+	createTodo(t, app.Router, "beto@example.com", "Dos")
+
+	for _, path := range []string{"/todos", "/todos?all=true"} {
+		rec := app.Do(http.MethodGet, path, nil, ana)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page struct {
+			Todos []struct {
+				Title string `json:"title"`
+			} `json:"todos"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &page)
+		require.Len(t, page.Todos, 1, path)
+		require.Equal(t, "Uno", page.Todos[0].Title, path)
+	}
+}
+
+func TestTodoListRejectsInvalidPagination(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/todos?limit=1000", nil, nil)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
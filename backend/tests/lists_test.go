@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/policy"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	cases := []struct {
+		role   policy.Role
+		action policy.Action
+		want   bool
+	}{
+		{policy.Viewer, policy.Read, true},
+		{policy.Viewer, policy.Write, false},
+		{policy.Viewer, policy.Comment, false},
+		{policy.Contributor, policy.Write, true},
+		{policy.Contributor, policy.Comment, true},
+		{policy.Contributor, policy.Manage, false},
+		{policy.Admin, policy.Manage, true},
+		{policy.Admin, "delete", false},
+		{"owner", policy.Read, false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, policy.Allows(c.role, c.action), "%s %s", c.role, c.action)
+	}
+}
+
+type listBody struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	Members []struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	} `json:"members"`
+}
+
+func TestSharedListRoles(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	carla := app.LoginAs(t, "carla@hotel.com", "")
+
+	do := func(method, path string, body any, headers map[string]string, status int) listBody {
+		t.Helper()
+		rec := app.Do(method, path, body, headers)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		var out struct {
+			List listBody `json:"list"`
+		}
+		if status < http.StatusBadRequest {
+			testsupport.DecodeData(t, rec.Body.Bytes(), &out)
+		}
+		return out.List
+	}
+
+	do(http.MethodPost, "/lists", map[string]string{"name": " "}, ana, http.StatusBadRequest)
+	list := do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3"}, ana, http.StatusCreated)
+	require.Equal(t, "admin", list.Role)
+	path := "/lists/" + list.ID
+
+	do(http.MethodGet, path, nil, beto, http.StatusNotFound)
+	do(http.MethodPost, path+"/members", map[string]string{"email": "beto@hotel.com", "role": "owner"}, ana, http.StatusBadRequest)
+	list = do(http.MethodPost, path+"/members", map[string]string{"email": "Beto@Hotel.com", "role": "viewer"}, ana, http.StatusCreated)
+	require.Len(t, list.Members, 2)
+	do(http.MethodPost, path+"/members", map[string]string{"email": "beto@hotel.com", "role": "viewer"}, ana, http.StatusConflict)
+	do(http.MethodPost, path+"/members", map[string]string{"email": "carla@hotel.com", "role": "viewer"}, beto, http.StatusForbidden)
+
+	shared := inbox(t, app, beto, "")
+	require.Len(t, shared, 1)
+	require.Equal(t, "share", shared[0].Kind)
+	require.Equal(t, "ana@hotel.com", shared[0].Author)
+
+	// A viewer reads the list but cannot add or change its todos.
+	require.Equal(t, "viewer", do(http.MethodGet, path, nil, beto, http.StatusOK).Role)
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"title": "Cambiar sabanas", "listId": list.ID}, beto)
+	require.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"title": "Cambiar sabanas", "listId": list.ID}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Todo struct {
+			ID     string `json:"id"`
+			ListID string `json:"listId"`
+		} `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, list.ID, created.Todo.ListID)
+	todoPath := "/todos/" + created.Todo.ID
+
+	rec = app.Do(http.MethodGet, path+"/todos", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "Cambiar sabanas")
+	require.Equal(t, http.StatusOK, app.Do(http.MethodGet, todoPath+"/comments", nil, beto).Code)
+	require.Equal(t, http.StatusForbidden, app.Do(http.MethodPut, todoPath, map[string]bool{"completed": true}, beto).Code)
+	require.Equal(t, http.StatusForbidden, app.Do(http.MethodPost, todoPath+"/comments", map[string]string{"body": "hola"}, beto).Code)
+	require.Equal(t, http.StatusNotFound, app.Do(http.MethodPut, todoPath, map[string]bool{"completed": true}, carla).Code)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodDelete, todoPath, nil, nil).Code)
+
+	// Promoted to contributor, the same member may edit the todo.
+	do(http.MethodPut, path+"/members/beto@hotel.com", map[string]string{"role": "contributor"}, beto, http.StatusForbidden)
+	list = do(http.MethodPut, path+"/members/beto@hotel.com", map[string]string{"role": "contributor"}, ana, http.StatusOK)
+	require.Equal(t, "contributor", list.Members[1].Role)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodPut, todoPath, map[string]bool{"completed": true}, beto).Code)
+	require.Equal(t, http.StatusCreated, app.Do(http.MethodPost, todoPath+"/comments", map[string]string{"body": "hecho"}, beto).Code)
+
+	// The list always keeps an admin.
+	do(http.MethodPut, path+"/members/ana@hotel.com", map[string]string{"role": "viewer"}, ana, http.StatusConflict)
+	do(http.MethodDelete, path+"/members/ana@hotel.com", nil, ana, http.StatusConflict)
+	do(http.MethodPut, path+"/members/carla@hotel.com", map[string]string{"role": "viewer"}, ana, http.StatusNotFound)
+
+	// Members may leave on their own.
+	list = do(http.MethodDelete, path+"/members/beto@hotel.com", nil, beto, http.StatusOK)
+	require.Len(t, list.Members, 1)
+	do(http.MethodGet, path, nil, beto, http.StatusNotFound)
+
+	rec = app.Do(http.MethodGet, "/lists", nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var lists struct {
+		Lists []listBody `json:"lists"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &lists)
+	require.Len(t, lists.Lists, 1)
+	require.Equal(t, "Piso 3", lists.Lists[0].Name)
+}
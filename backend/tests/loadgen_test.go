@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func runLoadGen(t *testing.T, cfg services.LoadGenConfig) ([]services.User, []services.Todo) {
+	t.Helper()
+	ctx := context.Background()
+	users := testsupport.NewMemoryUserRepo()
+	todos := testsupport.NewMemoryTodoRepo()
+	stats, err := services.NewLoadGenerator(users, todos, func() time.Time { return testsupport.FixedTime }).Run(ctx, cfg)
+	require.NoError(t, err)
+	require.Equal(t, services.LoadGenStats{Users: cfg.Users, Todos: cfg.Todos}, stats)
+
+	written, err := users.List(ctx)
+	require.NoError(t, err)
+	live, err := todos.List(ctx, services.TodoQuery{All: true})
+	require.NoError(t, err)
+	trashed, err := todos.List(ctx, services.TodoQuery{Trashed: true, All: true})
+	require.NoError(t, err)
+	return written, append(live, trashed...)
+}
+
+func TestLoadGeneratorWritesRealisticData(t *testing.T) {
+	cfg := services.LoadGenConfig{
+		Users: 50, Todos: 2000, Distribution: services.DistributionZipf,
+		Completed: 0.5, Recurring: 0.1, Trashed: 0.1, Days: 30, Domain: "loadgen.test", Seed: 7, Workers: 4,
+	}
+	users, todos := runLoadGen(t, cfg)
+	require.Len(t, users, 50)
+	require.Len(t, todos, 2000)
+
+	signedUp := map[string]time.Time{}
+	for _, user := range users {
+		require.True(t, strings.HasSuffix(user.Email, "@loadgen.test"))
+		require.False(t, user.CreatedAt.Before(testsupport.FixedTime.AddDate(0, 0, -30)))
+		signedUp[user.Email] = user.CreatedAt
+	}
+
+	perUser := map[string]int{}
+	var completed, recurring, trashed int
+	for _, todo := range todos {
+		require.Contains(t, signedUp, todo.Email)
+		require.False(t, todo.CreatedAt.Before(signedUp[todo.Email]))
+		require.False(t, todo.CreatedAt.After(testsupport.FixedTime))
+		require.Equal(t, todo.CreatedAt.Unix(), todo.ID.Timestamp().Unix(), "IDs sort like creation dates")
+		perUser[todo.Email]++
+		if todo.Completed {
+			completed++
+		}
+		if todo.Recurrence != "" {
+			recurring++
+			require.True(t, todo.NextOccurrence.After(testsupport.FixedTime), "no occurrences are left due")
+		}
+		if todo.DeletedAt != nil {
+			trashed++
+		}
+	}
+	require.InDelta(t, 1000, completed, 150)
+	require.InDelta(t, 200, recurring, 80)
+	require.InDelta(t, 200, trashed, 80)
+	require.Greater(t, perUser["user000000@loadgen.test"], 2000/50*5, "zipf gives the first accounts most todos")
+}
+
+func TestLoadGeneratorIsReproducible(t *testing.T) {
+	cfg := services.LoadGenConfig{Users: 10, Todos: 200, Distribution: services.DistributionUniform, Completed: 0.3, Days: 7, Domain: "loadgen.test", Seed: 3}
+	_, first := runLoadGen(t, cfg)
+	_, second := runLoadGen(t, cfg)
+
+	ids := func(todos []services.Todo) []string {
+		hexes := make([]string, 0, len(todos))
+		for _, todo := range todos {
+			hexes = append(hexes, todo.ID.Hex()+todo.Email+todo.Title)
+		}
+		slices.Sort(hexes)
+		return hexes
+	}
+	require.Equal(t, ids(first), ids(second))
+}
+
+func TestLoadGeneratorRejectsInvalidConfig(t *testing.T) {
+	generator := services.NewLoadGenerator(testsupport.NewMemoryUserRepo(), testsupport.NewMemoryTodoRepo(), nil)
+	for _, cfg := range []services.LoadGenConfig{
+		{Users: 0, Todos: 10, Days: 1, Domain: "loadgen.test"},
+		{Users: 1, Todos: 10, Days: 1, Domain: "loadgen.test", Distribution: "normal"},
+		{Users: 1, Todos: 10, Days: 1, Domain: "loadgen.test", Completed: 1.5},
+		{Users: 1, Todos: 10, Days: 0, Domain: "loadgen.test"},
+	} {
+		_, err := generator.Run(context.Background(), cfg)
+		require.True(t, errors.Is(err, services.ErrInvalidLoadGen), "%+v", cfg)
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mailer"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// waitForMail waits for the background mailer to send an email whose
+// subject starts with subject; emails of different events may arrive in
+// any order.
+func waitForMail(t *testing.T, app *testsupport.App, subject string) mailer.Message {
+	t.Helper()
+	var found mailer.Message
+	require.Eventually(t, func() bool {
+		for _, msg := range app.Mailbox.Sent() {
+			if strings.HasPrefix(msg.Subject, subject) {
+				found = msg
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	return found
+}
+
+var optOutLink = regexp.MustCompile(`https://hotel\.test(/mail/opt-out\?\S+)`)
+
+func TestBookingConfirmationEmail(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-10", "2025-01-12")
+
+	msg := waitForMail(t, app, "Reserva confirmada")
+	require.Equal(t, "guest@example.com", msg.To)
+	require.Equal(t, "Reserva confirmada: habitacion 101 del 2025-01-10", msg.Subject)
+	require.Contains(t, msg.Body, "Noches: 2")
+	require.Contains(t, msg.Body, "Total: 200.00")
+	require.Contains(t, msg.Body, booking.ID)
+	require.Regexp(t, optOutLink, msg.Body)
+}
+
+func TestReviewRequestAfterCheckOut(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := completeStay(t, app, room.ID)
+
+	msg := waitForMail(t, app, "Como estuvo")
+	require.Equal(t, "Como estuvo tu estadia?", msg.Subject)
+	require.Contains(t, msg.Body, "https://hotel.test/bookings/"+booking.ID+"/review")
+}
+
+func TestArrivalReminderIsSentOnce(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	other := createRoom(t, app, map[string]interface{}{"number": "102", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-01-04", "2025-01-06")
+	createBooking(t, app, other.ID, "2025-01-05", "2025-01-06")
+
+	require.NoError(t, app.Mailer.SendReminders(context.Background()))
+	require.NoError(t, app.Mailer.SendReminders(context.Background()))
+
+	var reminders []mailer.Message
+	for _, msg := range app.Mailbox.Sent() {
+		if strings.HasPrefix(msg.Subject, "Tu llegada") {
+			reminders = append(reminders, msg)
+		}
+	}
+	require.Len(t, reminders, 1)
+	require.Equal(t, "Tu llegada es en 3 dias", reminders[0].Subject)
+	require.Contains(t, reminders[0].Body, "habitacion 101")
+}
+
+func TestMailOptOut(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-01-04", "2025-01-06")
+
+	link := optOutLink.FindStringSubmatch(waitForMail(t, app, "Reserva confirmada").Body)
+	require.Len(t, link, 2)
+
+	rec := app.Do(http.MethodGet, "/mail/opt-out?"+url.Values{"email": {"guest@example.com"}, "token": {"forged"}}.Encode(), nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_OPT_OUT_TOKEN")
+
+	rec = app.Do(http.MethodGet, link[1], nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "MAIL_OPTED_OUT")
+
+	require.NoError(t, app.Mailer.SendReminders(context.Background()))
+	require.Len(t, app.Mailbox.Sent(), 1, "no reminder after opting out")
+}
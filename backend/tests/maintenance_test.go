@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+var adminHeaders = map[string]string{middleware.AdminTokenHeader: testsupport.AdminToken}
+
+func TestMaintenanceModeBlocksWritesButAllowsReads(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		AdminToken:  testsupport.AdminToken,
+		Maintenance: middleware.NewMaintenanceMode(false, 2*time.Minute),
+	})
+
+	rec := app.Do(http.MethodPut, "/admin/maintenance", map[string]bool{"enabled": true}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	writeRec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "a@b.com", "title": "x"}, nil)
+	require.Equal(t, http.StatusServiceUnavailable, writeRec.Code)
+	require.Equal(t, "120", writeRec.Header().Get("Retry-After"))
+
+	readRec := app.Do(http.MethodGet, "/todos?email=a@b.com", nil, nil)
+	require.Equal(t, http.StatusOK, readRec.Code)
+
+	offRec := app.Do(http.MethodPut, "/admin/maintenance", map[string]bool{"enabled": false}, adminHeaders)
+	require.Equal(t, http.StatusOK, offRec.Code)
+
+	writeRec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "a@b.com", "title": "x"}, nil)
+	require.Equal(t, http.StatusCreated, writeRec.Code)
+}
+
+func TestAdminEndpointsRequireToken(t *testing.T) {
+	disabled := testsupport.NewApp()
+	rec := disabled.Do(http.MethodGet, "/admin/maintenance", nil, adminHeaders)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken})
+	rec = app.Do(http.MethodGet, "/admin/maintenance", nil, map[string]string{middleware.AdminTokenHeader: "wrong"})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/admin/maintenance", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"maintenance":false}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+}
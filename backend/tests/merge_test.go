@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestMergeAccounts(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana.perez@gmail.com", "")
+	variant := app.LoginAs(t, "anaperez+hotel@googlemail.com", "")
+	app.LoginAs(t, "otra@example.com", "")
+
+	createTodo(t, app.Router, "ana.perez@gmail.com", "Propia")
+	done := createTodo(t, app.Router, "anaperez+hotel@googlemail.com", "Pedir toallas")
+	rec := app.Do(http.MethodPut, "/todos/"+done.ID, map[string]bool{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	createTodo(t, app.Router, "anaperez+hotel@googlemail.com", "Revisar minibar")
+
+	// The variant administers a list where the signed-in account only views.
+	rec = app.Do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3"}, variant)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		List listBody `json:"list"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	rec = app.Do(http.MethodPost, "/lists/"+created.List.ID+"/members", map[string]string{"email": "ana.perez@gmail.com", "role": "viewer"}, variant)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	merge := func(body map[string]any, status int) services.MergeSummary {
+		t.Helper()
+		rec := app.Do(http.MethodPost, "/users/me/merge", body, ana)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		var payload struct {
+			Merge services.MergeSummary `json:"merge"`
+		}
+		if status == http.StatusOK {
+			testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+		}
+		return payload.Merge
+	}
+
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPost, "/users/me/merge", map[string]any{"email": "otra@example.com"}, nil).Code)
+	merge(map[string]any{"email": "Ana.Perez@gmail.com", "password": "secret"}, http.StatusBadRequest)
+	merge(map[string]any{"email": "otra@example.com", "password": "secret"}, http.StatusUnprocessableEntity)
+	merge(map[string]any{"email": "anaperez+hotel@googlemail.com", "password": "wrong"}, http.StatusForbidden)
+	merge(map[string]any{"email": "anaperez+hotel@googlemail.com", "token": "nope"}, http.StatusForbidden)
+	merge(map[string]any{"email": "a.naperez@gmail.com", "password": "secret"}, http.StatusForbidden)
+
+	// The dry run tells what would move and changes nothing.
+	summary := merge(map[string]any{"email": "anaperez+hotel@googlemail.com", "password": "secret", "dryRun": true}, http.StatusOK)
+	require.Equal(t, services.MergeSummary{
+		From: "anaperez+hotel@googlemail.com", Into: "ana.perez@gmail.com", DryRun: true, Todos: 2, Lists: 1, Logins: 1,
+	}, summary)
+	require.Len(t, listTodos(t, app.Router, "/todos?email=anaperez%2Bhotel@googlemail.com"), 2)
+	require.Empty(t, app.Outbox.OfType(events.UserMerged))
+
+	// A session token of the variant proves owning it too.
+	token := strings.TrimPrefix(variant["Authorization"], "Bearer ")
+	summary = merge(map[string]any{"email": "anaperez+hotel@googlemail.com", "token": token}, http.StatusOK)
+	require.False(t, summary.DryRun)
+	require.Equal(t, int64(2), summary.Todos)
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana.perez@gmail.com"), 3)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=anaperez%2Bhotel@googlemail.com"))
+	account, err := app.Users.FindByEmail(context.Background(), "ana.perez@gmail.com")
+	require.NoError(t, err)
+	for _, todo := range ownedTodos(t, app, "ana.perez@gmail.com") {
+		require.Equal(t, &account.ID, todo.UserID, todo.Title)
+	}
+
+	rec = app.Do(http.MethodGet, "/lists/"+created.List.ID, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, "admin", created.List.Role)
+	require.Len(t, created.List.Members, 1)
+
+	rec = app.Do(http.MethodGet, "/users/me/logins", nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, 2, strings.Count(rec.Body.String(), `"ip"`))
+
+	_, err = app.Users.FindByEmail(context.Background(), "anaperez+hotel@googlemail.com")
+	require.ErrorIs(t, err, services.ErrNotFound)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/users/me/usage", nil, variant).Code)
+	merged := app.Outbox.OfType(events.UserMerged)
+	require.Len(t, merged, 1)
+	require.Equal(t, "ana.perez@gmail.com", merged[0].Event.Key)
+	require.Equal(t, "ana.perez@gmail.com", merged[0].Actor)
+}
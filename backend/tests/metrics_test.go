@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/metrics"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// scrape reads the counters served at /metrics.
+func scrape(t *testing.T, app *testsupport.App) map[string]int64 {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/metrics", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+
+	values := map[string]int64{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, " ")
+		require.True(t, ok, line)
+		count, err := strconv.ParseInt(value, 10, 64)
+		require.NoError(t, err, line)
+		values[name] = count
+	}
+	return values
+}
+
+func TestDomainMetrics(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{ContractMode: middleware.ContractFail, AdminToken: testsupport.AdminToken})
+	before := scrape(t, app)
+	require.Contains(t, app.Do(http.MethodGet, "/metrics", nil, nil).Body.String(),
+		"# HELP todos_created_total Todos created, including imported ones.\n# TYPE todos_created_total counter\n")
+
+	app.Register(t, "beto@hotel.com")
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	rec := app.Do(http.MethodPost, "/login", map[string]string{"email": "ana@hotel.com", "password": "incorrecta"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	first := createTodo(t, app.Router, "ana@hotel.com", "Cambiar sabanas")
+	createTodo(t, app.Router, "ana@hotel.com", "Pedir toallas")
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@hotel.com"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodPut, "/todos/"+first.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = app.Do(http.MethodPost, "/todos/toggle-all", map[string]bool{"completed": true}, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	after := scrape(t, app)
+	require.Equal(t, int64(1), after["users_registered_total"]-before["users_registered_total"])
+	require.Equal(t, int64(1), after["login_failures_total"]-before["login_failures_total"])
+	require.Equal(t, int64(2), after["todos_created_total"]-before["todos_created_total"])
+	require.Equal(t, int64(2), after["todos_completed_total"]-before["todos_completed_total"])
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/mock"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type previewMessage struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+func outboxPreview(t *testing.T, app *testsupport.App, channel string) []previewMessage {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/admin/outbox-preview?channel="+channel, nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		Messages []previewMessage `json:"messages"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Messages
+}
+
+func TestOutboxPreviewShowsWhatMockIntegrationsWouldSend(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		AdminToken:   testsupport.AdminToken,
+		ContractMode: middleware.ContractFail,
+		Mocks:        mock.NewRecorder(10, nil),
+	})
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-01-10", "2025-01-12")
+	app.Register(t, "ana@example.com")
+	relayEvents(t, app)
+
+	require.Eventually(t, func() bool {
+		return len(outboxPreview(t, app, mock.ChannelEmail)) > 0
+	}, time.Second, 10*time.Millisecond)
+	emails := outboxPreview(t, app, mock.ChannelEmail)
+	require.Equal(t, "guest@example.com", emails[0].To)
+	require.Equal(t, "Reserva confirmada: habitacion 101 del 2025-01-10", emails[0].Subject)
+	require.Empty(t, app.Mailbox.Sent(), "nothing reaches the real mailer")
+
+	var published []string
+	for _, message := range outboxPreview(t, app, mock.ChannelEvent) {
+		require.Equal(t, events.BrokerMemory, message.To)
+		published = append(published, message.Subject)
+	}
+	require.ElementsMatch(t, []string{events.BookingCreated, events.UserRegistered}, published)
+	require.Len(t, outboxPreview(t, app, ""), len(emails)+len(published))
+
+	rec := app.Do(http.MethodGet, "/admin/outbox-preview?channel=sms", nil, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = app.Do(http.MethodDelete, "/admin/outbox-preview", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, outboxPreview(t, app, ""))
+}
+
+func TestOutboxPreviewIsOnlyMountedWithMocks(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken})
+	rec := app.Do(http.MethodGet, "/admin/outbox-preview", nil, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestMockRecorderKeepsTheLastMessages(t *testing.T) {
+	recorder := mock.NewRecorder(2, func() time.Time { return testsupport.FixedTime })
+	ctx := context.Background()
+	require.NoError(t, mock.Notifier{Recorder: recorder}.Notify(ctx, alerts.Alert{Title: "Panic en la API"}))
+	require.Error(t, mock.Verifier{Recorder: recorder}.Verify(ctx, "", "10.0.0.1"))
+	require.NoError(t, mock.Verifier{Recorder: recorder}.Verify(ctx, "solved", "10.0.0.1"))
+
+	messages := recorder.Messages("")
+	require.Len(t, messages, 2, "the oldest message is dropped")
+	for _, message := range messages {
+		require.Equal(t, mock.ChannelCaptcha, message.Channel)
+		require.Equal(t, testsupport.FixedTime, message.Time)
+	}
+	require.Empty(t, recorder.Messages(mock.ChannelAlert))
+}
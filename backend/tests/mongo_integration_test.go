@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// The tests in this file run against the MongoDB server of MONGO_URI, each
+// one on a scratch database dropped afterwards; without it they are
+// skipped. The transactions need the server to be a replica set.
+
+// scratchDatabase connects to MONGO_URI and returns a new empty database.
+func scratchDatabase(t *testing.T) *services.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		t.Skip("MONGO_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := services.ConnectMongo(ctx, uri)
+	require.NoError(t, err)
+	database := client.Database(fmt.Sprintf("test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = database.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+	db, err := services.NewDatabase(database, "")
+	require.NoError(t, err)
+	return db
+}
+
+func TestMongoBackfillOwners(t *testing.T) {
+	ctx := context.Background()
+	db := scratchDatabase(t)
+	todos := services.NewMongoTodoRepository(db.Collection("todos"), db.Collection("users"))
+	require.NoError(t, todos.EnsureIndexes(ctx))
+
+	// Todos stored before they had an owner, and accounts stored straight,
+	// as Insert would hand them their todos.
+	ana := primitive.NewObjectID()
+	_, err := db.Collection("users").InsertOne(ctx, services.User{ID: ana, Email: "ana@hotel.com", Password: "secret"})
+	require.NoError(t, err)
+	for _, email := range []string{"ana@hotel.com", "ana@hotel.com", "beto@hotel.com"} {
+		_, err := db.Collection("todos").InsertOne(ctx, bson.M{"email": email, "title": "Revisar minibar", "completed": false, "createdAt": time.Now()})
+		require.NoError(t, err)
+	}
+
+	backfilled, err := todos.BackfillOwners(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), backfilled)
+	backfilled, err = todos.BackfillOwners(ctx)
+	require.NoError(t, err)
+	require.Zero(t, backfilled)
+
+	owned, err := todos.List(ctx, services.TodoQuery{Email: "ana@hotel.com"})
+	require.NoError(t, err)
+	require.Len(t, owned, 2)
+	for _, todo := range owned {
+		require.Equal(t, &ana, todo.UserID)
+	}
+	// The todos of emails without an account keep being found by email.
+	orphans, err := todos.List(ctx, services.TodoQuery{Email: "beto@hotel.com"})
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+	require.Nil(t, orphans[0].UserID)
+}
+
+// parallelErrors runs n calls of fn at once and returns their errors.
+func parallelErrors(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// mongoBookings returns a booking repository over a scratch database with
+// one room, and the room.
+func mongoBookings(t *testing.T) (*services.MongoBookingRepository, *services.Database, primitive.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	db := scratchDatabase(t)
+	bookings := services.NewMongoBookingRepository(db.Collection("bookings"), db.Collection("rooms"))
+	require.NoError(t, bookings.EnsureIndexes(ctx))
+	res, err := db.Collection("rooms").InsertOne(ctx, services.Room{Number: "101", Type: "double", Capacity: 2, Price: 100, Status: "available"})
+	require.NoError(t, err)
+	return bookings, db, res.InsertedID.(primitive.ObjectID)
+}
+
+func newBooking(room primitive.ObjectID, checkIn, checkOut string) services.Booking {
+	in, _ := time.Parse(time.DateOnly, checkIn)
+	out, _ := time.Parse(time.DateOnly, checkOut)
+	return services.Booking{RoomID: room, Email: "guest@example.com", Guests: 1, CheckIn: in, CheckOut: out, Status: services.BookingBooked, CreatedAt: time.Now()}
+}
+
+// TestMongoConcurrentBookingsCannotOverbook is TestConcurrentBookingsCannotOverbook
+// on the transaction and the room counter of MongoBookingRepository
+// instead of the lock of the memory repository.
+func TestMongoConcurrentBookingsCannotOverbook(t *testing.T) {
+	ctx := context.Background()
+	bookings, db, room := mongoBookings(t)
+
+	// Every booking shares at least the night of 2025-02-12.
+	ranges := [][2]string{
+		{"2025-02-10", "2025-02-13"},
+		{"2025-02-12", "2025-02-13"},
+		{"2025-02-11", "2025-02-15"},
+		{"2025-02-12", "2025-02-20"},
+	}
+	const attempts = 20
+	errs := parallelErrors(attempts, func(i int) error {
+		stay := ranges[i%len(ranges)]
+		_, err := bookings.Create(ctx, newBooking(room, stay[0], stay[1]))
+		return err
+	})
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		require.ErrorIs(t, err, services.ErrBookingOverlap)
+	}
+	require.Equal(t, 1, created, errs)
+	stored, err := bookings.List(ctx, services.BookingQuery{RoomID: room})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+
+	// The rejected transactions rolled back their bump of the room counter.
+	var counter struct {
+		BookingVersion int `bson:"bookingVersion"`
+	}
+	require.NoError(t, db.Collection("rooms").FindOne(ctx, bson.M{"_id": room}).Decode(&counter))
+	require.Equal(t, 1, counter.BookingVersion)
+}
+
+func TestMongoConcurrentModificationsCannotOverbook(t *testing.T) {
+	ctx := context.Background()
+	bookings, _, room := mongoBookings(t)
+
+	var existing []services.Booking
+	for _, stay := range [][2]string{
+		{"2025-03-10", "2025-03-11"},
+		{"2025-03-11", "2025-03-12"},
+		{"2025-03-12", "2025-03-13"},
+		{"2025-03-13", "2025-03-14"},
+	} {
+		booking, err := bookings.Create(ctx, newBooking(room, stay[0], stay[1]))
+		require.NoError(t, err)
+		existing = append(existing, booking)
+	}
+
+	// All bookings try to move onto the same night at once.
+	moved := newBooking(room, "2025-03-20", "2025-03-21")
+	errs := parallelErrors(len(existing), func(i int) error {
+		booking := existing[i]
+		booking.CheckIn, booking.CheckOut = moved.CheckIn, moved.CheckOut
+		_, err := bookings.Update(ctx, booking)
+		return err
+	})
+
+	updated := 0
+	for _, err := range errs {
+		if err == nil {
+			updated++
+			continue
+		}
+		require.ErrorIs(t, err, services.ErrBookingOverlap)
+	}
+	require.Equal(t, 1, updated, errs)
+	onNight, err := bookings.List(ctx, services.BookingQuery{RoomID: room, StayFrom: moved.CheckIn, StayTo: moved.CheckOut})
+	require.NoError(t, err)
+	require.Len(t, onNight, 1)
+}
+
+func TestMongoUserSearchCountsTheTodosOfEachUser(t *testing.T) {
+	ctx := context.Background()
+	db := scratchDatabase(t)
+	users := services.NewMongoUserRepository(db.Collection("users"), db.Collection("todos"))
+
+	ana, beto := primitive.NewObjectID(), primitive.NewObjectID()
+	for _, user := range []services.User{{ID: ana, Email: "ana@hotel.com", Password: "secret"}, {ID: beto, Email: "beto@hotel.com", Password: "secret"}} {
+		_, err := db.Collection("users").InsertOne(ctx, user)
+		require.NoError(t, err)
+	}
+	created := time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	for _, todo := range []bson.M{
+		{"userId": ana, "email": "ana@hotel.com", "title": "Uno", "createdAt": created},
+		{"userId": ana, "email": "ana@hotel.com", "title": "Dos", "createdAt": created, "deletedAt": deleted},
+	} {
+		_, err := db.Collection("todos").InsertOne(ctx, todo)
+		require.NoError(t, err)
+	}
+
+	found, err := users.Search(ctx, services.UserQuery{})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	require.Equal(t, "ana@hotel.com", found[0].Email)
+	require.Equal(t, int64(1), found[0].TodoCount)
+	require.Equal(t, deleted, found[0].LastActivityAt.UTC())
+	require.Zero(t, found[1].TodoCount)
+	require.Nil(t, found[1].LastActivityAt)
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+func TestPoolOptionsBuildClientOptions(t *testing.T) {
+	pool := services.PoolOptions{
+		MaxPoolSize:            50,
+		MinPoolSize:            5,
+		ServerSelectionTimeout: 3 * time.Second,
+		ReadPreference:         "secondaryPreferred",
+	}
+
+	opts, err := pool.ClientOptions()
+	require.NoError(t, err)
+	require.EqualValues(t, 50, *opts.MaxPoolSize)
+	require.EqualValues(t, 5, *opts.MinPoolSize)
+	require.Equal(t, 3*time.Second, *opts.ServerSelectionTimeout)
+	require.Nil(t, opts.SocketTimeout)
+	require.Equal(t, readpref.SecondaryPreferredMode, opts.ReadPreference.Mode())
+	require.Contains(t, pool.String(), "socketTimeout=default")
+}
+
+func TestPoolOptionsRejectUnknownReadPreference(t *testing.T) {
+	_, err := services.PoolOptions{ReadPreference: "closest"}.ClientOptions()
+	require.Error(t, err)
+}
+
+func TestDatabasePrefixesCollectionNames(t *testing.T) {
+	// Connect does not reach the server until the first operation.
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+	qa, err := services.NewDatabase(client.Database("shared"), "qa_")
+	require.NoError(t, err)
+	require.Equal(t, "qa_todos", qa.Collection("todos").Name())
+
+	plain, err := services.NewDatabase(client.Database("shared"), "")
+	require.NoError(t, err)
+	require.Equal(t, "todos", plain.Collection("todos").Name())
+
+	for _, prefix := range []string{"qa$", "system.", "a\x00"} {
+		_, err := services.NewDatabase(client.Database("shared"), prefix)
+		require.ErrorIs(t, err, services.ErrInvalidCollectionPrefix, prefix)
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func receiveAlert(t *testing.T, notifier channelNotifier) alerts.Alert {
+	t.Helper()
+	select {
+	case alert := <-notifier:
+		return alert
+	case <-time.After(time.Second):
+		t.Fatal("no se recibio la alerta")
+		return alerts.Alert{}
+	}
+}
+
+func requireNoAlert(t *testing.T, notifier channelNotifier) {
+	t.Helper()
+	select {
+	case alert := <-notifier:
+		t.Fatalf("alerta inesperada: %s", alert.Title)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMonitorAlertsOnErrorRateAndLoginFailures(t *testing.T) {
+	notifier := make(channelNotifier, 4)
+	now := testsupport.FixedTime
+	monitor := alerts.NewMonitor(alerts.MonitorConfig{
+		Window: time.Minute, ErrorRate: 50, MinRequests: 4, LoginFailures: 3, Cooldown: 10 * time.Minute,
+	}, notifier, func() time.Time { return now })
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{Monitor: monitor, Faults: middleware.NewFaultInjector(nil)})
+	fail := map[string]string{middleware.FaultStatusHeader: "503"}
+
+	// Too few requests to judge the error rate.
+	app.Do(http.MethodGet, "/healthz", nil, nil)
+	app.Do(http.MethodGet, "/healthz", nil, nil)
+	require.Equal(t, http.StatusServiceUnavailable, app.Do(http.MethodGet, "/todos", nil, fail).Code)
+	requireNoAlert(t, notifier)
+
+	app.Do(http.MethodGet, "/todos", nil, fail)
+	alert := receiveAlert(t, notifier)
+	require.Equal(t, "Tasa de errores elevada", alert.Title)
+	require.Equal(t, map[string]string{"errors": "2", "requests": "4", "window": "1m0s"}, alert.Fields)
+
+	// The cooldown holds back a second alert of the same kind.
+	app.Do(http.MethodGet, "/todos", nil, fail)
+	requireNoAlert(t, notifier)
+
+	app.Register(t, "ana@hotel.com")
+	for i := 0; i < 3; i++ {
+		rec := app.Do(http.MethodPost, "/login", map[string]string{"email": "ana@hotel.com", "password": "incorrecta"}, nil)
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	alert = receiveAlert(t, notifier)
+	require.Equal(t, "Pico de inicios de sesion fallidos", alert.Title)
+	require.Equal(t, "3", alert.Fields["failures"])
+
+	// Once the window has passed the old failures no longer count.
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		app.Do(http.MethodPost, "/login", map[string]string{"email": "ana@hotel.com", "password": "incorrecta"}, nil)
+	}
+	requireNoAlert(t, notifier)
+}
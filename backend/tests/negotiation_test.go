@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestResponsesHonorXMLAccept(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Do(http.MethodPost, "/todos", map[string]string{"email": "xml@example.com", "title": "En XML"}, nil)
+
+	rec := app.Do(http.MethodGet, "/todos?email=xml@example.com", nil, map[string]string{"Accept": "application/xml"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/xml")
+
+	var body struct {
+		Todos []struct {
+			Title string `xml:"title"`
+			Links []struct {
+				Rel  string `xml:"rel,attr"`
+				Href string `xml:"href,attr"`
+			} `xml:"links>link"`
+		} `xml:"data>todos"`
+	}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Todos, 1)
+	require.Equal(t, "En XML", body.Todos[0].Title)
+	require.NotEmpty(t, body.Todos[0].Links)
+}
+
+func TestXMLErrorsShareTheResponseRoot(t *testing.T) {
+	app := testsupport.NewApp()
+	accept := map[string]string{"Accept": "application/xml"}
+
+	rec := app.Do(http.MethodPost, "/login", map[string]string{"email": "nobody@example.com", "password": "x"}, accept)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/xml")
+	var body struct {
+		XMLName xml.Name `xml:"response"`
+		Code    string   `xml:"code"`
+		Error   string   `xml:"error"`
+	}
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &body), rec.Body.String())
+	require.Equal(t, "INVALID_CREDENTIALS", body.Code)
+	require.NotEmpty(t, body.Error)
+
+	rec = app.Do(http.MethodGet, "/does-not-exist", nil, accept)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "<response><code>ROUTE_NOT_FOUND</code>")
+	rec = app.Do(http.MethodPatch, "/todos", nil, accept)
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	require.Contains(t, rec.Body.String(), "<response><code>METHOD_NOT_ALLOWED</code>")
+}
+
+func TestResponsesHonorMessagePackAccept(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/login", map[string]string{"email": "nobody@example.com", "password": "x"},
+		map[string]string{"Accept": "application/msgpack"})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/msgpack")
+
+	var body map[string]string
+	require.NoError(t, codec.NewDecoderBytes(rec.Body.Bytes(), new(codec.MsgpackHandle)).Decode(&body))
+	require.Equal(t, "INVALID_CREDENTIALS", body["code"])
+}
+
+func TestUnsupportedAcceptFallsBackToJSON(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/healthz", nil, map[string]string{"Accept": "text/csv"})
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type inboxBody struct {
+	Notifications []notificationBody `json:"notifications"`
+	Unread        int                `json:"unread"`
+}
+
+func TestNotificationInbox(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID + "/comments"
+
+	rec := app.Do(http.MethodPost, path, map[string]string{"body": "Yo me encargo"}, beto)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	for _, body := range []string{"@beto@hotel.com uno", "@beto@hotel.com dos", "@beto@hotel.com tres"} {
+		rec = app.Do(http.MethodPost, path, map[string]string{"body": body}, ana)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	rec = app.Do(http.MethodGet, "/notifications?limit=2", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var page inboxBody
+	testsupport.DecodeData(t, rec.Body.Bytes(), &page)
+	require.Len(t, page.Notifications, 2)
+	require.Equal(t, 3, page.Unread)
+	require.Contains(t, rec.Body.String(), `"total":3`)
+
+	rec = app.Do(http.MethodPost, "/notifications/"+page.Notifications[0].ID+"/read", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, inbox(t, app, beto, "?unread=true"), 2)
+
+	rec = app.Do(http.MethodPost, "/notifications/read-all", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var read struct {
+		Read int `json:"read"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &read)
+	require.Equal(t, 2, read.Read)
+	require.Empty(t, inbox(t, app, beto, "?unread=true"))
+	require.Len(t, inbox(t, app, beto, ""), 3)
+
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/notifications", nil, nil).Code)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPost, "/notifications/read-all", nil, nil).Code)
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodGet, "/notifications?limit=-1", nil, beto).Code)
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodPost, "/notifications/bad-id/read", nil, beto).Code)
+}
+
+func TestCheckOutNotifiesHousekeeper(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-01", "2025-01-02")
+	require.Equal(t, http.StatusOK, app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-in", nil, app.StaffHeaders(t)).Code)
+	require.Equal(t, http.StatusOK, app.Do(http.MethodPost, "/bookings/"+booking.ID+"/check-out", nil, app.StaffHeaders(t)).Code)
+
+	notifications := inbox(t, app, app.LoginAs(t, testsupport.Housekeepers[0], ""), "")
+	require.Len(t, notifications, 1)
+	require.Equal(t, services.NotificationAssignment, notifications[0].Kind)
+	require.NotEmpty(t, notifications[0].TodoID)
+	require.Empty(t, inbox(t, app, app.LoginAs(t, testsupport.Housekeepers[1], ""), ""))
+}
+
+func TestArrivalReminderNotifiesOnce(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createBooking(t, app, room.ID, "2025-01-04", "2025-01-06")
+
+	require.NoError(t, app.Mailer.SendReminders(context.Background()))
+	require.NoError(t, app.Mailer.SendReminders(context.Background()))
+
+	notifications := inbox(t, app, app.LoginAs(t, "guest@example.com", ""), "")
+	require.Len(t, notifications, 1)
+	require.Equal(t, services.NotificationReminder, notifications[0].Kind)
+}
@@ -0,0 +1,305 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/webauthn"
+)
+
+// authenticator simulates a platform authenticator holding one ES256
+// passkey.
+type authenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+	origin       string
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	return &authenticator{key: key, credentialID: id, origin: testsupport.Origin}
+}
+
+func cbor(t *testing.T, value any) []byte {
+	t.Helper()
+	var out []byte
+	require.NoError(t, codec.NewEncoderBytes(&out, new(codec.CborHandle)).Encode(value))
+	return out
+}
+
+func (a *authenticator) clientData(t *testing.T, ceremony, challenge string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{"type": ceremony, "challenge": challenge, "origin": a.origin})
+	require.NoError(t, err)
+	return data
+}
+
+// authData builds the authenticator data, with the attested credential when
+// registering.
+func (a *authenticator) authData(t *testing.T, attested bool) []byte {
+	t.Helper()
+	rpIDHash := sha256.Sum256([]byte(testsupport.RPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(0x01 | 0x04)
+	if attested {
+		flags |= 0x40
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if !attested {
+		return data
+	}
+
+	data = append(data, make([]byte, 16)...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, cbor(t, map[int]any{
+		1:  2,
+		3:  webauthn.AlgES256,
+		-1: 1,
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})...)
+}
+
+// create answers navigator.credentials.create in its toJSON form.
+func (a *authenticator) create(t *testing.T, challenge string) map[string]any {
+	t.Helper()
+	attestation := cbor(t, map[string]any{"fmt": "none", "attStmt": map[string]any{}, "authData": a.authData(t, true)})
+	return map[string]any{
+		"id":   webauthn.Encode(a.credentialID),
+		"type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    webauthn.Encode(a.clientData(t, "webauthn.create", challenge)),
+			"attestationObject": webauthn.Encode(attestation),
+		},
+	}
+}
+
+// get answers navigator.credentials.get in its toJSON form, bumping the
+// signature counter like real authenticators do.
+func (a *authenticator) get(t *testing.T, challenge string) map[string]any {
+	t.Helper()
+	a.signCount++
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	authData := a.authData(t, false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	return map[string]any{
+		"id":   webauthn.Encode(a.credentialID),
+		"type": "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    webauthn.Encode(clientData),
+			"authenticatorData": webauthn.Encode(authData),
+			"signature":         webauthn.Encode(signature),
+		},
+	}
+}
+
+type ceremonyOptions struct {
+	CeremonyID string `json:"ceremonyId"`
+	PublicKey  struct {
+		Challenge string `json:"challenge"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		RPID               string                                   `json:"rpId"`
+		ExcludeCredentials []services.PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+		AllowCredentials   []services.PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	} `json:"publicKey"`
+}
+
+func passkeyOptions(t *testing.T, app *testsupport.App, path string, body any, headers map[string]string) ceremonyOptions {
+	t.Helper()
+	rec := app.Do(http.MethodPost, path, body, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var options ceremonyOptions
+	testsupport.DecodeData(t, rec.Body.Bytes(), &options)
+	return options
+}
+
+// registerPasskey adds the passkey of device to the signed-in account.
+func registerPasskey(t *testing.T, app *testsupport.App, headers map[string]string, device *authenticator) services.PasskeyResponse {
+	t.Helper()
+	options := passkeyOptions(t, app, "/users/me/passkeys/options", nil, headers)
+	rec := app.Do(http.MethodPost, "/users/me/passkeys", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"name":       "Notebook",
+		"credential": device.create(t, options.PublicKey.Challenge),
+	}, headers)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var payload struct {
+		Passkey services.PasskeyResponse `json:"passkey"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	return payload.Passkey
+}
+
+func passkeyLogin(t *testing.T, app *testsupport.App, email string, device *authenticator) *httptest.ResponseRecorder {
+	t.Helper()
+	body := map[string]string{}
+	if email != "" {
+		body["email"] = email
+	}
+	options := passkeyOptions(t, app, "/login/passkey/options", body, nil)
+	return app.Do(http.MethodPost, "/login/passkey", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"credential": device.get(t, options.PublicKey.Challenge),
+	}, map[string]string{"User-Agent": "Passkey/1.0"})
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	headers := loginFrom(t, app, "ana@example.com", "Firefox/128.0")
+	device := newAuthenticator(t)
+
+	rec := app.Do(http.MethodPost, "/users/me/passkeys/options", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	options := passkeyOptions(t, app, "/users/me/passkeys/options", nil, headers)
+	require.Equal(t, testsupport.RPID, options.PublicKey.RP.ID)
+	require.Empty(t, options.PublicKey.ExcludeCredentials)
+
+	credential := device.create(t, options.PublicKey.Challenge)
+	rec = app.Do(http.MethodPost, "/users/me/passkeys", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"name":       "Notebook",
+		"credential": credential,
+	}, headers)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"name":"Notebook"`)
+
+	// Each challenge is answered once.
+	rec = app.Do(http.MethodPost, "/users/me/passkeys", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"credential": credential,
+	}, headers)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_PASSKEY_CEREMONY")
+
+	// The browser is told not to register the same authenticator twice.
+	options = passkeyOptions(t, app, "/users/me/passkeys/options", nil, headers)
+	require.Equal(t, []services.PublicKeyCredentialDescriptor{{Type: "public-key", ID: webauthn.Encode(device.credentialID)}},
+		options.PublicKey.ExcludeCredentials)
+	rec = app.Do(http.MethodPost, "/users/me/passkeys", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"credential": device.create(t, options.PublicKey.Challenge),
+	}, headers)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "PASSKEY_EXISTS")
+
+	// Discoverable login: no email, the browser picks the passkey.
+	rec = passkeyLogin(t, app, "", device)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login map[string]string
+	testsupport.DecodeData(t, rec.Body.Bytes(), &login)
+	require.Equal(t, "LOGIN_SUCCEEDED", login["code"])
+	passkeyHeaders := testsupport.Bearer(login["token"])
+
+	sessions := listSessions(t, app, passkeyHeaders)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "Passkey/1.0", sessions[0].UserAgent)
+
+	options = passkeyOptions(t, app, "/login/passkey/options", map[string]string{"email": "ANA@example.com"}, nil)
+	require.Len(t, options.PublicKey.AllowCredentials, 1)
+	require.Equal(t, testsupport.RPID, options.PublicKey.RPID)
+
+	rec = app.Do(http.MethodGet, "/users/me/passkeys", nil, passkeyHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list struct {
+		Passkeys []services.PasskeyResponse `json:"passkeys"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &list)
+	require.Len(t, list.Passkeys, 1)
+	require.NotNil(t, list.Passkeys[0].LastUsedAt)
+}
+
+func TestPasskeyLoginRejectsInvalidResponses(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	device := newAuthenticator(t)
+	passkey := registerPasskey(t, app, loginFrom(t, app, "ana@example.com", "Firefox/128.0"), device)
+	require.Equal(t, "Notebook", passkey.Name)
+
+	// A phishing site gets a response bound to its own origin.
+	device.origin = "https://hotel.example.evil"
+	rec := passkeyLogin(t, app, "", device)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_PASSKEY")
+	device.origin = testsupport.Origin
+
+	// A cloned authenticator replays an old signature counter.
+	require.Equal(t, http.StatusOK, passkeyLogin(t, app, "", device).Code)
+	device.signCount--
+	rec = passkeyLogin(t, app, "", device)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_PASSKEY")
+
+	rec = passkeyLogin(t, app, "", newAuthenticator(t))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_CREDENTIALS")
+
+	// A challenge issued for another account does not accept the passkey.
+	rec = passkeyLogin(t, app, "beto@example.com", device)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	options := passkeyOptions(t, app, "/login/passkey/options", nil, nil)
+	app.Clock.Advance(services.PasskeyCeremonyTTL + time.Second)
+	rec = app.Do(http.MethodPost, "/login/passkey", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"credential": device.get(t, options.PublicKey.Challenge),
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_PASSKEY_CEREMONY")
+
+	rec = app.Do(http.MethodPost, "/login/passkey", map[string]any{
+		"ceremonyId": options.CeremonyID,
+		"credential": map[string]any{"id": "%%%", "response": map[string]string{}},
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_PAYLOAD")
+}
+
+func TestDeletePasskey(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	ana := loginFrom(t, app, "ana@example.com", "Firefox/128.0")
+	beto := loginFrom(t, app, "beto@example.com", "Chrome/126.0")
+	device := newAuthenticator(t)
+	passkey := registerPasskey(t, app, ana, device)
+
+	rec := app.Do(http.MethodDelete, "/users/me/passkeys/"+passkey.ID, nil, beto)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "PASSKEY_NOT_FOUND")
+	rec = app.Do(http.MethodDelete, "/users/me/passkeys/nope", nil, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = app.Do(http.MethodDelete, "/users/me/passkeys/"+passkey.ID, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "PASSKEY_DELETED")
+	require.Equal(t, http.StatusUnauthorized, passkeyLogin(t, app, "", device).Code)
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type paymentBody struct {
+	ID          string  `json:"id"`
+	BookingID   string  `json:"bookingId"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Method      string  `json:"method"`
+	Status      string  `json:"status"`
+	ProviderRef string  `json:"providerRef"`
+}
+
+func createPayment(t *testing.T, app *testsupport.App, bookingID string, payload map[string]interface{}) paymentBody {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/bookings/"+bookingID+"/payments", payload, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var body struct {
+		Payment paymentBody `json:"payment"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Payment
+}
+
+// sendNotification posts payload to the webhook signed with secret.
+func sendNotification(app *testsupport.App, secret string, payload map[string]interface{}) (int, string, paymentBody) {
+	encoded, _ := json.Marshal(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(encoded)
+
+	rec := app.Do(http.MethodPost, "/payments/webhook", payload, map[string]string{
+		handlers.WebhookSignatureHeader: hex.EncodeToString(mac.Sum(nil)),
+	})
+	var body struct {
+		Code    string      `json:"code"`
+		Payment paymentBody `json:"payment"`
+	}
+	// Successful answers carry the body in the data of the envelope.
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	raw := rec.Body.Bytes()
+	if rec.Code < http.StatusMultipleChoices && json.Unmarshal(raw, &envelope) == nil {
+		raw = envelope.Data
+	}
+	_ = json.Unmarshal(raw, &body)
+	return rec.Code, body.Code, body.Payment
+}
+
+func TestCreateAndListPayments(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+
+	cash := createPayment(t, app, booking.ID, map[string]interface{}{"amount": 100.505, "currency": "ars", "method": "Cash"})
+	require.Equal(t, "approved", cash.Status)
+	require.Equal(t, "ARS", cash.Currency)
+	require.Equal(t, 100.51, cash.Amount)
+
+	online := createPayment(t, app, booking.ID, map[string]interface{}{"amount": 50, "currency": "USD", "method": "stripe"})
+	require.Equal(t, "pending", online.Status)
+
+	rec := app.Do(http.MethodGet, "/bookings/"+booking.ID+"/payments", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Payments []paymentBody `json:"payments"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Payments, 2)
+	require.Equal(t, cash.ID, body.Payments[0].ID)
+}
+
+func TestCreatePaymentValidation(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+
+	for _, payload := range []map[string]interface{}{
+		{"amount": 0, "currency": "ARS", "method": "cash"},
+		{"amount": 10, "currency": "PESOS", "method": "cash"},
+		{"amount": 10, "currency": "ARS", "method": "bitcoin"},
+	} {
+		rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/payments", payload, app.StaffHeaders(t))
+		require.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		require.Contains(t, rec.Body.String(), "INVALID_PAYMENT_INPUT")
+	}
+
+	rec := app.Do(http.MethodGet, "/bookings/65a000000000000000000000/payments", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "BOOKING_NOT_FOUND")
+}
+
+func TestPaymentWebhookIsIdempotent(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+	payment := createPayment(t, app, booking.ID, map[string]interface{}{"amount": 200, "currency": "ARS", "method": "mercadopago"})
+
+	notification := map[string]interface{}{
+		"eventId":     "evt-1",
+		"paymentId":   payment.ID,
+		"status":      "approved",
+		"providerRef": "mp-123",
+	}
+	status, code, updated := sendNotification(app, testsupport.WebhookSecret, notification)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "PAYMENT_NOTIFICATION_PROCESSED", code)
+	require.Equal(t, "approved", updated.Status)
+	require.Equal(t, "mp-123", updated.ProviderRef)
+
+	// The provider redelivers the same event.
+	status, code, updated = sendNotification(app, testsupport.WebhookSecret, notification)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "PAYMENT_NOTIFICATION_IGNORED", code)
+	require.Equal(t, "approved", updated.Status)
+
+	// A late rejection no longer applies to an approved payment.
+	status, code, updated = sendNotification(app, testsupport.WebhookSecret, map[string]interface{}{
+		"eventId": "evt-0", "paymentId": payment.ID, "status": "rejected",
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "PAYMENT_NOTIFICATION_IGNORED", code)
+	require.Equal(t, "approved", updated.Status)
+
+	status, code, updated = sendNotification(app, testsupport.WebhookSecret, map[string]interface{}{
+		"eventId": "evt-2", "paymentId": payment.ID, "status": "refunded",
+	})
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "PAYMENT_NOTIFICATION_PROCESSED", code)
+	require.Equal(t, "refunded", updated.Status)
+}
+
+func TestPaymentWebhookRejectsInvalidNotifications(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+	payment := createPayment(t, app, booking.ID, map[string]interface{}{"amount": 200, "currency": "ARS", "method": "stripe"})
+
+	status, code, _ := sendNotification(app, "wrong-secret", map[string]interface{}{
+		"eventId": "evt-1", "paymentId": payment.ID, "status": "approved",
+	})
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, "INVALID_WEBHOOK_SIGNATURE", code)
+
+	status, code, _ = sendNotification(app, testsupport.WebhookSecret, map[string]interface{}{
+		"eventId": "evt-1", "paymentId": payment.ID, "status": "paid",
+	})
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "INVALID_PAYMENT_NOTIFICATION", code)
+
+	status, code, _ = sendNotification(app, testsupport.WebhookSecret, map[string]interface{}{
+		"eventId": "evt-1", "paymentId": "65a000000000000000000000", "status": "approved",
+	})
+	require.Equal(t, http.StatusNotFound, status)
+	require.Equal(t, "PAYMENT_NOT_FOUND", code)
+}
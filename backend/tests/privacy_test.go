@@ -0,0 +1,343 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type erasureBody struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Todos    int64  `json:"todos"`
+	Sessions int64  `json:"sessions"`
+	Logins   int64  `json:"logins"`
+	Comments int64  `json:"comments"`
+	Bookings int64  `json:"bookings"`
+	Activity int64  `json:"activity"`
+}
+
+func decodeErasure(t *testing.T, body []byte) erasureBody {
+	t.Helper()
+	var payload struct {
+		Erasure erasureBody `json:"erasure"`
+	}
+	testsupport.DecodeData(t, body, &payload)
+	return payload.Erasure
+}
+
+// seedGuestAccount registers the guest of createBooking with a completed
+// and a trashed todo and a reviewed stay; another account gets a todo too.
+func seedGuestAccount(t *testing.T, app *testsupport.App) (map[string]string, bookingBody) {
+	t.Helper()
+	app.Register(t, "guest@example.com")
+	guest := app.LoginAs(t, "guest@example.com", "")
+
+	done := createTodo(t, app.Router, "guest@example.com", "Pedir toallas")
+	rec := app.Do(http.MethodPut, "/todos/"+done.ID, map[string]bool{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	trashed := createTodo(t, app.Router, "guest@example.com", "Cancelar spa")
+	rec = app.Do(http.MethodDelete, "/todos/"+trashed.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	createTodo(t, app.Router, "otra@example.com", "Ajena")
+
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := completeStay(t, app, room.ID)
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/review", map[string]interface{}{"rating": 4, "comment": "Muy comodo"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	return guest, booking
+}
+
+func TestExportAccount(t *testing.T) {
+	app := testsupport.NewApp()
+	guest, booking := seedGuestAccount(t, app)
+
+	rec := app.Do(http.MethodGet, "/users/me/export", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), "LOGIN_REQUIRED")
+
+	rec = app.Do(http.MethodGet, "/users/me/export", nil, guest)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Export struct {
+			Account  struct{ Email string } `json:"account"`
+			Todos    []todoBody             `json:"todos"`
+			Comments []struct {
+				BookingID string `json:"bookingId"`
+				Comment   string `json:"comment"`
+			} `json:"comments"`
+			Activity []struct {
+				Type string `json:"type"`
+				Key  string `json:"key"`
+			} `json:"activity"`
+			Logins []struct {
+				IP string `json:"ip"`
+			} `json:"logins"`
+		} `json:"export"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	export := payload.Export
+	require.Equal(t, "guest@example.com", export.Account.Email)
+	require.Len(t, export.Todos, 2)
+	require.Len(t, export.Comments, 1)
+	require.Equal(t, booking.ID, export.Comments[0].BookingID)
+	require.Equal(t, "Muy comodo", export.Comments[0].Comment)
+	types := make([]string, 0, len(export.Activity))
+	for _, event := range export.Activity {
+		types = append(types, event.Type)
+	}
+	require.ElementsMatch(t, []string{"user.registered", "todo.completed", "booking.created"}, types)
+	require.Len(t, export.Logins, 1)
+	require.NotEmpty(t, export.Logins[0].IP)
+
+	rec = app.Do(http.MethodGet, "/users/me/export?format=zip", nil, guest)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Header().Get("Content-Disposition"), "export.zip")
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		require.NoError(t, err)
+		files[file.Name], err = io.ReadAll(r)
+		require.NoError(t, err)
+	}
+	require.Len(t, files, 5)
+	var todos []todoBody
+	require.NoError(t, json.Unmarshal(files["todos.json"], &todos))
+	require.Len(t, todos, 2)
+	require.Contains(t, string(files["account.json"]), "guest@example.com")
+	require.Contains(t, string(files["comments.json"]), "Muy comodo")
+	require.Contains(t, string(files["activity.json"]), "booking.created")
+	require.Contains(t, string(files["logins.json"]), "sessionId")
+}
+
+func TestExportAccountXLSX(t *testing.T) {
+	app := testsupport.NewApp()
+	guest, _ := seedGuestAccount(t, app)
+	rec := app.Do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3: suites"}, guest)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var list struct {
+		List struct {
+			ID string `json:"id"`
+		} `json:"list"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &list)
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "guest@example.com", "title": "Revisar minibar", "listId": list.List.ID}, guest)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = importTodos(app, "source=google", []byte(googleTasksExport), guest)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = app.Do(http.MethodGet, "/users/me/export?format=xlsx", nil, guest)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Header().Get("Content-Disposition"), "todos.xlsx")
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		var node struct{}
+		require.NoError(t, xml.Unmarshal(data, &node), file.Name)
+		files[file.Name] = string(data)
+	}
+
+	// A sheet for the own todos, one per list and one for the trash; list
+	// names lose the characters Excel refuses.
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	require.NoError(t, xml.Unmarshal([]byte(files["xl/workbook.xml"]), &workbook))
+	require.Len(t, workbook.Sheets, 4)
+	require.Equal(t, "todos", workbook.Sheets[0].Name)
+	require.Equal(t, "Piso 3  suites", workbook.Sheets[1].Name)
+	require.Equal(t, "Compras", workbook.Sheets[2].Name)
+	require.Equal(t, "trash", workbook.Sheets[3].Name)
+
+	own := files["xl/worksheets/sheet1.xml"]
+	require.Contains(t, own, `<c r="A1" t="inlineStr" s="1"><is><t xml:space="preserve">title</t></is></c>`)
+	require.Contains(t, own, "Pedir toallas")
+	require.Contains(t, own, `<c r="B2" t="b"><v>1</v></c>`)
+	require.NotContains(t, own, "Cancelar spa")
+	require.Contains(t, files["xl/worksheets/sheet2.xml"], "Revisar minibar")
+	require.Contains(t, files["xl/worksheets/sheet4.xml"], "Cancelar spa")
+
+	// Overdue todos are highlighted by Excel through conditional formatting.
+	imported := files["xl/worksheets/sheet3.xml"]
+	require.Contains(t, imported, `<conditionalFormatting sqref="A2:I3">`)
+	require.Contains(t, imported, "<formula>AND(NOT($B2),$E2&lt;&gt;&#34;&#34;,$E2&lt;NOW())</formula>")
+	// 2024-06-01 as an Excel serial date.
+	require.Contains(t, imported, `<c r="E2" s="2"><v>45444</v></c>`)
+	require.NotContains(t, files["xl/worksheets/sheet4.xml"], "conditionalFormatting")
+
+	rec = app.Do(http.MethodGet, "/users/me/export", nil, map[string]string{
+		"Authorization": guest["Authorization"],
+		"Accept":        "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Disposition"), "todos.xlsx")
+}
+
+func TestEraseAccount(t *testing.T) {
+	app := testsupport.NewApp()
+	guest, booking := seedGuestAccount(t, app)
+	device := newAuthenticator(t)
+	registerPasskey(t, app, guest, device)
+
+	rec := app.Do(http.MethodDelete, "/users/me", nil, guest)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_ERASURE_MODE")
+
+	rec = app.Do(http.MethodDelete, "/users/me?mode=gdpr", nil, guest)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	erasure := decodeErasure(t, rec.Body.Bytes())
+	require.Equal(t, "completed", erasure.Status)
+	require.Equal(t, "/users/erasures/"+erasure.ID, rec.Header().Get("Location"))
+	require.Equal(t, erasureBody{
+		ID: erasure.ID, Status: "completed", Todos: 2, Sessions: 1, Logins: 1, Comments: 1, Bookings: 1, Activity: 3,
+	}, erasure)
+
+	rec = app.Do(http.MethodGet, "/users/erasures/"+erasure.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, erasure, decodeErasure(t, rec.Body.Bytes()))
+
+	// The session and the personal data are gone.
+	rec = app.Do(http.MethodGet, "/users/me/usage", nil, guest)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, http.StatusUnauthorized, passkeyLogin(t, app, "", device).Code)
+	_, err := app.Users.FindByEmail(context.Background(), "guest@example.com")
+	require.ErrorIs(t, err, services.ErrNotFound)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=guest@example.com"))
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=guest@example.com&trashed=true"))
+	require.Len(t, listTodos(t, app.Router, "/todos?email=otra@example.com"), 1)
+
+	// The bookings and events are kept for auditing under an alias.
+	alias := services.ErasureAlias("guest@example.com")
+	rec = app.Do(http.MethodGet, "/bookings/"+booking.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, alias, decodeBooking(t, rec.Body.Bytes()).Email)
+	registered := app.Outbox.OfType("user.registered")
+	require.Len(t, registered, 1)
+	require.Equal(t, alias, registered[0].Event.Key)
+	for _, eventType := range []string{"user.registered", "todo.completed", "booking.created"} {
+		for _, msg := range app.Outbox.OfType(eventType) {
+			require.NotContains(t, string(msg.Event.Data), "guest@example.com")
+		}
+	}
+	require.Equal(t, "", app.Reviews.All()[0].Comment)
+	require.Equal(t, 4, app.Reviews.All()[0].Rating)
+
+	rec = app.Do(http.MethodGet, "/users/erasures/"+booking.ID, nil, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "ERASURE_NOT_FOUND")
+	rec = app.Do(http.MethodGet, "/users/erasures/nope", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAnonymizeUsers(t *testing.T) {
+	app := testsupport.NewApp()
+	guest, booking := seedGuestAccount(t, app)
+	users, err := app.Users.List(context.Background())
+	require.NoError(t, err)
+
+	rec := app.Do(http.MethodDelete, "/users?mode=soft", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_CLEAR_MODE")
+
+	anonymize := func() services.Anonymization {
+		t.Helper()
+		rec := app.Do(http.MethodDelete, "/users?mode=anonymize", nil, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var payload struct {
+			Anonymized services.Anonymization `json:"anonymized"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+		return payload.Anonymized
+	}
+	result := anonymize()
+	require.Equal(t, int64(len(users)), result.Users)
+	require.Equal(t, int64(2), result.Todos)
+	require.Equal(t, int64(1), result.Bookings)
+
+	// The account is a tombstone that cannot sign in; its session is gone.
+	alias := services.ErasureAlias("guest@example.com")
+	_, err = app.Users.FindByEmail(context.Background(), "guest@example.com")
+	require.ErrorIs(t, err, services.ErrNotFound)
+	tombstone, err := app.Users.FindByEmail(context.Background(), alias)
+	require.NoError(t, err)
+	require.NotNil(t, tombstone.AnonymizedAt)
+	require.Empty(t, tombstone.Password)
+	rec = app.Do(http.MethodGet, "/users/me/usage", nil, guest)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// The todos, bookings and events are kept under the alias.
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=guest@example.com"))
+	require.Len(t, listTodos(t, app.Router, "/todos?email="+alias), 1)
+	require.Len(t, listTodos(t, app.Router, "/todos?email="+alias+"&trashed=true"), 1)
+	// The staff was anonymized too, so another manager signs in.
+	manager := app.LoginAs(t, "direccion@hotel.com", services.RoleManager)
+	rec = app.Do(http.MethodGet, "/bookings/"+booking.ID, nil, manager)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, alias, decodeBooking(t, rec.Body.Bytes()).Email)
+	require.Equal(t, "", app.Reviews.All()[0].Comment)
+	require.Equal(t, 4, app.Reviews.All()[0].Rating)
+	require.Len(t, app.Outbox.WithKey(alias), 2)
+	anonymized := app.Outbox.OfType(events.UserAnonymized)
+	require.Len(t, anonymized, len(users))
+	for _, eventType := range []string{events.UserRegistered, events.TodoCompleted, events.BookingCreated} {
+		for _, msg := range app.Outbox.OfType(eventType) {
+			require.NotContains(t, string(msg.Event.Data), "guest@example.com")
+		}
+	}
+
+	// Tombstones are skipped: only the new manager is left. Without a mode
+	// the users are deleted.
+	require.Equal(t, services.Anonymization{Users: 1}, anonymize())
+	rec = app.Do(http.MethodDelete, "/users", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	users, err = app.Users.List(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, users)
+}
+
+func TestEraseLargeAccountInBackground(t *testing.T) {
+	app := testsupport.NewApp()
+	guest := app.LoginAs(t, "grande@example.com", "")
+	for i := 0; i <= services.ErasureSyncLimit; i++ {
+		_, err := app.Todos.Create(context.Background(), services.Todo{Email: "grande@example.com", Title: "Tarea", CreatedAt: testsupport.FixedTime})
+		require.NoError(t, err)
+	}
+
+	rec := app.Do(http.MethodDelete, "/users/me?mode=gdpr", nil, guest)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	require.Equal(t, "running", decodeErasure(t, rec.Body.Bytes()).Status)
+	location := rec.Header().Get("Location")
+
+	var erasure erasureBody
+	require.Eventually(t, func() bool {
+		rec := app.Do(http.MethodGet, location, nil, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		erasure = decodeErasure(t, rec.Body.Bytes())
+		return erasure.Status == "completed"
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(services.ErasureSyncLimit+1), erasure.Todos)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=grande@example.com"))
+}
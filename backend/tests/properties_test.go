@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func newChainApp() *testsupport.App {
+	return testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+}
+
+func createProperty(t *testing.T, app *testsupport.App, code, name string) string {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/properties", map[string]string{"code": code, "name": name, "city": "Cordoba"}, adminHeaders)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var body struct {
+		Property struct {
+			ID   string `json:"id"`
+			Code string `json:"code"`
+		} `json:"property"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Property.ID
+}
+
+// withProperty returns headers plus the X-Property-ID header.
+func withProperty(headers map[string]string, propertyID string) map[string]string {
+	scoped := maps.Clone(headers)
+	if scoped == nil {
+		scoped = map[string]string{}
+	}
+	scoped[middleware.PropertyHeader] = propertyID
+	return scoped
+}
+
+// loginAtProperty signs in a staff member bound to propertyID.
+func loginAtProperty(t *testing.T, app *testsupport.App, email, role, propertyID string) map[string]string {
+	t.Helper()
+	headers := app.LoginAs(t, email, role)
+	rec := app.Do(http.MethodPut, "/admin/users/"+email+"/property", map[string]string{"propertyId": propertyID}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	return headers
+}
+
+func TestPropertiesScopeRooms(t *testing.T) {
+	app := newChainApp()
+	centro := createProperty(t, app, "cba-centro", "Hotel Centro")
+	sierras := createProperty(t, app, "CBA-SIERRAS", "Hotel Sierras")
+
+	rec := app.Do(http.MethodPost, "/properties", map[string]string{"code": "CBA-CENTRO", "name": "Otro"}, adminHeaders)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	rec = app.Do(http.MethodPost, "/properties", map[string]string{"code": "X"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	room := map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100}
+	for _, property := range []string{centro, sierras} {
+		rec = app.Do(http.MethodPost, "/rooms", room, withProperty(adminHeaders, property))
+		require.Equal(t, http.StatusCreated, rec.Code, "the same number is allowed in another hotel: %s", rec.Body.String())
+	}
+	rec = app.Do(http.MethodPost, "/rooms", room, withProperty(adminHeaders, centro))
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	var body struct {
+		Rooms []roomBody `json:"rooms"`
+	}
+	rec = app.Do(http.MethodGet, "/rooms", nil, withProperty(nil, sierras))
+	require.Equal(t, http.StatusOK, rec.Code)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Rooms, 1)
+	require.Equal(t, sierras, body.Rooms[0].PropertyID)
+
+	rec = app.Do(http.MethodGet, "/rooms", nil, nil)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Rooms, 2)
+
+	rec = app.Do(http.MethodGet, "/rooms", nil, withProperty(nil, "bad"))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodGet, "/rooms", nil, withProperty(nil, primitive.NewObjectID().Hex()))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "PROPERTY_NOT_FOUND")
+
+	rec = app.Do(http.MethodGet, "/properties", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "CBA-SIERRAS")
+}
+
+func TestStaffOnlyWorkOnTheirProperty(t *testing.T) {
+	app := newChainApp()
+	centro := createProperty(t, app, "CENTRO", "Hotel Centro")
+	sierras := createProperty(t, app, "SIERRAS", "Hotel Sierras")
+	staff := loginAtProperty(t, app, "recepcion@centro.com", services.RoleFrontDesk, centro)
+
+	room := map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100}
+	rec := app.Do(http.MethodPost, "/rooms", room, withProperty(adminHeaders, centro))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = app.Do(http.MethodPost, "/rooms", room, withProperty(adminHeaders, sierras))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var created struct {
+		Room roomBody `json:"room"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	otherRoom := created.Room.ID
+
+	// Without the header the staff member works on their own property.
+	rec = app.Do(http.MethodGet, "/rooms/"+otherRoom, nil, staff)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = app.Do(http.MethodGet, "/rooms", nil, withProperty(staff, sierras))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "PROPERTY_FORBIDDEN")
+
+	booking := map[string]interface{}{
+		"roomId": otherRoom, "email": "guest@example.com", "guests": 1,
+		"checkIn": "2025-02-10", "checkOut": "2025-02-12",
+	}
+	rec = app.Do(http.MethodPost, "/bookings", booking, staff)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// A chain-wide admin books the other hotel; the staff member cannot see it.
+	rec = app.Do(http.MethodPost, "/bookings", booking, adminHeaders)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	other := decodeBooking(t, rec.Body.Bytes())
+	require.Equal(t, sierras, other.PropertyID)
+
+	rec = app.Do(http.MethodGet, "/bookings/"+other.ID, nil, staff)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = app.Do(http.MethodPost, "/bookings/"+other.ID+"/cancel", nil, staff)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = app.Do(http.MethodGet, "/bookings", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"bookings":[]}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+
+	rec = app.Do(http.MethodGet, "/rooms/availability?from=2025-02-10&to=2025-02-12", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var available struct {
+		Rooms []roomBody `json:"rooms"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &available)
+	require.Len(t, available.Rooms, 1)
+	require.Equal(t, centro, available.Rooms[0].PropertyID)
+}
+
+func TestAssignStaffProperty(t *testing.T) {
+	app := newChainApp()
+	centro := createProperty(t, app, "CENTRO", "Hotel Centro")
+	require.NoError(t, app.Users.Insert(context.Background(), services.User{Email: "staff@hotel.com", Password: "x"}))
+
+	rec := app.Do(http.MethodPut, "/admin/users/staff@hotel.com/property", map[string]string{"propertyId": primitive.NewObjectID().Hex()}, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "PROPERTY_NOT_FOUND")
+	rec = app.Do(http.MethodPut, "/admin/users/nadie@hotel.com/property", map[string]string{"propertyId": centro}, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "USER_NOT_FOUND")
+
+	rec = app.Do(http.MethodPut, "/admin/users/staff@hotel.com/property", map[string]string{"propertyId": centro}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), centro)
+
+	rec = app.Do(http.MethodPut, "/admin/users/staff@hotel.com/property", map[string]string{"propertyId": ""}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	user, err := app.Users.FindByEmail(context.Background(), "staff@hotel.com")
+	require.NoError(t, err)
+	require.Nil(t, user.PropertyID)
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func clientIPFor(app *testsupport.App, remoteAddr, forwardedFor string) string {
+	app.Router.GET("/_ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/_ip", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func TestClientIPUsesForwardedHeaderFromTrustedProxy(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{TrustedProxies: []string{"10.0.0.0/8"}})
+
+	require.Equal(t, "203.0.113.7", clientIPFor(app, "10.1.2.3:5000", "203.0.113.7"))
+}
+
+func TestClientIPIgnoresForwardedHeaderWithoutTrustedProxies(t *testing.T) {
+	app := testsupport.NewApp()
+
+	require.Equal(t, "10.1.2.3", clientIPFor(app, "10.1.2.3:5000", "203.0.113.7"))
+}
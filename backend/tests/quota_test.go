@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func accountUsage(t *testing.T, app *testsupport.App, headers map[string]string) services.AccountUsage {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/users/me/usage", nil, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Usage services.AccountUsage `json:"usage"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	return payload.Usage
+}
+
+func setQuota(t *testing.T, app *testsupport.App, email string, body interface{}) int {
+	t.Helper()
+	rec := app.Do(http.MethodPut, "/admin/users/"+email+"/quota", body, adminHeaders)
+	return rec.Code
+}
+
+func TestTodoQuota(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+	ana := app.LoginAs(t, "ana@example.com", "")
+	createTodo(t, app.Router, "ana@example.com", "Primera")
+
+	usage := accountUsage(t, app, ana)
+	require.Equal(t, "ana@example.com", usage.Email)
+	require.Equal(t, int64(1), usage.Todos.Used)
+	require.Equal(t, testsupport.MaxTodos, *usage.Todos.Limit)
+	require.False(t, usage.Overridden)
+
+	require.Equal(t, http.StatusOK, setQuota(t, app, "ana@example.com", map[string]int{"maxTodos": 2}))
+	second := createTodo(t, app.Router, "ana@example.com", "Segunda")
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "Ana@Example.com", "title": "Tercera"}, nil)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "QUOTA_EXCEEDED")
+	usage = accountUsage(t, app, ana)
+	require.Equal(t, int64(2), usage.Todos.Used)
+	require.Equal(t, 2, *usage.Todos.Limit)
+	require.True(t, usage.Overridden)
+
+	createTodo(t, app.Router, "beto@example.com", "Otra cuenta")
+
+	rec = app.Do(http.MethodDelete, "/todos/"+second.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	createTodo(t, app.Router, "ana@example.com", "Tercera")
+
+	// Zero lifts the limit; null goes back to the plan limit.
+	require.Equal(t, http.StatusOK, setQuota(t, app, "ana@example.com", map[string]int{"maxTodos": 0}))
+	require.Nil(t, accountUsage(t, app, ana).Todos.Limit)
+	createTodo(t, app.Router, "ana@example.com", "Cuarta")
+	require.Equal(t, http.StatusOK, setQuota(t, app, "ana@example.com", map[string]interface{}{"maxTodos": nil}))
+	usage = accountUsage(t, app, ana)
+	require.Equal(t, testsupport.MaxTodos, *usage.Todos.Limit)
+	require.False(t, usage.Overridden)
+}
+
+func TestQuotaEndpointsValidation(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken, ContractMode: middleware.ContractFail})
+	app.LoginAs(t, "ana@example.com", "")
+
+	rec := app.Do(http.MethodGet, "/users/me/usage", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), "LOGIN_REQUIRED")
+
+	rec = app.Do(http.MethodPut, "/admin/users/ana@example.com/quota", map[string]int{"maxTodos": 1}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, http.StatusBadRequest, setQuota(t, app, "ana@example.com", map[string]int{"maxTodos": -1}))
+	require.Equal(t, http.StatusBadRequest, setQuota(t, app, "ana@example.com", map[string]string{"maxTodos": "muchos"}))
+	require.Equal(t, http.StatusNotFound, setQuota(t, app, "nadie@example.com", map[string]int{"maxTodos": 1}))
+}
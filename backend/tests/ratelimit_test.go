@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/i18n"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestRateLimitTiers(t *testing.T) {
+	clock := testsupport.NewClock(testsupport.FixedTime)
+	limiter := middleware.NewRateLimiter(middleware.RateLimits{Window: time.Minute, Anonymous: 3, User: 5}, nil, clock.Now)
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		AdminToken:   testsupport.AdminToken,
+		ContractMode: middleware.ContractFail,
+		RateLimiter:  limiter,
+	})
+	staff := app.LoginAs(t, "gerencia@hotel.com", services.RoleManager)
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		rec := app.Do(http.MethodGet, "/rooms", nil, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, "3", rec.Header().Get(middleware.RateLimitLimitHeader))
+		require.Equal(t, strconv.Itoa(remaining), rec.Header().Get(middleware.RateLimitRemainingHeader))
+		require.Equal(t, "60", rec.Header().Get(middleware.RateLimitResetHeader))
+	}
+	rec := app.Do(http.MethodGet, "/rooms", nil, nil)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+	require.Contains(t, rec.Body.String(), string(i18n.RateLimited))
+
+	rec = app.Do(http.MethodGet, "/healthz", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, "the health check is not limited")
+
+	rec = app.Do(http.MethodGet, "/rooms", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code, "signed-in users have their own budget")
+	require.Equal(t, "5", rec.Header().Get(middleware.RateLimitLimitHeader))
+	require.Equal(t, "4", rec.Header().Get(middleware.RateLimitRemainingHeader))
+
+	for i := 0; i < 10; i++ {
+		rec = app.Do(http.MethodGet, "/admin/maintenance", nil, adminHeaders)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	require.Empty(t, rec.Header().Get(middleware.RateLimitLimitHeader), "the admin tier is unlimited")
+
+	limiter.SetLimits(middleware.RateLimits{Window: time.Minute, Anonymous: 4, User: 5})
+	rec = app.Do(http.MethodGet, "/rooms", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, "a reload applies to the counts already taken")
+}
+
+func TestMemoryRateLimitStoreSlidesTheWindow(t *testing.T) {
+	store := middleware.NewMemoryRateLimitStore()
+	ctx := context.Background()
+	start := testsupport.FixedTime
+	take := func(at time.Duration) middleware.RateLimitResult {
+		result, err := store.Take(ctx, "user:ana@hotel.com", 2, time.Minute, start.Add(at))
+		require.NoError(t, err)
+		return result
+	}
+
+	require.True(t, take(0).Allowed)
+	require.True(t, take(10*time.Second).Allowed)
+	blocked := take(30 * time.Second)
+	require.False(t, blocked.Allowed)
+	require.Equal(t, start.Add(time.Minute), blocked.Reset)
+
+	// Halfway through the next window the previous one still weighs one
+	// request of the two.
+	result := take(90 * time.Second)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+	require.False(t, take(90*time.Second).Allowed)
+
+	require.True(t, take(5*time.Minute).Allowed, "an idle caller starts over")
+
+	other, err := store.Take(ctx, "user:beto@hotel.com", 2, time.Minute, start.Add(5*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, other.Remaining, "callers are counted apart")
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type quoteBody struct {
+	Nights []struct {
+		Date     string  `json:"date"`
+		Price    float64 `json:"price"`
+		RatePlan string  `json:"ratePlan"`
+	} `json:"nights"`
+	Subtotal float64 `json:"subtotal"`
+	Discount float64 `json:"discount"`
+	Total    float64 `json:"total"`
+}
+
+func createRatePlan(t *testing.T, app *testsupport.App, payload map[string]interface{}) string {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/rate-plans", payload, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var body struct {
+		RatePlan struct {
+			ID string `json:"id"`
+		} `json:"ratePlan"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.RatePlan.ID
+}
+
+func getQuote(t *testing.T, app *testsupport.App, roomID, checkIn, checkOut string) quoteBody {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/bookings/quote?roomId="+roomID+"&checkIn="+checkIn+"&checkOut="+checkOut, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body struct {
+		Quote quoteBody `json:"quote"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Quote
+}
+
+func TestQuoteUsesBasePriceWithoutRatePlans(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+
+	quote := getQuote(t, app, room.ID, "2025-02-10", "2025-02-13")
+	require.Len(t, quote.Nights, 3)
+	require.Equal(t, 300.0, quote.Total)
+	require.Empty(t, quote.Nights[0].RatePlan)
+}
+
+func TestQuoteAppliesSeasonWeekendAndStayDiscount(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	createRatePlan(t, app, map[string]interface{}{
+		"name":         "Temporada alta",
+		"roomType":     "double",
+		"startDate":    "2025-02-01",
+		"endDate":      "2025-03-01",
+		"price":        150,
+		"weekendPrice": 200,
+		"discounts":    []map[string]interface{}{{"minNights": 3, "percent": 10}},
+		"priority":     10,
+	})
+	createRatePlan(t, app, map[string]interface{}{"name": "Suites", "roomType": "suite", "price": 400})
+
+	// Nights of Thu 27 and Fri 28 fall in the season (Friday at the weekend
+	// price); Sat 1 March is past the season and falls back to the room price.
+	quote := getQuote(t, app, room.ID, "2025-02-27", "2025-03-02")
+	require.Len(t, quote.Nights, 3)
+	require.Equal(t, 150.0, quote.Nights[0].Price)
+	require.Equal(t, 200.0, quote.Nights[1].Price)
+	require.Equal(t, "Temporada alta", quote.Nights[1].RatePlan)
+	require.Equal(t, 100.0, quote.Nights[2].Price)
+	require.Equal(t, 450.0, quote.Subtotal)
+	require.Equal(t, 45.0, quote.Discount)
+	require.Equal(t, 405.0, quote.Total)
+}
+
+func TestBookingStoresQuoteAtCreation(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	planID := createRatePlan(t, app, map[string]interface{}{"name": "Promo", "price": 80})
+
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+
+	// Later rate changes do not alter the stored price.
+	rec := app.Do(http.MethodPut, "/rate-plans/"+planID, map[string]interface{}{"name": "Promo", "price": 500}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/bookings/"+booking.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Booking struct {
+			Quote quoteBody `json:"quote"`
+		} `json:"booking"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Equal(t, 160.0, body.Booking.Quote.Total)
+}
+
+func TestRatePlanValidation(t *testing.T) {
+	app := testsupport.NewApp()
+	cases := []map[string]interface{}{
+		{"price": 100},
+		{"name": "x", "price": -1},
+		{"name": "x", "price": 100, "roomType": "castle"},
+		{"name": "x", "price": 100, "startDate": "2025-03-01", "endDate": "2025-02-01"},
+		{"name": "x", "price": 100, "discounts": []map[string]interface{}{{"minNights": 0, "percent": 10}}},
+	}
+	for _, payload := range cases {
+		rec := app.Do(http.MethodPost, "/rate-plans", payload, app.StaffHeaders(t))
+		require.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		require.Contains(t, rec.Body.String(), "INVALID_RATE_PLAN_INPUT")
+	}
+
+	rec := app.Do(http.MethodDelete, "/rate-plans/65a000000000000000000000", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
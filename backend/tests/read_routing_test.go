@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestReadPreferenceWithMaxStaleness(t *testing.T) {
+	pref, err := services.ReadPreference("secondaryPreferred", 2*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, readpref.SecondaryPreferredMode, pref.Mode())
+	staleness, ok := pref.MaxStaleness()
+	require.True(t, ok)
+	require.Equal(t, 2*time.Minute, staleness)
+
+	_, err = services.ReadPreference("secondary", 30*time.Second)
+	require.Error(t, err, "MongoDB requires at least 90s")
+	_, err = services.ReadPreference("primary", 2*time.Minute)
+	require.Error(t, err, "the primary is never stale")
+	_, err = services.ReadPreference("closest", 0)
+	require.Error(t, err)
+}
+
+func TestTodoListingsReadFromTheListRepository(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := testsupport.NewMemoryTodoRepo(), testsupport.NewMemoryTodoRepo()
+	todos := services.NewTodoService(primary, nil, nil, nil)
+	todos.SetListRepository(replica)
+
+	created, err := todos.Create(ctx, "ana@hotel.com", "Cambiar toallas", "", services.TodoLabel{}, nil)
+	require.NoError(t, err)
+	page, err := todos.List(ctx, services.TodoQuery{Email: "ana@hotel.com"})
+	require.NoError(t, err)
+	require.Empty(t, page.Todos, "the replica has not caught up yet")
+
+	stored, err := primary.List(ctx, services.TodoQuery{Email: "ana@hotel.com"})
+	require.NoError(t, err)
+	_, err = replica.Create(ctx, stored[0])
+	require.NoError(t, err)
+	page, err = todos.List(ctx, services.TodoQuery{Email: "ana@hotel.com", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Todos, 1)
+	require.Equal(t, created.ID, page.Todos[0].ID)
+	require.EqualValues(t, 1, page.Total)
+}
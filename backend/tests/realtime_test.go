@@ -0,0 +1,325 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// dialRealtime opens the realtime WebSocket of server with headers.
+func dialRealtime(t *testing.T, server *httptest.Server, headers map[string]string) *websocket.Conn {
+	t.Helper()
+	ws, err := dialRealtimePath(server, "/ws", headers)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ws.Close() })
+	return ws
+}
+
+func dialRealtimePath(server *httptest.Server, path string, headers map[string]string) (*websocket.Conn, error) {
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+	cfg, err := websocket.NewConfig(url, server.URL)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		cfg.Header.Set(key, value)
+	}
+	return websocket.DialConfig(cfg)
+}
+
+func TestRealtimeReachesClientsOnAnotherReplica(t *testing.T) {
+	bridge := realtime.NewLocalBridge()
+	cfg := handlers.RouterConfig{ContractMode: middleware.ContractFail}
+	first := testsupport.NewAppWithOptions(cfg, testsupport.Options{Realtime: bridge})
+	second := testsupport.NewAppWithOptions(cfg, testsupport.Options{Realtime: bridge})
+	server := httptest.NewServer(first.Router)
+	defer server.Close()
+
+	ws := dialRealtime(t, server, first.LoginAs(t, "ana@hotel.com", ""))
+	todo := createTodo(t, second.Router, "ana@hotel.com", "Revisar el minibar")
+	rec := second.Do(http.MethodPut, "/todos/"+todo.ID, map[string]bool{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	relayEvents(t, second)
+
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg realtime.Message
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	require.Equal(t, realtime.UserChannel("ana@hotel.com"), msg.Channel)
+	require.Equal(t, events.TodoCompleted, msg.Type)
+	var data todoBody
+	require.NoError(t, json.Unmarshal(msg.Data, &data))
+	require.Equal(t, todo.ID, data.ID)
+}
+
+func TestRealtimeRequiresSessionAndUpgrade(t *testing.T) {
+	app := testsupport.NewApp()
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	_, err := websocket.Dial(url, "", server.URL)
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/ws", nil, nil).Code)
+
+	rec := app.Do(http.MethodGet, "/ws", nil, app.LoginAs(t, "ana@hotel.com", ""))
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "WEBSOCKET_REQUIRED")
+}
+
+func TestRealtimeTicketOpensTheSocketOnAnyReplica(t *testing.T) {
+	bridge := realtime.NewLocalBridge()
+	tickets := &testsupport.MemoryRealtimeTicketRepo{}
+	cfg := handlers.RouterConfig{ContractMode: middleware.ContractFail}
+	first := testsupport.NewAppWithOptions(cfg, testsupport.Options{Realtime: bridge, RealtimeTickets: tickets})
+	second := testsupport.NewAppWithOptions(cfg, testsupport.Options{Realtime: bridge, RealtimeTickets: tickets})
+	server := httptest.NewServer(second.Router)
+	defer server.Close()
+
+	require.Equal(t, http.StatusUnauthorized, first.Do(http.MethodPost, "/ws/ticket", nil, nil).Code)
+	rec := first.Do(http.MethodPost, "/ws/ticket", nil, first.LoginAs(t, "ana@hotel.com", ""))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var issued struct {
+		Ticket    string    `json:"ticket"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &issued)
+	require.NotEmpty(t, issued.Ticket)
+	require.Equal(t, testsupport.FixedTime.Add(services.RealtimeTicketTTL), issued.ExpiresAt)
+
+	// A request that is not a WebSocket does not use the ticket up.
+	rec = second.Do(http.MethodGet, "/ws?ticket="+issued.Ticket, nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	ws, err := dialRealtimePath(server, "/ws?ticket="+issued.Ticket, nil)
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, first.Realtime.Publish(context.Background(), realtime.Message{Channel: realtime.UserChannel("ana@hotel.com"), Type: "hola"}))
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg realtime.Message
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	require.Equal(t, "hola", msg.Type)
+
+	_, err = dialRealtimePath(server, "/ws?ticket="+issued.Ticket, nil)
+	require.Error(t, err, "a ticket is used once")
+	_, err = dialRealtimePath(server, "/ws?ticket=inventado", nil)
+	require.Error(t, err)
+}
+
+func TestRealtimeTicketExpires(t *testing.T) {
+	clock := testsupport.NewClock(testsupport.FixedTime)
+	tickets := services.NewRealtimeTicketService(&testsupport.MemoryRealtimeTicketRepo{}, clock.Now, clock)
+	ticket, _, err := tickets.Issue(context.Background(), "ana@hotel.com")
+	require.NoError(t, err)
+	clock.Advance(services.RealtimeTicketTTL)
+	_, err = tickets.Redeem(context.Background(), ticket)
+	require.ErrorIs(t, err, services.ErrInvalidRealtimeTicket)
+
+	ticket, _, err = tickets.Issue(context.Background(), "ana@hotel.com")
+	require.NoError(t, err)
+	email, err := tickets.Redeem(context.Background(), ticket)
+	require.NoError(t, err)
+	require.Equal(t, "ana@hotel.com", email)
+}
+
+// receiveRealtime reads the next message of ws.
+func receiveRealtime(t *testing.T, ws *websocket.Conn) realtime.Message {
+	t.Helper()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg realtime.Message
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	return msg
+}
+
+func TestListPresence(t *testing.T) {
+	app := testsupport.NewApp()
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	carla := app.LoginAs(t, "carla@hotel.com", "")
+
+	rec := app.Do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		List listBody `json:"list"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	path := "/lists/" + created.List.ID
+	rec = app.Do(http.MethodPost, path+"/members", map[string]string{"email": "beto@hotel.com", "role": "viewer"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	viewers := func(headers map[string]string) []services.ListPresenceResponse {
+		t.Helper()
+		rec := app.Do(http.MethodGet, path+"/presence", nil, headers)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var out struct {
+			Viewers []services.ListPresenceResponse `json:"viewers"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &out)
+		return out.Viewers
+	}
+	heartbeat := map[string]string{"type": "presence.heartbeat", "listId": created.List.ID}
+
+	anaWS := dialRealtime(t, server, ana)
+	require.NoError(t, websocket.JSON.Send(anaWS, heartbeat))
+	require.Eventually(t, func() bool { return len(viewers(ana)) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	betoWS := dialRealtime(t, server, beto)
+	require.NoError(t, websocket.JSON.Send(betoWS, heartbeat))
+	msg := receiveRealtime(t, anaWS)
+	require.Equal(t, realtime.ListChannel(created.List.ID), msg.Channel)
+	require.Equal(t, services.PresenceJoined, msg.Type)
+	require.JSONEq(t, `{"listId":"`+created.List.ID+`","email":"beto@hotel.com","since":"2025-01-01T10:00:00Z"}`, string(msg.Data))
+	// Repeated heartbeats keep the presence without announcing it again.
+	require.NoError(t, websocket.JSON.Send(betoWS, heartbeat))
+	present := viewers(beto)
+	require.Len(t, present, 2)
+	require.Equal(t, "ana@hotel.com", present[0].Email)
+	require.Equal(t, "beto@hotel.com", present[1].Email)
+
+	carlaWS := dialRealtime(t, server, carla)
+	require.NoError(t, websocket.JSON.Send(carlaWS, heartbeat))
+	msg = receiveRealtime(t, carlaWS)
+	require.Equal(t, "error", msg.Type)
+	require.Contains(t, string(msg.Data), "LIST_NOT_FOUND")
+	require.Equal(t, http.StatusNotFound, app.Do(http.MethodGet, path+"/presence", nil, carla).Code)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, path+"/presence", nil, nil).Code)
+
+	// Closing the socket leaves the list.
+	require.NoError(t, betoWS.Close())
+	msg = receiveRealtime(t, anaWS)
+	require.Equal(t, services.PresenceLeft, msg.Type)
+	require.Contains(t, string(msg.Data), "beto@hotel.com")
+	require.Len(t, viewers(ana), 1)
+
+	// Without heartbeats the presence expires.
+	app.Clock.Advance(services.PresenceTTL)
+	require.Empty(t, viewers(ana))
+}
+
+// sseEvent is a server-sent event of the activity stream.
+type sseEvent struct {
+	ID, Event string
+	Data      services.ActivityEntry
+}
+
+// openActivity opens the activity stream of server with headers.
+func openActivity(t *testing.T, server *httptest.Server, path string, headers map[string]string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = res.Body.Close() })
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	return bufio.NewReader(res.Body)
+}
+
+// receiveActivity reads the next event of stream, skipping the comments.
+func receiveActivity(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+	read := make(chan sseEvent, 1)
+	go func() {
+		var event sseEvent
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				close(read)
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && event.ID != "":
+				read <- event
+				return
+			case strings.HasPrefix(line, "id: "):
+				event.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data)
+			}
+		}
+	}()
+	select {
+	case event, ok := <-read:
+		require.True(t, ok, "the stream ended")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no activity within 5s")
+		return sseEvent{}
+	}
+}
+
+func TestActivityStream(t *testing.T) {
+	app := testsupport.NewApp()
+	server := httptest.NewServer(app.Router)
+	// Closed after the streams, which would otherwise hold it open.
+	t.Cleanup(server.Close)
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	complete := func(email, title string) todoBody {
+		t.Helper()
+		todo := createTodo(t, app.Router, email, title)
+		rec := app.Do(http.MethodPut, "/todos/"+todo.ID, map[string]bool{"completed": true}, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		relayEvents(t, app)
+		return todo
+	}
+	complete("ana@hotel.com", "Antes de conectarse")
+
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/activity/stream", nil, nil).Code)
+	stream := openActivity(t, server, "/activity/stream", ana)
+	complete("beto@hotel.com", "Ajena")
+	first := complete("ana@hotel.com", "Revisar minibar")
+	second := complete("ana@hotel.com", "Cambiar sabanas")
+
+	event := receiveActivity(t, stream)
+	require.Equal(t, events.TodoCompleted, event.Event)
+	require.Equal(t, event.ID, event.Data.ID.Hex())
+	require.Equal(t, first.ID, event.Data.Key, "the stream starts with the next entry, for its user only")
+	var data todoBody
+	require.NoError(t, json.Unmarshal(event.Data.Data, &data))
+	require.Equal(t, "Revisar minibar", data.Title)
+	require.Equal(t, second.ID, receiveActivity(t, stream).Data.Key)
+
+	// A reconnecting client resumes after the last entry it got, with a
+	// ticket when it has no session.
+	resumed := openActivity(t, server, "/activity/stream", map[string]string{"Authorization": ana["Authorization"], "Last-Event-ID": event.ID})
+	require.Equal(t, second.ID, receiveActivity(t, resumed).Data.Key)
+	rec := app.Do(http.MethodPost, "/ws/ticket", nil, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var issued struct {
+		Ticket string `json:"ticket"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &issued)
+	resumed = openActivity(t, server, "/activity/stream?ticket="+issued.Ticket+"&after="+event.ID, nil)
+	require.Equal(t, second.ID, receiveActivity(t, resumed).Data.Key)
+
+	// The feed keeps only the most recent entries.
+	for i := 0; i < testsupport.ActivityMaxEvents; i++ {
+		complete("ana@hotel.com", "Tarea")
+	}
+	require.Equal(t, testsupport.ActivityMaxEvents, app.Activity.Len())
+}
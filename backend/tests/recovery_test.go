@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/alerts"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type channelNotifier chan alerts.Alert
+
+func (n channelNotifier) Notify(_ context.Context, alert alerts.Alert) error {
+	n <- alert
+	return nil
+}
+
+func TestPanicsReturnStandardErrorAndAlert(t *testing.T) {
+	notifier := make(channelNotifier, 1)
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{Alerts: notifier, RequestTimeout: time.Second})
+	app.Router.GET("/_panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	captureLog(t)
+
+	rec := app.Do(http.MethodGet, "/_panic", nil, map[string]string{middleware.RequestIDHeader: "req-123"})
+
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, "req-123", rec.Header().Get(middleware.RequestIDHeader))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "INTERNAL_ERROR", body["code"])
+	require.NotEmpty(t, body["error"])
+
+	select {
+	case alert := <-notifier:
+		require.Equal(t, "req-123", alert.RequestID)
+		require.Contains(t, alert.Message, "boom")
+	case <-time.After(time.Second):
+		t.Fatal("no se recibio la alerta")
+	}
+}
+
+func TestRequestIDIsGeneratedWhenMissing(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/healthz", nil, nil)
+
+	require.Len(t, rec.Header().Get(middleware.RequestIDHeader), 32)
+}
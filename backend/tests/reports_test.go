@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type reportPeriod struct {
+	Period          string  `json:"period"`
+	Start           string  `json:"start"`
+	End             string  `json:"end"`
+	AvailableNights int     `json:"availableNights"`
+	SoldNights      int     `json:"soldNights"`
+	Occupancy       float64 `json:"occupancy"`
+	Revenue         float64 `json:"revenue"`
+	ADR             float64 `json:"adr"`
+	RevPAR          float64 `json:"revpar"`
+}
+
+// newReportsApp seeds two rooms with two stays and a cancelled booking.
+func newReportsApp(t *testing.T) *testsupport.App {
+	t.Helper()
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{ContractMode: middleware.ContractFail, AdminToken: testsupport.AdminToken})
+	single := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	suite := createRoom(t, app, map[string]interface{}{"number": "201", "type": "suite", "capacity": 2, "price": 200})
+
+	createBooking(t, app, single.ID, "2025-02-10", "2025-02-12")
+	createBooking(t, app, suite.ID, "2025-02-11", "2025-02-14")
+	cancelled := createBooking(t, app, single.ID, "2025-02-12", "2025-02-13")
+	rec := app.Do(http.MethodPost, "/bookings/"+cancelled.ID+"/cancel", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	return app
+}
+
+func getReport(t *testing.T, app *testsupport.App, path string) []reportPeriod {
+	t.Helper()
+	rec := app.Do(http.MethodGet, path, nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body struct {
+		Periods []reportPeriod `json:"periods"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Periods
+}
+
+func TestOccupancyReportByDay(t *testing.T) {
+	app := newReportsApp(t)
+
+	periods := getReport(t, app, "/reports/occupancy?from=2025-02-10&to=2025-02-13")
+	require.Len(t, periods, 3)
+	require.Equal(t, reportPeriod{Period: "2025-02-10", Start: "2025-02-10", End: "2025-02-11", AvailableNights: 2, SoldNights: 1, Occupancy: 50}, periods[0])
+	require.Equal(t, 100.0, periods[1].Occupancy)
+	require.Equal(t, 1, periods[2].SoldNights, "cancelled bookings are not sold")
+}
+
+func TestRevenueReportByWeekAndMonth(t *testing.T) {
+	app := newReportsApp(t)
+
+	weeks := getReport(t, app, "/reports/revenue?from=2025-02-10&to=2025-02-24&groupBy=week")
+	require.Len(t, weeks, 2)
+	require.Equal(t, "2025-W07", weeks[0].Period)
+	require.Equal(t, "2025-02-17", weeks[0].End)
+	require.Equal(t, 5, weeks[0].SoldNights)
+	require.Equal(t, 800.0, weeks[0].Revenue)
+	require.Equal(t, 160.0, weeks[0].ADR)
+	require.Equal(t, 57.14, weeks[0].RevPAR)
+	require.Zero(t, weeks[1].Revenue)
+
+	months := getReport(t, app, "/reports/revenue?from=2025-01-15&to=2025-03-01&groupBy=month")
+	require.Len(t, months, 2)
+	require.Equal(t, "2025-01", months[0].Period)
+	require.Equal(t, "2025-02-01", months[0].End)
+	require.Equal(t, 800.0, months[1].Revenue)
+}
+
+func TestReportsExportCSV(t *testing.T) {
+	app := newReportsApp(t)
+
+	headers := map[string]string{middleware.AdminTokenHeader: testsupport.AdminToken, "Accept": "text/csv"}
+	rec := app.Do(http.MethodGet, "/reports/revenue?from=2025-02-10&to=2025-02-12", nil, headers)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/csv")
+	require.Contains(t, rec.Header().Get("Content-Disposition"), "revenue.csv")
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Equal(t, []string{
+		"period,start,end,soldNights,revenue,adr,revpar",
+		"2025-02-10,2025-02-10,2025-02-11,1,100.00,100.00,50.00",
+		"2025-02-11,2025-02-11,2025-02-12,2,300.00,150.00,150.00",
+	}, lines)
+
+	rec = app.Do(http.MethodGet, "/reports/occupancy?from=2025-02-10&to=2025-02-11&format=csv", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, strings.HasPrefix(rec.Body.String(), "period,start,end,availableNights,soldNights,occupancy\n"))
+}
+
+func TestReportsRequireAdminAndValidRange(t *testing.T) {
+	app := newReportsApp(t)
+
+	rec := app.Do(http.MethodGet, "/reports/occupancy?from=2025-02-10&to=2025-02-13", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	for _, query := range []string{
+		"from=2025-02-13&to=2025-02-10",
+		"from=2025-02-10",
+		"from=2025-02-10&to=2025-02-13&groupBy=year",
+		"from=2020-01-01&to=2025-01-01",
+	} {
+		rec = app.Do(http.MethodGet, "/reports/occupancy?"+query, nil, adminHeaders)
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+		require.Contains(t, rec.Body.String(), "INVALID_REPORT_QUERY")
+	}
+}
+
+func TestWeeklyReportPDF(t *testing.T) {
+	app := newChainApp()
+	ana := createTodo(t, app.Router, "ana@hotel.com", "Revisar minibar")
+	createTodo(t, app.Router, "ana@hotel.com", "Cambiar toallas")
+	createTodo(t, app.Router, "bruno@hotel.com", "Reponer cafe")
+	app.Clock.Advance(24 * time.Hour)
+	rec := app.Do(http.MethodPut, "/todos/"+ana.ID, map[string]interface{}{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	// The imported towels are overdue since June.
+	rec = importTodos(app, "source=google", []byte(googleTasksExport), app.LoginAs(t, "ana@hotel.com", ""))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = app.Do(http.MethodGet, "/reports/weekly.pdf", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Header().Get("Content-Disposition"), `weekly-2024-12-27.pdf`)
+	body := rec.Body.String()
+	require.True(t, strings.HasPrefix(body, "%PDF-1.4\n"))
+	require.True(t, strings.HasSuffix(body, "%%EOF\n"))
+	require.Contains(t, body, "(Resumen semanal de tareas) Tj")
+	require.Contains(t, body, "(2024-12-27 - 2025-01-03) Tj")
+	for _, count := range []string{"(5) Tj", "(1) Tj", "(3) Tj"} {
+		require.Contains(t, body, count)
+	}
+	require.Contains(t, body, "(ana@hotel.com) Tj")
+	require.Contains(t, body, "(bruno@hotel.com) Tj")
+
+	rec = app.Do(http.MethodGet, "/reports/weekly.pdf?from=2025-01-01&to=2025-01-02", nil,
+		map[string]string{middleware.AdminTokenHeader: testsupport.AdminToken, "Accept-Language": "en"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "(Weekly todo summary) Tj")
+	require.Contains(t, rec.Header().Get("Content-Disposition"), `weekly-2025-01-01.pdf`)
+
+	// A property only sees the todos of its rooms.
+	centro := createProperty(t, app, "CENTRO", "Hotel Centro")
+	rec = app.Do(http.MethodGet, "/reports/weekly.pdf", nil, withProperty(adminHeaders, centro))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotContains(t, rec.Body.String(), "ana@hotel.com")
+
+	for _, query := range []string{"from=2025-01-10&to=2025-01-01", "from=ayer", "from=2024-01-01&to=2025-01-01"} {
+		rec = app.Do(http.MethodGet, "/reports/weekly.pdf?"+query, nil, adminHeaders)
+		require.Equal(t, http.StatusBadRequest, rec.Code, query)
+		require.Contains(t, rec.Body.String(), "INVALID_SUMMARY_RANGE")
+	}
+
+	rec = app.Do(http.MethodGet, "/reports/weekly.pdf", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = app.Do(http.MethodGet, "/reports/weekly.pdf", nil, app.LoginAs(t, "carla@hotel.com", ""))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
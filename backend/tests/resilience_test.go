@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+var errNetwork = mongo.CommandError{Message: "connection reset", Labels: []string{"NetworkError"}}
+
+// flakyTodoRepo fails the first `failures` List calls with a network error.
+type flakyTodoRepo struct {
+	*testsupport.MemoryTodoRepo
+	failures int32
+	calls    atomic.Int32
+}
+
+func (f *flakyTodoRepo) List(ctx context.Context, query services.TodoQuery) ([]services.Todo, error) {
+	if f.calls.Add(1) <= f.failures {
+		return nil, errNetwork
+	}
+	return f.MemoryTodoRepo.List(ctx, query)
+}
+
+func newResilientRouter(repo services.TodoRepository, policy services.ResiliencePolicy) *gin.Engine {
+	return testsupport.NewAppWithTodos(handlers.RouterConfig{}, services.NewResilientTodoRepository(repo, policy)).Router
+}
+
+func TestTransientErrorsAreRetried(t *testing.T) {
+	repo := &flakyTodoRepo{MemoryTodoRepo: testsupport.NewMemoryTodoRepo(), failures: 2}
+	router := newResilientRouter(repo, services.ResiliencePolicy{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos?email=a@b.com", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.EqualValues(t, 3, repo.calls.Load())
+}
+
+func TestCircuitBreakerFailsFastWhenDatabaseIsDown(t *testing.T) {
+	repo := &flakyTodoRepo{MemoryTodoRepo: testsupport.NewMemoryTodoRepo(), failures: 1000}
+	now := testsupport.FixedTime
+	breaker := services.NewCircuitBreaker(2, time.Minute, func() time.Time { return now })
+	router := newResilientRouter(repo, services.ResiliencePolicy{MaxAttempts: 1, Breaker: breaker})
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos?email=a@b.com", nil))
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/todos?email=a@b.com", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("Retry-After"))
+	require.EqualValues(t, 2, repo.calls.Load())
+
+	// after the cool-down a probe reaches the database again
+	now = now.Add(2 * time.Minute)
+	repo.failures = 0
+	probeRec := httptest.NewRecorder()
+	router.ServeHTTP(probeRec, httptest.NewRequest(http.MethodGet, "/todos?email=a@b.com", nil))
+	require.Equal(t, http.StatusOK, probeRec.Code)
+}
+
+func TestCircuitBreakerLetsOneProbeThrough(t *testing.T) {
+	now := testsupport.FixedTime
+	breaker := services.NewCircuitBreaker(1, time.Minute, func() time.Time { return now })
+
+	// A call started while the breaker was closed is still running when
+	// another one opens it.
+	ok, straggler := breaker.Allow()
+	require.True(t, ok)
+	require.False(t, straggler)
+	ok, probe := breaker.Allow()
+	require.True(t, ok)
+	breaker.Record(true, probe)
+	ok, _ = breaker.Allow()
+	require.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	ok, probe = breaker.Allow()
+	require.True(t, ok)
+	require.True(t, probe)
+	// The straggler is abandoned by its caller: the probe stays the only one.
+	breaker.Release(straggler)
+	ok, _ = breaker.Allow()
+	require.False(t, ok)
+
+	// Once the probe is abandoned too, the next call probes.
+	breaker.Release(probe)
+	ok, probe = breaker.Allow()
+	require.True(t, ok)
+	require.True(t, probe)
+	breaker.Record(false, probe)
+	ok, probe = breaker.Allow()
+	require.True(t, ok)
+	require.False(t, probe)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// completeStay books the room from the fixed "today", checks the guest in and out.
+func completeStay(t *testing.T, app *testsupport.App, roomID string) bookingBody {
+	t.Helper()
+	booking := createBooking(t, app, roomID, "2025-01-01", "2025-01-02")
+	for _, step := range []string{"check-in", "check-out"} {
+		rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/"+step, nil, app.StaffHeaders(t))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+	return booking
+}
+
+func roomRating(t *testing.T, app *testsupport.App, roomID string) (float64, int) {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/rooms/"+roomID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Room struct {
+			Rating struct {
+				Average float64 `json:"average"`
+				Count   int     `json:"count"`
+			} `json:"rating"`
+		} `json:"room"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Room.Rating.Average, body.Room.Rating.Count
+}
+
+func TestReviewRequiresCheckOutAndIsUnique(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	pending := createBooking(t, app, room.ID, "2025-02-10", "2025-02-12")
+
+	rec := app.Do(http.MethodPost, "/bookings/"+pending.ID+"/review", map[string]interface{}{"rating": 5}, nil)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "REVIEW_NOT_ALLOWED")
+
+	booking := completeStay(t, app, room.ID)
+	for _, rating := range []int{0, 6} {
+		rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/review", map[string]interface{}{"rating": rating}, nil)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), "INVALID_REVIEW_INPUT")
+	}
+
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/review", map[string]interface{}{"rating": 4, "comment": "  Muy comodo  "}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), `"comment":"Muy comodo"`)
+
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/review", map[string]interface{}{"rating": 5}, nil)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "REVIEW_ALREADY_EXISTS")
+}
+
+func TestRoomReviewsArePaginatedAndAveraged(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+
+	average, count := roomRating(t, app, room.ID)
+	require.Zero(t, average)
+	require.Zero(t, count)
+
+	for _, rating := range []int{5, 4, 4} {
+		booking := completeStay(t, app, room.ID)
+		rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/review", map[string]interface{}{"rating": rating}, nil)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	}
+
+	// Creating a review invalidates the cached rating of the room.
+	average, count = roomRating(t, app, room.ID)
+	require.Equal(t, 4.3, average)
+	require.Equal(t, 3, count)
+
+	calls := app.Reviews.RatingCalls()
+	roomRating(t, app, room.ID)
+	require.Equal(t, calls, app.Reviews.RatingCalls(), "rating should be served from the cache")
+
+	rec := app.Do(http.MethodGet, "/rooms/"+room.ID+"/reviews?limit=2", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var page struct {
+		Reviews []struct {
+			Rating int `json:"rating"`
+		} `json:"reviews"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &page)
+	require.Equal(t, int64(3), testsupport.DecodeMeta(t, rec.Body.Bytes()).Pagination.Total)
+	require.Len(t, page.Reviews, 2)
+	require.Equal(t, 4, page.Reviews[0].Rating)
+	require.Contains(t, rec.Header().Get("Link"), `rel="next"`)
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestStaffRoutesRequireAuthentication(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/bookings", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), "AUTH_REQUIRED")
+
+	rec = app.Do(http.MethodGet, "/bookings", nil, map[string]string{"Authorization": "Bearer nope"})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_SESSION")
+
+	// Public endpoints keep working anonymously.
+	rec = app.Do(http.MethodGet, "/rooms", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRolesLimitStaffRoutes(t *testing.T) {
+	app := testsupport.NewApp()
+	frontDesk := app.LoginAs(t, "recepcion@hotel.com", services.RoleFrontDesk)
+	housekeeper := app.LoginAs(t, "limpieza@hotel.com", services.RoleHousekeeping)
+	regular := app.LoginAs(t, "cliente@example.com", "")
+
+	room := map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100}
+	rec := app.Do(http.MethodPost, "/rooms", room, frontDesk)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "ROLE_FORBIDDEN")
+
+	created := createRoom(t, app, room)
+	booking := map[string]interface{}{
+		"roomId": created.ID, "email": "guest@example.com", "guests": 1,
+		"checkIn": "2025-01-01", "checkOut": "2025-01-03",
+	}
+	rec = app.Do(http.MethodPost, "/bookings", booking, housekeeper)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = app.Do(http.MethodPost, "/bookings", booking, regular)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = app.Do(http.MethodPost, "/bookings", booking, frontDesk)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = app.Do(http.MethodGet, "/reports/occupancy?from=2025-01-01&to=2025-01-02", nil, frontDesk)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = app.Do(http.MethodGet, "/rooms/"+created.ID+"/todos", nil, housekeeper)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHousekeepingOnlySeesRoomTodos(t *testing.T) {
+	app := testsupport.NewApp()
+	housekeeper := app.LoginAs(t, "limpieza@hotel.com", services.RoleHousekeeping)
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": testsupport.Housekeepers[0], "title": "Personal"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	completeStay(t, app, room.ID)
+
+	type todoList struct {
+		Todos []struct {
+			RoomID string `json:"roomId"`
+		} `json:"todos"`
+	}
+	var regular todoList
+	rec = app.Do(http.MethodGet, "/todos?email="+testsupport.Housekeepers[0], nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &regular)
+	require.Len(t, regular.Todos, 2)
+
+	var housekeeping todoList
+	rec = app.Do(http.MethodGet, "/todos?email="+testsupport.Housekeepers[0], nil, housekeeper)
+	require.Equal(t, http.StatusOK, rec.Code)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &housekeeping)
+	require.Len(t, housekeeping.Todos, 1)
+	require.Equal(t, room.ID, housekeeping.Todos[0].RoomID)
+}
+
+func TestAdminAssignsRoles(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{AdminToken: testsupport.AdminToken})
+	user := app.LoginAs(t, "nuevo@hotel.com", "")
+
+	rec := app.Do(http.MethodGet, "/guests", nil, user)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = app.Do(http.MethodPut, "/admin/users/nuevo@hotel.com/role", map[string]string{"role": "chef"}, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_ROLE")
+	rec = app.Do(http.MethodPut, "/admin/users/nadie@hotel.com/role", map[string]string{"role": "manager"}, adminHeaders)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = app.Do(http.MethodPut, "/admin/users/nuevo@hotel.com/role", map[string]string{"role": "front_desk"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = app.Do(http.MethodPut, "/admin/users/nuevo@hotel.com/role", map[string]string{"role": " Front_Desk "}, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		User struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		} `json:"user"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Equal(t, services.RoleFrontDesk, body.User.Role)
+
+	// The open session picks up the new role.
+	rec = app.Do(http.MethodGet, "/guests", nil, user)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// The admin token keeps access to every staff endpoint.
+	rec = app.Do(http.MethodGet, "/bookings", nil, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRegisterCannotChooseRole(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/register", map[string]string{"email": "a@b.com", "password": "x", "role": "manager"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	user, err := app.Users.FindByEmail(context.Background(), "a@b.com")
+	require.NoError(t, err)
+	require.Empty(t, user.Role)
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type roomBody struct {
+	ID         string   `json:"id"`
+	PropertyID string   `json:"propertyId"`
+	Number     string   `json:"number"`
+	Type       string   `json:"type"`
+	Capacity   int      `json:"capacity"`
+	Price      float64  `json:"price"`
+	Amenities  []string `json:"amenities"`
+	Status     string   `json:"status"`
+}
+
+func createRoom(t *testing.T, app *testsupport.App, payload map[string]interface{}) roomBody {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/rooms", payload, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var body struct {
+		Room roomBody `json:"room"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Room
+}
+
+func TestCreateRoomDefaultsAndNormalizes(t *testing.T) {
+	app := testsupport.NewApp()
+
+	room := createRoom(t, app, map[string]interface{}{
+		"number":    " 101 ",
+		"type":      "Double",
+		"capacity":  2,
+		"price":     120.5,
+		"amenities": []string{"WiFi", " tv", "wifi"},
+	})
+
+	require.NotEmpty(t, room.ID)
+	require.Equal(t, "101", room.Number)
+	require.Equal(t, "double", room.Type)
+	require.Equal(t, "available", room.Status)
+	require.Equal(t, []string{"wifi", "tv"}, room.Amenities)
+
+	rec := app.Do(http.MethodGet, "/rooms/"+room.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCreateRoomValidation(t *testing.T) {
+	app := testsupport.NewApp()
+
+	cases := []map[string]interface{}{
+		{"type": "single", "capacity": 1, "price": 50},
+		{"number": "1", "type": "penthouse", "capacity": 1, "price": 50},
+		{"number": "1", "type": "single", "capacity": 0, "price": 50},
+		{"number": "1", "type": "single", "capacity": 1, "price": -1},
+		{"number": "1", "type": "single", "capacity": 1, "price": 50, "status": "closed"},
+	}
+	for _, payload := range cases {
+		rec := app.Do(http.MethodPost, "/rooms", payload, app.StaffHeaders(t))
+		require.Equal(t, http.StatusBadRequest, rec.Code, payload)
+		require.Contains(t, rec.Body.String(), "INVALID_ROOM_INPUT")
+	}
+}
+
+func TestRoomNumberIsUnique(t *testing.T) {
+	app := testsupport.NewApp()
+	createRoom(t, app, map[string]interface{}{"number": "201", "type": "single", "capacity": 1, "price": 60})
+	other := createRoom(t, app, map[string]interface{}{"number": "202", "type": "single", "capacity": 1, "price": 60})
+
+	rec := app.Do(http.MethodPost, "/rooms", map[string]interface{}{
+		"number": "201", "type": "suite", "capacity": 4, "price": 300,
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "ROOM_NUMBER_TAKEN")
+
+	rec = app.Do(http.MethodPut, "/rooms/"+other.ID, map[string]interface{}{"number": "201"}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestListRoomsFiltersByTypeAndStatus(t *testing.T) {
+	app := testsupport.NewApp()
+	createRoom(t, app, map[string]interface{}{"number": "103", "type": "suite", "capacity": 4, "price": 300})
+	createRoom(t, app, map[string]interface{}{"number": "101", "type": "single", "capacity": 1, "price": 60})
+	createRoom(t, app, map[string]interface{}{"number": "102", "type": "single", "capacity": 1, "price": 60, "status": "cleaning"})
+
+	var body struct {
+		Rooms []roomBody `json:"rooms"`
+	}
+
+	rec := app.Do(http.MethodGet, "/rooms", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Rooms, 3)
+	require.Equal(t, "101", body.Rooms[0].Number)
+
+	rec = app.Do(http.MethodGet, "/rooms?type=single&status=available", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Len(t, body.Rooms, 1)
+	require.Equal(t, "101", body.Rooms[0].Number)
+
+	rec = app.Do(http.MethodGet, "/rooms?status=closed", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_ROOM_FILTER")
+}
+
+func TestUpdateAndDeleteRoom(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "301", "type": "twin", "capacity": 2, "price": 90})
+
+	rec := app.Do(http.MethodPut, "/rooms/"+room.ID, map[string]interface{}{
+		"status": "maintenance",
+		"price":  95,
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var updated struct {
+		Room roomBody `json:"room"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &updated)
+	require.Equal(t, "maintenance", updated.Room.Status)
+	require.Equal(t, 95.0, updated.Room.Price)
+	require.Equal(t, "twin", updated.Room.Type)
+
+	rec = app.Do(http.MethodPut, "/rooms/"+room.ID, map[string]interface{}{}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = app.Do(http.MethodDelete, "/rooms/"+room.ID, nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "ROOM_DELETED")
+
+	rec = app.Do(http.MethodGet, "/rooms/"+room.ID, nil, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = app.Do(http.MethodDelete, "/rooms/not-an-id", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestUnknownRouteReturnsJSONError(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodGet, "/does-not-exist", nil, nil)
+
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "ROUTE_NOT_FOUND", body["code"])
+}
+
+func TestWrongMethodReturns405WithAllowHeader(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPatch, "/todos", nil, nil)
+
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	allowed := strings.Split(rec.Header().Get("Allow"), ", ")
+	require.ElementsMatch(t, []string{http.MethodGet, http.MethodPost, http.MethodDelete}, allowed)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "METHOD_NOT_ALLOWED", body["code"])
+}
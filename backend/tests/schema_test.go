@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// bsonTypeAliases names the BSON types the way $jsonSchema does.
+var bsonTypeAliases = map[bsontype.Type]string{
+	bsontype.String:           "string",
+	bsontype.Boolean:          "bool",
+	bsontype.DateTime:         "date",
+	bsontype.Null:             "null",
+	bsontype.ObjectID:         "objectId",
+	bsontype.Int32:            "int",
+	bsontype.Int64:            "long",
+	bsontype.Double:           "double",
+	bsontype.Array:            "array",
+	bsontype.EmbeddedDocument: "object",
+}
+
+// schemaViolations checks the document doc encodes to against the part of
+// $jsonSchema the collection schemas use, so they do not drift from what
+// the repositories write.
+func schemaViolations(t *testing.T, schema bson.M, doc any) []string {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	require.NoError(t, err)
+	var violations []string
+	for _, field := range schema["required"].(bson.A) {
+		if _, err := bson.Raw(raw).LookupErr(field.(string)); err != nil {
+			violations = append(violations, fmt.Sprintf("%s is missing", field))
+		}
+	}
+	elements, err := bson.Raw(raw).Elements()
+	require.NoError(t, err)
+	properties := schema["properties"].(bson.M)
+	for _, element := range elements {
+		rule, ok := properties[element.Key()].(bson.M)
+		if !ok {
+			continue
+		}
+		value := element.Value()
+		alias := bsonTypeAliases[value.Type]
+		switch types := rule["bsonType"].(type) {
+		case string:
+			if alias != types {
+				violations = append(violations, fmt.Sprintf("%s is %s, not %s", element.Key(), alias, types))
+			}
+		case bson.A:
+			if !slices.Contains(types, any(alias)) {
+				violations = append(violations, fmt.Sprintf("%s is %s, not one of %v", element.Key(), alias, types))
+			}
+		}
+		if enum, ok := rule["enum"].(bson.A); ok && !slices.Contains(enum, any(value.StringValue())) {
+			violations = append(violations, fmt.Sprintf("%s is %q", element.Key(), value.StringValue()))
+		}
+		if min, ok := rule["minLength"].(int); ok && value.Type == bsontype.String && len(value.StringValue()) < min {
+			violations = append(violations, fmt.Sprintf("%s is too short", element.Key()))
+		}
+		if min, ok := rule["minimum"].(int); ok && value.Type == bsontype.Int32 && int(value.Int32()) < min {
+			violations = append(violations, fmt.Sprintf("%s is below %d", element.Key(), min))
+		}
+	}
+	return violations
+}
+
+func TestCollectionSchemasAcceptTheStoredDocuments(t *testing.T) {
+	ctx := context.Background()
+	app := testsupport.NewApp()
+	schemas := services.CollectionSchemas()
+	require.Equal(t, schemas["todos"], schemas[services.TodoArchiveCollection])
+
+	app.LoginAs(t, "ana@hotel.com", "")
+	staff := app.StaffHeaders(t)
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Revisar minibar")
+	rec := app.Do(http.MethodPut, "/todos/"+todo.ID, map[string]any{"completed": true, "color": "blue", "icon": "bed", "assignee": "beto@hotel.com"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-02-10", "2025-02-13")
+	rec = app.Do(http.MethodPost, "/bookings/"+booking.ID+"/cancel", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	users, err := app.Users.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	for _, user := range users {
+		require.Empty(t, schemaViolations(t, schemas["users"], user), user.Email)
+	}
+	todos, err := app.Todos.List(ctx, services.TodoQuery{All: true})
+	require.NoError(t, err)
+	require.Len(t, todos, 1)
+	require.Empty(t, schemaViolations(t, schemas["todos"], todos[0]))
+	bookings, err := app.Bookings.List(ctx, services.BookingQuery{})
+	require.NoError(t, err)
+	require.Len(t, bookings, 1)
+	require.Empty(t, schemaViolations(t, schemas["bookings"], bookings[0]))
+
+	broken := todos[0]
+	broken.Title, broken.Color = "", "fucsia"
+	require.ElementsMatch(t, []string{"title is too short", `color is "fucsia"`}, schemaViolations(t, schemas["todos"], broken))
+	require.Contains(t, schemaViolations(t, schemas["bookings"], bson.M{"roomId": bookings[0].RoomID, "guests": 0}), "guests is below 1")
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// scimToken issues the SCIM token of propertyID and returns the headers
+// that authenticate with it.
+func scimToken(t *testing.T, app *testsupport.App, propertyID, defaultRole string) map[string]string {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/admin/properties/"+propertyID+"/scim-token", map[string]string{"defaultRole": defaultRole}, adminHeaders)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var body struct {
+		SCIM services.SCIMToken `json:"scim"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.NotEmpty(t, body.SCIM.Token)
+	return testsupport.Bearer(body.SCIM.Token)
+}
+
+func scimUser(t *testing.T, rec interface{ Bytes() []byte }) services.SCIMUser {
+	t.Helper()
+	var user services.SCIMUser
+	require.NoError(t, json.Unmarshal(rec.Bytes(), &user))
+	return user
+}
+
+func TestSCIMProvisioning(t *testing.T) {
+	app := newChainApp()
+	centro := createProperty(t, app, "CBA-CENTRO", "Hotel Centro")
+	sierras := createProperty(t, app, "CBA-SIERRAS", "Hotel Sierras")
+
+	rec := app.Do(http.MethodPost, "/admin/properties/"+centro+"/scim-token", map[string]string{"defaultRole": "chef"}, adminHeaders)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPost, "/admin/properties/"+centro+"/scim-token", nil, nil).Code)
+	headers := scimToken(t, app, centro, "housekeeping")
+	sierrasHeaders := scimToken(t, app, sierras, "")
+
+	rec = app.Do(http.MethodGet, "/scim/v2/Users", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, "application/scim+json", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), services.SCIMErrorSchema)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/scim/v2/Users", nil, testsupport.Bearer("otro")).Code)
+
+	// Users without roles get the default role of the token.
+	rec = app.Do(http.MethodPost, "/scim/v2/Users", map[string]any{
+		"schemas":  []string{services.SCIMUserSchema},
+		"userName": "Lucia@Hotel.com",
+		"active":   true,
+	}, headers)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	lucia := scimUser(t, rec.Body)
+	require.Equal(t, "lucia@hotel.com", lucia.ID)
+	require.True(t, lucia.Active)
+	require.Equal(t, []services.SCIMValue{{Value: "housekeeping", Primary: true}}, lucia.Roles)
+	require.Equal(t, "/scim/v2/Users/"+url.PathEscape("lucia@hotel.com"), rec.Header().Get("Location"))
+	require.Len(t, app.Outbox.WithKey("lucia@hotel.com"), 1)
+	require.Equal(t, events.UserRegistered, app.Outbox.WithKey("lucia@hotel.com")[0].Event.Type)
+
+	rec = app.Do(http.MethodPost, "/scim/v2/Users", map[string]any{
+		"emails": []map[string]any{{"value": "mario@hotel.com", "primary": true}},
+		"roles":  []map[string]any{{"value": "front_desk"}},
+	}, headers)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Equal(t, "front_desk", scimUser(t, rec.Body).Roles[0].Value)
+
+	rec = app.Do(http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "lucia@hotel.com"}, sierrasHeaders)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), `"scimType":"uniqueness"`)
+	rec = app.Do(http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "ana@hotel.com", "roles": []map[string]any{{"value": "chef"}}}, headers)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodPost, "/scim/v2/Users", map[string]any{"userName": "ana"}, headers).Code)
+
+	// Each token only sees the staff of its property.
+	rec = app.Do(http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(`userName eq "lucia@hotel.com"`), nil, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var list struct {
+		TotalResults int                 `json:"totalResults"`
+		StartIndex   int                 `json:"startIndex"`
+		Resources    []services.SCIMUser `json:"Resources"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, 1, list.TotalResults)
+	require.Equal(t, "lucia@hotel.com", list.Resources[0].UserName)
+
+	rec = app.Do(http.MethodGet, "/scim/v2/Users?startIndex=2&count=1", nil, headers)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, 2, list.TotalResults)
+	require.Equal(t, 2, list.StartIndex)
+	require.Len(t, list.Resources, 1)
+	require.Equal(t, "mario@hotel.com", list.Resources[0].UserName)
+
+	rec = app.Do(http.MethodGet, "/scim/v2/Users", nil, sierrasHeaders)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Zero(t, list.TotalResults)
+	require.Equal(t, http.StatusNotFound, app.Do(http.MethodGet, "/scim/v2/Users/lucia@hotel.com", nil, sierrasHeaders).Code)
+
+	rec = app.Do(http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape(`displayName co "Lu"`), nil, headers)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `"scimType":"invalidFilter"`)
+
+	// Deactivating closes the sessions of the user.
+	session := loginAtProperty(t, app, "ines@hotel.com", "manager", centro)
+	rec = app.Do(http.MethodPatch, "/scim/v2/Users/ines@hotel.com", map[string]any{
+		"schemas":    []string{services.SCIMPatchSchema},
+		"Operations": []map[string]any{{"op": "Replace", "path": "active", "value": "False"}},
+	}, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.False(t, scimUser(t, rec.Body).Active)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/users/me/sessions", nil, session).Code)
+
+	rec = app.Do(http.MethodPatch, "/scim/v2/Users/ines@hotel.com", map[string]any{
+		"Operations": []map[string]any{{"op": "replace", "value": map[string]any{"active": true, "roles": []map[string]any{{"value": "front_desk"}}}}},
+	}, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	ines := scimUser(t, rec.Body)
+	require.True(t, ines.Active)
+	require.Equal(t, "front_desk", ines.Roles[0].Value)
+
+	rec = app.Do(http.MethodPatch, "/scim/v2/Users/ines@hotel.com", map[string]any{
+		"Operations": []map[string]any{{"op": "replace", "path": "active", "value": false}, {"op": "replace", "path": "userName", "value": "x@hotel.com"}},
+	}, headers)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodGet, "/scim/v2/Users/ines@hotel.com", nil, headers)
+	require.True(t, scimUser(t, rec.Body).Active)
+
+	rec = app.Do(http.MethodDelete, "/scim/v2/Users/lucia@hotel.com", nil, headers)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = app.Do(http.MethodGet, "/scim/v2/Users?filter="+url.QueryEscape("active eq false"), nil, headers)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Equal(t, 1, list.TotalResults)
+	require.Equal(t, "lucia@hotel.com", list.Resources[0].UserName)
+
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, "/admin/properties/"+centro+"/scim-token", nil, adminHeaders).Code)
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodGet, "/scim/v2/Users", nil, headers).Code)
+}
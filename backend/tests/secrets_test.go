@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/config"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/secrets"
+)
+
+// unsetenv removes key for the duration of the test.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	require.NoError(t, os.Unsetenv(key))
+}
+
+func TestSecretsAreReadFromFiles(t *testing.T) {
+	unsetenv(t, "MONGO_URI")
+	unsetenv(t, "ADMIN_TOKEN")
+	path := filepath.Join(t.TempDir(), "mongo_uri")
+	require.NoError(t, os.WriteFile(path, []byte("mongodb://app:s3cret@db:27017\n"), 0o600))
+	t.Setenv("MONGO_URI_FILE", path)
+	config.SetSecrets(map[string]string{"ADMIN_TOKEN": "from-vault", "MONGO_URI": "ignored"})
+	t.Cleanup(func() { config.SetSecrets(nil) })
+
+	cfg := config.Load()
+	require.Equal(t, "mongodb://app:s3cret@db:27017", cfg.MongoURI, "the file wins over the provider")
+	require.Equal(t, "from-vault", cfg.AdminToken)
+
+	t.Setenv("ADMIN_TOKEN", "from-env")
+	require.Equal(t, "from-env", config.Load().AdminToken, "the environment wins over the provider")
+}
+
+func TestVaultProviderReadsKVSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/hotel-api" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"MONGO_URI":"mongodb://vault","QUOTA_MAX_TODOS":50},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	provider, err := secrets.Open(secrets.ProviderVault, secrets.Options{VaultAddr: server.URL, VaultToken: "vault-token", Path: "secret/data/hotel-api"})
+	require.NoError(t, err)
+	values, err := provider.Values(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"MONGO_URI": "mongodb://vault", "QUOTA_MAX_TODOS": "50"}, values)
+
+	missing := secrets.NewVault(server.URL, "vault-token", "secret/data/other", nil)
+	_, err = missing.Values(context.Background())
+	require.ErrorIs(t, err, secrets.ErrNotFound)
+}
+
+func TestAWSProviderSignsRequests(t *testing.T) {
+	authorization := regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/\d{8}/us-east-1/secretsmanager/aws4_request, ` +
+		`SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=[0-9a-f]{64}$`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		require.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+		require.Regexp(t, authorization, r.Header.Get("Authorization"))
+		var body struct {
+			SecretID string `json:"SecretId"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "prod/hotel-api", body.SecretID)
+		_, _ = w.Write([]byte(`{"Name":"prod/hotel-api","SecretString":"{\"ADMIN_TOKEN\":\"from-aws\"}"}`))
+	}))
+	defer server.Close()
+
+	provider, err := secrets.Open(secrets.ProviderAWS, secrets.Options{
+		Path:        "prod/hotel-api",
+		AWSRegion:   "us-east-1",
+		AWSEndpoint: server.URL,
+		AWS:         secrets.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"},
+	})
+	require.NoError(t, err)
+	values, err := provider.Values(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ADMIN_TOKEN": "from-aws"}, values)
+}
+
+func TestOpenSecretsProviderValidatesOptions(t *testing.T) {
+	provider, err := secrets.Open("", secrets.Options{})
+	require.NoError(t, err)
+	require.Nil(t, provider)
+
+	_, err = secrets.Open("gcp", secrets.Options{})
+	require.Error(t, err)
+	_, err = secrets.Open(secrets.ProviderVault, secrets.Options{VaultAddr: "http://vault:8200"})
+	require.Error(t, err)
+	_, err = secrets.Open(secrets.ProviderAWS, secrets.Options{AWSRegion: "us-east-1", Path: "prod/hotel-api"})
+	require.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/server"
+)
+
+func TestRedirectHandlerSendsClientsToHTTPS(t *testing.T) {
+	handler := server.RedirectHandler("8443")
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com:8080/todos?email=a@b.com", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusMovedPermanently, rec.Code)
+	require.Equal(t, "https://api.example.com:8443/todos?email=a@b.com", rec.Header().Get("Location"))
+}
+
+func TestRedirectHandlerOmitsDefaultHTTPSPort(t *testing.T) {
+	handler := server.RedirectHandler("443")
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/healthz", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	require.Equal(t, "https://api.example.com/healthz", rec.Header().Get("Location"))
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// loginFrom signs in email from a device identified by userAgent.
+func loginFrom(t *testing.T, app *testsupport.App, email, userAgent string) map[string]string {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/login",
+		map[string]string{"email": email, "password": "secret"}, map[string]string{"User-Agent": userAgent})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body map[string]string
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return map[string]string{"Authorization": "Bearer " + body["token"], "User-Agent": userAgent}
+}
+
+func listSessions(t *testing.T, app *testsupport.App, headers map[string]string) []services.SessionResponse {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/users/me/sessions", nil, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Sessions []services.SessionResponse `json:"sessions"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	return payload.Sessions
+}
+
+func TestListAndRevokeSessions(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	app.Register(t, "beto@example.com")
+	laptop := loginFrom(t, app, "ana@example.com", "Firefox/128.0")
+	phone := loginFrom(t, app, "ana@example.com", "MobileSafari/17.0")
+	beto := loginFrom(t, app, "beto@example.com", "Chrome/126.0")
+
+	rec := app.Do(http.MethodGet, "/users/me/sessions", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), "LOGIN_REQUIRED")
+
+	sessions := listSessions(t, app, laptop)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "MobileSafari/17.0", sessions[0].UserAgent)
+	require.False(t, sessions[0].Current)
+	require.Equal(t, "Firefox/128.0", sessions[1].UserAgent)
+	require.True(t, sessions[1].Current)
+	require.Equal(t, "192.0.2.1", sessions[1].IP)
+	phoneID := sessions[0].ID
+
+	// Another account cannot revoke the session.
+	rec = app.Do(http.MethodDelete, "/users/me/sessions/"+phoneID, nil, beto)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "SESSION_NOT_FOUND")
+	rec = app.Do(http.MethodDelete, "/users/me/sessions/nope", nil, laptop)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = app.Do(http.MethodDelete, "/users/me/sessions/"+phoneID, nil, laptop)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "SESSION_REVOKED")
+	rec = app.Do(http.MethodGet, "/users/me/sessions", nil, phone)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Len(t, listSessions(t, app, laptop), 1)
+}
+
+func TestLoginHistory(t *testing.T) {
+	app := testsupport.NewApp()
+	app.Register(t, "ana@example.com")
+	laptop := loginFrom(t, app, "ana@example.com", "Firefox/128.0")
+	loginFrom(t, app, "ana@example.com", "MobileSafari/17.0")
+	sessions := listSessions(t, app, laptop)
+
+	// Revoked sessions stay in the history.
+	rec := app.Do(http.MethodDelete, "/users/me/sessions/"+sessions[0].ID, nil, laptop)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = app.Do(http.MethodGet, "/users/me/logins", nil, laptop)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var payload struct {
+		Logins []services.LoginResponse `json:"logins"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.Len(t, payload.Logins, 2)
+	require.Equal(t, "MobileSafari/17.0", payload.Logins[0].UserAgent)
+	require.Equal(t, sessions[0].ID, payload.Logins[0].SessionID)
+	require.Equal(t, "Firefox/128.0", payload.Logins[1].UserAgent)
+	require.Equal(t, "192.0.2.1", payload.Logins[1].IP)
+	require.Equal(t, testsupport.FixedTime, payload.Logins[1].At.UTC())
+}
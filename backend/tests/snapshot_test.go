@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+)
+
+// rawCollections is a BackupRepository holding raw documents.
+type rawCollections map[string][]bson.Raw
+
+func (r rawCollections) Export(_ context.Context, collection string, fn func(doc bson.Raw) error) error {
+	for _, doc := range r[collection] {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r rawCollections) Replace(_ context.Context, collection string, docs []bson.Raw) error {
+	r[collection] = docs
+	return nil
+}
+
+func rawDocs(t *testing.T, docs ...bson.M) []bson.Raw {
+	t.Helper()
+	raws := make([]bson.Raw, len(docs))
+	for i, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		require.NoError(t, err)
+		raws[i] = raw
+	}
+	return raws
+}
+
+func TestCopyAnonymizedRemovesPersonalData(t *testing.T) {
+	titles := []string{"Llamar a Ana", "Pagar proveedor", "Revisar minibar", "Cambiar toallas"}
+	todos := make([]bson.M, len(titles))
+	for i, title := range titles {
+		todos[i] = bson.M{"_id": i, "email": "ana@hotel.com", "title": title}
+	}
+	source := rawCollections{
+		"users":         rawDocs(t, bson.M{"_id": 1, "email": "Ana@Hotel.com", "password": "hunter2"}),
+		"todos":         rawDocs(t, todos...),
+		"guests":        rawDocs(t, bson.M{"_id": 1, "name": "Ana Perez", "document": "30111222", "phone": "+54 11 5555 0000", "email": "ana@hotel.com"}),
+		"bookings":      rawDocs(t, bson.M{"_id": 1, "email": "ana@hotel.com", "quote": bson.M{"total": 200}}),
+		"passkeys":      rawDocs(t, bson.M{"_id": 1, "email": "ana@hotel.com", "publicKey": []byte{1, 2}}),
+		"mail_opt_outs": rawDocs(t, bson.M{"_id": "ana@hotel.com"}),
+		"import_runs":   rawDocs(t, bson.M{"_id": 1, "webhookToken": "s3cret", "channel": "booking"}),
+	}
+	target := rawCollections{"passkeys": rawDocs(t, bson.M{"_id": 9})}
+	anonymizer := services.NewAnonymizer([]byte("key"), "qa", 1)
+
+	copied, err := services.CopyAnonymized(context.Background(), source, target, anonymizer)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, copied["todos"])
+
+	alias := anonymizer.Email("ana@hotel.com")
+	require.True(t, strings.HasSuffix(alias, "@anon.test"), alias)
+	dump := func(collection string) string {
+		var out strings.Builder
+		for _, doc := range target[collection] {
+			out.WriteString(doc.String())
+		}
+		return out.String()
+	}
+	for _, collection := range []string{"users", "todos", "guests", "bookings", "mail_opt_outs"} {
+		require.NotContains(t, strings.ToLower(dump(collection)), "ana@hotel.com", collection)
+		require.Contains(t, dump(collection), alias, "the same alias keeps the relations of "+collection)
+	}
+	require.Contains(t, dump("users"), `"password": "qa"`)
+	require.NotContains(t, dump("guests"), "Ana Perez")
+	require.NotContains(t, dump("guests"), "30111222")
+	require.NotContains(t, dump("import_runs"), "s3cret")
+	require.Contains(t, dump("bookings"), `"total": {"$numberInt":"200"}`)
+	require.Len(t, target["passkeys"], 1, "passkeys are not copied")
+
+	var shuffled []string
+	for _, doc := range target["todos"] {
+		shuffled = append(shuffled, doc.Lookup("title").StringValue())
+	}
+	require.ElementsMatch(t, titles, shuffled, "titles are shuffled, not lost")
+	require.NotEqual(t, titles, shuffled)
+}
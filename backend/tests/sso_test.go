@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/oidc"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+// ssoOptions starts an SSO login at propertyID and returns the state and
+// nonce of the authorization URL.
+func ssoOptions(t *testing.T, app *testsupport.App, propertyID string) (string, string) {
+	t.Helper()
+	return startSSO(t, app, "/login/sso/options", propertyID, nil)
+}
+
+// startSSO asks path for the authorization URL of propertyID with headers.
+func startSSO(t *testing.T, app *testsupport.App, path, propertyID string, headers map[string]string) (string, string) {
+	t.Helper()
+	rec := app.Do(http.MethodPost, path, map[string]string{"propertyId": propertyID}, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		AuthorizationURL string `json:"authorizationUrl"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	authorization, err := url.Parse(body.AuthorizationURL)
+	require.NoError(t, err)
+	query := authorization.Query()
+	require.Equal(t, testsupport.SSORedirectURL, query.Get("redirect_uri"))
+	require.Equal(t, "code", query.Get("response_type"))
+	return query.Get("state"), query.Get("nonce")
+}
+
+func TestPropertySSOLogin(t *testing.T) {
+	idp := testsupport.NewIdP()
+	defer idp.Close()
+	app := newChainApp()
+	centro := createProperty(t, app, "CBA-CENTRO", "Hotel Centro")
+	sierras := createProperty(t, app, "CBA-SIERRAS", "Hotel Sierras")
+	path := "/admin/properties/" + centro + "/sso"
+
+	config := map[string]string{"issuer": "ftp://idp", "clientId": idp.ClientID}
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodPut, path, config, adminHeaders).Code)
+	config = map[string]string{"issuer": idp.URL, "clientId": idp.ClientID, "defaultRole": "chef"}
+	require.Equal(t, http.StatusBadRequest, app.Do(http.MethodPut, path, config, adminHeaders).Code)
+	config["defaultRole"] = ""
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPut, path, config, nil).Code)
+
+	config = map[string]string{
+		"issuer":       idp.URL + oidc.DiscoveryPath,
+		"clientId":     idp.ClientID,
+		"clientSecret": idp.ClientSecret,
+		"defaultRole":  "Housekeeping",
+	}
+	rec := app.Do(http.MethodPut, path, config, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotContains(t, rec.Body.String(), idp.ClientSecret)
+	require.Contains(t, rec.Body.String(), `"defaultRole":"housekeeping"`)
+
+	rec = app.Do(http.MethodPost, "/login/sso/options", map[string]string{"propertyId": sierras}, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "SSO_NOT_CONFIGURED")
+
+	// The first login creates the account as staff of the property.
+	state, nonce := ssoOptions(t, app, centro)
+	idp.Grant("code-1", "Lucia@Hotel.com", nonce, nil)
+	rec = app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": "code-1"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var login struct {
+		Token string `json:"token"`
+		Role  string `json:"role"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &login)
+	require.NotEmpty(t, login.Token)
+	require.Equal(t, "housekeeping", login.Role)
+
+	user, err := app.Users.FindByEmail(context.Background(), "lucia@hotel.com")
+	require.NoError(t, err)
+	require.Equal(t, centro, user.PropertyID.Hex())
+	require.Len(t, app.Outbox.WithKey("lucia@hotel.com"), 1)
+	require.Equal(t, events.UserRegistered, app.Outbox.WithKey("lucia@hotel.com")[0].Event.Type)
+
+	// A state is used once.
+	rec = app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": "code-1"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_SSO_STATE")
+
+	// The second login keeps the account as it is.
+	state, nonce = ssoOptions(t, app, centro)
+	idp.Grant("code-2", "lucia@hotel.com", nonce, nil)
+	rec = app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": "code-2"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, app.Outbox.WithKey("lucia@hotel.com"), 1)
+
+	rejected := func(code, email string, extra map[string]any) {
+		t.Helper()
+		state, nonce := ssoOptions(t, app, centro)
+		idp.Grant(code, email, nonce, extra)
+		rec := app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": code}, nil)
+		require.Equal(t, http.StatusUnauthorized, rec.Code, rec.Body.String())
+		require.Contains(t, rec.Body.String(), "SSO_REJECTED")
+	}
+	rejected("replayed", "lucia@hotel.com", map[string]any{"nonce": "otro"})
+	rejected("unverified", "mario@hotel.com", map[string]any{"email_verified": false})
+	rejected("other-client", "mario@hotel.com", map[string]any{"aud": []string{"otra-app"}})
+	rejected("expired", "mario@hotel.com", map[string]any{"exp": testsupport.FixedTime.Add(-time.Hour).Unix()})
+	loginAtProperty(t, app, "sierras@hotel.com", "front_desk", sierras)
+	rejected("other-property", "sierras@hotel.com", nil)
+	// The provider vouches for the email, not for who controls an account
+	// registered with it outside the property.
+	app.LoginAs(t, "cliente@hotel.com", "")
+	rejected("unbound", "cliente@hotel.com", nil)
+	unbound, err := app.Users.FindByEmail(context.Background(), "cliente@hotel.com")
+	require.NoError(t, err)
+	require.Nil(t, unbound.PropertyID)
+
+	state, _ = ssoOptions(t, app, centro)
+	rec = app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": "unknown"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	state, nonce = ssoOptions(t, app, centro)
+	idp.Grant("late", "lucia@hotel.com", nonce, nil)
+	app.Clock.Advance(11 * time.Minute)
+	rec = app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": "late"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Accounts created through SSO have no password.
+	rec = app.Do(http.MethodPost, "/login", map[string]string{"email": "lucia@hotel.com", "password": ""}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	require.Equal(t, http.StatusOK, app.Do(http.MethodDelete, path, nil, adminHeaders).Code)
+	rec = app.Do(http.MethodPost, "/login/sso/options", map[string]string{"propertyId": centro}, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestSSOLinksTheSignedInAccount(t *testing.T) {
+	idp := testsupport.NewIdP()
+	defer idp.Close()
+	app := newChainApp()
+	centro := createProperty(t, app, "CBA-CENTRO", "Hotel Centro")
+	config := map[string]string{"issuer": idp.URL, "clientId": idp.ClientID, "clientSecret": idp.ClientSecret}
+	rec := app.Do(http.MethodPut, "/admin/properties/"+centro+"/sso", config, adminHeaders)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = app.Do(http.MethodPost, "/login/sso/link", map[string]string{"propertyId": centro}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	headers := app.LoginAs(t, "ana@hotel.com", "")
+	login := func(state, code string) int {
+		return app.Do(http.MethodPost, "/login/sso", map[string]string{"state": state, "code": code}, nil).Code
+	}
+
+	// The provider must assert the email of the account that asked.
+	state, nonce := startSSO(t, app, "/login/sso/link", centro, headers)
+	idp.Grant("someone-else", "beto@hotel.com", nonce, nil)
+	require.Equal(t, http.StatusUnauthorized, login(state, "someone-else"))
+	_, err := app.Users.FindByEmail(context.Background(), "beto@hotel.com")
+	require.Error(t, err)
+
+	state, nonce = startSSO(t, app, "/login/sso/link", centro, headers)
+	idp.Grant("link", "Ana@Hotel.com", nonce, nil)
+	require.Equal(t, http.StatusOK, login(state, "link"))
+	user, err := app.Users.FindByEmail(context.Background(), "ana@hotel.com")
+	require.NoError(t, err)
+	require.Equal(t, centro, user.PropertyID.Hex())
+
+	// From then on the plain login through the provider works.
+	state, nonce = ssoOptions(t, app, centro)
+	idp.Grant("again", "ana@hotel.com", nonce, nil)
+	require.Equal(t, http.StatusOK, login(state, "again"))
+}
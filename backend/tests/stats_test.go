@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestStatsBreakdown(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	create := func(body map[string]string) string {
+		t.Helper()
+		rec := app.Do(http.MethodPost, "/todos", body, ana)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		var created struct {
+			Todo struct {
+				ID string `json:"id"`
+			} `json:"todo"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+		return created.Todo.ID
+	}
+	breakdown := func() services.TodoBreakdown {
+		t.Helper()
+		rec := app.Do(http.MethodGet, "/stats/breakdown", nil, ana)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body services.TodoBreakdown
+		testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+		return body
+	}
+
+	rec := app.Do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var list struct {
+		List struct {
+			ID string `json:"id"`
+		} `json:"list"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &list)
+
+	sheets := create(map[string]string{"email": "ana@hotel.com", "title": "Cambiar sabanas", "color": "blue", "icon": "bed"})
+	towels := create(map[string]string{"email": "ana@hotel.com", "title": "Pedir toallas", "color": "blue"})
+	create(map[string]string{"email": "ana@hotel.com", "title": "Revisar minibar", "listId": list.List.ID})
+	createTodo(t, app.Router, "beto@hotel.com", "Reponer cafe")
+	for _, id := range []string{sheets, towels} {
+		app.Clock.Advance(2 * time.Hour)
+		rec = app.Do(http.MethodPut, "/todos/"+id, map[string]interface{}{"completed": true}, nil)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	stats := breakdown()
+	require.Equal(t, []services.StatsGroup{
+		{Key: "blue", Todos: 2, Completed: 2, MedianCompletionSeconds: 3 * 3600},
+		{Key: "bed", Todos: 1, Completed: 1, MedianCompletionSeconds: 2 * 3600},
+	}, stats.Tags)
+	require.Equal(t, []services.StatsGroup{
+		{Key: "", Todos: 2, Completed: 2, MedianCompletionSeconds: 3 * 3600},
+		{Key: list.List.ID, Todos: 1},
+	}, stats.Lists)
+	require.Len(t, stats.Weekdays, 7)
+	require.Equal(t, services.StatsGroup{Key: "monday"}, stats.Weekdays[0])
+	// The clock starts on Wednesday 2025-01-01.
+	require.Equal(t, services.StatsGroup{Key: "wednesday", Todos: 3, Completed: 2, MedianCompletionSeconds: 3 * 3600}, stats.Weekdays[2])
+	require.Equal(t, "sunday", stats.Weekdays[6].Key)
+
+	// The breakdown is cached for a minute.
+	create(map[string]string{"email": "ana@hotel.com", "title": "Llamar al tecnico", "icon": "wrench"})
+	require.Len(t, breakdown().Tags, 2)
+	app.Clock.Advance(2 * time.Minute)
+	require.Len(t, breakdown().Tags, 3)
+
+	rec = app.Do(http.MethodGet, "/stats/breakdown", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+const (
+	opCreate    = "6f1c2a3b-0000-4000-8000-000000000001"
+	opRename    = "6f1c2a3b-0000-4000-8000-000000000002"
+	opCreate2   = "6f1c2a3b-0000-4000-8000-000000000003"
+	opDelete    = "6f1c2a3b-0000-4000-8000-000000000004"
+	opUnknown   = "6f1c2a3b-0000-4000-8000-000000000005"
+	opForeign   = "6f1c2a3b-0000-4000-8000-000000000006"
+	clientTodo  = "0b7e4d52-1111-4aaa-9bbb-000000000001"
+	clientTodo2 = "0b7e4d52-1111-4aaa-9bbb-000000000002"
+)
+
+type syncReport struct {
+	Results []services.SyncOpResult `json:"results"`
+	IDs     map[string]string       `json:"ids"`
+}
+
+func syncOps(t *testing.T, app *testsupport.App, headers map[string]string, ops ...map[string]any) syncReport {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/sync/ops", map[string]any{"ops": ops}, headers)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report syncReport
+	testsupport.DecodeData(t, rec.Body.Bytes(), &report)
+	return report
+}
+
+func TestSyncOpsReplaysOfflineQueue(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	foreign := createTodo(t, app.Router, "beto@hotel.com", "Revisar caldera")
+
+	require.Equal(t, http.StatusUnauthorized, app.Do(http.MethodPost, "/sync/ops", map[string]any{"ops": []any{}}, nil).Code)
+	rec := app.Do(http.MethodPost, "/sync/ops", map[string]any{"ops": []any{}}, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "INVALID_SYNC_BATCH")
+	rec = app.Do(http.MethodPost, "/sync/ops", map[string]any{"ops": []any{map[string]string{"opId": "1", "type": "delete"}}}, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+
+	batch := []map[string]any{
+		{"opId": opCreate, "type": "create", "clientId": clientTodo, "title": "Revisar minibar"},
+		// Later ops name the todo by its client ID before it has a server ID.
+		{"opId": opRename, "type": "update", "todoId": clientTodo, "title": "Revisar minibar y TV", "completed": true},
+		{"opId": opCreate2, "type": "create", "clientId": clientTodo2, "title": "Cambiar sabanas", "color": "blue"},
+		{"opId": opDelete, "type": "delete", "todoId": clientTodo2},
+		{"opId": opUnknown, "type": "move", "todoId": clientTodo},
+		{"opId": opForeign, "type": "update", "todoId": foreign.ID, "completed": true},
+	}
+	report := syncOps(t, app, ana, batch...)
+	require.Len(t, report.Results, 6)
+	require.Len(t, report.IDs, 2)
+	id := report.IDs[clientTodo]
+	want := []services.SyncOpResult{
+		{OpID: opCreate, Status: services.SyncApplied, TodoID: id},
+		{OpID: opRename, Status: services.SyncApplied, TodoID: id},
+		{OpID: opCreate2, Status: services.SyncApplied, TodoID: report.IDs[clientTodo2]},
+		{OpID: opDelete, Status: services.SyncApplied, TodoID: report.IDs[clientTodo2]},
+		{OpID: opUnknown, Status: services.SyncRejected, Reason: "invalid_op"},
+		{OpID: opForeign, Status: services.SyncRejected, Reason: "not_found"},
+	}
+	require.Equal(t, want, report.Results)
+
+	todos := listTodos(t, app.Router, "/todos?email=ana@hotel.com")
+	require.Len(t, todos, 1)
+	require.Equal(t, id, todos[0].ID)
+	require.Equal(t, "Revisar minibar y TV", todos[0].Title)
+	require.True(t, todos[0].Completed)
+	require.False(t, listTodos(t, app.Router, "/todos?email=beto@hotel.com")[0].Completed)
+
+	// Sending the batch again, e.g. after losing the response, applies
+	// nothing twice.
+	replayed := syncOps(t, app, ana, batch...)
+	for i := range want {
+		want[i].Replayed = true
+	}
+	require.Equal(t, want, replayed.Results)
+	require.Equal(t, report.IDs, replayed.IDs)
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com"), 1)
+
+	// A later batch still finds the todo by its client ID, but another user
+	// does not, and a client ID names a single todo.
+	report = syncOps(t, app, ana,
+		map[string]any{"opId": "6f1c2a3b-0000-4000-8000-000000000007", "type": "update", "todoId": clientTodo, "completed": false},
+		map[string]any{"opId": "6f1c2a3b-0000-4000-8000-000000000008", "type": "create", "clientId": clientTodo, "title": "Otra"},
+	)
+	require.Equal(t, services.SyncApplied, report.Results[0].Status)
+	require.Equal(t, id, report.Results[0].TodoID)
+	require.Equal(t, "duplicate_client_id", report.Results[1].Reason)
+	report = syncOps(t, app, beto,
+		map[string]any{"opId": "6f1c2a3b-0000-4000-8000-000000000009", "type": "delete", "todoId": clientTodo},
+	)
+	require.Equal(t, "not_found", report.Results[0].Reason)
+}
+
+// failingUpdateTodoRepo fails the first Update with a network error.
+type failingUpdateTodoRepo struct {
+	*testsupport.MemoryTodoRepo
+	failed atomic.Bool
+}
+
+func (f *failingUpdateTodoRepo) Update(ctx context.Context, id primitive.ObjectID, update services.TodoUpdate) (services.Todo, error) {
+	if f.failed.CompareAndSwap(false, true) {
+		return services.Todo{}, errNetwork
+	}
+	return f.MemoryTodoRepo.Update(ctx, id, update)
+}
+
+func TestSyncOpsKeepTheOrderAfterAServerError(t *testing.T) {
+	repo := &failingUpdateTodoRepo{MemoryTodoRepo: testsupport.NewMemoryTodoRepo()}
+	app := testsupport.NewAppWithOptions(handlers.RouterConfig{}, testsupport.Options{Todos: repo})
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+
+	batch := []map[string]any{
+		{"opId": opCreate, "type": "create", "clientId": clientTodo, "title": "Revisar minibar"},
+		{"opId": opRename, "type": "update", "todoId": clientTodo, "title": "Revisar la TV"},
+		{"opId": opCreate2, "type": "create", "clientId": clientTodo2, "title": "Cambiar sabanas"},
+	}
+	report := syncOps(t, app, ana, batch...)
+	require.Equal(t, services.SyncApplied, report.Results[0].Status)
+	require.Equal(t, services.SyncPending, report.Results[1].Status)
+	require.Equal(t, services.SyncPending, report.Results[2].Status, "an op never applies before the ones ahead of it")
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com"), 1)
+
+	report = syncOps(t, app, ana, batch...)
+	require.True(t, report.Results[0].Replayed)
+	require.Equal(t, services.SyncApplied, report.Results[1].Status)
+	require.False(t, report.Results[1].Replayed)
+	require.Equal(t, services.SyncApplied, report.Results[2].Status)
+	require.Len(t, report.IDs, 2)
+	titles := []string{}
+	for _, todo := range listTodos(t, app.Router, "/todos?email=ana@hotel.com") {
+		titles = append(titles, todo.Title)
+	}
+	require.ElementsMatch(t, []string{"Revisar la TV", "Cambiar sabanas"}, titles)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestRequestTimeoutReturnsGatewayTimeout(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{
+		RequestTimeout: 20 * time.Millisecond,
+		RouteTimeouts:  map[string]time.Duration{"GET /_slow-allowed": time.Second},
+	})
+	slow := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cancelado"})
+		case <-time.After(50 * time.Millisecond):
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		}
+	}
+	app.Router.GET("/_slow", slow)
+	app.Router.GET("/_slow-allowed", slow)
+
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_slow", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	require.Contains(t, rec.Body.String(), "tiempo de espera agotado")
+
+	allowedRec := httptest.NewRecorder()
+	app.Router.ServeHTTP(allowedRec, httptest.NewRequest(http.MethodGet, "/_slow-allowed", nil))
+	require.Equal(t, http.StatusOK, allowedRec.Code)
+}
+
+func TestRequestTimeoutAnswersWithoutWaitingForTheHandler(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{RequestTimeout: 20 * time.Millisecond})
+	release := make(chan struct{})
+	lateWrite := make(chan error, 1)
+	app.Router.GET("/_stuck", func(c *gin.Context) {
+		// Ignores the deadline of its context.
+		<-release
+		_, err := c.Writer.WriteString("tarde")
+		lateWrite <- err
+	})
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+	defer close(release)
+
+	started := time.Now()
+	res, err := http.Get(server.URL + "/_stuck")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Less(t, time.Since(started), time.Second)
+	require.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+	require.Contains(t, string(body), "REQUEST_TIMEOUT")
+
+	release <- struct{}{}
+	require.ErrorIs(t, <-lateWrite, http.ErrHandlerTimeout)
+}
+
+func TestPanicsAfterTheTimeoutKeepThe504(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{RequestTimeout: 20 * time.Millisecond})
+	app.Router.GET("/_late-panic", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		panic("tarde")
+	})
+	captureLog(t)
+
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_late-panic", nil))
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	require.NotContains(t, rec.Body.String(), "INTERNAL_ERROR")
+}
+
+func TestRequestTimeoutPassesFastResponsesThrough(t *testing.T) {
+	app := testsupport.NewAppWithConfig(handlers.RouterConfig{RequestTimeout: time.Second})
+
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status":"ok"}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+	require.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+}
+
+func TestQueryShapeHidesLiteralValues(t *testing.T) {
+	cmd, err := bson.Marshal(bson.D{
+		{Key: "find", Value: "todos"},
+		{Key: "filter", Value: bson.D{{Key: "email", Value: "secret@example.com"}}},
+		{Key: "sort", Value: bson.D{{Key: "createdAt", Value: 1}}},
+	})
+	require.NoError(t, err)
+
+	shape := services.QueryShape("find", cmd)
+	require.Equal(t, `find todos filter={"email":?} sort={"createdAt":?}`, shape)
+	require.NotContains(t, shape, "secret")
+}
+
+func TestPlanSummaryReportsIndexUse(t *testing.T) {
+	plan := func(doc bson.D) bson.Raw {
+		t.Helper()
+		raw, err := bson.Marshal(doc)
+		require.NoError(t, err)
+		return raw
+	}
+
+	summary, indexed := services.PlanSummary(plan(bson.D{
+		{Key: "stage", Value: "LIMIT"},
+		{Key: "inputStage", Value: bson.D{
+			{Key: "stage", Value: "FETCH"},
+			{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "userId_1_completed_1_createdAt_1"}}},
+		}},
+	}))
+	require.True(t, indexed)
+	require.Equal(t, "LIMIT > FETCH > IXSCAN userId_1_completed_1_createdAt_1", summary)
+
+	summary, indexed = services.PlanSummary(plan(bson.D{
+		{Key: "queryPlan", Value: bson.D{
+			{Key: "stage", Value: "SORT"},
+			{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+		}},
+	}))
+	require.False(t, indexed)
+	require.Equal(t, "SORT > COLLSCAN", summary)
+}
@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/handlers"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/middleware"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/respond"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/timing"
+)
+
+// serverTiming parses a Server-Timing header into milliseconds per phase.
+func serverTiming(t testing.TB, header string) map[string]float64 {
+	t.Helper()
+	phases := map[string]float64{}
+	for _, entry := range strings.Split(header, ", ") {
+		parts := strings.Split(entry, ";")
+		for _, param := range parts[1:] {
+			if value, ok := strings.CutPrefix(param, "dur="); ok {
+				dur, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					t.Fatalf("Server-Timing invalido %q: %v", header, err)
+				}
+				phases[parts[0]] = dur
+			}
+		}
+	}
+	return phases
+}
+
+func TestServerTimingHeader(t *testing.T) {
+	todos := services.NewResilientTodoRepository(testsupport.NewMemoryTodoRepo(), services.ResiliencePolicy{})
+	app := testsupport.NewAppWithTodos(handlers.RouterConfig{ContractMode: middleware.ContractFail, ServerTiming: true}, todos)
+	app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@example.com", "title": "Uno"}, nil)
+
+	for _, accept := range []string{"application/json", "application/xml", "application/msgpack"} {
+		rec := app.Do(http.MethodGet, "/todos?email=ana@example.com", nil, map[string]string{"Accept": accept})
+		require.Equal(t, http.StatusOK, rec.Code)
+		header := rec.Header().Get(timing.Header)
+		phases := serverTiming(t, header)
+		require.Contains(t, phases, timing.DB, header)
+		require.Contains(t, phases, timing.Serialize, header)
+		require.GreaterOrEqual(t, phases[timing.App], phases[timing.DB]+phases[timing.Serialize], header)
+	}
+
+	// Listing and counting are two database calls.
+	rec := app.Do(http.MethodGet, "/todos?email=ana@example.com&limit=10", nil, nil)
+	require.Contains(t, rec.Header().Get(timing.Header), `;desc="2"`)
+
+	// Errors and responses without a database call are timed too.
+	rec = app.Do(http.MethodDelete, "/todos/invalid-id", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.NotContains(t, serverTiming(t, rec.Header().Get(timing.Header)), timing.DB)
+	require.Contains(t, serverTiming(t, rec.Header().Get(timing.Header)), timing.App)
+}
+
+func TestServerTimingIsOffByDefault(t *testing.T) {
+	app := testsupport.NewApp()
+	rec := app.Do(http.MethodGet, "/healthz", nil, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get(timing.Header))
+}
+
+func TestRenderFailuresAnswer500WithAndWithoutServerTiming(t *testing.T) {
+	for _, serverTiming := range []bool{false, true} {
+		app := testsupport.NewAppWithConfig(handlers.RouterConfig{ServerTiming: serverTiming})
+		var errs []*gin.Error
+		app.Router.GET("/_unencodable", func(c *gin.Context) {
+			respond.Render(c, http.StatusOK, gin.H{"ratio": math.Inf(1)})
+			errs = c.Errors
+		})
+
+		rec := app.Do(http.MethodGet, "/_unencodable", nil, nil)
+		require.Equal(t, http.StatusInternalServerError, rec.Code, serverTiming)
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body), rec.Body.String())
+		require.Equal(t, "INTERNAL_ERROR", body["code"])
+		require.Equal(t, "error interno del servidor", body["error"])
+		require.Len(t, errs, 1)
+	}
+}
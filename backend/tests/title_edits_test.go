@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/collab"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/realtime"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+func TestCollabTransformConverges(t *testing.T) {
+	base := "Revisar minibar"
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"inserts apart", `["¡", 15]`, `[15, " y TV"]`, "¡Revisar minibar y TV"},
+		{"inserts at the same place", `[7, " el", 8]`, `[7, " ya", 8]`, "Revisar el ya minibar"},
+		{"delete around an insert", `[8, -7]`, `[10, "ni", 5]`, "Revisar ni"},
+		{"same delete", `[-8, 7]`, `[-8, 7]`, "minibar"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var a, b collab.Op
+			require.NoError(t, json.Unmarshal([]byte(tc.a), &a))
+			require.NoError(t, json.Unmarshal([]byte(tc.b), &b))
+			aPrime, bPrime, err := collab.Transform(a, b)
+			require.NoError(t, err)
+
+			afterA, err := a.Apply(base)
+			require.NoError(t, err)
+			ab, err := bPrime.Apply(afterA)
+			require.NoError(t, err)
+			afterB, err := b.Apply(base)
+			require.NoError(t, err)
+			ba, err := aPrime.Apply(afterB)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, ab)
+			require.Equal(t, ab, ba)
+		})
+	}
+
+	var short collab.Op
+	require.NoError(t, json.Unmarshal([]byte(`[3, "x"]`), &short))
+	_, err := short.Apply(base)
+	require.ErrorIs(t, err, collab.ErrInvalidOp)
+	require.Error(t, json.Unmarshal([]byte(`[0]`), &short))
+}
+
+func TestConcurrentTitleEditsMerge(t *testing.T) {
+	app := testsupport.NewApp()
+	server := httptest.NewServer(app.Router)
+	defer server.Close()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	carla := app.LoginAs(t, "carla@hotel.com", "")
+
+	rec := app.Do(http.MethodPost, "/lists", map[string]string{"name": "Piso 3"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var list struct {
+		List listBody `json:"list"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &list)
+	rec = app.Do(http.MethodPost, "/lists/"+list.List.ID+"/members", map[string]string{"email": "beto@hotel.com", "role": "contributor"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"title": "Revisar minibar", "listId": list.List.ID}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Todo todoBody `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	path := "/todos/" + created.Todo.ID + "/title"
+
+	edit := func(headers map[string]string, body string, status int) services.TitleState {
+		t.Helper()
+		rec := app.Do(http.MethodPost, path+"/edits", json.RawMessage(body), headers)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		var state services.TitleState
+		if status == http.StatusOK {
+			testsupport.DecodeData(t, rec.Body.Bytes(), &state)
+		}
+		return state
+	}
+
+	rec = app.Do(http.MethodGet, path, nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var state services.TitleState
+	testsupport.DecodeData(t, rec.Body.Bytes(), &state)
+	require.Equal(t, services.TitleState{TodoID: created.Todo.ID, Title: "Revisar minibar", Version: 0}, state)
+
+	// Beto follows the list while both edit the same version.
+	betoWS := dialRealtime(t, server, beto)
+	require.NoError(t, websocket.JSON.Send(betoWS, map[string]string{"type": "presence.heartbeat", "listId": list.List.ID}))
+	require.Eventually(t, func() bool {
+		rec := app.Do(http.MethodGet, "/lists/"+list.List.ID+"/presence", nil, beto)
+		return strings.Contains(rec.Body.String(), "beto@hotel.com")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	state = edit(ana, `{"baseVersion": 0, "op": [15, " y TV"]}`, http.StatusOK)
+	require.Equal(t, "Revisar minibar y TV", state.Title)
+	require.Equal(t, 1, state.Version)
+	state = edit(beto, `{"baseVersion": 0, "op": ["¡", 15]}`, http.StatusOK)
+	require.Equal(t, "¡Revisar minibar y TV", state.Title)
+	require.Equal(t, 2, state.Version)
+
+	msg := receiveRealtime(t, betoWS)
+	require.Equal(t, realtime.ListChannel(list.List.ID), msg.Channel)
+	require.Equal(t, services.TitleEdited, msg.Type)
+	require.JSONEq(t, `{"todoId":"`+created.Todo.ID+`","version":1,"op":[15," y TV"],"title":"Revisar minibar y TV","author":"ana@hotel.com"}`, string(msg.Data))
+	msg = receiveRealtime(t, betoWS)
+	require.JSONEq(t, `{"todoId":"`+created.Todo.ID+`","version":2,"op":["¡",20],"title":"¡Revisar minibar y TV","author":"beto@hotel.com"}`, string(msg.Data))
+
+	// The last-writer-wins mode replaces the title whatever its version.
+	state = edit(ana, `{"title": "  Revisar la TV  "}`, http.StatusOK)
+	require.Equal(t, "Revisar la TV", state.Title)
+	require.Equal(t, 3, state.Version)
+	// A concurrent edit of the replaced text has nothing left to change.
+	state = edit(beto, `{"baseVersion": 2, "op": [-1, 20]}`, http.StatusOK)
+	require.Equal(t, "Revisar la TV", state.Title)
+	require.Equal(t, 4, state.Version)
+
+	edit(beto, `{"baseVersion": 9, "op": [13, "!"]}`, http.StatusBadRequest)
+	edit(beto, `{"baseVersion": 4, "op": [3, "!"]}`, http.StatusBadRequest)
+	edit(beto, `{"baseVersion": 4, "op": [-13]}`, http.StatusBadRequest)
+	edit(beto, `{"baseVersion": 4, "op": [13], "title": "Otra"}`, http.StatusBadRequest)
+	edit(carla, `{"baseVersion": 4, "op": [13, "!"]}`, http.StatusNotFound)
+	edit(nil, `{"baseVersion": 4, "op": [13, "!"]}`, http.StatusUnauthorized)
+
+	// PUT /todos/:id replaces the title without recording an edit, so the
+	// edits written before it cannot be merged.
+	rec = app.Do(http.MethodPut, "/todos/"+created.Todo.ID, map[string]string{"title": "Cambiar la TV"}, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = app.Do(http.MethodPost, path+"/edits", json.RawMessage(`{"baseVersion": 4, "op": [13, "?"]}`), beto)
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "TITLE_HISTORY_GONE")
+	state = edit(beto, `{"baseVersion": 5, "op": [13, "?"]}`, http.StatusOK)
+	require.Equal(t, "Cambiar la TV?", state.Title)
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+const googleTasksExport = `{
+  "kind": "tasks#taskLists",
+  "items": [{
+    "kind": "tasks#tasks",
+    "title": "Compras",
+    "items": [
+      {"kind": "tasks#task", "title": "Comprar toallas", "status": "needsAction", "due": "2024-06-01T00:00:00.000Z"},
+      {"kind": "tasks#task", "title": "Comprar jabon", "status": "completed"},
+      {"kind": "tasks#task", "title": "Borrada", "status": "needsAction", "deleted": true},
+      {"kind": "tasks#task", "title": "  ", "status": "needsAction"}
+    ]
+  }]
+}`
+
+func importTodos(app *testsupport.App, query string, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/todos/import?"+query, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	app.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportGoogleTasks(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+
+	run := func(query string, status int) services.TodoImportReport {
+		t.Helper()
+		rec := importTodos(app, query, []byte(googleTasksExport), ana)
+		require.Equal(t, status, rec.Code, rec.Body.String())
+		var payload struct {
+			Import services.TodoImportReport `json:"import"`
+		}
+		testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+		return payload.Import
+	}
+
+	// The preview reports what would be imported without storing it.
+	preview := run("source=google&dryRun=true", http.StatusOK)
+	require.True(t, preview.DryRun)
+	require.Equal(t, 0, preview.Imported)
+	require.Equal(t, 2, preview.Skipped)
+	require.Equal(t, []services.TodoImportList{{Name: "Compras", Todos: 2}}, preview.Lists)
+	require.Len(t, preview.Todos, 4)
+	require.Equal(t, services.ImportTodoReady, preview.Todos[0].Status)
+	require.Equal(t, "2024-06-01T00:00:00Z", preview.Todos[0].DueAt.Format("2006-01-02T15:04:05Z07:00"))
+	require.Equal(t, "deleted", preview.Todos[2].Reason)
+	require.Equal(t, "missing_title", preview.Todos[3].Reason)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com"))
+
+	report := run("source=google", http.StatusCreated)
+	require.Equal(t, 2, report.Imported)
+	require.NotEmpty(t, report.Lists[0].ID)
+	require.False(t, report.Lists[0].Exists)
+	require.Equal(t, services.ImportTodoCreated, report.Todos[1].Status)
+
+	rec := app.Do(http.MethodGet, "/todos?email=ana@hotel.com", nil, ana)
+	var listed struct {
+		Todos []services.TodoResponse `json:"todos"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &listed)
+	require.Len(t, listed.Todos, 2)
+	for _, todo := range listed.Todos {
+		require.Equal(t, report.Lists[0].ID, todo.ListID)
+		if todo.Title == "Comprar toallas" {
+			require.NotNil(t, todo.DueAt)
+			require.False(t, todo.Completed)
+		} else {
+			require.True(t, todo.Completed)
+		}
+	}
+
+	// Importing again reuses the list.
+	again := run("source=google&dryRun=true", http.StatusOK)
+	require.True(t, again.Lists[0].Exists)
+	require.Equal(t, report.Lists[0].ID, again.Lists[0].ID)
+}
+
+func TestImportTodoist(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+
+	var backup bytes.Buffer
+	archive := zip.NewWriter(&backup)
+	files := map[string]string{
+		"Inbox [2203306141].csv": "TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+			"task,Llamar al tecnico @phone @urgente,,1,1,Ana,,2024-06-01,es,America/Argentina/Buenos_Aires\n",
+		"Limpieza [2203306142].csv": "TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,AUTHOR,RESPONSIBLE,DATE,DATE_LANG,TIMEZONE\n" +
+			"section,Piso 3,,,,,,,,\n" +
+			"task,Cambiar sabanas @blue @bed,,4,1,Ana,,every week,en,UTC\n" +
+			"note,Usar las blancas,,,,,,,,\n" +
+			"task,Revisar minibar,,4,1,Ana,,el proximo finde,es,UTC\n",
+	}
+	for _, name := range []string{"Inbox [2203306141].csv", "Limpieza [2203306142].csv"} {
+		file, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = file.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+
+	require.Equal(t, http.StatusUnauthorized, importTodos(app, "source=todoist", backup.Bytes(), nil).Code)
+	require.Equal(t, http.StatusBadRequest, importTodos(app, "source=trello", backup.Bytes(), ana).Code)
+	require.Equal(t, http.StatusBadRequest, importTodos(app, "source=todoist", []byte("no es un csv"), ana).Code)
+	require.Equal(t, http.StatusBadRequest, importTodos(app, "source=google", []byte("[]"), ana).Code)
+
+	rec := importTodos(app, "source=todoist", backup.Bytes(), ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var payload struct {
+		Import services.TodoImportReport `json:"import"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	report := payload.Import
+	require.Equal(t, 3, report.Imported)
+	require.Len(t, report.Lists, 1)
+	require.Equal(t, "Limpieza", report.Lists[0].Name)
+
+	inbox := report.Todos[0]
+	require.Empty(t, inbox.List)
+	require.Equal(t, "Llamar al tecnico", inbox.Title)
+	require.Equal(t, "phone", inbox.Icon)
+	require.Equal(t, []string{"label_not_mapped:urgente"}, inbox.Warnings)
+	require.NotNil(t, inbox.DueAt)
+
+	sheets := report.Todos[1]
+	require.Equal(t, "Cambiar sabanas", sheets.Title)
+	require.Equal(t, "blue", sheets.Color)
+	require.Equal(t, "bed", sheets.Icon)
+	require.Equal(t, services.RecurWeekly, sheets.Recurrence)
+	require.Equal(t, []string{"due_not_recognized"}, report.Todos[2].Warnings)
+
+	todos := listTodos(t, app.Router, "/todos?email=ana@hotel.com")
+	require.Len(t, todos, 3)
+
+	// A single project CSV takes its name from the file.
+	csv := "TYPE,CONTENT,DATE\ntask,Pedir toallas,\n"
+	rec = importTodos(app, "source=todoist&name=Limpieza.csv&dryRun=true", []byte(csv), ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	testsupport.DecodeData(t, rec.Body.Bytes(), &payload)
+	require.True(t, payload.Import.Lists[0].Exists)
+
+	// The whole import is refused when it does not fit in the quota.
+	var many bytes.Buffer
+	many.WriteString("TYPE,CONTENT\n")
+	for i := 0; i < testsupport.MaxTodos; i++ {
+		many.WriteString("task,Tarea\n")
+	}
+	require.Equal(t, http.StatusForbidden, importTodos(app, "source=todoist&dryRun=true", many.Bytes(), ana).Code)
+}
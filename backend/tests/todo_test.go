@@ -2,16 +2,22 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/events"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/services"
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
 )
 
 func TestCreateListUpdateDeleteTodoFlow(t *testing.T) {
-	app := newTestApp()
+	app := testsupport.NewApp()
 
 	// register a user to associate todos
 	registerBody, err := json.Marshal(map[string]string{
@@ -23,7 +29,7 @@ func TestCreateListUpdateDeleteTodoFlow(t *testing.T) {
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewReader(registerBody))
 	req.Header.Set("Content-Type", "application/json")
-	app.router.ServeHTTP(rec, req)
+	app.Router.ServeHTTP(rec, req)
 	require.Equal(t, http.StatusCreated, rec.Code)
 
 	// create todo
@@ -36,13 +42,13 @@ func TestCreateListUpdateDeleteTodoFlow(t *testing.T) {
 	createRec := httptest.NewRecorder()
 	createReq := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewReader(createBody))
 	createReq.Header.Set("Content-Type", "application/json")
-	app.router.ServeHTTP(createRec, createReq)
+	app.Router.ServeHTTP(createRec, createReq)
 	require.Equal(t, http.StatusCreated, createRec.Code)
 
 	var createResp struct {
 		Todo map[string]interface{} `json:"todo"`
 	}
-	require.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &createResp))
+	testsupport.DecodeData(t, createRec.Body.Bytes(), &createResp)
 	require.Equal(t, "Primera tarea", createResp.Todo["title"])
 	require.Equal(t, false, createResp.Todo["completed"])
 	todoID, ok := createResp.Todo["id"].(string)
@@ -51,13 +57,13 @@ func TestCreateListUpdateDeleteTodoFlow(t *testing.T) {
 	// list todos filtered by email
 	listRec := httptest.NewRecorder()
 	listReq := httptest.NewRequest(http.MethodGet, "/todos?email=tasks@example.com", nil)
-	app.router.ServeHTTP(listRec, listReq)
+	app.Router.ServeHTTP(listRec, listReq)
 	require.Equal(t, http.StatusOK, listRec.Code)
 
 	var listResp struct {
 		Todos []map[string]interface{} `json:"todos"`
 	}
-	require.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &listResp))
+	testsupport.DecodeData(t, listRec.Body.Bytes(), &listResp)
 	require.Len(t, listResp.Todos, 1)
 	require.Equal(t, todoID, listResp.Todos[0]["id"])
 
@@ -71,51 +77,344 @@ func TestCreateListUpdateDeleteTodoFlow(t *testing.T) {
 	updateRec := httptest.NewRecorder()
 	updateReq := httptest.NewRequest(http.MethodPut, "/todos/"+todoID, bytes.NewReader(updateBody))
 	updateReq.Header.Set("Content-Type", "application/json")
-	app.router.ServeHTTP(updateRec, updateReq)
+	app.Router.ServeHTTP(updateRec, updateReq)
 	require.Equal(t, http.StatusOK, updateRec.Code)
 
 	var updateResp struct {
 		Todo map[string]interface{} `json:"todo"`
 	}
-	require.NoError(t, json.Unmarshal(updateRec.Body.Bytes(), &updateResp))
+	testsupport.DecodeData(t, updateRec.Body.Bytes(), &updateResp)
 	require.Equal(t, "Actualizada", updateResp.Todo["title"])
 	require.Equal(t, true, updateResp.Todo["completed"])
 
 	// delete todo
 	deleteRec := httptest.NewRecorder()
 	deleteReq := httptest.NewRequest(http.MethodDelete, "/todos/"+todoID, nil)
-	app.router.ServeHTTP(deleteRec, deleteReq)
+	app.Router.ServeHTTP(deleteRec, deleteReq)
 	require.Equal(t, http.StatusOK, deleteRec.Code)
 
 	// ensure list is empty after delete
 	listRec2 := httptest.NewRecorder()
 	listReq2 := httptest.NewRequest(http.MethodGet, "/todos?email=tasks@example.com", nil)
-	app.router.ServeHTTP(listRec2, listReq2)
+	app.Router.ServeHTTP(listRec2, listReq2)
 	require.Equal(t, http.StatusOK, listRec2.Code)
-	require.NoError(t, json.Unmarshal(listRec2.Body.Bytes(), &listResp))
+	testsupport.DecodeData(t, listRec2.Body.Bytes(), &listResp)
 	require.Len(t, listResp.Todos, 0)
 }
 
 func TestTodoValidationErrors(t *testing.T) {
-	app := newTestApp()
+	app := testsupport.NewApp()
 
 	// create with invalid payload
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodPost, "/todos", bytes.NewReader([]byte(`{"email":"","title":""}`)))
 	req.Header.Set("Content-Type", "application/json")
-	app.router.ServeHTTP(rec, req)
+	app.Router.ServeHTTP(rec, req)
 	require.Equal(t, http.StatusBadRequest, rec.Code)
 
 	// update with invalid ID
 	updateRec := httptest.NewRecorder()
 	updateReq := httptest.NewRequest(http.MethodPut, "/todos/invalid-id", bytes.NewReader([]byte(`{"completed":true}`)))
 	updateReq.Header.Set("Content-Type", "application/json")
-	app.router.ServeHTTP(updateRec, updateReq)
+	app.Router.ServeHTTP(updateRec, updateReq)
 	require.Equal(t, http.StatusBadRequest, updateRec.Code)
 
 	// delete with invalid ID
 	deleteRec := httptest.NewRecorder()
 	deleteReq := httptest.NewRequest(http.MethodDelete, "/todos/invalid-id", nil)
-	app.router.ServeHTTP(deleteRec, deleteReq)
+	app.Router.ServeHTTP(deleteRec, deleteReq)
 	require.Equal(t, http.StatusBadRequest, deleteRec.Code)
+
+	// the ID is checked before the payload
+	rec = app.Do(http.MethodPost, "/todos/invalid-id/restore", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_ID")
+	rec = app.Do(http.MethodPut, "/todos/invalid-id", map[string]string{}, nil)
+	require.Contains(t, rec.Body.String(), "INVALID_ID")
+}
+
+func TestTodoIDsAndTimestampsComeFromTheClock(t *testing.T) {
+	app := testsupport.NewApp()
+	expected := testsupport.NewClock(testsupport.FixedTime)
+
+	first := createTodo(t, app.Router, "ana@example.com", "Uno")
+	second := createTodo(t, app.Router, "ana@example.com", "Dos")
+	require.Equal(t, expected.NewID().Hex(), first.ID)
+	require.Equal(t, expected.NewID().Hex(), second.ID)
+	require.True(t, testsupport.FixedTime.Equal(first.CreatedAt))
+
+	app.Clock.Advance(time.Hour)
+	expected.Advance(time.Hour)
+	third := createTodo(t, app.Router, "ana@example.com", "Tres")
+	require.Equal(t, expected.NewID().Hex(), third.ID)
+	require.True(t, testsupport.FixedTime.Add(time.Hour).Equal(third.CreatedAt))
+}
+
+func TestToggleAllAndClearCompletedOnlyTouchTheSignedInUser(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	first := createTodo(t, app.Router, "ana@hotel.com", "Comprar sabanas")
+	createTodo(t, app.Router, "ana@hotel.com", "Revisar minibar")
+	other := createTodo(t, app.Router, "beto@hotel.com", "Llamar al tecnico")
+
+	rec := app.Do(http.MethodPut, "/todos/"+first.ID, map[string]bool{"completed": true}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, app.Outbox.OfType(events.TodoCompleted), 1)
+
+	rec = app.Do(http.MethodPost, "/todos/toggle-all", map[string]bool{"completed": true}, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.JSONEq(t, `{"completed":true,"updated":1}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+	require.Len(t, app.Outbox.OfType(events.TodoCompleted), 2, "only the todo that changed is announced")
+
+	rec = app.Do(http.MethodPost, "/todos/toggle-all", map[string]bool{"completed": true}, ana)
+	require.JSONEq(t, `{"completed":true,"updated":0}`, testsupport.DataJSON(t, rec.Body.Bytes()), "repeating converges")
+
+	rec = app.Do(http.MethodDelete, "/todos/completed", nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.JSONEq(t, `{"deleted":2}`, testsupport.DataJSON(t, rec.Body.Bytes()))
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com"))
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com&trashed=true"), 2, "cleared todos can be restored")
+
+	remaining := listTodos(t, app.Router, "/todos?email=beto@hotel.com")
+	require.Len(t, remaining, 1)
+	require.Equal(t, other.ID, remaining[0].ID)
+}
+
+func TestBulkTodoEndpointsRequireASession(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/todos/toggle-all", map[string]bool{"completed": true}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = app.Do(http.MethodDelete, "/todos/completed", nil, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	rec = app.Do(http.MethodPost, "/todos/toggle-all", map[string]string{}, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code, "the target state is required")
+}
+
+func TestTodoColorAndIconLabels(t *testing.T) {
+	app := testsupport.NewApp()
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@hotel.com", "title": "Cambiar sabanas", "color": "Blue", "icon": "bed"}, nil)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Todo struct {
+			ID    string `json:"id"`
+			Color string `json:"color"`
+			Icon  string `json:"icon"`
+		} `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, "blue", created.Todo.Color)
+	require.Equal(t, "bed", created.Todo.Icon)
+	createTodo(t, app.Router, "ana@hotel.com", "Sin etiqueta")
+
+	blue := listTodos(t, app.Router, "/todos?email=ana@hotel.com&color=blue")
+	require.Len(t, blue, 1)
+	require.Equal(t, created.Todo.ID, blue[0].ID)
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com&icon=key"))
+
+	rec = app.Do(http.MethodPut, "/todos/"+created.Todo.ID, map[string]string{"color": "", "icon": "broom"}, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Empty(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com&color=blue"), "an empty color removes it")
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com&icon=broom"), 1)
+
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@hotel.com", "title": "x", "color": "magenta"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_TODO_LABEL")
+	rec = app.Do(http.MethodPut, "/todos/"+created.Todo.ID, map[string]string{"icon": "rocket"}, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodGet, "/todos?color=magenta", nil, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestTodoReactionsAreCountedOncePerUser(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID + "/reactions"
+
+	type reactions struct {
+		Todo struct {
+			Reactions []struct {
+				Emoji string `json:"emoji"`
+				Count int    `json:"count"`
+			} `json:"reactions"`
+		} `json:"todo"`
+	}
+	react := func(headers map[string]string, emoji string) reactions {
+		t.Helper()
+		rec := app.Do(http.MethodPost, path, map[string]string{"emoji": emoji}, headers)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body reactions
+		testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+		return body
+	}
+
+	react(ana, "👍")
+	react(beto, "🎉")
+	body := react(beto, "👍")
+	require.Len(t, body.Todo.Reactions, 2)
+	require.Equal(t, "👍", body.Todo.Reactions[0].Emoji)
+	require.Equal(t, 2, body.Todo.Reactions[0].Count)
+	require.NotContains(t, app.Do(http.MethodGet, "/todos?email=ana@hotel.com", nil, nil).Body.String(), "beto@hotel.com")
+
+	react(beto, "👍")
+	require.Len(t, app.Outbox.OfType(events.TodoReactionAdded), 3, "repeating a reaction changes nothing")
+
+	rec := app.Do(http.MethodDelete, path+"?emoji=👍", nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	require.Equal(t, 1, body.Todo.Reactions[0].Count)
+	removed := app.Outbox.OfType(events.TodoReactionRemoved)
+	require.Len(t, removed, 1)
+	require.Equal(t, todo.ID, removed[0].Event.Key)
+	require.NotContains(t, string(removed[0].Event.Data), "beto")
+}
+
+func TestTodoReactionErrors(t *testing.T) {
+	app := testsupport.NewApp()
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Preparar la suite")
+	path := "/todos/" + todo.ID + "/reactions"
+
+	rec := app.Do(http.MethodPost, path, map[string]string{"emoji": "👍"}, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	rec = app.Do(http.MethodPost, path, map[string]string{"emoji": "🦄"}, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "INVALID_REACTION")
+	rec = app.Do(http.MethodPost, "/todos/000000000000000000000000/reactions", map[string]string{"emoji": "👍"}, ana)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestTodoClientIDs(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	beto := app.LoginAs(t, "beto@hotel.com", "")
+	const clientID = "0b7e4d52-1111-4aaa-9bbb-0000000000aa"
+
+	rec := app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@hotel.com", "title": "Revisar minibar", "clientId": "0B7E4D52-1111-4AAA-9BBB-0000000000AA"}, ana)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		Todo struct {
+			ID       string `json:"id"`
+			ClientID string `json:"clientId"`
+		} `json:"todo"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &created)
+	require.Equal(t, clientID, created.Todo.ClientID)
+
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@hotel.com", "title": "Otra", "clientId": clientID}, ana)
+	require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "DUPLICATE_CLIENT_ID")
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "ana@hotel.com", "title": "Otra", "clientId": "todo-1"}, ana)
+	require.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "INVALID_CLIENT_ID")
+	// Client IDs are unique per owner.
+	rec = app.Do(http.MethodPost, "/todos", map[string]string{"email": "beto@hotel.com", "title": "Revisar caldera", "clientId": clientID}, beto)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	rec = app.Do(http.MethodGet, "/todos?clientId="+clientID, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), created.Todo.ID)
+	require.NotContains(t, rec.Body.String(), "Revisar caldera")
+
+	// The todo routes take the client ID in place of the ObjectID.
+	rec = app.Do(http.MethodPut, "/todos/"+clientID, map[string]any{"completed": true}, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), created.Todo.ID)
+	rec = app.Do(http.MethodGet, "/todos/"+clientID+"/title", nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "Revisar minibar")
+	rec = app.Do(http.MethodDelete, "/todos/0b7e4d52-1111-4aaa-9bbb-0000000000bb", nil, ana)
+	require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+
+	// Beto's todo with the same client ID stays his.
+	rec = app.Do(http.MethodDelete, "/todos/"+clientID, nil, beto)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = app.Do(http.MethodGet, "/todos?clientId="+clientID, nil, ana)
+	require.Contains(t, rec.Body.String(), created.Todo.ID)
+}
+
+func TestGetTodoAnswersNotModified(t *testing.T) {
+	app := testsupport.NewApp()
+	ana := app.LoginAs(t, "ana@hotel.com", "")
+	todo := createTodo(t, app.Router, "ana@hotel.com", "Revisar minibar")
+	path := "/todos/" + todo.ID
+
+	rec := app.Do(http.MethodGet, path, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Contains(t, rec.Body.String(), "Revisar minibar")
+	etag := rec.Header().Get("ETag")
+	require.Regexp(t, `^W/"[0-9a-f]{32}"$`, etag)
+
+	get := func(headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+		merged := map[string]string{}
+		for k, v := range ana {
+			merged[k] = v
+		}
+		for k, v := range headers {
+			merged[k] = v
+		}
+		return app.Do(http.MethodGet, path, nil, merged)
+	}
+	rec = get(map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Empty(t, rec.Body.String())
+	require.Equal(t, etag, rec.Header().Get("ETag"))
+	// The XML representation shares the weak tag.
+	rec = get(map[string]string{"If-None-Match": `"abc", ` + etag, "Accept": "application/xml"})
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	rec = app.Do(http.MethodPut, path, map[string]any{"completed": true}, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = get(map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotEqual(t, etag, rec.Header().Get("ETag"))
+
+	rec = app.Do(http.MethodGet, "/todos/000000000000000000000000", nil, ana)
+	require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+	// A todo in the trash is not served, whatever tag the client has.
+	rec = app.Do(http.MethodDelete, path, nil, ana)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = get(map[string]string{"If-None-Match": etag})
+	require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+}
+
+// ownedTodos returns the live todos of email straight from the repository.
+func ownedTodos(t *testing.T, app *testsupport.App, email string) []services.Todo {
+	t.Helper()
+	todos, err := app.Todos.List(context.Background(), services.TodoQuery{Email: email})
+	require.NoError(t, err)
+	return todos
+}
+
+func TestTodosBelongToTheAccountOfTheirEmail(t *testing.T) {
+	ctx := context.Background()
+	app := testsupport.NewApp()
+	createTodo(t, app.Router, "ana@hotel.com", "Antes del registro")
+	todos := ownedTodos(t, app, "ana@hotel.com")
+	require.Len(t, todos, 1)
+	require.Nil(t, todos[0].UserID)
+
+	// Signing up hands the account the todos of its email.
+	app.Register(t, "ana@hotel.com")
+	ana, err := app.Users.FindByEmail(ctx, "ana@hotel.com")
+	require.NoError(t, err)
+	todos = ownedTodos(t, app, "ana@hotel.com")
+	require.Len(t, todos, 1)
+	require.Equal(t, &ana.ID, todos[0].UserID)
+	createTodo(t, app.Router, "ana@hotel.com", "Despues del registro")
+	for _, todo := range ownedTodos(t, app, "ana@hotel.com") {
+		require.Equal(t, &ana.ID, todo.UserID, todo.Title)
+	}
+
+	// The owner is the account, not the copy of its email.
+	_, err = app.Todos.Create(ctx, services.Todo{UserID: &ana.ID, Email: "vieja@hotel.com", Title: "Copia vieja", CreatedAt: testsupport.FixedTime})
+	require.NoError(t, err)
+	require.Len(t, listTodos(t, app.Router, "/todos?email=ana@hotel.com"), 3)
 }
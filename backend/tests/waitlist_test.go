@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ignaciomagoia/tp6ingdesoft/backend/internal/testsupport"
+)
+
+type waitlistBody struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	Status    string `json:"status"`
+	BookingID string `json:"bookingId"`
+}
+
+func joinWaitlist(t *testing.T, app *testsupport.App, email string) waitlistBody {
+	t.Helper()
+	rec := app.Do(http.MethodPost, "/waitlist", map[string]interface{}{
+		"email": email, "guests": 2, "checkIn": "2025-01-01", "checkOut": "2025-01-03",
+	}, app.StaffHeaders(t))
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	var body struct {
+		Entry waitlistBody `json:"entry"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Entry
+}
+
+func listWaitlist(t *testing.T, app *testsupport.App) []waitlistBody {
+	t.Helper()
+	rec := app.Do(http.MethodGet, "/waitlist", nil, app.StaffHeaders(t))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body struct {
+		Entries []waitlistBody `json:"entries"`
+	}
+	testsupport.DecodeData(t, rec.Body.Bytes(), &body)
+	return body.Entries
+}
+
+func TestJoinWaitlistOnlyWhenFullyBooked(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	staff := app.StaffHeaders(t)
+
+	entry := map[string]interface{}{"email": "ana@example.com", "guests": 2, "checkIn": "2025-01-01", "checkOut": "2025-01-03"}
+	rec := app.Do(http.MethodPost, "/waitlist", entry, staff)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "ROOMS_AVAILABLE")
+
+	createBooking(t, app, room.ID, "2025-01-02", "2025-01-04")
+	created := joinWaitlist(t, app, "Ana@Example.com")
+	require.Equal(t, "ana@example.com", created.Email)
+	require.Equal(t, "waiting", created.Status)
+
+	rec = app.Do(http.MethodPost, "/waitlist", map[string]interface{}{
+		"email": "ana@example.com", "guests": 2, "roomType": "castle", "checkIn": "2025-01-01", "checkOut": "2025-01-03",
+	}, staff)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodPost, "/waitlist", map[string]interface{}{
+		"email": "ana@example.com", "guests": 2, "checkIn": "2025-01-03", "checkOut": "2025-01-01",
+	}, staff)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = app.Do(http.MethodPost, "/waitlist", entry, nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestCancellationOffersRoomInOrder(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-01", "2025-01-03")
+	first := joinWaitlist(t, app, "primero@example.com")
+	second := joinWaitlist(t, app, "segundo@example.com")
+	staff := app.StaffHeaders(t)
+
+	rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/cancel", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, app.Waitlist.Process(context.Background()))
+	require.Equal(t, []string{"primero@example.com"}, app.Notifier.Emails())
+
+	entries := listWaitlist(t, app)
+	require.Len(t, entries, 2)
+	require.Equal(t, first.ID, entries[0].ID)
+	require.Equal(t, "offered", entries[0].Status)
+	require.Equal(t, second.ID, entries[1].ID)
+	require.Equal(t, "waiting", entries[1].Status)
+
+	rec = app.Do(http.MethodGet, "/bookings/"+entries[0].BookingID, nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "held", decodeBooking(t, rec.Body.Bytes()).Status)
+	rec = app.Do(http.MethodPost, "/bookings", map[string]interface{}{
+		"roomId": room.ID, "email": "otro@example.com", "guests": 1, "checkIn": "2025-01-01", "checkOut": "2025-01-02",
+	}, staff)
+	require.Equal(t, http.StatusConflict, rec.Code, "the held room cannot be sold to someone else")
+
+	rec = app.Do(http.MethodPost, "/waitlist/"+second.ID+"/confirm", nil, staff)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "WAITLIST_NOT_OFFERED")
+
+	rec = app.Do(http.MethodPost, "/waitlist/"+first.ID+"/confirm", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	confirmed := decodeBooking(t, rec.Body.Bytes())
+	require.Equal(t, "booked", confirmed.Status)
+	require.Equal(t, "primero@example.com", confirmed.Email)
+	require.Equal(t, "booked", listWaitlist(t, app)[0].Status)
+}
+
+func TestExpiredHoldPassesToNextGuest(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-01", "2025-01-03")
+	first := joinWaitlist(t, app, "primero@example.com")
+	joinWaitlist(t, app, "segundo@example.com")
+	staff := app.StaffHeaders(t)
+
+	rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/cancel", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, app.Waitlist.Process(context.Background()))
+
+	app.Clock.Advance(testsupport.WaitlistHold)
+	rec = app.Do(http.MethodPost, "/waitlist/"+first.ID+"/confirm", nil, staff)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Contains(t, rec.Body.String(), "WAITLIST_HOLD_EXPIRED")
+
+	require.NoError(t, app.Waitlist.Process(context.Background()))
+	require.Equal(t, []string{"primero@example.com", "segundo@example.com"}, app.Notifier.Emails())
+	entries := listWaitlist(t, app)
+	require.Equal(t, "expired", entries[0].Status)
+	require.Equal(t, "offered", entries[1].Status)
+
+	rec = app.Do(http.MethodGet, "/bookings/"+entries[0].BookingID, nil, staff)
+	require.Equal(t, "cancelled", decodeBooking(t, rec.Body.Bytes()).Status)
+}
+
+func TestWaitlistWorkerRunsOnCancellation(t *testing.T) {
+	app := testsupport.NewApp()
+	room := createRoom(t, app, map[string]interface{}{"number": "101", "type": "double", "capacity": 2, "price": 100})
+	booking := createBooking(t, app, room.ID, "2025-01-01", "2025-01-03")
+	joinWaitlist(t, app, "ana@example.com")
+	staff := app.StaffHeaders(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.Waitlist.Run(ctx, time.Hour)
+
+	rec := app.Do(http.MethodPost, "/bookings/"+booking.ID+"/cancel", nil, staff)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Eventually(t, func() bool {
+		return len(app.Notifier.Emails()) == 1
+	}, time.Second, 10*time.Millisecond)
+}
@@ -7,6 +7,7 @@ import Toast from "./components/Toast";
 import {
   registerUser,
   loginUser,
+  loginWithPasskey,
   getTodos,
   createTodo,
   updateTodo,
@@ -80,6 +81,15 @@ function App() {
     }
   };
 
+  const handlePasskeyLogin = async ({ email }) => {
+    try {
+      const response = await loginWithPasskey({ email });
+      handleAuthSuccess(email, response.message ?? "Inicio de sesión exitoso");
+    } catch (error) {
+      showToast(error.message, "error");
+    }
+  };
+
   const handleLogout = () => {
     setCurrentUser("");
     setTodos([]);
@@ -133,6 +143,7 @@ function App() {
         <RegisterForm
           onRegister={handleRegister}
           onLogin={handleLogin}
+          onPasskeyLogin={window.PublicKeyCredential ? handlePasskeyLogin : undefined}
           disabled={Boolean(currentUser)}
           defaultEmail={currentUser}
         />
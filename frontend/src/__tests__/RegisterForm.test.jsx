@@ -30,4 +30,29 @@ describe("RegisterForm", () => {
       password: "secret",
     });
   });
+
+  it("inicia sesión con passkey sin pedir la contraseña", async () => {
+    const handlePasskeyLogin = jest.fn();
+    render(
+      <RegisterForm
+        onRegister={jest.fn()}
+        onLogin={jest.fn()}
+        onPasskeyLogin={handlePasskeyLogin}
+      />
+    );
+
+    await act(async () => {
+      await userEvent.type(screen.getByLabelText(/email/i), "User@Test.com");
+      await userEvent.click(screen.getByRole("button", { name: /passkey/i }));
+    });
+
+    expect(handlePasskeyLogin).toHaveBeenCalledWith({ email: "user@test.com" });
+    expect(screen.queryByText(/la contraseña es requerida/i)).not.toBeInTheDocument();
+  });
+
+  it("oculta el acceso con passkey si no está disponible", () => {
+    render(<RegisterForm onRegister={jest.fn()} onLogin={jest.fn()} />);
+
+    expect(screen.queryByRole("button", { name: /passkey/i })).not.toBeInTheDocument();
+  });
 });
@@ -7,6 +7,7 @@ const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
 export default function RegisterForm({
   onRegister,
   onLogin,
+  onPasskeyLogin,
   disabled = false,
   defaultEmail = "",
 }) {
@@ -20,7 +21,7 @@ export default function RegisterForm({
     setValues((prev) => ({ ...prev, [field]: event.target.value }));
   };
 
-  const validate = () => {
+  const validate = ({ requirePassword = true } = {}) => {
     const newErrors = {};
     const trimmedEmail = values.email.trim().toLowerCase();
 
@@ -30,7 +31,7 @@ export default function RegisterForm({
       newErrors.email = "El email no tiene un formato válido";
     }
 
-    if (!values.password.trim()) {
+    if (requirePassword && !values.password.trim()) {
       newErrors.password = "La contraseña es requerida";
     }
 
@@ -57,6 +58,18 @@ export default function RegisterForm({
     setValues((prev) => ({ ...prev, password: "" }));
   };
 
+  const handlePasskeyLogin = async () => {
+    if (disabled) {
+      return;
+    }
+
+    const { isValid, trimmedEmail } = validate({ requirePassword: false });
+    if (!isValid) {
+      return;
+    }
+    await onPasskeyLogin({ email: trimmedEmail });
+  };
+
   return (
     <section aria-labelledby="auth-section-title" className="panel panel--auth">
       <header className="panel__header">
@@ -121,6 +134,16 @@ export default function RegisterForm({
         >
           Iniciar sesión
         </button>
+        {onPasskeyLogin && (
+          <button
+            type="button"
+            className="btn btn--outline"
+            onClick={handlePasskeyLogin}
+            disabled={disabled}
+          >
+            Ingresar con passkey
+          </button>
+        )}
       </div>
     </section>
   );
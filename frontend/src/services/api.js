@@ -10,7 +10,8 @@ async function handleResponse(response) {
     throw new Error(message);
   }
 
-  return payload;
+  // Successful responses come wrapped in {data, meta}.
+  return payload.data ?? payload;
 }
 
 export async function registerUser({ email, password }) {
@@ -31,6 +32,61 @@ export async function loginUser({ email, password }) {
   return handleResponse(response);
 }
 
+function fromBase64url(value) {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+  const binary = atob(base64.padEnd(Math.ceil(base64.length / 4) * 4, "="));
+  return Uint8Array.from(binary, (char) => char.charCodeAt(0)).buffer;
+}
+
+function toBase64url(buffer) {
+  const binary = String.fromCharCode(...new Uint8Array(buffer));
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+// loginWithPasskey runs the WebAuthn login ceremony: the backend issues a
+// challenge, the browser signs it with a passkey of the account and the
+// backend answers like loginUser.
+export async function loginWithPasskey({ email }) {
+  const optionsResponse = await fetch(`${API_URL}/login/passkey/options`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ email }),
+  });
+  const { ceremonyId, publicKey } = await handleResponse(optionsResponse);
+
+  const credential = await navigator.credentials.get({
+    publicKey: {
+      ...publicKey,
+      challenge: fromBase64url(publicKey.challenge),
+      allowCredentials: publicKey.allowCredentials.map((allowed) => ({
+        ...allowed,
+        id: fromBase64url(allowed.id),
+      })),
+    },
+  });
+  if (!credential) {
+    throw new Error("No se seleccionó ninguna passkey");
+  }
+
+  const response = await fetch(`${API_URL}/login/passkey`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({
+      ceremonyId,
+      credential: {
+        id: credential.id,
+        type: credential.type,
+        response: {
+          clientDataJSON: toBase64url(credential.response.clientDataJSON),
+          authenticatorData: toBase64url(credential.response.authenticatorData),
+          signature: toBase64url(credential.response.signature),
+        },
+      },
+    }),
+  });
+  return handleResponse(response);
+}
+
 export async function getTodos(email) {
   const url = new URL(`${API_URL}/todos`);
   if (email) {